@@ -0,0 +1,134 @@
+// Package access decides who may see a file. Every file has a sensitivity
+// level; the level and the departments the file is shared with (through
+// file_department) decide who can read its metadata, OCR text and
+// summaries.
+package access
+
+import "strings"
+
+// Sensitivity levels, from least to most protected.
+const (
+	// Internal files are visible to every active user. This is the default
+	// and matches how files behaved before levels existed.
+	Internal = "internal"
+	// Confidential files are visible to the uploader and to members of the
+	// departments the file is shared with.
+	Confidential = "confidential"
+	// Restricted files are visible to the uploader and to the heads of the
+	// departments the file is shared with.
+	Restricted = "restricted"
+)
+
+// Levels lists the sensitivity levels in increasing order.
+var Levels = []string{Internal, Confidential, Restricted}
+
+// ValidLevel reports whether level is a known sensitivity level.
+func ValidLevel(level string) bool {
+	for _, l := range Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Sensitive reports whether files at level need access logging and are kept
+// out of emails.
+func Sensitive(level string) bool {
+	return level == Confidential || level == Restricted
+}
+
+// Viewer is the user asking for a file.
+type Viewer struct {
+	UUID     string
+	DUUID    string
+	Position string
+	Active   bool
+}
+
+func (v Viewer) IsHead() bool { return v.Position == "head" }
+
+// File is what access decisions need to know about a file.
+type File struct {
+	FUUID       string
+	OwnerUUID   string
+	Sensitivity string
+	// Departments the file is shared with.
+	Departments []string
+	// WorkspaceDepartments reach the file through a workspace it is linked
+	// into. They may read it like Departments but do not manage it.
+	WorkspaceDepartments []string
+	// WorkflowDepartments have an approval step pending on the file. They
+	// read it like WorkspaceDepartments until the step is decided.
+	WorkflowDepartments []string
+}
+
+// reaches reports whether the file is shared with dUUID directly, through a
+// workspace or through a pending approval.
+func (f File) reaches(dUUID string) bool {
+	if dUUID == "" {
+		return false
+	}
+	for _, list := range [][]string{f.Departments, f.WorkspaceDepartments, f.WorkflowDepartments} {
+		for _, d := range list {
+			if strings.EqualFold(d, dUUID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decision is the outcome of Check. Reason says why, for the audit log.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+func allow(reason string) Decision { return Decision{Allowed: true, Reason: reason} }
+func deny(reason string) Decision  { return Decision{Reason: reason} }
+
+// Check decides whether v may see f. Unknown levels are treated as
+// restricted so a bad value never widens access.
+func Check(v Viewer, f File) Decision {
+	if v.UUID == "" || !v.Active {
+		return deny("inactive or unknown user")
+	}
+	if f.OwnerUUID != "" && strings.EqualFold(f.OwnerUUID, v.UUID) {
+		return allow("uploader")
+	}
+	if f.Sensitivity == "" || f.Sensitivity == Internal {
+		return allow("internal document")
+	}
+	if !f.reaches(v.DUUID) {
+		return deny("file is not shared with the user's department")
+	}
+	if f.Sensitivity == Confidential {
+		return allow("department member")
+	}
+	if v.IsHead() {
+		return allow("department head")
+	}
+	return deny("restricted to department heads")
+}
+
+// CheckDownload decides whether v may download f's stored object. On top of
+// Check, the object itself is only served to the uploader and to members
+// of the departments the file is shared with (directly or through a
+// workspace), whatever its level.
+func CheckDownload(v Viewer, f File) Decision {
+	d := Check(v, f)
+	if !d.Allowed || d.Reason == "uploader" {
+		return d
+	}
+	if f.reaches(v.DUUID) {
+		return d
+	}
+	return deny("file is not shared with the user's department")
+}
+
+// InlineOnly reports whether files at level may only be displayed, never
+// served as attachments or through signed URLs that outlive the check.
+func InlineOnly(level string) bool {
+	return level != "" && level != Internal
+}
@@ -0,0 +1,123 @@
+package access
+
+import "testing"
+
+const (
+	ops   = "11111111-1111-4111-8111-111111111111"
+	legal = "22222222-2222-4222-8222-222222222222"
+)
+
+func TestCheck(t *testing.T) {
+	owner := Viewer{UUID: "owner", DUUID: legal, Active: true}
+	member := Viewer{UUID: "member", DUUID: ops, Active: true}
+	head := Viewer{UUID: "head", DUUID: ops, Position: "head", Active: true}
+	outsider := Viewer{UUID: "outsider", DUUID: legal, Position: "head", Active: true}
+	inactive := Viewer{UUID: "member", DUUID: ops}
+
+	file := func(level string) File {
+		return File{FUUID: "f1", OwnerUUID: "owner", Sensitivity: level, Departments: []string{ops}}
+	}
+	cases := []struct {
+		level  string
+		viewer Viewer
+		want   bool
+	}{
+		{Internal, outsider, true},
+		{"", outsider, true},
+		{Internal, inactive, false},
+		{Confidential, owner, true},
+		{Confidential, member, true},
+		{Confidential, outsider, false},
+		{Restricted, owner, true},
+		{Restricted, head, true},
+		{Restricted, member, false},
+		{Restricted, outsider, false},
+		{"top-secret", head, true},
+		{"top-secret", member, false},
+	}
+	for _, c := range cases {
+		d := Check(c.viewer, file(c.level))
+		if d.Allowed != c.want {
+			t.Errorf("Check(%s, %q) = %+v, want allowed=%v", c.viewer.UUID, c.level, d, c.want)
+		}
+		if d.Reason == "" {
+			t.Errorf("Check(%s, %q) has no reason", c.viewer.UUID, c.level)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	for _, l := range Levels {
+		if !ValidLevel(l) {
+			t.Errorf("%q should be valid", l)
+		}
+	}
+	if ValidLevel("secret") || ValidLevel("") {
+		t.Error("unknown levels must be invalid")
+	}
+	if Sensitive(Internal) || !Sensitive(Confidential) || !Sensitive(Restricted) {
+		t.Error("only confidential and restricted are sensitive")
+	}
+}
+
+func TestCheckDownload(t *testing.T) {
+	owner := Viewer{UUID: "owner", DUUID: legal, Active: true}
+	member := Viewer{UUID: "member", DUUID: ops, Active: true}
+	outsider := Viewer{UUID: "outsider", DUUID: legal, Position: "head", Active: true}
+
+	file := func(level string) File {
+		return File{FUUID: "f1", OwnerUUID: "owner", Sensitivity: level, Departments: []string{ops}}
+	}
+	cases := []struct {
+		level  string
+		viewer Viewer
+		want   bool
+	}{
+		{Internal, owner, true},
+		{Internal, member, true},
+		{Internal, outsider, false},
+		{Confidential, member, true},
+		{Restricted, member, false},
+		{Restricted, owner, true},
+	}
+	for _, c := range cases {
+		if d := CheckDownload(c.viewer, file(c.level)); d.Allowed != c.want {
+			t.Errorf("CheckDownload(%s, %q) = %+v, want allowed=%v", c.viewer.UUID, c.level, d, c.want)
+		}
+	}
+	if InlineOnly(Internal) || InlineOnly("") || !InlineOnly(Confidential) || !InlineOnly(Restricted) {
+		t.Error("only confidential and restricted files are inline-only")
+	}
+}
+
+func TestWorkspaceDepartments(t *testing.T) {
+	member := Viewer{UUID: "member", DUUID: legal, Active: true}
+	head := Viewer{UUID: "head", DUUID: legal, Position: "head", Active: true}
+
+	linked := func(level string) File {
+		return File{FUUID: "f1", OwnerUUID: "owner", Sensitivity: level, Departments: []string{ops}, WorkspaceDepartments: []string{legal}}
+	}
+	if !Check(member, linked(Confidential)).Allowed || !CheckDownload(member, linked(Confidential)).Allowed {
+		t.Error("workspace departments should read confidential files like the file's own departments")
+	}
+	if Check(member, linked(Restricted)).Allowed || !Check(head, linked(Restricted)).Allowed {
+		t.Error("restricted files linked into a workspace are still limited to heads")
+	}
+	unlinked := linked(Confidential)
+	unlinked.WorkspaceDepartments = nil
+	if Check(member, unlinked).Allowed {
+		t.Error("without the workspace the file must stay hidden")
+	}
+}
+
+func TestWorkflowDepartments(t *testing.T) {
+	member := Viewer{UUID: "member", DUUID: legal, Active: true}
+	pending := File{FUUID: "f1", OwnerUUID: "owner", Sensitivity: Confidential, Departments: []string{ops}, WorkflowDepartments: []string{legal}}
+	if !CheckDownload(member, pending).Allowed {
+		t.Error("a department asked to approve should read the file")
+	}
+	pending.WorkflowDepartments = nil
+	if Check(member, pending).Allowed {
+		t.Error("access should end once the step is decided")
+	}
+}
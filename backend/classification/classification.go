@@ -0,0 +1,205 @@
+// Package classification assigns documents a category from the
+// admin-managed taxonomy. The configured LLM answers in a constrained JSON
+// format; when it is unavailable or unsure, keyword scoring decides.
+package classification
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Methods record how a classification was reached.
+const (
+	MethodLLM     = "llm"
+	MethodKeyword = "keyword"
+	MethodNone    = "none"
+)
+
+const (
+	MaxNameLen        = 60
+	MaxDescriptionLen = 500
+	MaxKeywords       = 50
+	MaxKeywordLen     = 60
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+type Category struct {
+	ID          string    `json:"category_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Keywords    []string  `json:"keywords"`
+	Enabled     bool      `json:"enabled"`
+	FileCount   int       `json:"file_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Result is a document's classification. CategoryID and Category are empty
+// when nothing fit. Scores are the keyword scores by category name, kept
+// for every method so admins can see why the fallback would have chosen.
+type Result struct {
+	FUUID        string             `json:"f_uuid,omitempty"`
+	CategoryID   string             `json:"category_id,omitempty"`
+	Category     string             `json:"category,omitempty"`
+	Confidence   float64            `json:"confidence"`
+	Method       string             `json:"method"`
+	Scores       map[string]float64 `json:"scores"`
+	Note         string             `json:"note,omitempty"`
+	ClassifiedAt time.Time          `json:"classified_at,omitempty"`
+}
+
+// Normalize trims c's fields, lowercases and de-duplicates keywords, and
+// checks lengths.
+func Normalize(c *Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	if c.Name == "" {
+		return invalid("name is required")
+	}
+	if utf8.RuneCountInString(c.Name) > MaxNameLen {
+		return invalid("name must be at most %d characters", MaxNameLen)
+	}
+	if strings.EqualFold(c.Name, "none") {
+		return invalid(`"none" is reserved`)
+	}
+	if utf8.RuneCountInString(c.Description) > MaxDescriptionLen {
+		return invalid("description must be at most %d characters", MaxDescriptionLen)
+	}
+	seen := map[string]bool{}
+	keywords := []string{}
+	for _, k := range c.Keywords {
+		k = normalizeText(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		if utf8.RuneCountInString(k) > MaxKeywordLen {
+			return invalid("keyword %q is longer than %d characters", k, MaxKeywordLen)
+		}
+		seen[k] = true
+		keywords = append(keywords, k)
+	}
+	if len(keywords) > MaxKeywords {
+		return invalid("at most %d keywords allowed", MaxKeywords)
+	}
+	c.Keywords = keywords
+	return nil
+}
+
+// normalizeText lowercases s and collapses whitespace, which OCR output is
+// full of.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// countPhrase counts occurrences of phrase in text on word boundaries. Both
+// must already be normalized.
+func countPhrase(text, phrase string) int {
+	if phrase == "" {
+		return 0
+	}
+	n := 0
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], phrase)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(phrase)
+		before, after := ' ', ' '
+		if start > 0 {
+			before, _ = utf8.DecodeLastRuneInString(text[:start])
+		}
+		if end < len(text) {
+			after, _ = utf8.DecodeRuneInString(text[end:])
+		}
+		if !isWordRune(before) && !isWordRune(after) {
+			n++
+			from = end
+		} else {
+			from = start + 1
+		}
+	}
+	return n
+}
+
+// Keyword scoring: each keyword (and the category name) counts up to
+// maxHitsPerKeyword times, so one repeated word cannot carry a category.
+const (
+	maxHitsPerKeyword = 3
+	// fullConfidenceScore is the score at which the winner's share of all
+	// hits is taken at face value; below it confidence is scaled down.
+	fullConfidenceScore = 6.0
+)
+
+// KeywordScores scores text against each enabled category.
+func KeywordScores(text string, categories []Category) map[string]float64 {
+	text = normalizeText(text)
+	scores := map[string]float64{}
+	for _, c := range categories {
+		if !c.Enabled {
+			continue
+		}
+		terms := append([]string{normalizeText(c.Name)}, c.Keywords...)
+		seen := map[string]bool{}
+		total := 0
+		for _, t := range terms {
+			t = normalizeText(t)
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			total += min(countPhrase(text, t), maxHitsPerKeyword)
+		}
+		scores[c.Name] = float64(total)
+	}
+	return scores
+}
+
+// KeywordClassify picks the category with the highest keyword score.
+// Confidence is the winner's share of all hits, scaled down while the
+// evidence is thin. Ties go to the first category by name.
+func KeywordClassify(text string, categories []Category) Result {
+	scores := KeywordScores(text, categories)
+	res := Result{Method: MethodNone, Scores: scores}
+
+	names := make([]string, 0, len(scores))
+	total := 0.0
+	for name, s := range scores {
+		names = append(names, name)
+		total += s
+	}
+	sort.Strings(names)
+	best, bestScore := "", 0.0
+	for _, name := range names {
+		if scores[name] > bestScore {
+			best, bestScore = name, scores[name]
+		}
+	}
+	if bestScore == 0 {
+		return res
+	}
+	conf := bestScore / total * math.Min(1, bestScore/fullConfidenceScore)
+	res.Method, res.Confidence = MethodKeyword, math.Round(conf*100)/100
+	for _, c := range categories {
+		if c.Name == best {
+			res.CategoryID, res.Category = c.ID, c.Name
+		}
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+package classification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeModel struct {
+	answer Answer
+	err    error
+	calls  int
+	names  []string
+}
+
+func (f *fakeModel) Classify(ctx context.Context, text string, categories []Category) (Answer, error) {
+	f.calls++
+	f.names = nil
+	for _, c := range categories {
+		f.names = append(f.names, c.Name)
+	}
+	return f.answer, f.err
+}
+
+func taxonomy() []Category {
+	return []Category{
+		{ID: "s", Name: "Safety", Keywords: []string{"incident", "fire", "near miss"}, Enabled: true},
+		{ID: "p", Name: "Procurement", Keywords: []string{"tender", "purchase order", "vendor"}, Enabled: true},
+		{ID: "h", Name: "HR", Keywords: []string{"leave", "payroll"}, Enabled: false},
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	c := Category{Name: "  Legal/Regulatory ", Keywords: []string{" Court ", "court", "", "STATUTORY  notice"}}
+	if err := Normalize(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Legal/Regulatory" || strings.Join(c.Keywords, "|") != "court|statutory notice" {
+		t.Errorf("normalized = %+v", c)
+	}
+	for _, bad := range []Category{{Name: ""}, {Name: "None"}, {Name: strings.Repeat("x", MaxNameLen+1)}} {
+		err := Normalize(&bad)
+		var v ValidationError
+		if !errors.As(err, &v) {
+			t.Errorf("Normalize(%q) = %v, want ValidationError", bad.Name, err)
+		}
+	}
+}
+
+func TestCountPhraseWordBoundaries(t *testing.T) {
+	text := normalizeText("Fire drill; FIREWALL upgrade. Near\n miss reported, fire.")
+	if n := countPhrase(text, "fire"); n != 2 {
+		t.Errorf("fire counted %d times, want 2 (not inside firewall)", n)
+	}
+	if n := countPhrase(text, "near miss"); n != 1 {
+		t.Errorf("near miss counted %d times, want 1 across a line break", n)
+	}
+}
+
+func TestKeywordClassify(t *testing.T) {
+	text := "Tender notice: purchase order for vendor supplied spares. Tender tender tender tender. One incident noted. Payroll payroll."
+	res := KeywordClassify(text, taxonomy())
+	if res.Method != MethodKeyword || res.Category != "Procurement" || res.CategoryID != "p" {
+		t.Fatalf("result = %+v, want keyword Procurement", res)
+	}
+	// Tender is capped at 3 hits: 3 + purchase order + vendor = 5 of 6 total.
+	if res.Scores["Procurement"] != 5 || res.Scores["Safety"] != 1 {
+		t.Errorf("scores = %v", res.Scores)
+	}
+	if _, ok := res.Scores["HR"]; ok {
+		t.Error("disabled categories must not be scored")
+	}
+	if res.Confidence <= 0 || res.Confidence >= 1 {
+		t.Errorf("confidence = %v, want between 0 and 1", res.Confidence)
+	}
+
+	none := KeywordClassify("Minutes of the weekly meeting", taxonomy())
+	if none.Method != MethodNone || none.Category != "" || none.Confidence != 0 {
+		t.Errorf("no hits should be none, got %+v", none)
+	}
+}
+
+func TestParseAnswer(t *testing.T) {
+	cats := taxonomy()
+	if a, err := parseAnswer(` {"category": "safety", "confidence": 0.91}`, cats); err != nil || a.Category != "Safety" || a.Confidence != 0.91 {
+		t.Errorf("parsed %+v, %v", a, err)
+	}
+	if a, err := parseAnswer(`{"category": "none", "confidence": 0.8}`, cats); err != nil || a.Category != "" {
+		t.Errorf("none parsed as %+v, %v", a, err)
+	}
+	for _, bad := range []string{`{"category": "Finance", "confidence": 0.9}`, `{"category": "Safety", "confidence": 3}`, `Safety`} {
+		if _, err := parseAnswer(bad, cats); err == nil {
+			t.Errorf("parseAnswer(%q) should fail", bad)
+		}
+	}
+	schema := answerSchema(cats)
+	enum := schema["properties"].(map[string]interface{})["category"].(map[string]interface{})["enum"].([]string)
+	if len(enum) != 4 || enum[3] != MethodNone {
+		t.Errorf("schema enum = %v", enum)
+	}
+}
+
+func TestServiceClassifyFallback(t *testing.T) {
+	store := NewMemoryStore()
+	for _, c := range taxonomy() {
+		if _, err := store.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := "Fire incident near the depot; incident report attached."
+
+	model := &fakeModel{answer: Answer{Category: "Procurement", Confidence: 0.83}}
+	svc := NewService(store, model)
+	res, err := svc.Classify(context.Background(), "f1", text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != MethodLLM || res.Category != "Procurement" || res.CategoryID == "" {
+		t.Errorf("confident LLM answer should stand, got %+v", res)
+	}
+	if len(model.names) != 2 {
+		t.Errorf("model saw %v, want only enabled categories", model.names)
+	}
+
+	cases := []struct {
+		name  string
+		model *fakeModel
+	}{
+		{"error", &fakeModel{err: errors.New("connection refused")}},
+		{"none", &fakeModel{answer: Answer{Confidence: 0.9}}},
+		{"low confidence", &fakeModel{answer: Answer{Category: "Procurement", Confidence: 0.3}}},
+	}
+	for _, c := range cases {
+		svc.Model = c.model
+		res, err := svc.Classify(context.Background(), "f1", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Method != MethodKeyword || res.Category != "Safety" || res.Note == "" {
+			t.Errorf("%s: want keyword fallback to Safety with a note, got %+v", c.name, res)
+		}
+	}
+
+	stored, _ := svc.Result("f1")
+	if stored == nil || stored.Category != "Safety" {
+		t.Fatalf("stored result = %+v", stored)
+	}
+
+	svc.Model = &fakeModel{}
+	res, _ = svc.Classify(context.Background(), "f2", "   ")
+	if res.Method != MethodNone || svc.Model.(*fakeModel).calls != 0 {
+		t.Errorf("empty text should skip the model, got %+v", res)
+	}
+}
+
+func TestServiceCategoryCRUD(t *testing.T) {
+	svc := NewService(NewMemoryStore(), nil)
+	c, err := svc.Create(Category{Name: "Safety", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(Category{Name: "safety"}); err == nil {
+		t.Error("duplicate name should be rejected")
+	}
+	if _, _, err := svc.Update(c.ID, Category{Name: "SAFETY", Enabled: true}); err != nil {
+		t.Errorf("renaming a category's own case should be allowed: %v", err)
+	}
+	if _, _, err := svc.Update("missing", Category{Name: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update missing: %v", err)
+	}
+	if err := svc.Delete(c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(c.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package classification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPromptChars bounds how much OCR text is put in the prompt.
+const maxPromptChars = 4000
+
+// Answer is a model's pick. Category is empty for none.
+type Answer struct {
+	Category   string
+	Confidence float64
+}
+
+// Model picks one of the enabled categories for a document.
+type Model interface {
+	Classify(ctx context.Context, text string, categories []Category) (Answer, error)
+}
+
+// LLMModel asks a llama.cpp completion server for a category. The answer is
+// constrained with a JSON schema so the model can only name a category from
+// the taxonomy (or none).
+type LLMModel struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewLLMModel(url string) *LLMModel {
+	return &LLMModel{URL: url, Client: &http.Client{Timeout: 45 * time.Second}}
+}
+
+func prompt(text string, categories []Category) string {
+	var b strings.Builder
+	b.WriteString("You file documents for a metro rail organisation. Pick the single category that best fits the document below.\n\nCategories:\n")
+	for _, c := range categories {
+		b.WriteString("- " + c.Name)
+		if c.Description != "" {
+			b.WriteString(": " + c.Description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("- none: nothing above fits\n\n")
+	b.WriteString("Answer with JSON only, in the form {\"category\": \"<category>\", \"confidence\": <0 to 1>}.\n\n")
+	b.WriteString("Document:\n")
+	b.WriteString(clip(text, maxPromptChars))
+	b.WriteString("\n\nAnswer:")
+	return b.String()
+}
+
+// answerSchema limits the completion to a category name (or none) and a
+// confidence between 0 and 1.
+func answerSchema(categories []Category) map[string]interface{} {
+	names := make([]string, 0, len(categories)+1)
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+	names = append(names, MethodNone)
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"category":   map[string]interface{}{"type": "string", "enum": names},
+			"confidence": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+		},
+		"required": []string{"category", "confidence"},
+	}
+}
+
+// parseAnswer reads the model's JSON answer. The schema should make it
+// well-formed, but servers without grammar support may ignore it, so the
+// category is still checked against the taxonomy.
+func parseAnswer(content string, categories []Category) (Answer, error) {
+	var raw struct {
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+	}
+	i, j := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if i < 0 || j <= i {
+		return Answer{}, fmt.Errorf("LLM answer is not JSON: %q", clip(content, 200))
+	}
+	if err := json.Unmarshal([]byte(content[i:j+1]), &raw); err != nil {
+		return Answer{}, fmt.Errorf("LLM answer is not JSON: %v", err)
+	}
+	if raw.Confidence < 0 || raw.Confidence > 1 {
+		return Answer{}, fmt.Errorf("LLM confidence %v is out of range", raw.Confidence)
+	}
+	name := strings.TrimSpace(raw.Category)
+	if strings.EqualFold(name, MethodNone) {
+		return Answer{Confidence: raw.Confidence}, nil
+	}
+	for _, c := range categories {
+		if strings.EqualFold(name, c.Name) {
+			return Answer{Category: c.Name, Confidence: raw.Confidence}, nil
+		}
+	}
+	return Answer{}, fmt.Errorf("LLM answered unknown category %q", name)
+}
+
+func (m *LLMModel) Classify(ctx context.Context, text string, categories []Category) (Answer, error) {
+	if m.URL == "" {
+		return Answer{}, fmt.Errorf("LLM_COMPLETION_URL is not configured")
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"prompt":      prompt(text, categories),
+		"json_schema": answerSchema(categories),
+		"n_predict":   64,
+		"temperature": 0,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return Answer{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to call LLM service: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Answer{}, fmt.Errorf("LLM service error (status %d): %s", resp.StatusCode, msg)
+	}
+	var out struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Answer{}, fmt.Errorf("failed to decode LLM response: %v", err)
+	}
+	return parseAnswer(out.Content, categories)
+}
+
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
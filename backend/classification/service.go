@@ -0,0 +1,179 @@
+package classification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("category not found")
+
+// MinLLMConfidence is the confidence below which an LLM answer is not
+// trusted and keyword scoring decides instead.
+const MinLLMConfidence = 0.5
+
+// Store persists the taxonomy and per-file results. Implementations:
+// NewPostgresStore and NewMemoryStore.
+type Store interface {
+	// Categories returns every category, enabled or not.
+	Categories() ([]Category, error)
+	// Category and CategoryByName return nil when there is no such category.
+	Category(id string) (*Category, error)
+	CategoryByName(name string) (*Category, error)
+	Create(c Category) (Category, error)
+	// Update and Delete return ErrNotFound for unknown categories.
+	Update(c Category) (Category, error)
+	Delete(id string) error
+	// Save replaces the file's classification.
+	Save(r Result) error
+	// Result returns nil when the file has not been classified.
+	Result(fuuid string) (*Result, error)
+}
+
+type Service struct {
+	store Store
+	Model Model
+}
+
+func NewService(store Store, model Model) *Service {
+	return &Service{store: store, Model: model}
+}
+
+// Categories lists the taxonomy by name.
+func (s *Service) Categories() ([]Category, error) {
+	cats, err := s.store.Categories()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cats, func(i, j int) bool { return strings.ToLower(cats[i].Name) < strings.ToLower(cats[j].Name) })
+	return cats, nil
+}
+
+// Enabled returns the names of the enabled categories.
+func (s *Service) Enabled() ([]string, error) {
+	cats, err := s.Categories()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, c := range cats {
+		if c.Enabled {
+			names = append(names, c.Name)
+		}
+	}
+	return names, nil
+}
+
+// check validates c and rejects names already used by another category.
+func (s *Service) check(c *Category) error {
+	if err := Normalize(c); err != nil {
+		return err
+	}
+	other, err := s.store.CategoryByName(c.Name)
+	if err != nil {
+		return err
+	}
+	if other != nil && other.ID != c.ID {
+		return invalid("category %q already exists", other.Name)
+	}
+	return nil
+}
+
+func (s *Service) Create(c Category) (Category, error) {
+	c.ID = ""
+	if err := s.check(&c); err != nil {
+		return Category{}, err
+	}
+	return s.store.Create(c)
+}
+
+// Update replaces category id with c. It returns the category as it was
+// before, for auditing, and as stored.
+func (s *Service) Update(id string, c Category) (before, after Category, err error) {
+	old, err := s.store.Category(id)
+	if err != nil {
+		return Category{}, Category{}, err
+	}
+	if old == nil {
+		return Category{}, Category{}, ErrNotFound
+	}
+	c.ID = id
+	if err := s.check(&c); err != nil {
+		return Category{}, Category{}, err
+	}
+	after, err = s.store.Update(c)
+	return *old, after, err
+}
+
+func (s *Service) Delete(id string) error {
+	return s.store.Delete(id)
+}
+
+func (s *Service) Result(fuuid string) (*Result, error) {
+	return s.store.Result(fuuid)
+}
+
+// Decide classifies text against the enabled categories without storing
+// anything. The LLM is asked first; its answer stands when it names a
+// category with at least MinLLMConfidence. Otherwise, or when the LLM fails,
+// keyword scoring decides and Note says why.
+func (s *Service) Decide(ctx context.Context, text string) (Result, error) {
+	all, err := s.store.Categories()
+	if err != nil {
+		return Result{}, err
+	}
+	var cats []Category
+	for _, c := range all {
+		if c.Enabled {
+			cats = append(cats, c)
+		}
+	}
+	fallback := KeywordClassify(text, cats)
+	if len(cats) == 0 {
+		fallback.Note = "no categories are enabled"
+		return fallback, nil
+	}
+	if strings.TrimSpace(text) == "" {
+		fallback.Note = "document has no text"
+		return fallback, nil
+	}
+	if s.Model == nil {
+		return fallback, nil
+	}
+
+	ans, err := s.Model.Classify(ctx, text, cats)
+	switch {
+	case err != nil:
+		fallback.Note = fmt.Sprintf("LLM unavailable: %v", err)
+	case ans.Category == "":
+		fallback.Note = "LLM found no fitting category"
+	case ans.Confidence < MinLLMConfidence:
+		fallback.Note = fmt.Sprintf("LLM answer %s had low confidence %.2f", ans.Category, ans.Confidence)
+	default:
+		res := Result{Method: MethodLLM, Category: ans.Category, Confidence: math.Round(ans.Confidence*100) / 100, Scores: fallback.Scores}
+		for _, c := range cats {
+			if c.Name == ans.Category {
+				res.CategoryID = c.ID
+			}
+		}
+		return res, nil
+	}
+	return fallback, nil
+}
+
+// Classify decides the category of file fuuid from its text and stores it.
+func (s *Service) Classify(ctx context.Context, fuuid, text string) (Result, error) {
+	res, err := s.Decide(ctx, text)
+	if err != nil {
+		return Result{}, err
+	}
+	res.FUUID, res.ClassifiedAt = fuuid, time.Now()
+	if err := s.store.Save(res); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
@@ -0,0 +1,219 @@
+package classification
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (document_categories, document_classifications)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(c models.DocumentCategory) Category {
+	keywords := c.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	return Category{
+		ID: c.CategoryID, Name: c.Name, Description: c.Description, Keywords: keywords, Enabled: c.Enabled,
+		FileCount: c.FileCount, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func toRow(c Category) models.DocumentCategory {
+	return models.DocumentCategory{
+		CategoryID: c.ID, Name: c.Name, Description: c.Description, Keywords: c.Keywords, Enabled: c.Enabled,
+	}
+}
+
+func (s pgStore) Categories() ([]Category, error) {
+	rows, err := models.ListDocumentCategories(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Category, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Category(id string) (*Category, error) {
+	r, err := models.GetDocumentCategory(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	c := fromRow(*r)
+	return &c, nil
+}
+
+func (s pgStore) CategoryByName(name string) (*Category, error) {
+	r, err := models.GetDocumentCategoryByName(s.db, name)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	c := fromRow(*r)
+	return &c, nil
+}
+
+func (s pgStore) Create(c Category) (Category, error) {
+	row, err := models.InsertDocumentCategory(s.db, toRow(c))
+	if err != nil {
+		return Category{}, err
+	}
+	return fromRow(row), nil
+}
+
+func (s pgStore) Update(c Category) (Category, error) {
+	_, err := models.UpdateDocumentCategory(s.db, toRow(c))
+	if err == sql.ErrNoRows {
+		return Category{}, ErrNotFound
+	}
+	if err != nil {
+		return Category{}, err
+	}
+	// Reload so the file count is filled in.
+	updated, err := s.Category(c.ID)
+	if err != nil || updated == nil {
+		return Category{}, fmt.Errorf("reload category %s: %v", c.ID, err)
+	}
+	return *updated, nil
+}
+
+func (s pgStore) Delete(id string) error {
+	err := models.DeleteDocumentCategory(s.db, id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s pgStore) Save(r Result) error {
+	return models.SaveDocumentClassification(s.db, models.DocumentClassification{
+		FUUID: r.FUUID, CategoryID: r.CategoryID, Confidence: r.Confidence, Method: r.Method,
+		Scores: r.Scores, Note: r.Note,
+	})
+}
+
+func (s pgStore) Result(fuuid string) (*Result, error) {
+	c, err := models.GetDocumentClassification(s.db, fuuid)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return &Result{
+		FUUID: c.FUUID, CategoryID: c.CategoryID, Category: c.Category, Confidence: c.Confidence,
+		Method: c.Method, Scores: c.Scores, Note: c.Note, ClassifiedAt: c.ClassifiedAt,
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu         sync.Mutex
+	seq        int
+	categories map[string]Category
+	results    map[string]Result
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{categories: map[string]Category{}, results: map[string]Result{}}
+}
+
+func (m *MemoryStore) Categories() ([]Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Category, 0, len(m.categories))
+	for _, c := range m.categories {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Category(id string) (*Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.categories[id]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (m *MemoryStore) CategoryByName(name string) (*Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.categories {
+		if strings.EqualFold(c.Name, name) {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) Create(c Category) (Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	c.ID = fmt.Sprintf("00000000-0000-4000-8000-%012d", m.seq)
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+	m.categories[c.ID] = c
+	return c, nil
+}
+
+func (m *MemoryStore) Update(c Category) (Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.categories[c.ID]
+	if !ok {
+		return Category{}, ErrNotFound
+	}
+	c.CreatedAt, c.UpdatedAt = old.CreatedAt, time.Now()
+	m.categories[c.ID] = c
+	return c, nil
+}
+
+// Delete leaves files in the category uncategorized, like ON DELETE SET NULL.
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.categories[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.categories, id)
+	for f, r := range m.results {
+		if r.CategoryID == id {
+			r.CategoryID, r.Category = "", ""
+			m.results[f] = r
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Save(r Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[r.FUUID] = r
+	return nil
+}
+
+func (m *MemoryStore) Result(fuuid string) (*Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.results[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
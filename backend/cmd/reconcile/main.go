@@ -0,0 +1,49 @@
+// Command reconcile compares the storage buckets with the file table once
+// and prints the report as JSON. It only reports unless -clean or -requeue
+// is given; the report is stored like those of the scheduled job.
+//
+//	go run ./cmd/reconcile [-clean] [-requeue] [-findings]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"backend/config"
+	"backend/handlers"
+	"backend/reconcile"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	clean := flag.Bool("clean", false, "delete orphan objects")
+	requeue := flag.Bool("requeue", false, "re-run missing OCR and queue missing summaries")
+	findings := flag.Bool("findings", false, "print every finding, not just the counts")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, falling back to system environment")
+	}
+	config.InitConfig()
+	if err := config.InitDB(os.Getenv("DATABASE_URL")); err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+	handlers.InitClassificationService()
+	handlers.InitInlineReconcileService()
+
+	rep, runErr := handlers.RunReconcile(reconcile.TriggerCommand, reconcile.Options{Clean: *clean, Requeue: *requeue})
+	if !*findings {
+		rep.Findings = nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		log.Fatal(err)
+	}
+	if runErr != nil {
+		log.Fatalf("Reconcile failed: %v", runErr)
+	}
+}
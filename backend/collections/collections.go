@@ -0,0 +1,113 @@
+// Package collections implements a user's favorites ("Important" files)
+// and named collections of files. A collection belongs to one user, keeps
+// its files in a manual order, and can be shared read-only with the other
+// members of the owner's department.
+package collections
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxNameLen        = 80
+	MaxDescriptionLen = 500
+	MaxCollections    = 100
+	MaxItems          = 500
+)
+
+var (
+	ErrNotFound     = errors.New("collection not found")
+	ErrForbidden    = errors.New("only the owner can change this collection")
+	ErrFileNotFound = errors.New("file not found")
+	ErrNoAccess     = errors.New("you do not have access to this file")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Member is an active user as seen by favorites and collections.
+type Member struct {
+	UUID     string
+	Name     string
+	DUUID    string
+	Position string
+}
+
+// Favorite is a file the user starred.
+type Favorite struct {
+	FUUID     string    `json:"f_uuid"`
+	FileName  string    `json:"f_name"`
+	Language  string    `json:"language"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Item is a file in a collection. Position is 0-based.
+type Item struct {
+	FUUID    string    `json:"f_uuid"`
+	FileName string    `json:"f_name"`
+	Language string    `json:"language"`
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Collection is a named, ordered group of files. DUUID is the owner's
+// department when it was last shared; only its members see a shared
+// collection.
+type Collection struct {
+	ID          string    `json:"collection_id"`
+	OwnerUUID   string    `json:"owner_uuid"`
+	OwnerName   string    `json:"owner_name,omitempty"`
+	DUUID       string    `json:"d_uuid,omitempty"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Shared      bool      `json:"shared"`
+	ItemCount   int       `json:"item_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// ReadOnly is set for viewers other than the owner.
+	ReadOnly bool   `json:"read_only"`
+	Items    []Item `json:"items,omitempty"`
+}
+
+// Draft creates a collection.
+type Draft struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Shared      bool   `json:"shared"`
+}
+
+// Patch updates a collection; nil fields are left alone.
+type Patch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Shared      *bool   `json:"shared"`
+}
+
+func checkName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", invalid("name is required")
+	}
+	if utf8.RuneCountInString(name) > MaxNameLen {
+		return "", invalid("name must be at most %d characters", MaxNameLen)
+	}
+	return name, nil
+}
+
+func checkDescription(d string) (string, error) {
+	d = strings.TrimSpace(d)
+	if utf8.RuneCountInString(d) > MaxDescriptionLen {
+		return "", invalid("description must be at most %d characters", MaxDescriptionLen)
+	}
+	return d, nil
+}
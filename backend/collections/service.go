@@ -0,0 +1,366 @@
+package collections
+
+import (
+	"sort"
+	"strings"
+
+	"backend/access"
+)
+
+// Store persists favorites and collections. Implementations:
+// NewPostgresStore and NewMemoryStore.
+type Store interface {
+	// User returns an active user, or nil when missing or deactivated.
+	User(uuid string) (*Member, error)
+	// File returns what access checks need to know about a file, or nil.
+	File(fuuid string) (*access.File, error)
+
+	// Favorites lists the user's favorites that they may still see,
+	// newest first.
+	Favorites(user Member) ([]Favorite, error)
+	// AddFavorite is a no-op when the file is already a favorite.
+	AddFavorite(userUUID, fuuid string) error
+	RemoveFavorite(userUUID, fuuid string) (bool, error)
+
+	// Collections returns the user's own collections and the shared
+	// collections of their department.
+	Collections(user Member) ([]Collection, error)
+	// Collection returns nil when there is no such collection.
+	Collection(id string) (*Collection, error)
+	// CollectionNames returns the names of the owner's collections by ID.
+	CollectionNames(ownerUUID string) (map[string]string, error)
+	CreateCollection(c Collection) (Collection, error)
+	UpdateCollection(c Collection) (Collection, error)
+	DeleteCollection(id string) error
+
+	// Items lists a collection's files that viewer may see, in order.
+	Items(collectionID string, viewer Member) ([]Item, error)
+	// ItemIDs lists every file in a collection, in order.
+	ItemIDs(collectionID string) ([]string, error)
+	// AddItem appends a file at the end of the collection.
+	AddItem(collectionID, fuuid string) error
+	RemoveItem(collectionID, fuuid string) (bool, error)
+	// Reorder sets the order of a collection to fuuids, which must be a
+	// permutation of its ItemIDs.
+	Reorder(collectionID string, fuuids []string) error
+}
+
+type Service struct {
+	store Store
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+func (s *Service) user(userID string) (Member, error) {
+	u, err := s.store.User(userID)
+	if err != nil {
+		return Member{}, err
+	}
+	if u == nil {
+		return Member{}, ErrNotFound
+	}
+	return *u, nil
+}
+
+func viewer(u Member) access.Viewer {
+	return access.Viewer{UUID: u.UUID, DUUID: u.DUUID, Position: u.Position, Active: true}
+}
+
+// checkFile returns ErrFileNotFound or ErrNoAccess unless u may see fuuid.
+func (s *Service) checkFile(u Member, fuuid string) (string, error) {
+	fuuid = strings.ToLower(strings.TrimSpace(fuuid))
+	if fuuid == "" {
+		return "", invalid("f_uuid is required")
+	}
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return "", err
+	}
+	if f == nil {
+		return "", ErrFileNotFound
+	}
+	if !access.Check(viewer(u), *f).Allowed {
+		return "", ErrNoAccess
+	}
+	return f.FUUID, nil
+}
+
+// ---------------------------------------------------------------------------
+// Favorites
+// ---------------------------------------------------------------------------
+
+func (s *Service) Favorites(userID string) ([]Favorite, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Favorites(u)
+}
+
+// AddFavorite stars a file the user may see. Starring twice is fine.
+func (s *Service) AddFavorite(userID, fuuid string) error {
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	fuuid, err = s.checkFile(u, fuuid)
+	if err != nil {
+		return err
+	}
+	return s.store.AddFavorite(u.UUID, fuuid)
+}
+
+// RemoveFavorite unstars a file; removing one that is not starred is fine.
+func (s *Service) RemoveFavorite(userID, fuuid string) error {
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.RemoveFavorite(u.UUID, strings.ToLower(strings.TrimSpace(fuuid)))
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Collections
+// ---------------------------------------------------------------------------
+
+// Collections returns the user's own collections and, separately, the
+// collections colleagues in their department share, each by name.
+func (s *Service) Collections(userID string) (owned, shared []Collection, err error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := s.store.Collections(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	owned, shared = []Collection{}, []Collection{}
+	for _, c := range all {
+		switch {
+		case c.OwnerUUID == u.UUID:
+			owned = append(owned, c)
+		case canRead(u, c):
+			c.ReadOnly = true
+			shared = append(shared, c)
+		}
+	}
+	byName := func(list []Collection) {
+		sort.SliceStable(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+	}
+	byName(owned)
+	byName(shared)
+	return owned, shared, nil
+}
+
+func canRead(u Member, c Collection) bool {
+	if c.OwnerUUID == u.UUID {
+		return true
+	}
+	return c.Shared && c.DUUID != "" && c.DUUID == u.DUUID
+}
+
+// load returns the collection if u may read it; others get ErrNotFound so
+// private collections stay invisible.
+func (s *Service) load(u Member, id string) (Collection, error) {
+	c, err := s.store.Collection(id)
+	if err != nil {
+		return Collection{}, err
+	}
+	if c == nil || !canRead(u, *c) {
+		return Collection{}, ErrNotFound
+	}
+	c.ReadOnly = c.OwnerUUID != u.UUID
+	return *c, nil
+}
+
+// owned is load for changes: readers who are not the owner get
+// ErrForbidden.
+func (s *Service) owned(userID, id string) (Member, Collection, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Member{}, Collection{}, err
+	}
+	c, err := s.load(u, id)
+	if err != nil {
+		return Member{}, Collection{}, err
+	}
+	if c.ReadOnly {
+		return Member{}, Collection{}, ErrForbidden
+	}
+	return u, c, nil
+}
+
+// checkUniqueName rejects a name the owner already uses for another
+// collection, ignoring case.
+func (s *Service) checkUniqueName(ownerUUID, id, name string) error {
+	names, err := s.store.CollectionNames(ownerUUID)
+	if err != nil {
+		return err
+	}
+	for otherID, other := range names {
+		if otherID != id && strings.EqualFold(other, name) {
+			return invalid("you already have a collection named %q", other)
+		}
+	}
+	return nil
+}
+
+func (s *Service) Create(userID string, d Draft) (Collection, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Collection{}, err
+	}
+	name, err := checkName(d.Name)
+	if err != nil {
+		return Collection{}, err
+	}
+	desc, err := checkDescription(d.Description)
+	if err != nil {
+		return Collection{}, err
+	}
+	names, err := s.store.CollectionNames(u.UUID)
+	if err != nil {
+		return Collection{}, err
+	}
+	if len(names) >= MaxCollections {
+		return Collection{}, invalid("at most %d collections allowed", MaxCollections)
+	}
+	if err := s.checkUniqueName(u.UUID, "", name); err != nil {
+		return Collection{}, err
+	}
+	return s.store.CreateCollection(Collection{
+		OwnerUUID: u.UUID, OwnerName: u.Name, DUUID: u.DUUID,
+		Name: name, Description: desc, Shared: d.Shared,
+	})
+}
+
+// Get returns a collection with the items the caller may see.
+func (s *Service) Get(userID, id string) (Collection, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Collection{}, err
+	}
+	c, err := s.load(u, id)
+	if err != nil {
+		return Collection{}, err
+	}
+	if c.Items, err = s.store.Items(c.ID, u); err != nil {
+		return Collection{}, err
+	}
+	return c, nil
+}
+
+// Update renames, describes or (un)shares a collection. Sharing uses the
+// owner's current department.
+func (s *Service) Update(userID, id string, p Patch) (Collection, error) {
+	u, c, err := s.owned(userID, id)
+	if err != nil {
+		return Collection{}, err
+	}
+	if p.Name != nil {
+		if c.Name, err = checkName(*p.Name); err != nil {
+			return Collection{}, err
+		}
+		if err := s.checkUniqueName(u.UUID, c.ID, c.Name); err != nil {
+			return Collection{}, err
+		}
+	}
+	if p.Description != nil {
+		if c.Description, err = checkDescription(*p.Description); err != nil {
+			return Collection{}, err
+		}
+	}
+	if p.Shared != nil {
+		c.Shared = *p.Shared
+	}
+	c.DUUID = u.DUUID
+	c, err = s.store.UpdateCollection(c)
+	if err != nil {
+		return Collection{}, err
+	}
+	return c, nil
+}
+
+func (s *Service) Delete(userID, id string) error {
+	_, c, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	return s.store.DeleteCollection(c.ID)
+}
+
+// AddItem appends a file the owner may see. Adding a file that is already
+// in the collection is a no-op.
+func (s *Service) AddItem(userID, id, fuuid string) error {
+	u, c, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	fuuid, err = s.checkFile(u, fuuid)
+	if err != nil {
+		return err
+	}
+	ids, err := s.store.ItemIDs(c.ID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range ids {
+		if existing == fuuid {
+			return nil
+		}
+	}
+	if len(ids) >= MaxItems {
+		return invalid("a collection holds at most %d files", MaxItems)
+	}
+	return s.store.AddItem(c.ID, fuuid)
+}
+
+func (s *Service) RemoveItem(userID, id, fuuid string) error {
+	_, c, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	removed, err := s.store.RemoveItem(c.ID, strings.ToLower(strings.TrimSpace(fuuid)))
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+// Reorder puts the collection's files in the given order, which must list
+// each of them exactly once.
+func (s *Service) Reorder(userID, id string, fuuids []string) error {
+	_, c, err := s.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	current, err := s.store.ItemIDs(c.ID)
+	if err != nil {
+		return err
+	}
+	order := make([]string, len(fuuids))
+	seen := map[string]bool{}
+	for i, f := range fuuids {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if seen[f] {
+			return invalid("f_uuids lists %s twice", f)
+		}
+		seen[f] = true
+		order[i] = f
+	}
+	if len(order) != len(current) {
+		return invalid("f_uuids must list all %d files in the collection", len(current))
+	}
+	for _, f := range current {
+		if !seen[f] {
+			return invalid("f_uuids must list all %d files in the collection", len(current))
+		}
+	}
+	return s.store.Reorder(c.ID, order)
+}
@@ -0,0 +1,215 @@
+package collections
+
+import (
+	"errors"
+	"testing"
+
+	"backend/access"
+)
+
+const (
+	deptOps     = "11111111-1111-1111-1111-111111111111"
+	deptFinance = "22222222-2222-2222-2222-222222222222"
+	fileA       = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	fileB       = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+	fileC       = "cccccccc-cccc-cccc-cccc-cccccccccccc"
+	fileSecret  = "dddddddd-dddd-dddd-dddd-dddddddddddd"
+)
+
+func newTestService() (*Service, *MemoryStore) {
+	store := NewMemoryStore()
+	store.AddUser(Member{UUID: "asha", Name: "Asha", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "ravi", Name: "Ravi", DUUID: deptOps, Position: "head"})
+	store.AddUser(Member{UUID: "meera", Name: "Meera", DUUID: deptFinance, Position: "regular"})
+	store.AddFile(access.File{FUUID: fileA, OwnerUUID: "asha"}, "a.pdf")
+	store.AddFile(access.File{FUUID: fileB, OwnerUUID: "meera"}, "b.pdf")
+	store.AddFile(access.File{FUUID: fileC, OwnerUUID: "ravi"}, "c.pdf")
+	store.AddFile(access.File{FUUID: fileSecret, OwnerUUID: "ravi", Sensitivity: access.Restricted, Departments: []string{deptOps}}, "secret.pdf")
+	return NewService(store), store
+}
+
+func itemIDs(items []Item) []string {
+	out := make([]string, len(items))
+	for i, it := range items {
+		out[i] = it.FUUID
+	}
+	return out
+}
+
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFavoritesRespectAccess(t *testing.T) {
+	svc, _ := newTestService()
+	if err := svc.AddFavorite("asha", fileB); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddFavorite("asha", fileB); err != nil {
+		t.Fatalf("starring twice should be a no-op, got %v", err)
+	}
+	if err := svc.AddFavorite("asha", fileSecret); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("expected ErrNoAccess for a restricted file, got %v", err)
+	}
+	if err := svc.AddFavorite("asha", "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+	favs, err := svc.Favorites("asha")
+	if err != nil || len(favs) != 1 || favs[0].FUUID != fileB || favs[0].FileName != "b.pdf" {
+		t.Fatalf("unexpected favorites %+v, %v", favs, err)
+	}
+	if err := svc.RemoveFavorite("asha", fileB); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveFavorite("asha", fileB); err != nil {
+		t.Fatalf("unstarring twice should be fine, got %v", err)
+	}
+	if _, err := svc.Favorites("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func TestCreateValidatesAndKeepsNamesUnique(t *testing.T) {
+	svc, _ := newTestService()
+	if _, err := svc.Create("asha", Draft{Name: "  "}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a validation error for an empty name, got %v", err)
+	}
+	c, err := svc.Create("asha", Draft{Name: " Audit 2025 ", Description: "for the auditors"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Audit 2025" || c.DUUID != deptOps || c.Shared {
+		t.Fatalf("unexpected collection %+v", c)
+	}
+	if _, err := svc.Create("asha", Draft{Name: "audit 2025"}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a duplicate-name error, got %v", err)
+	}
+	if _, err := svc.Create("ravi", Draft{Name: "Audit 2025"}); err != nil {
+		t.Fatalf("another user may reuse the name: %v", err)
+	}
+	other, err := svc.Create("asha", Draft{Name: "Misc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "AUDIT 2025"
+	if _, err := svc.Update("asha", other.ID, Patch{Name: &name}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected rename onto an existing name to fail, got %v", err)
+	}
+	if _, err := svc.Update("asha", c.ID, Patch{Name: &name}); err != nil {
+		t.Fatalf("changing the case of its own name should be allowed: %v", err)
+	}
+}
+
+func TestSharedCollectionsAreReadOnlyForTheDepartment(t *testing.T) {
+	svc, _ := newTestService()
+	c, err := svc.Create("asha", Draft{Name: "Onboarding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get("ravi", c.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("private collection should be invisible, got %v", err)
+	}
+
+	shared := true
+	if _, err := svc.Update("asha", c.ID, Patch{Shared: &shared}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Get("ravi", c.ID)
+	if err != nil || !got.ReadOnly {
+		t.Fatalf("expected a read-only view for a colleague, got %+v, %v", got, err)
+	}
+	owned, sharedList, err := svc.Collections("ravi")
+	if err != nil || len(owned) != 0 || len(sharedList) != 1 || sharedList[0].ID != c.ID {
+		t.Fatalf("unexpected lists owned=%+v shared=%+v err=%v", owned, sharedList, err)
+	}
+	if _, err := svc.Get("meera", c.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("other departments must not see it, got %v", err)
+	}
+
+	name := "Hijacked"
+	if _, err := svc.Update("ravi", c.ID, Patch{Name: &name}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on update, got %v", err)
+	}
+	if err := svc.AddItem("ravi", c.ID, fileC); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on add, got %v", err)
+	}
+	if err := svc.Delete("ravi", c.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on delete, got %v", err)
+	}
+	if err := svc.Delete("asha", c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get("asha", c.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the collection to be gone, got %v", err)
+	}
+}
+
+func TestItemsKeepManualOrder(t *testing.T) {
+	svc, _ := newTestService()
+	c, err := svc.Create("ravi", Draft{Name: "Board pack", Shared: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{fileA, fileB, fileC, fileSecret, fileA} {
+		if err := svc.AddItem("ravi", c.ID, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := svc.Get("ravi", c.ID)
+	if !sameIDs(itemIDs(got.Items), []string{fileA, fileB, fileC, fileSecret}) || got.ItemCount != 4 {
+		t.Fatalf("unexpected items %+v", got.Items)
+	}
+
+	// Asha shares the department but may not see the restricted file.
+	got, _ = svc.Get("asha", c.ID)
+	if !sameIDs(itemIDs(got.Items), []string{fileA, fileB, fileC}) {
+		t.Fatalf("restricted file should be hidden, got %v", itemIDs(got.Items))
+	}
+
+	if err := svc.Reorder("ravi", c.ID, []string{fileC, fileA}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a partial order to be rejected, got %v", err)
+	}
+	if err := svc.Reorder("ravi", c.ID, []string{fileC, fileA, fileA, fileB}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected duplicates to be rejected, got %v", err)
+	}
+	if err := svc.Reorder("ravi", c.ID, []string{fileSecret, fileC, fileA, fileB}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveItem("ravi", c.ID, fileC); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveItem("ravi", c.ID, fileC); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+	got, _ = svc.Get("ravi", c.ID)
+	if !sameIDs(itemIDs(got.Items), []string{fileSecret, fileA, fileB}) {
+		t.Fatalf("unexpected order %v", itemIDs(got.Items))
+	}
+	for i, it := range got.Items {
+		if it.Position != i {
+			t.Fatalf("positions should be contiguous, got %+v", got.Items)
+		}
+	}
+}
+
+func TestAddItemChecksAccess(t *testing.T) {
+	svc, _ := newTestService()
+	c, err := svc.Create("asha", Draft{Name: "Mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddItem("asha", c.ID, fileSecret); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("expected ErrNoAccess, got %v", err)
+	}
+	if err := svc.AddItem("asha", "col-missing", fileA); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,425 @@
+package collections
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/access"
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (favorites, collections, collection_items)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(r models.CollectionRow) Collection {
+	return Collection{
+		ID: r.CollectionID, OwnerUUID: r.OwnerUUID, OwnerName: r.OwnerName, DUUID: r.DUUID,
+		Name: r.Name, Description: r.Description, Shared: r.Shared, ItemCount: r.ItemCount,
+		CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func toRow(c Collection) models.CollectionRow {
+	return models.CollectionRow{
+		CollectionID: c.ID, OwnerUUID: c.OwnerUUID, DUUID: c.DUUID,
+		Name: c.Name, Description: c.Description, Shared: c.Shared,
+	}
+}
+
+func (s pgStore) User(uuid string) (*Member, error) {
+	p, err := models.GetUserProfile(s.db, uuid)
+	if err != nil || p == nil || !p.IsActive {
+		return nil, err
+	}
+	return &Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position}, nil
+}
+
+func (s pgStore) File(fuuid string) (*access.File, error) {
+	f, err := models.GetFileAccess(s.db, fuuid)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return &access.File{FUUID: f.FUUID, OwnerUUID: f.OwnerUUID, Sensitivity: f.Sensitivity, Departments: f.Departments}, nil
+}
+
+func (s pgStore) Favorites(user Member) ([]Favorite, error) {
+	rows, err := models.ListFavorites(s.db, user.UUID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Favorite, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Favorite{FUUID: r.FUUID, FileName: r.FileName, Language: r.Language, CreatedAt: r.CreatedAt})
+	}
+	return out, nil
+}
+
+func (s pgStore) AddFavorite(userUUID, fuuid string) error {
+	return models.AddFavorite(s.db, userUUID, fuuid)
+}
+
+func (s pgStore) RemoveFavorite(userUUID, fuuid string) (bool, error) {
+	return models.RemoveFavorite(s.db, userUUID, fuuid)
+}
+
+func (s pgStore) Collections(user Member) ([]Collection, error) {
+	rows, err := models.ListCollections(s.db, user.UUID, user.DUUID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Collection, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Collection(id string) (*Collection, error) {
+	r, err := models.GetCollection(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	c := fromRow(*r)
+	return &c, nil
+}
+
+func (s pgStore) CollectionNames(ownerUUID string) (map[string]string, error) {
+	return models.CollectionNames(s.db, ownerUUID)
+}
+
+func (s pgStore) CreateCollection(c Collection) (Collection, error) {
+	row, err := models.InsertCollection(s.db, toRow(c))
+	if err != nil {
+		return Collection{}, err
+	}
+	c.ID, c.CreatedAt, c.UpdatedAt = row.CollectionID, row.CreatedAt, row.UpdatedAt
+	return c, nil
+}
+
+func (s pgStore) UpdateCollection(c Collection) (Collection, error) {
+	row, err := models.UpdateCollection(s.db, toRow(c))
+	if err == sql.ErrNoRows {
+		return Collection{}, ErrNotFound
+	}
+	if err != nil {
+		return Collection{}, err
+	}
+	c.UpdatedAt = row.UpdatedAt
+	return c, nil
+}
+
+func (s pgStore) DeleteCollection(id string) error {
+	if err := models.DeleteCollection(s.db, id); err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) Items(collectionID string, viewer Member) ([]Item, error) {
+	rows, err := models.ListCollectionItems(s.db, collectionID, viewer.UUID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Item, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Item{FUUID: r.FUUID, FileName: r.FileName, Language: r.Language, Position: r.Position, AddedAt: r.AddedAt})
+	}
+	return out, nil
+}
+
+func (s pgStore) ItemIDs(collectionID string) ([]string, error) {
+	return models.CollectionItemIDs(s.db, collectionID)
+}
+
+func (s pgStore) AddItem(collectionID, fuuid string) error {
+	return models.AddCollectionItem(s.db, collectionID, fuuid)
+}
+
+func (s pgStore) RemoveItem(collectionID, fuuid string) (bool, error) {
+	return models.RemoveCollectionItem(s.db, collectionID, fuuid)
+}
+
+func (s pgStore) Reorder(collectionID string, fuuids []string) error {
+	return models.ReorderCollectionItems(s.db, collectionID, fuuids)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store, for tests
+// ---------------------------------------------------------------------------
+
+type memFile struct {
+	access.File
+	Name string
+}
+
+type memFavorite struct {
+	userUUID, fuuid string
+	at              time.Time
+}
+
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       map[string]Member
+	files       map[string]memFile
+	favorites   []memFavorite
+	collections map[string]Collection
+	items       map[string][]Item
+	seq         int
+	now         time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[string]Member{},
+		files:       map[string]memFile{},
+		collections: map[string]Collection{},
+		items:       map[string][]Item{},
+		now:         time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func (m *MemoryStore) AddUser(u Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.UUID] = u
+}
+
+// AddFile registers a file with its access facts and name.
+func (m *MemoryStore) AddFile(f access.File, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[f.FUUID] = memFile{File: f, Name: name}
+}
+
+func (m *MemoryStore) tick() time.Time {
+	m.now = m.now.Add(time.Minute)
+	return m.now
+}
+
+func (m *MemoryStore) visible(u Member, fuuid string) (memFile, bool) {
+	f, ok := m.files[fuuid]
+	if !ok {
+		return memFile{}, false
+	}
+	return f, access.Check(viewer(u), f.File).Allowed
+}
+
+func (m *MemoryStore) User(uuid string) (*Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[uuid]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) File(fuuid string) (*access.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	out := f.File
+	return &out, nil
+}
+
+func (m *MemoryStore) Favorites(user Member) ([]Favorite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Favorite{}
+	for i := len(m.favorites) - 1; i >= 0; i-- {
+		fav := m.favorites[i]
+		if fav.userUUID != user.UUID {
+			continue
+		}
+		if f, ok := m.visible(user, fav.fuuid); ok {
+			out = append(out, Favorite{FUUID: fav.fuuid, FileName: f.Name, CreatedAt: fav.at})
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) AddFavorite(userUUID, fuuid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fav := range m.favorites {
+		if fav.userUUID == userUUID && fav.fuuid == fuuid {
+			return nil
+		}
+	}
+	m.favorites = append(m.favorites, memFavorite{userUUID: userUUID, fuuid: fuuid, at: m.tick()})
+	return nil
+}
+
+func (m *MemoryStore) RemoveFavorite(userUUID, fuuid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, fav := range m.favorites {
+		if fav.userUUID == userUUID && fav.fuuid == fuuid {
+			m.favorites = append(m.favorites[:i], m.favorites[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) withCount(c Collection) Collection {
+	c.ItemCount = len(m.items[c.ID])
+	if u, ok := m.users[c.OwnerUUID]; ok {
+		c.OwnerName = u.Name
+	}
+	return c
+}
+
+func (m *MemoryStore) Collections(user Member) ([]Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Collection{}
+	for _, c := range m.collections {
+		if c.OwnerUUID == user.UUID || (c.Shared && user.DUUID != "" && c.DUUID == user.DUUID) {
+			out = append(out, m.withCount(c))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemoryStore) Collection(id string) (*Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.collections[id]
+	if !ok {
+		return nil, nil
+	}
+	c = m.withCount(c)
+	return &c, nil
+}
+
+func (m *MemoryStore) CollectionNames(ownerUUID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]string{}
+	for _, c := range m.collections {
+		if c.OwnerUUID == ownerUUID {
+			out[c.ID] = c.Name
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) CreateCollection(c Collection) (Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	c.ID = fmt.Sprintf("col-%d", m.seq)
+	c.CreatedAt = m.tick()
+	c.UpdatedAt = c.CreatedAt
+	m.collections[c.ID] = c
+	return c, nil
+}
+
+func (m *MemoryStore) UpdateCollection(c Collection) (Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.collections[c.ID]
+	if !ok {
+		return Collection{}, ErrNotFound
+	}
+	old.Name, old.Description, old.Shared, old.DUUID = c.Name, c.Description, c.Shared, c.DUUID
+	old.UpdatedAt = m.tick()
+	m.collections[c.ID] = old
+	return m.withCount(old), nil
+}
+
+func (m *MemoryStore) DeleteCollection(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.collections[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.collections, id)
+	delete(m.items, id)
+	return nil
+}
+
+func (m *MemoryStore) Items(collectionID string, viewerMember Member) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Item{}
+	for _, it := range m.items[collectionID] {
+		if f, ok := m.visible(viewerMember, it.FUUID); ok {
+			it.FileName = f.Name
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) ItemIDs(collectionID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for _, it := range m.items[collectionID] {
+		ids = append(ids, it.FUUID)
+	}
+	return ids, nil
+}
+
+func (m *MemoryStore) AddItem(collectionID, fuuid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.items[collectionID]
+	for _, it := range items {
+		if it.FUUID == fuuid {
+			return nil
+		}
+	}
+	m.items[collectionID] = append(items, Item{FUUID: fuuid, Position: len(items), AddedAt: m.tick()})
+	return nil
+}
+
+func (m *MemoryStore) RemoveItem(collectionID, fuuid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.items[collectionID]
+	for i, it := range items {
+		if it.FUUID == fuuid {
+			items = append(items[:i], items[i+1:]...)
+			for j := range items {
+				items[j].Position = j
+			}
+			m.items[collectionID] = items
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) Reorder(collectionID string, fuuids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byID := map[string]Item{}
+	for _, it := range m.items[collectionID] {
+		byID[it.FUUID] = it
+	}
+	items := make([]Item, 0, len(fuuids))
+	for i, id := range fuuids {
+		it := byID[id]
+		it.Position = i
+		items = append(items, it)
+	}
+	m.items[collectionID] = items
+	return nil
+}
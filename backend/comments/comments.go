@@ -0,0 +1,164 @@
+// Package comments implements threaded discussions on documents. A comment
+// may be anchored to a page and to a span of the file's OCR text, and may
+// @mention colleagues, who are notified when they can see the file. Edits
+// and deletions keep the earlier text as revisions.
+package comments
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/access"
+)
+
+const (
+	MaxBodyLen  = 4000
+	MaxMentions = 20
+	MaxQuoteLen = 500
+)
+
+// Revision actions.
+const (
+	ActionEdit   = "edit"
+	ActionDelete = "delete"
+)
+
+var (
+	ErrNotFound  = errors.New("comment not found")
+	ErrNoAccess  = errors.New("you do not have access to this file")
+	ErrForbidden = errors.New("forbidden")
+	ErrDeleted   = errors.New("comment was deleted")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Member is an active user who can be mentioned.
+type Member struct {
+	UUID     string
+	Name     string
+	Email    string
+	DUUID    string
+	Position string
+}
+
+// File is a file being discussed.
+type File struct {
+	access.File
+	Name string
+}
+
+// Anchor ties a comment to a page and, when End > Start, to the rune range
+// [Start, End) of the file's OCR text. Quote is filled in from the OCR text
+// so the anchor still reads after the text is re-extracted.
+type Anchor struct {
+	Page  int    `json:"page,omitempty"`
+	Start int    `json:"start,omitempty"`
+	End   int    `json:"end,omitempty"`
+	Quote string `json:"quote,omitempty"`
+}
+
+// Mention is a user named in a comment.
+type Mention struct {
+	UUID   string `json:"uuid"`
+	Name   string `json:"name"`
+	Handle string `json:"handle"`
+}
+
+// Comment is one message. ThreadID is the root comment's ID (its own for
+// a root). Deleted comments keep their place in the thread with an empty
+// body.
+type Comment struct {
+	ID         string     `json:"comment_id"`
+	FUUID      string     `json:"f_uuid"`
+	ParentID   string     `json:"parent_id,omitempty"`
+	ThreadID   string     `json:"thread_id"`
+	AuthorUUID string     `json:"author_uuid"`
+	AuthorName string     `json:"author_name,omitempty"`
+	Body       string     `json:"body"`
+	Anchor     *Anchor    `json:"anchor,omitempty"`
+	Mentions   []Mention  `json:"mentions"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	// Replies holds the rest of a thread, oldest first, on root comments.
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// Revision is an earlier state of a comment, recorded when it was edited or
+// deleted.
+type Revision struct {
+	CommentID string    `json:"comment_id"`
+	Action    string    `json:"action"`
+	Body      string    `json:"body"`
+	EditedBy  string    `json:"edited_by"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// Draft creates a comment; ParentID makes it a reply.
+type Draft struct {
+	Body     string  `json:"body"`
+	ParentID string  `json:"parent_id"`
+	Anchor   *Anchor `json:"anchor"`
+}
+
+// Event tells Recipients they were mentioned in Comment.
+type Event struct {
+	File       File
+	Comment    Comment
+	Author     string
+	Recipients []string
+}
+
+func checkBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", invalid("body is required")
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLen {
+		return "", invalid("body must be at most %d characters", MaxBodyLen)
+	}
+	return body, nil
+}
+
+// mentionPattern matches @handle where handle is an email address or its
+// local part. The @ must not follow a word character, so addresses written
+// out in full are not read as mentions of their domain.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z0-9._%+-]+(?:@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)?)`)
+
+// Handles returns the distinct lower-cased handles mentioned in body, in
+// order of appearance.
+func Handles(body string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		h := strings.ToLower(strings.TrimRight(m[1], "."))
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		out = append(out, h)
+	}
+	return out
+}
+
+// matchesHandle reports whether handle names m, by full email or by its
+// local part.
+func matchesHandle(m Member, handle string) bool {
+	email := strings.ToLower(m.Email)
+	if email == handle {
+		return true
+	}
+	local, _, ok := strings.Cut(email, "@")
+	return ok && local == handle
+}
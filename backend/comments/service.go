@@ -0,0 +1,328 @@
+package comments
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/access"
+)
+
+// Store persists comments. Implementations: NewPostgresStore and
+// NewMemoryStore.
+type Store interface {
+	// OCRText returns the file's latest OCR text, or "".
+	OCRText(fuuid string) (string, error)
+	// PageCount returns the number of pages when known, otherwise 0.
+	PageCount(fuuid string) (int, error)
+	// Members returns the active users matching any of the handles (full
+	// email or its local part).
+	Members(handles []string) ([]Member, error)
+
+	// Comments lists every comment on a file, deleted ones included, oldest
+	// first.
+	Comments(fuuid string) ([]Comment, error)
+	// Comment returns nil when there is no such comment on the file.
+	Comment(fuuid, id string) (*Comment, error)
+	// Insert assigns the ID (and ThreadID for roots) and creation time.
+	Insert(c Comment) (Comment, error)
+	// Update saves c's body and mentions and records rev, in one step.
+	Update(c Comment, rev Revision) (Comment, error)
+	// Delete marks c deleted, clears its body and mentions and records rev.
+	Delete(c Comment, rev Revision) error
+	// Revisions lists a comment's revisions, oldest first.
+	Revisions(id string) ([]Revision, error)
+}
+
+type Service struct {
+	store Store
+	// Notify receives mention events; nil drops them.
+	Notify func(Event)
+	Now    func() time.Time
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store, Now: time.Now}
+}
+
+func checkAccess(v access.Viewer, f File) error {
+	if !access.Check(v, f.File).Allowed {
+		return ErrNoAccess
+	}
+	return nil
+}
+
+// canModerate: the uploader and the heads of the file's departments may
+// remove anyone's comment and read its history.
+func canModerate(v access.Viewer, f File) bool {
+	if strings.EqualFold(v.UUID, f.OwnerUUID) {
+		return true
+	}
+	if !v.IsHead() {
+		return false
+	}
+	for _, d := range f.Departments {
+		if strings.EqualFold(d, v.DUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the file's threads, oldest first, each root carrying its
+// replies.
+func (s *Service) List(v access.Viewer, f File) ([]Comment, error) {
+	if err := checkAccess(v, f); err != nil {
+		return nil, err
+	}
+	all, err := s.store.Comments(f.FUUID)
+	if err != nil {
+		return nil, err
+	}
+	roots := []Comment{}
+	index := map[string]int{}
+	for _, c := range all {
+		if c.ThreadID == "" || c.ThreadID == c.ID {
+			index[c.ID] = len(roots)
+			roots = append(roots, c)
+		}
+	}
+	for _, c := range all {
+		if i, ok := index[c.ThreadID]; ok && c.ID != c.ThreadID {
+			roots[i].Replies = append(roots[i].Replies, c)
+		}
+	}
+	return roots, nil
+}
+
+// checkAnchor validates a against the file's pages and OCR text and fills
+// in the quote.
+func (s *Service) checkAnchor(fuuid string, a *Anchor) (*Anchor, error) {
+	if a == nil || (a.Page == 0 && a.Start == 0 && a.End == 0) {
+		return nil, nil
+	}
+	out := Anchor{Page: a.Page, Start: a.Start, End: a.End}
+	if out.Page < 0 {
+		return nil, invalid("anchor.page must be 1 or more")
+	}
+	if out.Page > 0 {
+		pages, err := s.store.PageCount(fuuid)
+		if err != nil {
+			return nil, err
+		}
+		if pages > 0 && out.Page > pages {
+			return nil, invalid("anchor.page must be at most %d", pages)
+		}
+	}
+	if out.Start == 0 && out.End == 0 {
+		return &out, nil
+	}
+	if out.Start < 0 || out.End <= out.Start {
+		return nil, invalid("anchor.start must be less than anchor.end")
+	}
+	text, err := s.store.OCRText(fuuid)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, invalid("this file has no OCR text to anchor to")
+	}
+	if n := utf8.RuneCountInString(text); out.End > n {
+		return nil, invalid("anchor.end must be at most %d", n)
+	}
+	quote := []rune(text)[out.Start:out.End]
+	if len(quote) > MaxQuoteLen {
+		quote = quote[:MaxQuoteLen]
+	}
+	out.Quote = strings.TrimSpace(string(quote))
+	return &out, nil
+}
+
+// mentions resolves the handles in body to users who may see the file.
+// Handles that match nobody, match several people or name someone without
+// access are left as plain text.
+func (s *Service) mentions(f File, body string) ([]Mention, error) {
+	handles := Handles(body)
+	if len(handles) == 0 {
+		return []Mention{}, nil
+	}
+	if len(handles) > MaxMentions {
+		return nil, invalid("a comment may mention at most %d people", MaxMentions)
+	}
+	members, err := s.store.Members(handles)
+	if err != nil {
+		return nil, err
+	}
+	out := []Mention{}
+	seen := map[string]bool{}
+	for _, h := range handles {
+		var match []Member
+		for _, m := range members {
+			if matchesHandle(m, h) {
+				match = append(match, m)
+			}
+		}
+		if len(match) != 1 || seen[match[0].UUID] {
+			continue
+		}
+		m := match[0]
+		if !access.Check(access.Viewer{UUID: m.UUID, DUUID: m.DUUID, Position: m.Position, Active: true}, f.File).Allowed {
+			continue
+		}
+		seen[m.UUID] = true
+		out = append(out, Mention{UUID: m.UUID, Name: m.Name, Handle: h})
+	}
+	return out, nil
+}
+
+// notify tells the users in mentions, except the author and those in
+// already, about c.
+func (s *Service) notify(f File, c Comment, already []Mention) {
+	if s.Notify == nil {
+		return
+	}
+	skip := map[string]bool{c.AuthorUUID: true}
+	for _, m := range already {
+		skip[m.UUID] = true
+	}
+	var to []string
+	for _, m := range c.Mentions {
+		if !skip[m.UUID] {
+			to = append(to, m.UUID)
+		}
+	}
+	if len(to) > 0 {
+		s.Notify(Event{File: f, Comment: c, Author: c.AuthorName, Recipients: to})
+	}
+}
+
+// Create posts a comment or, with ParentID, a reply in the parent's thread.
+func (s *Service) Create(v access.Viewer, f File, d Draft) (Comment, error) {
+	if err := checkAccess(v, f); err != nil {
+		return Comment{}, err
+	}
+	body, err := checkBody(d.Body)
+	if err != nil {
+		return Comment{}, err
+	}
+	c := Comment{FUUID: f.FUUID, AuthorUUID: v.UUID, Body: body}
+	if parentID := strings.TrimSpace(d.ParentID); parentID != "" {
+		parent, err := s.store.Comment(f.FUUID, parentID)
+		if err != nil {
+			return Comment{}, err
+		}
+		if parent == nil {
+			return Comment{}, ErrNotFound
+		}
+		if parent.DeletedAt != nil {
+			return Comment{}, ErrDeleted
+		}
+		c.ParentID, c.ThreadID = parent.ID, parent.ThreadID
+	}
+	if c.Anchor, err = s.checkAnchor(f.FUUID, d.Anchor); err != nil {
+		return Comment{}, err
+	}
+	if c.Mentions, err = s.mentions(f, body); err != nil {
+		return Comment{}, err
+	}
+	c, err = s.store.Insert(c)
+	if err != nil {
+		return Comment{}, err
+	}
+	s.notify(f, c, nil)
+	return c, nil
+}
+
+// Edit replaces the body of the caller's own comment. The old text is kept
+// as a revision and only newly mentioned people are notified.
+func (s *Service) Edit(v access.Viewer, f File, id, body string) (Comment, error) {
+	if err := checkAccess(v, f); err != nil {
+		return Comment{}, err
+	}
+	c, err := s.store.Comment(f.FUUID, id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if c == nil {
+		return Comment{}, ErrNotFound
+	}
+	if c.DeletedAt != nil {
+		return Comment{}, ErrDeleted
+	}
+	if c.AuthorUUID != v.UUID {
+		return Comment{}, ErrForbidden
+	}
+	body, err = checkBody(body)
+	if err != nil {
+		return Comment{}, err
+	}
+	if body == c.Body {
+		return *c, nil
+	}
+	before := c.Mentions
+	rev := Revision{CommentID: c.ID, Action: ActionEdit, Body: c.Body, EditedBy: v.UUID, EditedAt: s.Now()}
+	updated := *c
+	updated.Body = body
+	if updated.Mentions, err = s.mentions(f, body); err != nil {
+		return Comment{}, err
+	}
+	edited := rev.EditedAt
+	updated.EditedAt = &edited
+	updated, err = s.store.Update(updated, rev)
+	if err != nil {
+		return Comment{}, err
+	}
+	s.notify(f, updated, before)
+	return updated, nil
+}
+
+// Delete removes a comment's text, keeping it as a revision. Its author and
+// the file's moderators may delete it. moderated reports whether someone
+// other than the author did.
+func (s *Service) Delete(v access.Viewer, f File, id string) (moderated bool, err error) {
+	if err := checkAccess(v, f); err != nil {
+		return false, err
+	}
+	c, err := s.store.Comment(f.FUUID, id)
+	if err != nil {
+		return false, err
+	}
+	if c == nil || c.DeletedAt != nil {
+		return false, ErrNotFound
+	}
+	own := c.AuthorUUID == v.UUID
+	if !own && !canModerate(v, f) {
+		return false, ErrForbidden
+	}
+	rev := Revision{CommentID: c.ID, Action: ActionDelete, Body: c.Body, EditedBy: v.UUID, EditedAt: s.Now()}
+	if err := s.store.Delete(*c, rev); err != nil {
+		return false, err
+	}
+	return !own, nil
+}
+
+// History returns a comment's earlier versions to its author and the
+// file's moderators.
+func (s *Service) History(v access.Viewer, f File, id string) ([]Revision, error) {
+	if err := checkAccess(v, f); err != nil {
+		return nil, err
+	}
+	c, err := s.store.Comment(f.FUUID, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	if c.AuthorUUID != v.UUID && !canModerate(v, f) {
+		return nil, ErrForbidden
+	}
+	revs, err := s.store.Revisions(c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revs == nil {
+		revs = []Revision{}
+	}
+	return revs, nil
+}
@@ -0,0 +1,196 @@
+package comments
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+const (
+	deptOps     = "11111111-1111-1111-1111-111111111111"
+	deptFinance = "22222222-2222-2222-2222-222222222222"
+	fileOps     = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+)
+
+var (
+	opsHead  = access.Viewer{UUID: "ops-head", DUUID: deptOps, Position: "head", Active: true}
+	opsClerk = access.Viewer{UUID: "ops-clerk", DUUID: deptOps, Position: "regular", Active: true}
+	opsTemp  = access.Viewer{UUID: "ops-temp", DUUID: deptOps, Position: "regular", Active: true}
+	finHead  = access.Viewer{UUID: "fin-head", DUUID: deptFinance, Position: "head", Active: true}
+)
+
+type recorder struct{ events []Event }
+
+func (r *recorder) notify(e Event) { r.events = append(r.events, e) }
+
+func newTestService() (*Service, *recorder) {
+	store := NewMemoryStore()
+	store.AddUser(Member{UUID: "ops-head", Name: "Ravi", Email: "ravi@example.org", DUUID: deptOps, Position: "head"})
+	store.AddUser(Member{UUID: "ops-clerk", Name: "Asha", Email: "asha@example.org", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "ops-temp", Name: "Asha K", Email: "asha@contractor.example", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "fin-head", Name: "Meera", Email: "meera@example.org", DUUID: deptFinance, Position: "head"})
+	store.SetDocument(fileOps, "Tender notice\nBid security: ₹2,00,000 payable by DD", 3)
+
+	svc := NewService(store)
+	rec := &recorder{}
+	svc.Notify = rec.notify
+	t0 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.Now = func() time.Time { t0 = t0.Add(time.Minute); return t0 }
+	return svc, rec
+}
+
+func opsFile() File {
+	return File{
+		File: access.File{FUUID: fileOps, OwnerUUID: "ops-clerk", Sensitivity: access.Confidential, Departments: []string{deptOps}},
+		Name: "tender.pdf",
+	}
+}
+
+func TestHandles(t *testing.T) {
+	got := Handles("@Asha please check with @ravi@example.org, cc @asha. Mail me at x@y.org")
+	want := []string{"asha", "ravi@example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Handles = %v, want %v", got, want)
+	}
+	if got := Handles("no mentions here"); len(got) != 0 {
+		t.Fatalf("expected no handles, got %v", got)
+	}
+}
+
+func TestThreads(t *testing.T) {
+	svc, _ := newTestService()
+	f := opsFile()
+	root, err := svc.Create(opsClerk, f, Draft{Body: "Is the bid security right?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.ThreadID != root.ID || root.AuthorName != "Asha" {
+		t.Fatalf("unexpected root %+v", root)
+	}
+	reply, err := svc.Create(opsHead, f, Draft{Body: "Yes", ParentID: root.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := svc.Create(opsClerk, f, Draft{Body: "Thanks", ParentID: reply.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ThreadID != root.ID || nested.ThreadID != root.ID || nested.ParentID != reply.ID {
+		t.Fatalf("replies should join the root's thread: %+v %+v", reply, nested)
+	}
+	if _, err := svc.Create(opsClerk, f, Draft{Body: "x", ParentID: "nope"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing parent, got %v", err)
+	}
+	if _, err := svc.Create(opsClerk, f, Draft{Body: "  "}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	threads, err := svc.List(opsHead, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || len(threads[0].Replies) != 2 || threads[0].Replies[1].ID != nested.ID {
+		t.Fatalf("unexpected threads %+v", threads)
+	}
+	if _, err := svc.List(finHead, f); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("other departments should not read comments, got %v", err)
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	svc, _ := newTestService()
+	f := opsFile()
+	c, err := svc.Create(opsClerk, f, Draft{Body: "Amount?", Anchor: &Anchor{Page: 2, Start: 29, End: 37, Quote: "ignored"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Anchor == nil || c.Anchor.Page != 2 || c.Anchor.Quote != "2,00,000" {
+		t.Fatalf("unexpected anchor %+v", c.Anchor)
+	}
+	for _, a := range []Anchor{{Page: 4}, {Page: -1}, {Start: 5, End: 5}, {Start: 10, End: 500}} {
+		if _, err := svc.Create(opsClerk, f, Draft{Body: "x", Anchor: &a}); !errors.As(err, &ValidationError{}) {
+			t.Fatalf("anchor %+v: expected a validation error, got %v", a, err)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	svc, rec := newTestService()
+	f := opsFile()
+	// @asha is ambiguous, Meera cannot see the file and @ghost is nobody.
+	// The author's own mention is kept but not notified.
+	c, err := svc.Create(opsHead, f, Draft{Body: "@asha @asha@example.org @meera @ghost @ravi please review"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mention{
+		{UUID: "ops-clerk", Name: "Asha", Handle: "asha@example.org"},
+		{UUID: "ops-head", Name: "Ravi", Handle: "ravi"},
+	}
+	if !reflect.DeepEqual(c.Mentions, want) {
+		t.Fatalf("Mentions = %+v, want %+v", c.Mentions, want)
+	}
+	if len(rec.events) != 1 || !reflect.DeepEqual(rec.events[0].Recipients, []string{"ops-clerk"}) {
+		t.Fatalf("unexpected events %+v", rec.events)
+	}
+
+	// Editing notifies only people who were not mentioned before.
+	if _, err := svc.Edit(opsHead, f, c.ID, "@asha@example.org and @asha@contractor.example please review"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 2 || !reflect.DeepEqual(rec.events[1].Recipients, []string{"ops-temp"}) {
+		t.Fatalf("unexpected events %+v", rec.events)
+	}
+}
+
+func TestEditAndDeleteKeepHistory(t *testing.T) {
+	svc, _ := newTestService()
+	f := opsFile()
+	c, _ := svc.Create(opsTemp, f, Draft{Body: "first"})
+	reply, _ := svc.Create(opsClerk, f, Draft{Body: "reply", ParentID: c.ID})
+
+	if _, err := svc.Edit(opsClerk, f, c.ID, "hijack"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("only the author may edit, got %v", err)
+	}
+	edited, err := svc.Edit(opsTemp, f, c.ID, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Body != "second" || edited.EditedAt == nil {
+		t.Fatalf("unexpected edit %+v", edited)
+	}
+
+	// The uploader moderates; another member of the department does not.
+	if _, err := svc.Delete(opsTemp, f, reply.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if moderated, err := svc.Delete(opsClerk, f, c.ID); err != nil || !moderated {
+		t.Fatalf("the uploader should remove it: %v, %v", moderated, err)
+	}
+	if _, err := svc.Edit(opsTemp, f, c.ID, "third"); !errors.Is(err, ErrDeleted) {
+		t.Fatalf("expected ErrDeleted, got %v", err)
+	}
+	if _, err := svc.Create(opsClerk, f, Draft{Body: "late", ParentID: c.ID}); !errors.Is(err, ErrDeleted) {
+		t.Fatalf("expected ErrDeleted for a reply to a deleted comment, got %v", err)
+	}
+
+	threads, _ := svc.List(opsClerk, f)
+	if len(threads) != 1 || threads[0].DeletedAt == nil || threads[0].Body != "" || len(threads[0].Replies) != 1 {
+		t.Fatalf("deleted comment should keep its place: %+v", threads)
+	}
+
+	revs, err := svc.History(opsHead, f, c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Action != ActionEdit || revs[0].Body != "first" ||
+		revs[1].Action != ActionDelete || revs[1].Body != "second" || revs[1].EditedBy != "ops-clerk" {
+		t.Fatalf("unexpected history %+v", revs)
+	}
+	if _, err := svc.History(opsTemp, f, reply.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package comments
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (file_comments, file_comment_mentions,
+// file_comment_revisions)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(r models.FileCommentRow) Comment {
+	c := Comment{
+		ID: r.CommentID, FUUID: r.FUUID, ParentID: r.ParentID, ThreadID: r.ThreadID, AuthorUUID: r.AuthorUUID,
+		AuthorName: r.AuthorName, Body: r.Body, CreatedAt: r.CreatedAt, EditedAt: r.EditedAt, DeletedAt: r.DeletedAt,
+		Mentions: []Mention{},
+	}
+	if r.Page > 0 || r.SpanEnd > 0 {
+		c.Anchor = &Anchor{Page: r.Page, Start: r.SpanStart, End: r.SpanEnd, Quote: r.Quote}
+	}
+	for _, m := range r.Mentions {
+		c.Mentions = append(c.Mentions, Mention{UUID: m.UUID, Name: m.Name, Handle: m.Handle})
+	}
+	return c
+}
+
+func toRow(c Comment) models.FileCommentRow {
+	r := models.FileCommentRow{
+		CommentID: c.ID, FUUID: c.FUUID, ParentID: c.ParentID, ThreadID: c.ThreadID, AuthorUUID: c.AuthorUUID,
+		Body: c.Body, EditedAt: c.EditedAt,
+	}
+	if a := c.Anchor; a != nil {
+		r.Page, r.SpanStart, r.SpanEnd, r.Quote = a.Page, a.Start, a.End, a.Quote
+	}
+	for _, m := range c.Mentions {
+		r.Mentions = append(r.Mentions, models.FileCommentMentionRow{UUID: m.UUID, Handle: m.Handle})
+	}
+	return r
+}
+
+func revisionRow(r Revision) models.FileCommentRevisionRow {
+	return models.FileCommentRevisionRow{CommentID: r.CommentID, Action: r.Action, Body: r.Body, EditedBy: r.EditedBy, EditedAt: r.EditedAt}
+}
+
+func (s pgStore) OCRText(fuuid string) (string, error) {
+	return models.GetLatestOCRText(s.db, fuuid)
+}
+
+func (s pgStore) PageCount(fuuid string) (int, error) {
+	p, err := models.GetFilePreview(s.db, fuuid)
+	if err != nil || p == nil {
+		return 0, err
+	}
+	return p.PageCount, nil
+}
+
+func (s pgStore) Members(handles []string) ([]Member, error) {
+	rows, err := models.ListActiveUsersByHandle(s.db, handles)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, Member{UUID: p.UUID, Name: p.Name, Email: p.Email, DUUID: p.DUUID, Position: p.Position})
+	}
+	return out, nil
+}
+
+func (s pgStore) Comments(fuuid string) ([]Comment, error) {
+	rows, err := models.ListFileComments(s.db, fuuid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Comment, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Comment(fuuid, id string) (*Comment, error) {
+	r, err := models.GetFileComment(s.db, fuuid, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	c := fromRow(*r)
+	return &c, nil
+}
+
+func (s pgStore) Insert(c Comment) (Comment, error) {
+	row, err := models.InsertFileComment(s.db, toRow(c))
+	if err != nil {
+		return Comment{}, err
+	}
+	c.ID, c.ThreadID, c.CreatedAt = row.CommentID, row.ThreadID, row.CreatedAt
+	return c, nil
+}
+
+func (s pgStore) Update(c Comment, rev Revision) (Comment, error) {
+	if err := models.UpdateFileComment(s.db, toRow(c), revisionRow(rev)); err == sql.ErrNoRows {
+		return Comment{}, ErrNotFound
+	} else if err != nil {
+		return Comment{}, err
+	}
+	return c, nil
+}
+
+func (s pgStore) Delete(c Comment, rev Revision) error {
+	if err := models.DeleteFileComment(s.db, c.ID, revisionRow(rev)); err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) Revisions(id string) ([]Revision, error) {
+	rows, err := models.ListFileCommentRevisions(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Revision, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Revision{CommentID: r.CommentID, Action: r.Action, Body: r.Body, EditedBy: r.EditedBy, EditedAt: r.EditedAt})
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store, for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	users     map[string]Member
+	ocr       map[string]string
+	pages     map[string]int
+	comments  []Comment
+	revisions map[string][]Revision
+	seq       int
+	now       time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:     map[string]Member{},
+		ocr:       map[string]string{},
+		pages:     map[string]int{},
+		revisions: map[string][]Revision{},
+		now:       time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func (m *MemoryStore) AddUser(u Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.UUID] = u
+}
+
+// SetDocument records a file's OCR text and page count (0 when unknown).
+func (m *MemoryStore) SetDocument(fuuid, text string, pages int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ocr[fuuid], m.pages[fuuid] = text, pages
+}
+
+func (m *MemoryStore) tick() time.Time {
+	m.now = m.now.Add(time.Minute)
+	return m.now
+}
+
+func (m *MemoryStore) OCRText(fuuid string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ocr[fuuid], nil
+}
+
+func (m *MemoryStore) PageCount(fuuid string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pages[fuuid], nil
+}
+
+func (m *MemoryStore) Members(handles []string) ([]Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Member
+	for _, u := range m.users {
+		for _, h := range handles {
+			if matchesHandle(u, strings.ToLower(h)) {
+				out = append(out, u)
+				break
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UUID < out[j].UUID })
+	return out, nil
+}
+
+// copyOf fills in names and copies the mention list.
+func (m *MemoryStore) copyOf(c Comment) Comment {
+	c.AuthorName = m.users[c.AuthorUUID].Name
+	mentions := make([]Mention, 0, len(c.Mentions))
+	for _, x := range c.Mentions {
+		x.Name = m.users[x.UUID].Name
+		mentions = append(mentions, x)
+	}
+	c.Mentions = mentions
+	return c
+}
+
+func (m *MemoryStore) find(fuuid, id string) int {
+	for i, c := range m.comments {
+		if c.ID == id && c.FUUID == fuuid {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryStore) Comments(fuuid string) ([]Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Comment{}
+	for _, c := range m.comments {
+		if c.FUUID == fuuid {
+			out = append(out, m.copyOf(c))
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Comment(fuuid, id string) (*Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(fuuid, id)
+	if i < 0 {
+		return nil, nil
+	}
+	c := m.copyOf(m.comments[i])
+	return &c, nil
+}
+
+func (m *MemoryStore) Insert(c Comment) (Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	c.ID = fmt.Sprintf("c-%d", m.seq)
+	if c.ThreadID == "" {
+		c.ThreadID = c.ID
+	}
+	c.CreatedAt = m.tick()
+	m.comments = append(m.comments, c)
+	return m.copyOf(c), nil
+}
+
+func (m *MemoryStore) Update(c Comment, rev Revision) (Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(c.FUUID, c.ID)
+	if i < 0 || m.comments[i].DeletedAt != nil {
+		return Comment{}, ErrNotFound
+	}
+	old := &m.comments[i]
+	old.Body, old.Mentions, old.EditedAt = c.Body, c.Mentions, c.EditedAt
+	m.revisions[c.ID] = append(m.revisions[c.ID], rev)
+	return m.copyOf(*old), nil
+}
+
+func (m *MemoryStore) Delete(c Comment, rev Revision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(c.FUUID, c.ID)
+	if i < 0 || m.comments[i].DeletedAt != nil {
+		return ErrNotFound
+	}
+	deleted := rev.EditedAt
+	old := &m.comments[i]
+	old.Body, old.Mentions, old.DeletedAt = "", nil, &deleted
+	m.revisions[c.ID] = append(m.revisions[c.ID], rev)
+	return nil
+}
+
+func (m *MemoryStore) Revisions(id string) ([]Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Revision{}, m.revisions[id]...), nil
+}
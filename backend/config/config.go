@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "github.com/lib/pq"
 
@@ -162,3 +163,225 @@ func (s SupabaseClient) InsertDocument(doc models.Document) (string, error) {
 	}
 	return inserted[0].FUUID, nil
 }
+
+// OpenFile starts a GET for an object in Supabase Storage and returns the
+// response for the caller to stream. Range and conditional headers in
+// header are passed through, so the response may be 206 or 304; the caller
+// must close the body.
+func (s SupabaseClient) OpenFile(bucket, path string, header http.Header) (*http.Response, error) {
+	encodedPath := url.PathEscape(path)
+	endpoint := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.URL, bucket, encodedPath)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	req.Header.Set("apikey", s.Key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// SignedURL asks Supabase Storage for a URL that serves the object without
+// credentials for expiresIn seconds.
+func (s SupabaseClient) SignedURL(bucket, path string, expiresIn int) (string, error) {
+	encodedPath := url.PathEscape(path)
+	endpoint := fmt.Sprintf("%s/storage/v1/object/sign/%s/%s", s.URL, bucket, encodedPath)
+
+	data, _ := json.Marshal(map[string]int{"expiresIn": expiresIn})
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	req.Header.Set("apikey", s.Key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("sign failed: %s", resp.Status)
+	}
+	var out struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.SignedURL == "" {
+		return "", fmt.Errorf("no signed URL returned")
+	}
+	return s.URL + "/storage/v1" + out.SignedURL, nil
+}
+
+// PutFile stores data at path in Supabase Storage, replacing any object
+// already there.
+func (s SupabaseClient) PutFile(bucket, path string, data []byte, contentType string) error {
+	encodedPath := url.PathEscape(path)
+	endpoint := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.URL, bucket, encodedPath)
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	req.Header.Set("apikey", s.Key)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-upsert", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("upload failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// MoveFile moves the object at path from bucket to the same path in
+// toBucket.
+func (s SupabaseClient) MoveFile(bucket, path, toBucket string) error {
+	endpoint := fmt.Sprintf("%s/storage/v1/object/move", s.URL)
+
+	data, _ := json.Marshal(map[string]string{
+		"bucketId": bucket, "sourceKey": path, "destinationBucket": toBucket, "destinationKey": path,
+	})
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	req.Header.Set("apikey", s.Key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("move failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// DeleteFiles removes objects from bucket. Paths that do not exist are
+// ignored by Supabase.
+func (s SupabaseClient) DeleteFiles(bucket string, paths []string) error {
+	endpoint := fmt.Sprintf("%s/storage/v1/object/%s", s.URL, bucket)
+
+	data, _ := json.Marshal(map[string][]string{"prefixes": paths})
+	req, err := http.NewRequest("DELETE", endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	req.Header.Set("apikey", s.Key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("delete failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// StorageObject is an object listed from a bucket. Path is relative to the
+// bucket.
+type StorageObject struct {
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// listPageSize is how many entries ListFiles asks Supabase for at a time.
+const listPageSize = 1000
+
+// ListFiles returns every object in bucket under prefix ("" for the whole
+// bucket), walking into folders.
+func (s SupabaseClient) ListFiles(bucket, prefix string) ([]StorageObject, error) {
+	endpoint := fmt.Sprintf("%s/storage/v1/object/list/%s", s.URL, bucket)
+
+	var out []StorageObject
+	for offset := 0; ; offset += listPageSize {
+		data, _ := json.Marshal(map[string]interface{}{
+			"prefix": prefix, "limit": listPageSize, "offset": offset,
+			"sortBy": map[string]string{"column": "name", "order": "asc"},
+		})
+		req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.Key)
+		req.Header.Set("apikey", s.Key)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var entries []struct {
+			Name      string    `json:"name"`
+			ID        *string   `json:"id"`
+			CreatedAt time.Time `json:"created_at"`
+			Metadata  struct {
+				Size int64 `json:"size"`
+			} `json:"metadata"`
+		}
+		if resp.StatusCode >= 400 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("list failed: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			p := e.Name
+			if prefix != "" {
+				p = prefix + "/" + e.Name
+			}
+			// Folders are listed without an id.
+			if e.ID == nil {
+				children, err := s.ListFiles(bucket, p)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, children...)
+				continue
+			}
+			out = append(out, StorageObject{Path: p, Size: e.Metadata.Size, CreatedAt: e.CreatedAt})
+		}
+		if len(entries) < listPageSize {
+			return out, nil
+		}
+	}
+}
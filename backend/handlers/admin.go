@@ -2,33 +2,32 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"backend/config"
+	"backend/models"
 )
 
 // ---------------------------------------------------------------------------
-// Admin session store (in-memory)
+// Admin sessions (see admin_session_store.go for persistence)
 // ---------------------------------------------------------------------------
 
-type adminSession struct {
-	AdminID   string
-	Username  string
-	ExpiresAt time.Time
-}
-
-var (
-	adminSessions = sync.Map{} // map[token]adminSession
-)
+type adminSessionCtxKey struct{}
 
 // generateToken creates a secure random hex token.
 func generateToken() (string, error) {
@@ -39,7 +38,82 @@ func generateToken() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-// AdminAuthMiddleware verifies the admin Bearer token.
+// adminSessionFromRequest returns the session attached by AdminAuthMiddleware.
+func adminSessionFromRequest(r *http.Request) *models.AdminSession {
+	sess, _ := r.Context().Value(adminSessionCtxKey{}).(*models.AdminSession)
+	return sess
+}
+
+// trustedProxies is TRUSTED_PROXIES: comma-separated addresses or CIDR
+// ranges of the proxies in front of the API.
+var trustedProxies = sync.OnceValue(func() []netip.Prefix {
+	return parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+})
+
+func parseTrustedProxies(s string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(part); err == nil {
+			out = append(out, p.Masked())
+		} else if a, err := netip.ParseAddr(part); err == nil {
+			out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+		} else {
+			log.Printf("[ADMIN] Ignoring invalid TRUSTED_PROXIES entry %q", part)
+		}
+	}
+	return out
+}
+
+func trusted(proxies []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range proxies {
+		if p.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address audit rows and admin sessions record.
+func clientIP(r *http.Request) string {
+	return clientIPVia(r, trustedProxies())
+}
+
+// clientIPVia returns the peer address unless the peer is a trusted proxy;
+// then X-Forwarded-For is read from the right and the first hop that is not
+// a trusted proxy is the client. Hops left of it could be forged by the
+// client, so they are never used.
+func clientIPVia(r *http.Request, proxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !trusted(proxies, peer) {
+		return host
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap()
+		if !trusted(proxies, client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// AdminAuthMiddleware verifies the admin Bearer token and slides the idle expiry.
 func AdminAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setCORS(w)
@@ -55,19 +129,29 @@ func AdminAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 		token := strings.TrimPrefix(auth, "Bearer ")
 
-		val, ok := adminSessions.Load(token)
-		if !ok {
+		sess, err := adminSessions.GetByTokenHash(hashAdminToken(token))
+		if err != nil {
+			log.Printf("[ADMIN] Session lookup failed: %v", err)
+			http.Error(w, `{"error":"failed to verify admin session"}`, http.StatusInternalServerError)
+			return
+		}
+		if sess == nil {
 			http.Error(w, `{"error":"invalid or expired admin token"}`, http.StatusUnauthorized)
 			return
 		}
-		sess := val.(adminSession)
-		if time.Now().After(sess.ExpiresAt) {
-			adminSessions.Delete(token)
+		now := time.Now()
+		if !adminSessionActive(sess, now) {
+			_, _ = adminSessions.Delete(sess.ID)
 			http.Error(w, `{"error":"admin session expired"}`, http.StatusUnauthorized)
 			return
 		}
+		if now.Sub(sess.LastSeenAt) >= adminSessionTouchInterval {
+			if err := adminSessions.Touch(sess.ID, now); err == nil {
+				sess.LastSeenAt = now
+			}
+		}
 
-		next(w, r)
+		next(w, r.WithContext(context.WithValue(r.Context(), adminSessionCtxKey{}, sess)))
 	}
 }
 
@@ -87,9 +171,10 @@ type adminLoginReq struct {
 }
 
 type adminLoginResp struct {
-	Token    string `json:"token"`
-	AdminID  string `json:"adminId"`
-	Username string `json:"username"`
+	Token     string `json:"token"`
+	SessionID string `json:"sessionId"`
+	AdminID   string `json:"adminId"`
+	Username  string `json:"username"`
 }
 
 func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -116,10 +201,12 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	).Scan(&aUUID, &storedPass)
 
 	if err != nil {
+		recordAudit(r, auditActor{Type: "admin", ID: "unknown", Name: req.Username}, auditAdminLoginFailed, "admin", req.Username, nil)
 		http.Error(w, `{"error":"admin username not found"}`, http.StatusUnauthorized)
 		return
 	}
 	if storedPass != req.Password {
+		recordAudit(r, auditActor{Type: "admin", ID: aUUID, Name: req.Username}, auditAdminLoginFailed, "admin", aUUID, nil)
 		http.Error(w, `{"error":"invalid admin credentials"}`, http.StatusUnauthorized)
 		return
 	}
@@ -130,17 +217,34 @@ func AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	adminSessions.Store(token, adminSession{
-		AdminID:   aUUID,
-		Username:  req.Username,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-	})
+	sessionID, err := newJobID()
+	if err != nil {
+		http.Error(w, `{"error":"failed to generate session"}`, http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	if err := adminSessions.Create(models.AdminSession{
+		ID:         sessionID,
+		TokenHash:  hashAdminToken(token),
+		AdminID:    aUUID,
+		Username:   req.Username,
+		IP:         clientIP(r),
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(adminSessionMaxLifetime()),
+	}); err != nil {
+		http.Error(w, `{"error":"failed to store session"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, auditActor{Type: "admin", ID: aUUID, Name: req.Username}, auditAdminLogin, "admin_session", sessionID, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(adminLoginResp{
-		Token:    token,
-		AdminID:  aUUID,
-		Username: req.Username,
+		Token:     token,
+		SessionID: sessionID,
+		AdminID:   aUUID,
+		Username:  req.Username,
 	})
 }
 
@@ -154,10 +258,11 @@ func AdminLogoutHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
-	auth := r.Header.Get("Authorization")
-	if strings.HasPrefix(auth, "Bearer ") {
-		token := strings.TrimPrefix(auth, "Bearer ")
-		adminSessions.Delete(token)
+	if sess := adminSessionFromRequest(r); sess != nil {
+		if _, err := adminSessions.Delete(sess.ID); err != nil {
+			log.Printf("[ADMIN] Failed to revoke session on logout: %v", err)
+		}
+		recordAudit(r, adminActor(r), auditAdminLogout, "admin_session", sess.ID, nil)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"ok":true}`))
@@ -196,6 +301,13 @@ func forwardJSON(w http.ResponseWriter, resp *http.Response) {
 	w.Write(respBody)
 }
 
+// writeJSON encodes v with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
 // ---------------------------------------------------------------------------
 // GET /v1/admin/users — list all users with auth enrichment
 // ---------------------------------------------------------------------------
@@ -210,6 +322,7 @@ func AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := config.DB.Query(`
 		SELECT
 			u.uuid, u.name, u.email, u.phone_number, u.address, u.position,
+			COALESCE(u.is_active, true),
 			d.d_uuid, d.d_name,
 			r.r_uuid, r.r_name
 		FROM users u
@@ -231,6 +344,7 @@ func AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
 		PhoneNumber *string `json:"phone_number"`
 		Address     *string `json:"address"`
 		Position    *string `json:"position"`
+		IsActive    bool    `json:"is_active"`
 		Department  *struct {
 			DUuid string `json:"d_uuid"`
 			DName string `json:"d_name"`
@@ -248,7 +362,7 @@ func AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var u userRow
 		var dUUID, dName, rUUID, rName *string
-		if err := rows.Scan(&u.UUID, &u.Name, &u.Email, &u.PhoneNumber, &u.Address, &u.Position,
+		if err := rows.Scan(&u.UUID, &u.Name, &u.Email, &u.PhoneNumber, &u.Address, &u.Position, &u.IsActive,
 			&dUUID, &dName, &rUUID, &rName); err != nil {
 			log.Printf("[ADMIN] Row scan error: %v", err)
 			continue
@@ -314,7 +428,7 @@ func AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // ---------------------------------------------------------------------------
-// POST /v1/admin/users — create a new user via Supabase Auth
+// POST /v1/admin/users — create auth user + profile row (see user_lifecycle.go)
 // ---------------------------------------------------------------------------
 
 type createUserReq struct {
@@ -335,49 +449,42 @@ func AdminCreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic server-side validation
-	if req.Email == "" || req.Password == "" {
-		http.Error(w, `{"error":"email and password are required"}`, http.StatusBadRequest)
-		return
-	}
-	if len(req.Password) < 6 {
-		http.Error(w, `{"error":"password must be at least 6 characters"}`, http.StatusBadRequest)
-		return
-	}
-
-	// Build the Supabase Auth Admin API payload
-	// email_confirm: false → Supabase marks the email as unverified, then we trigger
-	// the confirmation email separately via /auth/v1/resend.
-	payload := map[string]interface{}{
-		"email":         req.Email,
-		"password":      req.Password,
-		"email_confirm": false,
-		"user_metadata": req.UserMetadata,
-	}
-	body, _ := json.Marshal(payload)
-
-	resp, err := supabaseAuthRequest("POST", supabaseAuthURL(), bytes.NewReader(body))
+	profile, err := lifecycle.Create(newUserFromMetadata(req.Email, req.Password, req.UserMetadata))
 	if err != nil {
-		log.Printf("[ADMIN] Failed to call Supabase Auth: %v", err)
-		http.Error(w, `{"error":"failed to create user in auth"}`, http.StatusInternalServerError)
-		return
-	}
-
-	// Log non-success responses from Supabase so we can debug
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		log.Printf("[ADMIN] Supabase create-user returned %d: %s", resp.StatusCode, string(respBody))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.StatusCode)
-		w.Write(respBody)
+		var authErr *authAPIError
+		switch {
+		case errors.Is(err, errValidation):
+			msg := err.Error()
+			var sagaErr *sagaError
+			if errors.As(err, &sagaErr) {
+				msg = sagaErr.Err.Error()
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		case errors.As(err, &authErr):
+			// Forward Supabase's own error (duplicate email, weak password, ...)
+			log.Printf("[ADMIN] Supabase create-user returned %d: %s", authErr.Status, string(authErr.Body))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(authErr.Status)
+			w.Write(authErr.Body)
+		default:
+			log.Printf("[ADMIN] Create user failed: %v", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create user: " + err.Error()})
+		}
 		return
 	}
 
 	// User created successfully — send the confirmation email in the background.
-	go sendConfirmationEmail(req.Email)
+	go sendConfirmationEmail(profile.Email)
 
-	forwardJSON(w, resp)
+	recordAudit(r, adminActor(r), auditUserCreate, "user", profile.UUID, map[string]interface{}{
+		"email":         profile.Email,
+		"user_metadata": req.UserMetadata,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      profile.UUID,
+		"email":   profile.Email,
+		"profile": profile,
+	})
 }
 
 // sendConfirmationEmail triggers Supabase to send the signup-confirmation email
@@ -412,6 +519,31 @@ func sendConfirmationEmail(email string) {
 	}
 }
 
+// sendPasswordSetupEmail asks Supabase to email a password recovery link by
+// calling POST /auth/v1/recover with the anon key. Users created without a
+// password of their own choose one through it; following the link also
+// confirms the address.
+func sendPasswordSetupEmail(email string) error {
+	payload, _ := json.Marshal(map[string]string{"email": email})
+	req, err := http.NewRequest("POST", config.Supabase.URL+"/auth/v1/recover", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", config.Supabase.Key) // anon key
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("/auth/v1/recover returned %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // PUT /v1/admin/users?id=<uuid> — update a user via Supabase Auth
 // ---------------------------------------------------------------------------
@@ -438,24 +570,66 @@ func AdminUpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	url := fmt.Sprintf("%s/%s", supabaseAuthURL(), userID)
+	// Read the current metadata first so the audit entry has both sides.
+	current, err := supabaseAuthRequest("GET", url, nil)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to fetch auth user: %v", err)
+		http.Error(w, `{"error":"failed to fetch user from auth"}`, http.StatusInternalServerError)
+		return
+	}
+	if current.StatusCode >= 300 {
+		forwardJSON(w, current)
+		return
+	}
+	var before struct {
+		UserMetadata map[string]interface{} `json:"user_metadata"`
+	}
+	err = json.NewDecoder(current.Body).Decode(&before)
+	current.Body.Close()
+	if err != nil {
+		log.Printf("[ADMIN] Failed to decode auth user %s: %v", userID, err)
+		http.Error(w, `{"error":"failed to fetch user from auth"}`, http.StatusInternalServerError)
+		return
+	}
+
 	payload := map[string]interface{}{
 		"user_metadata": req.UserMetadata,
 	}
 	body, _ := json.Marshal(payload)
 
-	url := fmt.Sprintf("%s/%s", supabaseAuthURL(), userID)
 	resp, err := supabaseAuthRequest("PUT", url, bytes.NewReader(body))
 	if err != nil {
 		log.Printf("[ADMIN] Failed to update auth user: %v", err)
 		http.Error(w, `{"error":"failed to update user in auth"}`, http.StatusInternalServerError)
 		return
 	}
+	if resp.StatusCode < 300 {
+		recordAudit(r, adminActor(r), auditUserUpdate, "user", userID, map[string]interface{}{
+			"user_metadata": metadataChanges(before.UserMetadata, req.UserMetadata),
+		})
+	}
 
 	forwardJSON(w, resp)
 }
 
+// metadataChanges lists the keys of update whose value differs from before,
+// each as {"from": ..., "to": ...}. Supabase merges user_metadata, so keys
+// missing from update are unchanged and left out.
+func metadataChanges(before, update map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, to := range update {
+		from := before[key]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		out[key] = map[string]interface{}{"from": from, "to": to}
+	}
+	return out
+}
+
 // ---------------------------------------------------------------------------
-// DELETE /v1/admin/users?id=<uuid> — delete a user from auth + public.users
+// DELETE /v1/admin/users?id=<uuid>[&reassign_to=<uuid>] — soft-deactivate a user
 // ---------------------------------------------------------------------------
 
 func AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -470,31 +644,100 @@ func AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Delete from Supabase Auth
-	url := fmt.Sprintf("%s/%s", supabaseAuthURL(), userID)
-	resp, err := supabaseAuthRequest("DELETE", url, nil)
-	authDeleted := false
-	if err == nil {
-		resp.Body.Close()
-		authDeleted = resp.StatusCode < 300
+	result, err := lifecycle.Deactivate(userID, r.URL.Query().Get("reassign_to"))
+	switch {
+	case errors.Is(err, errNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, errValidation):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, errNoReassignTarget):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "user owns files and the department has no head; pass reassign_to"})
+		return
+	case err != nil:
+		log.Printf("[ADMIN] Failed to deactivate user %s: %v", userID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to deactivate user: " + err.Error()})
+		return
 	}
 
-	// 2. Delete from public.users
-	_, dbErr := config.DB.Exec("DELETE FROM users WHERE uuid = $1", userID)
+	recordAudit(r, adminActor(r), auditUserDelete, "user", userID, result)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":               true,
+		"deactivated":      true,
+		"files_reassigned": result.FilesReassigned,
+		"reassigned_to":    result.ReassignedTo,
+		"already_inactive": result.AlreadyInactive,
+	})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if dbErr != nil {
-		log.Printf("[ADMIN] Failed to delete user from DB: %v", dbErr)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":        "failed to delete user from database",
-			"auth_deleted": authDeleted,
+// ---------------------------------------------------------------------------
+// GET /v1/admin/sessions — list active admin sessions
+// ---------------------------------------------------------------------------
+
+type adminSessionView struct {
+	models.AdminSession
+	IdleExpiresAt time.Time `json:"idle_expires_at"`
+	Current       bool      `json:"current"`
+}
+
+func AdminListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	sessions, err := adminSessions.List(now)
+	if err != nil {
+		http.Error(w, `{"error":"failed to list sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	current := adminSessionFromRequest(r)
+	views := []adminSessionView{}
+	for i := range sessions {
+		if !adminSessionActive(&sessions[i], now) {
+			continue
+		}
+		views = append(views, adminSessionView{
+			AdminSession:  sessions[i],
+			IdleExpiresAt: sessions[i].LastSeenAt.Add(adminSessionIdleTimeout()),
+			Current:       current != nil && current.ID == sessions[i].ID,
 		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// ---------------------------------------------------------------------------
+// DELETE /v1/admin/sessions/{id} — revoke an admin session
+// ---------------------------------------------------------------------------
+
+func AdminRevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"ok":           true,
-		"auth_deleted": authDeleted,
-	})
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
+		http.Error(w, `{"error":"missing session id"}`, http.StatusBadRequest)
+		return
+	}
+
+	found, err := adminSessions.Delete(sessionID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to revoke session"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"session not found"}`, http.StatusNotFound)
+		return
+	}
+	recordAudit(r, adminActor(r), auditSessionRevoke, "admin_session", sessionID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`))
 }
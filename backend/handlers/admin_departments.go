@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"backend/config"
+	"backend/models"
+)
+
+const (
+	auditDepartmentCreate = "department.create"
+	auditDepartmentUpdate = "department.update"
+	auditDepartmentDelete = "department.delete"
+	auditRoleCreate       = "role.create"
+	auditRoleUpdate       = "role.update"
+	auditRoleDelete       = "role.delete"
+)
+
+// validateEntityName trims and checks a department or role name.
+func validateEntityName(kind, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New(kind + " name is required")
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		return "", errors.New(kind + " name must be at most 100 characters")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", errors.New(kind + " name contains invalid characters")
+		}
+	}
+	return name, nil
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/departments — GET list, POST create, PUT ?id= rename,
+// DELETE ?id=[&reassign_to=<d_uuid>]
+// ---------------------------------------------------------------------------
+
+type departmentReq struct {
+	DName string `json:"d_name"`
+}
+
+func AdminDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		adminListDepartments(w, r)
+	case http.MethodPost:
+		adminCreateDepartment(w, r)
+	case http.MethodPut:
+		adminUpdateDepartment(w, r)
+	case http.MethodDelete:
+		adminDeleteDepartment(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func adminListDepartments(w http.ResponseWriter, r *http.Request) {
+	depts, err := models.ListDepartmentsWithUsage(config.DB)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to list departments: %v", err)
+		http.Error(w, `{"error":"failed to fetch departments"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, depts)
+}
+
+func adminCreateDepartment(w http.ResponseWriter, r *http.Request) {
+	var req departmentReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	name, err := validateEntityName("department", req.DName)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	existing, err := models.GetDepartmentByName(config.DB, name)
+	if err != nil {
+		http.Error(w, `{"error":"failed to check department name"}`, http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		http.Error(w, `{"error":"a department with this name already exists"}`, http.StatusConflict)
+		return
+	}
+
+	dept, err := models.CreateDepartment(config.DB, name)
+	if err != nil {
+		http.Error(w, `{"error":"failed to create department"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, adminActor(r), auditDepartmentCreate, "department", dept.DUUID, map[string]string{"d_name": name})
+	writeJSON(w, http.StatusCreated, dept)
+}
+
+func adminUpdateDepartment(w http.ResponseWriter, r *http.Request) {
+	dUUID := r.URL.Query().Get("id")
+	if dUUID == "" {
+		http.Error(w, `{"error":"missing department id"}`, http.StatusBadRequest)
+		return
+	}
+	var req departmentReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	name, err := validateEntityName("department", req.DName)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	before, err := models.GetDepartmentByUUID(config.DB, dUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch department"}`, http.StatusInternalServerError)
+		return
+	}
+	if before == nil {
+		http.Error(w, `{"error":"department not found"}`, http.StatusNotFound)
+		return
+	}
+	if clash, err := models.GetDepartmentByName(config.DB, name); err == nil && clash != nil && clash.DUUID != dUUID {
+		http.Error(w, `{"error":"a department with this name already exists"}`, http.StatusConflict)
+		return
+	}
+
+	if err := models.RenameDepartment(config.DB, dUUID, name); err != nil {
+		http.Error(w, `{"error":"failed to update department"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, adminActor(r), auditDepartmentUpdate, "department", dUUID, map[string]interface{}{
+		"d_name": map[string]string{"from": before.DName, "to": name},
+	})
+	writeJSON(w, http.StatusOK, models.Department{DUUID: dUUID, DName: name})
+}
+
+func adminDeleteDepartment(w http.ResponseWriter, r *http.Request) {
+	dUUID := r.URL.Query().Get("id")
+	if dUUID == "" {
+		http.Error(w, `{"error":"missing department id"}`, http.StatusBadRequest)
+		return
+	}
+	reassignTo := r.URL.Query().Get("reassign_to")
+	if strings.EqualFold(reassignTo, dUUID) {
+		http.Error(w, `{"error":"cannot reassign to the department being deleted"}`, http.StatusBadRequest)
+		return
+	}
+
+	before, err := models.GetDepartmentByUUID(config.DB, dUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch department"}`, http.StatusInternalServerError)
+		return
+	}
+	if before == nil {
+		http.Error(w, `{"error":"department not found"}`, http.StatusNotFound)
+		return
+	}
+	if reassignTo != "" {
+		target, err := models.GetDepartmentByUUID(config.DB, reassignTo)
+		if err != nil || target == nil {
+			http.Error(w, `{"error":"reassignment department not found"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	usage, err := models.GetDepartmentUsage(config.DB, dUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to inspect department usage"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err = models.DeleteDepartment(config.DB, dUUID, reassignTo)
+	if errors.Is(err, models.ErrDepartmentInUse) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": "department still has users, files, workflows or workspaces; pass reassign_to to move them",
+			"usage": usage,
+		})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, `{"error":"department not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ADMIN] Failed to delete department %s: %v", dUUID, err)
+		http.Error(w, `{"error":"failed to delete department"}`, http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, adminActor(r), auditDepartmentDelete, "department", dUUID, map[string]interface{}{
+		"d_name":      before.DName,
+		"usage":       usage,
+		"reassign_to": reassignTo,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "moved": usage})
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/roles — GET ?d_uuid=, POST create, PUT ?id= rename, DELETE ?id=
+// ---------------------------------------------------------------------------
+
+type roleReq struct {
+	RName string `json:"r_name"`
+	DUUID string `json:"d_uuid"`
+}
+
+func AdminRolesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		adminListRoles(w, r)
+	case http.MethodPost:
+		adminCreateRole(w, r)
+	case http.MethodPut:
+		adminUpdateRole(w, r)
+	case http.MethodDelete:
+		adminDeleteRole(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func adminListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := models.ListRoles(config.DB, r.URL.Query().Get("d_uuid"))
+	if err != nil {
+		log.Printf("[ADMIN] Failed to list roles: %v", err)
+		http.Error(w, `{"error":"failed to fetch roles"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
+
+func adminCreateRole(w http.ResponseWriter, r *http.Request) {
+	var req roleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	name, err := validateEntityName("role", req.RName)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if req.DUUID == "" {
+		http.Error(w, `{"error":"d_uuid is required"}`, http.StatusBadRequest)
+		return
+	}
+	dept, err := models.GetDepartmentByUUID(config.DB, req.DUUID)
+	if err != nil || dept == nil {
+		http.Error(w, `{"error":"department not found"}`, http.StatusBadRequest)
+		return
+	}
+	if clash, err := models.GetRoleByName(config.DB, req.DUUID, name); err == nil && clash != nil {
+		http.Error(w, `{"error":"a role with this name already exists in the department"}`, http.StatusConflict)
+		return
+	}
+
+	role, err := models.CreateRole(config.DB, name, req.DUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to create role"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, adminActor(r), auditRoleCreate, "role", role.RUUID, map[string]string{"r_name": name, "d_uuid": req.DUUID})
+	writeJSON(w, http.StatusCreated, role)
+}
+
+func adminUpdateRole(w http.ResponseWriter, r *http.Request) {
+	rUUID := r.URL.Query().Get("id")
+	if rUUID == "" {
+		http.Error(w, `{"error":"missing role id"}`, http.StatusBadRequest)
+		return
+	}
+	var req roleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	name, err := validateEntityName("role", req.RName)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	before, err := models.GetRoleByUUID(config.DB, rUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch role"}`, http.StatusInternalServerError)
+		return
+	}
+	if before == nil {
+		http.Error(w, `{"error":"role not found"}`, http.StatusNotFound)
+		return
+	}
+	if clash, err := models.GetRoleByName(config.DB, before.DUUID, name); err == nil && clash != nil && clash.RUUID != rUUID {
+		http.Error(w, `{"error":"a role with this name already exists in the department"}`, http.StatusConflict)
+		return
+	}
+
+	if err := models.RenameRole(config.DB, rUUID, name); err != nil {
+		http.Error(w, `{"error":"failed to update role"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, adminActor(r), auditRoleUpdate, "role", rUUID, map[string]interface{}{
+		"r_name": map[string]string{"from": before.RName, "to": name},
+	})
+	before.RName = name
+	writeJSON(w, http.StatusOK, before)
+}
+
+func adminDeleteRole(w http.ResponseWriter, r *http.Request) {
+	rUUID := r.URL.Query().Get("id")
+	if rUUID == "" {
+		http.Error(w, `{"error":"missing role id"}`, http.StatusBadRequest)
+		return
+	}
+	before, err := models.GetRoleByUUID(config.DB, rUUID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch role"}`, http.StatusInternalServerError)
+		return
+	}
+	if before == nil {
+		http.Error(w, `{"error":"role not found"}`, http.StatusNotFound)
+		return
+	}
+
+	err = models.DeleteRole(config.DB, rUUID)
+	if errors.Is(err, models.ErrRoleInUse) {
+		http.Error(w, `{"error":"role is still assigned to users; reassign them first"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, `{"error":"role not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to delete role"}`, http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, adminActor(r), auditRoleDelete, "role", rUUID, map[string]string{"r_name": before.RName, "d_uuid": before.DUUID})
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
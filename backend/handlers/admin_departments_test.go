@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The self-reassignment check runs before any query, so it needs no
+// database.
+func TestDeleteDepartmentRejectsReassignToItself(t *testing.T) {
+	for _, target := range []string{
+		"3f1c2a9e-8b7d-4c6e-9f00-123456789abc",
+		"3F1C2A9E-8B7D-4C6E-9F00-123456789ABC",
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/v1/admin/departments?id=3f1c2a9e-8b7d-4c6e-9f00-123456789abc&reassign_to="+target, nil)
+		rec := httptest.NewRecorder()
+		AdminDepartmentsHandler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("reassign_to=%s: expected 400, got %d %s", target, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+// AdminSessionStore persists admin sessions. The Postgres store is used in
+// production; the memory store is used by tests and by ADMIN_SESSION_STORE=memory.
+type AdminSessionStore interface {
+	Create(s models.AdminSession) error
+	GetByTokenHash(tokenHash string) (*models.AdminSession, error)
+	Touch(id string, seenAt time.Time) error
+	Delete(id string) (bool, error)
+	List(now time.Time) ([]models.AdminSession, error)
+	DeleteExpired(now, idleCutoff time.Time) (int64, error)
+}
+
+var adminSessions AdminSessionStore = pgAdminSessionStore{}
+
+// SetAdminSessionStore swaps the session backend.
+func SetAdminSessionStore(store AdminSessionStore) {
+	adminSessions = store
+}
+
+// InitAdminSessionStore picks the session backend from ADMIN_SESSION_STORE.
+func InitAdminSessionStore() {
+	if strings.EqualFold(os.Getenv("ADMIN_SESSION_STORE"), "memory") {
+		log.Println("WARNING: ADMIN_SESSION_STORE=memory — admin sessions will not survive restarts")
+		SetAdminSessionStore(NewMemoryAdminSessionStore())
+		return
+	}
+	SetAdminSessionStore(pgAdminSessionStore{})
+}
+
+// adminSessionIdleTimeout is the sliding window; each request extends it.
+func adminSessionIdleTimeout() time.Duration {
+	return time.Duration(envInt("ADMIN_SESSION_IDLE_MINUTES", 120)) * time.Minute
+}
+
+// adminSessionMaxLifetime caps a session regardless of activity.
+func adminSessionMaxLifetime() time.Duration {
+	return time.Duration(envInt("ADMIN_SESSION_MAX_HOURS", 24)) * time.Hour
+}
+
+// envInt reads a positive integer from the environment, falling back to def.
+func envInt(key string, def int) int {
+	if raw := os.Getenv(key); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// adminSessionTouchInterval throttles last_seen_at writes so every admin
+// request does not turn into an UPDATE.
+const adminSessionTouchInterval = time.Minute
+
+func hashAdminToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// adminSessionActive applies both the absolute and the idle expiry.
+func adminSessionActive(s *models.AdminSession, now time.Time) bool {
+	if !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return now.Before(s.LastSeenAt.Add(adminSessionIdleTimeout()))
+}
+
+// StartAdminSessionJanitor periodically removes expired sessions.
+func StartAdminSessionJanitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			now := time.Now()
+			n, err := adminSessions.DeleteExpired(now, now.Add(-adminSessionIdleTimeout()))
+			if err != nil {
+				log.Printf("[ADMIN] Session cleanup failed: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("[ADMIN] Removed %d expired admin sessions", n)
+			}
+		}
+	}()
+}
+
+// ---------------------------------------------------------------------------
+// Postgres store
+// ---------------------------------------------------------------------------
+
+type pgAdminSessionStore struct{}
+
+func (pgAdminSessionStore) Create(s models.AdminSession) error {
+	return models.InsertAdminSession(config.DB, s)
+}
+
+func (pgAdminSessionStore) GetByTokenHash(tokenHash string) (*models.AdminSession, error) {
+	return models.GetAdminSessionByTokenHash(config.DB, tokenHash)
+}
+
+func (pgAdminSessionStore) Touch(id string, seenAt time.Time) error {
+	return models.TouchAdminSession(config.DB, id, seenAt)
+}
+
+func (pgAdminSessionStore) Delete(id string) (bool, error) {
+	return models.DeleteAdminSession(config.DB, id)
+}
+
+func (pgAdminSessionStore) List(now time.Time) ([]models.AdminSession, error) {
+	return models.ListAdminSessions(config.DB, now)
+}
+
+func (pgAdminSessionStore) DeleteExpired(now, idleCutoff time.Time) (int64, error) {
+	return models.DeleteExpiredAdminSessions(config.DB, now, idleCutoff)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store
+// ---------------------------------------------------------------------------
+
+type memoryAdminSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.AdminSession // id -> session
+}
+
+func NewMemoryAdminSessionStore() AdminSessionStore {
+	return &memoryAdminSessionStore{sessions: make(map[string]models.AdminSession)}
+}
+
+func (m *memoryAdminSessionStore) Create(s models.AdminSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *memoryAdminSessionStore) GetByTokenHash(tokenHash string) (*models.AdminSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		if s.TokenHash == tokenHash {
+			found := s
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryAdminSessionStore) Touch(id string, seenAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		s.LastSeenAt = seenAt
+		m.sessions[id] = s
+	}
+	return nil
+}
+
+func (m *memoryAdminSessionStore) Delete(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessions[id]
+	delete(m.sessions, id)
+	return ok, nil
+}
+
+func (m *memoryAdminSessionStore) List(now time.Time) ([]models.AdminSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.AdminSession
+	for _, s := range m.sessions {
+		if now.Before(s.ExpiresAt) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeenAt.After(out[j].LastSeenAt) })
+	return out, nil
+}
+
+func (m *memoryAdminSessionStore) DeleteExpired(now, idleCutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, s := range m.sessions {
+		if !now.Before(s.ExpiresAt) || !s.LastSeenAt.After(idleCutoff) {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+func seedAdminSession(t *testing.T, token string, lastSeen, expires time.Time) models.AdminSession {
+	t.Helper()
+	sess := models.AdminSession{
+		ID:         "sess-" + token,
+		TokenHash:  hashAdminToken(token),
+		AdminID:    "admin-1",
+		Username:   "root",
+		CreatedAt:  lastSeen,
+		LastSeenAt: lastSeen,
+		ExpiresAt:  expires,
+	}
+	if err := adminSessions.Create(sess); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	return sess
+}
+
+func callWithAdminToken(token string) int {
+	handler := AdminAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if adminSessionFromRequest(r) == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr.Code
+}
+
+func TestAdminAuthMiddleware_SlidingAndAbsoluteExpiry(t *testing.T) {
+	SetAdminSessionStore(NewMemoryAdminSessionStore())
+	now := time.Now()
+
+	seedAdminSession(t, "fresh", now.Add(-5*time.Minute), now.Add(time.Hour))
+	seedAdminSession(t, "idle", now.Add(-adminSessionIdleTimeout()-time.Minute), now.Add(time.Hour))
+	seedAdminSession(t, "capped", now, now.Add(-time.Second))
+
+	if code := callWithAdminToken("fresh"); code != http.StatusNoContent {
+		t.Fatalf("fresh session: expected %d, got %d", http.StatusNoContent, code)
+	}
+	if code := callWithAdminToken("idle"); code != http.StatusUnauthorized {
+		t.Fatalf("idle session: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := callWithAdminToken("capped"); code != http.StatusUnauthorized {
+		t.Fatalf("capped session: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code := callWithAdminToken("unknown"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown token: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	sess, _ := adminSessions.GetByTokenHash(hashAdminToken("fresh"))
+	if sess == nil || time.Since(sess.LastSeenAt) > time.Minute {
+		t.Fatal("expected fresh session last_seen_at to slide forward")
+	}
+	if gone, _ := adminSessions.GetByTokenHash(hashAdminToken("idle")); gone != nil {
+		t.Fatal("expected idle session to be removed")
+	}
+}
+
+func TestAdminRevokeSessionHandler(t *testing.T) {
+	SetAdminSessionStore(NewMemoryAdminSessionStore())
+	now := time.Now()
+	sess := seedAdminSession(t, "victim", now, now.Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/admin/sessions/"+sess.ID, nil)
+	req.SetPathValue("id", sess.ID)
+	rr := httptest.NewRecorder()
+	AdminRevokeSessionHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rr.Code)
+	}
+	if code := callWithAdminToken("victim"); code != http.StatusUnauthorized {
+		t.Fatalf("revoked session: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	rr = httptest.NewRecorder()
+	AdminRevokeSessionHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("second revoke: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestMemoryAdminSessionStore_DeleteExpired(t *testing.T) {
+	store := NewMemoryAdminSessionStore()
+	now := time.Now()
+	_ = store.Create(models.AdminSession{ID: "a", LastSeenAt: now, ExpiresAt: now.Add(time.Hour)})
+	_ = store.Create(models.AdminSession{ID: "b", LastSeenAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)})
+	_ = store.Create(models.AdminSession{ID: "c", LastSeenAt: now, ExpiresAt: now.Add(-time.Minute)})
+
+	n, err := store.DeleteExpired(now, now.Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 removed sessions, got %d", n)
+	}
+	left, _ := store.List(now)
+	if len(left) != 1 || left[0].ID != "a" {
+		t.Fatalf("unexpected remaining sessions: %+v", left)
+	}
+}
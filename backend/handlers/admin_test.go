@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataChanges(t *testing.T) {
+	var before, update map[string]interface{}
+	json.Unmarshal([]byte(`{"name":"Asha","position":"regular","d_uuid":"d-1","tags":["ops"]}`), &before)
+	json.Unmarshal([]byte(`{"name":"Asha","position":"head","tags":["ops"],"phone_number":"0484","address":null}`), &update)
+
+	got := metadataChanges(before, update)
+	want := map[string]interface{}{
+		"position":     map[string]interface{}{"from": "regular", "to": "head"},
+		"phone_number": map[string]interface{}{"from": nil, "to": "0484"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+}
+
+func TestClientIPOnlyTrustsConfiguredProxies(t *testing.T) {
+	proxies := parseTrustedProxies("10.0.0.0/8, 192.0.2.7, not-an-ip")
+	cases := []struct {
+		remote string
+		xff    []string
+		want   string
+	}{
+		// Not from a proxy: the header is the client's own claim.
+		{"203.0.113.5:4000", []string{"1.2.3.4"}, "203.0.113.5"},
+		{"203.0.113.5:4000", nil, "203.0.113.5"},
+		// Through the proxies: the right-most hop they did not add.
+		{"10.1.2.3:4000", []string{"1.2.3.4, 198.51.100.9"}, "198.51.100.9"},
+		{"10.1.2.3:4000", []string{"1.2.3.4, 198.51.100.9", "192.0.2.7, 10.9.9.9"}, "198.51.100.9"},
+		{"192.0.2.7:4000", []string{"forged, 198.51.100.9"}, "198.51.100.9"},
+		{"10.1.2.3:4000", []string{"garbage"}, "10.1.2.3"},
+		{"10.1.2.3:4000", []string{"10.4.4.4"}, "10.4.4.4"},
+		{"10.1.2.3:4000", nil, "10.1.2.3"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		for _, v := range c.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIPVia(r, proxies); got != c.want {
+			t.Errorf("%s %v: got %s, want %s", c.remote, c.xff, got, c.want)
+		}
+	}
+}
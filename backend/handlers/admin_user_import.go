@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+const (
+	auditUserImport = "user.import"
+
+	maxImportRows  = 1000
+	maxImportBytes = 2 << 20
+)
+
+// importColumns are the accepted CSV headers, matched case-insensitively.
+var importColumns = []string{"name", "email", "phone", "department", "role", "position"}
+
+type importRow struct {
+	Line       int
+	Name       string
+	Email      string
+	Phone      string
+	Department string
+	Role       string
+	Position   string
+}
+
+type importRowResult struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email"`
+	Status string   `json:"status"` // valid | invalid | created | failed
+	UUID   string   `json:"uuid,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+	// Invite is whether the password setup email reached Supabase: sent | failed.
+	Invite string `json:"invite,omitempty"`
+
+	user *newUser
+}
+
+// importDirectory is the department/role/user snapshot rows are validated against.
+type importDirectory struct {
+	departments    map[string]models.Department      // lower(d_name) -> department
+	roles          map[string]map[string]models.Role // d_uuid -> lower(r_name) -> role
+	existingEmails map[string]bool                   // lower(email)
+}
+
+func loadImportDirectory() (importDirectory, error) {
+	dir := importDirectory{
+		departments:    map[string]models.Department{},
+		roles:          map[string]map[string]models.Role{},
+		existingEmails: map[string]bool{},
+	}
+	depts, err := models.GetAllDepartments(config.DB)
+	if err != nil {
+		return dir, err
+	}
+	for _, d := range depts {
+		dir.departments[strings.ToLower(strings.TrimSpace(d.DName))] = d
+	}
+	roles, err := models.ListRoles(config.DB, "")
+	if err != nil {
+		return dir, err
+	}
+	for _, r := range roles {
+		if dir.roles[r.DUUID] == nil {
+			dir.roles[r.DUUID] = map[string]models.Role{}
+		}
+		dir.roles[r.DUUID][strings.ToLower(strings.TrimSpace(r.RName))] = r
+	}
+	profiles, err := models.ListUserProfiles(config.DB)
+	if err != nil {
+		return dir, err
+	}
+	for _, p := range profiles {
+		dir.existingEmails[strings.ToLower(strings.TrimSpace(p.Email))] = true
+	}
+	return dir, nil
+}
+
+// parseImportCSV reads the header and maps each record onto importRow.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, col := range []string{"name", "email", "department"} {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("missing required column %q (expected %s)", col, strings.Join(importColumns, ", "))
+		}
+	}
+
+	var rows []importRow
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		field := func(col string) string {
+			i, ok := index[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		row := importRow{
+			Line: line, Name: field("name"), Email: field("email"), Phone: field("phone"),
+			Department: field("department"), Role: field("role"), Position: strings.ToLower(field("position")),
+		}
+		if row == (importRow{Line: line}) {
+			continue // blank line
+		}
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return nil, fmt.Errorf("too many rows: at most %d users per import", maxImportRows)
+		}
+	}
+	return rows, nil
+}
+
+// validateImportRows checks every row and builds the user to create for valid ones.
+func validateImportRows(rows []importRow, dir importDirectory) []importRowResult {
+	results := make([]importRowResult, 0, len(rows))
+	seen := map[string]int{}
+	for _, row := range rows {
+		res := importRowResult{Line: row.Line, Email: row.Email}
+		var errs []string
+
+		if row.Name == "" {
+			errs = append(errs, "name is required")
+		}
+		emailKey := strings.ToLower(row.Email)
+		if row.Email == "" {
+			errs = append(errs, "email is required")
+		} else if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+			errs = append(errs, "email is not a valid address")
+		} else if dir.existingEmails[emailKey] {
+			errs = append(errs, "a user with this email already exists")
+		} else if first, dup := seen[emailKey]; dup {
+			errs = append(errs, fmt.Sprintf("duplicate of line %d", first))
+		}
+		if emailKey != "" {
+			if _, dup := seen[emailKey]; !dup {
+				seen[emailKey] = row.Line
+			}
+		}
+
+		position := row.Position
+		if position == "" {
+			position = "regular"
+		}
+		if position != "regular" && position != "head" {
+			errs = append(errs, "position must be regular or head")
+		}
+
+		dept, ok := dir.departments[strings.ToLower(row.Department)]
+		var roleUUID string
+		switch {
+		case row.Department == "":
+			errs = append(errs, "department is required")
+		case !ok:
+			errs = append(errs, fmt.Sprintf("department %q does not exist", row.Department))
+		case position == "head":
+			// Heads are not tied to a department role.
+		case row.Role != "":
+			role, found := dir.roles[dept.DUUID][strings.ToLower(row.Role)]
+			if !found {
+				errs = append(errs, fmt.Sprintf("role %q does not exist in %s", row.Role, dept.DName))
+			}
+			roleUUID = role.RUUID
+		case len(dir.roles[dept.DUUID]) > 0:
+			errs = append(errs, fmt.Sprintf("role is required for regular users in %s", dept.DName))
+		}
+
+		if len(errs) > 0 {
+			res.Status = "invalid"
+			res.Errors = errs
+		} else {
+			res.Status = "valid"
+			res.user = &newUser{
+				Email: row.Email,
+				Profile: models.UserProfile{
+					Name: row.Name, Email: row.Email, PhoneNumber: row.Phone,
+					Position: position, DUUID: dept.DUUID, RUUID: roleUUID,
+				},
+				Metadata: map[string]interface{}{
+					"name": row.Name, "phone_number": row.Phone, "d_uuid": dept.DUUID,
+					"r_uuid": nullIfBlank(roleUUID), "position": position,
+				},
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func nullIfBlank(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func importConcurrency() int {
+	return envInt("USER_IMPORT_CONCURRENCY", 4)
+}
+
+// sendImportInvite lets the users an import creates set their password.
+var sendImportInvite = sendPasswordSetupEmail
+
+// createImportedUsers runs lifecycle.Create for every valid row, at most
+// `workers` at a time, filling in each result's final status. Imported
+// users get a random password nobody is told, so each is sent a password
+// setup email instead; a failed one is left in the row for the report.
+func createImportedUsers(r *http.Request, actor auditActor, results []importRowResult, workers int) {
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range results {
+		if results[i].Status != "valid" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *importRowResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			password, err := generateToken()
+			if err != nil {
+				res.Status, res.Errors = "failed", []string{"could not generate password"}
+				return
+			}
+			nu := *res.user
+			nu.Password = password[:24]
+			profile, err := lifecycle.Create(nu)
+			if err != nil {
+				res.Status, res.Errors = "failed", []string{err.Error()}
+				return
+			}
+			res.Status, res.UUID = "created", profile.UUID
+			recordAudit(r, actor, auditUserCreate, "user", profile.UUID, map[string]interface{}{
+				"email": profile.Email, "source": "import", "line": res.Line,
+			})
+			if err := sendImportInvite(profile.Email); err != nil {
+				log.Printf("[ADMIN] Import: password setup email to %s failed: %v", profile.Email, err)
+				res.Invite, res.Errors = "failed", []string{"password setup email could not be sent; send a password recovery from the Supabase dashboard"}
+				return
+			}
+			res.Invite = "sent"
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+func countImportRows(results []importRowResult) map[string]int {
+	counts := map[string]int{}
+	for _, res := range results {
+		counts[res.Status]++
+	}
+	return counts
+}
+
+// ---------------------------------------------------------------------------
+// Import records (user_imports)
+// ---------------------------------------------------------------------------
+
+// InitUserImports marks imports a previous process left running as
+// interrupted; the users they had created so far are kept.
+func InitUserImports() {
+	n, err := models.InterruptUserImports(config.DB, time.Now())
+	if err != nil {
+		log.Printf("[ADMIN] Import: failed to mark unfinished imports: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[ADMIN] Import: %d unfinished imports marked interrupted", n)
+	}
+}
+
+func encodeImport(results []importRowResult) (counts, rows json.RawMessage, err error) {
+	if counts, err = json.Marshal(countImportRows(results)); err != nil {
+		return nil, nil, err
+	}
+	if rows, err = json.Marshal(results); err != nil {
+		return nil, nil, err
+	}
+	return counts, rows, nil
+}
+
+// runImport creates the users of a stored import and records the outcome.
+// It runs after the request has been answered.
+func runImport(r *http.Request, actor auditActor, importID string, results []importRowResult) {
+	createImportedUsers(r, actor, results, importConcurrency())
+	counts := countImportRows(results)
+	rawCounts, rows, err := encodeImport(results)
+	if err == nil {
+		err = models.FinishUserImport(config.DB, importID, rawCounts, rows, time.Now())
+	}
+	if err != nil {
+		log.Printf("[ADMIN] Import %s: failed to store outcome: %v", importID, err)
+	}
+	recordAudit(r, actor, auditUserImport, "user_import", importID, counts)
+	log.Printf("[ADMIN] Import %s finished: %v", importID, counts)
+}
+
+func importView(im models.UserImport) map[string]interface{} {
+	return map[string]interface{}{
+		"import_id":   im.ImportID,
+		"dry_run":     im.DryRun,
+		"status":      im.Status,
+		"counts":      im.Counts,
+		"rows":        im.Rows,
+		"created_at":  im.CreatedAt,
+		"finished_at": im.FinishedAt,
+		"status_url":  "/v1/admin/users/import/" + im.ImportID,
+		"report_url":  "/v1/admin/users/import/" + im.ImportID + "/report",
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/admin/users/import[?dry_run=true] — multipart "file" or text/csv body
+// ---------------------------------------------------------------------------
+
+// AdminImportUsersHandler validates the CSV and stores the import. A dry run
+// answers with every row's outcome; otherwise the users are created in the
+// background and the 202 response names the import to poll.
+func AdminImportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `{"error":"missing CSV file in form field \"file\""}`, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		src = file
+	}
+
+	rows, err := parseImportCSV(src)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, `{"error":"CSV contains no users"}`, http.StatusBadRequest)
+		return
+	}
+
+	dir, err := loadImportDirectory()
+	if err != nil {
+		log.Printf("[ADMIN] Import: failed to load departments/roles: %v", err)
+		http.Error(w, `{"error":"failed to load departments and roles"}`, http.StatusInternalServerError)
+		return
+	}
+	results := validateImportRows(rows, dir)
+
+	actor := adminActor(r)
+	counts, rawRows, err := encodeImport(results)
+	if err != nil {
+		http.Error(w, `{"error":"failed to store import"}`, http.StatusInternalServerError)
+		return
+	}
+	im := models.UserImport{CreatedBy: actor.ID, DryRun: dryRun, Status: models.UserImportRunning, Counts: counts, Rows: rawRows}
+	if dryRun {
+		now := time.Now()
+		im.Status, im.FinishedAt = models.UserImportDone, &now
+	}
+	im, err = models.InsertUserImport(config.DB, im)
+	if err != nil {
+		http.Error(w, `{"error":"failed to store import"}`, http.StatusInternalServerError)
+		return
+	}
+	if dryRun {
+		writeJSON(w, http.StatusOK, importView(im))
+		return
+	}
+
+	// The request is done before the users are; keep what auditing needs.
+	go runImport(r.Clone(context.WithoutCancel(r.Context())), actor, im.ImportID, results)
+	writeJSON(w, http.StatusAccepted, importView(im))
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/users/import/{id} — status and per-row outcomes
+// ---------------------------------------------------------------------------
+
+func AdminImportStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	im, ok := loadImport(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, importView(*im))
+}
+
+func loadImport(w http.ResponseWriter, id string) (*models.UserImport, bool) {
+	im, err := models.GetUserImport(config.DB, id)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load import"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	if im == nil {
+		http.Error(w, `{"error":"import not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return im, true
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/users/import/{id}/report — CSV of per-row outcomes
+// ---------------------------------------------------------------------------
+
+func AdminImportReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	im, ok := loadImport(w, id)
+	if !ok {
+		return
+	}
+	if im.Status == models.UserImportRunning {
+		http.Error(w, `{"error":"import is still running"}`, http.StatusConflict)
+		return
+	}
+	var results []importRowResult
+	if err := json.Unmarshal(im.Rows, &results); err != nil {
+		log.Printf("[ADMIN] Import %s: bad stored rows: %v", id, err)
+		http.Error(w, `{"error":"failed to load import"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="user_import_`+id+`.csv"`)
+	w.Write(importReportCSV(results))
+}
+
+func importReportCSV(results []importRowResult) []byte {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write([]string{"line", "email", "status", "uuid", "invite", "errors"})
+	for _, res := range results {
+		_ = cw.Write([]string{strconv.Itoa(res.Line), res.Email, res.Status, res.UUID, res.Invite, strings.Join(res.Errors, "; ")})
+	}
+	cw.Flush()
+	return buf.Bytes()
+}
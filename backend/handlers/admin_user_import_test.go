@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"backend/models"
+)
+
+func testImportDirectory() importDirectory {
+	return importDirectory{
+		departments: map[string]models.Department{
+			"operations": {DUUID: "d-ops", DName: "Operations"},
+			"finance":    {DUUID: "d-fin", DName: "Finance"},
+		},
+		roles: map[string]map[string]models.Role{
+			"d-ops": {"station controller": {RUUID: "r-sc", RName: "Station Controller", DUUID: "d-ops"}},
+		},
+		existingEmails: map[string]bool{"taken@kmrl.in": true},
+	}
+}
+
+func TestParseImportCSV(t *testing.T) {
+	in := "Name,Email,Phone,Department,Role,Position\n" +
+		"Asha,asha@kmrl.in,9876543210,Operations,Station Controller,\n" +
+		",,,,,\n" +
+		"Ravi,ravi@kmrl.in,,Finance,,HEAD\n"
+	rows, err := parseImportCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected blank line to be skipped, got %d rows", len(rows))
+	}
+	if rows[1].Line != 4 || rows[1].Position != "head" || rows[1].Department != "Finance" {
+		t.Fatalf("unexpected row: %+v", rows[1])
+	}
+
+	if _, err := parseImportCSV(strings.NewReader("name,phone\nAsha,1\n")); err == nil {
+		t.Fatal("expected missing column error")
+	}
+}
+
+func TestValidateImportRows(t *testing.T) {
+	rows := []importRow{
+		{Line: 2, Name: "Asha", Email: "asha@kmrl.in", Department: "operations", Role: "Station Controller"},
+		{Line: 3, Name: "Ravi", Email: "ravi@kmrl.in", Department: "Finance", Position: "head", Role: "ignored"},
+		{Line: 4, Name: "Dup", Email: "ASHA@kmrl.in", Department: "Finance"},
+		{Line: 5, Name: "Old", Email: "taken@kmrl.in", Department: "Finance"},
+		{Line: 6, Name: "NoRole", Email: "n@kmrl.in", Department: "Operations"},
+		{Line: 7, Name: "", Email: "bad-email", Department: "Nowhere", Position: "boss"},
+		{Line: 8, Name: "WrongRole", Email: "w@kmrl.in", Department: "Operations", Role: "Accountant"},
+	}
+	results := validateImportRows(rows, testImportDirectory())
+
+	status := func(i int) string { return results[i].Status }
+	if status(0) != "valid" || results[0].user.Profile.RUUID != "r-sc" || results[0].user.Profile.Position != "regular" {
+		t.Fatalf("row 2: %+v", results[0])
+	}
+	if status(1) != "valid" || results[1].user.Profile.RUUID != "" {
+		t.Fatalf("row 3: head should be valid without role, got %+v", results[1])
+	}
+	for i := 2; i < len(results); i++ {
+		if status(i) != "invalid" || results[i].user != nil {
+			t.Fatalf("line %d: expected invalid, got %+v", results[i].Line, results[i])
+		}
+	}
+	if !strings.Contains(results[2].Errors[0], "duplicate of line 2") {
+		t.Fatalf("unexpected duplicate error: %v", results[2].Errors)
+	}
+	if len(results[5].Errors) != 4 {
+		t.Fatalf("expected every problem on line 7 to be reported, got %v", results[5].Errors)
+	}
+}
+
+func TestImportReportFromStoredRows(t *testing.T) {
+	rows := []importRow{
+		{Line: 2, Name: "Asha", Email: "asha@kmrl.in", Department: "Finance"},
+		{Line: 3, Name: "", Email: "taken@kmrl.in", Department: "Nowhere"},
+	}
+	results := validateImportRows(rows, testImportDirectory())
+	counts, raw, err := encodeImport(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(counts) != `{"invalid":1,"valid":1}` {
+		t.Fatalf("counts = %s", counts)
+	}
+
+	// The report is built from the rows as the import stored them.
+	var stored []importRowResult
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		t.Fatal(err)
+	}
+	report := string(importReportCSV(stored))
+	want := "line,email,status,uuid,invite,errors\n" +
+		"2,asha@kmrl.in,valid,,,\n" +
+		`3,taken@kmrl.in,invalid,,,"name is required; a user with this email already exists; department ""Nowhere"" does not exist"` + "\n"
+	if report != want {
+		t.Fatalf("report =\n%s\nwant\n%s", report, want)
+	}
+}
+
+func TestImportedUsersAreSentPasswordSetup(t *testing.T) {
+	saved, savedInvite := lifecycle, sendImportInvite
+	t.Cleanup(func() { lifecycle, sendImportInvite = saved, savedInvite })
+	lifecycle = &userLifecycle{auth: newFakeAuthAdmin(), profiles: newFakeProfileStore()}
+
+	var invited []string
+	sendImportInvite = func(email string) error {
+		invited = append(invited, email)
+		return nil
+	}
+	rows := []importRow{{Line: 2, Name: "Ravi", Email: "ravi@kmrl.in", Department: "Finance"}}
+	results := validateImportRows(rows, testImportDirectory())
+	createImportedUsers(nil, systemActor, results, 1)
+	if results[0].Status != "created" || results[0].Invite != "sent" || len(invited) != 1 || invited[0] != "ravi@kmrl.in" {
+		t.Fatalf("result = %+v, invited %v", results[0], invited)
+	}
+
+	// A user whose email did not go out is still created, and the report says so.
+	lifecycle = &userLifecycle{auth: newFakeAuthAdmin(), profiles: newFakeProfileStore()}
+	sendImportInvite = func(string) error { return errors.New("rate limited") }
+	results = validateImportRows(rows, testImportDirectory())
+	createImportedUsers(nil, systemActor, results, 1)
+	if results[0].Status != "created" || results[0].Invite != "failed" || len(results[0].Errors) != 1 {
+		t.Fatalf("result = %+v", results[0])
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+// Audit actions. Keep these stable: they are filtered on by compliance queries.
+const (
+	auditDocumentUpload   = "document.upload"
+	auditDocumentView     = "document.view"
+	auditDocumentProcess  = "document.process"
+	auditSummaryRequest   = "summary.request"
+	auditAdminLogin       = "admin.login"
+	auditAdminLoginFailed = "admin.login_failed"
+	auditAdminLogout      = "admin.logout"
+	auditSessionRevoke    = "admin.session.revoke"
+	auditUserCreate       = "user.create"
+	auditUserUpdate       = "user.update"
+	auditUserDelete       = "user.delete"
+	auditLogExport        = "audit.export"
+)
+
+type auditActor struct {
+	Type string
+	ID   string
+	Name string
+}
+
+var systemActor = auditActor{Type: "system", ID: "system"}
+
+// adminActor identifies the admin behind an AdminAuthMiddleware-protected request.
+func adminActor(r *http.Request) auditActor {
+	if sess := adminSessionFromRequest(r); sess != nil {
+		return auditActor{Type: "admin", ID: sess.AdminID, Name: sess.Username}
+	}
+	return auditActor{Type: "admin", ID: "unknown"}
+}
+
+// userActor identifies an authenticated app user; anonymous callers are recorded as such.
+func userActor(userID string) auditActor {
+	if userID == "" {
+		return auditActor{Type: "user", ID: "anonymous"}
+	}
+	return auditActor{Type: "user", ID: userID}
+}
+
+// recordAudit appends an audit row. Failures are logged rather than returned so
+// that a broken audit table surfaces in logs without taking the API down.
+func recordAudit(r *http.Request, actor auditActor, action, targetType, targetID string, diff interface{}) {
+	if config.DB == nil {
+		return
+	}
+	entry := models.AuditEntry{
+		OccurredAt: time.Now(),
+		ActorType:  actor.Type,
+		ActorID:    actor.ID,
+		ActorName:  actor.Name,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+	if r != nil {
+		entry.IP = clientIP(r)
+		entry.UserAgent = r.UserAgent()
+	}
+	if diff != nil {
+		raw, err := json.Marshal(diff)
+		if err != nil {
+			log.Printf("[AUDIT] Failed to encode diff for %s %s/%s: %v", action, targetType, targetID, err)
+		} else {
+			entry.Diff = raw
+		}
+	}
+	if _, err := models.InsertAuditEntry(config.DB, entry); err != nil {
+		log.Printf("[AUDIT] Failed to record %s %s/%s: %v", action, targetType, targetID, err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/audit — filterable, paginated audit log (format=csv to export)
+// ---------------------------------------------------------------------------
+
+type auditPage struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	NextBefore int64               `json:"next_before,omitempty"`
+}
+
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	f := models.AuditFilter{
+		ActorID:    q.Get("actor_id"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+	}
+	for key, dst := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if raw := q.Get(key); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return f, err
+			}
+			*dst = &t
+		}
+	}
+	if raw := q.Get("before"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return f, err
+		}
+		f.BeforeID = v
+	}
+	if raw := q.Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return f, err
+		}
+		f.Limit = v
+	}
+	return f, nil
+}
+
+func AdminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, `{"error":"invalid filter: use RFC3339 for from/to and integers for before/limit"}`, http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		recordAudit(r, adminActor(r), auditLogExport, "audit_log", "csv", r.URL.Query())
+		writeAuditCSV(w, filter)
+		return
+	}
+
+	entries, err := models.QueryAuditEntries(config.DB, filter)
+	if err != nil {
+		http.Error(w, `{"error":"failed to query audit log"}`, http.StatusInternalServerError)
+		return
+	}
+
+	page := auditPage{Entries: entries}
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if len(entries) == limit {
+		page.NextBefore = entries[len(entries)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// writeAuditCSV streams every row matching filter, paging internally.
+func writeAuditCSV(w http.ResponseWriter, filter models.AuditFilter) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit_log_`+time.Now().UTC().Format("20060102T150405Z")+`.csv"`)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "occurred_at", "actor_type", "actor_id", "actor_name", "action",
+		"target_type", "target_id", "ip", "user_agent", "diff", "prev_hash", "hash"})
+
+	filter.Limit = 1000
+	for {
+		entries, err := models.QueryAuditEntries(config.DB, filter)
+		if err != nil {
+			log.Printf("[AUDIT] CSV export aborted: %v", err)
+			break
+		}
+		for _, e := range entries {
+			_ = cw.Write([]string{
+				strconv.FormatInt(e.ID, 10), e.OccurredAt.UTC().Format(time.RFC3339Nano), e.ActorType, e.ActorID,
+				e.ActorName, e.Action, e.TargetType, e.TargetID, e.IP, e.UserAgent, string(e.Diff), e.PrevHash, e.Hash,
+			})
+		}
+		cw.Flush()
+		if len(entries) < filter.Limit {
+			break
+		}
+		filter.BeforeID = entries[len(entries)-1].ID
+	}
+	cw.Flush()
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/audit/verify — re-hash the chain and report the first break
+// ---------------------------------------------------------------------------
+
+func AdminAuditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checked, brokenAt, err := models.VerifyAuditChain(config.DB)
+	if err != nil {
+		log.Printf("[AUDIT] Chain verification failed: %v", err)
+		http.Error(w, `{"error":"failed to verify audit log"}`, http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"ok":      brokenAt == 0,
+		"checked": checked,
+	}
+	if brokenAt != 0 {
+		resp["broken_at_id"] = brokenAt
+		log.Printf("[AUDIT] Hash chain broken at id=%d", brokenAt)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/classification"
+	"backend/config"
+	"backend/models"
+	"backend/routing"
+)
+
+const (
+	auditCategoryCreate       = "category.create"
+	auditCategoryUpdate       = "category.update"
+	auditCategoryDelete       = "category.delete"
+	auditDocumentClassified   = "document.classified"
+	auditDocumentReclassified = "document.reclassified"
+)
+
+var classifier *classification.Service
+
+// SetClassificationService swaps the classification backend (tests use a
+// memory store).
+func SetClassificationService(s *classification.Service) {
+	classifier = s
+}
+
+// InitClassificationService backs the taxonomy with the database and asks
+// the LLM completion server first.
+func InitClassificationService() {
+	SetClassificationService(classification.NewService(classification.NewPostgresStore(config.DB), classification.NewLLMModel(llmEndpoint())))
+}
+
+func writeClassificationError(w http.ResponseWriter, err error) {
+	var invalid classification.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, classification.ErrNotFound):
+		http.Error(w, `{"error":"category not found"}`, http.StatusNotFound)
+	default:
+		log.Printf("[CLASSIFY] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// categoryReq is the body of create and update. Enabled defaults to true.
+type categoryReq struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Keywords    []string `json:"keywords"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+func (req categoryReq) category() classification.Category {
+	c := classification.Category{Name: req.Name, Description: req.Description, Keywords: req.Keywords, Enabled: true}
+	if req.Enabled != nil {
+		c.Enabled = *req.Enabled
+	}
+	return c
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/categories — GET list, POST create
+// ---------------------------------------------------------------------------
+
+func AdminCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cats, err := classifier.Categories()
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cats)
+
+	case http.MethodPost:
+		var req categoryReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		cat, err := classifier.Create(req.category())
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditCategoryCreate, "category", cat.ID, cat)
+		writeJSON(w, http.StatusCreated, cat)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/categories/{id} — PUT replace, DELETE
+// ---------------------------------------------------------------------------
+
+// AdminCategoryHandler edits one category. Deleting a category leaves its
+// files uncategorized until they are re-classified.
+func AdminCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPut:
+		var req categoryReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		before, after, err := classifier.Update(id, req.category())
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditCategoryUpdate, "category", id, map[string]interface{}{
+			"from": before, "to": after,
+		})
+		writeJSON(w, http.StatusOK, after)
+
+	case http.MethodDelete:
+		if err := classifier.Delete(id); err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditCategoryDelete, "category", id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/files/{id}/classification — GET stored result, POST re-classify
+// ---------------------------------------------------------------------------
+
+// AdminFileClassificationHandler shows a file's classification or, on POST,
+// re-classifies it from its latest OCR text. Routing rules are not re-run.
+func AdminFileClassificationHandler(w http.ResponseWriter, r *http.Request) {
+	fuuid := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		res, err := classifier.Result(fuuid)
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		if res == nil {
+			http.Error(w, `{"error":"file has not been classified"}`, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+
+	case http.MethodPost:
+		if _, err := models.GetFileByUUID(config.DB, fuuid); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, `{"error":"failed to fetch file"}`, http.StatusInternalServerError)
+			return
+		}
+		text, err := models.GetLatestOCRText(config.DB, fuuid)
+		if err != nil {
+			http.Error(w, `{"error":"failed to fetch OCR text"}`, http.StatusInternalServerError)
+			return
+		}
+		before, err := classifier.Result(fuuid)
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		// Stay inside the server's 30s write timeout.
+		ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+		defer cancel()
+		res, err := classifier.Classify(ctx, fuuid, text)
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditDocumentReclassified, "file", fuuid, map[string]interface{}{
+			"from": before, "to": res,
+		})
+		writeJSON(w, http.StatusOK, res)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// classifyUploadedDocument classifies a freshly OCR'd upload and returns
+// the result for routing. Failures are logged and yield nil; the upload
+// itself has already succeeded.
+func classifyUploadedDocument(fuuid, text string) *classification.Result {
+	if classifier == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res, err := classifier.Classify(ctx, fuuid, text)
+	if err != nil {
+		log.Printf("[CLASSIFY] Failed to classify %s: %v", fuuid, err)
+		return nil
+	}
+	log.Printf("[CLASSIFY] %s classified as %q by %s (confidence %.2f)", fuuid, res.Category, res.Method, res.Confidence)
+	recordAudit(nil, systemActor, auditDocumentClassified, "file", fuuid, res)
+	return &res
+}
+
+// classifyIfMissing classifies a file that has no classification yet, for
+// files whose text only becomes available later (the summary worker).
+func classifyIfMissing(fuuid, text string) {
+	if classifier == nil {
+		return
+	}
+	existing, err := classifier.Result(fuuid)
+	if err != nil {
+		log.Printf("[CLASSIFY] Failed to check %s: %v", fuuid, err)
+		return
+	}
+	if existing == nil {
+		classifyUploadedDocument(fuuid, text)
+	}
+}
+
+// withCategory lets routing decide llm rules that name a taxonomy category
+// from the stored classification rather than a second LLM call.
+func withCategory(doc routing.Document, res *classification.Result) routing.Document {
+	if classifier == nil || res == nil {
+		return doc
+	}
+	taxonomy, err := classifier.Enabled()
+	if err != nil {
+		log.Printf("[CLASSIFY] Failed to load categories: %v", err)
+		return doc
+	}
+	doc.Taxonomy = taxonomy
+	doc.Category = &routing.Classification{Label: res.Category, Confidence: res.Confidence}
+	return doc
+}
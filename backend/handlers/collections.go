@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"backend/collections"
+	"backend/config"
+)
+
+var collectionSvc *collections.Service
+
+// SetCollectionService swaps the favorites/collections backend (tests use a
+// memory store).
+func SetCollectionService(s *collections.Service) {
+	collectionSvc = s
+}
+
+// InitCollectionService backs favorites and collections with the database.
+func InitCollectionService() {
+	SetCollectionService(collections.NewService(collections.NewPostgresStore(config.DB)))
+}
+
+func writeCollectionError(w http.ResponseWriter, err error) {
+	var invalid collections.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, collections.ErrNotFound):
+		http.Error(w, `{"error":"collection not found"}`, http.StatusNotFound)
+	case errors.Is(err, collections.ErrFileNotFound):
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+	case errors.Is(err, collections.ErrForbidden):
+		http.Error(w, `{"error":"only the owner can change this collection"}`, http.StatusForbidden)
+	case errors.Is(err, collections.ErrNoAccess):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	default:
+		log.Printf("[COLLECTIONS] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/me/favorites
+// PUT/DELETE /v1/me/favorites/{fileId}
+// ---------------------------------------------------------------------------
+
+func FavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	favs, err := collectionSvc.Favorites(userID)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"favorites": favs})
+}
+
+// FavoriteHandler stars (PUT) or unstars (DELETE) a file. Both are
+// idempotent.
+func FavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "PUT, DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	fuuid := r.PathValue("fileId")
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		err = collectionSvc.AddFavorite(userID, fuuid)
+	case http.MethodDelete:
+		err = collectionSvc.RemoveFavorite(userID, fuuid)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"f_uuid": fuuid, "is_favorite": r.Method == http.MethodPut})
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/me/collections
+// ---------------------------------------------------------------------------
+
+// CollectionsHandler lists the caller's collections and those shared with
+// their department, or creates a collection.
+func CollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		owned, shared, err := collectionSvc.Collections(userID)
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"collections": owned, "shared": shared})
+
+	case http.MethodPost:
+		var d collections.Draft
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		c, err := collectionSvc.Create(userID, d)
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, c)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET/PATCH/DELETE /v1/me/collections/{id}
+// ---------------------------------------------------------------------------
+
+func CollectionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, PATCH, DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeCollection(w, userID, id)
+
+	case http.MethodPatch:
+		var p collections.Patch
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		c, err := collectionSvc.Update(userID, id, p)
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+
+	case http.MethodDelete:
+		if err := collectionSvc.Delete(userID, id); err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST/PUT /v1/me/collections/{id}/items
+// DELETE /v1/me/collections/{id}/items/{fileId}
+// ---------------------------------------------------------------------------
+
+// CollectionItemsHandler appends a file (POST {"f_uuid"}) or sets the order
+// of all files (PUT {"f_uuids": [...]}). Both return the collection.
+func CollectionItemsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "POST, PUT, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	var req struct {
+		FUUID  string   `json:"f_uuid"`
+		FUUIDs []string `json:"f_uuids"`
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	var err error
+	if r.Method == http.MethodPost {
+		err = collectionSvc.AddItem(userID, id, req.FUUID)
+	} else {
+		err = collectionSvc.Reorder(userID, id, req.FUUIDs)
+	}
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeCollection(w, userID, id)
+}
+
+func CollectionItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	if err := collectionSvc.RemoveItem(userID, id, r.PathValue("fileId")); err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	writeCollection(w, userID, id)
+}
+
+func writeCollection(w http.ResponseWriter, userID, id string) {
+	c, err := collectionSvc.Get(userID, id)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+	if c.Items == nil {
+		c.Items = []collections.Item{}
+	}
+	writeJSON(w, http.StatusOK, c)
+}
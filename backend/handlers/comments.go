@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"backend/access"
+	"backend/comments"
+	"backend/config"
+	"backend/notifications"
+)
+
+const auditCommentDelete = "comment.delete"
+
+var commentSvc *comments.Service
+
+// SetCommentService swaps the comments backend (tests use a memory store).
+func SetCommentService(s *comments.Service) {
+	commentSvc = s
+}
+
+// InitCommentService backs file comments with the database and sends
+// mentions through the notification outbox.
+func InitCommentService() {
+	s := comments.NewService(comments.NewPostgresStore(config.DB))
+	s.Notify = enqueueCommentMention
+	SetCommentService(s)
+}
+
+// enqueueCommentMention queues one comment_mention per mentioned user, in
+// the background.
+func enqueueCommentMention(e comments.Event) {
+	author := e.Author
+	if author == "" {
+		author = "A colleague"
+	}
+	p := notifications.CommentMentionPayload{
+		CommentID: e.Comment.ID, FUUID: e.File.FUUID, FileName: e.File.Name, Author: author,
+		Comment: e.Comment.Body, Sensitivity: e.File.Sensitivity,
+	}
+	if e.Comment.Anchor != nil {
+		p.Page = e.Comment.Anchor.Page
+	}
+	go func() {
+		for _, uid := range e.Recipients {
+			key := fmt.Sprintf("%s:%s:%s", notifications.KindCommentMention, e.Comment.ID, uid)
+			if _, err := notifications.EnqueueEvent(config.DB, notifications.KindCommentMention, key, uid, p); err != nil {
+				log.Printf("[COMMENTS] Failed to queue mention notification for %s: %v", uid, err)
+			}
+		}
+	}()
+}
+
+func writeCommentError(w http.ResponseWriter, err error) {
+	var invalid comments.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, comments.ErrNotFound):
+		http.Error(w, `{"error":"comment not found"}`, http.StatusNotFound)
+	case errors.Is(err, comments.ErrDeleted):
+		http.Error(w, `{"error":"comment was deleted"}`, http.StatusConflict)
+	case errors.Is(err, comments.ErrForbidden), errors.Is(err, comments.ErrNoAccess):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	default:
+		log.Printf("[COMMENTS] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// commentFile runs the same access check as the file's metadata and
+// returns the caller and the file for the comments service.
+func commentFile(w http.ResponseWriter, r *http.Request, methods string) (access.Viewer, comments.File, bool) {
+	if ApplyCORS(w, r, methods) {
+		return access.Viewer{}, comments.File{}, false
+	}
+	userID, file, ok := authorizeFile(w, r, r.PathValue("id"), "comments")
+	if !ok {
+		return access.Viewer{}, comments.File{}, false
+	}
+	viewer, err := fileViewer(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return access.Viewer{}, comments.File{}, false
+	}
+	return viewer, comments.File{File: accessFile(file), Name: file.FileName}, true
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/files/{id}/comments
+// ---------------------------------------------------------------------------
+
+// FileCommentsHandler lists a file's comment threads or posts a comment or
+// reply. Anyone who may see the file may read and join the discussion.
+func FileCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, file, ok := commentFile(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		threads, err := commentSvc.List(viewer, file)
+		if err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"comments": threads})
+
+	case http.MethodPost:
+		var d comments.Draft
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		c, err := commentSvc.Create(viewer, file, d)
+		if err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, c)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PATCH/DELETE /v1/files/{id}/comments/{commentId}
+// ---------------------------------------------------------------------------
+
+// FileCommentHandler edits the caller's comment or deletes it. The file's
+// uploader and department heads may delete anyone's comment; those
+// deletions are audited.
+func FileCommentHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, file, ok := commentFile(w, r, "PATCH, DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("commentId")
+
+	switch r.Method {
+	case http.MethodPatch:
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		c, err := commentSvc.Edit(viewer, file, id, req.Body)
+		if err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+
+	case http.MethodDelete:
+		moderated, err := commentSvc.Delete(viewer, file, id)
+		if err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		recordAudit(r, userActor(viewer.UUID), auditCommentDelete, "file", file.FUUID,
+			map[string]interface{}{"comment_id": id, "moderated": moderated})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/files/{id}/comments/{commentId}/history
+// ---------------------------------------------------------------------------
+
+// FileCommentHistoryHandler returns a comment's earlier versions to its
+// author and the file's moderators.
+func FileCommentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, file, ok := commentFile(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revs, err := commentSvc.History(viewer, file, r.PathValue("commentId"))
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": revs})
+}
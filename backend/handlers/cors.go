@@ -22,7 +22,7 @@ func ApplyCORS(w http.ResponseWriter, r *http.Request, methods string) bool {
 	}
 	w.Header().Set("Vary", "Origin")
 	w.Header().Set("Access-Control-Allow-Methods", methods)
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
 		return true
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+const maxDeadlineDays = 365
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/deadlines
+// ---------------------------------------------------------------------------
+
+// DeadlinesHandler lists upcoming deadlines for the caller's department
+// (?days=, default 30) and lets department heads add new ones. Deadlines
+// appear in digests and trigger a reminder a day before they are due.
+func DeadlinesHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "GET, POST, OPTIONS") {
+		return
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	profile, err := models.GetUserProfile(config.DB, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return
+	}
+	if profile == nil || !profile.IsActive {
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		days := 30
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxDeadlineDays {
+				http.Error(w, `{"error":"days must be between 1 and 365"}`, http.StatusBadRequest)
+				return
+			}
+			days = n
+		}
+		now := time.Now()
+		list, err := models.ListUpcomingDeadlines(config.DB, profile.DUUID, now, now.AddDate(0, 0, days))
+		if err != nil {
+			http.Error(w, `{"error":"failed to load deadlines"}`, http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []models.Deadline{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deadlines": list})
+
+	case http.MethodPost:
+		if profile.Position != "head" || profile.DUUID == "" {
+			http.Error(w, `{"error":"only department heads can add deadlines"}`, http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Title string    `json:"title"`
+			DueAt time.Time `json:"due_at"`
+			FUUID string    `json:"f_uuid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body (due_at must be RFC 3339)"}`, http.StatusBadRequest)
+			return
+		}
+		req.Title = strings.TrimSpace(req.Title)
+		if req.Title == "" || req.DueAt.IsZero() {
+			http.Error(w, `{"error":"title and due_at are required"}`, http.StatusBadRequest)
+			return
+		}
+		if !req.DueAt.After(time.Now()) {
+			http.Error(w, `{"error":"due_at must be in the future"}`, http.StatusBadRequest)
+			return
+		}
+		d, err := models.CreateDeadline(config.DB, models.Deadline{
+			Title: req.Title, DueAt: req.DueAt, FUUID: strings.TrimSpace(req.FUUID), DUUID: profile.DUUID, CreatedBy: userID,
+		})
+		if err != nil {
+			http.Error(w, `{"error":"failed to create deadline"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, d)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -155,6 +155,10 @@ func ProcessFirst10PagesHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid PDF file format", http.StatusBadRequest)
 		return
 	}
+	recordAudit(r, userActor(userID), auditDocumentProcess, "upload", fileHeader.Filename, map[string]interface{}{
+		"size":  fileHeader.Size,
+		"async": asyncMode,
+	})
 
 	if asyncMode {
 		jobID, err := newJobID()
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/access"
+	"backend/config"
+	"backend/models"
+)
+
+const auditFileDownload = "file.download"
+
+const storageBucket = "file_storage"
+
+// Signed download URLs are short-lived; DOWNLOAD_URL_TTL_SECONDS can lower
+// or raise the default up to maxDownloadURLTTL.
+const (
+	defaultDownloadURLTTL = 60
+	maxDownloadURLTTL     = 15 * 60
+)
+
+func downloadURLTTL() int {
+	if raw := os.Getenv("DOWNLOAD_URL_TTL_SECONDS"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return min(n, maxDownloadURLTTL)
+		}
+	}
+	return defaultDownloadURLTTL
+}
+
+// Headers copied from the storage response when streaming.
+var streamedHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified",
+}
+
+// streamWriteIdle is how long a streamed response may go without the client
+// taking more data. The server's WriteTimeout covers the whole response,
+// which large files cannot finish in, so streams push the deadline forward
+// after every chunk instead.
+const streamWriteIdle = 30 * time.Second
+
+// streamBody copies body to w, extending the write deadline per chunk.
+func streamBody(w http.ResponseWriter, body io.Reader) error {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if err := rc.SetWriteDeadline(time.Now().Add(streamWriteIdle)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func copyStoredHeaders(h http.Header, resp *http.Response) {
+	for _, name := range streamedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			h.Set(name, v)
+		}
+	}
+}
+
+// contentDisposition builds an inline or attachment Content-Disposition for
+// name. Non-ASCII names are encoded per RFC 2231.
+func contentDisposition(name string, inline bool) string {
+	kind := "attachment"
+	if inline {
+		kind = "inline"
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." || name == "/" {
+		return kind
+	}
+	if v := mime.FormatMediaType(kind, map[string]string{"filename": name}); v != "" {
+		return v
+	}
+	return kind
+}
+
+// watermarkText is sent with sensitive files so viewers can stamp every
+// page with who opened the file and when. Header values must be printable
+// ASCII, so anything else in who is dropped.
+func watermarkText(level, who, fuuid string, at time.Time) string {
+	who = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, who)
+	return fmt.Sprintf("%s | %s | %s | %s", strings.ToUpper(level), who, at.UTC().Format(time.RFC3339), fuuid)
+}
+
+// watermarkFor names the caller for the watermark, preferring their email.
+func watermarkFor(userID string) string {
+	if p, err := models.GetUserProfile(config.DB, userID); err == nil && p != nil && p.Email != "" {
+		return p.Email
+	}
+	return userID
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/files/{id}/download
+// ---------------------------------------------------------------------------
+
+// FileDownloadHandler serves a file's stored object to users whose
+// department the file is shared with (and to its uploader). By default the
+// object is streamed with Range support; ?mode=redirect sends the caller to
+// a short-lived signed URL instead. ?inline=true asks for inline display.
+//
+// Confidential and restricted files are always streamed inline, never
+// cached, and carry an X-Watermark header naming the viewer. Every
+// download is written to the audit log.
+func FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "GET, OPTIONS") {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fuuid := r.PathValue("id")
+	q := r.URL.Query()
+	mode := q.Get("mode")
+	if mode != "" && mode != "stream" && mode != "redirect" {
+		http.Error(w, `{"error":"mode must be stream or redirect"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, file, ok := authorizeFileWith(w, r, fuuid, "download", access.CheckDownload)
+	if !ok {
+		return
+	}
+	if file.FilePath == "" {
+		http.Error(w, `{"error":"file has no stored content"}`, http.StatusNotFound)
+		return
+	}
+	if file.ArchivedAt != nil {
+		http.Error(w, `{"error":"file has been archived"}`, http.StatusGone)
+		return
+	}
+	inlineOnly := access.InlineOnly(file.Sensitivity)
+	inline := inlineOnly || q.Get("inline") == "true" || q.Get("inline") == "1"
+
+	if mode == "redirect" {
+		if inlineOnly {
+			http.Error(w, `{"error":"this file can only be viewed inline"}`, http.StatusForbidden)
+			return
+		}
+		ttl := downloadURLTTL()
+		signed, err := config.Supabase.SignedURL(storageBucket, file.FilePath, ttl)
+		if err != nil {
+			log.Printf("[DOWNLOAD] signing %s failed: %v", fuuid, err)
+			http.Error(w, `{"error":"failed to create download link"}`, http.StatusBadGateway)
+			return
+		}
+		recordAudit(r, userActor(userID), auditFileDownload, "file", fuuid, map[string]interface{}{
+			"mode":        "redirect",
+			"expires_in":  ttl,
+			"sensitivity": file.Sensitivity,
+		})
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, signed, http.StatusFound)
+		return
+	}
+
+	resp, err := config.Supabase.OpenFile(storageBucket, file.FilePath, r.Header)
+	if err != nil {
+		log.Printf("[DOWNLOAD] fetching %s failed: %v", fuuid, err)
+		http.Error(w, `{"error":"failed to fetch file from storage"}`, http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	h := w.Header()
+	copyStoredHeaders(h, resp)
+	if h.Get("Content-Type") == "" {
+		if ct := mime.TypeByExtension(filepath.Ext(file.FileName)); ct != "" {
+			h.Set("Content-Type", ct)
+		} else {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+	}
+	if h.Get("Accept-Ranges") == "" {
+		h.Set("Accept-Ranges", "bytes")
+	}
+	h.Set("Content-Disposition", contentDisposition(file.FileName, inline))
+	h.Set("X-Content-Type-Options", "nosniff")
+	exposed := "Content-Disposition, Content-Length, Content-Range, Accept-Ranges"
+	if inlineOnly {
+		h.Set("Cache-Control", "private, no-store")
+		h.Set("X-Watermark", watermarkText(file.Sensitivity, watermarkFor(userID), fuuid, time.Now()))
+		exposed += ", X-Watermark"
+	} else {
+		h.Set("Cache-Control", "private, max-age=0, must-revalidate")
+	}
+	h.Set("Access-Control-Expose-Headers", exposed)
+
+	// Range requests from a viewer paging through a document are logged
+	// too; the range says which part was read.
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+		recordAudit(r, userActor(userID), auditFileDownload, "file", fuuid, map[string]interface{}{
+			"mode":        "stream",
+			"range":       r.Header.Get("Range"),
+			"status":      resp.StatusCode,
+			"inline":      inline,
+			"sensitivity": file.Sensitivity,
+		})
+	}
+	w.WriteHeader(resp.StatusCode)
+	if err := streamBody(w, resp.Body); err != nil {
+		log.Printf("[DOWNLOAD] streaming %s interrupted: %v", fuuid, err)
+	}
+}
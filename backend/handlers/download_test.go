@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	cases := []struct {
+		name   string
+		inline bool
+		want   string
+	}{
+		{"report.pdf", false, `attachment; filename=report.pdf`},
+		{"Safety Report.pdf", true, `inline; filename="Safety Report.pdf"`},
+		{"../../etc/passwd", false, `attachment; filename=passwd`},
+		{"", true, "inline"},
+		{"सूचना.pdf", false, "attachment; filename*=utf-8''%E0%A4%B8%E0%A5%82%E0%A4%9A%E0%A4%A8%E0%A4%BE.pdf"},
+	}
+	for _, c := range cases {
+		if got := contentDisposition(c.name, c.inline); got != c.want {
+			t.Errorf("contentDisposition(%q, %v) = %q, want %q", c.name, c.inline, got, c.want)
+		}
+	}
+}
+
+func TestWatermarkText(t *testing.T) {
+	at := time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	got := watermarkText("confidential", "asha@kmrl.in\r\nX-Evil: 1", "f1", at)
+	want := "CONFIDENTIAL | asha@kmrl.inX-Evil: 1 | 2026-10-18T04:00:00Z | f1"
+	if got != want {
+		t.Fatalf("watermarkText = %q, want %q", got, want)
+	}
+	if strings.ContainsAny(got, "\r\n") {
+		t.Fatal("watermark must not contain line breaks")
+	}
+}
+
+// slowReader returns one chunk per read, pausing before each.
+type slowReader struct {
+	chunks int
+	pause  time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.chunks == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.pause)
+	r.chunks--
+	return copy(p, "chunk\n"), nil
+}
+
+func TestStreamBodyOutlivesWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if err := streamBody(w, &slowReader{chunks: 6, pause: 100 * time.Millisecond}); err != nil {
+			t.Errorf("stream: %v", err)
+		}
+	}))
+	srv.Config.WriteTimeout = 250 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("stream was cut after %d bytes: %v", len(body), err)
+	}
+	if want := strings.Repeat("chunk\n", 6); string(body) != want {
+		t.Fatalf("body = %q", body)
+	}
+}
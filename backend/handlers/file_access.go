@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"backend/access"
+	"backend/config"
+	"backend/models"
+)
+
+const (
+	auditConfidentialAccess = "file.confidential_access"
+	auditFileAccessDenied   = "file.access_denied"
+	auditFileSensitivity    = "file.sensitivity"
+)
+
+// fileViewer loads the access-relevant profile of userID. Unknown users get
+// an empty viewer, which access.Check refuses.
+func fileViewer(userID string) (access.Viewer, error) {
+	p, err := models.GetUserProfile(config.DB, userID)
+	if err != nil || p == nil {
+		return access.Viewer{}, err
+	}
+	return access.Viewer{UUID: p.UUID, DUUID: p.DUUID, Position: p.Position, Active: p.IsActive}, nil
+}
+
+func accessFile(f *models.FileAccess) access.File {
+	return access.File{
+		FUUID: f.FUUID, OwnerUUID: f.OwnerUUID, Sensitivity: f.Sensitivity,
+		Departments: f.Departments, WorkspaceDepartments: f.WorkspaceDepartments,
+		WorkflowDepartments: f.WorkflowDepartments,
+	}
+}
+
+// authorizeFile checks that the caller may see file fuuid at its
+// sensitivity level, writing the error response when they may not. what
+// names the part of the file being read ("metadata", "summary", ...) for
+// the audit log. Every access to a confidential or restricted file is
+// audited, allowed or not.
+func authorizeFile(w http.ResponseWriter, r *http.Request, fuuid, what string) (string, *models.FileAccess, bool) {
+	return authorizeFileWith(w, r, fuuid, what, access.Check)
+}
+
+// authorizeFileWith is authorizeFile with a different access rule, such as
+// access.CheckDownload.
+func authorizeFileWith(w http.ResponseWriter, r *http.Request, fuuid, what string, check func(access.Viewer, access.File) access.Decision) (string, *models.FileAccess, bool) {
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return "", nil, false
+	}
+	file, err := models.GetFileAccess(config.DB, fuuid)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch file"}`, http.StatusInternalServerError)
+		return "", nil, false
+	}
+	if file == nil {
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+		return "", nil, false
+	}
+	viewer, err := fileViewer(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	decision := check(viewer, accessFile(file))
+	if access.Sensitive(file.Sensitivity) || !decision.Allowed {
+		action := auditConfidentialAccess
+		if !decision.Allowed {
+			action = auditFileAccessDenied
+		}
+		recordAudit(r, userActor(userID), action, "file", fuuid, map[string]interface{}{
+			"what":        what,
+			"sensitivity": file.Sensitivity,
+			"reason":      decision.Reason,
+		})
+	}
+	if !decision.Allowed {
+		http.Error(w, `{"error":"you do not have access to this file"}`, http.StatusForbidden)
+		return "", nil, false
+	}
+	return userID, file, true
+}
+
+// ---------------------------------------------------------------------------
+// PUT /v1/files/{id}/sensitivity
+// ---------------------------------------------------------------------------
+
+// FileSensitivityHandler changes a file's sensitivity level. The uploader
+// and the heads of the file's departments may change it.
+func FileSensitivityHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "PUT, OPTIONS") {
+		return
+	}
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fuuid := r.PathValue("id")
+	var req struct {
+		Sensitivity string `json:"sensitivity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	level := strings.ToLower(strings.TrimSpace(req.Sensitivity))
+	if !access.ValidLevel(level) {
+		http.Error(w, `{"error":"sensitivity must be internal, confidential or restricted"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, file, ok := authorizeFile(w, r, fuuid, "sensitivity")
+	if !ok {
+		return
+	}
+	viewer, err := fileViewer(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return
+	}
+	if !canSetSensitivity(viewer, accessFile(file)) {
+		http.Error(w, `{"error":"only the uploader or a head of the file's departments can change its sensitivity"}`, http.StatusForbidden)
+		return
+	}
+
+	if level != file.Sensitivity {
+		if err := models.SetFileSensitivity(config.DB, fuuid, level); errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, `{"error":"failed to update sensitivity"}`, http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, userActor(userID), auditFileSensitivity, "file", fuuid, map[string]string{
+			"from": file.Sensitivity, "to": level,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"f_uuid": fuuid, "sensitivity": level})
+}
+
+func canSetSensitivity(v access.Viewer, f access.File) bool {
+	if strings.EqualFold(v.UUID, f.OwnerUUID) {
+		return true
+	}
+	if !v.IsHead() {
+		return false
+	}
+	for _, d := range f.Departments {
+		if strings.EqualFold(d, v.DUUID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+const (
+	defaultFilePageSize = 25
+	maxFilePageSize     = 100
+)
+
+// fileListCursor is the opaque next_cursor of file listings. It carries the
+// sort it was made for, so a cursor cannot be replayed against another
+// order.
+type fileListCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	FUUID string `json:"id"`
+}
+
+func encodeFileCursor(c fileListCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFileCursor(s string) (fileListCursor, error) {
+	var c fileListCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if c.FUUID == "" {
+		return c, errors.New("malformed cursor")
+	}
+	return c, nil
+}
+
+// fileListQuery is a parsed file listing request.
+type fileListQuery struct {
+	Filter models.FileListFilter
+	Sort   string
+	Desc   bool
+	Cursor *models.FileListCursor
+	Limit  int
+}
+
+// parseFileListQuery reads filters, sort and paging from q:
+//
+//	language, status, uploader, category   exact matches
+//	q                                      substring of the file name
+//	from, to                               RFC3339 or YYYY-MM-DD; to is exclusive
+//	has_summary                            true or false
+//	sort                                   created_at (default) or name
+//	order                                  desc (default for created_at) or asc
+//	limit, cursor                          page size and next_cursor
+//
+// The returned error message is safe to show.
+func parseFileListQuery(q url.Values) (fileListQuery, error) {
+	out := fileListQuery{
+		Filter: models.FileListFilter{
+			Language:   q.Get("language"),
+			Status:     q.Get("status"),
+			Uploader:   q.Get("uploader"),
+			CategoryID: q.Get("category"),
+			Query:      q.Get("q"),
+		},
+		Sort:  models.FileSortCreatedAt,
+		Limit: defaultFilePageSize,
+	}
+	if out.Filter.Uploader != "" && !uuidRegex.MatchString(out.Filter.Uploader) {
+		return out, errors.New("uploader must be a user id")
+	}
+	if out.Filter.CategoryID != "" && !uuidRegex.MatchString(out.Filter.CategoryID) {
+		return out, errors.New("category must be a category id")
+	}
+	for key, dst := range map[string]**time.Time{"from": &out.Filter.From, "to": &out.Filter.To} {
+		raw := q.Get(key)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, raw); err != nil {
+				return out, errors.New(key + " must be RFC3339 or YYYY-MM-DD")
+			}
+		}
+		*dst = &t
+	}
+	if raw := q.Get("has_summary"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return out, errors.New("has_summary must be true or false")
+		}
+		out.Filter.HasSummary = &v
+	}
+
+	switch s := q.Get("sort"); s {
+	case "", models.FileSortCreatedAt:
+	case models.FileSortName:
+		out.Sort = s
+	default:
+		return out, errors.New("sort must be created_at or name")
+	}
+	switch q.Get("order") {
+	case "":
+		out.Desc = out.Sort == models.FileSortCreatedAt
+	case "desc":
+		out.Desc = true
+	case "asc":
+	default:
+		return out, errors.New("order must be asc or desc")
+	}
+
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxFilePageSize {
+			return out, errors.New("limit must be between 1 and 100")
+		}
+		out.Limit = n
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		c, err := decodeFileCursor(raw)
+		if err != nil || c.Sort != out.Sort || c.Desc != out.Desc {
+			return out, errors.New("invalid cursor")
+		}
+		if c.Sort == models.FileSortCreatedAt {
+			if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+				return out, errors.New("invalid cursor")
+			}
+		}
+		out.Cursor = &models.FileListCursor{Value: c.Value, FUUID: c.FUUID}
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/files
+// GET /v1/departments/{id}/files
+// ---------------------------------------------------------------------------
+
+// FilesHandler lists the files the caller may see.
+func FilesHandler(w http.ResponseWriter, r *http.Request) {
+	listFiles(w, r, "")
+}
+
+// DepartmentFilesHandler lists the files shared with a department that the
+// caller may see.
+func DepartmentFilesHandler(w http.ResponseWriter, r *http.Request) {
+	listFiles(w, r, r.PathValue("id"))
+}
+
+// listFiles answers both listings with one page of files, each with its
+// departments, summary state, category and the caller's favorite flag.
+func listFiles(w http.ResponseWriter, r *http.Request, dUUID string) {
+	if ApplyCORS(w, r, "GET, OPTIONS") {
+		return
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseFileListQuery(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if dUUID != "" {
+		if !uuidRegex.MatchString(dUUID) {
+			http.Error(w, `{"error":"department not found"}`, http.StatusNotFound)
+			return
+		}
+		dept, err := models.GetDepartmentByUUID(config.DB, dUUID)
+		if err != nil {
+			http.Error(w, `{"error":"failed to load department"}`, http.StatusInternalServerError)
+			return
+		}
+		if dept == nil {
+			http.Error(w, `{"error":"department not found"}`, http.StatusNotFound)
+			return
+		}
+		q.Filter.DUUID = dUUID
+	}
+
+	items, err := models.ListFiles(config.DB, userID, q.Filter, q.Sort, q.Desc, q.Cursor, q.Limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load files"}`, http.StatusInternalServerError)
+		return
+	}
+	var next interface{}
+	if len(items) == q.Limit {
+		last := items[len(items)-1]
+		next = encodeFileCursor(fileListCursor{Sort: q.Sort, Desc: q.Desc, Value: last.SortValue, FUUID: last.FUUID})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"files": items, "next_cursor": next})
+}
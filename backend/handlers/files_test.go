@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"backend/models"
+)
+
+func TestParseFileListQueryDefaults(t *testing.T) {
+	q, err := parseFileListQuery(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Sort != models.FileSortCreatedAt || !q.Desc || q.Limit != defaultFilePageSize || q.Cursor != nil {
+		t.Fatalf("unexpected defaults: %+v", q)
+	}
+
+	q, err = parseFileListQuery(url.Values{"sort": {"name"}})
+	if err != nil || q.Desc {
+		t.Fatalf("name should sort ascending by default: %+v, %v", q, err)
+	}
+}
+
+func TestParseFileListQueryFilters(t *testing.T) {
+	q, err := parseFileListQuery(url.Values{
+		"language":    {"Malayalam"},
+		"uploader":    {"3f1c2a9e-8b7d-4c6e-9f00-123456789abc"},
+		"from":        {"2026-01-01"},
+		"to":          {"2026-02-01T00:00:00+05:30"},
+		"has_summary": {"false"},
+		"limit":       {"10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := q.Filter
+	if f.Language != "Malayalam" || f.From == nil || f.To == nil || f.HasSummary == nil || *f.HasSummary || q.Limit != 10 {
+		t.Fatalf("unexpected filter: %+v", q)
+	}
+
+	for _, bad := range []url.Values{
+		{"uploader": {"alice"}},
+		{"from": {"yesterday"}},
+		{"has_summary": {"maybe"}},
+		{"sort": {"size"}},
+		{"order": {"up"}},
+		{"limit": {"0"}},
+		{"limit": {"500"}},
+		{"cursor": {"!!"}},
+	} {
+		if _, err := parseFileListQuery(bad); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestFileCursorIsTiedToItsSort(t *testing.T) {
+	c := encodeFileCursor(fileListCursor{Sort: models.FileSortName, Value: "budget|2026.pdf", FUUID: "f-9"})
+
+	q, err := parseFileListQuery(url.Values{"sort": {"name"}, "cursor": {c}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Cursor == nil || q.Cursor.Value != "budget|2026.pdf" || q.Cursor.FUUID != "f-9" {
+		t.Fatalf("cursor did not round-trip: %+v", q.Cursor)
+	}
+	if _, err := parseFileListQuery(url.Values{"cursor": {c}}); err == nil {
+		t.Fatal("a name cursor must not be accepted for the created_at order")
+	}
+}
+
+func TestCreatedAtCursorMustBeATimestamp(t *testing.T) {
+	good := encodeFileCursor(fileListCursor{Sort: models.FileSortCreatedAt, Desc: true, Value: "2026-01-05T09:30:00.000123Z", FUUID: "f-9"})
+	if q, err := parseFileListQuery(url.Values{"cursor": {good}}); err != nil || q.Cursor.Value != "2026-01-05T09:30:00.000123Z" {
+		t.Fatalf("expected the cursor to be accepted: %+v, %v", q.Cursor, err)
+	}
+	for _, v := range []string{"", "yesterday", "2026-01-05 09:30:00+00", "1); DROP TABLE file; --"} {
+		c := encodeFileCursor(fileListCursor{Sort: models.FileSortCreatedAt, Desc: true, Value: v, FUUID: "f-9"})
+		if _, err := parseFileListQuery(url.Values{"cursor": {c}}); err == nil || err.Error() != "invalid cursor" {
+			t.Errorf("value %q: expected invalid cursor, got %v", v, err)
+		}
+	}
+}
@@ -52,11 +52,16 @@ func GetFileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fuuid := parts[3]
 
+	userID, _, ok := authorizeFile(w, r, fuuid, "metadata")
+	if !ok {
+		return
+	}
 	file, err := models.GetFileByUUID(config.DB, fuuid)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
+	recordAudit(r, userActor(userID), auditDocumentView, "file", fuuid, nil)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(file)
 }
@@ -34,18 +34,8 @@ func ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(docs)
 }
 
-/*TODO
-the below fn is not working
-*/
-// func ListFilesHandler(w http.ResponseWriter, r *http.Request) {
-// 	files, err := models.GetAllFiles(config.DB)
-// 	if err != nil {
-// 		http.Error(w, "Failed to fetch files", http.StatusInternalServerError)
-// 		return
-// 	}
-// 	w.Header().Set("Content-Type", "application/json")
-// 	json.NewEncoder(w).Encode(files)
-// }
+// Paginated file listings are served by FilesHandler and
+// DepartmentFilesHandler in files.go.
 
 /*
 TODO
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"backend/config"
+	"backend/models"
+	"backend/notifications"
+)
+
+// ---------------------------------------------------------------------------
+// GET/PUT /v1/me/notification-preferences
+// ---------------------------------------------------------------------------
+
+func NotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "GET, PUT, OPTIONS") {
+		return
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := models.GetNotificationPreferences(config.DB, userID)
+		if err != nil {
+			http.Error(w, `{"error":"failed to load preferences"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+
+	case http.MethodPut:
+		var prefs models.NotificationPreferences
+		if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		prefs.UUID = userID
+		prefs.WebhookURL = strings.TrimSpace(prefs.WebhookURL)
+		if prefs.WebhookURL != "" {
+			if err := notifications.ValidateWebhookURL(prefs.WebhookURL); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		} else if prefs.WebhookEnabled {
+			http.Error(w, `{"error":"webhook_url is required when webhook is enabled"}`, http.StatusBadRequest)
+			return
+		}
+		switch prefs.DigestFrequency {
+		case "":
+			prefs.DigestFrequency = models.DigestImmediate
+		case models.DigestImmediate, models.DigestDaily, models.DigestWeekly:
+		default:
+			http.Error(w, `{"error":"digest_frequency must be immediate, daily or weekly"}`, http.StatusBadRequest)
+			return
+		}
+		if err := models.UpsertNotificationPreferences(config.DB, prefs); err != nil {
+			http.Error(w, `{"error":"failed to save preferences"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/ws/notifications?access_token=... — in-app push
+// ---------------------------------------------------------------------------
+
+// NotificationSocketHandler upgrades to a websocket that receives the user's
+// in-app notifications. Browsers cannot set Authorization on websockets, so
+// the Supabase access token may be passed as access_token instead.
+func NotificationSocketHandler(hub *notifications.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := AuthenticatedUserIDFromRequest(r)
+		if err != nil {
+			userID, err = authenticatedUserIDFromToken(r.URL.Query().Get("access_token"))
+		}
+		if err != nil {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		log.Printf("[WS] Notification socket opened for %s", userID)
+		hub.Serve(w, r, userID)
+		log.Printf("[WS] Notification socket closed for %s", userID)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/notifications"
+)
+
+const (
+	defaultInboxPageSize = 20
+	maxInboxPageSize     = 100
+	maxMarkReadIDs       = 500
+)
+
+func encodeInboxCursor(c models.InboxCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.NotifID))
+}
+
+func decodeInboxCursor(s string) (*models.InboxCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &models.InboxCursor{CreatedAt: t, NotifID: id}, nil
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/notifications?status=unseen|seen|all&limit=&cursor=
+// ---------------------------------------------------------------------------
+
+// NotificationsHandler lists the caller's notifications, newest first, one
+// per file, with file name, departments and a summary snippet.
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "GET, OPTIONS") {
+		return
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "":
+		status = "all"
+	case "all", "seen", "unseen":
+	default:
+		http.Error(w, `{"error":"status must be seen, unseen or all"}`, http.StatusBadRequest)
+		return
+	}
+	limit := defaultInboxPageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxInboxPageSize {
+			http.Error(w, `{"error":"limit must be between 1 and 100"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var cursor *models.InboxCursor
+	if v := q.Get("cursor"); v != "" {
+		if cursor, err = decodeInboxCursor(v); err != nil {
+			http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	profile, err := models.GetUserProfile(config.DB, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return
+	}
+	dUUID := ""
+	if profile != nil {
+		dUUID = profile.DUUID
+	}
+	items, err := models.ListInboxNotifications(config.DB, userID, dUUID, status, cursor, limit)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load notifications"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var next interface{}
+	if len(items) == limit {
+		last := items[len(items)-1]
+		next = encodeInboxCursor(models.InboxCursor{CreatedAt: last.CreatedAt, NotifID: last.NotifID})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notifications": items, "next_cursor": next})
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/notifications/unread-count
+// ---------------------------------------------------------------------------
+
+func NotificationsUnreadCountHandler(w http.ResponseWriter, r *http.Request) {
+	if ApplyCORS(w, r, "GET, OPTIONS") {
+		return
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := models.CountUnreadNotifications(config.DB, userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to count notifications"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"count": n})
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/notifications/read {"notif_ids": [...]} or {"all": true}
+// ---------------------------------------------------------------------------
+
+// NotificationsReadHandler marks notifications as seen and tells the user's
+// other open tabs to refresh their badge.
+func NotificationsReadHandler(hub *notifications.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ApplyCORS(w, r, "POST, OPTIONS") {
+			return
+		}
+		userID, err := AuthenticatedUserIDFromRequest(r)
+		if err != nil {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			NotifIDs []string `json:"notif_ids"`
+			All      bool     `json:"all"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		if !req.All && len(req.NotifIDs) == 0 {
+			http.Error(w, `{"error":"notif_ids or all is required"}`, http.StatusBadRequest)
+			return
+		}
+		if len(req.NotifIDs) > maxMarkReadIDs {
+			http.Error(w, `{"error":"too many notif_ids (max 500)"}`, http.StatusBadRequest)
+			return
+		}
+
+		updated, err := models.MarkNotificationsRead(config.DB, userID, req.NotifIDs, req.All)
+		if err != nil {
+			http.Error(w, `{"error":"failed to update notifications"}`, http.StatusInternalServerError)
+			return
+		}
+		unread, err := models.CountUnreadNotifications(config.DB, userID)
+		if err != nil {
+			http.Error(w, `{"error":"failed to count notifications"}`, http.StatusInternalServerError)
+			return
+		}
+		if updated > 0 && hub != nil {
+			hub.Publish(userID, []byte(`{"kind":"notifications_read"}`))
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"updated": updated, "unread_count": unread})
+	}
+}
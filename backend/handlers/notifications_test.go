@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+func TestInboxCursorRoundTrip(t *testing.T) {
+	in := models.InboxCursor{CreatedAt: time.Date(2025, 3, 1, 9, 30, 0, 123456000, time.FixedZone("IST", 19800)), NotifID: "n-42"}
+	out, err := decodeInboxCursor(encodeInboxCursor(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.CreatedAt.Equal(in.CreatedAt) || out.NotifID != in.NotifID {
+		t.Fatalf("round trip mismatch: %+v != %+v", out, in)
+	}
+	for _, bad := range []string{"", "!!", "bm8tc2VwYXJhdG9y", "MjAyNS0wMy0wMXw"} {
+		if _, err := decodeInboxCursor(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"backend/access"
+	"backend/config"
+	"backend/preview"
+)
+
+// previewCacheAge is how long browsers may reuse a thumbnail or page
+// preview. Rendered images only change if the file is re-rendered, and the
+// ETag covers that.
+const previewCacheAge = 24 * time.Hour
+
+var previews *preview.Service
+
+func SetPreviewService(s *preview.Service) { previews = s }
+
+// InitPreviewService renders with poppler and stores images in the
+// file_storage bucket.
+func InitPreviewService() {
+	SetPreviewService(preview.NewService(preview.NewPostgresStore(config.DB), supabasePreviewStorage{}, preview.NewCommandRenderer()))
+}
+
+type supabasePreviewStorage struct{}
+
+func (supabasePreviewStorage) Put(path string, data []byte, contentType string) error {
+	return config.Supabase.PutFile(storageBucket, path, data, contentType)
+}
+
+// fetchOriginal returns a preview.Fetch that downloads the stored file to a
+// temporary path.
+func fetchOriginal(filePath string) preview.Fetch {
+	return func() (string, func(), error) {
+		tmp, err := os.CreateTemp("", "preview-*"+filepath.Ext(filePath))
+		if err != nil {
+			return "", nil, err
+		}
+		tmp.Close()
+		cleanup := func() { _ = os.Remove(tmp.Name()) }
+		if err := config.Supabase.DownloadFile(storageBucket, filePath, tmp.Name()); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		return tmp.Name(), cleanup, nil
+	}
+}
+
+// generatePreviews renders the thumbnail and first page previews of a
+// freshly uploaded file from its local copy. Failures are logged and
+// recorded; the previews are rendered again on first request.
+func generatePreviews(fuuid, filePath, localPath string) {
+	if previews == nil || preview.KindOf(filePath) == "" {
+		return
+	}
+	rec, err := previews.Generate(context.Background(), fuuid, filePath, localPath)
+	if err != nil {
+		log.Printf("[PREVIEW] Rendering %s failed: %v", fuuid, err)
+		return
+	}
+	log.Printf("[PREVIEW] Rendered %s: %d of %d pages", fuuid, len(rec.Pages), rec.PageCount)
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/files/{id}/thumbnail
+// GET /v1/files/{id}/pages/{n}/preview
+// ---------------------------------------------------------------------------
+
+// FileThumbnailHandler serves the page-1 thumbnail. It shows the first page
+// of the document, so it follows the download rule like page previews.
+func FileThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	servePreview(w, r, 0, "thumbnail", access.CheckDownload)
+}
+
+// FilePagePreviewHandler serves a low-resolution page image. Previews show
+// the document's content, so they follow the download rule.
+func FilePagePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || page < 1 {
+		ApplyCORS(w, r, "GET, OPTIONS")
+		http.Error(w, `{"error":"page must be a positive number"}`, http.StatusBadRequest)
+		return
+	}
+	servePreview(w, r, page, "preview", access.CheckDownload)
+}
+
+// servePreview streams the thumbnail (page 0) or a page preview from
+// storage, rendering it first when it is missing. Sensitive files are never
+// cached and carry a watermark like downloads do.
+func servePreview(w http.ResponseWriter, r *http.Request, page int, what string, check func(access.Viewer, access.File) access.Decision) {
+	if ApplyCORS(w, r, "GET, OPTIONS") {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if previews == nil {
+		http.Error(w, `{"error":"previews are not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+	fuuid := r.PathValue("id")
+	userID, file, ok := authorizeFileWith(w, r, fuuid, what, check)
+	if !ok {
+		return
+	}
+	if file.FilePath == "" {
+		http.Error(w, `{"error":"file has no stored content"}`, http.StatusNotFound)
+		return
+	}
+	if file.ArchivedAt != nil {
+		http.Error(w, `{"error":"file has been archived"}`, http.StatusGone)
+		return
+	}
+
+	path, err := previews.Ensure(r.Context(), fuuid, file.FilePath, page, fetchOriginal(file.FilePath))
+	switch {
+	case errors.Is(err, preview.ErrUnsupported):
+		http.Error(w, `{"error":"no preview for this file type"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, preview.ErrNoPage):
+		http.Error(w, `{"error":"page not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, preview.ErrTooLarge):
+		http.Error(w, `{"error":"image is too large to preview"}`, http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, preview.ErrFailed):
+		w.Header().Set("Retry-After", strconv.Itoa(int(preview.RetryAfter.Seconds())))
+		http.Error(w, `{"error":"preview rendering failed; it will be retried later"}`, http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("[PREVIEW] %s of %s failed: %v", what, fuuid, err)
+		http.Error(w, `{"error":"failed to render preview"}`, http.StatusBadGateway)
+		return
+	}
+
+	resp, err := config.Supabase.OpenFile(storageBucket, path, r.Header)
+	if err != nil {
+		log.Printf("[PREVIEW] fetching %s failed: %v", path, err)
+		http.Error(w, `{"error":"failed to fetch preview from storage"}`, http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	h := w.Header()
+	copyStoredHeaders(h, resp)
+	h.Set("Content-Type", preview.ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if access.InlineOnly(file.Sensitivity) {
+		h.Set("Cache-Control", "private, no-store")
+		h.Set("X-Watermark", watermarkText(file.Sensitivity, watermarkFor(userID), fuuid, time.Now()))
+		h.Set("Access-Control-Expose-Headers", "X-Watermark")
+	} else {
+		h.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(previewCacheAge.Seconds())))
+	}
+	w.WriteHeader(resp.StatusCode)
+	if err := streamBody(w, resp.Body); err != nil {
+		log.Printf("[PREVIEW] streaming %s interrupted: %v", path, err)
+	}
+}
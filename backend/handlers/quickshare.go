@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/config"
+	"backend/quickshare"
+)
+
+const (
+	auditQuickShareCreate = "quickshare.create"
+	auditQuickShareReply  = "quickshare.reply"
+)
+
+var quickShares *quickshare.Service
+
+// SetQuickShareService swaps the quick share backend (tests use a memory store).
+func SetQuickShareService(s *quickshare.Service) {
+	quickShares = s
+}
+
+// InitQuickShareService backs quick shares with the database.
+func InitQuickShareService() {
+	SetQuickShareService(quickshare.NewService(quickshare.NewPostgresStore(config.DB)))
+}
+
+// auditQuickShare records who sent a message, with the files attached and
+// who received it.
+func auditQuickShare(r *http.Request, userID, action string, m quickshare.Message) {
+	recipients := make([]string, 0, len(m.Recipients))
+	for _, rc := range m.Recipients {
+		recipients = append(recipients, rc.UUID)
+	}
+	recordAudit(r, userActor(userID), action, "quick_share", m.ID, map[string]interface{}{
+		"thread_id": m.ThreadID, "attachments": m.Attachments, "departments": m.Departments, "recipients": recipients,
+	})
+}
+
+func writeQuickShareError(w http.ResponseWriter, err error) {
+	var invalid quickshare.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, quickshare.ErrNotFound):
+		http.Error(w, `{"error":"quick share not found"}`, http.StatusNotFound)
+	case errors.Is(err, quickshare.ErrForbidden):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	case errors.Is(err, quickshare.ErrNoRecipients):
+		http.Error(w, `{"error":"no active recipients in the target departments"}`, http.StatusUnprocessableEntity)
+	default:
+		log.Printf("[QUICK_SHARE] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/quickshare
+// ---------------------------------------------------------------------------
+
+// QuickSharesHandler lists the caller's inbox or sent quick shares
+// (?box=inbox|sent&before=<RFC 3339>&limit=) or creates one.
+func QuickSharesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		var before time.Time
+		if v := q.Get("before"); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				http.Error(w, `{"error":"before must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+				return
+			}
+			before = t
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		limit = quickshare.PageSize(limit)
+		list, err := quickShares.List(userID, q.Get("box"), before, limit)
+		if err != nil {
+			writeQuickShareError(w, err)
+			return
+		}
+		resp := map[string]interface{}{"messages": list, "next_before": nil}
+		if list == nil {
+			resp["messages"] = []quickshare.Message{}
+		} else if len(list) == limit {
+			resp["next_before"] = list[len(list)-1].CreatedAt.Format(time.RFC3339Nano)
+		}
+		writeJSON(w, http.StatusOK, resp)
+
+	case http.MethodPost:
+		var d quickshare.Draft
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		m, err := quickShares.Create(userID, d)
+		if err != nil {
+			writeQuickShareError(w, err)
+			return
+		}
+		log.Printf("[QUICK_SHARE] %s sent %s to %d recipient(s)", userID, m.ID, len(m.Recipients))
+		auditQuickShare(r, userID, auditQuickShareCreate, m)
+		writeJSON(w, http.StatusCreated, m)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/quickshare/{id} — the whole thread
+// ---------------------------------------------------------------------------
+
+func QuickShareThreadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	thread, err := quickShares.Thread(userID, r.PathValue("id"))
+	if err != nil {
+		writeQuickShareError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"thread_id": thread[0].ThreadID, "messages": thread})
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/quickshare/{id}/reply
+// ---------------------------------------------------------------------------
+
+func QuickShareReplyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var reply quickshare.Reply
+	if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	m, err := quickShares.Reply(userID, r.PathValue("id"), reply)
+	if err != nil {
+		writeQuickShareError(w, err)
+		return
+	}
+	auditQuickShare(r, userID, auditQuickShareReply, m)
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/quickshare/{id}/read — read receipt
+// ---------------------------------------------------------------------------
+
+func QuickShareReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := quickShares.MarkRead(userID, r.PathValue("id")); err != nil {
+		writeQuickShareError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
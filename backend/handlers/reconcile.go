@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/reconcile"
+	"backend/services"
+)
+
+const auditReconcileRun = "reconcile.run"
+
+var reconcileSvc *reconcile.Service
+
+// SetReconcileService swaps the reconciler backend (tests use a memory store).
+func SetReconcileService(s *reconcile.Service) {
+	reconcileSvc = s
+}
+
+// InitReconcileService backs the reconciler with the database and both
+// buckets. Missing OCR is re-run by a background worker, one file at a
+// time; missing summaries go to the summary queue. Runs a previous process
+// left running are marked interrupted.
+func InitReconcileService() {
+	if n, err := models.InterruptReconcileRuns(config.DB, time.Now()); err != nil {
+		log.Printf("[RECONCILE] Failed to mark unfinished runs: %v", err)
+	} else if n > 0 {
+		log.Printf("[RECONCILE] %d unfinished runs marked interrupted", n)
+	}
+	queue := make(chan reconcile.File, reconcile.MaxRequeuesPerRun)
+	go func() {
+		for f := range queue {
+			if err := reprocessOCR(f.FUUID, f.FilePath); err != nil {
+				log.Printf("[RECONCILE] OCR for %s failed: %v", f.FUUID, err)
+			}
+		}
+	}()
+	SetReconcileService(reconcile.NewService(reconcile.NewPostgresStore(config.DB), supabaseReconcileStorage{}, pipelineRequeuer{ocr: queue}))
+}
+
+// InitInlineReconcileService is InitReconcileService for cmd/reconcile:
+// OCR runs inline, so it has finished when the command exits.
+func InitInlineReconcileService() {
+	SetReconcileService(reconcile.NewService(reconcile.NewPostgresStore(config.DB), supabaseReconcileStorage{}, pipelineRequeuer{}))
+}
+
+// supabaseReconcileStorage lists file_storage and the archive bucket, and
+// deletes from them like retention does.
+type supabaseReconcileStorage struct{ supabaseRetentionStorage }
+
+func (supabaseReconcileStorage) List(archived bool) ([]reconcile.Object, error) {
+	bucket := storageBucket
+	if archived {
+		bucket = archiveBucket()
+	}
+	objects, err := config.Supabase.ListFiles(bucket, "")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]reconcile.Object, 0, len(objects))
+	for _, o := range objects {
+		out = append(out, reconcile.Object{Path: o.Path, Size: o.Size, CreatedAt: o.CreatedAt, Archived: archived})
+	}
+	return out, nil
+}
+
+var errOCRQueueFull = errors.New("OCR queue is full")
+
+// pipelineRequeuer hands OCR to the background worker, or runs it inline
+// when there is none.
+type pipelineRequeuer struct{ ocr chan reconcile.File }
+
+func (q pipelineRequeuer) OCR(f reconcile.File) error {
+	if q.ocr == nil {
+		return reprocessOCR(f.FUUID, f.FilePath)
+	}
+	select {
+	case q.ocr <- f:
+		return nil
+	default:
+		return errOCRQueueFull
+	}
+}
+
+// Summary inserts a pending summary row for the summary worker.
+func (pipelineRequeuer) Summary(f reconcile.File) error {
+	return models.InsertSummary(config.DB, models.Summary{FUUID: f.FUUID})
+}
+
+// reprocessOCR runs the OCR step of the upload pipeline again for a stored
+// file and classifies it if it has no category yet.
+func reprocessOCR(fuuid, filePath string) error {
+	tmpPath := filepath.Join(os.TempDir(), "reconcile_"+filepath.Base(filePath))
+	if err := config.Supabase.DownloadFile(storageBucket, filePath, tmpPath); err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmpPath) }()
+	text, avgConf, err := services.RunOCR(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := models.InsertOCRResult(config.DB, models.OCRResult{FUUID: fuuid, Data: text, AvgConfidence: avgConf}); err != nil {
+		return err
+	}
+	classifyIfMissing(fuuid, text)
+	return nil
+}
+
+// auditReconcile records a finished run with its counts.
+func auditReconcile(r *http.Request, actor auditActor, rep reconcile.Report) {
+	recordAudit(r, actor, auditReconcileRun, "reconcile_run", rep.ID, map[string]interface{}{
+		"trigger": rep.Trigger, "clean": rep.Clean, "requeue": rep.Requeue, "counts": rep.Counts, "error": rep.Error,
+	})
+}
+
+// RunReconcile executes one run on behalf of the system (the schedule and
+// cmd/reconcile) and waits for it.
+func RunReconcile(trigger string, opts reconcile.Options) (reconcile.Report, error) {
+	rep, err := reconcileSvc.Execute(trigger, opts)
+	if rep.ID != "" {
+		auditReconcile(nil, systemActor, rep)
+	}
+	return rep, err
+}
+
+// StartReconcileJob reconciles storage with the database every interval,
+// re-queueing missing processing. Orphan objects are only reported unless
+// RECONCILE_CLEAN_ORPHANS is "true".
+func StartReconcileJob(interval time.Duration) {
+	opts := reconcile.Options{Requeue: true, Clean: os.Getenv("RECONCILE_CLEAN_ORPHANS") == "true"}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rep, err := RunReconcile(reconcile.TriggerSchedule, opts)
+			if err != nil {
+				log.Printf("[RECONCILE] Run failed: %v", err)
+				continue
+			}
+			c := rep.Counts
+			log.Printf("[RECONCILE] Run %s: %d orphan objects (%d deleted), %d missing objects, %d missing OCR, %d missing summaries, %d requeued, %d failed",
+				rep.ID, c.OrphanObjects, c.Deleted, c.MissingObjects, c.MissingOCR, c.MissingSummaries, c.Requeued, c.Failed)
+		}
+	}()
+}
+
+func writeReconcileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, reconcile.ErrRunNotFound):
+		http.Error(w, `{"error":"reconcile run not found"}`, http.StatusNotFound)
+	case errors.Is(err, reconcile.ErrRunning):
+		http.Error(w, `{"error":"a reconcile run is already in progress"}`, http.StatusConflict)
+	default:
+		log.Printf("[RECONCILE] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/reconcile/runs — GET list, POST run now
+// ---------------------------------------------------------------------------
+
+// AdminReconcileRunsHandler lists the latest runs (?limit=, default 20) or
+// starts one. POST {"clean": true} deletes orphan objects and
+// {"requeue": true} re-queues missing OCR and summaries; with neither the
+// run only reports. The run goes on in the background: the 202 response
+// carries its ID, and GET /v1/admin/reconcile/runs/{id} has its status.
+func AdminReconcileRunsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit := 20
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > 200 {
+				http.Error(w, `{"error":"limit must be between 1 and 200"}`, http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		runs, err := reconcileSvc.Runs(limit)
+		if err != nil {
+			writeReconcileError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+
+	case http.MethodPost:
+		var opts struct {
+			Clean   bool `json:"clean"`
+			Requeue bool `json:"requeue"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		actor, audited := adminActor(r), r.Clone(context.WithoutCancel(r.Context()))
+		rep, err := reconcileSvc.Start(reconcile.TriggerManual, reconcile.Options{Clean: opts.Clean, Requeue: opts.Requeue},
+			func(rep reconcile.Report, err error) {
+				if err != nil {
+					log.Printf("[RECONCILE] Manual run %s failed: %v", rep.ID, err)
+				}
+				auditReconcile(audited, actor, rep)
+			})
+		if err != nil {
+			writeReconcileError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/v1/admin/reconcile/runs/"+rep.ID)
+		writeJSON(w, http.StatusAccepted, rep)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/reconcile/runs/{id}
+// ---------------------------------------------------------------------------
+
+// AdminReconcileRunHandler returns a run with its findings; the id "latest"
+// names the most recent run.
+func AdminReconcileRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	if id == "latest" {
+		runs, err := reconcileSvc.Runs(1)
+		if err != nil {
+			writeReconcileError(w, err)
+			return
+		}
+		if len(runs) == 0 {
+			writeReconcileError(w, reconcile.ErrRunNotFound)
+			return
+		}
+		id = runs[0].ID
+	}
+	rep, err := reconcileSvc.Run(id)
+	if err != nil {
+		writeReconcileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
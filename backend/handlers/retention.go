@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"backend/config"
+	"backend/models"
+	"backend/retention"
+)
+
+const (
+	auditRetentionPolicyCreate = "retention_policy.create"
+	auditRetentionPolicyUpdate = "retention_policy.update"
+	auditRetentionPolicyDelete = "retention_policy.delete"
+	auditRetentionRun          = "retention.run"
+	auditFileLegalHold         = "file.legal_hold"
+	auditFileArchive           = "file.archive"
+	auditFilePurge             = "file.purge"
+)
+
+// defaultArchiveBucket receives archived objects unless
+// RETENTION_ARCHIVE_BUCKET names another bucket.
+const defaultArchiveBucket = "file_archive"
+
+func archiveBucket() string {
+	if b := os.Getenv("RETENTION_ARCHIVE_BUCKET"); b != "" {
+		return b
+	}
+	return defaultArchiveBucket
+}
+
+var retentionSvc *retention.Service
+
+// SetRetentionService swaps the retention backend (tests use a memory store).
+func SetRetentionService(s *retention.Service) {
+	retentionSvc = s
+}
+
+// InitRetentionService backs retention with the database and the
+// file_storage and archive buckets. Runs a previous process left running
+// are marked interrupted.
+func InitRetentionService() {
+	if n, err := models.InterruptRetentionRuns(config.DB, time.Now()); err != nil {
+		log.Printf("[RETENTION] Failed to mark unfinished runs: %v", err)
+	} else if n > 0 {
+		log.Printf("[RETENTION] %d unfinished runs marked interrupted", n)
+	}
+	SetRetentionService(retention.NewService(retention.NewPostgresStore(config.DB), supabaseRetentionStorage{}))
+}
+
+type supabaseRetentionStorage struct{}
+
+func (supabaseRetentionStorage) Archive(path string) error {
+	return config.Supabase.MoveFile(storageBucket, path, archiveBucket())
+}
+
+func (supabaseRetentionStorage) Unarchive(path string) error {
+	return config.Supabase.MoveFile(archiveBucket(), path, storageBucket)
+}
+
+func (supabaseRetentionStorage) Delete(paths []string, archived bool) error {
+	bucket := storageBucket
+	if archived {
+		bucket = archiveBucket()
+	}
+	return config.Supabase.DeleteFiles(bucket, paths)
+}
+
+// auditRetention records a finished run with its counts; every file it
+// archived or purged is audited on its own.
+func auditRetention(r *http.Request, actor auditActor, rep retention.Report) {
+	recordAudit(r, actor, auditRetentionRun, "retention_run", rep.ID, map[string]interface{}{
+		"trigger": rep.Trigger, "dry_run": rep.DryRun, "counts": rep.Counts, "error": rep.Error,
+	})
+	if !rep.DryRun {
+		for _, it := range rep.Items {
+			switch it.Outcome {
+			case retention.OutcomeArchived:
+				recordAudit(r, actor, auditFileArchive, "file", it.FUUID, it)
+			case retention.OutcomePurged:
+				recordAudit(r, actor, auditFilePurge, "file", it.FUUID, it)
+			}
+		}
+	}
+}
+
+// StartRetentionJob applies the retention policies every interval.
+func StartRetentionJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rep, err := retentionSvc.Execute(retention.TriggerSchedule, false)
+			if rep.ID != "" {
+				auditRetention(nil, systemActor, rep)
+			}
+			if err != nil {
+				log.Printf("[RETENTION] Run failed: %v", err)
+				continue
+			}
+			c := rep.Counts
+			log.Printf("[RETENTION] Run %s: %d archived, %d purged, %d held, %d failed, %d quick shares, %d processing results",
+				rep.ID, c.Archived, c.Purged, c.Held, c.Failed, c.QuickShares, c.ProcessingResults)
+		}
+	}()
+}
+
+func writeRetentionError(w http.ResponseWriter, err error) {
+	var invalid retention.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, retention.ErrNotFound):
+		http.Error(w, `{"error":"retention policy not found"}`, http.StatusNotFound)
+	case errors.Is(err, retention.ErrRunNotFound):
+		http.Error(w, `{"error":"retention run not found"}`, http.StatusNotFound)
+	case errors.Is(err, retention.ErrFileNotFound):
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+	case errors.Is(err, retention.ErrRunning):
+		http.Error(w, `{"error":"a retention run is already in progress"}`, http.StatusConflict)
+	default:
+		log.Printf("[RETENTION] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/retention-policies — GET list, POST create
+// ---------------------------------------------------------------------------
+
+// retentionPolicyReq is the body of create and update. Enabled defaults to
+// true.
+type retentionPolicyReq struct {
+	Name       string `json:"name"`
+	Scope      string `json:"scope"`
+	CategoryID string `json:"category_id"`
+	DUUID      string `json:"d_uuid"`
+	KeepDays   int    `json:"keep_days"`
+	Action     string `json:"action"`
+	Enabled    *bool  `json:"enabled"`
+}
+
+func (req retentionPolicyReq) policy() retention.Policy {
+	p := retention.Policy{
+		Name: req.Name, Scope: req.Scope, CategoryID: req.CategoryID, DUUID: req.DUUID,
+		KeepDays: req.KeepDays, Action: req.Action, Enabled: true,
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	return p
+}
+
+func AdminRetentionPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := retentionSvc.Policies()
+		if err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		var req retentionPolicyReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		actor := adminActor(r)
+		p, err := retentionSvc.CreatePolicy(req.policy(), actor.ID)
+		if err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		recordAudit(r, actor, auditRetentionPolicyCreate, "retention_policy", p.ID, p)
+		writeJSON(w, http.StatusCreated, p)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/retention-policies/{id} — PUT replace, DELETE
+// ---------------------------------------------------------------------------
+
+func AdminRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPut:
+		var req retentionPolicyReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		before, after, err := retentionSvc.UpdatePolicy(id, req.policy())
+		if err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditRetentionPolicyUpdate, "retention_policy", id, map[string]interface{}{
+			"from": before, "to": after,
+		})
+		writeJSON(w, http.StatusOK, after)
+
+	case http.MethodDelete:
+		if err := retentionSvc.DeletePolicy(id); err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditRetentionPolicyDelete, "retention_policy", id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/retention/runs — GET list, POST run now
+// ---------------------------------------------------------------------------
+
+// AdminRetentionRunsHandler lists the latest runs (?limit=, default 20) or
+// starts one. POST {"dry_run": true} reports what would happen without
+// changing anything. The run goes on in the background: the 202 response
+// carries its ID, and GET /v1/admin/retention/runs/{id} has its status.
+func AdminRetentionRunsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit := 20
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > 200 {
+				http.Error(w, `{"error":"limit must be between 1 and 200"}`, http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		runs, err := retentionSvc.Runs(limit)
+		if err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+
+	case http.MethodPost:
+		var req struct {
+			DryRun bool `json:"dry_run"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		actor, audited := adminActor(r), r.Clone(context.WithoutCancel(r.Context()))
+		rep, err := retentionSvc.Start(retention.TriggerManual, req.DryRun, func(rep retention.Report, err error) {
+			if err != nil {
+				log.Printf("[RETENTION] Manual run %s failed: %v", rep.ID, err)
+			}
+			auditRetention(audited, actor, rep)
+		})
+		if err != nil {
+			writeRetentionError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/v1/admin/retention/runs/"+rep.ID)
+		writeJSON(w, http.StatusAccepted, rep)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/retention/runs/{id}
+// ---------------------------------------------------------------------------
+
+func AdminRetentionRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rep, err := retentionSvc.Run(r.PathValue("id"))
+	if err != nil {
+		writeRetentionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// ---------------------------------------------------------------------------
+// PUT /v1/admin/files/{id}/legal-hold
+// ---------------------------------------------------------------------------
+
+// AdminFileLegalHoldHandler places ({"hold": true, "reason": "..."}) or
+// lifts ({"hold": false}) a legal hold, which keeps retention runs from
+// archiving or purging the file.
+func AdminFileLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Hold   *bool  `json:"hold"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Hold == nil {
+		http.Error(w, `{"error":"body must be {\"hold\": true|false, \"reason\": \"...\"}"}`, http.StatusBadRequest)
+		return
+	}
+	actor := adminActor(r)
+	f, err := retentionSvc.SetLegalHold(r.PathValue("id"), *req.Hold, req.Reason, actor.ID)
+	if err != nil {
+		writeRetentionError(w, err)
+		return
+	}
+	recordAudit(r, actor, auditFileLegalHold, "file", f.FUUID, map[string]interface{}{
+		"hold": *req.Hold, "reason": req.Reason,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"f_uuid": f.FUUID, "legal_hold": f.LegalHold})
+}
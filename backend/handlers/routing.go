@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"backend/classification"
+	"backend/config"
+	"backend/models"
+	"backend/routing"
+)
+
+const (
+	auditRoutingRuleCreate = "routing_rule.create"
+	auditRoutingRuleUpdate = "routing_rule.update"
+	auditRoutingRuleDelete = "routing_rule.delete"
+	auditDocumentRouted    = "document.routed"
+)
+
+var routingRules *routing.Service
+
+// SetRoutingService swaps the routing backend (tests use a memory store).
+func SetRoutingService(s *routing.Service) {
+	routingRules = s
+}
+
+// InitRoutingService backs routing rules with the database and classifies
+// with the LLM completion server.
+func InitRoutingService() {
+	SetRoutingService(routing.NewService(routing.NewPostgresStore(config.DB), routing.NewLLMClassifier(llmEndpoint())))
+}
+
+func writeRoutingError(w http.ResponseWriter, err error) {
+	var invalid routing.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, routing.ErrNotFound):
+		http.Error(w, `{"error":"routing rule not found"}`, http.StatusNotFound)
+	default:
+		log.Printf("[ROUTING] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// routingRuleReq is the body of create and update. Enabled defaults to true
+// and priority to 100; lower priorities are evaluated first.
+type routingRuleReq struct {
+	Name          string  `json:"name"`
+	RuleType      string  `json:"rule_type"`
+	Pattern       string  `json:"pattern"`
+	DUUID         string  `json:"d_uuid"`
+	Priority      *int    `json:"priority"`
+	MinConfidence float64 `json:"min_confidence"`
+	Enabled       *bool   `json:"enabled"`
+}
+
+func (req routingRuleReq) rule() routing.Rule {
+	r := routing.Rule{
+		Name: req.Name, Type: req.RuleType, Pattern: req.Pattern, DUUID: req.DUUID,
+		Priority: 100, MinConfidence: req.MinConfidence, Enabled: true,
+	}
+	if req.Priority != nil {
+		r.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		r.Enabled = *req.Enabled
+	}
+	return r
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/routing-rules — GET list, POST create
+// ---------------------------------------------------------------------------
+
+func AdminRoutingRulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := routingRules.Rules()
+		if err != nil {
+			writeRoutingError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+
+	case http.MethodPost:
+		var req routingRuleReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		actor := adminActor(r)
+		rule, err := routingRules.Create(req.rule(), actor.ID)
+		if err != nil {
+			writeRoutingError(w, err)
+			return
+		}
+		recordAudit(r, actor, auditRoutingRuleCreate, "routing_rule", rule.ID, rule)
+		writeJSON(w, http.StatusCreated, rule)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/routing-rules/{id} — PUT replace, DELETE
+// ---------------------------------------------------------------------------
+
+func AdminRoutingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPut:
+		var req routingRuleReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		before, after, err := routingRules.Update(id, req.rule())
+		if err != nil {
+			writeRoutingError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditRoutingRuleUpdate, "routing_rule", id, map[string]interface{}{
+			"from": before, "to": after,
+		})
+		writeJSON(w, http.StatusOK, after)
+
+	case http.MethodDelete:
+		if err := routingRules.Delete(id); err != nil {
+			writeRoutingError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditRoutingRuleDelete, "routing_rule", id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/admin/routing-rules/dry-run
+// ---------------------------------------------------------------------------
+
+// routingDryRunReq names a stored file (its latest OCR text is used unless
+// text is given) or describes a document inline. Rule is an optional unsaved
+// rule to try alongside the stored ones.
+type routingDryRunReq struct {
+	FUUID        string          `json:"f_uuid"`
+	Text         string          `json:"text"`
+	FileName     string          `json:"f_name"`
+	UploaderUUID string          `json:"uploader_uuid"`
+	Rule         *routingRuleReq `json:"rule"`
+}
+
+func AdminRoutingDryRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req routingDryRunReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if req.FUUID == "" && req.Text == "" && req.FileName == "" {
+		http.Error(w, `{"error":"f_uuid or text is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	originalName, uploader := req.FileName, req.UploaderUUID
+	storedText := false
+	if req.FUUID != "" {
+		file, err := models.GetFileByUUID(config.DB, req.FUUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `{"error":"failed to fetch file"}`, http.StatusInternalServerError)
+			return
+		}
+		if req.Text == "" {
+			if req.Text, err = models.GetLatestOCRText(config.DB, req.FUUID); err != nil {
+				http.Error(w, `{"error":"failed to fetch OCR text"}`, http.StatusInternalServerError)
+				return
+			}
+			storedText = true
+		}
+		if req.FileName == "" {
+			req.FileName = file.FileName
+		}
+		originalName = filepath.Base(file.FilePath)
+		if uploader == "" {
+			uploader = file.UUID
+		}
+	}
+	doc, err := routingDocument(req.FUUID, req.FileName, originalName, req.Text, uploader)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load document details"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var candidate *routing.Rule
+	if req.Rule != nil {
+		c := req.Rule.rule()
+		candidate = &c
+	}
+	// Stay inside the server's 30s write timeout.
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
+
+	// The file's stored category decides taxonomy llm rules, as it does on
+	// upload; inline text is classified afresh without being saved.
+	var category *classification.Result
+	if classifier != nil {
+		if storedText {
+			category, err = classifier.Result(req.FUUID)
+		} else {
+			var res classification.Result
+			res, err = classifier.Decide(ctx, doc.Text)
+			category = &res
+		}
+		if err != nil {
+			writeClassificationError(w, err)
+			return
+		}
+		doc = withCategory(doc, category)
+	}
+	results, err := routingRules.DryRun(ctx, doc, candidate)
+	if err != nil {
+		writeRoutingError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":        results,
+		"departments":    nonNil(routing.Targets(results)),
+		"classification": category,
+		"text_length":    len(doc.Text),
+	})
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// routingDocument fills in the uploader's email and department and the
+// departments the file already has.
+func routingDocument(fuuid, title, originalName, text, uploaderUUID string) (routing.Document, error) {
+	doc := routing.Document{FUUID: fuuid, FileName: title, OriginalName: originalName, Text: text, UploaderUUID: uploaderUUID}
+	if uploaderUUID != "" {
+		p, err := models.GetUserProfile(config.DB, uploaderUUID)
+		if err != nil {
+			return doc, err
+		}
+		if p != nil {
+			doc.UploaderEmail, doc.UploaderDUUID = p.Email, p.DUUID
+		}
+	}
+	if fuuid != "" {
+		depts, err := models.GetFileDepartmentIDs(config.DB, fuuid)
+		if err != nil {
+			return doc, err
+		}
+		doc.Departments = depts
+	}
+	return doc, nil
+}
+
+// routeUploadedDocument runs the routing rules for a freshly OCR'd upload.
+// category is the upload's classification, nil if it failed. Failures are
+// logged; the upload itself has already succeeded.
+func routeUploadedDocument(fuuid, title, originalName, text, uploaderUUID string, category *classification.Result) {
+	if routingRules == nil {
+		return
+	}
+	doc, err := routingDocument(fuuid, title, originalName, text, uploaderUUID)
+	if err != nil {
+		log.Printf("[ROUTING] Failed to load details for %s: %v", fuuid, err)
+		return
+	}
+	doc = withCategory(doc, category)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	results, added, err := routingRules.Route(ctx, doc)
+	if err != nil {
+		log.Printf("[ROUTING] Failed to route %s: %v", fuuid, err)
+		return
+	}
+	if len(added) == 0 {
+		return
+	}
+	var fired []routing.Result
+	for _, res := range results {
+		if res.Matched && !res.AlreadyAssigned {
+			fired = append(fired, res)
+		}
+	}
+	log.Printf("[ROUTING] %s routed to %d department(s)", fuuid, len(added))
+	recordAudit(nil, systemActor, auditDocumentRouted, "file", fuuid, map[string]interface{}{
+		"added": added,
+		"rules": fired,
+	})
+}
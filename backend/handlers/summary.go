@@ -34,7 +34,8 @@ type SummaryStatusResponse struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
-// RequestSummaryHandler queues summary generation for a document.
+// RequestSummaryHandler queues summary generation for a document the caller
+// may see at its sensitivity level.
 func RequestSummaryHandler(w http.ResponseWriter, r *http.Request) {
 	var req RequestSummaryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -56,8 +57,8 @@ func RequestSummaryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := models.GetFileByUUID(config.DB, fuuid); err != nil {
-		http.Error(w, "Document not found", http.StatusNotFound)
+	userID, _, ok := authorizeFile(w, r, fuuid, "summary")
+	if !ok {
 		return
 	}
 
@@ -80,6 +81,7 @@ func RequestSummaryHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to queue summary generation", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, userActor(userID), auditSummaryRequest, "file", fuuid, nil)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -91,6 +93,7 @@ func RequestSummaryHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSummaryStatusHandler checks the status of a summary generation request.
+// The summary is only returned to callers who may see the document.
 func GetSummaryStatusHandler(w http.ResponseWriter, r *http.Request) {
 	documentID := r.URL.Query().Get("document_id")
 	if documentID == "" {
@@ -106,8 +109,8 @@ func GetSummaryStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := models.GetFileByUUID(config.DB, documentID); err != nil {
-		http.Error(w, "Document not found", http.StatusNotFound)
+	userID, _, ok := authorizeFile(w, r, documentID, "summary")
+	if !ok {
 		return
 	}
 
@@ -116,6 +119,9 @@ func GetSummaryStatusHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to fetch summary status", http.StatusInternalServerError)
 		return
 	}
+	if state == "completed" {
+		recordAudit(r, userActor(userID), auditDocumentView, "summary", documentID, nil)
+	}
 
 	if state == "" {
 		w.Header().Set("Content-Type", "application/json")
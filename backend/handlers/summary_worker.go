@@ -8,6 +8,7 @@ import (
 
 	"backend/config"
 	"backend/models"
+	"backend/notifications"
 )
 
 // ProcessSummaryWorkerTask fetches a pending summary from DB, processes it, and updates DB
@@ -102,4 +103,16 @@ func processOneSummary(summaryRow *models.Summary) {
 	}
 
 	log.Printf("[WORKER] Successfully processed summary s_uuid=%s. Summary length: %d chars", summaryRow.SUUID, len(result.Summary))
+
+	// Files summarized without going through upload OCR get classified here.
+	classifyIfMissing(summaryRow.FUUID, result.ExtractedText)
+
+	if docFile.UUID != "" {
+		payload := notifications.SummaryReadyPayload{
+			FUUID: docFile.FUUID, FileName: docFile.FileName, Summary: result.Summary, Sensitivity: docFile.Sensitivity,
+		}
+		if _, err := notifications.EnqueueEvent(config.DB, notifications.KindSummaryReady, summaryRow.SUUID, docFile.UUID, payload); err != nil {
+			log.Printf("[WORKER] Failed to queue summary_ready notification: %v", err)
+		}
+	}
 }
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"backend/access"
+	"backend/config"
+	"backend/notifications"
+	"backend/trash"
+)
+
+const (
+	auditFileDelete  = "file.delete"
+	auditFileRestore = "file.restore"
+)
+
+var trashSvc *trash.Service
+
+// SetTrashService swaps the trash backend (tests use a memory store).
+func SetTrashService(s *trash.Service) {
+	trashSvc = s
+}
+
+// trashWindow is TRASH_RETENTION_DAYS, or trash.DefaultWindow when unset or
+// invalid.
+func trashWindow() time.Duration {
+	raw := os.Getenv("TRASH_RETENTION_DAYS")
+	if raw == "" {
+		return trash.DefaultWindow
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		log.Printf("[TRASH] Ignoring invalid TRASH_RETENTION_DAYS %q", raw)
+		return trash.DefaultWindow
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// InitTrashService backs the trash with the database and the storage
+// buckets retention uses, and tells uploaders when their files are deleted.
+func InitTrashService() {
+	s := trash.NewService(trash.NewPostgresStore(config.DB), supabaseRetentionStorage{}, trashWindow())
+	s.Notify = enqueueFileTrashed
+	SetTrashService(s)
+}
+
+// enqueueFileTrashed queues a file_trashed notification, in the background.
+func enqueueFileTrashed(e trash.Event) {
+	actor := e.File.DeletedByName
+	if actor == "" {
+		actor = "A colleague"
+	}
+	p := notifications.FileTrashedPayload{FUUID: e.File.FUUID, FileName: e.File.Name, Actor: actor}
+	if e.File.PurgeAt != nil {
+		p.PurgeAt = *e.File.PurgeAt
+	}
+	go func() {
+		key := fmt.Sprintf("%s:%d", e.File.FUUID, e.File.DeletedAt.Unix())
+		if _, err := notifications.EnqueueEvent(config.DB, notifications.KindFileTrashed, key, e.Recipient, p); err != nil {
+			log.Printf("[TRASH] Failed to queue notification for %s: %v", e.Recipient, err)
+		}
+	}()
+}
+
+// StartTrashPurgeJob permanently removes files whose restore window has
+// closed, every interval.
+func StartTrashPurgeJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := trashSvc.PurgeExpired()
+			if err != nil {
+				log.Printf("[TRASH] Purge failed: %v", err)
+				continue
+			}
+			for _, f := range res.Purged {
+				recordAudit(nil, systemActor, auditFilePurge, "file", f.FUUID, map[string]interface{}{
+					"f_name": f.Name, "deleted_at": f.DeletedAt, "deleted_by": f.DeletedBy, "reason": "trash",
+				})
+			}
+			for _, msg := range res.Errors {
+				log.Printf("[TRASH] %s", msg)
+			}
+			if len(res.Purged) > 0 || res.Skipped > 0 || len(res.Errors) > 0 {
+				log.Printf("[TRASH] Purged %d files, skipped %d, %d errors", len(res.Purged), res.Skipped, len(res.Errors))
+			}
+		}
+	}()
+}
+
+func writeTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+	case errors.Is(err, trash.ErrForbidden):
+		http.Error(w, `{"error":"only the uploader or a head of the file's department may delete or restore it"}`, http.StatusForbidden)
+	case errors.Is(err, trash.ErrExpired):
+		http.Error(w, `{"error":"the restore window for this file has passed"}`, http.StatusGone)
+	default:
+		log.Printf("[TRASH] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// trashUser authenticates the caller and loads them as an access viewer.
+func trashUser(w http.ResponseWriter, r *http.Request, methods string) (access.Viewer, bool) {
+	userID, ok := authenticatedUser(w, r, methods)
+	if !ok {
+		return access.Viewer{}, false
+	}
+	viewer, err := fileViewer(userID)
+	if err != nil {
+		http.Error(w, `{"error":"failed to load profile"}`, http.StatusInternalServerError)
+		return access.Viewer{}, false
+	}
+	return viewer, true
+}
+
+// ---------------------------------------------------------------------------
+// DELETE /v1/files/{id}
+// ---------------------------------------------------------------------------
+
+// FileHandler moves a file to the trash. The uploader and heads of the
+// file's departments may delete it; the stored object is kept until the
+// trash is purged.
+func FileHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := trashUser(w, r, "DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := trashSvc.Delete(viewer, r.PathValue("id"))
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+	recordAudit(r, userActor(viewer.UUID), auditFileDelete, "file", f.FUUID, map[string]interface{}{
+		"f_name": f.Name, "purge_at": f.PurgeAt,
+	})
+	writeJSON(w, http.StatusOK, f)
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/files/{id}/restore
+// ---------------------------------------------------------------------------
+
+// FileRestoreHandler takes a file out of the trash while its restore window
+// is open, and answers 410 once it has closed.
+func FileRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := trashUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := trashSvc.Restore(viewer, r.PathValue("id"))
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+	recordAudit(r, userActor(viewer.UUID), auditFileRestore, "file", f.FUUID, map[string]string{"f_name": f.Name})
+	writeJSON(w, http.StatusOK, f)
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/trash
+// ---------------------------------------------------------------------------
+
+// TrashHandler lists the trashed files the caller may restore: their own
+// uploads and, for department heads, files shared with their department.
+func TrashHandler(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := trashUser(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	files, err := trashSvc.List(viewer)
+	if err != nil {
+		writeTrashError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"files": files, "retention_days": int(trashSvc.Window / (24 * time.Hour)),
+	})
+}
@@ -14,11 +14,12 @@ import (
 
 	//used for linux(i'm on linux)
 	"path/filepath"
+	"strings"
 
+	"backend/access"
 	"backend/config"
 	"backend/models"
 	"backend/services"
-	"backend/utils"
 	"time"
 )
 
@@ -76,6 +77,29 @@ func UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Optional sensitivity level for every file in the upload.
+	sensitivity := strings.ToLower(strings.TrimSpace(r.FormValue("sensitivity")))
+	if sensitivity == "" {
+		sensitivity = access.Internal
+	}
+	if !access.ValidLevel(sensitivity) {
+		http.Error(w, "Invalid sensitivity: "+sensitivity, http.StatusBadRequest)
+		return
+	}
+
+	// Optional approval workflow started on every uploaded file.
+	workflowTemplateID := strings.TrimSpace(r.FormValue("workflow_template_id"))
+	if workflowTemplateID != "" {
+		if authErr != nil {
+			http.Error(w, "Approval workflows need a signed-in uploader", http.StatusUnauthorized)
+			return
+		}
+		if err := workflowSvc.CheckStartable(workflowTemplateID); err != nil {
+			writeWorkflowError(w, err)
+			return
+		}
+	}
+
 	var uploaded []models.Document
 	for _, f := range files {
 		file, err := f.Open()
@@ -104,12 +128,13 @@ func UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		language := r.FormValue("language")
 
 		doc := models.Document{
-			FileName: title, // use title if provided, else f.Filename
-			Language: language,
-			UUID:     userUUID,
-			FilePath: storagePath,
-			DUUID:    d_uuids_raw, // store all department UUIDs (optional, for reference)
-			Status:   "uploaded",
+			FileName:    title, // use title if provided, else f.Filename
+			Language:    language,
+			UUID:        userUUID,
+			FilePath:    storagePath,
+			DUUID:       d_uuids_raw, // store all department UUIDs (optional, for reference)
+			Status:      "uploaded",
+			Sensitivity: sensitivity,
 		}
 
 		fuuid, err := models.InsertDocument(config.DB, doc)
@@ -125,17 +150,27 @@ func UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		uploaded = append(uploaded, doc)
+		recordAudit(r, userActor(userUUID), auditDocumentUpload, "file", fuuid, map[string]interface{}{
+			"f_name":      doc.FileName,
+			"file_path":   storagePath,
+			"d_uuids":     d_uuids,
+			"size":        f.Size,
+			"sensitivity": sensitivity,
+		})
+		if workflowTemplateID != "" {
+			startUploadWorkflow(r, workflowTemplateID, fuuid, userUUID)
+		}
 
 		// Asynchronous OCR, summary, and notification trigger
-		go func(filePath, fuuid, ownerUUID, fileName, departmentsRaw string) {
+		go func(filePath, fuuid, ownerUUID, title, originalName string) {
 			log.Println("[DEBUG] Triggering OCR for:", filePath)
-			summaryText := ""
 			tmpPath := filepath.Join(os.TempDir(), filepath.Base(filePath))
 			err := config.Supabase.DownloadFile("file_storage", filePath, tmpPath)
 			if err != nil {
 				log.Println("[DEBUG] Download error:", err)
 			} else {
 				defer func() { _ = os.Remove(tmpPath) }()
+				generatePreviews(fuuid, filePath, tmpPath)
 				ocrText, avgConf, err := services.RunOCR(tmpPath)
 				if err != nil {
 					log.Println("[DEBUG] OCR error:", err)
@@ -151,7 +186,12 @@ func UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 						log.Println("[DEBUG] Failed to insert OCR result:", err)
 					}
 
-					summaryText, err = services.RunSummarizer(ocrText)
+					// Classify into the taxonomy, then share with any
+					// departments the routing rules pick out.
+					category := classifyUploadedDocument(fuuid, ocrText)
+					routeUploadedDocument(fuuid, title, originalName, ocrText, ownerUUID, category)
+
+					summaryText, err := services.RunSummarizer(ocrText)
 					if err != nil {
 						log.Println("[DEBUG] Summary error:", err)
 					} else {
@@ -177,25 +217,11 @@ func UploadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 				FUUID:  fuuid,
 				IsSeen: false,
 			}
+			// The notification dispatcher picks this row up and emails the owner.
 			if err := models.InsertNotification(config.DB, notif); err != nil {
 				log.Println("[DEBUG] Failed to insert notification:", err)
-			} else {
-				userEmail := ""
-				row := config.DB.QueryRow("SELECT email FROM users WHERE uuid = $1", ownerUUID)
-				_ = row.Scan(&userEmail)
-				if userEmail != "" {
-					subject := "New file uploaded: " + fileName
-					body := "A new file has been added to your account.\n\nFile: " + fileName + "\nDepartments: " + departmentsRaw + "\nSummary: " + summaryText
-					if err := utils.SendGmailNotification(userEmail, subject, body); err != nil {
-						log.Println("[DEBUG] Failed to send email notification:", err)
-					} else {
-						log.Println("[DEBUG] Email notification sent to:", userEmail)
-					}
-				} else {
-					log.Println("[DEBUG] No email found for user:", ownerUUID)
-				}
 			}
-		}(storagePath, fuuid, doc.UUID, doc.FileName, d_uuids_raw)
+		}(storagePath, fuuid, doc.UUID, doc.FileName, f.Filename)
 	}
 
 	if len(uploaded) == 0 {
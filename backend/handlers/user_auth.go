@@ -16,8 +16,30 @@ func AuthenticatedUserIDFromRequest(r *http.Request) (string, error) {
 	if !strings.HasPrefix(auth, "Bearer ") {
 		return "", fmt.Errorf("missing bearer token")
 	}
-	token := strings.TrimPrefix(auth, "Bearer ")
+	return authenticatedUserIDFromToken(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// authenticatedUser authenticates a user API request, answering CORS
+// preflight and auth failures itself. ok is false when the response has
+// been written.
+func authenticatedUser(w http.ResponseWriter, r *http.Request, methods string) (string, bool) {
+	if ApplyCORS(w, r, methods) {
+		return "", false
+	}
+	userID, err := AuthenticatedUserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return "", false
+	}
+	return userID, true
+}
 
+// authenticatedUserIDFromToken validates a Supabase access token directly,
+// for clients such as browser websockets that cannot send headers.
+func authenticatedUserIDFromToken(token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("missing bearer token")
+	}
 	if config.Supabase.URL == "" || config.Supabase.Key == "" {
 		return "", fmt.Errorf("supabase auth config missing")
 	}
@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Saga runner
+// ---------------------------------------------------------------------------
+
+// sagaStep is one forward action and the compensation that reverses it.
+type sagaStep struct {
+	Name string
+	Do   func() error
+	Undo func() error
+}
+
+// sagaError reports which step failed and whether every earlier step was undone.
+type sagaError struct {
+	Step        string
+	Err         error
+	Compensated bool
+}
+
+func (e *sagaError) Error() string {
+	state := "compensated"
+	if !e.Compensated {
+		state = "compensation incomplete"
+	}
+	return fmt.Sprintf("%s failed (%s): %v", e.Step, state, e.Err)
+}
+
+func (e *sagaError) Unwrap() error { return e.Err }
+
+// runSaga executes steps in order; on failure it undoes completed steps in reverse.
+func runSaga(steps []sagaStep) error {
+	for i, step := range steps {
+		err := step.Do()
+		if err == nil {
+			continue
+		}
+		compensated := true
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].Undo == nil {
+				continue
+			}
+			if uerr := steps[j].Undo(); uerr != nil {
+				compensated = false
+				log.Printf("[LIFECYCLE] Compensation for %q failed: %v", steps[j].Name, uerr)
+			}
+		}
+		return &sagaError{Step: step.Name, Err: err, Compensated: compensated}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Dependencies
+// ---------------------------------------------------------------------------
+
+type authUser struct {
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	BannedUntil string `json:"banned_until,omitempty"`
+}
+
+// banned reports whether the auth user is currently banned.
+func (u authUser) banned(now time.Time) bool {
+	if u.BannedUntil == "" {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339Nano, u.BannedUntil)
+	return err == nil && t.After(now)
+}
+
+// authAPIError carries a non-2xx Supabase Auth response so it can be forwarded.
+type authAPIError struct {
+	Status int
+	Body   []byte
+}
+
+func (e *authAPIError) Error() string {
+	return fmt.Sprintf("supabase auth returned %d: %s", e.Status, string(e.Body))
+}
+
+// authAdminAPI is the slice of the Supabase Auth Admin API the lifecycle uses.
+type authAdminAPI interface {
+	CreateUser(email, password string, metadata map[string]interface{}) (authUser, error)
+	DeleteUser(id string) error
+	SetBanned(id string, banned bool) error
+	ListUsers() ([]authUser, error)
+}
+
+// profileStore is the public.users side of the lifecycle.
+type profileStore interface {
+	ValidateAssignment(dUUID, rUUID string) error
+	Upsert(p models.UserProfile) error
+	Delete(uuid string) error
+	Get(uuid string) (*models.UserProfile, error)
+	List() ([]models.UserProfile, error)
+	OwnedFiles(uuid string) (int, error)
+	FindHead(dUUID, exclude string) (string, error)
+	Deactivate(uuid, reassignTo string) ([]string, error)
+	Reactivate(uuid string, movedFiles []string) error
+}
+
+type supabaseAuthAdmin struct{}
+
+func authResponseError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return &authAPIError{Status: resp.StatusCode, Body: body}
+}
+
+func (supabaseAuthAdmin) CreateUser(email, password string, metadata map[string]interface{}) (authUser, error) {
+	// email_confirm: false → Supabase marks the email as unverified; the
+	// confirmation email is triggered separately via /auth/v1/resend.
+	payload, _ := json.Marshal(map[string]interface{}{
+		"email":         email,
+		"password":      password,
+		"email_confirm": false,
+		"user_metadata": metadata,
+	})
+	resp, err := supabaseAuthRequest("POST", supabaseAuthURL(), bytes.NewReader(payload))
+	if err != nil {
+		return authUser{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return authUser{}, authResponseError(resp)
+	}
+	var u authUser
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return authUser{}, err
+	}
+	if u.ID == "" {
+		return authUser{}, errors.New("supabase auth returned no user id")
+	}
+	return u, nil
+}
+
+func (supabaseAuthAdmin) DeleteUser(id string) error {
+	resp, err := supabaseAuthRequest("DELETE", fmt.Sprintf("%s/%s", supabaseAuthURL(), id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return authResponseError(resp)
+	}
+	return nil
+}
+
+func (supabaseAuthAdmin) SetBanned(id string, banned bool) error {
+	duration := "none"
+	if banned {
+		duration = "876000h" // ~100 years; Supabase has no permanent ban flag
+	}
+	payload, _ := json.Marshal(map[string]string{"ban_duration": duration})
+	resp, err := supabaseAuthRequest("PUT", fmt.Sprintf("%s/%s", supabaseAuthURL(), id), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return authResponseError(resp)
+	}
+	return nil
+}
+
+func (supabaseAuthAdmin) ListUsers() ([]authUser, error) {
+	const perPage = 1000
+	var all []authUser
+	for page := 1; ; page++ {
+		resp, err := supabaseAuthRequest("GET", fmt.Sprintf("%s?page=%d&per_page=%d", supabaseAuthURL(), page, perPage), nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			err := authResponseError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var body struct {
+			Users []authUser `json:"users"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, body.Users...)
+		if len(body.Users) < perPage {
+			return all, nil
+		}
+	}
+}
+
+type pgProfileStore struct{}
+
+func (pgProfileStore) ValidateAssignment(dUUID, rUUID string) error {
+	if dUUID == "" {
+		if rUUID != "" {
+			return errors.New("a role requires a department")
+		}
+		return nil
+	}
+	dept, err := models.GetDepartmentByUUID(config.DB, dUUID)
+	if err != nil {
+		return err
+	}
+	if dept == nil {
+		return fmt.Errorf("department %s not found", dUUID)
+	}
+	if rUUID == "" {
+		return nil
+	}
+	role, err := models.GetRoleByUUID(config.DB, rUUID)
+	if err != nil {
+		return err
+	}
+	if role == nil || role.DUUID != dUUID {
+		return fmt.Errorf("role %s does not belong to department %s", rUUID, dept.DName)
+	}
+	return nil
+}
+
+func (pgProfileStore) Upsert(p models.UserProfile) error { return models.UpsertUserProfile(config.DB, p) }
+func (pgProfileStore) Delete(uuid string) error          { return models.DeleteUserProfile(config.DB, uuid) }
+func (pgProfileStore) Get(uuid string) (*models.UserProfile, error) {
+	return models.GetUserProfile(config.DB, uuid)
+}
+func (pgProfileStore) List() ([]models.UserProfile, error) { return models.ListUserProfiles(config.DB) }
+func (pgProfileStore) OwnedFiles(uuid string) (int, error) {
+	return models.CountOwnedFiles(config.DB, uuid)
+}
+func (pgProfileStore) FindHead(dUUID, exclude string) (string, error) {
+	return models.FindDepartmentHead(config.DB, dUUID, exclude)
+}
+func (pgProfileStore) Deactivate(uuid, reassignTo string) ([]string, error) {
+	return models.DeactivateUser(config.DB, uuid, reassignTo)
+}
+func (pgProfileStore) Reactivate(uuid string, movedFiles []string) error {
+	return models.ReactivateUser(config.DB, uuid, movedFiles)
+}
+
+// ---------------------------------------------------------------------------
+// Lifecycle service
+// ---------------------------------------------------------------------------
+
+// errNotFound is returned when the target record does not exist.
+var errNotFound = errors.New("not found")
+
+// errValidation marks input problems so handlers can answer 400 instead of 500.
+var errValidation = errors.New("validation failed")
+
+// errNoReassignTarget means the user owns files but nobody can take them over.
+var errNoReassignTarget = errors.New("user owns files and no reassignment target is available")
+
+type userLifecycle struct {
+	auth     authAdminAPI
+	profiles profileStore
+}
+
+var lifecycle = &userLifecycle{auth: supabaseAuthAdmin{}, profiles: pgProfileStore{}}
+
+type newUser struct {
+	Email    string
+	Password string
+	Profile  models.UserProfile
+	Metadata map[string]interface{}
+}
+
+// newUserFromMetadata maps the admin console's user_metadata onto a profile.
+func newUserFromMetadata(email, password string, metadata map[string]interface{}) newUser {
+	str := func(key string) string {
+		v, _ := metadata[key].(string)
+		return strings.TrimSpace(v)
+	}
+	p := models.UserProfile{
+		Email:       strings.TrimSpace(email),
+		Name:        str("name"),
+		PhoneNumber: str("phone_number"),
+		Address:     str("address"),
+		Position:    str("position"),
+		DUUID:       str("d_uuid"),
+		RUUID:       str("r_uuid"),
+	}
+	if p.Position == "" {
+		p.Position = "regular"
+	}
+	if p.Position == "head" {
+		p.RUUID = ""
+	}
+	return newUser{Email: p.Email, Password: password, Profile: p, Metadata: metadata}
+}
+
+// Create provisions the auth user and the profile row as one saga.
+func (l *userLifecycle) Create(nu newUser) (models.UserProfile, error) {
+	if nu.Email == "" || nu.Password == "" {
+		return nu.Profile, fmt.Errorf("%w: email and password are required", errValidation)
+	}
+	if len(nu.Password) < 6 {
+		return nu.Profile, fmt.Errorf("%w: password must be at least 6 characters", errValidation)
+	}
+	if nu.Profile.Position != "regular" && nu.Profile.Position != "head" {
+		return nu.Profile, fmt.Errorf("%w: position must be regular or head", errValidation)
+	}
+
+	profile := nu.Profile
+	err := runSaga([]sagaStep{
+		{
+			Name: "validate_assignment",
+			Do: func() error {
+				if err := l.profiles.ValidateAssignment(profile.DUUID, profile.RUUID); err != nil {
+					return fmt.Errorf("%w: %v", errValidation, err)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "auth_user",
+			Do: func() error {
+				u, err := l.auth.CreateUser(nu.Email, nu.Password, nu.Metadata)
+				if err != nil {
+					return err
+				}
+				profile.UUID = u.ID
+				return nil
+			},
+			Undo: func() error { return l.auth.DeleteUser(profile.UUID) },
+		},
+		{
+			Name: "profile",
+			Do:   func() error { return l.profiles.Upsert(profile) },
+			Undo: func() error { return l.profiles.Delete(profile.UUID) },
+		},
+	})
+	profile.IsActive = err == nil
+	return profile, err
+}
+
+type deactivationResult struct {
+	UUID            string `json:"uuid"`
+	FilesReassigned int    `json:"files_reassigned"`
+	ReassignedTo    string `json:"reassigned_to,omitempty"`
+	AlreadyInactive bool   `json:"already_inactive,omitempty"`
+}
+
+// Deactivate soft-deletes a user: the profile is kept but marked inactive,
+// owned files move to reassignTo (or the department head), and the auth user
+// is banned so they can no longer sign in.
+func (l *userLifecycle) Deactivate(uuid, reassignTo string) (deactivationResult, error) {
+	res := deactivationResult{UUID: uuid}
+	profile, err := l.profiles.Get(uuid)
+	if err != nil {
+		return res, err
+	}
+	if profile == nil {
+		return res, errNotFound
+	}
+	if !profile.IsActive {
+		res.AlreadyInactive = true
+		return res, nil
+	}
+
+	owned, err := l.profiles.OwnedFiles(uuid)
+	if err != nil {
+		return res, err
+	}
+	if reassignTo == uuid {
+		return res, fmt.Errorf("%w: cannot reassign files to the user being deactivated", errValidation)
+	}
+	if reassignTo != "" {
+		target, err := l.profiles.Get(reassignTo)
+		if err != nil {
+			return res, err
+		}
+		if target == nil || !target.IsActive {
+			return res, fmt.Errorf("%w: reassignment target must be an active user", errValidation)
+		}
+	} else if owned > 0 && profile.DUUID != "" {
+		if reassignTo, err = l.profiles.FindHead(profile.DUUID, uuid); err != nil {
+			return res, err
+		}
+	}
+	if owned > 0 && reassignTo == "" {
+		return res, errNoReassignTarget
+	}
+
+	var moved []string
+	err = runSaga([]sagaStep{
+		{
+			Name: "deactivate_profile",
+			Do: func() error {
+				var err error
+				moved, err = l.profiles.Deactivate(uuid, reassignTo)
+				return err
+			},
+			Undo: func() error { return l.profiles.Reactivate(uuid, moved) },
+		},
+		{
+			Name: "ban_auth_user",
+			Do:   func() error { return l.auth.SetBanned(uuid, true) },
+		},
+	})
+	if err != nil {
+		return res, err
+	}
+	res.FilesReassigned = len(moved)
+	if len(moved) > 0 {
+		res.ReassignedTo = reassignTo
+	}
+	return res, nil
+}
+
+// ---------------------------------------------------------------------------
+// Reconciliation between auth.users and public.users
+// ---------------------------------------------------------------------------
+
+type userDrift struct {
+	UUID   string `json:"uuid"`
+	Email  string `json:"email,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// diffUsers compares both sides and reports every mismatch.
+func diffUsers(authUsers []authUser, profiles []models.UserProfile, now time.Time) []userDrift {
+	drift := []userDrift{}
+	byID := make(map[string]models.UserProfile, len(profiles))
+	for _, p := range profiles {
+		byID[p.UUID] = p
+	}
+	seen := make(map[string]bool, len(authUsers))
+	for _, au := range authUsers {
+		seen[au.ID] = true
+		p, ok := byID[au.ID]
+		if !ok {
+			drift = append(drift, userDrift{UUID: au.ID, Email: au.Email, Kind: "auth_only"})
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(p.Email), strings.TrimSpace(au.Email)) {
+			drift = append(drift, userDrift{UUID: au.ID, Email: au.Email, Kind: "email_mismatch",
+				Detail: fmt.Sprintf("auth=%s profile=%s", au.Email, p.Email)})
+		}
+		switch {
+		case !p.IsActive && !au.banned(now):
+			drift = append(drift, userDrift{UUID: au.ID, Email: au.Email, Kind: "inactive_not_banned"})
+		case p.IsActive && au.banned(now):
+			drift = append(drift, userDrift{UUID: au.ID, Email: au.Email, Kind: "banned_but_active"})
+		}
+	}
+	for _, p := range profiles {
+		if !seen[p.UUID] {
+			drift = append(drift, userDrift{UUID: p.UUID, Email: p.Email, Kind: "profile_only"})
+		}
+	}
+	return drift
+}
+
+func (l *userLifecycle) Reconcile() ([]userDrift, error) {
+	authUsers, err := l.auth.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("listing auth users: %w", err)
+	}
+	profiles, err := l.profiles.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing profiles: %w", err)
+	}
+	return diffUsers(authUsers, profiles, time.Now()), nil
+}
+
+// StartUserReconciliationJob logs auth/profile drift on an interval.
+func StartUserReconciliationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			drift, err := lifecycle.Reconcile()
+			if err != nil {
+				log.Printf("[LIFECYCLE] Reconciliation failed: %v", err)
+				continue
+			}
+			if len(drift) == 0 {
+				continue
+			}
+			counts := map[string]int{}
+			for _, d := range drift {
+				counts[d.Kind]++
+			}
+			log.Printf("[LIFECYCLE] Drift between auth.users and public.users: %v", counts)
+		}
+	}()
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/admin/users/reconcile — report drift on demand
+// ---------------------------------------------------------------------------
+
+func AdminReconcileUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	drift, err := lifecycle.Reconcile()
+	if err != nil {
+		log.Printf("[LIFECYCLE] Reconciliation failed: %v", err)
+		http.Error(w, `{"error":"failed to reconcile users"}`, http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"checked_at": time.Now().UTC().Format(time.RFC3339),
+		"drift":      drift,
+	})
+}
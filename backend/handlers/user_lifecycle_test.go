@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+type fakeAuthAdmin struct {
+	users     map[string]authUser
+	createErr error
+	banErr    error
+	banned    map[string]bool
+	nextID    string
+}
+
+func newFakeAuthAdmin() *fakeAuthAdmin {
+	return &fakeAuthAdmin{users: map[string]authUser{}, banned: map[string]bool{}, nextID: "u-new"}
+}
+
+func (f *fakeAuthAdmin) CreateUser(email, password string, metadata map[string]interface{}) (authUser, error) {
+	if f.createErr != nil {
+		return authUser{}, f.createErr
+	}
+	u := authUser{ID: f.nextID, Email: email}
+	f.users[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeAuthAdmin) DeleteUser(id string) error {
+	delete(f.users, id)
+	return nil
+}
+
+func (f *fakeAuthAdmin) SetBanned(id string, banned bool) error {
+	if f.banErr != nil {
+		return f.banErr
+	}
+	f.banned[id] = banned
+	return nil
+}
+
+func (f *fakeAuthAdmin) ListUsers() ([]authUser, error) {
+	var out []authUser
+	for _, u := range f.users {
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+type fakeProfileStore struct {
+	profiles  map[string]models.UserProfile
+	owners    map[string]string // f_uuid -> owner uuid
+	upsertErr error
+}
+
+func newFakeProfileStore() *fakeProfileStore {
+	return &fakeProfileStore{profiles: map[string]models.UserProfile{}, owners: map[string]string{}}
+}
+
+func (f *fakeProfileStore) ValidateAssignment(dUUID, rUUID string) error {
+	if dUUID == "missing" {
+		return errors.New("department missing not found")
+	}
+	return nil
+}
+
+func (f *fakeProfileStore) Upsert(p models.UserProfile) error {
+	if f.upsertErr != nil {
+		return f.upsertErr
+	}
+	p.IsActive = true
+	f.profiles[p.UUID] = p
+	return nil
+}
+
+func (f *fakeProfileStore) Delete(uuid string) error {
+	delete(f.profiles, uuid)
+	return nil
+}
+
+func (f *fakeProfileStore) Get(uuid string) (*models.UserProfile, error) {
+	p, ok := f.profiles[uuid]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (f *fakeProfileStore) List() ([]models.UserProfile, error) {
+	var out []models.UserProfile
+	for _, p := range f.profiles {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (f *fakeProfileStore) OwnedFiles(uuid string) (int, error) {
+	n := 0
+	for _, owner := range f.owners {
+		if owner == uuid {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeProfileStore) FindHead(dUUID, exclude string) (string, error) {
+	for _, p := range f.profiles {
+		if p.DUUID == dUUID && p.Position == "head" && p.UUID != exclude && p.IsActive {
+			return p.UUID, nil
+		}
+	}
+	return "", nil
+}
+
+func (f *fakeProfileStore) Deactivate(uuid, reassignTo string) ([]string, error) {
+	p := f.profiles[uuid]
+	p.IsActive = false
+	f.profiles[uuid] = p
+	var moved []string
+	for fuuid, owner := range f.owners {
+		if owner == uuid && reassignTo != "" {
+			f.owners[fuuid] = reassignTo
+			moved = append(moved, fuuid)
+		}
+	}
+	return moved, nil
+}
+
+func (f *fakeProfileStore) Reactivate(uuid string, movedFiles []string) error {
+	p := f.profiles[uuid]
+	p.IsActive = true
+	f.profiles[uuid] = p
+	for _, fuuid := range movedFiles {
+		f.owners[fuuid] = uuid
+	}
+	return nil
+}
+
+func TestUserLifecycleCreate_CompensatesAuthUserWhenProfileFails(t *testing.T) {
+	auth, profiles := newFakeAuthAdmin(), newFakeProfileStore()
+	profiles.upsertErr = errors.New("users insert failed")
+	l := &userLifecycle{auth: auth, profiles: profiles}
+
+	_, err := l.Create(newUserFromMetadata("a@kmrl.in", "secret1", map[string]interface{}{"d_uuid": "d-1"}))
+	var sagaErr *sagaError
+	if !errors.As(err, &sagaErr) || sagaErr.Step != "profile" || !sagaErr.Compensated {
+		t.Fatalf("expected compensated profile failure, got %v", err)
+	}
+	if len(auth.users) != 0 {
+		t.Fatalf("expected auth user to be rolled back, still have %v", auth.users)
+	}
+}
+
+func TestUserLifecycleCreate_ValidationStopsBeforeAuth(t *testing.T) {
+	auth, profiles := newFakeAuthAdmin(), newFakeProfileStore()
+	l := &userLifecycle{auth: auth, profiles: profiles}
+
+	_, err := l.Create(newUserFromMetadata("a@kmrl.in", "secret1", map[string]interface{}{"d_uuid": "missing"}))
+	if !errors.Is(err, errValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if len(auth.users) != 0 {
+		t.Fatal("auth user must not be created when validation fails")
+	}
+}
+
+func TestUserLifecycleCreate_Succeeds(t *testing.T) {
+	auth, profiles := newFakeAuthAdmin(), newFakeProfileStore()
+	l := &userLifecycle{auth: auth, profiles: profiles}
+
+	p, err := l.Create(newUserFromMetadata("a@kmrl.in", "secret1", map[string]interface{}{
+		"name": "Asha", "d_uuid": "d-1", "r_uuid": "r-1", "position": "head",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.UUID != "u-new" || p.RUUID != "" || !p.IsActive {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+	if _, ok := profiles.profiles["u-new"]; !ok {
+		t.Fatal("expected profile row to be written")
+	}
+}
+
+func TestUserLifecycleDeactivate_ReassignsToHeadAndUndoesOnBanFailure(t *testing.T) {
+	auth, profiles := newFakeAuthAdmin(), newFakeProfileStore()
+	profiles.profiles["u-1"] = models.UserProfile{UUID: "u-1", DUUID: "d-1", Position: "regular", IsActive: true}
+	profiles.profiles["h-1"] = models.UserProfile{UUID: "h-1", DUUID: "d-1", Position: "head", IsActive: true}
+	profiles.owners["f-1"] = "u-1"
+	l := &userLifecycle{auth: auth, profiles: profiles}
+
+	auth.banErr = errors.New("auth down")
+	if _, err := l.Deactivate("u-1", ""); err == nil {
+		t.Fatal("expected ban failure")
+	}
+	if !profiles.profiles["u-1"].IsActive || profiles.owners["f-1"] != "u-1" {
+		t.Fatal("expected deactivation to be compensated")
+	}
+
+	auth.banErr = nil
+	res, err := l.Deactivate("u-1", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ReassignedTo != "h-1" || res.FilesReassigned != 1 || !auth.banned["u-1"] {
+		t.Fatalf("unexpected result: %+v banned=%v", res, auth.banned)
+	}
+}
+
+func TestUserLifecycleDeactivate_NoTarget(t *testing.T) {
+	auth, profiles := newFakeAuthAdmin(), newFakeProfileStore()
+	profiles.profiles["u-1"] = models.UserProfile{UUID: "u-1", DUUID: "d-1", IsActive: true}
+	profiles.owners["f-1"] = "u-1"
+	l := &userLifecycle{auth: auth, profiles: profiles}
+
+	if _, err := l.Deactivate("u-1", ""); !errors.Is(err, errNoReassignTarget) {
+		t.Fatalf("expected errNoReassignTarget, got %v", err)
+	}
+}
+
+func TestDiffUsers(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour).Format(time.RFC3339Nano)
+	authUsers := []authUser{
+		{ID: "ok", Email: "ok@kmrl.in"},
+		{ID: "orphan-auth", Email: "x@kmrl.in"},
+		{ID: "mail", Email: "new@kmrl.in"},
+		{ID: "gone", Email: "gone@kmrl.in"},
+		{ID: "banned", Email: "b@kmrl.in", BannedUntil: future},
+	}
+	profiles := []models.UserProfile{
+		{UUID: "ok", Email: "OK@kmrl.in", IsActive: true},
+		{UUID: "mail", Email: "old@kmrl.in", IsActive: true},
+		{UUID: "gone", Email: "gone@kmrl.in", IsActive: false},
+		{UUID: "banned", Email: "b@kmrl.in", IsActive: true},
+		{UUID: "orphan-profile", Email: "p@kmrl.in", IsActive: true},
+	}
+
+	kinds := map[string]string{}
+	for _, d := range diffUsers(authUsers, profiles, now) {
+		kinds[d.UUID] = d.Kind
+	}
+	want := map[string]string{
+		"orphan-auth":    "auth_only",
+		"mail":           "email_mismatch",
+		"gone":           "inactive_not_banned",
+		"banned":         "banned_but_active",
+		"orphan-profile": "profile_only",
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("unexpected drift: %v", kinds)
+	}
+	for id, kind := range want {
+		if kinds[id] != kind {
+			t.Fatalf("%s: expected %s, got %s", id, kind, kinds[id])
+		}
+	}
+}
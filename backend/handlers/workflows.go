@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/config"
+	"backend/notifications"
+	"backend/workflows"
+)
+
+const (
+	auditWorkflowTemplateCreate = "workflow_template.create"
+	auditWorkflowTemplateUpdate = "workflow_template.update"
+	auditWorkflowTemplateDelete = "workflow_template.delete"
+	auditWorkflowStart          = "workflow.start"
+	auditWorkflowApprove        = "workflow.approve"
+	auditWorkflowReject         = "workflow.reject"
+	auditWorkflowOverdue        = "workflow.overdue"
+)
+
+var workflowSvc *workflows.Service
+
+// SetWorkflowService swaps the workflow backend (tests use a memory store).
+func SetWorkflowService(s *workflows.Service) {
+	workflowSvc = s
+}
+
+// InitWorkflowService backs approval workflows with the database and sends
+// their transitions through the notification outbox.
+func InitWorkflowService() {
+	s := workflows.NewService(workflows.NewPostgresStore(config.DB))
+	s.Notify = enqueueWorkflowEvent
+	SetWorkflowService(s)
+}
+
+// enqueueWorkflowEvent queues one workflow_event per recipient, in the
+// background.
+func enqueueWorkflowEvent(e workflows.Event) {
+	p := notifications.WorkflowEventPayload{
+		WorkflowID: e.Instance.ID, TemplateName: e.Instance.TemplateName, Event: e.Kind, Actor: e.Actor,
+		FUUID: e.Instance.FUUID, FileName: e.Instance.FileName, Sensitivity: e.Sensitivity, Comment: e.Comment,
+	}
+	step := -1
+	if e.Step != nil {
+		p.Step, p.DueAt, step = e.Step.Name, e.Step.DueAt, e.Step.Position
+	}
+	go func() {
+		for _, uid := range e.Recipients {
+			key := fmt.Sprintf("%s:%s:%d:%s", e.Instance.ID, e.Kind, step, uid)
+			if _, err := notifications.EnqueueEvent(config.DB, notifications.KindWorkflowEvent, key, uid, p); err != nil {
+				log.Printf("[WORKFLOW] Failed to queue %s notification for %s: %v", e.Kind, uid, err)
+			}
+		}
+	}()
+}
+
+func writeWorkflowError(w http.ResponseWriter, err error) {
+	var invalid workflows.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, workflows.ErrNotFound):
+		http.Error(w, `{"error":"workflow not found"}`, http.StatusNotFound)
+	case errors.Is(err, workflows.ErrTemplateNotFound):
+		http.Error(w, `{"error":"workflow template not found"}`, http.StatusNotFound)
+	case errors.Is(err, workflows.ErrNotPending):
+		http.Error(w, `{"error":"nothing in this workflow is waiting for your decision"}`, http.StatusForbidden)
+	case errors.Is(err, workflows.ErrForbidden):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	case errors.Is(err, workflows.ErrClosed):
+		http.Error(w, `{"error":"workflow is already closed"}`, http.StatusConflict)
+	case errors.Is(err, workflows.ErrConflict):
+		http.Error(w, `{"error":"workflow was updated at the same time; try again"}`, http.StatusConflict)
+	default:
+		log.Printf("[WORKFLOW] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// startUploadWorkflow runs templateID on a freshly uploaded file. Failures
+// are logged; the upload itself has already succeeded.
+func startUploadWorkflow(r *http.Request, templateID, fuuid, userID string) {
+	in, err := workflowSvc.Start(templateID, fuuid, userID)
+	if err != nil {
+		log.Printf("[WORKFLOW] Failed to start %s on %s: %v", templateID, fuuid, err)
+		return
+	}
+	recordAudit(r, userActor(userID), auditWorkflowStart, "workflow", in.ID, map[string]interface{}{
+		"f_uuid": fuuid, "template_id": templateID, "template_name": in.TemplateName,
+	})
+}
+
+// StartWorkflowSLAJob flags pending steps past their SLA every interval,
+// notifying their approvers and the uploader once per step.
+func StartWorkflowSLAJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			late, err := workflowSvc.CheckOverdue()
+			for _, t := range late {
+				recordAudit(nil, systemActor, auditWorkflowOverdue, "workflow", t.WorkflowID, t)
+			}
+			if err != nil {
+				log.Printf("[WORKFLOW] SLA check failed: %v", err)
+				continue
+			}
+			if len(late) > 0 {
+				log.Printf("[WORKFLOW] %d step(s) past their SLA", len(late))
+			}
+		}
+	}()
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/workflow-templates — GET list, POST create
+// ---------------------------------------------------------------------------
+
+// workflowTemplateReq is the body of create and update. Active defaults to
+// true.
+type workflowTemplateReq struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Active      *bool                    `json:"active"`
+	Steps       []workflows.StepTemplate `json:"steps"`
+}
+
+func (req workflowTemplateReq) template() workflows.Template {
+	t := workflows.Template{Name: req.Name, Description: req.Description, Active: true, Steps: req.Steps}
+	if req.Active != nil {
+		t.Active = *req.Active
+	}
+	return t
+}
+
+func AdminWorkflowTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := workflowSvc.Templates()
+		if err != nil {
+			writeWorkflowError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		var req workflowTemplateReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		actor := adminActor(r)
+		t, err := workflowSvc.CreateTemplate(req.template(), actor.ID)
+		if err != nil {
+			writeWorkflowError(w, err)
+			return
+		}
+		recordAudit(r, actor, auditWorkflowTemplateCreate, "workflow_template", t.ID, t)
+		writeJSON(w, http.StatusCreated, t)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /v1/admin/workflow-templates/{id} — PUT replace, DELETE
+// ---------------------------------------------------------------------------
+
+func AdminWorkflowTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPut:
+		var req workflowTemplateReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		before, after, err := workflowSvc.UpdateTemplate(id, req.template())
+		if err != nil {
+			writeWorkflowError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditWorkflowTemplateUpdate, "workflow_template", id, map[string]interface{}{
+			"from": before, "to": after,
+		})
+		writeJSON(w, http.StatusOK, after)
+
+	case http.MethodDelete:
+		if err := workflowSvc.DeleteTemplate(id); err != nil {
+			writeWorkflowError(w, err)
+			return
+		}
+		recordAudit(r, adminActor(r), auditWorkflowTemplateDelete, "workflow_template", id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/workflow-templates
+// ---------------------------------------------------------------------------
+
+// WorkflowTemplatesHandler lists the active templates an upload may start.
+func WorkflowTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedUser(w, r, "GET, OPTIONS"); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list, err := workflowSvc.ActiveTemplates()
+	if err != nil {
+		writeWorkflowError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"templates": list})
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/workflows
+// ---------------------------------------------------------------------------
+
+// WorkflowsHandler lists the workflows waiting for the caller's decision
+// (?view=pending, the default), those they started (?view=started) or a
+// file's (?f_uuid=).
+func WorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	var list []workflows.Instance
+	var err error
+	switch {
+	case q.Get("f_uuid") != "":
+		list, err = workflowSvc.ForFile(userID, q.Get("f_uuid"))
+	case q.Get("view") == "started":
+		list, err = workflowSvc.Started(userID)
+	case q.Get("view") == "" || q.Get("view") == "pending":
+		list, err = workflowSvc.Pending(userID)
+	default:
+		http.Error(w, `{"error":"view must be pending or started"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeWorkflowError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"workflows": list})
+}
+
+// ---------------------------------------------------------------------------
+// GET /v1/workflows/{id}
+// ---------------------------------------------------------------------------
+
+func WorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	in, err := workflowSvc.Get(userID, r.PathValue("id"))
+	if err != nil {
+		writeWorkflowError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, in)
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/workflows/{id}/approve, POST /v1/workflows/{id}/reject
+// ---------------------------------------------------------------------------
+
+// workflowDecisionReq: Step picks one of several pending steps the caller
+// may decide; by default the first is used.
+type workflowDecisionReq struct {
+	Comment string `json:"comment"`
+	Step    *int   `json:"step"`
+}
+
+// WorkflowApproveHandler signs off the caller's pending step.
+func WorkflowApproveHandler(w http.ResponseWriter, r *http.Request) {
+	workflowDecision(w, r, true)
+}
+
+// WorkflowRejectHandler rejects the workflow; a comment is required.
+func WorkflowRejectHandler(w http.ResponseWriter, r *http.Request) {
+	workflowDecision(w, r, false)
+}
+
+func workflowDecision(w http.ResponseWriter, r *http.Request, approve bool) {
+	userID, ok := authenticatedUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req workflowDecisionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	decide, action := workflowSvc.Approve, auditWorkflowApprove
+	if !approve {
+		decide, action = workflowSvc.Reject, auditWorkflowReject
+	}
+	in, transitions, err := decide(userID, r.PathValue("id"), req.Step, req.Comment)
+	if err != nil {
+		writeWorkflowError(w, err)
+		return
+	}
+	recordAudit(r, userActor(userID), action, "workflow", in.ID, map[string]interface{}{
+		"f_uuid": in.FUUID, "comment": req.Comment, "transitions": transitions,
+	})
+	writeJSON(w, http.StatusOK, in)
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"backend/config"
+	"backend/notifications"
+	"backend/workspaces"
+)
+
+const (
+	auditWorkspaceCreate     = "workspace.create"
+	auditWorkspaceDelete     = "workspace.delete"
+	auditWorkspaceInvite     = "workspace.invite"
+	auditWorkspaceJoin       = "workspace.join"
+	auditWorkspaceDeptRemove = "workspace.department_remove"
+	auditWorkspaceFileLink   = "workspace.file_link"
+	auditWorkspaceFileUnlink = "workspace.file_unlink"
+)
+
+var workspaceSvc *workspaces.Service
+
+// SetWorkspaceService swaps the workspace backend (tests use a memory store).
+func SetWorkspaceService(s *workspaces.Service) {
+	workspaceSvc = s
+}
+
+// InitWorkspaceService backs workspaces with the database and sends their
+// events through the notification outbox.
+func InitWorkspaceService() {
+	s := workspaces.NewService(workspaces.NewPostgresStore(config.DB))
+	s.Notify = enqueueWorkspaceEvent
+	SetWorkspaceService(s)
+}
+
+// enqueueWorkspaceEvent queues one workspace_event per recipient, in the
+// background so large departments do not hold up the request.
+func enqueueWorkspaceEvent(e workspaces.Event) {
+	actor := e.Actor.Name
+	if actor == "" {
+		actor = "A colleague"
+	}
+	p := notifications.WorkspaceEventPayload{
+		WorkspaceID: e.Workspace.ID, WorkspaceName: e.Workspace.Name, Event: e.Kind, Actor: actor,
+	}
+	subject := e.Workspace.ID
+	if e.Department != nil {
+		p.Department, subject = e.Department.Name, e.Department.DUUID
+	}
+	if e.File != nil {
+		p.FUUID, p.FileName, p.Sensitivity, subject = e.File.FUUID, e.File.FileName, e.File.Sensitivity, e.File.FUUID
+	}
+	if e.Comment != nil {
+		p.Comment, subject = e.Comment.Body, e.Comment.ID
+	}
+	go func() {
+		for _, uid := range e.Recipients {
+			key := fmt.Sprintf("%s:%s:%s:%s", e.Workspace.ID, e.Kind, subject, uid)
+			if _, err := notifications.EnqueueEvent(config.DB, notifications.KindWorkspaceEvent, key, uid, p); err != nil {
+				log.Printf("[WORKSPACE] Failed to queue %s notification for %s: %v", e.Kind, uid, err)
+			}
+		}
+	}()
+}
+
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	var invalid workspaces.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Msg})
+	case errors.Is(err, workspaces.ErrNotFound):
+		http.Error(w, `{"error":"workspace not found"}`, http.StatusNotFound)
+	case errors.Is(err, workspaces.ErrFileNotFound):
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+	case errors.Is(err, workspaces.ErrCommentNotFound):
+		http.Error(w, `{"error":"comment not found"}`, http.StatusNotFound)
+	case errors.Is(err, workspaces.ErrLinkNotAllowed):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, workspaces.ErrForbidden), errors.Is(err, workspaces.ErrNoAccess):
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	default:
+		log.Printf("[WORKSPACE] Request failed: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/workspaces
+// ---------------------------------------------------------------------------
+
+// WorkspacesHandler lists the workspaces of the caller's department (and,
+// for heads, pending invitations) or creates one.
+func WorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := workspaceSvc.List(userID)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"workspaces": list})
+
+	case http.MethodPost:
+		var d workspaces.Draft
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		ws, err := workspaceSvc.Create(userID, d)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		recordAudit(r, userActor(userID), auditWorkspaceCreate, "workspace", ws.ID, map[string]string{"name": ws.Name})
+		writeJSON(w, http.StatusCreated, ws)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET/PATCH/DELETE /v1/workspaces/{id}
+// ---------------------------------------------------------------------------
+
+func WorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, PATCH, DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		ws, err := workspaceSvc.Get(userID, id)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ws)
+
+	case http.MethodPatch:
+		var p workspaces.Patch
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		ws, err := workspaceSvc.Update(userID, id, p)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ws)
+
+	case http.MethodDelete:
+		if err := workspaceSvc.Delete(userID, id); err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		recordAudit(r, userActor(userID), auditWorkspaceDelete, "workspace", id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /v1/workspaces/{id}/departments — invite {d_uuid}
+// DELETE /v1/workspaces/{id}/departments/{deptId} — remove, leave or decline
+// POST /v1/workspaces/{id}/join — accept for the caller's department
+// ---------------------------------------------------------------------------
+
+func WorkspaceDepartmentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		DUUID string `json:"d_uuid"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if !uuidRegex.MatchString(req.DUUID) {
+		http.Error(w, `{"error":"d_uuid must be a department id"}`, http.StatusBadRequest)
+		return
+	}
+	id := r.PathValue("id")
+	ws, err := workspaceSvc.Invite(userID, id, req.DUUID)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	recordAudit(r, userActor(userID), auditWorkspaceInvite, "workspace", id, map[string]string{"d_uuid": req.DUUID})
+	writeJSON(w, http.StatusOK, ws)
+}
+
+func WorkspaceDepartmentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, dUUID := r.PathValue("id"), r.PathValue("deptId")
+	if err := workspaceSvc.RemoveDepartment(userID, id, dUUID); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	recordAudit(r, userActor(userID), auditWorkspaceDeptRemove, "workspace", id, map[string]string{"d_uuid": dUUID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func WorkspaceJoinHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "POST, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	ws, err := workspaceSvc.Join(userID, id)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	recordAudit(r, userActor(userID), auditWorkspaceJoin, "workspace", id, nil)
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/workspaces/{id}/files
+// DELETE /v1/workspaces/{id}/files/{fileId}
+// ---------------------------------------------------------------------------
+
+// WorkspaceFilesHandler lists the linked files the caller may see or links
+// one ({f_uuid}). Linking shares the file with every joined department.
+func WorkspaceFilesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		files, err := workspaceSvc.Files(userID, id)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+
+	case http.MethodPost:
+		var req struct {
+			FUUID string `json:"f_uuid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		f, err := workspaceSvc.LinkFile(userID, id, req.FUUID)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		recordAudit(r, userActor(userID), auditWorkspaceFileLink, "workspace", id, map[string]string{"f_uuid": f.FUUID})
+		writeJSON(w, http.StatusCreated, f)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func WorkspaceFileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, fuuid := r.PathValue("id"), r.PathValue("fileId")
+	if err := workspaceSvc.UnlinkFile(userID, id, fuuid); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	recordAudit(r, userActor(userID), auditWorkspaceFileUnlink, "workspace", id, map[string]string{"f_uuid": fuuid})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------------------------------------------------------------
+// GET/POST /v1/workspaces/{id}/comments
+// DELETE /v1/workspaces/{id}/comments/{commentId}
+// ---------------------------------------------------------------------------
+
+func WorkspaceCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "GET, POST, OPTIONS")
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := workspaceSvc.Comments(userID, id)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"comments": list})
+
+	case http.MethodPost:
+		var req struct {
+			Body  string `json:"body"`
+			FUUID string `json:"f_uuid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+			return
+		}
+		c, err := workspaceSvc.AddComment(userID, id, req.Body, req.FUUID)
+		if err != nil {
+			writeWorkspaceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, c)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func WorkspaceCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r, "DELETE, OPTIONS")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := workspaceSvc.DeleteComment(userID, r.PathValue("id"), r.PathValue("commentId")); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv builds a sender from the environment:
+//
+//	MAIL_MODE            smtp (default), memory or file
+//	MAIL_SINK_DIR        directory for file mode (default ./mail-sink)
+//	SMTP_HOST/PORT       default smtp.gmail.com:587
+//	SMTP_USERNAME/PASSWORD/FROM   falling back to GMAIL_ADDRESS/GMAIL_APP_PASSWORD
+//	SMTP_TLS             starttls, implicit or none (default implicit on 465)
+//	SMTP_BOUNCE_ADDRESS  envelope sender for bounces (default From)
+//	SMTP_POOL_SIZE       idle connections kept open (default 2)
+//	SMTP_RATE_PER_MINUTE messages per minute, 0 = unlimited (default 60)
+func FromEnv() Sender {
+	cfg := SMTPConfig{
+		Host:          env("SMTP_HOST", "smtp.gmail.com"),
+		Port:          env("SMTP_PORT", "587"),
+		Username:      env("SMTP_USERNAME", env("GMAIL_ADDRESS", "")),
+		Password:      env("SMTP_PASSWORD", env("GMAIL_APP_PASSWORD", "")),
+		TLSMode:       strings.ToLower(env("SMTP_TLS", "")),
+		BounceAddress: env("SMTP_BOUNCE_ADDRESS", ""),
+		PoolSize:      envInt("SMTP_POOL_SIZE", 2),
+		RatePerMinute: envInt("SMTP_RATE_PER_MINUTE", 60),
+		Domain:        env("MAIL_DOMAIN", ""),
+	}
+	cfg.From = env("SMTP_FROM", cfg.Username)
+
+	switch mode := strings.ToLower(env("MAIL_MODE", "smtp")); mode {
+	case "memory":
+		log.Println("[MAIL] Capturing outgoing mail in memory")
+		return NewMemorySink(cfg.From)
+	case "file":
+		dir := env("MAIL_SINK_DIR", "mail-sink")
+		log.Printf("[MAIL] Writing outgoing mail to %s", dir)
+		return FileSink{Dir: dir, From: cfg.From}
+	case "smtp":
+	default:
+		log.Printf("[MAIL] Unknown MAIL_MODE %q, using smtp", mode)
+	}
+	if cfg.From == "" || (cfg.Username != "" && cfg.Password == "") {
+		log.Println("[MAIL] SMTP credentials are not fully configured; sends will fail")
+		return unconfigured{}
+	}
+	return NewSMTPSender(cfg)
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,186 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal plaintext SMTP server that records what it receives.
+type fakeSMTP struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns int
+	mails []string // MAIL FROM arguments
+	data  []string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeSMTP) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(s string) { c.Write([]byte(s + "\r\n")) }
+	reply("220 fake ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			f.mu.Lock()
+			f.mails = append(f.mails, strings.TrimSpace(line[len("MAIL FROM:"):]))
+			f.mu.Unlock()
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			if strings.Contains(cmd, "NOBODY@") {
+				reply("550 no such user")
+			} else {
+				reply("250 ok")
+			}
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			f.mu.Lock()
+			f.data = append(f.data, b.String())
+			f.mu.Unlock()
+			reply("250 queued")
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown")
+		}
+	}
+}
+
+func TestSMTPSenderReusesConnection(t *testing.T) {
+	f := newFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(f.ln.Addr().String())
+	s := NewSMTPSender(SMTPConfig{
+		Host: host, Port: port, TLSMode: TLSNone,
+		From: "KMRL Docs <docs@kmrl.in>", BounceAddress: "bounces@kmrl.in",
+	})
+	defer s.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := s.Send(ctx, &Message{To: []string{"a@kmrl.in"}, Subject: "Hi", Text: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Send(ctx, &Message{To: []string{"nobody@kmrl.in"}, Subject: "Hi", Text: "hello"})
+	if !IsPermanent(err) {
+		t.Fatalf("expected permanent rejection, got %v", err)
+	}
+	if err := s.Send(ctx, &Message{To: []string{"b@kmrl.in"}, Subject: "Hi", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns != 1 {
+		t.Fatalf("expected one pooled connection, got %d", f.conns)
+	}
+	if len(f.data) != 3 || f.mails[0] != "<bounces@kmrl.in>" {
+		t.Fatalf("unexpected traffic: mails=%v data=%d", f.mails, len(f.data))
+	}
+	for _, h := range []string{"From: \"KMRL Docs\" <docs@kmrl.in>\r\n", "Date: ", "Message-ID: <", "@kmrl.in>\r\n"} {
+		if !strings.Contains(f.data[0], h) {
+			t.Fatalf("missing %q in:\n%s", h, f.data[0])
+		}
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	m := &Message{From: "docs@kmrl.in", To: []string{"a@kmrl.in"}, Subject: "x\r\nBcc: evil@example.com", Text: "t"}
+	// Subjects are Q-encoded, so a CRLF there is encoded, not injected.
+	if raw, _, err := m.Build(time.Now(), ""); err != nil || strings.Contains(string(raw), "\r\nBcc:") {
+		t.Fatalf("header injected via subject (err %v)", err)
+	}
+	m = &Message{From: "docs@kmrl.in", To: []string{"a@kmrl.in"}, Headers: map[string]string{"X-Ref": "a\nBcc: b"}}
+	if _, _, err := m.Build(time.Now(), ""); err != errHeaderInjection {
+		t.Fatalf("expected header injection error, got %v", err)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	mem := NewMemorySink("docs@kmrl.in")
+	msg := &Message{To: []string{"Asha <asha@kmrl.in>"}, Subject: "Déjà vu", Text: "plain", HTML: "<p>html</p>"}
+	if err := mem.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	got := mem.Messages()
+	if len(got) != 1 || got[0].Envelope.To[0] != "asha@kmrl.in" || !strings.Contains(string(got[0].Raw), "multipart/alternative") {
+		t.Fatalf("unexpected capture: %+v", got)
+	}
+	if !strings.Contains(string(got[0].Raw), "Subject: =?UTF-8?q?") {
+		t.Fatalf("subject not encoded:\n%s", got[0].Raw)
+	}
+	if err := mem.Send(context.Background(), &Message{To: []string{"not an address"}}); !IsPermanent(err) {
+		t.Fatalf("expected permanent error for bad address, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := (FileSink{Dir: dir, From: "docs@kmrl.in"}).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), ".eml") {
+		t.Fatalf("expected one .eml file, got %v", files)
+	}
+}
+
+func TestLimiterSpacesSends(t *testing.T) {
+	l := &limiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected at least 40ms, got %s", elapsed)
+	}
+}
@@ -0,0 +1,173 @@
+// Package mail sends email. An SMTP sender keeps a small pool of
+// authenticated connections, paces messages to stay under provider limits
+// and supports STARTTLS (587) and implicit TLS (465). Sink senders capture
+// mail in memory or as .eml files for development and tests.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is one email. Text, HTML or both may be set; with both the message
+// is multipart/alternative. From defaults to the sender's configured address.
+type Message struct {
+	From    string
+	To      []string
+	ReplyTo string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are extra headers such as List-Unsubscribe or X-Entity-Ref-ID.
+	Headers map[string]string
+}
+
+var errHeaderInjection = errors.New("mail: header value contains a line break")
+
+// Envelope is the SMTP-level sender and recipients, which may differ from
+// the From/To headers. Bounces go to the envelope sender.
+type Envelope struct {
+	From string
+	To   []string
+}
+
+// parseAddressList validates addresses and returns their bare forms.
+func parseAddressList(list []string) ([]*netmail.Address, error) {
+	out := make([]*netmail.Address, 0, len(list))
+	for _, raw := range list {
+		a, err := netmail.ParseAddress(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("mail: invalid address %q: %w", raw, err)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// Build renders m as an RFC 5322 message with From, To, Date and
+// Message-ID set, and returns it with its envelope recipients. domain is
+// used for the Message-ID.
+func (m *Message) Build(now time.Time, domain string) ([]byte, []string, error) {
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mail: invalid From %q: %w", m.From, err)
+	}
+	to, err := parseAddressList(m.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(to) == 0 {
+		return nil, nil, errors.New("mail: no recipients")
+	}
+	if domain == "" {
+		domain = from.Address[strings.LastIndex(from.Address, "@")+1:]
+	}
+
+	var hdr bytes.Buffer
+	header := func(name, value string) error {
+		if strings.ContainsAny(name+value, "\r\n") {
+			return errHeaderInjection
+		}
+		hdr.WriteString(name + ": " + value + "\r\n")
+		return nil
+	}
+	recipients := make([]string, len(to))
+	toHeader := make([]string, len(to))
+	for i, a := range to {
+		recipients[i] = a.Address
+		toHeader[i] = a.String()
+	}
+	header("From", from.String())
+	header("To", strings.Join(toHeader, ", "))
+	if m.ReplyTo != "" {
+		rt, err := netmail.ParseAddress(m.ReplyTo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mail: invalid Reply-To %q: %w", m.ReplyTo, err)
+		}
+		header("Reply-To", rt.String())
+	}
+	if err := header("Subject", mime.QEncoding.Encode("UTF-8", m.Subject)); err != nil {
+		return nil, nil, err
+	}
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", newMessageID(now, domain))
+	header("MIME-Version", "1.0")
+	for k, v := range m.Headers {
+		if err := header(textproto.CanonicalMIMEHeaderKey(k), v); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	body, contentType, err := m.body()
+	if err != nil {
+		return nil, nil, err
+	}
+	header("Content-Type", contentType)
+	if !strings.HasPrefix(contentType, "multipart/") {
+		header("Content-Transfer-Encoding", "quoted-printable")
+	}
+	hdr.WriteString("\r\n")
+	hdr.Write(body)
+	return hdr.Bytes(), recipients, nil
+}
+
+func (m *Message) body() ([]byte, string, error) {
+	const textType, htmlType = `text/plain; charset="UTF-8"`, `text/html; charset="UTF-8"`
+	switch {
+	case m.HTML == "":
+		b, err := qpEncode(m.Text)
+		return b, textType, err
+	case m.Text == "":
+		b, err := qpEncode(m.HTML)
+		return b, htmlType, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{{textType, m.Text}, {htmlType, m.HTML}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		b, err := qpEncode(part.content)
+		if err != nil {
+			return nil, "", err
+		}
+		w.Write(b)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), `multipart/alternative; boundary="` + mw.Boundary() + `"`, nil
+}
+
+func qpEncode(s string) ([]byte, error) {
+	var b bytes.Buffer
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func newMessageID(now time.Time, domain string) string {
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(b[:]), domain)
+}
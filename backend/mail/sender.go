@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+)
+
+// Sender delivers messages. Implementations are safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// IsPermanent reports whether err is a 5xx SMTP rejection (unknown mailbox,
+// policy refusal, ...) or a malformed message, which retrying will not fix.
+func IsPermanent(err error) bool {
+	var tp *textproto.Error
+	if errors.As(err, &tp) {
+		return tp.Code >= 500
+	}
+	var inv invalidError
+	return errors.As(err, &inv)
+}
+
+// invalidError wraps errors in the message itself.
+type invalidError struct{ err error }
+
+func (e invalidError) Error() string { return e.err.Error() }
+func (e invalidError) Unwrap() error { return e.err }
+
+var (
+	defaultMu     sync.RWMutex
+	defaultSender Sender
+)
+
+// Default returns the process-wide sender, configured from the environment
+// on first use.
+func Default() Sender {
+	defaultMu.RLock()
+	s := defaultSender
+	defaultMu.RUnlock()
+	if s != nil {
+		return s
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultSender == nil {
+		defaultSender = FromEnv()
+	}
+	return defaultSender
+}
+
+// SetDefault replaces the process-wide sender (tests, custom setups).
+func SetDefault(s Sender) {
+	defaultMu.Lock()
+	defaultSender = s
+	defaultMu.Unlock()
+}
+
+// Send delivers m with the default sender.
+func Send(ctx context.Context, m *Message) error {
+	return Default().Send(ctx, m)
+}
+
+var errUnconfigured = errors.New("SMTP credentials are not fully configured")
+
+// unconfigured fails every send, so a missing SMTP setup is retried rather
+// than silently dropped.
+type unconfigured struct{}
+
+func (unconfigured) Send(ctx context.Context, m *Message) error { return errUnconfigured }
@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Captured is a message recorded by a sink.
+type Captured struct {
+	Envelope Envelope
+	Message  Message
+	Raw      []byte
+	SentAt   time.Time
+}
+
+// MemorySink keeps every message in memory instead of sending it.
+type MemorySink struct {
+	From string
+
+	mu   sync.Mutex
+	sent []Captured
+}
+
+func NewMemorySink(from string) *MemorySink {
+	return &MemorySink{From: from}
+}
+
+func (s *MemorySink) Send(ctx context.Context, m *Message) error {
+	c, err := capture(m, s.From)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.sent = append(s.sent, c)
+	s.mu.Unlock()
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (s *MemorySink) Messages() []Captured {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Captured(nil), s.sent...)
+}
+
+// Reset forgets captured messages.
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	s.sent = nil
+	s.mu.Unlock()
+}
+
+// FileSink writes each message to Dir as an .eml file that any mail client
+// can open.
+type FileSink struct {
+	Dir  string
+	From string
+}
+
+func (s FileSink) Send(ctx context.Context, m *Message) error {
+	c, err := capture(m, s.From)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", c.SentAt.Format("20060102T150405"), c.SentAt.UnixNano())
+	return os.WriteFile(filepath.Join(s.Dir, name), c.Raw, 0o644)
+}
+
+func capture(m *Message, from string) (Captured, error) {
+	msg := *m
+	if msg.From == "" {
+		msg.From = from
+	}
+	now := time.Now()
+	raw, rcpts, err := msg.Build(now, "")
+	if err != nil {
+		return Captured{}, invalidError{err}
+	}
+	return Captured{Envelope: Envelope{From: msg.From, To: rcpts}, Message: msg, Raw: raw, SentAt: now}, nil
+}
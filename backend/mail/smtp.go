@@ -0,0 +1,268 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// TLS modes.
+const (
+	TLSStartTLS = "starttls" // plain connect, upgrade with STARTTLS (587)
+	TLSImplicit = "implicit" // TLS from the first byte (465)
+	TLSNone     = "none"     // local relays and test servers only
+)
+
+// SMTPConfig configures an SMTPSender.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// BounceAddress is the envelope sender (MAIL FROM), where bounces go.
+	// Defaults to the From address.
+	BounceAddress string
+	TLSMode       string
+	TLSConfig     *tls.Config
+	// PoolSize caps how many connections are kept open between sends.
+	PoolSize int
+	// RatePerMinute caps messages sent per minute; 0 means unlimited.
+	RatePerMinute int
+	IdleTimeout   time.Duration
+	DialTimeout   time.Duration
+	// Domain is used in Message-IDs and HELO; defaults to the From domain.
+	Domain string
+}
+
+// SMTPSender sends mail over a pool of reused SMTP connections.
+type SMTPSender struct {
+	cfg     SMTPConfig
+	pool    chan *smtpConn
+	limiter *limiter
+	now     func() time.Time
+	dial    func(ctx context.Context) (net.Conn, error)
+}
+
+type smtpConn struct {
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	if cfg.TLSMode == "" {
+		cfg.TLSMode = TLSStartTLS
+		if cfg.Port == "465" {
+			cfg.TLSMode = TLSImplicit
+		}
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 2
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Second
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 15 * time.Second
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	}
+	s := &SMTPSender{cfg: cfg, pool: make(chan *smtpConn, cfg.PoolSize), now: time.Now}
+	if cfg.RatePerMinute > 0 {
+		s.limiter = &limiter{interval: time.Minute / time.Duration(cfg.RatePerMinute)}
+	}
+	s.dial = s.dialNet
+	return s
+}
+
+// Send delivers m, reusing an idle connection when one is available.
+// Recipient and content rejections (5xx) are reported as permanent; the
+// connection is reset and kept for the next message.
+func (s *SMTPSender) Send(ctx context.Context, m *Message) error {
+	msg := *m
+	if msg.From == "" {
+		msg.From = s.cfg.From
+	}
+	raw, rcpts, err := msg.Build(s.now(), s.cfg.Domain)
+	if err != nil {
+		return invalidError{err}
+	}
+	if s.limiter != nil {
+		if err := s.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	c, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		// smtp.Client has no context support; bound the exchange instead.
+		defer time.AfterFunc(time.Until(dl), func() { c.client.Close() }).Stop()
+	}
+	err = s.transmit(c.client, s.envelopeFrom(msg.From), rcpts, raw)
+	var tp *textproto.Error
+	switch {
+	case err == nil:
+		s.put(c)
+	case errors.As(err, &tp) && c.client.Reset() == nil:
+		s.put(c)
+	default:
+		c.client.Close()
+	}
+	return err
+}
+
+// envelopeFrom is the bare bounce address. Using it rather than the From
+// header keeps display names out of MAIL FROM and lets bounces go to a
+// dedicated mailbox.
+func (s *SMTPSender) envelopeFrom(from string) string {
+	addr := s.cfg.BounceAddress
+	if addr == "" {
+		addr = from
+	}
+	if a, err := parseAddressList([]string{addr}); err == nil {
+		return a[0].Address
+	}
+	return addr
+}
+
+func (s *SMTPSender) transmit(c *smtp.Client, from string, rcpts []string, raw []byte) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range rcpts {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("RCPT %s: %w", r, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// get returns a pooled connection that is still alive, or dials a new one.
+func (s *SMTPSender) get(ctx context.Context) (*smtpConn, error) {
+	for {
+		select {
+		case c := <-s.pool:
+			if s.now().Sub(c.lastUsed) < s.cfg.IdleTimeout && c.client.Noop() == nil {
+				return c, nil
+			}
+			c.client.Close()
+			continue
+		default:
+		}
+		return s.connect(ctx)
+	}
+}
+
+func (s *SMTPSender) put(c *smtpConn) {
+	c.lastUsed = s.now()
+	select {
+	case s.pool <- c:
+	default:
+		c.client.Quit()
+	}
+}
+
+// Close quits every pooled connection.
+func (s *SMTPSender) Close() {
+	for {
+		select {
+		case c := <-s.pool:
+			c.client.Quit()
+		default:
+			return
+		}
+	}
+}
+
+func (s *SMTPSender) dialNet(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: s.cfg.DialTimeout}
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	if s.cfg.TLSMode == TLSImplicit {
+		return (&tls.Dialer{NetDialer: d, Config: s.cfg.TLSConfig}).DialContext(ctx, "tcp", addr)
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (s *SMTPSender) connect(ctx context.Context) (*smtpConn, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fail := func(err error) (*smtpConn, error) {
+		c.Close()
+		return nil, err
+	}
+	if s.cfg.Domain != "" {
+		if err := c.Hello(s.cfg.Domain); err != nil {
+			return fail(err)
+		}
+	}
+	if s.cfg.TLSMode == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fail(errors.New("mail: server does not support STARTTLS"))
+		}
+		if err := c.StartTLS(s.cfg.TLSConfig); err != nil {
+			return fail(err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fail(err)
+		}
+	}
+	return &smtpConn{client: c, lastUsed: s.now()}, nil
+}
+
+// limiter spaces calls at least interval apart.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
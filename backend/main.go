@@ -11,15 +11,18 @@ TODO: for self
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"backend/config"
 	"backend/handlers"
+	"backend/mail"
+	"backend/notifications"
 	"backend/utils"
 
 	"github.com/joho/godotenv"
@@ -56,7 +59,6 @@ func main() {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
-	// http.HandleFunc("/v1/files", handlers.ListFilesHandler)
 	// http.HandleFunc("/v1/files/", handlers.GetFileHandler)
 	// http.HandleFunc("/v1/departments", handlers.ListDepartmentsHandler)
 
@@ -129,147 +131,101 @@ func main() {
 		}
 	}()
 
-	//Start notification polling goroutine
+	// Notification dispatcher: drains notifications and quick_share rows into
+	// the outbox and delivers them with retries. Stopped on shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hub := notifications.NewHub()
+	dispatcher := notifications.NewDispatcher(
+		notifications.NewPostgresStore(config.DB),
+		notifications.Route(
+			notifications.SMTPNotifier{Send: utils.SendGmailMultipart},
+			notifications.WebhookNotifier{Secret: os.Getenv("NOTIFY_WEBHOOK_SECRET")},
+			notifications.InAppNotifier{Hub: hub},
+		),
+		notifications.NotificationSource(config.DB),
+		notifications.QuickShareSource(config.DB),
+	)
+	dispatcherDone := make(chan struct{})
 	go func() {
-		for {
-			rows, err := config.DB.Query(`SELECT notif_id, uuid, f_uuid FROM notifications WHERE is_sent IS NOT TRUE`)
-			if err != nil {
-				log.Println("[NOTIF] DB query error:", err)
-				time.Sleep(10 * time.Second)
-				continue
-			}
-			for rows.Next() {
-				var notifID, uuid, fuuid string
-				if err := rows.Scan(&notifID, &uuid, &fuuid); err != nil {
-					log.Println("[NOTIF] Row scan error:", err)
-					continue
-				}
-				// Fetch user email
-				var userEmail string
-				row := config.DB.QueryRow("SELECT email FROM users WHERE uuid = $1", uuid)
-				_ = row.Scan(&userEmail)
-				// Fetch file name
-				var fileName string
-				row2 := config.DB.QueryRow("SELECT f_name FROM file WHERE f_uuid = $1", fuuid)
-				_ = row2.Scan(&fileName)
-				if userEmail != "" && fileName != "" {
-					subject := "New file uploaded: " + fileName
-					body := "A new file has been added to your account.\n\nFile: " + fileName
-					if err := utils.SendGmailNotification(userEmail, subject, body); err != nil {
-						log.Println("[NOTIF] Failed to send email:", err)
-					} else {
-						log.Println("[NOTIF] Email sent to:", userEmail)
-						// Mark notification as sent
-						_, err := config.DB.Exec("UPDATE notifications SET is_sent = true WHERE notif_id = $1", notifID)
-						if err != nil {
-							log.Println("[NOTIF] Failed to update is_sent:", err)
-						}
-					}
-				}
-			}
-			rows.Close()
-			time.Sleep(10 * time.Second)
-		}
+		defer close(dispatcherDone)
+		dispatcher.Run(ctx)
 	}()
-
-	//Start quick share notification polling goroutine
+	// Daily/weekly digests and deadline reminders, queued through the same outbox.
+	digests := notifications.NewDigestScheduler(config.DB)
+	digestsDone := make(chan struct{})
 	go func() {
-		for {
-			log.Println("[QUICK_SHARE] Polling for new quick_share entries...")
-			rows, err := config.DB.Query(`SELECT qs_uuid, d_uuid, data FROM quick_share WHERE is_sent = false`)
-			if err != nil {
-				log.Println("[QUICK_SHARE] DB query error:", err)
-				time.Sleep(5 * time.Second)
-				continue
-			}
-			for rows.Next() {
-				var qsUUID, dUUID string
-				var data string
-				if err := rows.Scan(&qsUUID, &dUUID, &data); err != nil {
-					log.Println("[QUICK_SHARE] Row scan error:", err)
-					continue
-				}
-				log.Printf("[QUICK_SHARE] Processing qs_uuid: %s, d_uuid: %s", qsUUID, dUUID)
+		defer close(digestsDone)
+		digests.Run(ctx)
+	}()
+	http.HandleFunc("/v1/me/notification-preferences", handlers.NotificationPreferencesHandler)
+	http.HandleFunc("/v1/deadlines", handlers.DeadlinesHandler)
 
-				// Parse the JSON data
-				var dataMap map[string]interface{}
-				if err := json.Unmarshal([]byte(data), &dataMap); err != nil {
-					log.Printf("[QUICK_SHARE] Failed to parse data JSON: %v", err)
-					continue
-				}
+	// Quick shares (replaces direct quick_share inserts from the frontend)
+	handlers.InitQuickShareService()
+	http.HandleFunc("/v1/quickshare", handlers.QuickSharesHandler)
+	http.HandleFunc("/v1/quickshare/{id}", handlers.QuickShareThreadHandler)
+	http.HandleFunc("/v1/quickshare/{id}/reply", handlers.QuickShareReplyHandler)
+	http.HandleFunc("/v1/quickshare/{id}/read", handlers.QuickShareReadHandler)
+	http.HandleFunc("/v1/ws/notifications", handlers.NotificationSocketHandler(hub))
+	http.HandleFunc("/v1/notifications", handlers.NotificationsHandler)
+	http.HandleFunc("/v1/notifications/unread-count", handlers.NotificationsUnreadCountHandler)
+	http.HandleFunc("/v1/notifications/read", handlers.NotificationsReadHandler(hub))
 
-				// Format the content
-				formatted := "You have received a quick share:\n\n"
-				for k, v := range dataMap {
-					formatted += fmt.Sprintf("%s: %v\n", k, v)
-				}
+	// Files: listings, access-checked metadata, downloads, previews and comments
+	http.HandleFunc("/v1/files", handlers.FilesHandler)
+	http.HandleFunc("/v1/departments/{id}/files", handlers.DepartmentFilesHandler)
+	http.HandleFunc("/v1/files/{id}/sensitivity", handlers.FileSensitivityHandler)
+	http.HandleFunc("/v1/files/{id}/download", handlers.FileDownloadHandler)
+	handlers.InitPreviewService()
+	http.HandleFunc("/v1/files/{id}/thumbnail", handlers.FileThumbnailHandler)
+	http.HandleFunc("/v1/files/{id}/pages/{n}/preview", handlers.FilePagePreviewHandler)
+	handlers.InitCommentService()
+	http.HandleFunc("/v1/files/{id}/comments", handlers.FileCommentsHandler)
+	http.HandleFunc("/v1/files/{id}/comments/{commentId}", handlers.FileCommentHandler)
+	http.HandleFunc("/v1/files/{id}/comments/{commentId}/history", handlers.FileCommentHistoryHandler)
 
-				rows2, err := config.DB.Query("SELECT position, email FROM users WHERE d_uuid = $1", dUUID)
-				if err != nil {
-					log.Printf("[QUICK_SHARE] User lookup error for d_uuid %s: %v", dUUID, err)
-					continue
-				}
-				headEmails := make([]string, 0)
-				regularEmails := make([]string, 0)
-				for rows2.Next() {
-					var position, email string
-					if err := rows2.Scan(&position, &email); err != nil {
-						log.Printf("[QUICK_SHARE] User scan error: %v", err)
-						continue
-					}
-					log.Printf("[QUICK_SHARE] User position: %s, email: %s", position, email)
-					if email == "" {
-						continue
-					}
-					if position == "head" {
-						headEmails = append(headEmails, email)
-					} else {
-						regularEmails = append(regularEmails, email)
-					}
-				}
-				rows2.Close()
+	// Trash: deleted files stay restorable for TRASH_RETENTION_DAYS, then are purged
+	handlers.InitTrashService()
+	handlers.StartTrashPurgeJob(time.Hour)
+	http.HandleFunc("/v1/files/{id}", handlers.FileHandler)
+	http.HandleFunc("/v1/files/{id}/restore", handlers.FileRestoreHandler)
+	http.HandleFunc("/v1/trash", handlers.TrashHandler)
 
-				recipients := headEmails
-				if len(recipients) == 0 {
-					recipients = regularEmails
-					if len(recipients) > 0 {
-						log.Printf("[QUICK_SHARE] No head users found for d_uuid %s; falling back to regular users", dUUID)
-					}
-				}
+	// Favorites and named collections (shared read-only within a department)
+	handlers.InitCollectionService()
+	http.HandleFunc("/v1/me/favorites", handlers.FavoritesHandler)
+	http.HandleFunc("/v1/me/favorites/{fileId}", handlers.FavoriteHandler)
+	http.HandleFunc("/v1/me/collections", handlers.CollectionsHandler)
+	http.HandleFunc("/v1/me/collections/{id}", handlers.CollectionHandler)
+	http.HandleFunc("/v1/me/collections/{id}/items", handlers.CollectionItemsHandler)
+	http.HandleFunc("/v1/me/collections/{id}/items/{fileId}", handlers.CollectionItemHandler)
 
-				sent := false
-				subject := "Quick Share Notification"
-				body := formatted
-				for _, email := range recipients {
-					log.Printf("[QUICK_SHARE] Sending email to %s...", email)
-					if err := utils.SendGmailNotification(email, subject, body); err != nil {
-						log.Println("[QUICK_SHARE] Failed to send email:", err)
-					} else {
-						log.Printf("[QUICK_SHARE] Email sent to: %s", email)
-						sent = true
-					}
-				}
+	// Cross-department workspaces: linked files, comments and invitations
+	handlers.InitWorkspaceService()
+	http.HandleFunc("/v1/workspaces", handlers.WorkspacesHandler)
+	http.HandleFunc("/v1/workspaces/{id}", handlers.WorkspaceHandler)
+	http.HandleFunc("/v1/workspaces/{id}/departments", handlers.WorkspaceDepartmentsHandler)
+	http.HandleFunc("/v1/workspaces/{id}/departments/{deptId}", handlers.WorkspaceDepartmentHandler)
+	http.HandleFunc("/v1/workspaces/{id}/join", handlers.WorkspaceJoinHandler)
+	http.HandleFunc("/v1/workspaces/{id}/files", handlers.WorkspaceFilesHandler)
+	http.HandleFunc("/v1/workspaces/{id}/files/{fileId}", handlers.WorkspaceFileHandler)
+	http.HandleFunc("/v1/workspaces/{id}/comments", handlers.WorkspaceCommentsHandler)
+	http.HandleFunc("/v1/workspaces/{id}/comments/{commentId}", handlers.WorkspaceCommentHandler)
 
-				if len(recipients) == 0 {
-					log.Printf("[QUICK_SHARE] No recipients found for d_uuid %s; marking as sent to avoid infinite retries", dUUID)
-					sent = true
-				}
-
-				if sent {
-					_, err := config.DB.Exec("UPDATE quick_share SET is_sent = true WHERE qs_uuid = $1", qsUUID)
-					if err != nil {
-						log.Println("[QUICK_SHARE] Failed to update is_sent:", err)
-					} else {
-						log.Printf("[QUICK_SHARE] Marked qs_uuid %s as sent.", qsUUID)
-					}
-				}
-			}
-			rows.Close()
-			time.Sleep(10 * time.Second)
-		}
-	}()
+	// Approval workflows started on upload; the SLA job flags late steps
+	handlers.InitWorkflowService()
+	handlers.StartWorkflowSLAJob(15 * time.Minute)
+	http.HandleFunc("/v1/workflow-templates", handlers.WorkflowTemplatesHandler)
+	http.HandleFunc("/v1/workflows", handlers.WorkflowsHandler)
+	http.HandleFunc("/v1/workflows/{id}", handlers.WorkflowHandler)
+	http.HandleFunc("/v1/workflows/{id}/approve", handlers.WorkflowApproveHandler)
+	http.HandleFunc("/v1/workflows/{id}/reject", handlers.WorkflowRejectHandler)
 
 	// ── Admin API routes (protected by admin session token) ──
+	handlers.InitAdminSessionStore()
+	handlers.StartAdminSessionJanitor(15 * time.Minute)
+	handlers.StartUserReconciliationJob(6 * time.Hour)
 	http.HandleFunc("/v1/admin/login", handlers.AdminLoginHandler)
 	http.HandleFunc("/v1/admin/logout", handlers.AdminAuthMiddleware(handlers.AdminLogoutHandler))
 	http.HandleFunc("/v1/admin/users", handlers.AdminAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -286,6 +242,45 @@ func main() {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
+	http.HandleFunc("/v1/admin/users/reconcile", handlers.AdminAuthMiddleware(handlers.AdminReconcileUsersHandler))
+	handlers.InitUserImports()
+	http.HandleFunc("/v1/admin/users/import", handlers.AdminAuthMiddleware(handlers.AdminImportUsersHandler))
+	http.HandleFunc("/v1/admin/users/import/{id}", handlers.AdminAuthMiddleware(handlers.AdminImportStatusHandler))
+	http.HandleFunc("/v1/admin/users/import/{id}/report", handlers.AdminAuthMiddleware(handlers.AdminImportReportHandler))
+	http.HandleFunc("/v1/admin/sessions", handlers.AdminAuthMiddleware(handlers.AdminListSessionsHandler))
+	http.HandleFunc("/v1/admin/sessions/{id}", handlers.AdminAuthMiddleware(handlers.AdminRevokeSessionHandler))
+	http.HandleFunc("/v1/admin/departments", handlers.AdminAuthMiddleware(handlers.AdminDepartmentsHandler))
+	http.HandleFunc("/v1/admin/roles", handlers.AdminAuthMiddleware(handlers.AdminRolesHandler))
+	http.HandleFunc("/v1/admin/audit", handlers.AdminAuthMiddleware(handlers.AdminAuditLogHandler))
+	http.HandleFunc("/v1/admin/audit/verify", handlers.AdminAuthMiddleware(handlers.AdminAuditVerifyHandler))
+
+	// Departmental routing rules, evaluated after OCR on every upload
+	handlers.InitClassificationService()
+	handlers.InitRoutingService()
+	http.HandleFunc("/v1/admin/routing-rules", handlers.AdminAuthMiddleware(handlers.AdminRoutingRulesHandler))
+	http.HandleFunc("/v1/admin/routing-rules/dry-run", handlers.AdminAuthMiddleware(handlers.AdminRoutingDryRunHandler))
+	http.HandleFunc("/v1/admin/routing-rules/{id}", handlers.AdminAuthMiddleware(handlers.AdminRoutingRuleHandler))
+	http.HandleFunc("/v1/admin/categories", handlers.AdminAuthMiddleware(handlers.AdminCategoriesHandler))
+	http.HandleFunc("/v1/admin/categories/{id}", handlers.AdminAuthMiddleware(handlers.AdminCategoryHandler))
+	http.HandleFunc("/v1/admin/files/{id}/classification", handlers.AdminAuthMiddleware(handlers.AdminFileClassificationHandler))
+	http.HandleFunc("/v1/admin/workflow-templates", handlers.AdminAuthMiddleware(handlers.AdminWorkflowTemplatesHandler))
+	http.HandleFunc("/v1/admin/workflow-templates/{id}", handlers.AdminAuthMiddleware(handlers.AdminWorkflowTemplateHandler))
+
+	// Retention policies, applied daily; legal holds block archival and purge
+	handlers.InitRetentionService()
+	handlers.StartRetentionJob(24 * time.Hour)
+	http.HandleFunc("/v1/admin/retention-policies", handlers.AdminAuthMiddleware(handlers.AdminRetentionPoliciesHandler))
+	http.HandleFunc("/v1/admin/retention-policies/{id}", handlers.AdminAuthMiddleware(handlers.AdminRetentionPolicyHandler))
+	http.HandleFunc("/v1/admin/retention/runs", handlers.AdminAuthMiddleware(handlers.AdminRetentionRunsHandler))
+	http.HandleFunc("/v1/admin/retention/runs/{id}", handlers.AdminAuthMiddleware(handlers.AdminRetentionRunHandler))
+	http.HandleFunc("/v1/admin/files/{id}/legal-hold", handlers.AdminAuthMiddleware(handlers.AdminFileLegalHoldHandler))
+
+	// Storage/database reconciler: reports orphan and missing objects every
+	// 6 hours and re-queues missing OCR and summaries (also cmd/reconcile)
+	handlers.InitReconcileService()
+	handlers.StartReconcileJob(6 * time.Hour)
+	http.HandleFunc("/v1/admin/reconcile/runs", handlers.AdminAuthMiddleware(handlers.AdminReconcileRunsHandler))
+	http.HandleFunc("/v1/admin/reconcile/runs/{id}", handlers.AdminAuthMiddleware(handlers.AdminReconcileRunHandler))
 
 	//Start HTTP server
 	port := os.Getenv("PORT")
@@ -299,8 +294,24 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Printf("Server started at http://localhost:%s\n", port)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	go func() {
+		log.Printf("Server started at http://localhost:%s\n", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %v", err)
+	}
+	hub.CloseAll()
+	<-digestsDone
+	<-dispatcherDone
+	if pool, ok := mail.Default().(*mail.SMTPSender); ok {
+		pool.Close()
 	}
 }
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// AdminSession is a persisted admin login. Only the SHA-256 of the bearer
+// token is stored so a leaked table cannot be replayed.
+type AdminSession struct {
+	ID         string    `json:"id"`
+	TokenHash  string    `json:"-"`
+	AdminID    string    `json:"admin_id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func InsertAdminSession(db *sql.DB, s AdminSession) error {
+	query := `
+		INSERT INTO admin_sessions (id, token_hash, a_uuid, username, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := db.Exec(query, s.ID, s.TokenHash, s.AdminID, s.Username, s.IP, s.UserAgent, s.CreatedAt, s.LastSeenAt, s.ExpiresAt)
+	if err != nil {
+		log.Println("[DB] InsertAdminSession error:", err)
+	}
+	return err
+}
+
+// GetAdminSessionByTokenHash returns nil, nil when no session matches.
+func GetAdminSessionByTokenHash(db *sql.DB, tokenHash string) (*AdminSession, error) {
+	query := `
+		SELECT id, token_hash, a_uuid, username, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at, last_seen_at, expires_at
+		FROM admin_sessions
+		WHERE token_hash = $1
+	`
+	var s AdminSession
+	err := db.QueryRow(query, tokenHash).Scan(
+		&s.ID, &s.TokenHash, &s.AdminID, &s.Username, &s.IP, &s.UserAgent,
+		&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Println("[DB] GetAdminSessionByTokenHash error:", err)
+		return nil, err
+	}
+	return &s, nil
+}
+
+func TouchAdminSession(db *sql.DB, id string, seenAt time.Time) error {
+	_, err := db.Exec(`UPDATE admin_sessions SET last_seen_at = $1 WHERE id = $2`, seenAt, id)
+	if err != nil {
+		log.Println("[DB] TouchAdminSession error:", err)
+	}
+	return err
+}
+
+// DeleteAdminSession revokes a session. It reports whether a row existed.
+func DeleteAdminSession(db *sql.DB, id string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM admin_sessions WHERE id = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteAdminSession error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListAdminSessions returns sessions that have not passed their absolute expiry.
+// Idle expiry is applied by the caller because the idle window is configurable.
+func ListAdminSessions(db *sql.DB, now time.Time) ([]AdminSession, error) {
+	query := `
+		SELECT id, token_hash, a_uuid, username, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at, last_seen_at, expires_at
+		FROM admin_sessions
+		WHERE expires_at > $1
+		ORDER BY last_seen_at DESC
+	`
+	rows, err := db.Query(query, now)
+	if err != nil {
+		log.Println("[DB] ListAdminSessions error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []AdminSession
+	for rows.Next() {
+		var s AdminSession
+		if err := rows.Scan(&s.ID, &s.TokenHash, &s.AdminID, &s.Username, &s.IP, &s.UserAgent,
+			&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteExpiredAdminSessions removes sessions past their absolute expiry or
+// idle since before idleCutoff.
+func DeleteExpiredAdminSessions(db *sql.DB, now, idleCutoff time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM admin_sessions WHERE expires_at <= $1 OR last_seen_at <= $2`, now, idleCutoff)
+	if err != nil {
+		log.Println("[DB] DeleteExpiredAdminSessions error:", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// AuditEntry is one append-only row of audit_log. Each row's Hash covers its
+// own fields plus the previous row's Hash, so editing or removing a row breaks
+// the chain from that point on.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorType  string          `json:"actor_type"` // admin | user | system
+	ActorID    string          `json:"actor_id"`
+	ActorName  string          `json:"actor_name,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditFilter narrows QueryAuditEntries. Pagination is keyset on id: pass the
+// last id of the previous page as BeforeID.
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// auditChainLock serialises writers so two inserts cannot share a prev_hash.
+const auditChainLock = 727001
+
+// ComputeAuditHash hashes the entry fields in a fixed order together with prevHash.
+func ComputeAuditHash(prevHash string, e AuditEntry) string {
+	diff := string(e.Diff)
+	if diff == "" {
+		diff = "null"
+	}
+	fields := []string{
+		prevHash,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		e.ActorType, e.ActorID, e.ActorName,
+		e.Action, e.TargetType, e.TargetID,
+		e.IP, e.UserAgent, diff,
+	}
+	canonical, _ := json.Marshal(fields)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditEntries checks entries (ascending by id) against prevHash, the
+// hash of the row before the first entry. It returns the id of the first row
+// whose hash does not match, or 0 if the chain is intact.
+func VerifyAuditEntries(prevHash string, entries []AuditEntry) int64 {
+	for _, e := range entries {
+		if e.PrevHash != prevHash || ComputeAuditHash(prevHash, e) != e.Hash {
+			return e.ID
+		}
+		prevHash = e.Hash
+	}
+	return 0
+}
+
+// InsertAuditEntry chains and stores e, returning the stored row.
+func InsertAuditEntry(db *sql.DB, e AuditEntry) (AuditEntry, error) {
+	// Postgres keeps microseconds; truncate first so the hash survives a round trip.
+	e.OccurredAt = e.OccurredAt.UTC().Truncate(time.Microsecond)
+	if len(e.Diff) == 0 {
+		e.Diff = json.RawMessage("null")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return e, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return e, err
+	}
+
+	var prevHash string
+	err = tx.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return e, err
+	}
+	e.PrevHash = prevHash
+	e.Hash = ComputeAuditHash(prevHash, e)
+
+	query := `
+		INSERT INTO audit_log (occurred_at, actor_type, actor_id, actor_name, action, target_type, target_id,
+		                       ip, user_agent, diff, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+	if err := tx.QueryRow(query, e.OccurredAt, e.ActorType, e.ActorID, e.ActorName, e.Action, e.TargetType,
+		e.TargetID, e.IP, e.UserAgent, string(e.Diff), e.PrevHash, e.Hash).Scan(&e.ID); err != nil {
+		log.Println("[DB] InsertAuditEntry error:", err)
+		return e, err
+	}
+	return e, tx.Commit()
+}
+
+const auditColumns = `id, occurred_at, actor_type, actor_id, COALESCE(actor_name, ''), action, target_type, target_id,
+	COALESCE(ip, ''), COALESCE(user_agent, ''), diff::text, prev_hash, hash`
+
+func scanAuditEntry(rows *sql.Rows) (AuditEntry, error) {
+	var e AuditEntry
+	var diff string
+	err := rows.Scan(&e.ID, &e.OccurredAt, &e.ActorType, &e.ActorID, &e.ActorName, &e.Action, &e.TargetType,
+		&e.TargetID, &e.IP, &e.UserAgent, &diff, &e.PrevHash, &e.Hash)
+	e.Diff = json.RawMessage(diff)
+	return e, err
+}
+
+// QueryAuditEntries returns matching rows newest first.
+func QueryAuditEntries(db *sql.DB, f AuditFilter) ([]AuditEntry, error) {
+	where := []string{}
+	args := []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ActorID != "" {
+		add("actor_id = $%d", f.ActorID)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".*") {
+			add(`action LIKE $%d ESCAPE '\'`, escapeLike(strings.TrimSuffix(f.Action, "*"))+"%")
+		} else {
+			add("action = $%d", f.Action)
+		}
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.From != nil {
+		add("occurred_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("occurred_at < $%d", *f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("[DB] QueryAuditEntries error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// VerifyAuditChain walks the whole log in id order. It returns the number of
+// rows checked and the id of the first broken row (0 if intact).
+func VerifyAuditChain(db *sql.DB) (int, int64, error) {
+	const batch = 1000
+	prevHash := ""
+	var afterID int64
+	checked := 0
+	for {
+		rows, err := db.Query("SELECT "+auditColumns+" FROM audit_log WHERE id > $1 ORDER BY id ASC LIMIT $2", afterID, batch)
+		if err != nil {
+			return checked, 0, err
+		}
+		entries := []AuditEntry{}
+		for rows.Next() {
+			e, err := scanAuditEntry(rows)
+			if err != nil {
+				rows.Close()
+				return checked, 0, err
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if len(entries) == 0 {
+			return checked, 0, nil
+		}
+		if broken := VerifyAuditEntries(prevHash, entries); broken != 0 {
+			return checked, broken, nil
+		}
+		checked += len(entries)
+		prevHash = entries[len(entries)-1].Hash
+		afterID = entries[len(entries)-1].ID
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func buildAuditChain(n int) []AuditEntry {
+	entries := make([]AuditEntry, 0, n)
+	prev := ""
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		e := AuditEntry{
+			ID:         int64(i + 1),
+			OccurredAt: base.Add(time.Duration(i) * time.Minute),
+			ActorType:  "admin",
+			ActorID:    "a-1",
+			Action:     "user.update",
+			TargetType: "user",
+			TargetID:   "u-1",
+			Diff:       json.RawMessage(`{"position":"head"}`),
+			PrevHash:   prev,
+		}
+		e.Hash = ComputeAuditHash(prev, e)
+		prev = e.Hash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestVerifyAuditEntries_IntactChain(t *testing.T) {
+	if broken := VerifyAuditEntries("", buildAuditChain(5)); broken != 0 {
+		t.Fatalf("expected intact chain, broken at %d", broken)
+	}
+}
+
+func TestVerifyAuditEntries_DetectsEditedRow(t *testing.T) {
+	entries := buildAuditChain(5)
+	entries[2].Diff = json.RawMessage(`{"position":"regular"}`)
+	if broken := VerifyAuditEntries("", entries); broken != 3 {
+		t.Fatalf("expected break at id 3, got %d", broken)
+	}
+}
+
+func TestVerifyAuditEntries_DetectsDeletedRow(t *testing.T) {
+	entries := buildAuditChain(5)
+	entries = append(entries[:1], entries[2:]...)
+	if broken := VerifyAuditEntries("", entries); broken != 3 {
+		t.Fatalf("expected break at id 3, got %d", broken)
+	}
+}
+
+func TestComputeAuditHash_NullDiffMatchesEmpty(t *testing.T) {
+	e := AuditEntry{OccurredAt: time.Unix(0, 0), Action: "admin.login"}
+	withNull := e
+	withNull.Diff = json.RawMessage("null")
+	if ComputeAuditHash("", e) != ComputeAuditHash("", withNull) {
+		t.Fatal("expected missing diff and null diff to hash the same")
+	}
+}
+
+func TestQueryAuditEntriesPrefixIsLiteral(t *testing.T) {
+	db := testDB(t)
+	for _, action := range []string{"file_access.grant", "fileXaccess.grant", "file.download"} {
+		e := AuditEntry{OccurredAt: time.Now(), ActorType: "system", ActorID: "system", Action: action, TargetType: "file", TargetID: "f-1"}
+		if _, err := InsertAuditEntry(db, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := QueryAuditEntries(db, AuditFilter{Action: "file_access.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != "file_access.grant" {
+		t.Fatalf("file_access.* matched %+v", entries)
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type DocumentCategory struct {
+	CategoryID  string
+	Name        string
+	Description string
+	Keywords    []string
+	Enabled     bool
+	FileCount   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// DocumentClassification is a file's category. CategoryID is empty when
+// nothing fit.
+type DocumentClassification struct {
+	FUUID        string
+	CategoryID   string
+	Category     string
+	Confidence   float64
+	Method       string
+	Scores       map[string]float64
+	Note         string
+	ClassifiedAt time.Time
+}
+
+const documentCategorySelect = `
+	SELECT c.category_id::text, c.name, c.description, c.keywords, c.enabled,
+		(SELECT COUNT(*) FROM document_classifications dc WHERE dc.category_id = c.category_id),
+		c.created_at, c.updated_at
+	FROM document_categories c
+`
+
+func scanDocumentCategory(scan func(...interface{}) error) (DocumentCategory, error) {
+	var c DocumentCategory
+	var keywords pq.StringArray
+	err := scan(&c.CategoryID, &c.Name, &c.Description, &keywords, &c.Enabled, &c.FileCount, &c.CreatedAt, &c.UpdatedAt)
+	c.Keywords = []string(keywords)
+	return c, err
+}
+
+// ListDocumentCategories returns the taxonomy by name, with how many files
+// are in each category.
+func ListDocumentCategories(db *sql.DB) ([]DocumentCategory, error) {
+	rows, err := db.Query(documentCategorySelect + ` ORDER BY lower(c.name)`)
+	if err != nil {
+		log.Println("[DB] ListDocumentCategories error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DocumentCategory
+	for rows.Next() {
+		c, err := scanDocumentCategory(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetDocumentCategory returns nil, nil when the category does not exist.
+func GetDocumentCategory(db *sql.DB, id string) (*DocumentCategory, error) {
+	c, err := scanDocumentCategory(db.QueryRow(documentCategorySelect+` WHERE c.category_id::text = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetDocumentCategory error:", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetDocumentCategoryByName matches case-insensitively; returns nil, nil when absent.
+func GetDocumentCategoryByName(db *sql.DB, name string) (*DocumentCategory, error) {
+	c, err := scanDocumentCategory(db.QueryRow(documentCategorySelect+` WHERE lower(c.name) = lower($1)`, name).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetDocumentCategoryByName error:", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+func InsertDocumentCategory(db *sql.DB, c DocumentCategory) (DocumentCategory, error) {
+	err := db.QueryRow(`
+		INSERT INTO document_categories (name, description, keywords, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING category_id::text, created_at, updated_at
+	`, c.Name, c.Description, pq.Array(c.Keywords), c.Enabled).Scan(&c.CategoryID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertDocumentCategory error:", err)
+	}
+	return c, err
+}
+
+// UpdateDocumentCategory returns sql.ErrNoRows when the category does not exist.
+func UpdateDocumentCategory(db *sql.DB, c DocumentCategory) (DocumentCategory, error) {
+	err := db.QueryRow(`
+		UPDATE document_categories
+		SET name = $2, description = $3, keywords = $4, enabled = $5, updated_at = NOW()
+		WHERE category_id::text = $1
+		RETURNING created_at, updated_at
+	`, c.CategoryID, c.Name, c.Description, pq.Array(c.Keywords), c.Enabled).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateDocumentCategory error:", err)
+	}
+	return c, err
+}
+
+// DeleteDocumentCategory removes a category; files in it become
+// uncategorized. It returns sql.ErrNoRows when the category does not exist.
+func DeleteDocumentCategory(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM document_categories WHERE category_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteDocumentCategory error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SaveDocumentClassification stores c as the file's classification,
+// replacing any earlier one.
+func SaveDocumentClassification(db *sql.DB, c DocumentClassification) error {
+	scores, err := json.Marshal(c.Scores)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO document_classifications (f_uuid, category_id, confidence, method, scores, note, classified_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, NOW())
+		ON CONFLICT (f_uuid) DO UPDATE SET
+			category_id = EXCLUDED.category_id, confidence = EXCLUDED.confidence, method = EXCLUDED.method,
+			scores = EXCLUDED.scores, note = EXCLUDED.note, classified_at = EXCLUDED.classified_at
+	`, c.FUUID, nullIfEmpty(c.CategoryID), c.Confidence, c.Method, string(scores), nullIfEmpty(c.Note))
+	if err != nil {
+		log.Println("[DB] SaveDocumentClassification error:", err)
+	}
+	return err
+}
+
+// GetDocumentClassification returns nil, nil when the file has not been
+// classified.
+func GetDocumentClassification(db *sql.DB, fuuid string) (*DocumentClassification, error) {
+	var c DocumentClassification
+	var scores []byte
+	var note sql.NullString
+	err := db.QueryRow(`
+		SELECT dc.f_uuid::text, COALESCE(dc.category_id::text, ''), COALESCE(cat.name, ''), dc.confidence,
+			dc.method, dc.scores, dc.note, dc.classified_at
+		FROM document_classifications dc
+		LEFT JOIN document_categories cat ON cat.category_id = dc.category_id
+		WHERE dc.f_uuid::text = $1
+	`, fuuid).Scan(&c.FUUID, &c.CategoryID, &c.Category, &c.Confidence, &c.Method, &scores, &note, &c.ClassifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetDocumentClassification error:", err)
+		return nil, err
+	}
+	c.Note = note.String
+	_ = json.Unmarshal(scores, &c.Scores)
+	return &c, nil
+}
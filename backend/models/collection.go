@@ -0,0 +1,320 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FavoriteRow struct {
+	FUUID     string
+	FileName  string
+	Language  string
+	CreatedAt time.Time
+}
+
+// ListFavorites returns the user's favorites that they may still see,
+// newest first.
+func ListFavorites(db *sql.DB, uuid string) ([]FavoriteRow, error) {
+	rows, err := db.Query(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.language, ''), COALESCE(fav.created_at, 'epoch'::timestamptz)
+		FROM favorites fav
+		JOIN file f ON f.f_uuid = fav.f_uuid
+		WHERE fav.uuid::text = $1 AND `+fileVisibleTo("f", "$1")+`
+		ORDER BY fav.created_at DESC NULLS LAST, f.f_uuid
+	`, uuid)
+	if err != nil {
+		log.Println("[DB] ListFavorites error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []FavoriteRow{}
+	for rows.Next() {
+		var r FavoriteRow
+		if err := rows.Scan(&r.FUUID, &r.FileName, &r.Language, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// AddFavorite is a no-op when the file is already a favorite.
+func AddFavorite(db *sql.DB, uuid, fuuid string) error {
+	_, err := db.Exec(`
+		INSERT INTO favorites (uuid, f_uuid, created_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (uuid, f_uuid) DO NOTHING
+	`, uuid, fuuid)
+	if err != nil {
+		log.Println("[DB] AddFavorite error:", err)
+	}
+	return err
+}
+
+func RemoveFavorite(db *sql.DB, uuid, fuuid string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM favorites WHERE uuid::text = $1 AND f_uuid::text = $2`, uuid, fuuid)
+	if err != nil {
+		log.Println("[DB] RemoveFavorite error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+type CollectionRow struct {
+	CollectionID string
+	OwnerUUID    string
+	OwnerName    string
+	DUUID        string
+	Name         string
+	Description  string
+	Shared       bool
+	ItemCount    int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+const collectionSelect = `
+	SELECT c.collection_id::text, c.owner_uuid::text, COALESCE(u.name, ''), COALESCE(c.d_uuid::text, ''),
+		c.name, c.description, c.shared,
+		(SELECT COUNT(*) FROM collection_items ci WHERE ci.collection_id = c.collection_id),
+		c.created_at, c.updated_at
+	FROM collections c
+	LEFT JOIN users u ON u.uuid = c.owner_uuid
+`
+
+func scanCollection(scan func(...interface{}) error) (CollectionRow, error) {
+	var c CollectionRow
+	err := scan(&c.CollectionID, &c.OwnerUUID, &c.OwnerName, &c.DUUID, &c.Name, &c.Description, &c.Shared,
+		&c.ItemCount, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// ListCollections returns uuid's collections and the collections shared
+// with department dUUID.
+func ListCollections(db *sql.DB, uuid, dUUID string) ([]CollectionRow, error) {
+	rows, err := db.Query(collectionSelect+`
+		WHERE c.owner_uuid::text = $1 OR (c.shared AND $2 <> '' AND c.d_uuid::text = $2)
+		ORDER BY lower(c.name), c.collection_id
+	`, uuid, dUUID)
+	if err != nil {
+		log.Println("[DB] ListCollections error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []CollectionRow{}
+	for rows.Next() {
+		c, err := scanCollection(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetCollection returns nil, nil when the collection does not exist.
+func GetCollection(db *sql.DB, id string) (*CollectionRow, error) {
+	c, err := scanCollection(db.QueryRow(collectionSelect+` WHERE c.collection_id::text = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetCollection error:", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CollectionNames maps the owner's collection IDs to their names.
+func CollectionNames(db *sql.DB, ownerUUID string) (map[string]string, error) {
+	rows, err := db.Query(`SELECT collection_id::text, name FROM collections WHERE owner_uuid::text = $1`, ownerUUID)
+	if err != nil {
+		log.Println("[DB] CollectionNames error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		out[id] = name
+	}
+	return out, rows.Err()
+}
+
+func InsertCollection(db *sql.DB, c CollectionRow) (CollectionRow, error) {
+	err := db.QueryRow(`
+		INSERT INTO collections (owner_uuid, d_uuid, name, description, shared)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING collection_id::text, created_at, updated_at
+	`, c.OwnerUUID, nullIfEmpty(c.DUUID), c.Name, c.Description, c.Shared).Scan(&c.CollectionID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertCollection error:", err)
+	}
+	return c, err
+}
+
+// UpdateCollection returns sql.ErrNoRows when the collection does not exist.
+func UpdateCollection(db *sql.DB, c CollectionRow) (CollectionRow, error) {
+	err := db.QueryRow(`
+		UPDATE collections
+		SET d_uuid = $2, name = $3, description = $4, shared = $5, updated_at = NOW()
+		WHERE collection_id::text = $1
+		RETURNING updated_at
+	`, c.CollectionID, nullIfEmpty(c.DUUID), c.Name, c.Description, c.Shared).Scan(&c.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateCollection error:", err)
+	}
+	return c, err
+}
+
+// DeleteCollection removes a collection and its items. It returns
+// sql.ErrNoRows when the collection does not exist.
+func DeleteCollection(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM collections WHERE collection_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteCollection error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type CollectionItemRow struct {
+	FUUID    string
+	FileName string
+	Language string
+	Position int
+	AddedAt  time.Time
+}
+
+// ListCollectionItems returns the files in a collection that viewer may
+// see, in order.
+func ListCollectionItems(db *sql.DB, id, viewer string) ([]CollectionItemRow, error) {
+	rows, err := db.Query(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.language, ''), ci.position, ci.added_at
+		FROM collection_items ci
+		JOIN file f ON f.f_uuid = ci.f_uuid
+		WHERE ci.collection_id::text = $2 AND `+fileVisibleTo("f", "$1")+`
+		ORDER BY ci.position
+	`, viewer, id)
+	if err != nil {
+		log.Println("[DB] ListCollectionItems error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []CollectionItemRow{}
+	for rows.Next() {
+		var r CollectionItemRow
+		if err := rows.Scan(&r.FUUID, &r.FileName, &r.Language, &r.Position, &r.AddedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// CollectionItemIDs returns every file in a collection, in order.
+func CollectionItemIDs(db *sql.DB, id string) ([]string, error) {
+	var ids pq.StringArray
+	err := db.QueryRow(`
+		SELECT COALESCE(array_agg(f_uuid::text ORDER BY position), '{}')
+		FROM collection_items WHERE collection_id::text = $1
+	`, id).Scan(&ids)
+	if err != nil {
+		log.Println("[DB] CollectionItemIDs error:", err)
+		return nil, err
+	}
+	return []string(ids), nil
+}
+
+// AddCollectionItem appends fuuid to the collection; it is a no-op when the
+// file is already there.
+func AddCollectionItem(db *sql.DB, id, fuuid string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Lock the collection so concurrent appends get distinct positions.
+	if _, err := tx.Exec(`SELECT 1 FROM collections WHERE collection_id::text = $1 FOR UPDATE`, id); err != nil {
+		log.Println("[DB] AddCollectionItem error:", err)
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO collection_items (collection_id, f_uuid, position, added_at)
+		SELECT $1::uuid, $2::uuid, COALESCE(MAX(position) + 1, 0), NOW()
+		FROM collection_items WHERE collection_id = $1::uuid
+		ON CONFLICT (collection_id, f_uuid) DO NOTHING
+	`, id, fuuid)
+	if err != nil {
+		log.Println("[DB] AddCollectionItem error:", err)
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE collections SET updated_at = NOW() WHERE collection_id::text = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveCollectionItem removes fuuid and closes the gap it leaves.
+func RemoveCollectionItem(db *sql.DB, id, fuuid string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var pos int
+	err = tx.QueryRow(`
+		DELETE FROM collection_items WHERE collection_id::text = $1 AND f_uuid::text = $2
+		RETURNING position
+	`, id, fuuid).Scan(&pos)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		log.Println("[DB] RemoveCollectionItem error:", err)
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE collection_items SET position = position - 1
+		WHERE collection_id::text = $1 AND position > $2
+	`, id, pos); err != nil {
+		log.Println("[DB] RemoveCollectionItem error:", err)
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE collections SET updated_at = NOW() WHERE collection_id::text = $1`, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ReorderCollectionItems sets each file's position to its index in
+// fuuids. The position constraint is deferred, so the swap is checked at
+// commit.
+func ReorderCollectionItems(db *sql.DB, id string, fuuids []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		UPDATE collection_items ci SET position = o.ord - 1
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS o(f_uuid, ord)
+		WHERE ci.collection_id::text = $1 AND ci.f_uuid = o.f_uuid
+	`, id, pq.Array(fuuids)); err != nil {
+		log.Println("[DB] ReorderCollectionItems error:", err)
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE collections SET updated_at = NOW() WHERE collection_id::text = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+func TestCollectionItemPositions(t *testing.T) {
+	db := testDB(t)
+	owner, coll := testID(10), testID(50)
+	mustExec(t, db, `INSERT INTO users (uuid) VALUES ($1)`, owner)
+	mustExec(t, db, `INSERT INTO collections (collection_id, owner_uuid, name) VALUES ($1, $2, 'Reading')`, coll, owner)
+	files := []string{testID(100), testID(101), testID(102), testID(103)}
+	for _, f := range files {
+		mustExec(t, db, `INSERT INTO file (f_uuid) VALUES ($1)`, f)
+		if err := AddCollectionItem(db, coll, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Adding a file twice keeps its place.
+	if err := AddCollectionItem(db, coll, files[0]); err != nil {
+		t.Fatal(err)
+	}
+	check := func(want ...string) {
+		t.Helper()
+		ids, err := CollectionItemIDs(db, coll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalStrings(ids, want) {
+			t.Fatalf("order = %v, want %v", ids, want)
+		}
+		var max int
+		if err := db.QueryRow(`SELECT COALESCE(MAX(position), -1) FROM collection_items WHERE collection_id = $1`, coll).Scan(&max); err != nil {
+			t.Fatal(err)
+		}
+		if max != len(want)-1 {
+			t.Fatalf("positions have gaps: max %d for %d items", max, len(want))
+		}
+	}
+	check(files...)
+
+	// Reversing swaps every position; checked row by row, the unique
+	// constraint would refuse it.
+	if err := ReorderCollectionItems(db, coll, []string{files[3], files[2], files[1], files[0]}); err != nil {
+		t.Fatal(err)
+	}
+	check(files[3], files[2], files[1], files[0])
+
+	// A reorder that would leave two items on one position is refused at
+	// commit and changes nothing.
+	if err := ReorderCollectionItems(db, coll, []string{files[0], files[1]}); err == nil {
+		t.Fatal("expected the duplicate position to be refused")
+	}
+	check(files[3], files[2], files[1], files[0])
+
+	if ok, err := RemoveCollectionItem(db, coll, files[2]); err != nil || !ok {
+		t.Fatalf("remove: %v %v", ok, err)
+	}
+	check(files[3], files[1], files[0])
+	if ok, err := RemoveCollectionItem(db, coll, files[2]); err != nil || ok {
+		t.Fatalf("removing a missing item: %v %v", ok, err)
+	}
+	if err := AddCollectionItem(db, coll, files[2]); err != nil {
+		t.Fatal(err)
+	}
+	check(files[3], files[1], files[0], files[2])
+}
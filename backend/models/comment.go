@@ -0,0 +1,302 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FileCommentMentionRow struct {
+	UUID   string
+	Name   string
+	Handle string
+}
+
+// FileCommentRow is a file_comments row. Page, SpanStart and SpanEnd are 0
+// when unset.
+type FileCommentRow struct {
+	CommentID  string
+	FUUID      string
+	ParentID   string
+	ThreadID   string
+	AuthorUUID string
+	AuthorName string
+	Body       string
+	Page       int
+	SpanStart  int
+	SpanEnd    int
+	Quote      string
+	CreatedAt  time.Time
+	EditedAt   *time.Time
+	DeletedAt  *time.Time
+	Mentions   []FileCommentMentionRow
+}
+
+type FileCommentRevisionRow struct {
+	CommentID string
+	Action    string
+	Body      string
+	EditedBy  string
+	EditedAt  time.Time
+}
+
+const fileCommentSelect = `
+	SELECT c.comment_id::text, c.f_uuid::text, COALESCE(c.parent_id::text, ''), c.thread_id::text, c.author_uuid::text,
+		COALESCE(u.name, ''), c.body, COALESCE(c.page, 0), COALESCE(c.span_start, 0), COALESCE(c.span_end, 0),
+		c.quote, c.created_at, c.edited_at, c.deleted_at
+	FROM file_comments c
+	LEFT JOIN users u ON u.uuid = c.author_uuid
+`
+
+func scanFileComment(scan func(...interface{}) error) (FileCommentRow, error) {
+	var c FileCommentRow
+	err := scan(&c.CommentID, &c.FUUID, &c.ParentID, &c.ThreadID, &c.AuthorUUID, &c.AuthorName, &c.Body,
+		&c.Page, &c.SpanStart, &c.SpanEnd, &c.Quote, &c.CreatedAt, &c.EditedAt, &c.DeletedAt)
+	return c, err
+}
+
+func loadFileCommentMentions(db *sql.DB, list []FileCommentRow) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, len(list))
+	index := map[string]int{}
+	for i, c := range list {
+		ids[i] = c.CommentID
+		index[c.CommentID] = i
+		list[i].Mentions = []FileCommentMentionRow{}
+	}
+	rows, err := db.Query(`
+		SELECT m.comment_id::text, m.uuid::text, COALESCE(u.name, ''), m.handle
+		FROM file_comment_mentions m
+		LEFT JOIN users u ON u.uuid = m.uuid
+		WHERE m.comment_id::text = ANY($1)
+		ORDER BY m.position
+	`, pq.Array(ids))
+	if err != nil {
+		log.Println("[DB] loadFileCommentMentions error:", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var m FileCommentMentionRow
+		if err := rows.Scan(&id, &m.UUID, &m.Name, &m.Handle); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			list[i].Mentions = append(list[i].Mentions, m)
+		}
+	}
+	return rows.Err()
+}
+
+// ListFileComments returns every comment on a file, deleted ones included,
+// oldest first.
+func ListFileComments(db *sql.DB, fuuid string) ([]FileCommentRow, error) {
+	rows, err := db.Query(fileCommentSelect+`
+		WHERE c.f_uuid::text = $1
+		ORDER BY c.created_at, c.comment_id
+	`, fuuid)
+	if err != nil {
+		log.Println("[DB] ListFileComments error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []FileCommentRow{}
+	for rows.Next() {
+		c, err := scanFileComment(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return out, loadFileCommentMentions(db, out)
+}
+
+// GetFileComment returns nil, nil when the file has no such comment.
+func GetFileComment(db *sql.DB, fuuid, id string) (*FileCommentRow, error) {
+	c, err := scanFileComment(db.QueryRow(fileCommentSelect+`
+		WHERE c.f_uuid::text = $1 AND c.comment_id::text = $2
+	`, fuuid, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetFileComment error:", err)
+		return nil, err
+	}
+	list := []FileCommentRow{c}
+	if err := loadFileCommentMentions(db, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+func saveFileCommentMentions(tx *sql.Tx, id string, mentions []FileCommentMentionRow) error {
+	if _, err := tx.Exec(`DELETE FROM file_comment_mentions WHERE comment_id::text = $1`, id); err != nil {
+		return err
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+	uuids := make([]string, len(mentions))
+	handles := make([]string, len(mentions))
+	for i, m := range mentions {
+		uuids[i], handles[i] = m.UUID, m.Handle
+	}
+	_, err := tx.Exec(`
+		INSERT INTO file_comment_mentions (comment_id, uuid, handle, position)
+		SELECT $1::uuid, m.uuid, m.handle, m.ord
+		FROM unnest($2::uuid[], $3::text[]) WITH ORDINALITY AS m(uuid, handle, ord)
+		ON CONFLICT (comment_id, uuid) DO NOTHING
+	`, id, pq.Array(uuids), pq.Array(handles))
+	return err
+}
+
+func insertFileCommentRevision(tx *sql.Tx, r FileCommentRevisionRow) error {
+	_, err := tx.Exec(`
+		INSERT INTO file_comment_revisions (comment_id, action, body, edited_by, edited_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, r.CommentID, r.Action, r.Body, r.EditedBy, r.EditedAt)
+	return err
+}
+
+// InsertFileComment stores c and its mentions. A root comment is its own
+// thread; a reply joins its parent's.
+func InsertFileComment(db *sql.DB, c FileCommentRow) (FileCommentRow, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRow(`
+		WITH id AS (SELECT gen_random_uuid() AS v)
+		INSERT INTO file_comments (comment_id, f_uuid, parent_id, thread_id, author_uuid, body, page, span_start, span_end, quote)
+		SELECT id.v, $1, $2::uuid, COALESCE($3::uuid, id.v), $4, $5, NULLIF($6, 0), NULLIF($7, 0), NULLIF($8, 0), $9
+		FROM id
+		RETURNING comment_id::text, thread_id::text, created_at
+	`, c.FUUID, nullIfEmpty(c.ParentID), nullIfEmpty(c.ThreadID), c.AuthorUUID, c.Body, c.Page, c.SpanStart, c.SpanEnd, c.Quote,
+	).Scan(&c.CommentID, &c.ThreadID, &c.CreatedAt)
+	if err != nil {
+		log.Println("[DB] InsertFileComment error:", err)
+		return c, err
+	}
+	if err := saveFileCommentMentions(tx, c.CommentID, c.Mentions); err != nil {
+		log.Println("[DB] InsertFileComment error:", err)
+		return c, err
+	}
+	return c, tx.Commit()
+}
+
+// UpdateFileComment saves c's new body and mentions and records rev.
+func UpdateFileComment(db *sql.DB, c FileCommentRow, rev FileCommentRevisionRow) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`
+		UPDATE file_comments SET body = $2, edited_at = $3
+		WHERE comment_id::text = $1 AND deleted_at IS NULL
+	`, c.CommentID, c.Body, c.EditedAt)
+	if err != nil {
+		log.Println("[DB] UpdateFileComment error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := insertFileCommentRevision(tx, rev); err != nil {
+		log.Println("[DB] UpdateFileComment error:", err)
+		return err
+	}
+	if err := saveFileCommentMentions(tx, c.CommentID, c.Mentions); err != nil {
+		log.Println("[DB] UpdateFileComment error:", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteFileComment blanks the comment, keeping its place in the thread,
+// and records rev with the removed text.
+func DeleteFileComment(db *sql.DB, id string, rev FileCommentRevisionRow) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`
+		UPDATE file_comments SET body = '', deleted_at = $2
+		WHERE comment_id::text = $1 AND deleted_at IS NULL
+	`, id, rev.EditedAt)
+	if err != nil {
+		log.Println("[DB] DeleteFileComment error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := insertFileCommentRevision(tx, rev); err != nil {
+		log.Println("[DB] DeleteFileComment error:", err)
+		return err
+	}
+	if err := saveFileCommentMentions(tx, id, nil); err != nil {
+		log.Println("[DB] DeleteFileComment error:", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListFileCommentRevisions returns a comment's revisions, oldest first.
+func ListFileCommentRevisions(db *sql.DB, id string) ([]FileCommentRevisionRow, error) {
+	rows, err := db.Query(`
+		SELECT comment_id::text, action, body, COALESCE(edited_by::text, ''), edited_at
+		FROM file_comment_revisions
+		WHERE comment_id::text = $1
+		ORDER BY edited_at, revision_id
+	`, id)
+	if err != nil {
+		log.Println("[DB] ListFileCommentRevisions error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []FileCommentRevisionRow{}
+	for rows.Next() {
+		var r FileCommentRevisionRow
+		if err := rows.Scan(&r.CommentID, &r.Action, &r.Body, &r.EditedBy, &r.EditedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// ListActiveUsersByHandle returns the active users whose email, or its
+// local part, is one of the lower-cased handles.
+func ListActiveUsersByHandle(db *sql.DB, handles []string) ([]UserProfile, error) {
+	rows, err := db.Query("SELECT "+userProfileColumns+` FROM users
+		WHERE COALESCE(is_active, true)
+			AND (lower(email) = ANY($1) OR lower(split_part(email, '@', 1)) = ANY($1))
+		ORDER BY name`, pq.Array(handles))
+	if err != nil {
+		log.Println("[DB] ListActiveUsersByHandle error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserProfile
+	for rows.Next() {
+		p, err := scanUserProfile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
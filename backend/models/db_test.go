@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests in this package that need Postgres run against
+// TEST_DATABASE_URL and are skipped when it is unset. Each test gets a
+// schema of its own, dropped afterwards, holding testSchema: the columns of
+// the Supabase tables the queries under test read, and the tables from
+// sql/ they write, without their row-level security.
+const testSchema = `
+CREATE TABLE department (d_uuid UUID PRIMARY KEY, d_name TEXT);
+CREATE TABLE role (r_uuid UUID PRIMARY KEY, r_name TEXT, d_uuid UUID REFERENCES department(d_uuid));
+CREATE TABLE users (
+    uuid UUID PRIMARY KEY, name TEXT, email TEXT, d_uuid UUID REFERENCES department(d_uuid),
+    r_uuid UUID REFERENCES role(r_uuid), position TEXT, is_active BOOLEAN DEFAULT true
+);
+CREATE TABLE file (
+    f_uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(), f_name TEXT, language TEXT, status TEXT, file_path TEXT,
+    uuid UUID REFERENCES users(uuid), d_uuid UUID REFERENCES department(d_uuid), created_at TIMESTAMPTZ DEFAULT NOW(),
+    sensitivity TEXT NOT NULL DEFAULT 'internal', deleted_at TIMESTAMPTZ, legal_hold BOOLEAN NOT NULL DEFAULT false
+);
+CREATE TABLE file_department (
+    f_uuid UUID REFERENCES file(f_uuid), d_uuid UUID REFERENCES department(d_uuid), is_approved BOOLEAN
+);
+-- sql/file_access_departments.sql adds workspace and pending approval departments.
+CREATE VIEW file_access_departments AS SELECT f_uuid, d_uuid FROM file_department;
+CREATE TABLE ocr (f_uuid UUID REFERENCES file(f_uuid), data TEXT);
+CREATE TABLE summary (f_uuid UUID REFERENCES file(f_uuid), summary TEXT, state TEXT, created_at TIMESTAMPTZ DEFAULT NOW());
+CREATE TABLE notifications (uuid UUID, f_uuid UUID REFERENCES file(f_uuid), is_seen BOOLEAN);
+CREATE TABLE favorites (uuid UUID, f_uuid UUID REFERENCES file(f_uuid) ON DELETE CASCADE);
+CREATE TABLE document_categories (category_id UUID PRIMARY KEY, name TEXT);
+CREATE TABLE document_classifications (
+    f_uuid UUID REFERENCES file(f_uuid) ON DELETE CASCADE, category_id UUID REFERENCES document_categories(category_id)
+);
+CREATE TABLE workflow_templates (
+    template_id UUID PRIMARY KEY DEFAULT gen_random_uuid(), name TEXT NOT NULL, steps JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE workflow_instances (
+    workflow_id UUID PRIMARY KEY DEFAULT gen_random_uuid(), f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'in_progress'
+);
+CREATE TABLE workflow_steps (
+    workflow_id UUID NOT NULL REFERENCES workflow_instances(workflow_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, d_uuid UUID NOT NULL REFERENCES department(d_uuid), status TEXT NOT NULL,
+    PRIMARY KEY (workflow_id, position)
+);
+CREATE TABLE workspaces (
+    workspace_id UUID PRIMARY KEY DEFAULT gen_random_uuid(), name TEXT NOT NULL,
+    owner_d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE RESTRICT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE workspace_departments (
+    workspace_id UUID NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+    d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE CASCADE,
+    status TEXT NOT NULL, joined_at TIMESTAMPTZ,
+    PRIMARY KEY (workspace_id, d_uuid)
+);
+CREATE TABLE collections (
+    collection_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    d_uuid UUID REFERENCES department(d_uuid) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    shared BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE collection_items (
+    collection_id UUID NOT NULL REFERENCES collections(collection_id) ON DELETE CASCADE,
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    position INT NOT NULL CHECK (position >= 0),
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, f_uuid),
+    CONSTRAINT collection_items_position_unique UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY, occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), actor_type TEXT NOT NULL,
+    actor_id TEXT NOT NULL, actor_name TEXT, action TEXT NOT NULL, target_type TEXT NOT NULL, target_id TEXT NOT NULL,
+    ip TEXT, user_agent TEXT, diff JSON, prev_hash TEXT NOT NULL, hash TEXT NOT NULL UNIQUE
+);
+`
+
+// testDB returns a connection whose search_path is a fresh schema holding
+// testSchema. It is limited to one connection so the search_path holds for
+// every query.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("models_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema + `; SET search_path TO ` + schema + `, public`); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("dropping %s: %v", schema, err)
+		}
+		db.Close()
+	})
+	if _, err := db.Exec(testSchema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testID returns a fixed UUID, so failures name recognisable rows.
+func testID(n int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", n)
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func countRows(t *testing.T, db *sql.DB, table, fuuid string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE f_uuid::text = $1`, fuuid).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// Deadline is a dated item shown in digests and reminded about the day before.
+// An empty DUUID means it applies to every department.
+type Deadline struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	DueAt      time.Time `json:"due_at"`
+	FUUID      string    `json:"f_uuid,omitempty"`
+	FileName   string    `json:"f_name,omitempty"`
+	DUUID      string    `json:"d_uuid,omitempty"`
+	Department string    `json:"department,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// deadlineSelect leaves out the names of confidential and restricted files:
+// deadlines go to whole departments, not just the people who may see them.
+const deadlineSelect = `
+	SELECT dl.id::text, dl.title, dl.due_at, COALESCE(dl.f_uuid::text, ''),
+		CASE WHEN COALESCE(f.sensitivity, 'internal') = 'internal' THEN COALESCE(f.f_name, '') ELSE '' END,
+		COALESCE(dl.d_uuid::text, ''), COALESCE(d.d_name, ''), COALESCE(dl.created_by::text, ''), dl.created_at
+	FROM deadlines dl
+	LEFT JOIN file f ON f.f_uuid = dl.f_uuid
+	LEFT JOIN department d ON d.d_uuid = dl.d_uuid
+`
+
+func scanDeadlines(rows *sql.Rows) ([]Deadline, error) {
+	defer rows.Close()
+	var out []Deadline
+	for rows.Next() {
+		var d Deadline
+		if err := rows.Scan(&d.ID, &d.Title, &d.DueAt, &d.FUUID, &d.FileName, &d.DUUID, &d.Department,
+			&d.CreatedBy, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func CreateDeadline(db *sql.DB, d Deadline) (Deadline, error) {
+	err := db.QueryRow(`
+		INSERT INTO deadlines (title, due_at, f_uuid, d_uuid, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id::text, created_at
+	`, d.Title, d.DueAt, nullIfEmpty(d.FUUID), nullIfEmpty(d.DUUID), nullIfEmpty(d.CreatedBy)).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		log.Println("[DB] CreateDeadline error:", err)
+	}
+	return d, err
+}
+
+// ListUpcomingDeadlines returns deadlines for dUUID (and global ones) due in [from, to).
+func ListUpcomingDeadlines(db *sql.DB, dUUID string, from, to time.Time) ([]Deadline, error) {
+	rows, err := db.Query(deadlineSelect+`
+		WHERE (dl.d_uuid IS NULL OR dl.d_uuid::text = $1) AND dl.due_at >= $2 AND dl.due_at < $3
+		ORDER BY dl.due_at
+	`, dUUID, from, to)
+	if err != nil {
+		log.Println("[DB] ListUpcomingDeadlines error:", err)
+		return nil, err
+	}
+	return scanDeadlines(rows)
+}
+
+// ListDeadlinesNeedingReminder returns deadlines due before until that have
+// not been reminded about yet.
+func ListDeadlinesNeedingReminder(db *sql.DB, now, until time.Time) ([]Deadline, error) {
+	rows, err := db.Query(deadlineSelect+`
+		WHERE dl.reminder_sent_at IS NULL AND dl.due_at > $1 AND dl.due_at <= $2
+		ORDER BY dl.due_at
+	`, now, until)
+	if err != nil {
+		log.Println("[DB] ListDeadlinesNeedingReminder error:", err)
+		return nil, err
+	}
+	return scanDeadlines(rows)
+}
+
+func MarkDeadlineReminded(db *sql.DB, id string) error {
+	_, err := db.Exec(`UPDATE deadlines SET reminder_sent_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		log.Println("[DB] MarkDeadlineReminded error:", err)
+	}
+	return err
+}
+
+// ListDepartmentMemberIDs returns active users of dUUID, or of every
+// department when dUUID is empty.
+func ListDepartmentMemberIDs(db *sql.DB, dUUID string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT uuid::text FROM users
+		WHERE ($1 = '' OR d_uuid::text = $1) AND COALESCE(is_active, true)
+	`, dUUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
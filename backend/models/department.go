@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+// ErrDepartmentInUse is returned when a department still has users, files,
+// workflow steps or workspaces and no reassignment target was given.
+var ErrDepartmentInUse = errors.New("department still has users, files, workflows or workspaces")
+
+// ErrReassignToSelf is returned when a department's users and files would be
+// moved to the department being deleted.
+var ErrReassignToSelf = errors.New("cannot reassign to the department being deleted")
+
+// ErrRoleInUse is returned when users still hold the role being deleted.
+var ErrRoleInUse = errors.New("role is still assigned to users")
+
+type Role struct {
+	RUUID string `json:"r_uuid"`
+	RName string `json:"r_name"`
+	DUUID string `json:"d_uuid"`
+}
+
+// DepartmentUsage counts what still references a department.
+type DepartmentUsage struct {
+	Users int `json:"users"`
+	Files int `json:"files"`
+	Roles int `json:"roles"`
+	// Workflows counts the approvals and templates with a step for the
+	// department.
+	Workflows int `json:"workflows"`
+	// Workspaces counts the workspaces the department owns.
+	Workspaces int `json:"workspaces"`
+}
+
+// inUse reports whether deleting the department needs a reassignment
+// target; roles are deleted with it.
+func (u DepartmentUsage) inUse() bool {
+	return u.Users+u.Files+u.Workflows+u.Workspaces > 0
+}
+
+// departmentUsageQuery counts what refers to department $1.
+const departmentUsageQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM users WHERE d_uuid = $1),
+		(SELECT COUNT(*) FROM file_department WHERE d_uuid = $1),
+		(SELECT COUNT(*) FROM role WHERE d_uuid = $1),
+		(SELECT COUNT(DISTINCT workflow_id) FROM workflow_steps WHERE d_uuid = $1)
+			+ (SELECT COUNT(*) FROM workflow_templates WHERE ` + templateNamesDepartment + `),
+		(SELECT COUNT(*) FROM workspaces WHERE owner_d_uuid = $1)
+`
+
+// templateNamesDepartment holds for workflow_templates rows with a step
+// for department $1.
+const templateNamesDepartment = `EXISTS (
+	SELECT 1 FROM jsonb_array_elements(steps) ts WHERE lower(ts->>'d_uuid') = lower($1::text)
+)`
+
+// GetDepartmentByUUID returns nil, nil when the department does not exist.
+func GetDepartmentByUUID(db *sql.DB, dUUID string) (*Department, error) {
+	var d Department
+	err := db.QueryRow("SELECT d_uuid, d_name FROM department WHERE d_uuid = $1", dUUID).Scan(&d.DUUID, &d.DName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetDepartmentByName matches case-insensitively; returns nil, nil when absent.
+func GetDepartmentByName(db *sql.DB, name string) (*Department, error) {
+	var d Department
+	err := db.QueryRow("SELECT d_uuid, d_name FROM department WHERE LOWER(d_name) = LOWER($1) LIMIT 1", name).Scan(&d.DUUID, &d.DName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func CreateDepartment(db *sql.DB, name string) (Department, error) {
+	d := Department{DName: name}
+	err := db.QueryRow("INSERT INTO department (d_name) VALUES ($1) RETURNING d_uuid", name).Scan(&d.DUUID)
+	if err != nil {
+		log.Println("[DB] CreateDepartment error:", err)
+	}
+	return d, err
+}
+
+func RenameDepartment(db *sql.DB, dUUID, name string) error {
+	res, err := db.Exec("UPDATE department SET d_name = $1 WHERE d_uuid = $2", name, dUUID)
+	if err != nil {
+		log.Println("[DB] RenameDepartment error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func GetDepartmentUsage(db *sql.DB, dUUID string) (DepartmentUsage, error) {
+	var u DepartmentUsage
+	err := db.QueryRow(departmentUsageQuery, dUUID).Scan(&u.Users, &u.Files, &u.Roles, &u.Workflows, &u.Workspaces)
+	return u, err
+}
+
+// DeleteDepartment removes a department and its roles. If users, file links,
+// workflow steps or owned workspaces remain, reassignTo must name another
+// department: users move there (their department-scoped role is cleared);
+// file links, the steps of approvals and templates, workspace ownership and
+// workspace memberships are re-pointed.
+func DeleteDepartment(db *sql.DB, dUUID, reassignTo string) error {
+	if reassignTo != "" && strings.EqualFold(reassignTo, dUUID) {
+		return ErrReassignToSelf
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var u DepartmentUsage
+	if err := tx.QueryRow(departmentUsageQuery, dUUID).Scan(&u.Users, &u.Files, &u.Roles, &u.Workflows, &u.Workspaces); err != nil {
+		return err
+	}
+
+	if u.inUse() {
+		if reassignTo == "" {
+			return ErrDepartmentInUse
+		}
+		if _, err := tx.Exec("UPDATE users SET d_uuid = $2, r_uuid = NULL WHERE d_uuid = $1", dUUID, reassignTo); err != nil {
+			return err
+		}
+		// Re-point links, skipping files already shared with the target.
+		if _, err := tx.Exec(`
+			UPDATE file_department SET d_uuid = $2
+			WHERE d_uuid = $1
+			  AND NOT EXISTS (
+				SELECT 1 FROM file_department fd2
+				WHERE fd2.f_uuid = file_department.f_uuid AND fd2.d_uuid = $2
+			  )
+		`, dUUID, reassignTo); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM file_department WHERE d_uuid = $1", dUUID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE workflow_steps SET d_uuid = $2 WHERE d_uuid = $1", dUUID, reassignTo); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE workflow_templates SET updated_at = NOW(), steps = (
+				SELECT jsonb_agg(CASE WHEN lower(ts->>'d_uuid') = lower($1::text)
+					THEN jsonb_set(ts, '{d_uuid}', to_jsonb($2::text)) ELSE ts END ORDER BY n)
+				FROM jsonb_array_elements(steps) WITH ORDINALITY AS e(ts, n)
+			)
+			WHERE `+templateNamesDepartment, dUUID, reassignTo); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE workspaces SET owner_d_uuid = $2, updated_at = NOW() WHERE owner_d_uuid = $1", dUUID, reassignTo); err != nil {
+			return err
+		}
+		// The target takes over memberships: it joins where the department
+		// had joined, and is invited where it had only been invited.
+		if _, err := tx.Exec(`
+			UPDATE workspace_departments t SET status = 'joined', joined_at = s.joined_at
+			FROM workspace_departments s
+			WHERE s.d_uuid = $1 AND s.status = 'joined'
+			  AND t.workspace_id = s.workspace_id AND t.d_uuid = $2 AND t.status = 'invited'
+		`, dUUID, reassignTo); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE workspace_departments SET d_uuid = $2
+			WHERE d_uuid = $1
+			  AND NOT EXISTS (
+				SELECT 1 FROM workspace_departments wd2
+				WHERE wd2.workspace_id = workspace_departments.workspace_id AND wd2.d_uuid = $2
+			  )
+		`, dUUID, reassignTo); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM role WHERE d_uuid = $1", dUUID); err != nil {
+		return err
+	}
+	res, err := tx.Exec("DELETE FROM department WHERE d_uuid = $1", dUUID)
+	if err != nil {
+		log.Println("[DB] DeleteDepartment error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// ListRoles returns roles ordered by name; dUUID == "" lists every department.
+func ListRoles(db *sql.DB, dUUID string) ([]Role, error) {
+	query := "SELECT r_uuid, r_name, COALESCE(d_uuid::text, '') FROM role"
+	args := []interface{}{}
+	if dUUID != "" {
+		query += " WHERE d_uuid = $1"
+		args = append(args, dUUID)
+	}
+	query += " ORDER BY r_name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var r Role
+		if err := rows.Scan(&r.RUUID, &r.RName, &r.DUUID); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// GetRoleByUUID returns nil, nil when the role does not exist.
+func GetRoleByUUID(db *sql.DB, rUUID string) (*Role, error) {
+	var r Role
+	err := db.QueryRow("SELECT r_uuid, r_name, COALESCE(d_uuid::text, '') FROM role WHERE r_uuid = $1", rUUID).Scan(&r.RUUID, &r.RName, &r.DUUID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetRoleByName matches case-insensitively within a department; nil, nil when absent.
+func GetRoleByName(db *sql.DB, dUUID, name string) (*Role, error) {
+	var r Role
+	err := db.QueryRow("SELECT r_uuid, r_name, d_uuid::text FROM role WHERE d_uuid = $1 AND LOWER(r_name) = LOWER($2) LIMIT 1",
+		dUUID, name).Scan(&r.RUUID, &r.RName, &r.DUUID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func CreateRole(db *sql.DB, name, dUUID string) (Role, error) {
+	r := Role{RName: name, DUUID: dUUID}
+	err := db.QueryRow("INSERT INTO role (r_name, d_uuid) VALUES ($1, $2) RETURNING r_uuid", name, dUUID).Scan(&r.RUUID)
+	if err != nil {
+		log.Println("[DB] CreateRole error:", err)
+	}
+	return r, err
+}
+
+func RenameRole(db *sql.DB, rUUID, name string) error {
+	res, err := db.Exec("UPDATE role SET r_name = $1 WHERE r_uuid = $2", name, rUUID)
+	if err != nil {
+		log.Println("[DB] RenameRole error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRole refuses to remove a role that users still hold.
+func DeleteRole(db *sql.DB, rUUID string) error {
+	var holders int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE r_uuid = $1", rUUID).Scan(&holders); err != nil {
+		return err
+	}
+	if holders > 0 {
+		return ErrRoleInUse
+	}
+	res, err := db.Exec("DELETE FROM role WHERE r_uuid = $1", rUUID)
+	if err != nil {
+		log.Println("[DB] DeleteRole error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DepartmentWithUsage is a department plus the counts that block deletion.
+type DepartmentWithUsage struct {
+	Department
+	DepartmentUsage
+}
+
+func ListDepartmentsWithUsage(db *sql.DB) ([]DepartmentWithUsage, error) {
+	rows, err := db.Query(`
+		SELECT d.d_uuid, d.d_name,
+			(SELECT COUNT(*) FROM users u WHERE u.d_uuid = d.d_uuid),
+			(SELECT COUNT(*) FROM file_department fd WHERE fd.d_uuid = d.d_uuid),
+			(SELECT COUNT(*) FROM role r WHERE r.d_uuid = d.d_uuid)
+		FROM department d
+		ORDER BY d.d_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depts := []DepartmentWithUsage{}
+	for rows.Next() {
+		var d DepartmentWithUsage
+		if err := rows.Scan(&d.DUUID, &d.DName, &d.Users, &d.Files, &d.Roles); err != nil {
+			return nil, err
+		}
+		depts = append(depts, d)
+	}
+	return depts, rows.Err()
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDeleteDepartment(t *testing.T) {
+	db := testDB(t)
+	ops, fin, legal := testID(1), testID(2), testID(3)
+	mustExec(t, db, `INSERT INTO department (d_uuid, d_name) VALUES ($1, 'Operations'), ($2, 'Finance'), ($3, 'Legal')`, ops, fin, legal)
+	role := testID(20)
+	mustExec(t, db, `INSERT INTO role (r_uuid, r_name, d_uuid) VALUES ($1, 'Clerk', $2)`, role, ops)
+	asha, ravi, meera := testID(10), testID(11), testID(12)
+	mustExec(t, db, `INSERT INTO users (uuid, d_uuid, r_uuid) VALUES ($1, $4, $5), ($2, $4, NULL), ($3, $6, NULL)`, asha, ravi, meera, ops, role, fin)
+	shared, both := testID(100), testID(101)
+	mustExec(t, db, `INSERT INTO file (f_uuid) VALUES ($1), ($2)`, shared, both)
+	mustExec(t, db, `INSERT INTO file_department (f_uuid, d_uuid, is_approved) VALUES ($1, $3, true), ($2, $3, true), ($2, $4, false)`, shared, both, ops, fin)
+
+	if err := DeleteDepartment(db, ops, ""); err != ErrDepartmentInUse {
+		t.Fatalf("expected ErrDepartmentInUse, got %v", err)
+	}
+	if err := DeleteDepartment(db, ops, ops); err != ErrReassignToSelf {
+		t.Fatalf("expected ErrReassignToSelf, got %v", err)
+	}
+	if d, err := GetDepartmentByUUID(db, ops); err != nil || d == nil {
+		t.Fatalf("a refused delete must keep the department: %v %v", d, err)
+	}
+	if u, _ := GetDepartmentUsage(db, ops); u.Users != 2 || u.Files != 2 || u.Roles != 1 {
+		t.Fatalf("a refused delete must not move anything: %+v", u)
+	}
+
+	if err := DeleteDepartment(db, ops, fin); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := GetDepartmentByUUID(db, ops); err != nil || d != nil {
+		t.Fatalf("department should be gone: %v %v", d, err)
+	}
+	rows, err := db.Query(`SELECT uuid::text, COALESCE(d_uuid::text, ''), r_uuid IS NULL FROM users ORDER BY uuid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uuid, dept string
+		var noRole bool
+		if err := rows.Scan(&uuid, &dept, &noRole); err != nil {
+			t.Fatal(err)
+		}
+		if dept != fin || !noRole {
+			t.Fatalf("user %s should be in Finance without a role, got %s (no role: %v)", uuid, dept, noRole)
+		}
+	}
+	var links []string
+	linkRows, err := db.Query(`SELECT f_uuid::text || ' ' || d_uuid::text FROM file_department ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer linkRows.Close()
+	for linkRows.Next() {
+		var l string
+		if err := linkRows.Scan(&l); err != nil {
+			t.Fatal(err)
+		}
+		links = append(links, l)
+	}
+	// The file already shared with Finance keeps a single link.
+	if want := []string{shared + " " + fin, both + " " + fin}; !equalStrings(links, want) {
+		t.Fatalf("file links = %v, want %v", links, want)
+	}
+	if u, _ := GetDepartmentUsage(db, ops); u.Roles != 0 {
+		t.Fatalf("the department's roles should be gone: %+v", u)
+	}
+
+	// Nothing in use: no target needed.
+	if err := DeleteDepartment(db, legal, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteDepartment(db, legal, ""); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows for a missing department, got %v", err)
+	}
+}
+
+func TestDeleteDepartmentMovesWorkflowSteps(t *testing.T) {
+	db := testDB(t)
+	hr, fin := testID(1), testID(2)
+	mustExec(t, db, `INSERT INTO department (d_uuid, d_name) VALUES ($1, 'HR'), ($2, 'Finance')`, hr, fin)
+	file, workflow, template := testID(100), testID(200), testID(300)
+	mustExec(t, db, `INSERT INTO file (f_uuid) VALUES ($1)`, file)
+	mustExec(t, db, `INSERT INTO workflow_instances (workflow_id, f_uuid) VALUES ($1, $2)`, workflow, file)
+	mustExec(t, db, `INSERT INTO workflow_steps (workflow_id, position, d_uuid, status) VALUES ($1, 0, $2, 'approved'), ($1, 1, $3, 'pending')`, workflow, fin, hr)
+	mustExec(t, db, `INSERT INTO workflow_templates (template_id, name, steps) VALUES ($1, 'Hiring', $2)`, template,
+		`[{"name":"Own head","d_uuid":""},{"name":"HR","d_uuid":"`+hr+`"},{"name":"Finance","d_uuid":"`+fin+`"}]`)
+
+	// Only workflows refer to HR; its delete still needs a target.
+	if u, _ := GetDepartmentUsage(db, hr); u.Users != 0 || u.Files != 0 || u.Workflows != 2 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if err := DeleteDepartment(db, hr, ""); err != ErrDepartmentInUse {
+		t.Fatalf("expected ErrDepartmentInUse, got %v", err)
+	}
+
+	if err := DeleteDepartment(db, hr, fin); err != nil {
+		t.Fatal(err)
+	}
+	var stepDept string
+	if err := db.QueryRow(`SELECT d_uuid::text FROM workflow_steps WHERE workflow_id = $1 AND position = 1`, workflow).Scan(&stepDept); err != nil {
+		t.Fatal(err)
+	}
+	if stepDept != fin {
+		t.Fatalf("pending step should have moved to Finance, got %s", stepDept)
+	}
+	var names, depts pq.StringArray
+	if err := db.QueryRow(`
+		SELECT array_agg(s->>'name' ORDER BY n), array_agg(s->>'d_uuid' ORDER BY n)
+		FROM workflow_templates, jsonb_array_elements(steps) WITH ORDINALITY AS e(s, n)
+		WHERE template_id = $1
+	`, template).Scan(&names, &depts); err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(names, []string{"Own head", "HR", "Finance"}) || !equalStrings(depts, []string{"", fin, fin}) {
+		t.Fatalf("template steps = %v %v", names, depts)
+	}
+}
+
+func TestDeleteDepartmentMovesWorkspaces(t *testing.T) {
+	db := testDB(t)
+	ops, fin, legal := testID(1), testID(2), testID(3)
+	mustExec(t, db, `INSERT INTO department (d_uuid, d_name) VALUES ($1, 'Operations'), ($2, 'Finance'), ($3, 'Legal')`, ops, fin, legal)
+	owned, joined, invited := testID(400), testID(401), testID(402)
+	mustExec(t, db, `INSERT INTO workspaces (workspace_id, name, owner_d_uuid) VALUES ($1, 'Contracts', $4), ($2, 'Budget', $5), ($3, 'Audit', $5)`,
+		owned, joined, invited, legal, ops)
+	mustExec(t, db, `
+		INSERT INTO workspace_departments (workspace_id, d_uuid, status, joined_at) VALUES
+			($1, $4, 'joined', NOW()), ($1, $5, 'invited', NULL),
+			($2, $6, 'joined', NOW()), ($2, $4, 'joined', NOW()),
+			($3, $6, 'joined', NOW()), ($3, $4, 'invited', NULL), ($3, $5, 'joined', NOW())
+	`, owned, joined, invited, legal, fin, ops)
+
+	if u, _ := GetDepartmentUsage(db, legal); u.Workspaces != 1 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	if err := DeleteDepartment(db, legal, ""); err != ErrDepartmentInUse {
+		t.Fatalf("expected ErrDepartmentInUse, got %v", err)
+	}
+
+	if err := DeleteDepartment(db, legal, fin); err != nil {
+		t.Fatal(err)
+	}
+	var owner string
+	if err := db.QueryRow(`SELECT owner_d_uuid::text FROM workspaces WHERE workspace_id = $1`, owned).Scan(&owner); err != nil {
+		t.Fatal(err)
+	}
+	if owner != fin {
+		t.Fatalf("Finance should own the workspace, got %s", owner)
+	}
+	// Every workspace survives, with Finance joined in Legal's place.
+	for _, ws := range []string{owned, joined, invited} {
+		var status string
+		if err := db.QueryRow(`SELECT status FROM workspace_departments WHERE workspace_id = $1 AND d_uuid = $2`, ws, fin).Scan(&status); err != nil {
+			t.Fatalf("workspace %s: %v", ws, err)
+		}
+		if status != "joined" {
+			t.Fatalf("workspace %s: Finance is %s", ws, status)
+		}
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM workspace_departments WHERE workspace_id = $1`, joined).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("the Budget workspace should keep Operations and Finance, got %d %v", n, err)
+	}
+}
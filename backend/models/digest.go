@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// DigestSubscriber is an active user who gets a batched digest.
+type DigestSubscriber struct {
+	UUID  string
+	Name  string
+	Email string
+	DUUID string
+}
+
+// ListDigestSubscribers returns active users on frequency who have not yet
+// had a digest for periodKey.
+func ListDigestSubscribers(db *sql.DB, frequency, periodKey string) ([]DigestSubscriber, error) {
+	rows, err := db.Query(`
+		SELECT u.uuid::text, COALESCE(u.name, ''), u.email, COALESCE(u.d_uuid::text, '')
+		FROM users u
+		JOIN notification_preferences p ON p.uuid = u.uuid
+		WHERE p.digest_frequency = $1 AND p.email_enabled
+			AND COALESCE(u.is_active, true) AND COALESCE(u.email, '') <> ''
+			AND NOT EXISTS (SELECT 1 FROM digest_deliveries dd WHERE dd.uuid = u.uuid AND dd.period_key = $2)
+	`, frequency, periodKey)
+	if err != nil {
+		log.Println("[DB] ListDigestSubscribers error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DigestSubscriber
+	for rows.Next() {
+		var s DigestSubscriber
+		if err := rows.Scan(&s.UUID, &s.Name, &s.Email, &s.DUUID); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// DigestFile is one file in one department group of a digest.
+type DigestFile struct {
+	FUUID      string
+	FileName   string
+	Department string
+	UploadedAt time.Time
+	Summary    string
+	// Sensitivity is the file's level (see access).
+	Sensitivity string
+}
+
+// ListDigestFiles returns files the user was notified about, or that were
+// shared with their department, in [since, until). A file shared with
+// several departments is listed once per department. Files the user may not
+// see are left out, and Sensitivity lets the caller withhold summaries.
+func ListDigestFiles(db *sql.DB, uuid, dUUID string, since, until time.Time) ([]DigestFile, error) {
+	rows, err := db.Query(`
+		WITH visible AS (
+			SELECT f_uuid FROM notifications
+			WHERE uuid = $1 AND created_at >= $3 AND created_at < $4
+			UNION
+			SELECT f_uuid FROM file_department
+			WHERE d_uuid::text = $2 AND created_at >= $3 AND created_at < $4
+		)
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(d.d_name, 'Unassigned'),
+			COALESCE(f.uploaded_at, f.created_at, NOW()),
+			COALESCE((SELECT s.summary FROM summary s
+				WHERE s.f_uuid = f.f_uuid AND COALESCE(s.summary, '') <> ''
+				ORDER BY s.created_at DESC LIMIT 1), ''),
+			COALESCE(f.sensitivity, 'internal')
+		FROM visible v
+		JOIN file f ON f.f_uuid = v.f_uuid
+		LEFT JOIN file_department fd ON fd.f_uuid = f.f_uuid
+		LEFT JOIN department d ON d.d_uuid = fd.d_uuid
+		WHERE `+fileVisibleTo("f", "$1")+`
+		ORDER BY d.d_name, f.created_at DESC
+	`, uuid, dUUID, since, until)
+	if err != nil {
+		log.Println("[DB] ListDigestFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DigestFile
+	for rows.Next() {
+		var f DigestFile
+		if err := rows.Scan(&f.FUUID, &f.FileName, &f.Department, &f.UploadedAt, &f.Summary, &f.Sensitivity); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// RecordDigestDelivery marks periodKey as delivered for uuid and queues entry
+// (nil for an empty digest) in one transaction. It returns false when the
+// period was already recorded, so each period is sent at most once.
+func RecordDigestDelivery(db *sql.DB, uuid, periodKey string, files, deadlines int, entry *OutboxEntry) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO digest_deliveries (uuid, period_key, file_count, deadline_count)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (uuid, period_key) DO NOTHING
+	`, uuid, periodKey, files, deadlines)
+	if err != nil {
+		log.Println("[DB] RecordDigestDelivery error:", err)
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if entry != nil {
+		if _, err := insertOutboxEntries(tx, []OutboxEntry{*entry}); err != nil {
+			log.Println("[DB] RecordDigestDelivery enqueue error:", err)
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
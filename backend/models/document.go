@@ -193,16 +193,18 @@ type Document struct {
 	Status     string `json:"status"`
 	CreatedAt  string `json:"created_at,omitempty"`
 	UploadedAt string `json:"uploaded_at,omitempty"`
+	// Sensitivity is internal, confidential or restricted (see access).
+	Sensitivity string `json:"sensitivity"`
 }
 
 func InsertDocument(db *sql.DB, doc Document) (string, error) {
 	query := `
-        INSERT INTO file (f_name, language, file_path, d_uuid, status, created_at, uploaded_at)
-        VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+        INSERT INTO file (f_name, language, file_path, d_uuid, status, sensitivity, created_at, uploaded_at)
+        VALUES ($1, $2, $3, $4, $5, COALESCE($6, 'internal'), NOW(), NOW())
         RETURNING f_uuid
     `
 	var fuuid string
-	err := db.QueryRow(query, doc.FileName, doc.Language, doc.FilePath, doc.DUUID, doc.Status).Scan(&fuuid)
+	err := db.QueryRow(query, doc.FileName, doc.Language, doc.FilePath, doc.DUUID, doc.Status, nullIfEmpty(doc.Sensitivity)).Scan(&fuuid)
 	if err != nil {
 		log.Printf("InsertDocument DB error: %+v\n", err)
 		return "", err
@@ -220,7 +222,7 @@ func InsertFileDepartment(db *sql.DB, f_uuid, d_uuid string) error {
 }
 
 func GetAllFiles(db *sql.DB) ([]Document, error) {
-	rows, err := db.QueryContext(context.Background(), "SELECT f_uuid, f_name, language, COALESCE(uuid::text, ''), file_path, COALESCE(d_uuid::text, ''), COALESCE(status, ''), COALESCE(uploaded_at::text, '') FROM file")
+	rows, err := db.QueryContext(context.Background(), "SELECT f_uuid, f_name, language, COALESCE(uuid::text, ''), file_path, COALESCE(d_uuid::text, ''), COALESCE(status, ''), COALESCE(uploaded_at::text, ''), sensitivity FROM file")
 	if err != nil {
 		return nil, err
 	}
@@ -229,7 +231,7 @@ func GetAllFiles(db *sql.DB) ([]Document, error) {
 	var files []Document
 	for rows.Next() {
 		var doc Document
-		err := rows.Scan(&doc.FUUID, &doc.FileName, &doc.Language, &doc.UUID, &doc.FilePath, &doc.DUUID, &doc.Status, &doc.UploadedAt)
+		err := rows.Scan(&doc.FUUID, &doc.FileName, &doc.Language, &doc.UUID, &doc.FilePath, &doc.DUUID, &doc.Status, &doc.UploadedAt, &doc.Sensitivity)
 		if err != nil {
 			return nil, err
 		}
@@ -239,10 +241,10 @@ func GetAllFiles(db *sql.DB) ([]Document, error) {
 }
 
 func GetFileByUUID(db *sql.DB, fuuid string) (*Document, error) {
-	query := "SELECT f_uuid, f_name, language, COALESCE(uuid::text, ''), file_path, COALESCE(d_uuid::text, ''), COALESCE(status, ''), COALESCE(uploaded_at::text, '') FROM file WHERE f_uuid = $1"
+	query := "SELECT f_uuid, f_name, language, COALESCE(uuid::text, ''), file_path, COALESCE(d_uuid::text, ''), COALESCE(status, ''), COALESCE(uploaded_at::text, ''), sensitivity FROM file WHERE f_uuid = $1"
 	row := db.QueryRowContext(context.Background(), query, fuuid)
 	var doc Document
-	err := row.Scan(&doc.FUUID, &doc.FileName, &doc.Language, &doc.UUID, &doc.FilePath, &doc.DUUID, &doc.Status, &doc.UploadedAt)
+	err := row.Scan(&doc.FUUID, &doc.FileName, &doc.Language, &doc.UUID, &doc.FilePath, &doc.DUUID, &doc.Status, &doc.UploadedAt, &doc.Sensitivity)
 	if err != nil {
 		return nil, err
 	}
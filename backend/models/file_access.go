@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FileAccess is what access checks need to know about a file.
+type FileAccess struct {
+	FUUID       string
+	FileName    string
+	FilePath    string
+	OwnerUUID   string
+	Sensitivity string
+	Departments []string
+	// WorkspaceDepartments have joined a workspace the file is linked into.
+	WorkspaceDepartments []string
+	// WorkflowDepartments have a pending step of an approval on the file.
+	WorkflowDepartments []string
+	// ArchivedAt is set once retention moved the object to the archive
+	// bucket.
+	ArchivedAt *time.Time
+}
+
+// GetFileAccess returns nil, nil when the file does not exist or is in the
+// trash.
+func GetFileAccess(db *sql.DB, fuuid string) (*FileAccess, error) {
+	var f FileAccess
+	var depts, workspaceDepts, workflowDepts pq.StringArray
+	err := db.QueryRow(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.file_path, ''), COALESCE(f.uuid::text, ''), COALESCE(f.sensitivity, 'internal'),
+			COALESCE((SELECT array_agg(fd.d_uuid::text) FROM file_department fd WHERE fd.f_uuid = f.f_uuid), '{}'),
+			COALESCE((
+				SELECT array_agg(DISTINCT wd.d_uuid::text)
+				FROM workspace_files wf
+				JOIN workspace_departments wd ON wd.workspace_id = wf.workspace_id AND wd.status = 'joined'
+				WHERE wf.f_uuid = f.f_uuid
+			), '{}'),
+			COALESCE((
+				SELECT array_agg(DISTINCT ws.d_uuid::text)
+				FROM workflow_instances wi
+				JOIN workflow_steps ws ON ws.workflow_id = wi.workflow_id AND ws.status = 'pending'
+				WHERE wi.f_uuid = f.f_uuid AND wi.status = 'in_progress'
+			), '{}'),
+			f.archived_at
+		FROM file f
+		WHERE f.f_uuid::text = $1 AND f.deleted_at IS NULL
+	`, fuuid).Scan(&f.FUUID, &f.FileName, &f.FilePath, &f.OwnerUUID, &f.Sensitivity, &depts, &workspaceDepts, &workflowDepts, &f.ArchivedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetFileAccess error:", err)
+		return nil, err
+	}
+	f.Departments = []string(depts)
+	f.WorkspaceDepartments = []string(workspaceDepts)
+	f.WorkflowDepartments = []string(workflowDepts)
+	return &f, nil
+}
+
+// SetFileSensitivity changes a file's level. It returns sql.ErrNoRows when
+// the file does not exist.
+func SetFileSensitivity(db *sql.DB, fuuid, level string) error {
+	res, err := db.Exec(`UPDATE file SET sensitivity = $2 WHERE f_uuid::text = $1`, fuuid, level)
+	if err != nil {
+		log.Println("[DB] SetFileSensitivity error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// fileVisibleTo is a condition that holds when the user whose uuid is the
+// query parameter param may see file alias f. It is the SQL form of
+// access.Check for list queries; keep the two in step. Like Check it sees
+// nothing for unknown or deactivated users. The file_access_departments
+// view is file_department plus the departments of workspaces the file is
+// linked into and of its pending approval steps
+// (sql/file_access_departments.sql). Files in the trash are visible to no
+// one.
+func fileVisibleTo(f, param string) string {
+	return fmt.Sprintf(`(%[1]s.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM users au WHERE au.uuid::text = %[2]s AND COALESCE(au.is_active, true))
+		AND (COALESCE(%[1]s.sensitivity, 'internal') = 'internal'
+		OR %[1]s.uuid::text = %[2]s
+		OR EXISTS (
+			SELECT 1 FROM users vu JOIN file_access_departments vfd ON vfd.d_uuid = vu.d_uuid
+			WHERE vu.uuid::text = %[2]s AND vfd.f_uuid = %[1]s.f_uuid
+				AND (%[1]s.sensitivity = 'confidential' OR vu.position = 'head')
+		)))`, f, param)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+// TestFileVisibleToMatchesAccessCheck lists the files each user sees and
+// compares them with what access.Check allows.
+func TestFileVisibleToMatchesAccessCheck(t *testing.T) {
+	db := testDB(t)
+	ops, fin := testID(1), testID(2)
+	mustExec(t, db, `INSERT INTO department (d_uuid, d_name) VALUES ($1, 'Operations'), ($2, 'Finance')`, ops, fin)
+	viewers := []access.Viewer{
+		{UUID: testID(10), DUUID: ops, Position: "regular", Active: true},
+		{UUID: testID(11), DUUID: ops, Position: "regular", Active: true},
+		{UUID: testID(12), DUUID: ops, Position: "head", Active: true},
+		{UUID: testID(13), DUUID: fin, Position: "head", Active: true},
+		// Deactivated, with a token that has not expired yet.
+		{UUID: testID(14), DUUID: ops, Position: "head", Active: false},
+	}
+	for _, v := range viewers {
+		mustExec(t, db, `INSERT INTO users (uuid, name, d_uuid, position, is_active) VALUES ($1, $2, $3, $4, $5)`,
+			v.UUID, v.UUID, v.DUUID, v.Position, v.Active)
+	}
+	// No profile row at all.
+	viewers = append(viewers, access.Viewer{UUID: testID(15)})
+	owner := viewers[0].UUID
+	files := []access.File{
+		{FUUID: testID(100), OwnerUUID: owner, Sensitivity: access.Internal},
+		{FUUID: testID(101), OwnerUUID: owner, Sensitivity: access.Confidential, Departments: []string{ops}},
+		{FUUID: testID(102), OwnerUUID: owner, Sensitivity: access.Restricted, Departments: []string{ops}},
+		{FUUID: testID(103), OwnerUUID: owner, Sensitivity: access.Confidential},
+		{FUUID: testID(104), OwnerUUID: viewers[3].UUID, Sensitivity: access.Restricted, Departments: []string{fin}},
+	}
+	for _, f := range files {
+		mustExec(t, db, `INSERT INTO file (f_uuid, f_name, uuid, sensitivity) VALUES ($1, $2, $3, $4)`, f.FUUID, f.FUUID, f.OwnerUUID, f.Sensitivity)
+		for _, d := range f.Departments {
+			mustExec(t, db, `INSERT INTO file_department (f_uuid, d_uuid) VALUES ($1, $2)`, f.FUUID, d)
+		}
+	}
+	trashed := testID(105)
+	mustExec(t, db, `INSERT INTO file (f_uuid, uuid, deleted_at) VALUES ($1, $2, $3)`, trashed, owner, time.Now())
+
+	for _, v := range viewers {
+		want := []string{}
+		for _, f := range files {
+			if access.Check(v, f).Allowed {
+				want = append(want, f.FUUID)
+			}
+		}
+		items, err := ListFiles(db, v.UUID, FileListFilter{}, FileSortName, false, nil, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, it := range items {
+			got = append(got, it.FUUID)
+		}
+		sort.Strings(got)
+		if !equalStrings(got, want) {
+			t.Fatalf("viewer %s sees %v, access.Check allows %v", v.UUID, got, want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// File listing sort fields.
+const (
+	FileSortCreatedAt = "created_at"
+	FileSortName      = "name"
+)
+
+// FileListFilter narrows a file listing. Zero values do not filter.
+type FileListFilter struct {
+	DUUID      string // shared with this department (file_department)
+	Language   string
+	Status     string
+	Uploader   string // uploader's uuid
+	From       *time.Time
+	To         *time.Time
+	HasSummary *bool
+	CategoryID string
+	Query      string // substring of the file name
+}
+
+// FileListCursor is the position after the last item of a page: that
+// item's SortValue and its f_uuid as the tie-breaker. For FileSortCreatedAt
+// the value is an RFC 3339 timestamp.
+type FileListCursor struct {
+	Value string
+	FUUID string
+}
+
+type FileDepartmentRef struct {
+	DUUID      string `json:"d_uuid"`
+	DName      string `json:"d_name"`
+	IsApproved bool   `json:"is_approved"`
+}
+
+// FileListItem is one file in a listing, with what file views need to
+// render it without further queries.
+type FileListItem struct {
+	FUUID        string              `json:"f_uuid"`
+	FileName     string              `json:"f_name"`
+	Language     string              `json:"language"`
+	Status       string              `json:"status"`
+	Sensitivity  string              `json:"sensitivity"`
+	UploaderUUID string              `json:"uploader_uuid"`
+	UploaderName string              `json:"uploader_name"`
+	SourceDUUID  string              `json:"d_uuid"`
+	CreatedAt    time.Time           `json:"created_at"`
+	Departments  []FileDepartmentRef `json:"departments"`
+	// SummaryState is the state of the latest summary request ("none" when
+	// there is none); HasSummary is true once summary text exists.
+	SummaryState string `json:"summary_state"`
+	HasSummary   bool   `json:"has_summary"`
+	IsFavorite   bool   `json:"is_favorite"`
+	CategoryID   string `json:"category_id,omitempty"`
+	Category     string `json:"category,omitempty"`
+	// SortValue is the item's sort key as text, for the next page's cursor;
+	// created_at is given in RFC 3339 with microseconds, in UTC.
+	SortValue string `json:"-"`
+}
+
+// fileSortKey is the SQL expression each sort field orders by.
+var fileSortKey = map[string]string{
+	FileSortCreatedAt: `COALESCE(f.created_at, 'epoch'::timestamptz)`,
+	FileSortName:      `lower(COALESCE(f.f_name, ''))`,
+}
+
+// fileSortText renders a sort key as the text a cursor carries.
+var fileSortText = map[string]string{
+	FileSortCreatedAt: `to_char(` + fileSortKey[FileSortCreatedAt] + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`,
+	FileSortName:      fileSortKey[FileSortName],
+}
+
+// ListFiles returns one page of the files viewer may see, ordered by sort
+// (ascending unless desc) and then f_uuid, starting after cursor (nil for
+// the first page).
+func ListFiles(db *sql.DB, viewer string, f FileListFilter, sort string, desc bool, cursor *FileListCursor, limit int) ([]FileListItem, error) {
+	key, ok := fileSortKey[sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", sort)
+	}
+	args := []interface{}{viewer}
+	where := []string{fileVisibleTo("f", "$1")}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.DUUID != "" {
+		add("EXISTS (SELECT 1 FROM file_department lfd WHERE lfd.f_uuid = f.f_uuid AND lfd.d_uuid::text = $%d)", f.DUUID)
+	}
+	if f.Language != "" {
+		add("lower(f.language) = lower($%d)", f.Language)
+	}
+	if f.Status != "" {
+		add("f.status = $%d", f.Status)
+	}
+	if f.Uploader != "" {
+		add("f.uuid::text = $%d", f.Uploader)
+	}
+	if f.From != nil {
+		add("f.created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("f.created_at < $%d", *f.To)
+	}
+	if f.HasSummary != nil {
+		cond := "EXISTS (SELECT 1 FROM summary hs WHERE hs.f_uuid = f.f_uuid AND COALESCE(hs.summary, '') <> '')"
+		if !*f.HasSummary {
+			cond = "NOT " + cond
+		}
+		where = append(where, cond)
+	}
+	if f.CategoryID != "" {
+		add("dc.category_id::text = $%d", f.CategoryID)
+	}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		add("f.f_name ILIKE $%d", "%"+escapeLike(q)+"%")
+	}
+
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+	if cursor != nil {
+		cast := "text"
+		if sort == FileSortCreatedAt {
+			cast = "timestamptz"
+		}
+		args = append(args, cursor.Value, cursor.FUUID)
+		where = append(where, fmt.Sprintf("(%s, f.f_uuid::text) %s ($%d::%s, $%d)", key, op, len(args)-1, cast, len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.language, ''), COALESCE(f.status, ''),
+			COALESCE(f.sensitivity, 'internal'), COALESCE(f.uuid::text, ''), COALESCE(u.name, ''),
+			COALESCE(f.d_uuid::text, ''), COALESCE(f.created_at, 'epoch'::timestamptz),
+			COALESCE((SELECT json_agg(json_build_object('d_uuid', d.d_uuid, 'd_name', d.d_name,
+					'is_approved', COALESCE(fd.is_approved, false)) ORDER BY d.d_name)
+				FROM file_department fd JOIN department d ON d.d_uuid = fd.d_uuid
+				WHERE fd.f_uuid = f.f_uuid), '[]'),
+			COALESCE((SELECT s.state FROM summary s WHERE s.f_uuid = f.f_uuid
+				ORDER BY s.created_at DESC LIMIT 1), 'none'),
+			EXISTS (SELECT 1 FROM summary s WHERE s.f_uuid = f.f_uuid AND COALESCE(s.summary, '') <> ''),
+			EXISTS (SELECT 1 FROM favorites fav WHERE fav.f_uuid = f.f_uuid AND fav.uuid::text = $1),
+			COALESCE(dc.category_id::text, ''), COALESCE(cat.name, ''), (`+fileSortText[sort]+`)::text
+		FROM file f
+		LEFT JOIN users u ON u.uuid = f.uuid
+		LEFT JOIN document_classifications dc ON dc.f_uuid = f.f_uuid
+		LEFT JOIN document_categories cat ON cat.category_id = dc.category_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY `+key+` `+dir+`, f.f_uuid::text `+dir+`
+		LIMIT $`+fmt.Sprint(len(args)), args...)
+	if err != nil {
+		log.Println("[DB] ListFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []FileListItem{}
+	for rows.Next() {
+		var it FileListItem
+		var depts []byte
+		if err := rows.Scan(&it.FUUID, &it.FileName, &it.Language, &it.Status, &it.Sensitivity,
+			&it.UploaderUUID, &it.UploaderName, &it.SourceDUUID, &it.CreatedAt, &depts,
+			&it.SummaryState, &it.HasSummary, &it.IsFavorite, &it.CategoryID, &it.Category, &it.SortValue); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(depts, &it.Departments); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
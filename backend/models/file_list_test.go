@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// pageThrough lists every page of limit items and returns the f_uuids in
+// the order they came.
+func pageThrough(t *testing.T, db *sql.DB, viewer, sort string, desc bool, limit int) []string {
+	t.Helper()
+	var out []string
+	var cursor *FileListCursor
+	for page := 0; page < 20; page++ {
+		items, err := ListFiles(db, viewer, FileListFilter{}, sort, desc, cursor, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range items {
+			out = append(out, it.FUUID)
+		}
+		if len(items) < limit {
+			return out
+		}
+		last := items[len(items)-1]
+		cursor = &FileListCursor{Value: last.SortValue, FUUID: last.FUUID}
+	}
+	t.Fatal("paging did not end")
+	return nil
+}
+
+func TestListFilesKeysetCursor(t *testing.T) {
+	db := testDB(t)
+	viewer := testID(10)
+	mustExec(t, db, `INSERT INTO users (uuid, name) VALUES ($1, 'Viewer')`, viewer)
+	t0 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	// Ties on both sort keys, and a file without created_at.
+	rows := []struct {
+		id   int
+		name string
+		at   *time.Time
+	}{
+		{1, "beta", &t0}, {2, "Alpha", &t0}, {3, "alpha", &t0},
+		{4, "gamma", ptrTime(t0.Add(time.Microsecond))}, {5, "delta", ptrTime(t0.Add(-time.Hour))}, {6, "Beta", nil},
+	}
+	for _, r := range rows {
+		mustExec(t, db, `INSERT INTO file (f_uuid, f_name, created_at) VALUES ($1, $2, $3)`, testID(r.id), r.name, r.at)
+	}
+
+	cases := []struct {
+		sort string
+		desc bool
+		want []int
+	}{
+		{FileSortCreatedAt, false, []int{6, 5, 1, 2, 3, 4}},
+		{FileSortCreatedAt, true, []int{4, 3, 2, 1, 5, 6}},
+		{FileSortName, false, []int{2, 3, 1, 6, 5, 4}},
+		{FileSortName, true, []int{4, 5, 6, 1, 3, 2}},
+	}
+	for _, c := range cases {
+		want := make([]string, len(c.want))
+		for i, n := range c.want {
+			want[i] = testID(n)
+		}
+		for _, limit := range []int{1, 2, 4} {
+			if got := pageThrough(t, db, viewer, c.sort, c.desc, limit); !equalStrings(got, want) {
+				t.Fatalf("sort %s desc=%v limit %d: got %v, want %v", c.sort, c.desc, limit, got, want)
+			}
+		}
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FilePreview records which thumbnail and page previews have been rendered
+// for a file. Error is set when rendering failed.
+type FilePreview struct {
+	FUUID        string
+	Kind         string
+	PageCount    int
+	Pages        []int
+	HasThumbnail bool
+	Error        string
+	GeneratedAt  time.Time
+}
+
+// GetFilePreview returns nil, nil when nothing has been rendered.
+func GetFilePreview(db *sql.DB, fuuid string) (*FilePreview, error) {
+	var p FilePreview
+	var pages pq.Int64Array
+	var errText sql.NullString
+	err := db.QueryRow(`
+		SELECT f_uuid::text, kind, page_count, pages, has_thumbnail, error, generated_at
+		FROM file_previews
+		WHERE f_uuid::text = $1
+	`, fuuid).Scan(&p.FUUID, &p.Kind, &p.PageCount, &pages, &p.HasThumbnail, &errText, &p.GeneratedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetFilePreview error:", err)
+		return nil, err
+	}
+	p.Error = errText.String
+	p.Pages = make([]int, len(pages))
+	for i, n := range pages {
+		p.Pages[i] = int(n)
+	}
+	return &p, nil
+}
+
+// SaveFilePreview stores p, replacing any earlier record for the file.
+func SaveFilePreview(db *sql.DB, p FilePreview) error {
+	pages := make([]int64, len(p.Pages))
+	for i, n := range p.Pages {
+		pages[i] = int64(n)
+	}
+	_, err := db.Exec(`
+		INSERT INTO file_previews (f_uuid, kind, page_count, pages, has_thumbnail, error, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (f_uuid) DO UPDATE SET
+			kind = EXCLUDED.kind, page_count = EXCLUDED.page_count, pages = EXCLUDED.pages,
+			has_thumbnail = EXCLUDED.has_thumbnail, error = EXCLUDED.error, generated_at = EXCLUDED.generated_at
+	`, p.FUUID, p.Kind, p.PageCount, pq.Array(pages), p.HasThumbnail, nullIfEmpty(p.Error))
+	if err != nil {
+		log.Println("[DB] SaveFilePreview error:", err)
+	}
+	return err
+}
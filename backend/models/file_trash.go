@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TrashFile is a file with its trash state. DeletedAt is nil for files that
+// are not in the trash.
+type TrashFile struct {
+	FUUID         string
+	FileName      string
+	FilePath      string
+	OwnerUUID     string
+	Sensitivity   string
+	Departments   []string
+	DeletedAt     *time.Time
+	DeletedBy     string
+	DeletedByName string
+	ArchivedAt    *time.Time
+	HasThumbnail  bool
+	PreviewPages  []int
+}
+
+const trashFileSelect = `
+	SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.file_path, ''), COALESCE(f.uuid::text, ''),
+		COALESCE(f.sensitivity, 'internal'),
+		COALESCE((SELECT array_agg(fd.d_uuid::text) FROM file_department fd WHERE fd.f_uuid = f.f_uuid), '{}'),
+		f.deleted_at, COALESCE(f.deleted_by::text, ''), COALESCE(u.name, ''), f.archived_at,
+		COALESCE(p.has_thumbnail, false), COALESCE(p.pages, '{}')
+	FROM file f
+	LEFT JOIN users u ON u.uuid = f.deleted_by
+	LEFT JOIN file_previews p ON p.f_uuid = f.f_uuid
+`
+
+func scanTrashFile(scan func(...interface{}) error) (TrashFile, error) {
+	var f TrashFile
+	var depts pq.StringArray
+	var pages pq.Int64Array
+	if err := scan(&f.FUUID, &f.FileName, &f.FilePath, &f.OwnerUUID, &f.Sensitivity, &depts,
+		&f.DeletedAt, &f.DeletedBy, &f.DeletedByName, &f.ArchivedAt, &f.HasThumbnail, &pages); err != nil {
+		return f, err
+	}
+	f.Departments = []string(depts)
+	f.PreviewPages = make([]int, len(pages))
+	for i, n := range pages {
+		f.PreviewPages[i] = int(n)
+	}
+	return f, nil
+}
+
+func queryTrashFiles(db *sql.DB, where string, args ...interface{}) ([]TrashFile, error) {
+	rows, err := db.Query(trashFileSelect+` WHERE `+where+` ORDER BY f.deleted_at DESC`, args...)
+	if err != nil {
+		log.Println("[DB] ListTrashFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []TrashFile{}
+	for rows.Next() {
+		f, err := scanTrashFile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// GetTrashFile returns nil, nil when the file does not exist. Files both in
+// and out of the trash are returned.
+func GetTrashFile(db *sql.DB, fuuid string) (*TrashFile, error) {
+	f, err := scanTrashFile(db.QueryRow(trashFileSelect+` WHERE f.f_uuid::text = $1`, fuuid).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetTrashFile error:", err)
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListTrashedFiles returns the trashed files uploaded by uuid or, when
+// headDUUID is set, shared with that department, most recently deleted
+// first.
+func ListTrashedFiles(db *sql.DB, uuid, headDUUID string) ([]TrashFile, error) {
+	return queryTrashFiles(db, `f.deleted_at IS NOT NULL AND (f.uuid::text = $1 OR ($2 <> '' AND EXISTS (
+		SELECT 1 FROM file_department tfd WHERE tfd.f_uuid = f.f_uuid AND tfd.d_uuid::text = $2
+	)))`, uuid, headDUUID)
+}
+
+// ListExpiredTrash returns the files moved to the trash before the cutoff.
+func ListExpiredTrash(db *sql.DB, before time.Time) ([]TrashFile, error) {
+	return queryTrashFiles(db, `f.deleted_at < $1`, before)
+}
+
+// MoveFileToTrash moves a file to the trash. It returns sql.ErrNoRows when the
+// file does not exist or is already there.
+func MoveFileToTrash(db *sql.DB, fuuid, by string, at time.Time) error {
+	res, err := db.Exec(`
+		UPDATE file SET deleted_at = $3, deleted_by = $2
+		WHERE f_uuid::text = $1 AND deleted_at IS NULL
+	`, fuuid, nullIfEmpty(by), at)
+	if err != nil {
+		log.Println("[DB] MoveFileToTrash error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RestoreTrashedFile takes a file out of the trash. It returns sql.ErrNoRows
+// when the file does not exist or is not in the trash.
+func RestoreTrashedFile(db *sql.DB, fuuid string) error {
+	res, err := db.Exec(`
+		UPDATE file SET deleted_at = NULL, deleted_by = NULL
+		WHERE f_uuid::text = $1 AND deleted_at IS NOT NULL
+	`, fuuid)
+	if err != nil {
+		log.Println("[DB] RestoreTrashedFile error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// InboxNotification is a notifications row enriched for display. Only the
+// newest notification per file is listed; older ones for the same file
+// are marked read together with it.
+type InboxNotification struct {
+	NotifID          string    `json:"notif_id"`
+	FUUID            string    `json:"f_uuid"`
+	FileName         string    `json:"f_name"`
+	FileCreatedAt    time.Time `json:"file_created_at"`
+	SourceDUUID      string    `json:"source_d_uuid,omitempty"`
+	SourceDepartment string    `json:"source_department,omitempty"`
+	Departments      []string  `json:"departments"`
+	FromSameDept     bool      `json:"from_same_dept"`
+	SummarySnippet   string    `json:"summary_snippet,omitempty"`
+	IsSeen           bool      `json:"is_seen"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// InboxCursor is the position after the last item of a page.
+type InboxCursor struct {
+	CreatedAt time.Time
+	NotifID   string
+}
+
+// inboxSnippetLength is how much of the latest summary is returned.
+const inboxSnippetLength = 240
+
+// latestPerFile keeps the newest notification per (user, file).
+const latestPerFile = `NOT EXISTS (
+	SELECT 1 FROM notifications n2
+	WHERE n2.uuid = n.uuid AND n2.f_uuid = n.f_uuid
+		AND (COALESCE(n2.created_at, 'epoch'), n2.notif_id::text) > (COALESCE(n.created_at, 'epoch'), n.notif_id::text)
+)`
+
+// seenFilter maps "seen"/"unseen" to a condition; anything else lists all.
+func seenFilter(status string) string {
+	switch status {
+	case "seen":
+		return ` AND n.is_seen IS TRUE`
+	case "unseen":
+		return ` AND n.is_seen IS NOT TRUE`
+	}
+	return ""
+}
+
+// ListInboxNotifications returns up to limit notifications for uuid, newest
+// first, starting after cursor (nil for the first page). Files the user may
+// not see at their sensitivity level are left out. dUUID is the
+// user's department, used for from_same_dept.
+func ListInboxNotifications(db *sql.DB, uuid, dUUID, status string, cursor *InboxCursor, limit int) ([]InboxNotification, error) {
+	args := []interface{}{uuid, dUUID, limit, inboxSnippetLength}
+	after := ""
+	if cursor != nil {
+		args = append(args, cursor.CreatedAt, cursor.NotifID)
+		after = ` AND (COALESCE(n.created_at, 'epoch'), n.notif_id::text) < ($5, $6)`
+	}
+	rows, err := db.Query(`
+		SELECT n.notif_id::text, n.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.created_at, n.created_at, 'epoch'),
+			COALESCE(f.d_uuid::text, ''), COALESCE(src.d_name, ''),
+			COALESCE((SELECT array_agg(d.d_name ORDER BY d.d_name)
+				FROM file_department fd JOIN department d ON d.d_uuid = fd.d_uuid
+				WHERE fd.f_uuid = n.f_uuid), '{}'),
+			COALESCE(f.d_uuid::text = $2, false),
+			COALESCE((SELECT left(s.summary, $4) FROM summary s
+				WHERE s.f_uuid = n.f_uuid AND COALESCE(s.summary, '') <> ''
+				ORDER BY s.created_at DESC LIMIT 1), ''),
+			COALESCE(n.is_seen, false), COALESCE(n.created_at, 'epoch')
+		FROM notifications n
+		JOIN file f ON f.f_uuid = n.f_uuid
+		LEFT JOIN department src ON src.d_uuid = f.d_uuid
+		WHERE n.uuid::text = $1 AND `+latestPerFile+` AND `+fileVisibleTo("f", "$1")+seenFilter(status)+after+`
+		ORDER BY n.created_at DESC NULLS LAST, n.notif_id::text DESC
+		LIMIT $3
+	`, args...)
+	if err != nil {
+		log.Println("[DB] ListInboxNotifications error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []InboxNotification{}
+	for rows.Next() {
+		var n InboxNotification
+		var depts pq.StringArray
+		if err := rows.Scan(&n.NotifID, &n.FUUID, &n.FileName, &n.FileCreatedAt, &n.SourceDUUID, &n.SourceDepartment,
+			&depts, &n.FromSameDept, &n.SummarySnippet, &n.IsSeen, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		n.Departments = []string(depts)
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// CountUnreadNotifications counts files with an unseen notification for uuid.
+func CountUnreadNotifications(db *sql.DB, uuid string) (int, error) {
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM notifications n
+		JOIN file f ON f.f_uuid = n.f_uuid
+		WHERE n.uuid::text = $1 AND n.is_seen IS NOT TRUE AND `+latestPerFile+` AND `+fileVisibleTo("f", "$1"), uuid).Scan(&n)
+	if err != nil {
+		log.Println("[DB] CountUnreadNotifications error:", err)
+	}
+	return n, err
+}
+
+// MarkNotificationsRead marks the given notifications (and any older ones
+// for the same files) as seen, or all of the user's when all is set. Only
+// the user's own rows are touched. It returns how many rows changed.
+func MarkNotificationsRead(db *sql.DB, uuid string, notifIDs []string, all bool) (int, error) {
+	var res sql.Result
+	var err error
+	if all {
+		res, err = db.Exec(`UPDATE notifications SET is_seen = true WHERE uuid::text = $1 AND is_seen IS NOT TRUE`, uuid)
+	} else {
+		res, err = db.Exec(`
+			UPDATE notifications SET is_seen = true
+			WHERE uuid::text = $1 AND is_seen IS NOT TRUE
+				AND f_uuid IN (SELECT f_uuid FROM notifications WHERE uuid::text = $1 AND notif_id::text = ANY($2))
+		`, uuid, pq.Array(notifIDs))
+	}
+	if err != nil {
+		log.Println("[DB] MarkNotificationsRead error:", err)
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
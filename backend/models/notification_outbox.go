@@ -0,0 +1,358 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OutboxEntry is one message waiting to be delivered to one recipient on one
+// channel. IdempotencyKey is unique, so re-enqueueing the same event is a no-op.
+type OutboxEntry struct {
+	ID             int64           `json:"id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Kind           string          `json:"kind"`
+	Channel        string          `json:"channel"`
+	RecipientUUID  string          `json:"recipient_uuid"`
+	Recipient      string          `json:"recipient"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertOutboxEntries writes all entries in one statement and returns how many
+// were new.
+func insertOutboxEntries(db execer, entries []OutboxEntry) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(entries))
+	kinds := make([]string, len(entries))
+	channels := make([]string, len(entries))
+	recipientUUIDs := make([]string, len(entries))
+	recipients := make([]string, len(entries))
+	payloads := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i], kinds[i], channels[i] = e.IdempotencyKey, e.Kind, e.Channel
+		recipientUUIDs[i], recipients[i] = e.RecipientUUID, e.Recipient
+		payloads[i] = string(e.Payload)
+		if len(e.Payload) == 0 {
+			payloads[i] = "{}"
+		}
+	}
+	res, err := db.Exec(`
+		INSERT INTO notification_outbox (idempotency_key, kind, channel, recipient_uuid, recipient, payload)
+		SELECT k, kind, ch, NULLIF(ru, '')::uuid, rc, p::jsonb
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[]) AS t(k, kind, ch, ru, rc, p)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`, pq.Array(keys), pq.Array(kinds), pq.Array(channels), pq.Array(recipientUUIDs), pq.Array(recipients), pq.Array(payloads))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func EnqueueOutboxEntries(db *sql.DB, entries []OutboxEntry) (int, error) {
+	n, err := insertOutboxEntries(db, entries)
+	if err != nil {
+		log.Println("[DB] EnqueueOutboxEntries error:", err)
+	}
+	return n, err
+}
+
+// ClaimOutboxEntries picks up to limit due, pending entries, counts the
+// attempt and hides them until leaseUntil so a crashed dispatcher's work is
+// retried rather than lost. SKIP LOCKED lets several instances run safely.
+func ClaimOutboxEntries(db *sql.DB, now, leaseUntil time.Time, limit int) ([]OutboxEntry, error) {
+	rows, err := db.Query(`
+		UPDATE notification_outbox
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, idempotency_key, kind, channel, COALESCE(recipient_uuid::text, ''), recipient, payload,
+			status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at
+	`, now, leaseUntil, limit)
+	if err != nil {
+		log.Println("[DB] ClaimOutboxEntries error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []OutboxEntry
+	for rows.Next() {
+		var e OutboxEntry
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.IdempotencyKey, &e.Kind, &e.Channel, &e.RecipientUUID, &e.Recipient, &payload,
+			&e.Status, &e.Attempts, &e.NextAttemptAt, &e.LastError, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func MarkOutboxSent(db *sql.DB, id int64) error {
+	_, err := db.Exec(`UPDATE notification_outbox SET status = 'sent', sent_at = NOW(), last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		log.Println("[DB] MarkOutboxSent error:", err)
+	}
+	return err
+}
+
+func MarkOutboxRetry(db *sql.DB, id int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := db.Exec(`UPDATE notification_outbox SET next_attempt_at = $2, last_error = $3 WHERE id = $1`, id, nextAttemptAt, lastError)
+	if err != nil {
+		log.Println("[DB] MarkOutboxRetry error:", err)
+	}
+	return err
+}
+
+func MarkOutboxDead(db *sql.DB, id int64, lastError string) error {
+	_, err := db.Exec(`UPDATE notification_outbox SET status = 'dead', last_error = $2 WHERE id = $1`, id, lastError)
+	if err != nil {
+		log.Println("[DB] MarkOutboxDead error:", err)
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Source tables
+// ---------------------------------------------------------------------------
+
+// PendingNotification is an unsent notifications row with everything needed
+// to render it, fetched in a single query.
+type PendingNotification struct {
+	NotifID     string
+	UUID        string
+	FUUID       string
+	Email       string
+	Active      bool
+	FileName    string
+	Departments string
+	Summary     string
+	// Sensitivity is the file's level; Hidden is set when the recipient may
+	// not see the file at that level.
+	Sensitivity string
+	Hidden      bool
+	CreatedAt   time.Time
+	Prefs       NotificationPreferences
+}
+
+// CollectUnsentNotifications locks up to limit unsent notifications rows,
+// turns them into outbox entries with build, and marks them sent in the same
+// transaction so each row is handed to the outbox exactly once.
+func CollectUnsentNotifications(db *sql.DB, limit int, build func([]PendingNotification) []OutboxEntry) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT n.notif_id::text, COALESCE(n.uuid::text, ''), COALESCE(n.f_uuid::text, ''),
+			COALESCE(u.email, ''), COALESCE(u.is_active, true), COALESCE(f.f_name, ''),
+			COALESCE((SELECT string_agg(d.d_name, ', ' ORDER BY d.d_name)
+				FROM file_department fd JOIN department d ON d.d_uuid = fd.d_uuid
+				WHERE fd.f_uuid = n.f_uuid), ''),
+			COALESCE((SELECT s.summary FROM summary s
+				WHERE s.f_uuid = n.f_uuid AND s.summary IS NOT NULL
+				ORDER BY s.created_at DESC LIMIT 1), ''),
+			COALESCE(f.sensitivity, 'internal'), NOT COALESCE(`+fileVisibleTo("f", "n.uuid::text")+`, false),
+			COALESCE(n.created_at, NOW()), `+notificationPreferenceColumns+`
+		FROM notifications n
+		LEFT JOIN users u ON u.uuid = n.uuid
+		LEFT JOIN file f ON f.f_uuid = n.f_uuid
+		LEFT JOIN notification_preferences p ON p.uuid = n.uuid
+		WHERE n.is_sent IS NOT TRUE
+		ORDER BY n.created_at
+		LIMIT $1
+		FOR UPDATE OF n SKIP LOCKED
+	`, limit)
+	if err != nil {
+		log.Println("[DB] CollectUnsentNotifications error:", err)
+		return 0, err
+	}
+	var pending []PendingNotification
+	var ids []string
+	for rows.Next() {
+		var p PendingNotification
+		dest := []interface{}{&p.NotifID, &p.UUID, &p.FUUID, &p.Email, &p.Active, &p.FileName,
+			&p.Departments, &p.Summary, &p.Sensitivity, &p.Hidden, &p.CreatedAt}
+		if err := rows.Scan(append(dest, p.Prefs.scanDest()...)...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.Prefs.UUID = p.UUID
+		pending = append(pending, p)
+		ids = append(ids, p.NotifID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	n, err := insertOutboxEntries(tx, build(pending))
+	if err != nil {
+		log.Println("[DB] CollectUnsentNotifications enqueue error:", err)
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE notifications SET is_sent = true WHERE notif_id::text = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// QuickShareMember is an active user of the department a quick share targets.
+type QuickShareMember struct {
+	UUID     string
+	Email    string
+	Position string
+	Prefs    NotificationPreferences
+}
+
+func (m QuickShareMember) IsHead() bool { return m.Position == "head" }
+
+// PendingQuickShare is an unsent quick_share row. Rows created through the
+// API carry their resolved recipients (Resolved, Members); for rows from
+// older clients Members is everyone in the target department.
+type PendingQuickShare struct {
+	QSUUID     string
+	DUUID      string
+	SenderUUID string
+	SenderName string
+	Data       json.RawMessage
+	CreatedAt  time.Time
+	Resolved   bool
+	Members    []QuickShareMember
+}
+
+// CollectUnsentQuickShares is the quick_share counterpart of
+// CollectUnsentNotifications. Department members for every locked row are
+// loaded with one extra query.
+func CollectUnsentQuickShares(db *sql.DB, limit int, build func([]PendingQuickShare) []OutboxEntry) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT qs_uuid::text, COALESCE(d_uuid::text, ''), COALESCE(uuid::text, ''),
+			COALESCE((SELECT s.name FROM users s WHERE s.uuid = quick_share.uuid), ''),
+			COALESCE(data::text, '{}'), COALESCE(created_at, NOW())
+		FROM quick_share
+		WHERE is_sent IS NOT TRUE
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		log.Println("[DB] CollectUnsentQuickShares error:", err)
+		return 0, err
+	}
+	var pending []PendingQuickShare
+	var ids, depts []string
+	for rows.Next() {
+		var p PendingQuickShare
+		var data string
+		if err := rows.Scan(&p.QSUUID, &p.DUUID, &p.SenderUUID, &p.SenderName, &data, &p.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.Data = json.RawMessage(data)
+		pending = append(pending, p)
+		ids = append(ids, p.QSUUID)
+		depts = append(depts, p.DUUID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	members := map[string][]QuickShareMember{}
+	mrows, err := tx.Query(`
+		SELECT u.d_uuid::text, u.uuid::text, COALESCE(u.email, ''), COALESCE(u.position, ''), `+notificationPreferenceColumns+`
+		FROM users u
+		LEFT JOIN notification_preferences p ON p.uuid = u.uuid
+		WHERE u.d_uuid::text = ANY($1) AND COALESCE(u.is_active, true)
+		ORDER BY u.name
+	`, pq.Array(depts))
+	if err != nil {
+		return 0, err
+	}
+	for mrows.Next() {
+		var d string
+		var m QuickShareMember
+		if err := mrows.Scan(append([]interface{}{&d, &m.UUID, &m.Email, &m.Position}, m.Prefs.scanDest()...)...); err != nil {
+			mrows.Close()
+			return 0, err
+		}
+		m.Prefs.UUID = m.UUID
+		members[d] = append(members[d], m)
+	}
+	mrows.Close()
+
+	resolved := map[string][]QuickShareMember{}
+	rrows, err := tx.Query(`
+		SELECT r.qs_uuid::text, COALESCE(u.is_active, true), u.uuid::text, COALESCE(u.email, ''), COALESCE(u.position, ''), `+notificationPreferenceColumns+`
+		FROM quick_share_recipients r
+		JOIN users u ON u.uuid = r.uuid
+		LEFT JOIN notification_preferences p ON p.uuid = u.uuid
+		WHERE r.qs_uuid::text = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	for rrows.Next() {
+		var qs string
+		var active bool
+		var m QuickShareMember
+		if err := rrows.Scan(append([]interface{}{&qs, &active, &m.UUID, &m.Email, &m.Position}, m.Prefs.scanDest()...)...); err != nil {
+			rrows.Close()
+			return 0, err
+		}
+		m.Prefs.UUID = m.UUID
+		if _, ok := resolved[qs]; !ok {
+			resolved[qs] = []QuickShareMember{}
+		}
+		if active {
+			resolved[qs] = append(resolved[qs], m)
+		}
+	}
+	rrows.Close()
+
+	for i := range pending {
+		if r, ok := resolved[pending[i].QSUUID]; ok {
+			pending[i].Resolved, pending[i].Members = true, r
+		} else {
+			pending[i].Members = members[pending[i].DUUID]
+		}
+	}
+
+	n, err := insertOutboxEntries(tx, build(pending))
+	if err != nil {
+		log.Println("[DB] CollectUnsentQuickShares enqueue error:", err)
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE quick_share SET is_sent = true WHERE qs_uuid::text = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+)
+
+// NotificationPreferences says which channels a user wants notifications on.
+type NotificationPreferences struct {
+	UUID           string `json:"uuid"`
+	EmailEnabled   bool   `json:"email_enabled"`
+	InAppEnabled   bool   `json:"in_app_enabled"`
+	WebhookEnabled bool   `json:"webhook_enabled"`
+	WebhookURL     string `json:"webhook_url"`
+	// DigestFrequency batches file emails: immediate, daily or weekly.
+	DigestFrequency string `json:"digest_frequency"`
+}
+
+const (
+	DigestImmediate = "immediate"
+	DigestDaily     = "daily"
+	DigestWeekly    = "weekly"
+)
+
+// DefaultNotificationPreferences applies to users without a stored row.
+func DefaultNotificationPreferences(uuid string) NotificationPreferences {
+	return NotificationPreferences{UUID: uuid, EmailEnabled: true, InAppEnabled: true, DigestFrequency: DigestImmediate}
+}
+
+// notificationPreferenceColumns selects preferences from a LEFT JOIN on
+// notification_preferences p, falling back to the defaults.
+const notificationPreferenceColumns = `COALESCE(p.email_enabled, true), COALESCE(p.in_app_enabled, true),
+	COALESCE(p.webhook_enabled, false), COALESCE(p.webhook_url, ''), COALESCE(p.digest_frequency, 'immediate')`
+
+// scanDest lists the fields notificationPreferenceColumns scans into.
+func (p *NotificationPreferences) scanDest() []interface{} {
+	return []interface{}{&p.EmailEnabled, &p.InAppEnabled, &p.WebhookEnabled, &p.WebhookURL, &p.DigestFrequency}
+}
+
+func GetNotificationPreferences(db *sql.DB, uuid string) (NotificationPreferences, error) {
+	prefs := NotificationPreferences{UUID: uuid}
+	err := db.QueryRow(`
+		SELECT `+notificationPreferenceColumns+`
+		FROM (SELECT $1::uuid AS uuid) u
+		LEFT JOIN notification_preferences p ON p.uuid = u.uuid
+	`, uuid).Scan(prefs.scanDest()...)
+	if err != nil {
+		log.Println("[DB] GetNotificationPreferences error:", err)
+	}
+	return prefs, err
+}
+
+func UpsertNotificationPreferences(db *sql.DB, prefs NotificationPreferences) error {
+	_, err := db.Exec(`
+		INSERT INTO notification_preferences (uuid, email_enabled, in_app_enabled, webhook_enabled, webhook_url, digest_frequency, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (uuid) DO UPDATE SET
+			email_enabled = EXCLUDED.email_enabled,
+			in_app_enabled = EXCLUDED.in_app_enabled,
+			webhook_enabled = EXCLUDED.webhook_enabled,
+			webhook_url = EXCLUDED.webhook_url,
+			digest_frequency = EXCLUDED.digest_frequency,
+			updated_at = NOW()
+	`, prefs.UUID, prefs.EmailEnabled, prefs.InAppEnabled, prefs.WebhookEnabled, nullIfEmpty(prefs.WebhookURL),
+		prefs.DigestFrequency)
+	if err != nil {
+		log.Println("[DB] UpsertNotificationPreferences error:", err)
+	}
+	return err
+}
+
+// NotificationRecipient is what the dispatcher needs to address one user.
+type NotificationRecipient struct {
+	UUID   string
+	Email  string
+	Active bool
+	Prefs  NotificationPreferences
+}
+
+// GetNotificationRecipient returns nil, nil when the user does not exist.
+func GetNotificationRecipient(db *sql.DB, uuid string) (*NotificationRecipient, error) {
+	r := NotificationRecipient{UUID: uuid}
+	err := db.QueryRow(`
+		SELECT COALESCE(u.email, ''), COALESCE(u.is_active, true), `+notificationPreferenceColumns+`
+		FROM users u
+		LEFT JOIN notification_preferences p ON p.uuid = u.uuid
+		WHERE u.uuid = $1
+	`, uuid).Scan(append([]interface{}{&r.Email, &r.Active}, r.Prefs.scanDest()...)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetNotificationRecipient error:", err)
+		return nil, err
+	}
+	r.Prefs.UUID = uuid
+	return &r, nil
+}
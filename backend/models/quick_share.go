@@ -0,0 +1,272 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QuickShareRecipient is one resolved recipient with their read receipt.
+type QuickShareRecipient struct {
+	UUID   string
+	Name   string
+	DUUID  string
+	ReadAt *time.Time
+}
+
+// QuickShareRow is a quick_share row in its typed form. Rows written by
+// older clients only have data; their body and priority are read from it.
+type QuickShareRow struct {
+	QSUUID       string
+	ThreadQSUUID string
+	ParentQSUUID string
+	SenderUUID   string
+	SenderName   string
+	SenderDUUID  string
+	Subject      string
+	Body         string
+	Priority     string
+	Attachments  []string
+	Departments  []string
+	CreatedAt    time.Time
+	ReplyCount   int
+	Recipients   []QuickShareRecipient
+}
+
+const quickShareSelect = `
+	SELECT q.qs_uuid::text, COALESCE(q.thread_qs_uuid, q.qs_uuid)::text, COALESCE(q.parent_qs_uuid::text, ''),
+		COALESCE(q.uuid::text, ''), COALESCE(s.name, ''), COALESCE(s.d_uuid::text, ''),
+		COALESCE(q.subject, ''), COALESCE(q.body, q.data->>'message', ''),
+		COALESCE(q.priority, q.data->>'priority', 'normal'),
+		COALESCE(q.attachments, '{}')::text[],
+		CASE WHEN cardinality(COALESCE(q.target_departments, '{}')) = 0 AND q.d_uuid IS NOT NULL
+			THEN ARRAY[q.d_uuid::text] ELSE COALESCE(q.target_departments, '{}')::text[] END,
+		COALESCE(q.created_at, NOW()),
+		(SELECT COUNT(*) FROM quick_share r WHERE r.thread_qs_uuid = q.qs_uuid AND r.qs_uuid <> q.qs_uuid)
+	FROM quick_share q
+	LEFT JOIN users s ON s.uuid = q.uuid
+`
+
+func scanQuickShares(db *sql.DB, rows *sql.Rows) ([]QuickShareRow, error) {
+	var out []QuickShareRow
+	for rows.Next() {
+		var q QuickShareRow
+		var attachments, departments pq.StringArray
+		if err := rows.Scan(&q.QSUUID, &q.ThreadQSUUID, &q.ParentQSUUID, &q.SenderUUID, &q.SenderName, &q.SenderDUUID,
+			&q.Subject, &q.Body, &q.Priority, &attachments, &departments, &q.CreatedAt, &q.ReplyCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		q.Attachments, q.Departments = []string(attachments), []string(departments)
+		out = append(out, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, loadQuickShareRecipients(db, out)
+}
+
+func loadQuickShareRecipients(db *sql.DB, list []QuickShareRow) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, len(list))
+	index := map[string]int{}
+	for i, q := range list {
+		ids[i] = q.QSUUID
+		index[q.QSUUID] = i
+	}
+	rows, err := db.Query(`
+		SELECT r.qs_uuid::text, r.uuid::text, COALESCE(u.name, ''), COALESCE(r.d_uuid::text, ''), r.read_at
+		FROM quick_share_recipients r
+		LEFT JOIN users u ON u.uuid = r.uuid
+		WHERE r.qs_uuid::text = ANY($1)
+		ORDER BY u.name
+	`, pq.Array(ids))
+	if err != nil {
+		log.Println("[DB] loadQuickShareRecipients error:", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var r QuickShareRecipient
+		var readAt sql.NullTime
+		if err := rows.Scan(&id, &r.UUID, &r.Name, &r.DUUID, &readAt); err != nil {
+			return err
+		}
+		if readAt.Valid {
+			r.ReadAt = &readAt.Time
+		}
+		list[index[id]].Recipients = append(list[index[id]].Recipients, r)
+	}
+	return rows.Err()
+}
+
+// InsertQuickShare writes the message and its recipients in one transaction
+// and returns its qs_uuid. data is filled in for clients that still read it.
+// The row is left unsent, so the notification dispatcher delivers it.
+func InsertQuickShare(db *sql.DB, q QuickShareRow) (string, error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"subject":   q.Subject,
+		"message":   q.Body,
+		"priority":  q.Priority,
+		"timestamp": q.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	var primary string
+	if len(q.Departments) > 0 {
+		primary = q.Departments[0]
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRow(`
+		INSERT INTO quick_share (data, d_uuid, uuid, created_at, subject, body, priority, attachments,
+			target_departments, parent_qs_uuid, thread_qs_uuid)
+		VALUES ($1::jsonb, $2, $3, $4, $5, $6, $7, $8::uuid[], $9::uuid[], $10, $11)
+		RETURNING qs_uuid::text
+	`, string(data), nullIfEmpty(primary), q.SenderUUID, q.CreatedAt, q.Subject, q.Body, q.Priority,
+		pq.Array(q.Attachments), pq.Array(q.Departments), nullIfEmpty(q.ParentQSUUID), nullIfEmpty(q.ThreadQSUUID)).Scan(&id)
+	if err != nil {
+		log.Println("[DB] InsertQuickShare error:", err)
+		return "", err
+	}
+	if q.ThreadQSUUID == "" {
+		if _, err := tx.Exec(`UPDATE quick_share SET thread_qs_uuid = qs_uuid WHERE qs_uuid = $1`, id); err != nil {
+			return "", err
+		}
+	}
+
+	uuids := make([]string, len(q.Recipients))
+	depts := make([]string, len(q.Recipients))
+	for i, r := range q.Recipients {
+		uuids[i], depts[i] = r.UUID, r.DUUID
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO quick_share_recipients (qs_uuid, uuid, d_uuid)
+		SELECT $1, u::uuid, NULLIF(d, '')::uuid FROM unnest($2::text[], $3::text[]) AS t(u, d)
+		ON CONFLICT DO NOTHING
+	`, id, pq.Array(uuids), pq.Array(depts)); err != nil {
+		log.Println("[DB] InsertQuickShare recipients error:", err)
+		return "", err
+	}
+	return id, tx.Commit()
+}
+
+func GetQuickShares(db *sql.DB, ids []string) ([]QuickShareRow, error) {
+	rows, err := db.Query(quickShareSelect+` WHERE q.qs_uuid::text = ANY($1) ORDER BY q.created_at`, pq.Array(ids))
+	if err != nil {
+		log.Println("[DB] GetQuickShares error:", err)
+		return nil, err
+	}
+	return scanQuickShares(db, rows)
+}
+
+// GetQuickShareThread returns every message of a thread, oldest first.
+func GetQuickShareThread(db *sql.DB, threadID string) ([]QuickShareRow, error) {
+	rows, err := db.Query(quickShareSelect+`
+		WHERE COALESCE(q.thread_qs_uuid, q.qs_uuid)::text = $1
+		ORDER BY q.created_at, q.qs_uuid
+	`, threadID)
+	if err != nil {
+		log.Println("[DB] GetQuickShareThread error:", err)
+		return nil, err
+	}
+	return scanQuickShares(db, rows)
+}
+
+// ListQuickShares returns messages uuid sent (sent=true) or received, newest
+// first. Received covers resolved recipients and, for rows from older
+// clients without recipients, every member of the target department.
+func ListQuickShares(db *sql.DB, uuid, dUUID string, sent bool, before time.Time, limit int) ([]QuickShareRow, error) {
+	where := `q.uuid::text = $1`
+	if !sent {
+		where = `(EXISTS (SELECT 1 FROM quick_share_recipients r WHERE r.qs_uuid = q.qs_uuid AND r.uuid::text = $1)
+			OR (q.d_uuid::text = $2 AND q.uuid::text <> $1
+				AND NOT EXISTS (SELECT 1 FROM quick_share_recipients r WHERE r.qs_uuid = q.qs_uuid)))`
+	}
+	rows, err := db.Query(quickShareSelect+` WHERE `+where+` AND q.created_at < $3 ORDER BY q.created_at DESC LIMIT $4`,
+		uuid, dUUID, before, limit)
+	if err != nil {
+		log.Println("[DB] ListQuickShares error:", err)
+		return nil, err
+	}
+	return scanQuickShares(db, rows)
+}
+
+// MarkQuickShareRead sets the recipient's read_at once. It returns false
+// when uuid is not a recipient of id.
+func MarkQuickShareRead(db *sql.DB, id, uuid string, at time.Time) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE quick_share_recipients SET read_at = COALESCE(read_at, $3)
+		WHERE qs_uuid::text = $1 AND uuid::text = $2
+	`, id, uuid, at)
+	if err != nil {
+		log.Println("[DB] MarkQuickShareRead error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListActiveDepartmentMembers returns the active users of the given departments.
+func ListActiveDepartmentMembers(db *sql.DB, dUUIDs []string) ([]UserProfile, error) {
+	rows, err := db.Query("SELECT "+userProfileColumns+` FROM users
+		WHERE d_uuid::text = ANY($1) AND COALESCE(is_active, true)
+		ORDER BY name`, pq.Array(dUUIDs))
+	if err != nil {
+		log.Println("[DB] ListActiveDepartmentMembers error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UserProfile
+	for rows.Next() {
+		p, err := scanUserProfile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// MissingDepartments returns the IDs in dUUIDs that are not departments.
+func MissingDepartments(db *sql.DB, dUUIDs []string) ([]string, error) {
+	return missingIDs(db, `SELECT id FROM unnest($1::text[]) AS id
+		WHERE NOT EXISTS (SELECT 1 FROM department d WHERE d.d_uuid::text = id)`, pq.Array(dUUIDs))
+}
+
+// InaccessibleFiles returns the f_uuids that uuid may not see, missing and
+// trashed files included.
+func InaccessibleFiles(db *sql.DB, uuid string, fuuids []string) ([]string, error) {
+	return missingIDs(db, `SELECT id FROM unnest($1::text[]) AS id
+		WHERE NOT EXISTS (SELECT 1 FROM file f WHERE f.f_uuid::text = id AND `+fileVisibleTo("f", "$2")+`)`,
+		pq.Array(fuuids), uuid)
+}
+
+func missingIDs(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("[DB] missingIDs error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
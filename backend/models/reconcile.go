@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// ReconcileFile is a file row with what the reconciler checks against
+// storage. UploadedAt falls back to created_at for rows without an upload
+// time.
+type ReconcileFile struct {
+	FUUID           string
+	FileName        string
+	FilePath        string
+	UploadedAt      time.Time
+	Archived        bool
+	Trashed         bool
+	HasOCR          bool
+	HasSummary      bool
+	OCRAttempts     int
+	SummaryAttempts int
+}
+
+// ListReconcileFiles returns every file row, including archived and
+// trashed ones.
+func ListReconcileFiles(db *sql.DB) ([]ReconcileFile, error) {
+	rows, err := db.Query(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.file_path, ''),
+			COALESCE(f.uploaded_at, f.created_at, NOW()), f.archived_at IS NOT NULL, f.deleted_at IS NOT NULL,
+			EXISTS (SELECT 1 FROM ocr o WHERE o.f_uuid = f.f_uuid),
+			EXISTS (SELECT 1 FROM summary s WHERE s.f_uuid = f.f_uuid),
+			COALESCE((SELECT rq.attempts FROM reconcile_requeues rq WHERE rq.f_uuid = f.f_uuid AND rq.kind = 'ocr'), 0),
+			COALESCE((SELECT rq.attempts FROM reconcile_requeues rq WHERE rq.f_uuid = f.f_uuid AND rq.kind = 'summary'), 0)
+		FROM file f
+		ORDER BY 4
+	`)
+	if err != nil {
+		log.Println("[DB] ListReconcileFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ReconcileFile{}
+	for rows.Next() {
+		var f ReconcileFile
+		if err := rows.Scan(&f.FUUID, &f.FileName, &f.FilePath, &f.UploadedAt, &f.Archived, &f.Trashed,
+			&f.HasOCR, &f.HasSummary, &f.OCRAttempts, &f.SummaryAttempts); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// NoteReconcileRequeue counts one more re-queue of kind ("ocr" or
+// "summary") for the file.
+func NoteReconcileRequeue(db *sql.DB, fuuid, kind string, at time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO reconcile_requeues (f_uuid, kind, attempts, last_attempt_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (f_uuid, kind) DO UPDATE
+		SET attempts = reconcile_requeues.attempts + 1, last_attempt_at = EXCLUDED.last_attempt_at
+	`, fuuid, kind, at)
+	if err != nil {
+		log.Println("[DB] NoteReconcileRequeue error:", err)
+	}
+	return err
+}
+
+// Reconcile run statuses.
+const (
+	ReconcileRunning     = "running"
+	ReconcileDone        = "done"
+	ReconcileFailed      = "failed"
+	ReconcileInterrupted = "interrupted"
+)
+
+type ReconcileRunRow struct {
+	RunID      string
+	Trigger    string
+	Clean      bool
+	Requeue    bool
+	Status     string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	// Counts and Findings are JSON; Findings is empty when listing runs.
+	Counts   json.RawMessage
+	Findings json.RawMessage
+	Error    string
+}
+
+// InsertReconcileRun stores a run as it starts.
+func InsertReconcileRun(db *sql.DB, r ReconcileRunRow) (ReconcileRunRow, error) {
+	r.Status = ReconcileRunning
+	err := db.QueryRow(`
+		INSERT INTO reconcile_runs (trigger, clean, requeue, status, started_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING run_id::text
+	`, r.Trigger, r.Clean, r.Requeue, r.Status, r.StartedAt).Scan(&r.RunID)
+	if err != nil {
+		log.Println("[DB] InsertReconcileRun error:", err)
+	}
+	return r, err
+}
+
+// FinishReconcileRun stores the outcome of a running run.
+func FinishReconcileRun(db *sql.DB, r ReconcileRunRow) error {
+	res, err := db.Exec(`
+		UPDATE reconcile_runs
+		SET status = $2, finished_at = $3, counts = $4, findings = $5, error = $6
+		WHERE run_id::text = $1 AND status = $7
+	`, r.RunID, r.Status, r.FinishedAt, []byte(r.Counts), []byte(r.Findings), nullIfEmpty(r.Error), ReconcileRunning)
+	if err != nil {
+		log.Println("[DB] FinishReconcileRun error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InterruptReconcileRuns marks runs left running by a previous process.
+func InterruptReconcileRuns(db *sql.DB, at time.Time) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE reconcile_runs SET status = $1, finished_at = $2, error = 'interrupted by a restart'
+		WHERE status = $3
+	`, ReconcileInterrupted, at, ReconcileRunning)
+	if err != nil {
+		log.Println("[DB] InterruptReconcileRuns error:", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func ListReconcileRuns(db *sql.DB, limit int) ([]ReconcileRunRow, error) {
+	rows, err := db.Query(`
+		SELECT run_id::text, trigger, clean, requeue, status, started_at, finished_at, counts, COALESCE(error, '')
+		FROM reconcile_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		log.Println("[DB] ListReconcileRuns error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ReconcileRunRow{}
+	for rows.Next() {
+		var r ReconcileRunRow
+		var counts []byte
+		if err := rows.Scan(&r.RunID, &r.Trigger, &r.Clean, &r.Requeue, &r.Status, &r.StartedAt, &r.FinishedAt, &counts, &r.Error); err != nil {
+			return nil, err
+		}
+		r.Counts = counts
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetReconcileRun returns nil, nil when the run does not exist.
+func GetReconcileRun(db *sql.DB, id string) (*ReconcileRunRow, error) {
+	var r ReconcileRunRow
+	var counts, findings []byte
+	err := db.QueryRow(`
+		SELECT run_id::text, trigger, clean, requeue, status, started_at, finished_at, counts, findings, COALESCE(error, '')
+		FROM reconcile_runs
+		WHERE run_id::text = $1
+	`, id).Scan(&r.RunID, &r.Trigger, &r.Clean, &r.Requeue, &r.Status, &r.StartedAt, &r.FinishedAt, &counts, &findings, &r.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetReconcileRun error:", err)
+		return nil, err
+	}
+	r.Counts, r.Findings = counts, findings
+	return &r, nil
+}
@@ -0,0 +1,421 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RetentionPolicyRow struct {
+	PolicyID   string
+	Name       string
+	Scope      string
+	CategoryID string
+	DUUID      string
+	KeepDays   int
+	Action     string
+	Enabled    bool
+	CreatedBy  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+const retentionPolicySelect = `
+	SELECT policy_id::text, name, scope, COALESCE(category_id::text, ''), COALESCE(d_uuid::text, ''),
+		keep_days, action, enabled, COALESCE(created_by, ''), created_at, updated_at
+	FROM retention_policies
+`
+
+func scanRetentionPolicy(scan func(...interface{}) error) (RetentionPolicyRow, error) {
+	var p RetentionPolicyRow
+	err := scan(&p.PolicyID, &p.Name, &p.Scope, &p.CategoryID, &p.DUUID,
+		&p.KeepDays, &p.Action, &p.Enabled, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+func ListRetentionPolicies(db *sql.DB) ([]RetentionPolicyRow, error) {
+	rows, err := db.Query(retentionPolicySelect + ` ORDER BY lower(name)`)
+	if err != nil {
+		log.Println("[DB] ListRetentionPolicies error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []RetentionPolicyRow{}
+	for rows.Next() {
+		p, err := scanRetentionPolicy(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetRetentionPolicy returns nil, nil when the policy does not exist.
+func GetRetentionPolicy(db *sql.DB, id string) (*RetentionPolicyRow, error) {
+	p, err := scanRetentionPolicy(db.QueryRow(retentionPolicySelect+` WHERE policy_id::text = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetRetentionPolicy error:", err)
+		return nil, err
+	}
+	return &p, nil
+}
+
+func InsertRetentionPolicy(db *sql.DB, p RetentionPolicyRow) (RetentionPolicyRow, error) {
+	err := db.QueryRow(`
+		INSERT INTO retention_policies (name, scope, category_id, d_uuid, keep_days, action, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING policy_id::text, created_at, updated_at
+	`, p.Name, p.Scope, nullIfEmpty(p.CategoryID), nullIfEmpty(p.DUUID), p.KeepDays, p.Action, p.Enabled,
+		nullIfEmpty(p.CreatedBy)).Scan(&p.PolicyID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertRetentionPolicy error:", err)
+	}
+	return p, err
+}
+
+// UpdateRetentionPolicy returns sql.ErrNoRows when the policy does not
+// exist.
+func UpdateRetentionPolicy(db *sql.DB, p RetentionPolicyRow) (RetentionPolicyRow, error) {
+	err := db.QueryRow(`
+		UPDATE retention_policies
+		SET name = $2, scope = $3, category_id = $4, d_uuid = $5, keep_days = $6, action = $7, enabled = $8, updated_at = NOW()
+		WHERE policy_id::text = $1
+		RETURNING updated_at
+	`, p.PolicyID, p.Name, p.Scope, nullIfEmpty(p.CategoryID), nullIfEmpty(p.DUUID), p.KeepDays, p.Action,
+		p.Enabled).Scan(&p.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateRetentionPolicy error:", err)
+	}
+	return p, err
+}
+
+// DeleteRetentionPolicy returns sql.ErrNoRows when the policy does not
+// exist.
+func DeleteRetentionPolicy(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM retention_policies WHERE policy_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteRetentionPolicy error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RetentionFile is a file with what retention needs to decide on it.
+// UploadedAt falls back to created_at for rows without an upload time.
+type RetentionFile struct {
+	FUUID        string
+	FileName     string
+	FilePath     string
+	DUUID        string
+	CategoryID   string
+	UploadedAt   time.Time
+	ArchivedAt   *time.Time
+	LegalHold    bool
+	HasThumbnail bool
+	PreviewPages []int
+}
+
+const retentionFileSelect = `
+	SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.file_path, ''), COALESCE(f.d_uuid::text, ''),
+		COALESCE(c.category_id::text, ''), COALESCE(f.uploaded_at, f.created_at, NOW()), f.archived_at, f.legal_hold,
+		COALESCE(p.has_thumbnail, false), COALESCE(p.pages, '{}')
+	FROM file f
+	LEFT JOIN document_classifications c ON c.f_uuid = f.f_uuid
+	LEFT JOIN file_previews p ON p.f_uuid = f.f_uuid
+`
+
+func scanRetentionFile(scan func(...interface{}) error) (RetentionFile, error) {
+	var f RetentionFile
+	var pages pq.Int64Array
+	if err := scan(&f.FUUID, &f.FileName, &f.FilePath, &f.DUUID, &f.CategoryID, &f.UploadedAt, &f.ArchivedAt,
+		&f.LegalHold, &f.HasThumbnail, &pages); err != nil {
+		return f, err
+	}
+	f.PreviewPages = make([]int, len(pages))
+	for i, n := range pages {
+		f.PreviewPages[i] = int(n)
+	}
+	return f, nil
+}
+
+// ListRetentionFiles returns the files uploaded before the cutoff.
+func ListRetentionFiles(db *sql.DB, before time.Time) ([]RetentionFile, error) {
+	rows, err := db.Query(retentionFileSelect+` WHERE COALESCE(f.uploaded_at, f.created_at) < $1 ORDER BY 6`, before)
+	if err != nil {
+		log.Println("[DB] ListRetentionFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []RetentionFile{}
+	for rows.Next() {
+		f, err := scanRetentionFile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// GetRetentionFile returns nil, nil when the file does not exist.
+func GetRetentionFile(db *sql.DB, fuuid string) (*RetentionFile, error) {
+	f, err := scanRetentionFile(db.QueryRow(retentionFileSelect+` WHERE f.f_uuid::text = $1`, fuuid).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetRetentionFile error:", err)
+		return nil, err
+	}
+	return &f, nil
+}
+
+// MarkFileArchived records that the file's object now lives in the archive
+// bucket and forgets its previews, which are deleted with it.
+func MarkFileArchived(db *sql.DB, fuuid string, at time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE file SET archived_at = $2 WHERE f_uuid::text = $1 AND NOT legal_hold`, fuuid, at)
+	if err != nil {
+		log.Println("[DB] MarkFileArchived error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM file_previews WHERE f_uuid::text = $1`, fuuid); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeFile deletes a file with its OCR, summaries, notifications and
+// department links; tables that reference file with ON DELETE CASCADE
+// follow. A file placed under legal hold meanwhile is left alone and
+// sql.ErrNoRows returned.
+func PurgeFile(db *sql.DB, fuuid string) error {
+	return purgeFile(db, fuuid, nil)
+}
+
+// PurgeTrashedFile is PurgeFile for a file that has been in the trash since
+// before deletedBefore. A file restored meanwhile is left alone and
+// sql.ErrNoRows returned.
+func PurgeTrashedFile(db *sql.DB, fuuid string, deletedBefore time.Time) error {
+	return purgeFile(db, fuuid, &deletedBefore)
+}
+
+func purgeFile(db *sql.DB, fuuid string, deletedBefore *time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var held bool
+	var deletedAt *time.Time
+	err = tx.QueryRow(`SELECT legal_hold, deleted_at FROM file WHERE f_uuid::text = $1 FOR UPDATE`, fuuid).Scan(&held, &deletedAt)
+	if err == nil && (held || deletedBefore != nil && (deletedAt == nil || !deletedAt.Before(*deletedBefore))) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"ocr", "summary", "notifications", "file_department"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE f_uuid::text = $1`, fuuid); err != nil {
+			log.Printf("[DB] PurgeFile %s error: %v", table, err)
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM file WHERE f_uuid::text = $1`, fuuid); err != nil {
+		log.Println("[DB] PurgeFile error:", err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetFileLegalHold places or lifts a hold. It returns sql.ErrNoRows when the
+// file does not exist.
+func SetFileLegalHold(db *sql.DB, fuuid string, hold bool, reason, by string, at time.Time) error {
+	var res sql.Result
+	var err error
+	if hold {
+		res, err = db.Exec(`
+			UPDATE file SET legal_hold = true, legal_hold_reason = $2, legal_hold_by = $3, legal_hold_at = $4
+			WHERE f_uuid::text = $1
+		`, fuuid, reason, nullIfEmpty(by), at)
+	} else {
+		res, err = db.Exec(`
+			UPDATE file SET legal_hold = false, legal_hold_reason = NULL, legal_hold_by = NULL, legal_hold_at = NULL
+			WHERE f_uuid::text = $1
+		`, fuuid)
+	}
+	if err != nil {
+		log.Println("[DB] SetFileLegalHold error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// quickShareThreadsBefore selects threads whose latest message is older than
+// $1, so an old thread that is still being replied to is kept whole.
+const quickShareThreadsBefore = `
+	COALESCE(thread_qs_uuid, qs_uuid) IN (
+		SELECT COALESCE(thread_qs_uuid, qs_uuid) FROM quick_share
+		GROUP BY 1 HAVING MAX(COALESCE(created_at, NOW())) < $1
+	)
+`
+
+// PurgeQuickShares deletes (or with dryRun counts) the messages of quick
+// share threads last active before the cutoff.
+func PurgeQuickShares(db *sql.DB, before time.Time, dryRun bool) (int, error) {
+	return purgeBefore(db, "quick_share", quickShareThreadsBefore, before, dryRun)
+}
+
+// PurgeProcessingResults deletes (or with dryRun counts) the
+// document_processing_results rows created before the cutoff.
+func PurgeProcessingResults(db *sql.DB, before time.Time, dryRun bool) (int, error) {
+	return purgeBefore(db, "document_processing_results", "created_at < $1", before, dryRun)
+}
+
+func purgeBefore(db *sql.DB, table, where string, before time.Time, dryRun bool) (int, error) {
+	if dryRun {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where, before).Scan(&n)
+		if err != nil {
+			log.Printf("[DB] Counting expired %s error: %v", table, err)
+		}
+		return n, err
+	}
+	res, err := db.Exec(`DELETE FROM `+table+` WHERE `+where, before)
+	if err != nil {
+		log.Printf("[DB] Purging expired %s error: %v", table, err)
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// Retention run statuses.
+const (
+	RetentionRunning     = "running"
+	RetentionDone        = "done"
+	RetentionFailed      = "failed"
+	RetentionInterrupted = "interrupted"
+)
+
+type RetentionRunRow struct {
+	RunID      string
+	Trigger    string
+	DryRun     bool
+	Status     string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	// Counts and Items are JSON; Items is empty when listing runs.
+	Counts json.RawMessage
+	Items  json.RawMessage
+	Error  string
+}
+
+// InsertRetentionRun stores a run as it starts.
+func InsertRetentionRun(db *sql.DB, r RetentionRunRow) (RetentionRunRow, error) {
+	r.Status = RetentionRunning
+	err := db.QueryRow(`
+		INSERT INTO retention_runs (trigger, dry_run, status, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING run_id::text
+	`, r.Trigger, r.DryRun, r.Status, r.StartedAt).Scan(&r.RunID)
+	if err != nil {
+		log.Println("[DB] InsertRetentionRun error:", err)
+	}
+	return r, err
+}
+
+// FinishRetentionRun stores the outcome of a running run.
+func FinishRetentionRun(db *sql.DB, r RetentionRunRow) error {
+	res, err := db.Exec(`
+		UPDATE retention_runs
+		SET status = $2, finished_at = $3, counts = $4, items = $5, error = $6
+		WHERE run_id::text = $1 AND status = $7
+	`, r.RunID, r.Status, r.FinishedAt, []byte(r.Counts), []byte(r.Items), nullIfEmpty(r.Error), RetentionRunning)
+	if err != nil {
+		log.Println("[DB] FinishRetentionRun error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InterruptRetentionRuns marks runs left running by a previous process.
+func InterruptRetentionRuns(db *sql.DB, at time.Time) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE retention_runs SET status = $1, finished_at = $2, error = 'interrupted by a restart'
+		WHERE status = $3
+	`, RetentionInterrupted, at, RetentionRunning)
+	if err != nil {
+		log.Println("[DB] InterruptRetentionRuns error:", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func ListRetentionRuns(db *sql.DB, limit int) ([]RetentionRunRow, error) {
+	rows, err := db.Query(`
+		SELECT run_id::text, trigger, dry_run, status, started_at, finished_at, counts, COALESCE(error, '')
+		FROM retention_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		log.Println("[DB] ListRetentionRuns error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []RetentionRunRow{}
+	for rows.Next() {
+		var r RetentionRunRow
+		var counts []byte
+		if err := rows.Scan(&r.RunID, &r.Trigger, &r.DryRun, &r.Status, &r.StartedAt, &r.FinishedAt, &counts, &r.Error); err != nil {
+			return nil, err
+		}
+		r.Counts = counts
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetRetentionRun returns nil, nil when the run does not exist.
+func GetRetentionRun(db *sql.DB, id string) (*RetentionRunRow, error) {
+	var r RetentionRunRow
+	var counts, items []byte
+	err := db.QueryRow(`
+		SELECT run_id::text, trigger, dry_run, status, started_at, finished_at, counts, items, COALESCE(error, '')
+		FROM retention_runs
+		WHERE run_id::text = $1
+	`, id).Scan(&r.RunID, &r.Trigger, &r.DryRun, &r.Status, &r.StartedAt, &r.FinishedAt, &counts, &items, &r.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetRetentionRun error:", err)
+		return nil, err
+	}
+	r.Counts, r.Items = counts, items
+	return &r, nil
+}
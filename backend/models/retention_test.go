@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPurgeFile(t *testing.T) {
+	db := testDB(t)
+	dept, user := testID(1), testID(10)
+	mustExec(t, db, `INSERT INTO department (d_uuid) VALUES ($1)`, dept)
+	mustExec(t, db, `INSERT INTO users (uuid, d_uuid) VALUES ($1, $2)`, user, dept)
+	deletedAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	plain, held, trashed := testID(100), testID(101), testID(102)
+	mustExec(t, db, `INSERT INTO file (f_uuid, uuid) VALUES ($1, $3), ($2, $3)`, plain, held, user)
+	mustExec(t, db, `UPDATE file SET legal_hold = true WHERE f_uuid = $1`, held)
+	mustExec(t, db, `INSERT INTO file (f_uuid, uuid, deleted_at) VALUES ($1, $2, $3)`, trashed, user, deletedAt)
+	tables := []string{"ocr", "summary", "notifications", "file_department", "favorites"}
+	for _, f := range []string{plain, held, trashed} {
+		mustExec(t, db, `INSERT INTO ocr (f_uuid, data) VALUES ($1, 'text')`, f)
+		mustExec(t, db, `INSERT INTO summary (f_uuid, summary) VALUES ($1, 'summary')`, f)
+		mustExec(t, db, `INSERT INTO notifications (uuid, f_uuid) VALUES ($1, $2)`, user, f)
+		mustExec(t, db, `INSERT INTO file_department (f_uuid, d_uuid) VALUES ($1, $2)`, f, dept)
+		mustExec(t, db, `INSERT INTO favorites (uuid, f_uuid) VALUES ($1, $2)`, user, f)
+	}
+	gone := func(fuuid string) bool {
+		for _, table := range append(tables, "file") {
+			if countRows(t, db, table, fuuid) != 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// The trash purge only takes files deleted before its cutoff.
+	if err := PurgeTrashedFile(db, plain, deletedAt.Add(time.Hour)); err != sql.ErrNoRows {
+		t.Fatalf("a file outside the trash must be kept, got %v", err)
+	}
+	if err := PurgeTrashedFile(db, trashed, deletedAt); err != sql.ErrNoRows {
+		t.Fatalf("a file trashed at the cutoff must be kept, got %v", err)
+	}
+	if countRows(t, db, "file", trashed) != 1 {
+		t.Fatal("the trashed file was purged early")
+	}
+	if err := PurgeTrashedFile(db, trashed, deletedAt.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if !gone(trashed) {
+		t.Fatal("purged trashed file left rows behind")
+	}
+
+	if err := PurgeFile(db, held); err != sql.ErrNoRows {
+		t.Fatalf("a file under legal hold must be kept, got %v", err)
+	}
+	if countRows(t, db, "file", held) != 1 || countRows(t, db, "ocr", held) != 1 {
+		t.Fatal("the held file lost rows")
+	}
+	if err := PurgeFile(db, testID(199)); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows for a missing file, got %v", err)
+	}
+	if err := PurgeFile(db, plain); err != nil {
+		t.Fatal(err)
+	}
+	if !gone(plain) {
+		t.Fatal("purged file left rows behind")
+	}
+}
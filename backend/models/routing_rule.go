@@ -0,0 +1,216 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RoutingRule struct {
+	RuleID        string
+	Name          string
+	RuleType      string
+	Pattern       string
+	DUUID         string
+	DName         string
+	Priority      int
+	MinConfidence float64
+	Enabled       bool
+	CreatedBy     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// RoutingHit is a department a rule added to a file.
+type RoutingHit struct {
+	RuleID   string
+	RuleName string
+	DUUID    string
+	Reason   string
+}
+
+const routingRuleSelect = `
+	SELECT r.rule_id::text, r.name, r.rule_type, r.pattern, r.d_uuid::text, COALESCE(d.d_name, ''),
+		r.priority, COALESCE(r.min_confidence, 0), r.enabled, COALESCE(r.created_by, ''), r.created_at, r.updated_at
+	FROM routing_rules r
+	LEFT JOIN department d ON d.d_uuid = r.d_uuid
+`
+
+func scanRoutingRule(scan func(...interface{}) error) (RoutingRule, error) {
+	var r RoutingRule
+	err := scan(&r.RuleID, &r.Name, &r.RuleType, &r.Pattern, &r.DUUID, &r.DName,
+		&r.Priority, &r.MinConfidence, &r.Enabled, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// ListRoutingRules returns every rule, enabled or not, in evaluation order.
+func ListRoutingRules(db *sql.DB) ([]RoutingRule, error) {
+	rows, err := db.Query(routingRuleSelect + ` ORDER BY r.priority, lower(r.name)`)
+	if err != nil {
+		log.Println("[DB] ListRoutingRules error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RoutingRule
+	for rows.Next() {
+		r, err := scanRoutingRule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetRoutingRule returns nil, nil when the rule does not exist.
+func GetRoutingRule(db *sql.DB, id string) (*RoutingRule, error) {
+	r, err := scanRoutingRule(db.QueryRow(routingRuleSelect+` WHERE r.rule_id::text = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetRoutingRule error:", err)
+		return nil, err
+	}
+	return &r, nil
+}
+
+func nullIfZero(f float64) interface{} {
+	if f == 0 {
+		return nil
+	}
+	return f
+}
+
+func InsertRoutingRule(db *sql.DB, r RoutingRule) (RoutingRule, error) {
+	err := db.QueryRow(`
+		INSERT INTO routing_rules (name, rule_type, pattern, d_uuid, priority, min_confidence, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING rule_id::text, created_at, updated_at
+	`, r.Name, r.RuleType, r.Pattern, r.DUUID, r.Priority, nullIfZero(r.MinConfidence), r.Enabled,
+		nullIfEmpty(r.CreatedBy)).Scan(&r.RuleID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertRoutingRule error:", err)
+	}
+	return r, err
+}
+
+// UpdateRoutingRule overwrites the editable fields. It returns sql.ErrNoRows
+// when the rule does not exist.
+func UpdateRoutingRule(db *sql.DB, r RoutingRule) (RoutingRule, error) {
+	err := db.QueryRow(`
+		UPDATE routing_rules
+		SET name = $2, rule_type = $3, pattern = $4, d_uuid = $5, priority = $6, min_confidence = $7,
+			enabled = $8, updated_at = NOW()
+		WHERE rule_id::text = $1
+		RETURNING created_at, updated_at, COALESCE(created_by, '')
+	`, r.RuleID, r.Name, r.RuleType, r.Pattern, r.DUUID, r.Priority, nullIfZero(r.MinConfidence),
+		r.Enabled).Scan(&r.CreatedAt, &r.UpdatedAt, &r.CreatedBy)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateRoutingRule error:", err)
+	}
+	return r, err
+}
+
+// DeleteRoutingRule returns sql.ErrNoRows when the rule does not exist.
+func DeleteRoutingRule(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM routing_rules WHERE rule_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteRoutingRule error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFileDepartmentIDs returns the departments a file is shared with.
+func GetFileDepartmentIDs(db *sql.DB, fuuid string) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT d_uuid::text FROM file_department WHERE f_uuid::text = $1`, fuuid)
+	if err != nil {
+		log.Println("[DB] GetFileDepartmentIDs error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// GetLatestOCRText returns the newest OCR text for a file, or "" when the
+// file has not been through OCR.
+func GetLatestOCRText(db *sql.DB, fuuid string) (string, error) {
+	var text string
+	err := db.QueryRow(`
+		SELECT COALESCE(data, '') FROM ocr WHERE f_uuid::text = $1
+		ORDER BY created_at DESC LIMIT 1
+	`, fuuid).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		log.Println("[DB] GetLatestOCRText error:", err)
+	}
+	return text, err
+}
+
+// ApplyFileRouting shares the file with each hit's department and notifies
+// that department's active members (except the uploader), in one
+// transaction. Departments the file already has, or that routing added
+// before, are skipped. It returns the departments that were added.
+func ApplyFileRouting(db *sql.DB, fuuid, uploaderUUID string, hits []RoutingHit) ([]string, error) {
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var added []string
+	for _, h := range hits {
+		res, err := tx.Exec(`
+			INSERT INTO file_routing (f_uuid, d_uuid, rule_id, rule_name, reason)
+			SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM file_department WHERE f_uuid = $1 AND d_uuid = $2)
+			ON CONFLICT DO NOTHING
+		`, fuuid, h.DUUID, nullIfEmpty(h.RuleID), h.RuleName, nullIfEmpty(h.Reason))
+		if err != nil {
+			log.Println("[DB] ApplyFileRouting error:", err)
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO file_department (f_uuid, d_uuid, created_at) VALUES ($1, $2, NOW())`,
+			fuuid, h.DUUID); err != nil {
+			log.Println("[DB] ApplyFileRouting file_department error:", err)
+			return nil, err
+		}
+		added = append(added, h.DUUID)
+	}
+	if len(added) == 0 {
+		return nil, tx.Commit()
+	}
+
+	// The notification dispatcher picks these rows up like any other new file.
+	if _, err := tx.Exec(`
+		INSERT INTO notifications (uuid, f_uuid, is_seen, created_at)
+		SELECT u.uuid, $1, false, NOW() FROM users u
+		WHERE u.d_uuid::text = ANY($2) AND COALESCE(u.is_active, true) AND u.uuid::text <> $3
+	`, fuuid, pq.Array(added), uploaderUUID); err != nil {
+		log.Println("[DB] ApplyFileRouting notifications error:", err)
+		return nil, err
+	}
+	return added, tx.Commit()
+}
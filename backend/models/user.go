@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// UserProfile is the public.users row that mirrors a Supabase Auth user.
+type UserProfile struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+	Address     string `json:"address"`
+	Position    string `json:"position"`
+	DUUID       string `json:"d_uuid"`
+	RUUID       string `json:"r_uuid"`
+	IsActive    bool   `json:"is_active"`
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// UpsertUserProfile writes the profile row. The handle_new_user trigger may
+// already have created it from user_metadata, so conflicts overwrite.
+func UpsertUserProfile(db *sql.DB, p UserProfile) error {
+	query := `
+		INSERT INTO users (uuid, name, email, phone_number, address, position, d_uuid, r_uuid, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, true)
+		ON CONFLICT (uuid) DO UPDATE SET
+			name = EXCLUDED.name,
+			email = EXCLUDED.email,
+			phone_number = EXCLUDED.phone_number,
+			address = EXCLUDED.address,
+			position = EXCLUDED.position,
+			d_uuid = EXCLUDED.d_uuid,
+			r_uuid = EXCLUDED.r_uuid,
+			is_active = true,
+			deactivated_at = NULL
+	`
+	_, err := db.Exec(query, p.UUID, p.Name, p.Email, nullIfEmpty(p.PhoneNumber), nullIfEmpty(p.Address),
+		p.Position, nullIfEmpty(p.DUUID), nullIfEmpty(p.RUUID))
+	if err != nil {
+		log.Println("[DB] UpsertUserProfile error:", err)
+	}
+	return err
+}
+
+func DeleteUserProfile(db *sql.DB, uuid string) error {
+	_, err := db.Exec("DELETE FROM users WHERE uuid = $1", uuid)
+	if err != nil {
+		log.Println("[DB] DeleteUserProfile error:", err)
+	}
+	return err
+}
+
+const userProfileColumns = `uuid, COALESCE(name, ''), COALESCE(email, ''), COALESCE(phone_number::text, ''),
+	COALESCE(address, ''), COALESCE(position, ''), COALESCE(d_uuid::text, ''), COALESCE(r_uuid::text, ''),
+	COALESCE(is_active, true)`
+
+func scanUserProfile(scan func(dest ...interface{}) error) (UserProfile, error) {
+	var p UserProfile
+	err := scan(&p.UUID, &p.Name, &p.Email, &p.PhoneNumber, &p.Address, &p.Position, &p.DUUID, &p.RUUID, &p.IsActive)
+	return p, err
+}
+
+// GetUserProfile returns nil, nil when no profile row exists.
+func GetUserProfile(db *sql.DB, uuid string) (*UserProfile, error) {
+	p, err := scanUserProfile(db.QueryRow("SELECT "+userProfileColumns+" FROM users WHERE uuid = $1", uuid).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func ListUserProfiles(db *sql.DB) ([]UserProfile, error) {
+	rows, err := db.Query("SELECT " + userProfileColumns + " FROM users")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []UserProfile
+	for rows.Next() {
+		p, err := scanUserProfile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// FindDepartmentHead returns an active head of dUUID other than exclude, or "".
+func FindDepartmentHead(db *sql.DB, dUUID, exclude string) (string, error) {
+	var uuid string
+	err := db.QueryRow(`
+		SELECT uuid FROM users
+		WHERE d_uuid = $1 AND position = 'head' AND uuid <> $2 AND COALESCE(is_active, true)
+		ORDER BY name
+		LIMIT 1
+	`, dUUID, exclude).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return uuid, err
+}
+
+func CountOwnedFiles(db *sql.DB, uuid string) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM file WHERE uuid = $1", uuid).Scan(&n)
+	return n, err
+}
+
+// DeactivateUser marks the profile inactive and hands its files to reassignTo
+// in one transaction. It returns the f_uuids that changed owner so the caller
+// can undo the move.
+func DeactivateUser(db *sql.DB, uuid, reassignTo string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE users SET is_active = false, deactivated_at = NOW() WHERE uuid = $1", uuid)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	moved := []string{}
+	if reassignTo != "" {
+		rows, err := tx.Query("UPDATE file SET uuid = $2 WHERE uuid = $1 RETURNING f_uuid", uuid, reassignTo)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var f string
+			if err := rows.Scan(&f); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			moved = append(moved, f)
+		}
+		rows.Close()
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("[DB] DeactivateUser error:", err)
+		return nil, err
+	}
+	return moved, nil
+}
+
+// ReactivateUser undoes DeactivateUser, returning movedFiles to uuid.
+func ReactivateUser(db *sql.DB, uuid string, movedFiles []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET is_active = true, deactivated_at = NULL WHERE uuid = $1", uuid); err != nil {
+		return err
+	}
+	if len(movedFiles) > 0 {
+		if _, err := tx.Exec("UPDATE file SET uuid = $1 WHERE f_uuid = ANY($2)", uuid, pq.Array(movedFiles)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// User import statuses.
+const (
+	UserImportRunning     = "running"
+	UserImportDone        = "done"
+	UserImportInterrupted = "interrupted"
+)
+
+type UserImport struct {
+	ImportID  string
+	CreatedBy string
+	DryRun    bool
+	Status    string
+	// Counts and Rows are JSON.
+	Counts     json.RawMessage
+	Rows       json.RawMessage
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+func InsertUserImport(db *sql.DB, im UserImport) (UserImport, error) {
+	err := db.QueryRow(`
+		INSERT INTO user_imports (created_by, dry_run, status, counts, rows, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING import_id::text, created_at
+	`, im.CreatedBy, im.DryRun, im.Status, []byte(im.Counts), []byte(im.Rows), im.FinishedAt).Scan(&im.ImportID, &im.CreatedAt)
+	if err != nil {
+		log.Println("[DB] InsertUserImport error:", err)
+	}
+	return im, err
+}
+
+// FinishUserImport stores the final outcome of a running import.
+func FinishUserImport(db *sql.DB, id string, counts, rows json.RawMessage, at time.Time) error {
+	res, err := db.Exec(`
+		UPDATE user_imports
+		SET status = $2, counts = $3, rows = $4, finished_at = $5
+		WHERE import_id::text = $1 AND status = $6
+	`, id, UserImportDone, []byte(counts), []byte(rows), at, UserImportRunning)
+	if err != nil {
+		log.Println("[DB] FinishUserImport error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InterruptUserImports marks imports left running by a previous process.
+func InterruptUserImports(db *sql.DB, at time.Time) (int64, error) {
+	res, err := db.Exec(`
+		UPDATE user_imports SET status = $1, finished_at = $2 WHERE status = $3
+	`, UserImportInterrupted, at, UserImportRunning)
+	if err != nil {
+		log.Println("[DB] InterruptUserImports error:", err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetUserImport returns nil, nil when the import does not exist.
+func GetUserImport(db *sql.DB, id string) (*UserImport, error) {
+	var im UserImport
+	var counts, rows []byte
+	err := db.QueryRow(`
+		SELECT import_id::text, created_by, dry_run, status, counts, rows, created_at, finished_at
+		FROM user_imports
+		WHERE import_id::text = $1
+	`, id).Scan(&im.ImportID, &im.CreatedBy, &im.DryRun, &im.Status, &counts, &rows, &im.CreatedAt, &im.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetUserImport error:", err)
+		return nil, err
+	}
+	im.Counts, im.Rows = counts, rows
+	return &im, nil
+}
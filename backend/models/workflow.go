@@ -0,0 +1,329 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WorkflowTemplateStepRow is one entry of workflow_templates.steps.
+type WorkflowTemplateStepRow struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	DUUID    string `json:"d_uuid,omitempty"`
+	SLAHours int    `json:"sla_hours"`
+	Mode     string `json:"mode"`
+}
+
+type WorkflowTemplateRow struct {
+	TemplateID  string
+	Name        string
+	Description string
+	Active      bool
+	Steps       []WorkflowTemplateStepRow
+	CreatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+const workflowTemplateSelect = `
+	SELECT template_id::text, name, description, active, steps, COALESCE(created_by, ''), created_at, updated_at
+	FROM workflow_templates
+`
+
+func scanWorkflowTemplate(scan func(...interface{}) error) (WorkflowTemplateRow, error) {
+	var t WorkflowTemplateRow
+	var steps []byte
+	if err := scan(&t.TemplateID, &t.Name, &t.Description, &t.Active, &steps, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+	return t, json.Unmarshal(steps, &t.Steps)
+}
+
+func ListWorkflowTemplates(db *sql.DB) ([]WorkflowTemplateRow, error) {
+	rows, err := db.Query(workflowTemplateSelect + ` ORDER BY lower(name)`)
+	if err != nil {
+		log.Println("[DB] ListWorkflowTemplates error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []WorkflowTemplateRow{}
+	for rows.Next() {
+		t, err := scanWorkflowTemplate(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// GetWorkflowTemplate returns nil, nil when the template does not exist.
+func GetWorkflowTemplate(db *sql.DB, id string) (*WorkflowTemplateRow, error) {
+	t, err := scanWorkflowTemplate(db.QueryRow(workflowTemplateSelect+` WHERE template_id::text = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetWorkflowTemplate error:", err)
+		return nil, err
+	}
+	return &t, nil
+}
+
+func InsertWorkflowTemplate(db *sql.DB, t WorkflowTemplateRow) (WorkflowTemplateRow, error) {
+	steps, err := json.Marshal(t.Steps)
+	if err != nil {
+		return t, err
+	}
+	err = db.QueryRow(`
+		INSERT INTO workflow_templates (name, description, active, steps, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING template_id::text, created_at, updated_at
+	`, t.Name, t.Description, t.Active, steps, nullIfEmpty(t.CreatedBy)).Scan(&t.TemplateID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertWorkflowTemplate error:", err)
+	}
+	return t, err
+}
+
+// UpdateWorkflowTemplate returns sql.ErrNoRows when the template does not
+// exist.
+func UpdateWorkflowTemplate(db *sql.DB, t WorkflowTemplateRow) (WorkflowTemplateRow, error) {
+	steps, err := json.Marshal(t.Steps)
+	if err != nil {
+		return t, err
+	}
+	err = db.QueryRow(`
+		UPDATE workflow_templates SET name = $2, description = $3, active = $4, steps = $5, updated_at = NOW()
+		WHERE template_id::text = $1
+		RETURNING updated_at
+	`, t.TemplateID, t.Name, t.Description, t.Active, steps).Scan(&t.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateWorkflowTemplate error:", err)
+	}
+	return t, err
+}
+
+// DeleteWorkflowTemplate returns sql.ErrNoRows when the template does not
+// exist. Instances keep running with the steps they copied.
+func DeleteWorkflowTemplate(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM workflow_templates WHERE template_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteWorkflowTemplate error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type WorkflowStepRow struct {
+	Position        int
+	Stage           int
+	Name            string
+	Role            string
+	DUUID           string
+	Mode            string
+	SLAHours        int
+	Status          string
+	StartedAt       *time.Time
+	DueAt           *time.Time
+	ActedBy         string
+	ActedByName     string
+	ActedAt         *time.Time
+	Comment         string
+	OverdueNotified bool
+}
+
+type WorkflowInstanceRow struct {
+	WorkflowID   string
+	TemplateID   string
+	TemplateName string
+	FUUID        string
+	FileName     string
+	StartedBy    string
+	Status       string
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+	Version      int
+	Steps        []WorkflowStepRow
+}
+
+// WorkflowFilter mirrors workflows.Filter.
+type WorkflowFilter struct {
+	StartedBy    string
+	PendingDUUID string
+	FUUID        string
+	DueBefore    *time.Time
+}
+
+const workflowInstanceSelect = `
+	SELECT w.workflow_id::text, COALESCE(w.template_id::text, ''), w.template_name, w.f_uuid::text, COALESCE(f.f_name, ''),
+		w.started_by::text, w.status, w.created_at, w.completed_at, w.version
+	FROM workflow_instances w
+	LEFT JOIN file f ON f.f_uuid = w.f_uuid
+`
+
+func scanWorkflowInstances(db *sql.DB, rows *sql.Rows) ([]WorkflowInstanceRow, error) {
+	defer rows.Close()
+	out := []WorkflowInstanceRow{}
+	for rows.Next() {
+		var w WorkflowInstanceRow
+		if err := rows.Scan(&w.WorkflowID, &w.TemplateID, &w.TemplateName, &w.FUUID, &w.FileName,
+			&w.StartedBy, &w.Status, &w.CreatedAt, &w.CompletedAt, &w.Version); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return out, loadWorkflowSteps(db, out)
+}
+
+func loadWorkflowSteps(db *sql.DB, list []WorkflowInstanceRow) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, len(list))
+	index := map[string]int{}
+	for i, w := range list {
+		ids[i] = w.WorkflowID
+		index[w.WorkflowID] = i
+	}
+	rows, err := db.Query(`
+		SELECT s.workflow_id::text, s.position, s.stage, s.name, s.role, s.d_uuid::text, s.mode, s.sla_hours, s.status,
+			s.started_at, s.due_at, COALESCE(s.acted_by::text, ''), COALESCE(u.name, ''), s.acted_at, s.comment,
+			s.overdue_notified_at IS NOT NULL
+		FROM workflow_steps s
+		LEFT JOIN users u ON u.uuid = s.acted_by
+		WHERE s.workflow_id::text = ANY($1)
+		ORDER BY s.workflow_id, s.position
+	`, pq.Array(ids))
+	if err != nil {
+		log.Println("[DB] loadWorkflowSteps error:", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var s WorkflowStepRow
+		if err := rows.Scan(&id, &s.Position, &s.Stage, &s.Name, &s.Role, &s.DUUID, &s.Mode, &s.SLAHours, &s.Status,
+			&s.StartedAt, &s.DueAt, &s.ActedBy, &s.ActedByName, &s.ActedAt, &s.Comment, &s.OverdueNotified); err != nil {
+			return err
+		}
+		if i, ok := index[id]; ok {
+			list[i].Steps = append(list[i].Steps, s)
+		}
+	}
+	return rows.Err()
+}
+
+// GetWorkflowInstance returns nil, nil when the instance does not exist.
+func GetWorkflowInstance(db *sql.DB, id string) (*WorkflowInstanceRow, error) {
+	rows, err := db.Query(workflowInstanceSelect+` WHERE w.workflow_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] GetWorkflowInstance error:", err)
+		return nil, err
+	}
+	list, err := scanWorkflowInstances(db, rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListWorkflowInstances returns the instances matching f, newest first.
+func ListWorkflowInstances(db *sql.DB, f WorkflowFilter) ([]WorkflowInstanceRow, error) {
+	rows, err := db.Query(workflowInstanceSelect+`
+		WHERE ($1 = '' OR w.started_by::text = $1)
+			AND ($2 = '' OR (w.status = 'in_progress' AND EXISTS (
+				SELECT 1 FROM workflow_steps s
+				WHERE s.workflow_id = w.workflow_id AND s.status = 'pending' AND s.d_uuid::text = $2)))
+			AND ($3 = '' OR w.f_uuid::text = $3)
+			AND ($4::timestamptz IS NULL OR (w.status = 'in_progress' AND EXISTS (
+				SELECT 1 FROM workflow_steps s
+				WHERE s.workflow_id = w.workflow_id AND s.status = 'pending'
+					AND s.due_at < $4 AND s.overdue_notified_at IS NULL)))
+		ORDER BY w.created_at DESC, w.workflow_id
+		LIMIT 500
+	`, f.StartedBy, f.PendingDUUID, f.FUUID, f.DueBefore)
+	if err != nil {
+		log.Println("[DB] ListWorkflowInstances error:", err)
+		return nil, err
+	}
+	return scanWorkflowInstances(db, rows)
+}
+
+func insertWorkflowStep(tx *sql.Tx, id string, s WorkflowStepRow) error {
+	_, err := tx.Exec(`
+		INSERT INTO workflow_steps (workflow_id, position, stage, name, role, d_uuid, mode, sla_hours, status, started_at, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, id, s.Position, s.Stage, s.Name, s.Role, s.DUUID, s.Mode, s.SLAHours, s.Status, s.StartedAt, s.DueAt)
+	return err
+}
+
+// InsertWorkflowInstance stores w with its steps at version 1.
+func InsertWorkflowInstance(db *sql.DB, w WorkflowInstanceRow) (WorkflowInstanceRow, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return w, err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRow(`
+		INSERT INTO workflow_instances (template_id, template_name, f_uuid, started_by, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING workflow_id::text, created_at, version
+	`, nullIfEmpty(w.TemplateID), w.TemplateName, w.FUUID, w.StartedBy, w.Status).Scan(&w.WorkflowID, &w.CreatedAt, &w.Version)
+	if err != nil {
+		log.Println("[DB] InsertWorkflowInstance error:", err)
+		return w, err
+	}
+	for _, s := range w.Steps {
+		if err := insertWorkflowStep(tx, w.WorkflowID, s); err != nil {
+			log.Println("[DB] InsertWorkflowInstance step error:", err)
+			return w, err
+		}
+	}
+	return w, tx.Commit()
+}
+
+// SaveWorkflowInstance writes w's status and steps if the stored version is
+// still w.Version, bumping it. It reports false, nil when another update
+// got there first.
+func SaveWorkflowInstance(db *sql.DB, w WorkflowInstanceRow) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`
+		UPDATE workflow_instances SET status = $3, completed_at = $4, version = version + 1
+		WHERE workflow_id::text = $1 AND version = $2
+	`, w.WorkflowID, w.Version, w.Status, w.CompletedAt)
+	if err != nil {
+		log.Println("[DB] SaveWorkflowInstance error:", err)
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	for _, s := range w.Steps {
+		if _, err := tx.Exec(`
+			UPDATE workflow_steps SET status = $3, started_at = $4, due_at = $5, acted_by = $6, acted_at = $7, comment = $8,
+				overdue_notified_at = CASE WHEN $9 THEN COALESCE(overdue_notified_at, NOW()) END
+			WHERE workflow_id::text = $1 AND position = $2
+		`, w.WorkflowID, s.Position, s.Status, s.StartedAt, s.DueAt, nullIfEmpty(s.ActedBy), s.ActedAt, s.Comment,
+			s.OverdueNotified); err != nil {
+			log.Println("[DB] SaveWorkflowInstance step error:", err)
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,349 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WorkspaceDepartmentRow struct {
+	DUUID     string
+	DName     string
+	Status    string
+	InvitedBy string
+	InvitedAt time.Time
+	JoinedAt  *time.Time
+}
+
+type WorkspaceRow struct {
+	WorkspaceID string
+	Name        string
+	Description string
+	CreatedBy   string
+	CreatorName string
+	OwnerDUUID  string
+	FileCount   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Departments []WorkspaceDepartmentRow
+}
+
+const workspaceSelect = `
+	SELECT w.workspace_id::text, w.name, w.description, w.created_by::text, COALESCE(u.name, ''), w.owner_d_uuid::text,
+		(SELECT COUNT(*) FROM workspace_files wf WHERE wf.workspace_id = w.workspace_id),
+		w.created_at, w.updated_at
+	FROM workspaces w
+	LEFT JOIN users u ON u.uuid = w.created_by
+`
+
+func scanWorkspaces(db *sql.DB, rows *sql.Rows) ([]WorkspaceRow, error) {
+	defer rows.Close()
+	out := []WorkspaceRow{}
+	for rows.Next() {
+		var w WorkspaceRow
+		if err := rows.Scan(&w.WorkspaceID, &w.Name, &w.Description, &w.CreatedBy, &w.CreatorName, &w.OwnerDUUID,
+			&w.FileCount, &w.CreatedAt, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return out, loadWorkspaceDepartments(db, out)
+}
+
+func loadWorkspaceDepartments(db *sql.DB, list []WorkspaceRow) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, len(list))
+	index := map[string]int{}
+	for i, w := range list {
+		ids[i] = w.WorkspaceID
+		index[w.WorkspaceID] = i
+	}
+	rows, err := db.Query(`
+		SELECT wd.workspace_id::text, wd.d_uuid::text, COALESCE(d.d_name, ''), wd.status,
+			COALESCE(wd.invited_by::text, ''), wd.invited_at, wd.joined_at
+		FROM workspace_departments wd
+		LEFT JOIN department d ON d.d_uuid = wd.d_uuid
+		WHERE wd.workspace_id::text = ANY($1)
+		ORDER BY wd.invited_at, d.d_name
+	`, pq.Array(ids))
+	if err != nil {
+		log.Println("[DB] loadWorkspaceDepartments error:", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var d WorkspaceDepartmentRow
+		var joined sql.NullTime
+		if err := rows.Scan(&id, &d.DUUID, &d.DName, &d.Status, &d.InvitedBy, &d.InvitedAt, &joined); err != nil {
+			return err
+		}
+		if joined.Valid {
+			t := joined.Time
+			d.JoinedAt = &t
+		}
+		list[index[id]].Departments = append(list[index[id]].Departments, d)
+	}
+	return rows.Err()
+}
+
+// ListWorkspaces returns the workspaces department dUUID has joined or been
+// invited to.
+func ListWorkspaces(db *sql.DB, dUUID string) ([]WorkspaceRow, error) {
+	rows, err := db.Query(workspaceSelect+`
+		WHERE EXISTS (SELECT 1 FROM workspace_departments wd WHERE wd.workspace_id = w.workspace_id AND wd.d_uuid::text = $1)
+		ORDER BY lower(w.name), w.workspace_id
+	`, dUUID)
+	if err != nil {
+		log.Println("[DB] ListWorkspaces error:", err)
+		return nil, err
+	}
+	return scanWorkspaces(db, rows)
+}
+
+// GetWorkspace returns nil, nil when the workspace does not exist.
+func GetWorkspace(db *sql.DB, id string) (*WorkspaceRow, error) {
+	rows, err := db.Query(workspaceSelect+` WHERE w.workspace_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] GetWorkspace error:", err)
+		return nil, err
+	}
+	list, err := scanWorkspaces(db, rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// InsertWorkspace creates the workspace with its owner department joined.
+func InsertWorkspace(db *sql.DB, w WorkspaceRow) (WorkspaceRow, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return w, err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRow(`
+		INSERT INTO workspaces (name, description, created_by, owner_d_uuid)
+		VALUES ($1, $2, $3, $4)
+		RETURNING workspace_id::text, created_at, updated_at
+	`, w.Name, w.Description, w.CreatedBy, w.OwnerDUUID).Scan(&w.WorkspaceID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		log.Println("[DB] InsertWorkspace error:", err)
+		return w, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO workspace_departments (workspace_id, d_uuid, status, invited_by, invited_at, joined_at)
+		VALUES ($1, $2, 'joined', $3, $4, $4)
+	`, w.WorkspaceID, w.OwnerDUUID, w.CreatedBy, w.CreatedAt); err != nil {
+		log.Println("[DB] InsertWorkspace error:", err)
+		return w, err
+	}
+	return w, tx.Commit()
+}
+
+// UpdateWorkspace returns sql.ErrNoRows when the workspace does not exist.
+func UpdateWorkspace(db *sql.DB, w WorkspaceRow) (WorkspaceRow, error) {
+	err := db.QueryRow(`
+		UPDATE workspaces SET name = $2, description = $3, updated_at = NOW()
+		WHERE workspace_id::text = $1
+		RETURNING updated_at
+	`, w.WorkspaceID, w.Name, w.Description).Scan(&w.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("[DB] UpdateWorkspace error:", err)
+	}
+	return w, err
+}
+
+// DeleteWorkspace removes a workspace with its departments, links and
+// comments. It returns sql.ErrNoRows when the workspace does not exist.
+func DeleteWorkspace(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM workspaces WHERE workspace_id::text = $1`, id)
+	if err != nil {
+		log.Println("[DB] DeleteWorkspace error:", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpsertWorkspaceDepartment adds a department or updates its status.
+func UpsertWorkspaceDepartment(db *sql.DB, id string, d WorkspaceDepartmentRow) error {
+	_, err := db.Exec(`
+		INSERT INTO workspace_departments (workspace_id, d_uuid, status, invited_by, invited_at, joined_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (workspace_id, d_uuid) DO UPDATE SET status = EXCLUDED.status, joined_at = EXCLUDED.joined_at
+	`, id, d.DUUID, d.Status, nullIfEmpty(d.InvitedBy), d.InvitedAt, d.JoinedAt)
+	if err != nil {
+		log.Println("[DB] UpsertWorkspaceDepartment error:", err)
+		return err
+	}
+	_, err = db.Exec(`UPDATE workspaces SET updated_at = NOW() WHERE workspace_id::text = $1`, id)
+	return err
+}
+
+func DeleteWorkspaceDepartment(db *sql.DB, id, dUUID string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM workspace_departments WHERE workspace_id::text = $1 AND d_uuid::text = $2`, id, dUUID)
+	if err != nil {
+		log.Println("[DB] DeleteWorkspaceDepartment error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// WorkspaceFileRow is a linked file with its own sharing.
+type WorkspaceFileRow struct {
+	FUUID        string
+	FileName     string
+	OwnerUUID    string
+	Sensitivity  string
+	Departments  []string
+	LinkedBy     string
+	LinkedByName string
+	LinkedAt     time.Time
+}
+
+// ListWorkspaceFiles returns the files linked into a workspace, newest
+// first.
+func ListWorkspaceFiles(db *sql.DB, id string) ([]WorkspaceFileRow, error) {
+	rows, err := db.Query(`
+		SELECT f.f_uuid::text, COALESCE(f.f_name, ''), COALESCE(f.uuid::text, ''), COALESCE(f.sensitivity, 'internal'),
+			COALESCE((SELECT array_agg(fd.d_uuid::text) FROM file_department fd WHERE fd.f_uuid = f.f_uuid), '{}'),
+			COALESCE(wf.linked_by::text, ''), COALESCE(u.name, ''), wf.linked_at
+		FROM workspace_files wf
+		JOIN file f ON f.f_uuid = wf.f_uuid
+		LEFT JOIN users u ON u.uuid = wf.linked_by
+		WHERE wf.workspace_id::text = $1
+		ORDER BY wf.linked_at DESC, f.f_uuid
+	`, id)
+	if err != nil {
+		log.Println("[DB] ListWorkspaceFiles error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []WorkspaceFileRow{}
+	for rows.Next() {
+		var r WorkspaceFileRow
+		var depts pq.StringArray
+		if err := rows.Scan(&r.FUUID, &r.FileName, &r.OwnerUUID, &r.Sensitivity, &depts, &r.LinkedBy, &r.LinkedByName, &r.LinkedAt); err != nil {
+			return nil, err
+		}
+		r.Departments = []string(depts)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// LinkWorkspaceFile is a no-op when the file is already linked.
+func LinkWorkspaceFile(db *sql.DB, id, fuuid, linkedBy string) error {
+	_, err := db.Exec(`
+		INSERT INTO workspace_files (workspace_id, f_uuid, linked_by, linked_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (workspace_id, f_uuid) DO NOTHING
+	`, id, fuuid, nullIfEmpty(linkedBy))
+	if err != nil {
+		log.Println("[DB] LinkWorkspaceFile error:", err)
+	}
+	return err
+}
+
+func UnlinkWorkspaceFile(db *sql.DB, id, fuuid string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM workspace_files WHERE workspace_id::text = $1 AND f_uuid::text = $2`, id, fuuid)
+	if err != nil {
+		log.Println("[DB] UnlinkWorkspaceFile error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+type WorkspaceCommentRow struct {
+	CommentID   string
+	WorkspaceID string
+	FUUID       string
+	AuthorUUID  string
+	AuthorName  string
+	Body        string
+	CreatedAt   time.Time
+}
+
+const workspaceCommentSelect = `
+	SELECT c.comment_id::text, c.workspace_id::text, COALESCE(c.f_uuid::text, ''), c.author_uuid::text,
+		COALESCE(u.name, ''), c.body, c.created_at
+	FROM workspace_comments c
+	LEFT JOIN users u ON u.uuid = c.author_uuid
+`
+
+func scanWorkspaceComment(scan func(...interface{}) error) (WorkspaceCommentRow, error) {
+	var c WorkspaceCommentRow
+	err := scan(&c.CommentID, &c.WorkspaceID, &c.FUUID, &c.AuthorUUID, &c.AuthorName, &c.Body, &c.CreatedAt)
+	return c, err
+}
+
+// ListWorkspaceComments returns a workspace's comments, oldest first.
+func ListWorkspaceComments(db *sql.DB, id string) ([]WorkspaceCommentRow, error) {
+	rows, err := db.Query(workspaceCommentSelect+`
+		WHERE c.workspace_id::text = $1
+		ORDER BY c.created_at, c.comment_id
+	`, id)
+	if err != nil {
+		log.Println("[DB] ListWorkspaceComments error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	out := []WorkspaceCommentRow{}
+	for rows.Next() {
+		c, err := scanWorkspaceComment(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetWorkspaceComment returns nil, nil when the comment does not exist.
+func GetWorkspaceComment(db *sql.DB, id, commentID string) (*WorkspaceCommentRow, error) {
+	c, err := scanWorkspaceComment(db.QueryRow(workspaceCommentSelect+`
+		WHERE c.workspace_id::text = $1 AND c.comment_id::text = $2
+	`, id, commentID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Println("[DB] GetWorkspaceComment error:", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+func InsertWorkspaceComment(db *sql.DB, c WorkspaceCommentRow) (WorkspaceCommentRow, error) {
+	err := db.QueryRow(`
+		INSERT INTO workspace_comments (workspace_id, f_uuid, author_uuid, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING comment_id::text, created_at
+	`, c.WorkspaceID, nullIfEmpty(c.FUUID), c.AuthorUUID, c.Body).Scan(&c.CommentID, &c.CreatedAt)
+	if err != nil {
+		log.Println("[DB] InsertWorkspaceComment error:", err)
+	}
+	return c, err
+}
+
+func DeleteWorkspaceComment(db *sql.DB, id, commentID string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM workspace_comments WHERE workspace_id::text = $1 AND comment_id::text = $2`, id, commentID)
+	if err != nil {
+		log.Println("[DB] DeleteWorkspaceComment error:", err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
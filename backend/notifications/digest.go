@@ -0,0 +1,341 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+// KindDigest is the batched daily/weekly email.
+const KindDigest = "digest"
+
+// IST is the zone digest send times are configured in.
+var IST = time.FixedZone("IST", 5*3600+30*60)
+
+// digestible kinds are held back from email for users on a daily or weekly
+// digest; other channels still get them straight away.
+func digestible(kind string) bool {
+	return kind == KindNewFile || kind == KindSummaryReady
+}
+
+// DigestPayload is stored with digest entries.
+type DigestPayload struct {
+	Frequency   string           `json:"frequency"`
+	PeriodLabel string           `json:"period_label"`
+	Name        string           `json:"name,omitempty"`
+	FileCount   int              `json:"file_count"`
+	Groups      []DigestGroup    `json:"groups"`
+	Deadlines   []DigestDeadline `json:"deadlines"`
+}
+
+// DigestGroup is the files of one department.
+type DigestGroup struct {
+	Department string       `json:"department"`
+	Files      []DigestFile `json:"files"`
+}
+
+type DigestFile struct {
+	FUUID       string    `json:"f_uuid"`
+	FileName    string    `json:"f_name"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Summary     string    `json:"summary,omitempty"`
+	Sensitivity string    `json:"sensitivity,omitempty"`
+}
+
+type DigestDeadline struct {
+	Title      string    `json:"title"`
+	DueAt      time.Time `json:"due_at"`
+	FUUID      string    `json:"f_uuid,omitempty"`
+	FileName   string    `json:"f_name,omitempty"`
+	Department string    `json:"department,omitempty"`
+}
+
+// digestSummaryLimit keeps each file's summary to a short snippet.
+const digestSummaryLimit = 400
+
+// digestPeriod is one delivery window. Key identifies it in digest_deliveries.
+type digestPeriod struct {
+	Key   string
+	Label string
+	Start time.Time
+	End   time.Time
+}
+
+// currentDigestPeriod returns the most recent period of freq that has ended
+// (at sendAt past midnight IST, on weekday for weekly digests). ok is false
+// once more than grace has passed since then, so a long outage skips a
+// period instead of sending a stale digest.
+func currentDigestPeriod(now time.Time, freq string, sendAt time.Duration, weekday time.Weekday, grace time.Duration) (digestPeriod, bool) {
+	local := now.In(IST)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, IST)
+
+	var p digestPeriod
+	switch freq {
+	case models.DigestDaily:
+		end := midnight.Add(sendAt)
+		if end.After(local) {
+			end = end.AddDate(0, 0, -1)
+		}
+		p = digestPeriod{
+			Key:   "daily:" + end.Format("2006-01-02"),
+			Label: end.Format("Mon, 02 Jan 2006"),
+			Start: end.AddDate(0, 0, -1),
+			End:   end,
+		}
+	case models.DigestWeekly:
+		back := (int(local.Weekday()) - int(weekday) + 7) % 7
+		end := midnight.AddDate(0, 0, -back).Add(sendAt)
+		if end.After(local) {
+			end = end.AddDate(0, 0, -7)
+		}
+		year, week := end.ISOWeek()
+		start := end.AddDate(0, 0, -7)
+		p = digestPeriod{
+			Key:   fmt.Sprintf("weekly:%d-W%02d", year, week),
+			Label: start.Format("02 Jan") + " – " + end.Format("02 Jan 2006"),
+			Start: start,
+			End:   end,
+		}
+	default:
+		return digestPeriod{}, false
+	}
+	return p, local.Sub(p.End) < grace
+}
+
+// groupDigestFiles groups rows (ordered by department) into one group per
+// department, dropping repeats of the same file and trimming summaries.
+// Summaries of confidential and restricted files are withheld.
+func groupDigestFiles(rows []models.DigestFile) ([]DigestGroup, int) {
+	var groups []DigestGroup
+	index := map[string]int{}
+	seen := map[string]bool{}
+	files := map[string]bool{}
+	for _, r := range rows {
+		if seen[r.Department+"\x00"+r.FUUID] {
+			continue
+		}
+		seen[r.Department+"\x00"+r.FUUID] = true
+		files[r.FUUID] = true
+
+		i, ok := index[r.Department]
+		if !ok {
+			i = len(groups)
+			index[r.Department] = i
+			groups = append(groups, DigestGroup{Department: r.Department})
+		}
+		groups[i].Files = append(groups[i].Files, DigestFile{
+			FUUID: r.FUUID, FileName: r.FileName, UploadedAt: r.UploadedAt.In(IST),
+			Summary:     messageSummary(r.Sensitivity, snippet(r.Summary, digestSummaryLimit)),
+			Sensitivity: r.Sensitivity,
+		})
+	}
+	return groups, len(files)
+}
+
+// snippet shortens s to at most limit runes, cutting at a word boundary.
+func snippet(s string, limit int) string {
+	s = strings.TrimSpace(s)
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > limit/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// DigestScheduler sends daily and weekly digests and deadline reminders.
+// Each user gets at most one digest per period: the period is recorded in
+// digest_deliveries in the same transaction that queues the email, and the
+// email itself goes out through the dispatcher.
+type DigestScheduler struct {
+	db *sql.DB
+
+	// SendAt is the offset from midnight IST at which a period ends.
+	SendAt   time.Duration
+	Weekday  time.Weekday
+	Grace    time.Duration
+	Interval time.Duration
+	// Lookahead is how far ahead upcoming deadlines are listed.
+	Lookahead time.Duration
+	// ReminderWindow is how long before a deadline its reminder goes out.
+	ReminderWindow time.Duration
+	Now            func() time.Time
+}
+
+// NewDigestScheduler reads DIGEST_SEND_TIME (HH:MM IST, default 08:00) and
+// DIGEST_WEEKDAY (default monday).
+func NewDigestScheduler(db *sql.DB) *DigestScheduler {
+	s := &DigestScheduler{
+		db:             db,
+		SendAt:         8 * time.Hour,
+		Weekday:        time.Monday,
+		Grace:          12 * time.Hour,
+		Interval:       time.Minute,
+		Lookahead:      7 * 24 * time.Hour,
+		ReminderWindow: 24 * time.Hour,
+		Now:            time.Now,
+	}
+	if v := os.Getenv("DIGEST_SEND_TIME"); v != "" {
+		if d, err := ParseSendTime(v); err == nil {
+			s.SendAt = d
+		} else {
+			log.Printf("[DIGEST] Ignoring DIGEST_SEND_TIME=%q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("DIGEST_WEEKDAY"); v != "" {
+		if d, err := ParseWeekday(v); err == nil {
+			s.Weekday = d
+		} else {
+			log.Printf("[DIGEST] Ignoring DIGEST_WEEKDAY=%q: %v", v, err)
+		}
+	}
+	return s
+}
+
+// ParseSendTime parses "HH:MM" into an offset from midnight.
+func ParseSendTime(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWeekday accepts full or three-letter English day names.
+func ParseWeekday(v string) (time.Weekday, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if v == name || v == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday")
+}
+
+// Run checks for due digests and reminders every Interval until ctx is done.
+func (s *DigestScheduler) Run(ctx context.Context) {
+	log.Printf("[DIGEST] Scheduler started (send at %s IST, weekly on %s)", fmtOffset(s.SendAt), s.Weekday)
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		s.RunOnce(s.Now())
+		select {
+		case <-ctx.Done():
+			log.Println("[DIGEST] Scheduler stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func fmtOffset(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// RunOnce queues every digest and reminder due at now and returns how many
+// digests were recorded.
+func (s *DigestScheduler) RunOnce(now time.Time) int {
+	sent := 0
+	for _, freq := range []string{models.DigestDaily, models.DigestWeekly} {
+		p, ok := currentDigestPeriod(now, freq, s.SendAt, s.Weekday, s.Grace)
+		if !ok {
+			continue
+		}
+		subs, err := models.ListDigestSubscribers(s.db, freq, p.Key)
+		if err != nil {
+			continue
+		}
+		for _, sub := range subs {
+			if err := s.sendDigest(now, freq, p, sub); err != nil {
+				log.Printf("[DIGEST] %s for %s failed: %v", p.Key, sub.UUID, err)
+				continue
+			}
+			sent++
+		}
+	}
+	s.sendReminders(now)
+	return sent
+}
+
+func (s *DigestScheduler) sendDigest(now time.Time, freq string, p digestPeriod, sub models.DigestSubscriber) error {
+	rows, err := models.ListDigestFiles(s.db, sub.UUID, sub.DUUID, p.Start, p.End)
+	if err != nil {
+		return err
+	}
+	deadlines, err := models.ListUpcomingDeadlines(s.db, sub.DUUID, now, now.Add(s.Lookahead))
+	if err != nil {
+		return err
+	}
+	groups, fileCount := groupDigestFiles(rows)
+	payload := DigestPayload{
+		Frequency: freq, PeriodLabel: p.Label, Name: sub.Name, FileCount: fileCount, Groups: groups,
+	}
+	for _, d := range deadlines {
+		payload.Deadlines = append(payload.Deadlines, DigestDeadline{
+			Title: d.Title, DueAt: d.DueAt.In(IST), FUUID: d.FUUID, FileName: d.FileName, Department: d.Department,
+		})
+	}
+
+	// Empty periods are still recorded so they are not re-checked every tick.
+	var entry *models.OutboxEntry
+	if fileCount > 0 || len(payload.Deadlines) > 0 {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		entry = &models.OutboxEntry{
+			IdempotencyKey: fmt.Sprintf("%s:%s:%s:%s", KindDigest, p.Key, sub.UUID, ChannelEmail),
+			Kind:           KindDigest,
+			Channel:        ChannelEmail,
+			RecipientUUID:  sub.UUID,
+			Recipient:      sub.Email,
+			Payload:        body,
+		}
+	}
+	recorded, err := models.RecordDigestDelivery(s.db, sub.UUID, p.Key, fileCount, len(payload.Deadlines), entry)
+	if err == nil && recorded && entry != nil {
+		log.Printf("[DIGEST] Queued %s for %s (%d files, %d deadlines)", p.Key, sub.UUID, fileCount, len(payload.Deadlines))
+	}
+	return err
+}
+
+// sendReminders queues a deadline_reminder for every member of the
+// deadline's department once it is within ReminderWindow.
+func (s *DigestScheduler) sendReminders(now time.Time) {
+	due, err := models.ListDeadlinesNeedingReminder(s.db, now, now.Add(s.ReminderWindow))
+	if err != nil {
+		return
+	}
+	for _, d := range due {
+		members, err := models.ListDepartmentMemberIDs(s.db, d.DUUID)
+		if err != nil {
+			log.Printf("[DIGEST] Reminder recipients for deadline %s failed: %v", d.ID, err)
+			continue
+		}
+		payload := DeadlineReminderPayload{
+			DeadlineID: d.ID, Title: d.Title, DueAt: d.DueAt.In(IST), FUUID: d.FUUID, FileName: d.FileName, Department: d.Department,
+		}
+		failed := false
+		for _, uid := range members {
+			if _, err := EnqueueEvent(s.db, KindDeadlineReminder, d.ID+":"+uid, uid, payload); err != nil {
+				failed = true
+			}
+		}
+		// Leave it unmarked on failure; idempotency keys stop repeats for
+		// the members that did get queued.
+		if !failed {
+			_ = models.MarkDeadlineReminded(s.db, d.ID)
+		}
+	}
+}
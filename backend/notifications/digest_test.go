@@ -0,0 +1,110 @@
+package notifications
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+func TestCurrentDigestPeriod(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, IST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	grace := 12 * time.Hour
+
+	// 2025-03-05 is a Wednesday.
+	p, ok := currentDigestPeriod(at("2025-03-05 08:30"), models.DigestDaily, 8*time.Hour, time.Monday, grace)
+	if !ok || p.Key != "daily:2025-03-05" || !p.End.Equal(at("2025-03-05 08:00")) || !p.Start.Equal(at("2025-03-04 08:00")) {
+		t.Fatalf("unexpected daily period %+v ok=%v", p, ok)
+	}
+	// Before today's send time the previous period is current, but it is
+	// past its grace by then.
+	p, ok = currentDigestPeriod(at("2025-03-05 07:59"), models.DigestDaily, 8*time.Hour, time.Monday, grace)
+	if ok || p.Key != "daily:2025-03-04" {
+		t.Fatalf("expected yesterday's stale period, got %+v ok=%v", p, ok)
+	}
+	// Computed in IST regardless of the caller's zone.
+	p, _ = currentDigestPeriod(at("2025-03-05 08:30").UTC(), models.DigestDaily, 8*time.Hour, time.Monday, grace)
+	if p.Key != "daily:2025-03-05" {
+		t.Fatalf("expected IST period, got %s", p.Key)
+	}
+
+	p, ok = currentDigestPeriod(at("2025-03-05 12:00"), models.DigestWeekly, 8*time.Hour, time.Wednesday, grace)
+	if !ok || p.Key != "weekly:2025-W10" || !p.Start.Equal(at("2025-02-26 08:00")) {
+		t.Fatalf("unexpected weekly period %+v ok=%v", p, ok)
+	}
+	// Two days after the weekly send time the period is past its grace.
+	if _, ok := currentDigestPeriod(at("2025-03-05 12:00"), models.DigestWeekly, 8*time.Hour, time.Monday, grace); ok {
+		t.Fatal("expected stale weekly period to be skipped")
+	}
+	if _, ok := currentDigestPeriod(at("2025-03-05 12:00"), models.DigestImmediate, 8*time.Hour, time.Monday, grace); ok {
+		t.Fatal("immediate has no digest period")
+	}
+}
+
+func TestGroupDigestFiles(t *testing.T) {
+	long := strings.Repeat("word ", 200)
+	groups, n := groupDigestFiles([]models.DigestFile{
+		{FUUID: "f1", FileName: "a.pdf", Department: "Finance"},
+		{FUUID: "f1", FileName: "a.pdf", Department: "Finance"},
+		{FUUID: "f2", FileName: "b.pdf", Department: "Finance", Summary: long},
+		{FUUID: "f1", FileName: "a.pdf", Department: "Operations"},
+	})
+	if n != 2 || len(groups) != 2 || len(groups[0].Files) != 2 || groups[1].Department != "Operations" {
+		t.Fatalf("unexpected grouping n=%d %+v", n, groups)
+	}
+	if s := groups[0].Files[1].Summary; len([]rune(s)) > digestSummaryLimit+1 || !strings.HasSuffix(s, "…") {
+		t.Fatalf("summary not trimmed: %q", s)
+	}
+}
+
+func TestDigestUsersSkipImmediateEmail(t *testing.T) {
+	prefs := models.NotificationPreferences{UUID: "u1", EmailEnabled: true, InAppEnabled: true, DigestFrequency: models.DigestDaily}
+	entries := fanOut(KindNewFile, "new_file:n1", "a@kmrl.in", prefs, []byte(`{}`))
+	if len(entries) != 1 || entries[0].Channel != ChannelInApp {
+		t.Fatalf("expected in-app only, got %+v", entries)
+	}
+	entries = fanOut(KindDeadlineReminder, "deadline_reminder:d1:u1", "a@kmrl.in", prefs, []byte(`{}`))
+	if len(entries) != 2 {
+		t.Fatalf("reminders are not digested, got %+v", entries)
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	payload, _ := json.Marshal(DigestPayload{
+		Frequency: models.DigestDaily, PeriodLabel: "Wed, 05 Mar 2025", FileCount: 1,
+		Groups:    []DigestGroup{{Department: "Finance", Files: []DigestFile{{FUUID: "f1", FileName: "a.pdf", Summary: "Budget"}}}},
+		Deadlines: []DigestDeadline{{Title: "Audit", DueAt: time.Date(2025, 3, 7, 10, 0, 0, 0, IST)}},
+	})
+	msg, err := renderMessage(models.OutboxEntry{Kind: KindDigest, Channel: ChannelEmail, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Your daily digest: 1 new file, 1 upcoming deadline" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	for _, want := range []string{"== Finance ==", "a.pdf", "Budget", "/file/f1", "Audit"} {
+		if !strings.Contains(msg.Text, want) || (want != "== Finance ==" && !strings.Contains(msg.HTML, want)) {
+			t.Fatalf("digest missing %q:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestParseDigestSettings(t *testing.T) {
+	if d, err := ParseSendTime("07:30"); err != nil || d != 7*time.Hour+30*time.Minute {
+		t.Fatalf("got %s %v", d, err)
+	}
+	if _, err := ParseSendTime("25:00"); err == nil {
+		t.Fatal("expected error")
+	}
+	if d, err := ParseWeekday("Fri"); err != nil || d != time.Friday {
+		t.Fatalf("got %s %v", d, err)
+	}
+}
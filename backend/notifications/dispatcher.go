@@ -0,0 +1,152 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend/models"
+)
+
+// Deliverer sends one outbox entry. Returning a Permanent error marks the
+// entry dead straight away; any other error is retried with backoff.
+type Deliverer func(ctx context.Context, e models.OutboxEntry) error
+
+type permanentError struct{ err error }
+
+func (p permanentError) Error() string { return p.err.Error() }
+func (p permanentError) Unwrap() error { return p.err }
+
+// Permanent marks err as not worth retrying (bad address, unknown kind, ...).
+func Permanent(err error) error { return permanentError{err: err} }
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Source copies new events into the outbox. Collect must be idempotent: it
+// is called on every tick and should only enqueue events it has not seen.
+type Source struct {
+	Name    string
+	Collect func(limit int) (int, error)
+}
+
+// Dispatcher is the single loop that moves events from their sources into
+// the outbox and from the outbox to recipients.
+type Dispatcher struct {
+	store   Store
+	deliver Deliverer
+	sources []Source
+
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// Lease is how long a claimed entry stays hidden; if the process dies
+	// mid-delivery the entry becomes due again once it passes.
+	Lease time.Duration
+	Now   func() time.Time
+}
+
+func NewDispatcher(store Store, deliver Deliverer, sources ...Source) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		deliver:      deliver,
+		sources:      sources,
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		Lease:        5 * time.Minute,
+		Now:          time.Now,
+	}
+}
+
+// Backoff is the delay before retry number attempts+1: BaseBackoff doubled
+// per failed attempt, capped at MaxBackoff.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Run polls until ctx is cancelled. The batch in progress is finished first,
+// so no claimed entry is abandoned mid-send.
+func (d *Dispatcher) Run(ctx context.Context) {
+	log.Printf("[NOTIF] Dispatcher started (poll every %s)", d.PollInterval)
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			log.Println("[NOTIF] Dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce collects from every source and delivers one batch of due entries.
+// It returns how many entries were delivered.
+func (d *Dispatcher) RunOnce(ctx context.Context) int {
+	for _, src := range d.sources {
+		if n, err := src.Collect(d.BatchSize); err != nil {
+			log.Printf("[NOTIF] Collect %s failed: %v", src.Name, err)
+		} else if n > 0 {
+			log.Printf("[NOTIF] Queued %d %s message(s)", n, src.Name)
+		}
+	}
+
+	now := d.Now()
+	entries, err := d.store.Claim(now, now.Add(d.Lease), d.BatchSize)
+	if err != nil {
+		log.Println("[NOTIF] Claim failed:", err)
+		return 0
+	}
+
+	delivered := 0
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			// Shutting down: give the rest back without waiting for the lease.
+			for _, rest := range entries[i:] {
+				_ = d.store.MarkRetry(rest.ID, now, "dispatcher stopped before delivery")
+			}
+			break
+		}
+		if d.handle(ctx, e) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+func (d *Dispatcher) handle(ctx context.Context, e models.OutboxEntry) bool {
+	err := d.deliver(ctx, e)
+	if err == nil {
+		if err := d.store.MarkSent(e.ID); err != nil {
+			log.Printf("[NOTIF] Delivered %s but failed to mark it sent: %v", e.IdempotencyKey, err)
+		}
+		return true
+	}
+
+	if IsPermanent(err) || e.Attempts >= d.MaxAttempts {
+		log.Printf("[NOTIF] Giving up on %s after %d attempt(s): %v", e.IdempotencyKey, e.Attempts, err)
+		_ = d.store.MarkDead(e.ID, err.Error())
+		return false
+	}
+	next := d.Now().Add(d.Backoff(e.Attempts))
+	log.Printf("[NOTIF] Delivery of %s failed (attempt %d), retrying at %s: %v",
+		e.IdempotencyKey, e.Attempts, next.Format(time.RFC3339), err)
+	_ = d.store.MarkRetry(e.ID, next, err.Error())
+	return false
+}
@@ -0,0 +1,348 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestDispatcher(store Store, deliver Deliverer, sources ...Source) (*Dispatcher, *clock) {
+	// Start ahead of wall time so freshly enqueued entries are already due.
+	c := &clock{t: time.Now().Add(time.Hour)}
+	d := NewDispatcher(store, deliver, sources...)
+	d.Now = c.now
+	d.MaxAttempts = 3
+	d.BaseBackoff = time.Minute
+	d.MaxBackoff = 10 * time.Minute
+	return d, c
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d, _ := newTestDispatcher(NewMemoryStore(), nil)
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, w := range want {
+		if got := d.Backoff(i + 1); got != w {
+			t.Fatalf("attempt %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+}
+
+func TestDispatcherRetriesThenGivesUp(t *testing.T) {
+	store := NewMemoryStore()
+	store.Enqueue([]models.OutboxEntry{{IdempotencyKey: "k1", Kind: KindNewFile, Channel: ChannelEmail, Recipient: "a@kmrl.in"}})
+
+	calls := 0
+	d, c := newTestDispatcher(store, func(ctx context.Context, e models.OutboxEntry) error {
+		calls++
+		return errors.New("smtp unavailable")
+	})
+
+	d.RunOnce(context.Background())
+	e := store.Entries()[0]
+	if e.Status != "pending" || e.Attempts != 1 || !e.NextAttemptAt.Equal(c.t.Add(time.Minute)) {
+		t.Fatalf("expected retry in 1m, got %+v", e)
+	}
+
+	// Not due yet: nothing happens.
+	d.RunOnce(context.Background())
+	if calls != 1 {
+		t.Fatalf("expected no delivery before backoff elapses, got %d calls", calls)
+	}
+
+	c.t = c.t.Add(time.Minute)
+	d.RunOnce(context.Background())
+	c.t = c.t.Add(2 * time.Minute)
+	d.RunOnce(context.Background())
+	e = store.Entries()[0]
+	if calls != 3 || e.Status != "dead" || e.LastError != "smtp unavailable" {
+		t.Fatalf("expected entry dead after 3 attempts, got calls=%d %+v", calls, e)
+	}
+}
+
+func TestDispatcherPermanentErrorAndSuccess(t *testing.T) {
+	store := NewMemoryStore()
+	d, _ := newTestDispatcher(store, Route(SMTPNotifier{Send: func(to, subject, text, html string) error { return nil }}))
+	store.Enqueue([]models.OutboxEntry{
+		{IdempotencyKey: "ok", Kind: KindNewFile, Channel: ChannelEmail, Recipient: "a@kmrl.in", Payload: json.RawMessage(`{"f_name":"a.pdf"}`)},
+		{IdempotencyKey: "bad", Kind: "unknown", Channel: ChannelEmail, Recipient: "a@kmrl.in"},
+	})
+
+	if n := d.RunOnce(context.Background()); n != 1 {
+		t.Fatalf("expected 1 delivery, got %d", n)
+	}
+	got := store.Entries()
+	if got[0].Status != "sent" || got[1].Status != "dead" || got[1].Attempts != 1 {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
+
+func TestIdempotencyKeysDeduplicateEvents(t *testing.T) {
+	store := NewMemoryStore()
+	prefs := models.NotificationPreferences{UUID: "u1", EmailEnabled: true}
+	pending := []models.PendingNotification{{NotifID: "n1", UUID: "u1", Email: "a@kmrl.in", Active: true, FileName: "a.pdf", Prefs: prefs}}
+	src := Source{Name: "test", Collect: func(limit int) (int, error) { return store.Enqueue(newFileEntries(pending)) }}
+
+	sent := 0
+	d, _ := newTestDispatcher(store, Route(SMTPNotifier{Send: func(to, subject, text, html string) error { sent++; return nil }}), src)
+	d.RunOnce(context.Background())
+	d.RunOnce(context.Background())
+	if sent != 1 || len(store.Entries()) != 1 {
+		t.Fatalf("expected exactly one email, got %d (entries %d)", sent, len(store.Entries()))
+	}
+}
+
+func TestNewFileSummariesAreRedactedOrWithheld(t *testing.T) {
+	prefs := models.NotificationPreferences{UUID: "u1", EmailEnabled: true}
+	summary := "Contact R. Nair at 98470 12345 or r.nair@kmrl.co.in."
+	pending := []models.PendingNotification{
+		{NotifID: "n1", UUID: "u1", FUUID: "f1", Email: "a@kmrl.in", Active: true, FileName: "a.pdf", Summary: summary, Prefs: prefs},
+		{NotifID: "n2", UUID: "u1", FUUID: "f2", Email: "a@kmrl.in", Active: true, FileName: "b.pdf", Summary: summary,
+			Sensitivity: "confidential", Prefs: prefs},
+		{NotifID: "n3", UUID: "u1", FUUID: "f3", Email: "a@kmrl.in", Active: true, FileName: "c.pdf", Summary: summary,
+			Sensitivity: "restricted", Hidden: true, Prefs: prefs},
+	}
+	entries := newFileEntries(pending)
+	if len(entries) != 2 {
+		t.Fatalf("expected the hidden file to be skipped, got %d entries", len(entries))
+	}
+
+	msg, err := renderMessage(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.Text, "98470") || strings.Contains(msg.Text, "r.nair@") || !strings.Contains(msg.Text, "[phone redacted]") {
+		t.Fatalf("summary not redacted:\n%s", msg.Text)
+	}
+	msg, err = renderMessage(entries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.Text, "R. Nair") || !strings.Contains(msg.Text, "confidential") {
+		t.Fatalf("confidential summary should be withheld:\n%s", msg.Text)
+	}
+}
+
+func TestWorkspaceCommentsAreRedactedOrWithheld(t *testing.T) {
+	render := func(p WorkspaceEventPayload) string {
+		t.Helper()
+		payload, _ := json.Marshal(p)
+		msg, err := renderMessage(models.OutboxEntry{Kind: KindWorkspaceEvent, Channel: ChannelEmail, Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Text
+	}
+	p := WorkspaceEventPayload{WorkspaceName: "Phase 2", Event: "comment", Actor: "Ravi", FileName: "a.pdf", Comment: "Call 98470 12345"}
+	if text := render(p); strings.Contains(text, "98470") || !strings.Contains(text, "[phone redacted]") {
+		t.Fatalf("comment not redacted:\n%s", text)
+	}
+	p.Sensitivity = "restricted"
+	if text := render(p); strings.Contains(text, "Call") || !strings.Contains(text, "Ravi commented") {
+		t.Fatalf("comment on a restricted file should be withheld:\n%s", text)
+	}
+}
+
+func TestCommentMentionsAreRedactedOrWithheld(t *testing.T) {
+	p := CommentMentionPayload{FUUID: "f1", FileName: "a.pdf", Author: "Ravi", Comment: "@asha call 98470 12345", Page: 2}
+	render := func() string {
+		t.Helper()
+		payload, _ := json.Marshal(p)
+		msg, err := renderMessage(models.OutboxEntry{Kind: KindCommentMention, Channel: ChannelEmail, Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Text
+	}
+	if text := render(); strings.Contains(text, "98470") || !strings.Contains(text, "[phone redacted]") {
+		t.Fatalf("comment not redacted:\n%s", text)
+	}
+	p.Sensitivity = "confidential"
+	if text := render(); strings.Contains(text, "call") || !strings.Contains(text, "Ravi mentioned you") {
+		t.Fatalf("comment on a confidential file should be withheld:\n%s", text)
+	}
+}
+
+func TestWorkflowCommentsAreRedactedOrWithheld(t *testing.T) {
+	p := WorkflowEventPayload{TemplateName: "Purchase request", Event: "rejected", Step: "Finance", Actor: "Meera",
+		FUUID: "f1", FileName: "po.pdf", Comment: "Call 98470 12345 about the quote"}
+	render := func() string {
+		t.Helper()
+		payload, _ := json.Marshal(p)
+		msg, err := renderMessage(models.OutboxEntry{Kind: KindWorkflowEvent, Channel: ChannelEmail, Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Text
+	}
+	if text := render(); strings.Contains(text, "98470") || !strings.Contains(text, "Meera rejected po.pdf") {
+		t.Fatalf("comment not redacted:\n%s", text)
+	}
+	p.Sensitivity = "restricted"
+	if text := render(); strings.Contains(text, "quote") {
+		t.Fatalf("comment on a restricted file should be withheld:\n%s", text)
+	}
+}
+
+func emailOnly(uuid string) models.NotificationPreferences {
+	return models.NotificationPreferences{UUID: uuid, EmailEnabled: true}
+}
+
+func TestQuickShareEntries(t *testing.T) {
+	qs := models.PendingQuickShare{
+		QSUUID: "qs1", DUUID: "d1", Data: json.RawMessage(`{"timestamp":"t","message":"hi","priority":"high","zeta":1}`),
+		Members: []models.QuickShareMember{
+			{UUID: "r1", Email: "r1@kmrl.in", Position: "regular", Prefs: emailOnly("r1")},
+			{UUID: "h1", Email: "h1@kmrl.in", Position: "head", Prefs: emailOnly("h1")},
+			{UUID: "h2", Email: "", Position: "head", Prefs: emailOnly("h2")},
+		},
+	}
+	entries := quickShareEntries([]models.PendingQuickShare{qs})
+	if len(entries) != 1 || entries[0].RecipientUUID != "h1" || entries[0].IdempotencyKey != "quick_share:qs1:h1:email" {
+		t.Fatalf("expected only the head with an email, got %+v", entries)
+	}
+
+	qs.Members = qs.Members[:1]
+	entries = quickShareEntries([]models.PendingQuickShare{qs})
+	if len(entries) != 1 || entries[0].RecipientUUID != "r1" {
+		t.Fatalf("expected fallback to regular members, got %+v", entries)
+	}
+
+	// Quick shares created through the API arrive with their recipients
+	// already resolved and are not filtered again.
+	resolved := qs
+	resolved.Resolved = true
+	resolved.Members = []models.QuickShareMember{{UUID: "r1", Email: "r1@kmrl.in", Prefs: emailOnly("r1")}, {UUID: "r2", Email: "r2@kmrl.in", Prefs: emailOnly("r2")}}
+	if got := quickShareEntries([]models.PendingQuickShare{resolved}); len(got) != 2 {
+		t.Fatalf("expected both resolved recipients, got %+v", got)
+	}
+
+	msg, err := renderMessage(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg.Text, "hi\n\nPriority: high\nSent: t\nzeta: 1\n") || msg.Subject != "Quick Share [HIGH]" {
+		t.Fatalf("fields not in stable order:\n%s", msg.Text)
+	}
+}
+
+func TestFanOutFollowsPreferences(t *testing.T) {
+	prefs := models.NotificationPreferences{UUID: "u1", EmailEnabled: false, InAppEnabled: true, WebhookEnabled: true, WebhookURL: "https://chat.example/hook"}
+	entries := fanOut(KindNewFile, "new_file:n1", "a@kmrl.in", prefs, []byte(`{}`))
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.Channel] = e.Recipient
+		if e.IdempotencyKey != "new_file:n1:"+e.Channel {
+			t.Fatalf("unexpected key %s", e.IdempotencyKey)
+		}
+	}
+	want := map[string]string{ChannelWebhook: "https://chat.example/hook", ChannelInApp: "u1"}
+	if len(got) != len(want) || got[ChannelWebhook] != want[ChannelWebhook] || got[ChannelInApp] != want[ChannelInApp] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var gotSig, gotKey string
+	var body webhookBody
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig, gotKey = r.Header.Get("X-KMRL-Signature"), r.Header.Get("Idempotency-Key")
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Kind == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	n := WebhookNotifier{Client: srv.Client(), Secret: "s3cret"}
+	e := models.OutboxEntry{IdempotencyKey: "k", Kind: KindNewFile, Channel: ChannelWebhook, Recipient: srv.URL, Payload: json.RawMessage(`{"f_name":"a.pdf"}`)}
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(gotSig, "sha256=") || gotKey != "k" || !strings.Contains(body.Text, "a.pdf") {
+		t.Fatalf("unexpected request: sig=%q key=%q body=%+v", gotSig, gotKey, body)
+	}
+
+	for _, u := range []string{"ftp://x", "http://hooks.example.com/x"} {
+		if err := n.Notify(context.Background(), models.OutboxEntry{Kind: KindNewFile, Recipient: u}); !IsPermanent(err) {
+			t.Fatalf("expected permanent error for %s, got %v", u, err)
+		}
+	}
+}
+
+func TestWebhookRefusesInternalHostsAndRedirects(t *testing.T) {
+	hit := false
+	internal := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer internal.Close()
+	redirect := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+	e := models.OutboxEntry{Kind: KindNewFile, Payload: json.RawMessage(`{"f_name":"a.pdf"}`)}
+
+	// The default client refuses the loopback address before connecting.
+	e.Recipient = internal.URL
+	if err := (WebhookNotifier{}).Notify(context.Background(), e); !IsPermanent(err) || !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("expected blocked address, got %v", err)
+	}
+	e.Recipient = redirect.URL
+	if err := (WebhookNotifier{Client: redirect.Client()}).Notify(context.Background(), e); !IsPermanent(err) {
+		t.Fatalf("expected permanent error for redirect, got %v", err)
+	}
+	if hit {
+		t.Fatal("webhook reached the internal host")
+	}
+
+	for addr, want := range map[string]bool{
+		"8.8.8.8": true, "2606:4700::1111": true, "127.0.0.1": false, "::1": false, "10.1.2.3": false,
+		"192.168.0.10": false, "172.16.5.4": false, "169.254.169.254": false, "fe80::1": false,
+		"0.0.0.0": false, "::": false, "::ffff:127.0.0.1": false, "fd00::1": false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestWebhookPayloadIsScrubbed(t *testing.T) {
+	var raw []byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	n := WebhookNotifier{Client: srv.Client()}
+
+	send := func(kind string, p interface{}) string {
+		t.Helper()
+		payload, _ := json.Marshal(p)
+		if err := n.Notify(context.Background(), models.OutboxEntry{Kind: kind, Channel: ChannelWebhook, Recipient: srv.URL, Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+	body := send(KindSummaryReady, SummaryReadyPayload{FUUID: "f1", FileName: "a.pdf", Summary: "Tender value is 4.2 crore", Sensitivity: "confidential"})
+	if strings.Contains(body, "crore") {
+		t.Fatalf("confidential summary reached the webhook:\n%s", body)
+	}
+	body = send(KindSummaryReady, SummaryReadyPayload{FUUID: "f1", FileName: "a.pdf", Summary: "Call 98470 12345"})
+	if strings.Contains(body, "98470") || !strings.Contains(body, "Call") {
+		t.Fatalf("internal summary should be redacted, not withheld:\n%s", body)
+	}
+	body = send(KindWorkspaceEvent, WorkspaceEventPayload{WorkspaceName: "Phase 2", Event: "comment", Actor: "Ravi", FileName: "a.pdf",
+		Comment: "Budget cut to 3 crore", Sensitivity: "restricted"})
+	if strings.Contains(body, "crore") {
+		t.Fatalf("restricted comment reached the webhook:\n%s", body)
+	}
+}
@@ -0,0 +1,416 @@
+package notifications
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/access"
+	"backend/models"
+	"backend/quickshare"
+	"backend/redact"
+	"backend/templates"
+)
+
+// Kinds double as template names in the templates package.
+const (
+	KindNewFile          = "new_file"
+	KindQuickShare       = "quick_share"
+	KindSummaryReady     = "summary_ready"
+	KindDeadlineReminder = "deadline_reminder"
+	KindWorkspaceEvent   = "workspace_event"
+	KindCommentMention   = "comment_mention"
+	KindWorkflowEvent    = "workflow_event"
+	KindFileTrashed      = "file_trashed"
+)
+
+// NewFilePayload is stored with new_file entries.
+type NewFilePayload struct {
+	NotifID     string `json:"notif_id"`
+	FUUID       string `json:"f_uuid"`
+	FileName    string `json:"f_name"`
+	Departments string `json:"departments"`
+	Summary     string `json:"summary,omitempty"`
+	Sensitivity string `json:"sensitivity,omitempty"`
+}
+
+// QuickSharePayload is stored with quick_share entries. Data is the
+// quick_share.data column as written by the sender.
+type QuickSharePayload struct {
+	QSUUID     string          `json:"qs_uuid"`
+	DUUID      string          `json:"d_uuid"`
+	SenderUUID string          `json:"sender_uuid"`
+	SenderName string          `json:"sender_name,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// SummaryReadyPayload is stored with summary_ready entries.
+type SummaryReadyPayload struct {
+	FUUID       string `json:"f_uuid"`
+	FileName    string `json:"f_name"`
+	Summary     string `json:"summary"`
+	Sensitivity string `json:"sensitivity,omitempty"`
+}
+
+// DeadlineReminderPayload is stored with deadline_reminder entries.
+type DeadlineReminderPayload struct {
+	DeadlineID string    `json:"deadline_id"`
+	Title      string    `json:"title"`
+	DueAt      time.Time `json:"due_at"`
+	FUUID      string    `json:"f_uuid,omitempty"`
+	FileName   string    `json:"f_name,omitempty"`
+	Department string    `json:"department,omitempty"`
+}
+
+// WorkspaceEventPayload is stored with workspace_event entries. Event is
+// one of the workspaces.Event* kinds.
+type WorkspaceEventPayload struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceName string `json:"workspace_name"`
+	Event         string `json:"event"`
+	Actor         string `json:"actor"`
+	Department    string `json:"department,omitempty"`
+	FUUID         string `json:"f_uuid,omitempty"`
+	FileName      string `json:"f_name,omitempty"`
+	Sensitivity   string `json:"sensitivity,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+// CommentMentionPayload is stored with comment_mention entries. Page is 0
+// when the comment is not anchored to a page.
+type CommentMentionPayload struct {
+	CommentID   string `json:"comment_id"`
+	FUUID       string `json:"f_uuid"`
+	FileName    string `json:"f_name"`
+	Author      string `json:"author"`
+	Comment     string `json:"comment,omitempty"`
+	Page        int    `json:"page,omitempty"`
+	Sensitivity string `json:"sensitivity,omitempty"`
+}
+
+// WorkflowEventPayload is stored with workflow_event entries. Event is one
+// of the workflows.Event* kinds; Step names the step concerned.
+type WorkflowEventPayload struct {
+	WorkflowID   string     `json:"workflow_id"`
+	TemplateName string     `json:"template_name"`
+	Event        string     `json:"event"`
+	Step         string     `json:"step,omitempty"`
+	Actor        string     `json:"actor,omitempty"`
+	FUUID        string     `json:"f_uuid"`
+	FileName     string     `json:"f_name"`
+	Sensitivity  string     `json:"sensitivity,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+}
+
+// FileTrashedPayload is stored with file_trashed entries, sent to the
+// uploader when someone else moves their file to the trash.
+type FileTrashedPayload struct {
+	FUUID    string    `json:"f_uuid"`
+	FileName string    `json:"f_name"`
+	Actor    string    `json:"actor"`
+	PurgeAt  time.Time `json:"purge_at"`
+}
+
+// NotificationSource moves unsent notifications rows into the outbox.
+func NotificationSource(db *sql.DB) Source {
+	return Source{Name: "notifications", Collect: func(limit int) (int, error) {
+		return models.CollectUnsentNotifications(db, limit, newFileEntries)
+	}}
+}
+
+// QuickShareSource moves unsent quick_share rows into the outbox, one entry
+// per recipient.
+func QuickShareSource(db *sql.DB) Source {
+	return Source{Name: "quick_share", Collect: func(limit int) (int, error) {
+		return models.CollectUnsentQuickShares(db, limit, quickShareEntries)
+	}}
+}
+
+// fanOut creates one entry per channel the recipient has enabled. The
+// idempotency key is keyPrefix plus the channel. Digestible kinds skip email
+// for users on a daily or weekly digest; the digest covers them instead.
+func fanOut(kind, keyPrefix, email string, prefs models.NotificationPreferences, payload []byte) []models.OutboxEntry {
+	var out []models.OutboxEntry
+	add := func(channel, recipient string) {
+		out = append(out, models.OutboxEntry{
+			IdempotencyKey: keyPrefix + ":" + channel,
+			Kind:           kind,
+			Channel:        channel,
+			RecipientUUID:  prefs.UUID,
+			Recipient:      recipient,
+			Payload:        payload,
+		})
+	}
+	batched := digestible(kind) && prefs.DigestFrequency != "" && prefs.DigestFrequency != models.DigestImmediate
+	if prefs.EmailEnabled && email != "" && !batched {
+		add(ChannelEmail, email)
+	}
+	if prefs.WebhookEnabled && prefs.WebhookURL != "" {
+		add(ChannelWebhook, prefs.WebhookURL)
+	}
+	if prefs.InAppEnabled {
+		add(ChannelInApp, prefs.UUID)
+	}
+	return out
+}
+
+func newFileEntries(pending []models.PendingNotification) []models.OutboxEntry {
+	var out []models.OutboxEntry
+	for _, p := range pending {
+		if !p.Active || p.UUID == "" {
+			log.Printf("[NOTIF] Skipping notification %s: recipient %s is inactive or missing", p.NotifID, p.UUID)
+			continue
+		}
+		if p.Hidden {
+			log.Printf("[NOTIF] Skipping notification %s: recipient %s may not see %s file %s", p.NotifID, p.UUID, p.Sensitivity, p.FUUID)
+			continue
+		}
+		payload, _ := json.Marshal(NewFilePayload{
+			NotifID: p.NotifID, FUUID: p.FUUID, FileName: p.FileName, Departments: p.Departments, Summary: p.Summary,
+			Sensitivity: p.Sensitivity,
+		})
+		out = append(out, fanOut(KindNewFile, KindNewFile+":"+p.NotifID, p.Email, p.Prefs, payload)...)
+	}
+	return out
+}
+
+func quickShareEntries(pending []models.PendingQuickShare) []models.OutboxEntry {
+	var out []models.OutboxEntry
+	for _, qs := range pending {
+		recipients := qs.Members
+		if !qs.Resolved {
+			recipients = quickshare.ResolveRecipients(qs.Members)
+		}
+		if len(recipients) == 0 {
+			log.Printf("[QUICK_SHARE] No recipients found for d_uuid %s; qs_uuid %s will not be delivered", qs.DUUID, qs.QSUUID)
+			continue
+		}
+		payload, _ := json.Marshal(QuickSharePayload{
+			QSUUID: qs.QSUUID, DUUID: qs.DUUID, SenderUUID: qs.SenderUUID, SenderName: qs.SenderName, Data: qs.Data,
+		})
+		for _, r := range recipients {
+			key := fmt.Sprintf("%s:%s:%s", KindQuickShare, qs.QSUUID, r.UUID)
+			out = append(out, fanOut(KindQuickShare, key, r.Email, r.Prefs, payload)...)
+		}
+	}
+	return out
+}
+
+// EnqueueEvent queues an event for one user on every channel they have
+// enabled. key identifies the event; enqueueing the same key twice is a no-op.
+func EnqueueEvent(db *sql.DB, kind, key, recipientUUID string, payload interface{}) (int, error) {
+	r, err := models.GetNotificationRecipient(db, recipientUUID)
+	if err != nil {
+		return 0, err
+	}
+	if r == nil || !r.Active {
+		return 0, nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	return models.EnqueueOutboxEntries(db, fanOut(kind, kind+":"+key, r.Email, r.Prefs, body))
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+var renderer = templates.FromEnv()
+
+// SetRenderer replaces the template renderer (tests, custom template dirs).
+func SetRenderer(r *templates.Renderer) { renderer = r }
+
+type message = templates.Rendered
+
+// messageSummary is the summary text a message may carry, since messages
+// leave the system by email and webhook. Summaries of confidential and
+// restricted files are replaced by a pointer to the app; the rest have
+// phone numbers, Aadhaar-style IDs and email addresses masked.
+func messageSummary(sensitivity, summary string) string {
+	if summary == "" {
+		return ""
+	}
+	if access.Sensitive(sensitivity) {
+		return fmt.Sprintf("This document is %s; open it in the app to read the summary.", sensitivity)
+	}
+	return redact.PII(summary)
+}
+
+// quickShareField is a non-standard key from quick_share.data.
+type quickShareField struct {
+	Name  string
+	Value interface{}
+}
+
+type quickShareView struct {
+	Sender    string
+	Subject   string
+	Message   string
+	Priority  string
+	Timestamp string
+	Extra     []quickShareField
+}
+
+// renderMessage renders e with its kind's templates. Malformed payloads are
+// permanent failures; template errors are retried so a broken override can
+// be fixed without losing messages.
+func renderMessage(e models.OutboxEntry) (message, error) {
+	msg, _, err := renderScrubbed(e)
+	return msg, err
+}
+
+// renderScrubbed is renderMessage that also returns the payload as it may
+// leave the system: the data the templates saw, with summaries and
+// comments withheld or redacted. Webhooks and in-app pushes send it in
+// place of the stored payload.
+func renderScrubbed(e models.OutboxEntry) (message, json.RawMessage, error) {
+	var data interface{}
+	// payload is data unless the templates need a different view of it.
+	var payload interface{}
+	switch e.Kind {
+	case KindNewFile:
+		var p NewFilePayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad new_file payload: %w", err))
+		}
+		p.Summary = messageSummary(p.Sensitivity, p.Summary)
+		data = p
+
+	case KindQuickShare:
+		var p QuickSharePayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad quick_share payload: %w", err))
+		}
+		view, err := newQuickShareView(p)
+		if err != nil {
+			return message{}, nil, Permanent(err)
+		}
+		data, payload = view, p
+
+	case KindSummaryReady:
+		var p SummaryReadyPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad summary_ready payload: %w", err))
+		}
+		p.Summary = messageSummary(p.Sensitivity, p.Summary)
+		data = p
+
+	case KindDeadlineReminder:
+		var p DeadlineReminderPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad deadline_reminder payload: %w", err))
+		}
+		data = p
+
+	case KindWorkspaceEvent:
+		var p WorkspaceEventPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad workspace_event payload: %w", err))
+		}
+		// Comments about confidential and restricted files stay in the app.
+		if access.Sensitive(p.Sensitivity) {
+			p.Comment = ""
+		} else {
+			p.Comment = redact.PII(p.Comment)
+		}
+		data = p
+
+	case KindCommentMention:
+		var p CommentMentionPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad comment_mention payload: %w", err))
+		}
+		if access.Sensitive(p.Sensitivity) {
+			p.Comment = ""
+		} else {
+			p.Comment = redact.PII(p.Comment)
+		}
+		data = p
+
+	case KindWorkflowEvent:
+		var p WorkflowEventPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad workflow_event payload: %w", err))
+		}
+		if access.Sensitive(p.Sensitivity) {
+			p.Comment = ""
+		} else {
+			p.Comment = redact.PII(p.Comment)
+		}
+		data = p
+
+	case KindFileTrashed:
+		var p FileTrashedPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad file_trashed payload: %w", err))
+		}
+		data = p
+
+	case KindDigest:
+		var p DigestPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return message{}, nil, Permanent(fmt.Errorf("bad digest payload: %w", err))
+		}
+		for _, g := range p.Groups {
+			for i := range g.Files {
+				g.Files[i].Summary = messageSummary(g.Files[i].Sensitivity, g.Files[i].Summary)
+			}
+		}
+		data = p
+
+	default:
+		return message{}, nil, Permanent(fmt.Errorf("unknown notification kind %q", e.Kind))
+	}
+	msg, err := renderer.Render(e.Kind, data)
+	if err != nil {
+		return msg, nil, err
+	}
+	if payload == nil {
+		payload = data
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return msg, nil, Permanent(err)
+	}
+	return msg, raw, nil
+}
+
+// newQuickShareView pulls the well-known fields out of quick_share.data and
+// lists the rest alphabetically, so every message reads the same way.
+func newQuickShareView(p QuickSharePayload) (quickShareView, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(p.Data, &data); err != nil {
+		return quickShareView{}, fmt.Errorf("bad quick_share data: %w", err)
+	}
+	str := func(k string) string {
+		v, ok := data[k]
+		if !ok || v == nil {
+			return ""
+		}
+		delete(data, k)
+		return fmt.Sprint(v)
+	}
+	view := quickShareView{
+		Sender:    p.SenderName,
+		Subject:   str("subject"),
+		Message:   str("message"),
+		Priority:  strings.ToLower(str("priority")),
+		Timestamp: str("timestamp"),
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		view.Extra = append(view.Extra, quickShareField{Name: k, Value: data[k]})
+	}
+	return view, nil
+}
@@ -0,0 +1,237 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"backend/mail"
+	"backend/models"
+)
+
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInApp   = "in_app"
+)
+
+// Notifier delivers outbox entries on one channel.
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, e models.OutboxEntry) error
+}
+
+// Route builds a Deliverer that hands each entry to the notifier for its
+// channel. Entries for a channel nobody handles are dead on arrival.
+func Route(notifiers ...Notifier) Deliverer {
+	byChannel := map[string]Notifier{}
+	for _, n := range notifiers {
+		byChannel[n.Channel()] = n
+	}
+	return func(ctx context.Context, e models.OutboxEntry) error {
+		n, ok := byChannel[e.Channel]
+		if !ok {
+			return Permanent(fmt.Errorf("no notifier for channel %q", e.Channel))
+		}
+		return n.Notify(ctx, e)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Email
+// ---------------------------------------------------------------------------
+
+// SMTPNotifier renders entries as multipart/alternative email and hands them
+// to Send (utils.SendGmailMultipart in production).
+type SMTPNotifier struct {
+	Send func(to, subject, text, html string) error
+}
+
+func (SMTPNotifier) Channel() string { return ChannelEmail }
+
+func (n SMTPNotifier) Notify(ctx context.Context, e models.OutboxEntry) error {
+	if e.Recipient == "" {
+		return Permanent(errors.New("no recipient address"))
+	}
+	msg, err := renderMessage(e)
+	if err != nil {
+		return err
+	}
+	if err := n.Send(e.Recipient, msg.Subject, msg.Text, msg.HTML); err != nil {
+		if mail.IsPermanent(err) {
+			return Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Webhook
+// ---------------------------------------------------------------------------
+
+// WebhookNotifier POSTs a JSON body to the recipient's URL. The "text" field
+// makes it usable directly as a Slack/Teams/Google Chat incoming webhook.
+// When Secret is set the body is signed with HMAC-SHA256 in X-KMRL-Signature.
+// The payload is scrubbed like the email text, so summaries and comments of
+// confidential and restricted files never leave through a webhook.
+//
+// Webhook URLs are chosen by users, so the default client only connects to
+// public addresses, and redirects are never followed.
+type WebhookNotifier struct {
+	// Client replaces the default client (tests); redirects stay refused.
+	Client *http.Client
+	Secret string
+}
+
+// errBlockedAddress is returned when a webhook host resolves to an address
+// on the server's own network.
+var errBlockedAddress = errors.New("webhook host resolves to a non-public address")
+
+// webhookTransport checks each address after DNS resolution, so a name that
+// resolves to an internal host is caught as well as a literal IP.
+var webhookTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addr.Addr()) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}).DialContext,
+	TLSHandshakeTimeout: 5 * time.Second,
+	MaxIdleConns:        20,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// publicAddr rejects loopback, private, link-local, multicast and
+// unspecified addresses.
+func publicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	return a.IsValid() && !a.IsLoopback() && !a.IsPrivate() && !a.IsLinkLocalUnicast() &&
+		!a.IsLinkLocalMulticast() && !a.IsInterfaceLocalMulticast() && !a.IsMulticast() && !a.IsUnspecified()
+}
+
+// refuseRedirect hands a 3xx back as the response, which Notify rejects.
+func refuseRedirect(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+type webhookBody struct {
+	Kind    string          `json:"kind"`
+	Subject string          `json:"subject"`
+	Text    string          `json:"text"`
+	Payload json.RawMessage `json:"payload"`
+	SentAt  time.Time       `json:"sent_at"`
+}
+
+func (WebhookNotifier) Channel() string { return ChannelWebhook }
+
+func (n WebhookNotifier) Notify(ctx context.Context, e models.OutboxEntry) error {
+	if err := ValidateWebhookURL(e.Recipient); err != nil {
+		return Permanent(err)
+	}
+	msg, payload, err := renderScrubbed(e)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(webhookBody{
+		Kind: e.Kind, Subject: msg.Subject, Text: msg.Subject + "\n\n" + msg.Text, Payload: payload, SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Recipient, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", e.IdempotencyKey)
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set("X-KMRL-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := http.Client{Timeout: 10 * time.Second, Transport: webhookTransport}
+	if n.Client != nil {
+		client = *n.Client
+	}
+	client.CheckRedirect = refuseRedirect
+	resp, err := client.Do(req)
+	if errors.Is(err, errBlockedAddress) {
+		return Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode < 400:
+		return Permanent(fmt.Errorf("webhook redirected with %d; redirects are not followed", resp.StatusCode))
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	default:
+		return Permanent(fmt.Errorf("webhook returned %d", resp.StatusCode))
+	}
+}
+
+// ValidateWebhookURL accepts absolute https URLs only. Where the host
+// points is checked when it is dialled.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Scheme != "https" {
+		return fmt.Errorf("webhook URL must be an absolute https URL")
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// In-app (websocket push)
+// ---------------------------------------------------------------------------
+
+// InAppNotifier pushes entries to the recipient's open websocket connections.
+// Users who are offline still see the notification in the app's list, so an
+// undelivered push is not retried.
+type InAppNotifier struct {
+	Hub *Hub
+}
+
+type inAppEvent struct {
+	Kind    string          `json:"kind"`
+	Subject string          `json:"subject"`
+	Text    string          `json:"text"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (InAppNotifier) Channel() string { return ChannelInApp }
+
+func (n InAppNotifier) Notify(ctx context.Context, e models.OutboxEntry) error {
+	msg, payload, err := renderScrubbed(e)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(inAppEvent{Kind: e.Kind, Subject: msg.Subject, Text: msg.Text, Payload: payload})
+	if err != nil {
+		return Permanent(err)
+	}
+	n.Hub.Publish(e.RecipientUUID, body)
+	return nil
+}
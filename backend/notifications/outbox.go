@@ -0,0 +1,143 @@
+// Package notifications turns notification and quick share events into
+// outbox entries and delivers them with retries.
+package notifications
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// Store is the durable queue the Dispatcher drains.
+type Store interface {
+	// Enqueue adds entries, ignoring any whose idempotency key already exists.
+	Enqueue(entries []models.OutboxEntry) (int, error)
+	// Claim returns due entries, counting an attempt and hiding them until leaseUntil.
+	Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEntry, error)
+	MarkSent(id int64) error
+	MarkRetry(id int64, nextAttemptAt time.Time, lastError string) error
+	MarkDead(id int64, lastError string) error
+}
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (notification_outbox table)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func (s pgStore) Enqueue(entries []models.OutboxEntry) (int, error) {
+	return models.EnqueueOutboxEntries(s.db, entries)
+}
+
+func (s pgStore) Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEntry, error) {
+	return models.ClaimOutboxEntries(s.db, now, leaseUntil, limit)
+}
+
+func (s pgStore) MarkSent(id int64) error { return models.MarkOutboxSent(s.db, id) }
+
+func (s pgStore) MarkRetry(id int64, nextAttemptAt time.Time, lastError string) error {
+	return models.MarkOutboxRetry(s.db, id, nextAttemptAt, lastError)
+}
+
+func (s pgStore) MarkDead(id int64, lastError string) error {
+	return models.MarkOutboxDead(s.db, id, lastError)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store (tests and local development)
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	entries map[int64]*models.OutboxEntry
+	keys    map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[int64]*models.OutboxEntry{}, keys: map[string]int64{}}
+}
+
+func (m *MemoryStore) Enqueue(entries []models.OutboxEntry) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, e := range entries {
+		if _, dup := m.keys[e.IdempotencyKey]; dup {
+			continue
+		}
+		m.nextID++
+		e.ID = m.nextID
+		e.Status = "pending"
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+		if e.NextAttemptAt.IsZero() {
+			e.NextAttemptAt = e.CreatedAt
+		}
+		m.entries[e.ID] = &e
+		m.keys[e.IdempotencyKey] = e.ID
+		n++
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*models.OutboxEntry
+	for _, e := range m.entries {
+		if e.Status == "pending" && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	out := make([]models.OutboxEntry, 0, len(due))
+	for _, e := range due {
+		e.Attempts++
+		e.NextAttemptAt = leaseUntil
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) update(id int64, fn func(e *models.OutboxEntry)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[id]; ok {
+		fn(e)
+	}
+	return nil
+}
+
+func (m *MemoryStore) MarkSent(id int64) error {
+	return m.update(id, func(e *models.OutboxEntry) { e.Status, e.LastError = "sent", "" })
+}
+
+func (m *MemoryStore) MarkRetry(id int64, nextAttemptAt time.Time, lastError string) error {
+	return m.update(id, func(e *models.OutboxEntry) { e.NextAttemptAt, e.LastError = nextAttemptAt, lastError })
+}
+
+func (m *MemoryStore) MarkDead(id int64, lastError string) error {
+	return m.update(id, func(e *models.OutboxEntry) { e.Status, e.LastError = "dead", lastError })
+}
+
+// Entries returns a snapshot ordered by ID.
+func (m *MemoryStore) Entries() []models.OutboxEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]models.OutboxEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
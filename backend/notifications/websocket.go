@@ -0,0 +1,293 @@
+package notifications
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal RFC 6455 server side: text frames out, control frames in. Clients
+// never send data on this socket, so fragmented or large frames are refused.
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxFrame     = 64 << 10
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// Hub tracks open websocket connections per user.
+type Hub struct {
+	mu    sync.Mutex
+	conns map[string]map[*wsConn]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{conns: map[string]map[*wsConn]struct{}{}}
+}
+
+// Publish sends msg to every connection userID has open and returns how many
+// received it.
+func (h *Hub) Publish(userID string, msg []byte) int {
+	h.mu.Lock()
+	targets := make([]*wsConn, 0, len(h.conns[userID]))
+	for c := range h.conns[userID] {
+		targets = append(targets, c)
+	}
+	h.mu.Unlock()
+
+	sent := 0
+	for _, c := range targets {
+		if err := c.write(wsOpText, msg); err != nil {
+			c.close()
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// Connected reports how many connections userID has open.
+func (h *Hub) Connected(userID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns[userID])
+}
+
+// CloseAll sends a close frame to every connection (used on shutdown).
+func (h *Hub) CloseAll() {
+	h.mu.Lock()
+	var all []*wsConn
+	for _, set := range h.conns {
+		for c := range set {
+			all = append(all, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range all {
+		_ = c.write(wsOpClose, []byte{0x03, 0xE9}) // 1001 going away
+		c.close()
+	}
+}
+
+func (h *Hub) add(userID string, c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[userID] == nil {
+		h.conns[userID] = map[*wsConn]struct{}{}
+	}
+	h.conns[userID][c] = struct{}{}
+}
+
+func (h *Hub) remove(userID string, c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns[userID], c)
+	if len(h.conns[userID]) == 0 {
+		delete(h.conns, userID)
+	}
+}
+
+// Serve upgrades the request to a websocket for userID and blocks until the
+// connection closes. The caller must have authenticated the request.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, userID string) {
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, `{"error":"websocket upgrade required"}`, http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, `{"error":"unsupported websocket version"}`, http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, `{"error":"missing Sec-WebSocket-Key"}`, http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, `{"error":"websocket not supported"}`, http.StatusInternalServerError)
+		return
+	}
+	netConn, rw, err := hj.Hijack()
+	if err != nil {
+		log.Println("[WS] Hijack failed:", err)
+		return
+	}
+	// The server's read/write timeouts no longer apply to a hijacked conn.
+	_ = netConn.SetDeadline(time.Time{})
+
+	c := &wsConn{conn: netConn, rw: rw, done: make(chan struct{})}
+	if err := c.handshake(key); err != nil {
+		netConn.Close()
+		return
+	}
+
+	h.add(userID, c)
+	defer h.remove(userID, c)
+	go c.pingLoop()
+	c.readLoop()
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type wsConn struct {
+	conn      net.Conn
+	rw        *bufio.ReadWriter
+	wmu       sync.Mutex
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (c *wsConn) handshake(key string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	c.rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n")
+	return c.rw.Flush()
+}
+
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) pingLoop() {
+	t := time.NewTicker(wsPingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if err := c.write(wsOpPing, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop answers pings and close frames until the peer goes away. Missing
+// two ping intervals of traffic counts as a dead connection.
+func (c *wsConn) readLoop() {
+	defer c.close()
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(2*wsPingInterval + wsWriteTimeout))
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpClose:
+			_ = c.write(wsOpClose, payload)
+			return
+		case wsOpPing:
+			if err := c.write(wsOpPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+var errWSProtocol = errors.New("websocket protocol error")
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.rw, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	op := hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	// Clients must mask every frame (RFC 6455 §5.1).
+	if !masked || n > wsMaxFrame {
+		return 0, nil, errWSProtocol
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWSAcceptKey(t *testing.T) {
+	// Example from RFC 6455 §1.3.
+	if got := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %s", got)
+	}
+}
+
+func TestHubPublishesToConnectedUser(t *testing.T) {
+	hub := NewHub()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Serve(w, r, "u1")
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake failed: %v %v", resp, err)
+	}
+
+	for i := 0; hub.Connected("u1") == 0; i++ {
+		if i > 100 {
+			t.Fatal("connection never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := hub.Publish("u1", []byte(`{"kind":"new_file"}`)); n != 1 {
+		t.Fatalf("expected 1 delivery, got %d", n)
+	}
+	if n := hub.Publish("someone-else", []byte(`{}`)); n != 0 {
+		t.Fatalf("expected no delivery to other users, got %d", n)
+	}
+
+	hdr := make([]byte, 2)
+	if _, err := br.Read(hdr); err != nil || hdr[0] != 0x80|wsOpText {
+		t.Fatalf("expected text frame, got %x %v", hdr, err)
+	}
+	payload := make([]byte, hdr[1])
+	if _, err := br.Read(payload); err != nil || string(payload) != `{"kind":"new_file"}` {
+		t.Fatalf("unexpected payload %q %v", payload, err)
+	}
+}
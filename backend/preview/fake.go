@@ -0,0 +1,47 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeRenderer stands in for CommandRenderer in tests. Every file has
+// Pages pages; rendered "images" are short text naming the page and width.
+// Err, when set, is returned from every call.
+type FakeRenderer struct {
+	Pages int
+	Err   error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *FakeRenderer) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// Calls lists the renders so far, as "page@width".
+func (f *FakeRenderer) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *FakeRenderer) PageCount(ctx context.Context, path, kind string) (int, error) {
+	return f.Pages, f.Err
+}
+
+func (f *FakeRenderer) RenderPage(ctx context.Context, path, kind string, page, width int) ([]byte, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if page < 1 || page > f.Pages {
+		return nil, ErrNoPage
+	}
+	call := fmt.Sprintf("%d@%d", page, width)
+	f.record(call)
+	return []byte("page " + call), nil
+}
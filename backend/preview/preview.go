@@ -0,0 +1,304 @@
+// Package preview renders page-1 thumbnails and low-resolution page
+// previews for PDFs and images. The images are kept in storage next to the
+// original file; a record per file says which pages exist.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rendered image sizes, in pixels of width. Height follows the page.
+const (
+	ThumbnailWidth = 320
+	PreviewWidth   = 800
+)
+
+// RetryAfter is how long a failed rendering is left alone before a request
+// tries again.
+const RetryAfter = time.Hour
+
+// MaxPages is how many page previews are rendered while a file is
+// processed. Later pages are rendered the first time they are asked for.
+const MaxPages = 10
+
+// ContentType of every rendered image.
+const ContentType = "image/jpeg"
+
+// Kinds of source file that can be previewed.
+const (
+	KindPDF   = "pdf"
+	KindImage = "image"
+)
+
+var (
+	ErrUnsupported = errors.New("file type has no previews")
+	ErrNoPage      = errors.New("page does not exist")
+	// ErrFailed means rendering failed less than RetryAfter ago.
+	ErrFailed = errors.New("rendering failed recently")
+)
+
+// KindOf returns the preview kind for a file name, or "" when it cannot be
+// previewed.
+func KindOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return KindPDF
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return KindImage
+	}
+	return ""
+}
+
+// ThumbnailPath and PagePath place rendered images next to the original.
+func ThumbnailPath(filePath string) string {
+	return filePath + ".preview/thumbnail.jpg"
+}
+
+func PagePath(filePath string, page int) string {
+	return fmt.Sprintf("%s.preview/page-%d.jpg", filePath, page)
+}
+
+// SourcePath returns the original file an object returned by ThumbnailPath
+// or PagePath belongs to.
+func SourcePath(objectPath string) (string, bool) {
+	i := strings.LastIndex(objectPath, ".preview/")
+	if i < 0 {
+		return "", false
+	}
+	return objectPath[:i], true
+}
+
+// Renderer turns a local copy of a source file into JPEG images.
+// Implementations: CommandRenderer and FakeRenderer.
+type Renderer interface {
+	PageCount(ctx context.Context, path, kind string) (int, error)
+	// RenderPage renders 1-based page at the given width.
+	RenderPage(ctx context.Context, path, kind string, page, width int) ([]byte, error)
+}
+
+// Storage keeps rendered images.
+type Storage interface {
+	Put(path string, data []byte, contentType string) error
+}
+
+// Record is what has been rendered for a file. Error is set when rendering
+// failed, so requests do not retry it until RetryAfter has passed since
+// GeneratedAt.
+type Record struct {
+	FUUID        string    `json:"f_uuid"`
+	Kind         string    `json:"kind"`
+	PageCount    int       `json:"page_count"`
+	Pages        []int     `json:"pages"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	Error        string    `json:"error,omitempty"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+func (r Record) HasPage(page int) bool { return slices.Contains(r.Pages, page) }
+
+// Store persists records. Implementations: NewPostgresStore and
+// NewMemoryStore.
+type Store interface {
+	// Record returns nil when nothing has been rendered for the file.
+	Record(fuuid string) (*Record, error)
+	Save(r Record) error
+}
+
+// Fetch makes a local copy of the original file for on-demand rendering.
+// The returned func removes it.
+type Fetch func() (localPath string, cleanup func(), err error)
+
+type Service struct {
+	store    Store
+	storage  Storage
+	Renderer Renderer
+	Now      func() time.Time
+
+	// Rendering for a file is serialized so concurrent requests for a
+	// missing page render it once; different files render in parallel.
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+type fileLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func NewService(store Store, storage Storage, renderer Renderer) *Service {
+	return &Service{store: store, storage: storage, Renderer: renderer, Now: time.Now, locks: map[string]*fileLock{}}
+}
+
+// lock takes the rendering lock for fuuid; the returned func releases it.
+func (s *Service) lock(fuuid string) func() {
+	s.mu.Lock()
+	l := s.locks[fuuid]
+	if l == nil {
+		l = &fileLock{}
+		s.locks[fuuid] = l
+	}
+	l.waiters++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.waiters--; l.waiters == 0 {
+			delete(s.locks, fuuid)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Record returns what has been rendered for fuuid, or nil.
+func (s *Service) Record(fuuid string) (*Record, error) {
+	return s.store.Record(fuuid)
+}
+
+// Generate renders the thumbnail and the first MaxPages previews of
+// filePath from its local copy localPath, stores them, and records the
+// result. Failures are recorded as well as returned.
+func (s *Service) Generate(ctx context.Context, fuuid, filePath, localPath string) (Record, error) {
+	defer s.lock(fuuid)()
+	return s.generate(ctx, fuuid, filePath, localPath)
+}
+
+func (s *Service) generate(ctx context.Context, fuuid, filePath, localPath string) (Record, error) {
+	kind := KindOf(filePath)
+	if kind == "" {
+		return Record{}, ErrUnsupported
+	}
+	rec := Record{FUUID: fuuid, Kind: kind, Pages: []int{}, GeneratedAt: s.Now()}
+	err := func() error {
+		n, err := s.Renderer.PageCount(ctx, localPath, kind)
+		if err != nil {
+			return fmt.Errorf("count pages: %w", err)
+		}
+		rec.PageCount = n
+		if n == 0 {
+			return nil
+		}
+		thumb, err := s.Renderer.RenderPage(ctx, localPath, kind, 1, ThumbnailWidth)
+		if err != nil {
+			return fmt.Errorf("render thumbnail: %w", err)
+		}
+		if err := s.storage.Put(ThumbnailPath(filePath), thumb, ContentType); err != nil {
+			return fmt.Errorf("store thumbnail: %w", err)
+		}
+		rec.HasThumbnail = true
+		for p := 1; p <= min(n, MaxPages); p++ {
+			if err := s.renderPage(ctx, &rec, filePath, localPath, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if saveErr := s.store.Save(rec); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return rec, err
+}
+
+func (s *Service) renderPage(ctx context.Context, rec *Record, filePath, localPath string, page int) error {
+	img, err := s.Renderer.RenderPage(ctx, localPath, rec.Kind, page, PreviewWidth)
+	if err != nil {
+		return fmt.Errorf("render page %d: %w", page, err)
+	}
+	if err := s.storage.Put(PagePath(filePath, page), img, ContentType); err != nil {
+		return fmt.Errorf("store page %d: %w", page, err)
+	}
+	if !rec.HasPage(page) {
+		rec.Pages = append(rec.Pages, page)
+		slices.Sort(rec.Pages)
+	}
+	return nil
+}
+
+// Ensure returns the storage path of the thumbnail (page 0) or of a page
+// preview, rendering it first if it is missing. fetch is only called when
+// something has to be rendered. Images that exist are found without
+// waiting on a rendering in progress; a failed rendering returns ErrFailed
+// until RetryAfter has passed.
+func (s *Service) Ensure(ctx context.Context, fuuid, filePath string, page int, fetch Fetch) (string, error) {
+	if page < 0 {
+		return "", ErrNoPage
+	}
+	if KindOf(filePath) == "" {
+		return "", ErrUnsupported
+	}
+	want := func(rec *Record) (string, bool) {
+		if page == 0 && rec.HasThumbnail {
+			return ThumbnailPath(filePath), true
+		}
+		if page > 0 && rec.HasPage(page) {
+			return PagePath(filePath, page), true
+		}
+		return "", false
+	}
+
+	// check answers from the record alone when no rendering is needed.
+	check := func() (*Record, string, error) {
+		rec, err := s.store.Record(fuuid)
+		if err != nil || rec == nil {
+			return rec, "", err
+		}
+		if path, ok := want(rec); ok {
+			return rec, path, nil
+		}
+		if rec.Error != "" && s.Now().Before(rec.GeneratedAt.Add(RetryAfter)) {
+			return rec, "", ErrFailed
+		}
+		if rec.Error == "" && page > rec.PageCount {
+			return rec, "", ErrNoPage
+		}
+		return rec, "", nil
+	}
+	if _, path, err := check(); path != "" || err != nil {
+		return path, err
+	}
+
+	defer s.lock(fuuid)()
+	// Another request may have rendered it while this one waited.
+	rec, path, err := check()
+	if path != "" || err != nil {
+		return path, err
+	}
+
+	localPath, cleanup, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if rec == nil || rec.Error != "" || !rec.HasThumbnail {
+		fresh, err := s.generate(ctx, fuuid, filePath, localPath)
+		if err != nil {
+			return "", err
+		}
+		rec = &fresh
+		if path, ok := want(rec); ok {
+			return path, nil
+		}
+	}
+	if page == 0 || page > rec.PageCount {
+		return "", ErrNoPage
+	}
+	if err := s.renderPage(ctx, rec, filePath, localPath, page); err != nil {
+		return "", err
+	}
+	if err := s.store.Save(*rec); err != nil {
+		return "", err
+	}
+	return PagePath(filePath, page), nil
+}
@@ -0,0 +1,225 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const path = "uploads/report.pdf"
+
+func newTestService(pages int) (*Service, *MemoryStorage, *FakeRenderer) {
+	storage := NewMemoryStorage()
+	renderer := &FakeRenderer{Pages: pages}
+	return NewService(NewMemoryStore(), storage, renderer), storage, renderer
+}
+
+// fetchCounter returns a Fetch that counts how often the original had to be
+// downloaded.
+func fetchCounter(n *int) Fetch {
+	return func() (string, func(), error) {
+		*n++
+		return "/tmp/report.pdf", func() {}, nil
+	}
+}
+
+func TestGenerateRendersThumbnailAndFirstPages(t *testing.T) {
+	svc, storage, renderer := newTestService(MaxPages + 2)
+	rec, err := svc.Generate(context.Background(), "f1", path, "/tmp/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.HasThumbnail || rec.PageCount != MaxPages+2 || len(rec.Pages) != MaxPages {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if got, ok := storage.Get(ThumbnailPath(path)); !ok || string(got) != "page 1@320" {
+		t.Fatalf("thumbnail = %q, %v", got, ok)
+	}
+	if got, _ := storage.Get(PagePath(path, 3)); string(got) != "page 3@800" {
+		t.Fatalf("page 3 = %q", got)
+	}
+	if _, ok := storage.Get(PagePath(path, MaxPages+1)); ok {
+		t.Fatal("pages past MaxPages should not be rendered up front")
+	}
+	if n := len(renderer.Calls()); n != MaxPages+1 {
+		t.Fatalf("expected %d renders, got %d", MaxPages+1, n)
+	}
+}
+
+func TestEnsureRendersMissingPagesOnDemand(t *testing.T) {
+	svc, storage, _ := newTestService(MaxPages + 2)
+	ctx := context.Background()
+	fetches := 0
+
+	// Nothing rendered yet: the first request generates everything.
+	got, err := svc.Ensure(ctx, "f1", path, 0, fetchCounter(&fetches))
+	if err != nil || got != ThumbnailPath(path) || fetches != 1 {
+		t.Fatalf("thumbnail: %q, %v, fetches=%d", got, err, fetches)
+	}
+	// Already rendered: no download.
+	if got, err = svc.Ensure(ctx, "f1", path, 2, fetchCounter(&fetches)); err != nil || got != PagePath(path, 2) || fetches != 1 {
+		t.Fatalf("page 2: %q, %v, fetches=%d", got, err, fetches)
+	}
+	// Past MaxPages: rendered and remembered.
+	last := MaxPages + 2
+	if got, err = svc.Ensure(ctx, "f1", path, last, fetchCounter(&fetches)); err != nil || got != PagePath(path, last) || fetches != 2 {
+		t.Fatalf("page %d: %q, %v, fetches=%d", last, got, err, fetches)
+	}
+	if _, ok := storage.Get(PagePath(path, last)); !ok {
+		t.Fatal("on-demand page was not stored")
+	}
+	rec, _ := svc.Record("f1")
+	if !slices.Contains(rec.Pages, last) {
+		t.Fatalf("record does not list page %d: %v", last, rec.Pages)
+	}
+	// Out of range: refused without a download.
+	if _, err = svc.Ensure(ctx, "f1", path, last+1, fetchCounter(&fetches)); !errors.Is(err, ErrNoPage) || fetches != 2 {
+		t.Fatalf("page %d: %v, fetches=%d", last+1, err, fetches)
+	}
+}
+
+func TestEnsureUnsupportedAndFailures(t *testing.T) {
+	svc, _, renderer := newTestService(1)
+	ctx := context.Background()
+	fetches := 0
+	if _, err := svc.Ensure(ctx, "f1", "notes.docx", 0, fetchCounter(&fetches)); !errors.Is(err, ErrUnsupported) || fetches != 0 {
+		t.Fatalf("docx: %v, fetches=%d", err, fetches)
+	}
+
+	renderer.Err = errors.New("renderer crashed")
+	if _, err := svc.Ensure(ctx, "f1", path, 0, fetchCounter(&fetches)); err == nil {
+		t.Fatal("expected the render failure")
+	}
+	rec, _ := svc.Record("f1")
+	if rec == nil || rec.Error == "" || rec.HasThumbnail {
+		t.Fatalf("failure should be recorded: %+v", rec)
+	}
+
+	// Requests within RetryAfter do not render again.
+	renderer.Err = nil
+	calls := len(renderer.Calls())
+	if _, err := svc.Ensure(ctx, "f1", path, 1, fetchCounter(&fetches)); !errors.Is(err, ErrFailed) || fetches != 1 || len(renderer.Calls()) != calls {
+		t.Fatalf("expected ErrFailed without a render, got %v, fetches=%d", err, fetches)
+	}
+
+	// A later request retries.
+	svc.Now = func() time.Time { return rec.GeneratedAt.Add(RetryAfter) }
+	if got, err := svc.Ensure(ctx, "f1", path, 1, fetchCounter(&fetches)); err != nil || got != PagePath(path, 1) {
+		t.Fatalf("retry: %q, %v", got, err)
+	}
+	if rec, _ = svc.Record("f1"); rec.Error != "" {
+		t.Fatalf("error should be cleared: %+v", rec)
+	}
+}
+
+func TestEnsureLocksPerFile(t *testing.T) {
+	svc, _, _ := newTestService(2)
+	ctx := context.Background()
+	fetches := 0
+	if _, err := svc.Ensure(ctx, "f1", path, 0, fetchCounter(&fetches)); err != nil {
+		t.Fatal(err)
+	}
+
+	// While f1 is being rendered, its existing images and other files are
+	// still served.
+	unlock := svc.lock("f1")
+	done := make(chan error, 2)
+	go func() {
+		_, err := svc.Ensure(ctx, "f1", path, 2, fetchCounter(new(int)))
+		done <- err
+	}()
+	go func() {
+		_, err := svc.Ensure(ctx, "f2", "uploads/other.pdf", 0, fetchCounter(new(int)))
+		done <- err
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("request waited on another file's rendering")
+		}
+	}
+	unlock()
+	if len(svc.locks) != 0 {
+		t.Fatalf("locks were not released: %v", svc.locks)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	cases := map[string]string{
+		"a/b/Report.PDF": KindPDF,
+		"scan.jpeg":      KindImage,
+		"scan.png":       KindImage,
+		"notes.docx":     "",
+		"README":         "",
+	}
+	for name, want := range cases {
+		if got := KindOf(name); got != want {
+			t.Errorf("KindOf(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRenderImageRefusesHugeCanvas(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Declare a 65535x65535 logical screen in the GIF header.
+	data := buf.Bytes()
+	copy(data[6:10], []byte{0xff, 0xff, 0xff, 0xff})
+	p := filepath.Join(t.TempDir(), "huge.gif")
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renderImage(p, ThumbnailWidth); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestScaleToWidth(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		src.Set(0, y, color.White)
+		src.Set(1, y, color.Black)
+		src.Set(2, y, color.White)
+		src.Set(3, y, color.White)
+	}
+	out := scaleToWidth(src, 2)
+	if b := out.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("bounds = %v", b)
+	}
+	r, _, _, _ := out.At(0, 0).RGBA()
+	if r < 0x7000 || r > 0x9000 {
+		t.Fatalf("left pixel should be mid grey, got %#x", r)
+	}
+	if r, _, _, _ := out.At(1, 0).RGBA(); r != 0xffff {
+		t.Fatalf("right pixel should be white, got %#x", r)
+	}
+	if scaleToWidth(src, 10) != image.Image(src) {
+		t.Fatal("narrow images should not be scaled up")
+	}
+}
+
+func TestSourcePath(t *testing.T) {
+	path := "Operations/20250301_plan.pdf"
+	for _, p := range []string{ThumbnailPath(path), PagePath(path, 4)} {
+		if got, ok := SourcePath(p); !ok || got != path {
+			t.Fatalf("SourcePath(%q) = %q, %v", p, got, ok)
+		}
+	}
+	if _, ok := SourcePath(path); ok {
+		t.Fatal("an original file is not a preview")
+	}
+}
@@ -0,0 +1,172 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// jpegQuality is low on purpose: previews are for recognising a page, the
+// download endpoint serves the real thing.
+const jpegQuality = 70
+
+// MaxImagePixels bounds the images that are decoded: a small file can
+// declare a huge canvas, and decoding allocates all of it.
+const MaxImagePixels = 50_000_000
+
+var ErrTooLarge = errors.New("image is too large to preview")
+
+// CommandRenderer renders PDFs with poppler's pdftoppm and pdfinfo, and
+// scales images in-process.
+type CommandRenderer struct {
+	PDFToPPM string
+	PDFInfo  string
+	Timeout  time.Duration
+}
+
+// NewCommandRenderer finds the poppler tools on PATH unless PDFTOPPM_PATH
+// or PDFINFO_PATH say otherwise.
+func NewCommandRenderer() *CommandRenderer {
+	r := &CommandRenderer{PDFToPPM: "pdftoppm", PDFInfo: "pdfinfo", Timeout: 60 * time.Second}
+	if p := os.Getenv("PDFTOPPM_PATH"); p != "" {
+		r.PDFToPPM = p
+	}
+	if p := os.Getenv("PDFINFO_PATH"); p != "" {
+		r.PDFInfo = p
+	}
+	return r
+}
+
+var pagesLine = regexp.MustCompile(`(?m)^Pages:\s+(\d+)`)
+
+func (r *CommandRenderer) PageCount(ctx context.Context, path, kind string) (int, error) {
+	if kind == KindImage {
+		return 1, nil
+	}
+	if kind != KindPDF {
+		return 0, ErrUnsupported
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, r.PDFInfo, path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", r.PDFInfo, err)
+	}
+	m := pagesLine.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("%s printed no page count", r.PDFInfo)
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+func (r *CommandRenderer) RenderPage(ctx context.Context, path, kind string, page, width int) ([]byte, error) {
+	switch kind {
+	case KindImage:
+		if page != 1 {
+			return nil, ErrNoPage
+		}
+		return renderImage(path, width)
+	case KindPDF:
+		return r.renderPDF(ctx, path, page, width)
+	}
+	return nil, ErrUnsupported
+}
+
+func (r *CommandRenderer) renderPDF(ctx context.Context, path string, page, width int) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "preview-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	p := strconv.Itoa(page)
+	root := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, r.PDFToPPM,
+		"-f", p, "-l", p, "-singlefile",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1",
+		"-jpeg", "-jpegopt", "quality="+strconv.Itoa(jpegQuality),
+		path, root)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", r.PDFToPPM, err, bytes.TrimSpace(out))
+	}
+	return os.ReadFile(root + ".jpg")
+}
+
+func renderImage(path string, width int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, fmt.Errorf("%w: image is %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	// JPEG has no alpha, so transparent areas are laid on white.
+	scaled := scaleToWidth(img, width)
+	flat := image.NewRGBA(scaled.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), scaled, scaled.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleToWidth shrinks img to width, keeping its aspect ratio, by averaging
+// the source pixels under each target pixel. Images already narrower are
+// returned as they are.
+func scaleToWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if width <= 0 || b.Dx() <= width {
+		return img
+	}
+	height := max(1, b.Dy()*width/b.Dx())
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/width)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			out.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package preview
+
+import (
+	"database/sql"
+	"slices"
+	"sync"
+
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (file_previews)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func (s pgStore) Record(fuuid string) (*Record, error) {
+	row, err := models.GetFilePreview(s.db, fuuid)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return &Record{
+		FUUID: row.FUUID, Kind: row.Kind, PageCount: row.PageCount, Pages: row.Pages,
+		HasThumbnail: row.HasThumbnail, Error: row.Error, GeneratedAt: row.GeneratedAt,
+	}, nil
+}
+
+func (s pgStore) Save(r Record) error {
+	return models.SaveFilePreview(s.db, models.FilePreview{
+		FUUID: r.FUUID, Kind: r.Kind, PageCount: r.PageCount, Pages: r.Pages,
+		HasThumbnail: r.HasThumbnail, Error: r.Error,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store and storage, for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (m *MemoryStore) Record(fuuid string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	r.Pages = slices.Clone(r.Pages)
+	return &r, nil
+}
+
+func (m *MemoryStore) Save(r Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.Pages = slices.Clone(r.Pages)
+	m.records[r.FUUID] = r
+	return nil
+}
+
+// MemoryStorage keeps rendered images in a map by path.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	Objects map[string][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{Objects: map[string][]byte{}}
+}
+
+func (m *MemoryStorage) Put(path string, data []byte, contentType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Objects[path] = slices.Clone(data)
+	return nil
+}
+
+func (m *MemoryStorage) Get(path string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.Objects[path]
+	return data, ok
+}
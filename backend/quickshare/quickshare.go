@@ -0,0 +1,114 @@
+// Package quickshare implements quick shares: short typed messages sent to
+// one or more departments, with optional file attachments, threaded
+// replies and per-recipient read receipts.
+package quickshare
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+
+	MaxSubjectLen  = 200
+	MaxBodyLen     = 10000
+	MaxAttachments = 10
+	MaxDepartments = 20
+)
+
+var (
+	ErrNotFound     = errors.New("quick share not found")
+	ErrForbidden    = errors.New("not a participant of this quick share")
+	ErrNoRecipients = errors.New("no active recipients in the target departments")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Member is an active user as seen by recipient resolution.
+type Member struct {
+	UUID     string
+	Name     string
+	DUUID    string
+	Position string
+}
+
+// Receipt is one recipient of a message and when they read it.
+type Receipt struct {
+	UUID   string     `json:"uuid"`
+	Name   string     `json:"name,omitempty"`
+	DUUID  string     `json:"d_uuid,omitempty"`
+	ReadAt *time.Time `json:"read_at"`
+}
+
+// Message is one quick share or reply. ThreadID is the ID of the first
+// message in the thread (its own ID for a new quick share).
+type Message struct {
+	ID          string    `json:"qs_uuid"`
+	ThreadID    string    `json:"thread_id"`
+	ParentID    string    `json:"parent_id,omitempty"`
+	SenderUUID  string    `json:"sender_uuid"`
+	SenderName  string    `json:"sender_name,omitempty"`
+	SenderDUUID string    `json:"sender_d_uuid,omitempty"`
+	Subject     string    `json:"subject"`
+	Body        string    `json:"body"`
+	Priority    string    `json:"priority"`
+	Attachments []string  `json:"attachments"`
+	Departments []string  `json:"departments"`
+	Recipients  []Receipt `json:"recipients,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// ReadAt is the viewer's own receipt, when they are a recipient.
+	ReadAt *time.Time `json:"read_at,omitempty"`
+	// ReplyCount is filled in listings.
+	ReplyCount int `json:"reply_count"`
+}
+
+// Draft is what a client submits to create a quick share.
+type Draft struct {
+	Subject     string   `json:"subject"`
+	Body        string   `json:"body"`
+	Priority    string   `json:"priority"`
+	Attachments []string `json:"attachments"`
+	Departments []string `json:"departments"`
+}
+
+// Reply is what a client submits to answer a quick share.
+type Reply struct {
+	Body        string   `json:"body"`
+	Attachments []string `json:"attachments"`
+}
+
+// Box selects which messages List returns.
+const (
+	BoxInbox = "inbox"
+	BoxSent  = "sent"
+)
+
+func (m Member) IsHead() bool { return m.Position == "head" }
+
+// ResolveRecipients picks who in one department receives a quick share: the
+// department heads, or every member when the department has no head.
+func ResolveRecipients[M interface{ IsHead() bool }](members []M) []M {
+	var heads, regular []M
+	for _, m := range members {
+		if m.IsHead() {
+			heads = append(heads, m)
+		} else {
+			regular = append(regular, m)
+		}
+	}
+	if len(heads) > 0 {
+		return heads
+	}
+	return regular
+}
@@ -0,0 +1,358 @@
+package quickshare
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Store persists quick shares. Implementations: NewPostgresStore and
+// NewMemoryStore.
+type Store interface {
+	// User returns an active user, or nil when missing or deactivated.
+	User(uuid string) (*Member, error)
+	// Members returns the active users of the given departments.
+	Members(dUUIDs []string) ([]Member, error)
+	// MissingDepartments returns the IDs that are not departments.
+	MissingDepartments(dUUIDs []string) ([]string, error)
+	// InaccessibleFiles returns the f_uuids user may not see under
+	// access.Check.
+	InaccessibleFiles(user Member, fuuids []string) ([]string, error)
+	// Insert stores m with its Recipients and assigns ID, ThreadID (when
+	// empty) and CreatedAt.
+	Insert(m Message) (Message, error)
+	Get(id string) (*Message, error)
+	// Thread returns every message in a thread, oldest first.
+	Thread(threadID string) ([]Message, error)
+	// List returns messages user received (inbox) or sent, newest first,
+	// created before before.
+	List(user Member, box string, before time.Time, limit int) ([]Message, error)
+	// MarkRead sets the user's receipt once; false means they are not a
+	// recipient of the message.
+	MarkRead(id, userID string, at time.Time) (bool, error)
+}
+
+type Service struct {
+	store Store
+	Now   func() time.Time
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store, Now: time.Now}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// normalizeIDs trims, de-duplicates and checks a list of UUIDs.
+func normalizeIDs(ids []string, max int, field string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, id := range ids {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" || seen[id] {
+			continue
+		}
+		if !uuidPattern.MatchString(id) {
+			return nil, invalid("%s contains an invalid id %q", field, id)
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	if len(out) > max {
+		return nil, invalid("at most %d %s allowed", max, field)
+	}
+	return out, nil
+}
+
+func checkBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", invalid("body is required")
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLen {
+		return "", invalid("body must be at most %d characters", MaxBodyLen)
+	}
+	return body, nil
+}
+
+func (s *Service) sender(userID string) (Member, error) {
+	u, err := s.store.User(userID)
+	if err != nil {
+		return Member{}, err
+	}
+	if u == nil {
+		return Member{}, ErrForbidden
+	}
+	return *u, nil
+}
+
+func (s *Service) checkAttachments(sender Member, ids []string) ([]string, error) {
+	ids, err := normalizeIDs(ids, MaxAttachments, "attachments")
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	missing, err := s.store.InaccessibleFiles(sender, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, invalid("attachments not found or not accessible: %s", strings.Join(missing, ", "))
+	}
+	return ids, nil
+}
+
+// Create validates d and sends it to the heads of each target department
+// (all members where a department has no head). The sender is never a
+// recipient of their own message.
+func (s *Service) Create(senderID string, d Draft) (Message, error) {
+	sender, err := s.sender(senderID)
+	if err != nil {
+		return Message{}, err
+	}
+	subject := strings.TrimSpace(d.Subject)
+	if subject == "" {
+		return Message{}, invalid("subject is required")
+	}
+	if utf8.RuneCountInString(subject) > MaxSubjectLen {
+		return Message{}, invalid("subject must be at most %d characters", MaxSubjectLen)
+	}
+	body, err := checkBody(d.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	priority := strings.ToLower(strings.TrimSpace(d.Priority))
+	switch priority {
+	case "":
+		priority = PriorityNormal
+	case PriorityLow, PriorityNormal, PriorityHigh:
+	default:
+		return Message{}, invalid("priority must be low, normal or high")
+	}
+	depts, err := normalizeIDs(d.Departments, MaxDepartments, "departments")
+	if err != nil {
+		return Message{}, err
+	}
+	if len(depts) == 0 {
+		return Message{}, invalid("at least one target department is required")
+	}
+	if missing, err := s.store.MissingDepartments(depts); err != nil {
+		return Message{}, err
+	} else if len(missing) > 0 {
+		return Message{}, invalid("unknown departments: %s", strings.Join(missing, ", "))
+	}
+	attachments, err := s.checkAttachments(sender, d.Attachments)
+	if err != nil {
+		return Message{}, err
+	}
+
+	members, err := s.store.Members(depts)
+	if err != nil {
+		return Message{}, err
+	}
+	byDept := map[string][]Member{}
+	for _, m := range members {
+		if m.UUID != sender.UUID {
+			byDept[m.DUUID] = append(byDept[m.DUUID], m)
+		}
+	}
+	var recipients []Receipt
+	for _, dept := range depts {
+		for _, m := range ResolveRecipients(byDept[dept]) {
+			recipients = append(recipients, Receipt{UUID: m.UUID, Name: m.Name, DUUID: m.DUUID})
+		}
+	}
+	if len(recipients) == 0 {
+		return Message{}, ErrNoRecipients
+	}
+
+	return s.store.Insert(Message{
+		SenderUUID: sender.UUID, SenderName: sender.Name, SenderDUUID: sender.DUUID,
+		Subject: subject, Body: body, Priority: priority,
+		Attachments: attachments, Departments: depts, Recipients: recipients,
+		CreatedAt: s.Now(),
+	})
+}
+
+// Reply answers parentID. It goes to everyone in the thread (senders and
+// recipients so far) except the person replying.
+func (s *Service) Reply(senderID, parentID string, r Reply) (Message, error) {
+	sender, err := s.sender(senderID)
+	if err != nil {
+		return Message{}, err
+	}
+	parent, err := s.store.Get(parentID)
+	if err != nil {
+		return Message{}, err
+	}
+	if parent == nil {
+		return Message{}, ErrNotFound
+	}
+	thread, err := s.store.Thread(parent.ThreadID)
+	if err != nil {
+		return Message{}, err
+	}
+	if !isParticipant(thread, sender) {
+		return Message{}, ErrForbidden
+	}
+	body, err := checkBody(r.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	attachments, err := s.checkAttachments(sender, r.Attachments)
+	if err != nil {
+		return Message{}, err
+	}
+
+	recipients := threadAudience(thread, sender.UUID)
+	if len(recipients) == 0 {
+		return Message{}, ErrNoRecipients
+	}
+	root := thread[0]
+	return s.store.Insert(Message{
+		ThreadID: root.ID, ParentID: parent.ID,
+		SenderUUID: sender.UUID, SenderName: sender.Name, SenderDUUID: sender.DUUID,
+		Subject: replySubject(root.Subject), Body: body, Priority: root.Priority,
+		Attachments: attachments, Departments: root.Departments, Recipients: recipients,
+		CreatedAt: s.Now(),
+	})
+}
+
+func replySubject(subject string) string {
+	if subject == "" || strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// isParticipant reports whether user sent or received any message in the
+// thread. Quick shares created by older clients have no recipient rows, so
+// for those anyone in a target department counts.
+func isParticipant(thread []Message, user Member) bool {
+	for _, m := range thread {
+		if m.SenderUUID == user.UUID {
+			return true
+		}
+		for _, r := range m.Recipients {
+			if r.UUID == user.UUID {
+				return true
+			}
+		}
+	}
+	if len(thread) > 0 && len(thread[0].Recipients) == 0 && user.DUUID != "" {
+		for _, d := range thread[0].Departments {
+			if d == user.DUUID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// threadAudience is everyone who sent or received a message in the thread,
+// minus exclude, in first-seen order.
+func threadAudience(thread []Message, exclude string) []Receipt {
+	seen := map[string]bool{exclude: true}
+	var out []Receipt
+	add := func(uuid, name, dUUID string) {
+		if uuid == "" || seen[uuid] {
+			return
+		}
+		seen[uuid] = true
+		out = append(out, Receipt{UUID: uuid, Name: name, DUUID: dUUID})
+	}
+	for _, m := range thread {
+		add(m.SenderUUID, m.SenderName, m.SenderDUUID)
+		for _, r := range m.Recipients {
+			add(r.UUID, r.Name, r.DUUID)
+		}
+	}
+	return out
+}
+
+// Thread returns the thread containing id. Read receipts are only shown to
+// the sender of each message; everyone sees their own.
+func (s *Service) Thread(userID, id string) ([]Message, error) {
+	user, err := s.sender(userID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNotFound
+	}
+	thread, err := s.store.Thread(m.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant(thread, user) {
+		return nil, ErrForbidden
+	}
+	for i := range thread {
+		viewFor(&thread[i], user.UUID)
+	}
+	return thread, nil
+}
+
+func viewFor(m *Message, userID string) {
+	for _, r := range m.Recipients {
+		if r.UUID == userID {
+			m.ReadAt = r.ReadAt
+		}
+	}
+	if m.SenderUUID != userID {
+		m.Recipients = nil
+	}
+}
+
+// List returns the user's inbox or sent messages, newest first. before is a
+// cursor (the created_at of the last item of the previous page).
+func (s *Service) List(userID, box string, before time.Time, limit int) ([]Message, error) {
+	user, err := s.sender(userID)
+	if err != nil {
+		return nil, err
+	}
+	if box == "" {
+		box = BoxInbox
+	}
+	if box != BoxInbox && box != BoxSent {
+		return nil, invalid("box must be inbox or sent")
+	}
+	limit = PageSize(limit)
+	if before.IsZero() {
+		before = s.Now().Add(time.Minute)
+	}
+	list, err := s.store.List(user, box, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		viewFor(&list[i], user.UUID)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// PageSize clamps a requested page size to 1..100, defaulting to 20.
+func PageSize(limit int) int {
+	if limit <= 0 || limit > 100 {
+		return 20
+	}
+	return limit
+}
+
+// MarkRead records that userID has read id. Marking twice keeps the first time.
+func (s *Service) MarkRead(userID, id string) error {
+	ok, err := s.store.MarkRead(id, userID, s.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package quickshare
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+const (
+	deptOps     = "11111111-1111-1111-1111-111111111111"
+	deptFinance = "22222222-2222-2222-2222-222222222222"
+	deptLegal   = "33333333-3333-3333-3333-333333333333"
+	fileA       = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	fileB       = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+)
+
+func newTestService() (*Service, *MemoryStore) {
+	store := NewMemoryStore()
+	store.AddUser(Member{UUID: "sender", Name: "Asha", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "ops-head", Name: "Ravi", DUUID: deptOps, Position: "head"})
+	store.AddUser(Member{UUID: "fin-head", Name: "Meera", DUUID: deptFinance, Position: "head"})
+	store.AddUser(Member{UUID: "fin-clerk", Name: "Joseph", DUUID: deptFinance, Position: "regular"})
+	store.AddUser(Member{UUID: "legal-1", Name: "Anu", DUUID: deptLegal, Position: "regular"})
+	store.AddUser(Member{UUID: "legal-2", Name: "Biju", DUUID: deptLegal, Position: "regular"})
+	store.AddFile(access.File{FUUID: fileA, OwnerUUID: "sender"})
+	store.AddFile(access.File{FUUID: fileB, OwnerUUID: "fin-clerk", Sensitivity: access.Confidential, Departments: []string{deptFinance}})
+
+	svc := NewService(store)
+	t0 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.Now = func() time.Time { t0 = t0.Add(time.Minute); return t0 }
+	return svc, store
+}
+
+func receiptIDs(rs []Receipt) []string {
+	out := make([]string, len(rs))
+	for i, r := range rs {
+		out[i] = r.UUID
+	}
+	return out
+}
+
+func TestResolveRecipientsPrefersHeads(t *testing.T) {
+	members := []Member{{UUID: "a"}, {UUID: "h", Position: "head"}, {UUID: "b"}}
+	if got := ResolveRecipients(members); len(got) != 1 || got[0].UUID != "h" {
+		t.Fatalf("expected the head, got %+v", got)
+	}
+	if got := ResolveRecipients(members[:1]); len(got) != 1 || got[0].UUID != "a" {
+		t.Fatalf("expected fallback to members, got %+v", got)
+	}
+}
+
+func TestCreateResolvesRecipientsPerDepartment(t *testing.T) {
+	svc, _ := newTestService()
+	m, err := svc.Create("sender", Draft{
+		Subject: " Budget ", Body: "Please review", Priority: "HIGH",
+		Departments: []string{deptFinance, deptLegal, deptFinance}, Attachments: []string{fileA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := receiptIDs(m.Recipients)
+	if len(got) != 3 || got[0] != "fin-head" || got[1] != "legal-1" || got[2] != "legal-2" {
+		t.Fatalf("unexpected recipients %v", got)
+	}
+	if m.Subject != "Budget" || m.Priority != PriorityHigh || m.ThreadID != m.ID || len(m.Departments) != 2 {
+		t.Fatalf("unexpected message %+v", m)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	svc, _ := newTestService()
+	cases := []Draft{
+		{Body: "x", Departments: []string{deptFinance}},
+		{Subject: "s", Departments: []string{deptFinance}},
+		{Subject: "s", Body: "x"},
+		{Subject: "s", Body: "x", Departments: []string{"not-a-uuid"}},
+		{Subject: "s", Body: "x", Departments: []string{"44444444-4444-4444-4444-444444444444"}},
+		{Subject: "s", Body: "x", Priority: "urgent", Departments: []string{deptFinance}},
+		// fileB is confidential to Finance; the sender is in Operations.
+		{Subject: "s", Body: "x", Departments: []string{deptFinance}, Attachments: []string{fileB}},
+	}
+	for i, d := range cases {
+		var v ValidationError
+		if _, err := svc.Create("sender", d); !errors.As(err, &v) {
+			t.Fatalf("case %d: expected validation error, got %v", i, err)
+		}
+	}
+	if _, err := svc.Create("ghost", Draft{Subject: "s", Body: "x", Departments: []string{deptFinance}}); err != ErrForbidden {
+		t.Fatalf("expected forbidden for unknown sender, got %v", err)
+	}
+	// A head writing to their own department reaches its other members.
+	if _, err := svc.Create("ops-head", Draft{Subject: "s", Body: "x", Departments: []string{deptOps}}); err != nil {
+		t.Fatalf("expected the regular member to receive it, got %v", err)
+	}
+}
+
+func TestReplyThreadsAndReachesParticipants(t *testing.T) {
+	svc, _ := newTestService()
+	root, err := svc.Create("sender", Draft{Subject: "Budget", Body: "Please review", Departments: []string{deptFinance}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := svc.Reply("fin-head", root.ID, Reply{Body: "Looks fine", Attachments: []string{fileB}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ThreadID != root.ID || reply.ParentID != root.ID || reply.Subject != "Re: Budget" {
+		t.Fatalf("reply not threaded: %+v", reply)
+	}
+	if got := receiptIDs(reply.Recipients); len(got) != 1 || got[0] != "sender" {
+		t.Fatalf("expected reply to go to the original sender, got %v", got)
+	}
+
+	if _, err := svc.Reply("legal-1", root.ID, Reply{Body: "me too"}); err != ErrForbidden {
+		t.Fatalf("expected outsiders to be refused, got %v", err)
+	}
+	// Replying to the reply stays in the same thread.
+	again, err := svc.Reply("sender", reply.ID, Reply{Body: "Thanks"})
+	if err != nil || again.ThreadID != root.ID || again.Subject != "Re: Budget" {
+		t.Fatalf("unexpected nested reply %+v %v", again, err)
+	}
+
+	thread, err := svc.Thread("fin-head", reply.ID)
+	if err != nil || len(thread) != 3 {
+		t.Fatalf("expected 3 messages, got %d %v", len(thread), err)
+	}
+	if thread[0].Recipients != nil || thread[1].Recipients == nil {
+		t.Fatalf("receipts must only be shown to each message's sender: %+v", thread)
+	}
+}
+
+func TestReadReceipts(t *testing.T) {
+	svc, _ := newTestService()
+	root, _ := svc.Create("sender", Draft{Subject: "s", Body: "x", Departments: []string{deptLegal}})
+	if err := svc.MarkRead("legal-1", root.ID); err != nil {
+		t.Fatal(err)
+	}
+	thread, _ := svc.Thread("legal-1", root.ID)
+	first := thread[0].ReadAt
+	if first == nil {
+		t.Fatal("expected own receipt to be visible")
+	}
+	if err := svc.MarkRead("legal-1", root.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.MarkRead("fin-head", root.ID); err != ErrNotFound {
+		t.Fatalf("expected non-recipient to get not found, got %v", err)
+	}
+
+	thread, _ = svc.Thread("sender", root.ID)
+	read := map[string]*time.Time{}
+	for _, r := range thread[0].Recipients {
+		read[r.UUID] = r.ReadAt
+	}
+	if read["legal-1"] == nil || !read["legal-1"].Equal(*first) || read["legal-2"] != nil {
+		t.Fatalf("unexpected receipts %+v", thread[0].Recipients)
+	}
+}
+
+func TestListBoxes(t *testing.T) {
+	svc, _ := newTestService()
+	a, _ := svc.Create("sender", Draft{Subject: "a", Body: "x", Departments: []string{deptLegal}})
+	b, _ := svc.Create("sender", Draft{Subject: "b", Body: "x", Departments: []string{deptLegal}})
+
+	inbox, err := svc.List("legal-2", BoxInbox, time.Time{}, 0)
+	if err != nil || len(inbox) != 2 || inbox[0].ID != b.ID {
+		t.Fatalf("unexpected inbox %+v %v", inbox, err)
+	}
+	page, _ := svc.List("legal-2", BoxInbox, inbox[0].CreatedAt, 1)
+	if len(page) != 1 || page[0].ID != a.ID {
+		t.Fatalf("cursor did not page: %+v", page)
+	}
+	sent, _ := svc.List("sender", BoxSent, time.Time{}, 10)
+	if len(sent) != 2 || sent[0].Recipients == nil {
+		t.Fatalf("expected sent items with receipts, got %+v", sent)
+	}
+	if _, err := svc.List("sender", "trash", time.Time{}, 10); err == nil {
+		t.Fatal("expected invalid box error")
+	}
+}
@@ -0,0 +1,295 @@
+package quickshare
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/access"
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (quick_share, quick_share_recipients)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(r models.QuickShareRow) Message {
+	m := Message{
+		ID: r.QSUUID, ThreadID: r.ThreadQSUUID, ParentID: r.ParentQSUUID,
+		SenderUUID: r.SenderUUID, SenderName: r.SenderName, SenderDUUID: r.SenderDUUID,
+		Subject: r.Subject, Body: r.Body, Priority: r.Priority,
+		Attachments: r.Attachments, Departments: r.Departments, CreatedAt: r.CreatedAt, ReplyCount: r.ReplyCount,
+	}
+	for _, rc := range r.Recipients {
+		m.Recipients = append(m.Recipients, Receipt{UUID: rc.UUID, Name: rc.Name, DUUID: rc.DUUID, ReadAt: rc.ReadAt})
+	}
+	return m
+}
+
+func fromRows(rows []models.QuickShareRow) []Message {
+	out := make([]Message, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out
+}
+
+func (s pgStore) User(uuid string) (*Member, error) {
+	p, err := models.GetUserProfile(s.db, uuid)
+	if err != nil || p == nil || !p.IsActive {
+		return nil, err
+	}
+	return &Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position}, nil
+}
+
+func (s pgStore) Members(dUUIDs []string) ([]Member, error) {
+	rows, err := models.ListActiveDepartmentMembers(s.db, dUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position})
+	}
+	return out, nil
+}
+
+func (s pgStore) MissingDepartments(dUUIDs []string) ([]string, error) {
+	return models.MissingDepartments(s.db, dUUIDs)
+}
+
+func (s pgStore) InaccessibleFiles(user Member, fuuids []string) ([]string, error) {
+	return models.InaccessibleFiles(s.db, user.UUID, fuuids)
+}
+
+func (s pgStore) Insert(m Message) (Message, error) {
+	row := models.QuickShareRow{
+		ThreadQSUUID: m.ThreadID, ParentQSUUID: m.ParentID, SenderUUID: m.SenderUUID,
+		Subject: m.Subject, Body: m.Body, Priority: m.Priority,
+		Attachments: m.Attachments, Departments: m.Departments, CreatedAt: m.CreatedAt,
+	}
+	for _, r := range m.Recipients {
+		row.Recipients = append(row.Recipients, models.QuickShareRecipient{UUID: r.UUID, DUUID: r.DUUID})
+	}
+	id, err := models.InsertQuickShare(s.db, row)
+	if err != nil {
+		return Message{}, err
+	}
+	m.ID = id
+	if m.ThreadID == "" {
+		m.ThreadID = id
+	}
+	return m, nil
+}
+
+func (s pgStore) Get(id string) (*Message, error) {
+	rows, err := models.GetQuickShares(s.db, []string{id})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	m := fromRow(rows[0])
+	return &m, nil
+}
+
+func (s pgStore) Thread(threadID string) ([]Message, error) {
+	rows, err := models.GetQuickShareThread(s.db, threadID)
+	return fromRows(rows), err
+}
+
+func (s pgStore) List(user Member, box string, before time.Time, limit int) ([]Message, error) {
+	rows, err := models.ListQuickShares(s.db, user.UUID, user.DUUID, box == BoxSent, before, limit)
+	return fromRows(rows), err
+}
+
+func (s pgStore) MarkRead(id, userID string, at time.Time) (bool, error) {
+	return models.MarkQuickShareRead(s.db, id, userID, at)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store (tests and local development)
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu       sync.Mutex
+	nextID   int
+	users    map[string]Member
+	depts    map[string]bool
+	files    map[string]access.File
+	messages []Message
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: map[string]Member{}, depts: map[string]bool{}, files: map[string]access.File{}}
+}
+
+// AddUser registers an active user and their department.
+func (s *MemoryStore) AddUser(m Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[m.UUID] = m
+	if m.DUUID != "" {
+		s.depts[m.DUUID] = true
+	}
+}
+
+func (s *MemoryStore) AddDepartment(dUUID string) {
+	s.mu.Lock()
+	s.depts[dUUID] = true
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) AddFile(f access.File) {
+	s.mu.Lock()
+	s.files[f.FUUID] = f
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) User(uuid string) (*Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.users[uuid]; ok {
+		return &m, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Members(dUUIDs []string) ([]Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := map[string]bool{}
+	for _, d := range dUUIDs {
+		want[d] = true
+	}
+	var out []Member
+	for _, m := range s.users {
+		if want[m.DUUID] {
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (s *MemoryStore) MissingDepartments(dUUIDs []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, d := range dUUIDs {
+		if !s.depts[d] {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) InaccessibleFiles(user Member, fuuids []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, id := range fuuids {
+		f, ok := s.files[id]
+		v := access.Viewer{UUID: user.UUID, DUUID: user.DUUID, Position: user.Position, Active: true}
+		if !ok || !access.Check(v, f).Allowed {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Insert(m Message) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	m.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", s.nextID)
+	if m.ThreadID == "" {
+		m.ThreadID = m.ID
+	}
+	m.Recipients = append([]Receipt(nil), m.Recipients...)
+	s.messages = append(s.messages, m)
+	return m, nil
+}
+
+func (s *MemoryStore) clone(m Message) Message {
+	m.Recipients = append([]Receipt(nil), m.Recipients...)
+	m.ReplyCount = 0
+	for _, o := range s.messages {
+		if o.ThreadID == m.ID && o.ID != m.ID {
+			m.ReplyCount++
+		}
+	}
+	return m
+}
+
+func (s *MemoryStore) Get(id string) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			c := s.clone(m)
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Thread(threadID string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Message
+	for _, m := range s.messages {
+		if m.ThreadID == threadID {
+			out = append(out, s.clone(m))
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) List(user Member, box string, before time.Time, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Message
+	for i := len(s.messages) - 1; i >= 0 && len(out) < limit; i-- {
+		m := s.messages[i]
+		if !m.CreatedAt.Before(before) {
+			continue
+		}
+		match := m.SenderUUID == user.UUID
+		if box == BoxInbox {
+			match = false
+			for _, r := range m.Recipients {
+				match = match || r.UUID == user.UUID
+			}
+		}
+		if match {
+			out = append(out, s.clone(m))
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) MarkRead(id, userID string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		if s.messages[i].ID != id {
+			continue
+		}
+		for j := range s.messages[i].Recipients {
+			r := &s.messages[i].Recipients[j]
+			if r.UUID == userID {
+				if r.ReadAt == nil {
+					t := at
+					r.ReadAt = &t
+				}
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
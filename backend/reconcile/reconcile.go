@@ -0,0 +1,147 @@
+// Package reconcile compares the storage buckets with the file table.
+// Uploads store the object before inserting the row, and purges delete the
+// row before the object, so a failure in between leaves an object nothing
+// points at; objects can also go missing under a row. A run lists both
+// buckets against file.file_path, reports or deletes orphan objects,
+// reports rows whose object is gone, and re-queues OCR and summaries for
+// files that never got them.
+package reconcile
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultGrace leaves objects and files younger than this alone, so an
+	// upload that is still between storing the object and inserting the
+	// row, or still being processed, is not mistaken for a failure.
+	DefaultGrace = time.Hour
+	// MaxRequeueAttempts stops re-queueing OCR or a summary for a file that
+	// keeps failing.
+	MaxRequeueAttempts = 3
+	// MaxRequeuesPerRun bounds how much processing one run starts.
+	MaxRequeuesPerRun = 50
+	// deleteBatch is how many objects are deleted per storage call.
+	deleteBatch = 100
+)
+
+// Finding kinds.
+const (
+	FindingOrphanObject   = "orphan_object"
+	FindingMissingObject  = "missing_object"
+	FindingMissingOCR     = "missing_ocr"
+	FindingMissingSummary = "missing_summary"
+)
+
+// Actions taken on findings. Findings are reported and left alone unless
+// the run cleans orphans or re-queues processing.
+const (
+	ActionReported = "reported"
+	ActionDeleted  = "deleted"
+	ActionRequeued = "requeued"
+	// ActionGaveUp means the file reached MaxRequeueAttempts.
+	ActionGaveUp = "gave_up"
+	ActionFailed = "failed"
+)
+
+// Requeue kinds, as stored in reconcile_requeues.
+const (
+	KindOCR     = "ocr"
+	KindSummary = "summary"
+)
+
+// Run statuses. A run is stored as RunRunning when it starts.
+const (
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed"
+	// RunInterrupted runs were cut short by a restart.
+	RunInterrupted = "interrupted"
+)
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerCommand  = "command"
+)
+
+var (
+	ErrRunNotFound = errors.New("reconcile run not found")
+	// ErrRunning means another run has not finished yet.
+	ErrRunning = errors.New("a reconcile run is already in progress")
+)
+
+// File is a file row as the reconciler sees it.
+type File struct {
+	FUUID      string
+	Name       string
+	FilePath   string
+	UploadedAt time.Time
+	// Archived files keep their object in the archive bucket.
+	Archived bool
+	Trashed  bool
+	HasOCR   bool
+	// HasSummary is set once any summary row exists, whatever its state;
+	// failed summaries are retried by the summary worker.
+	HasSummary      bool
+	OCRAttempts     int
+	SummaryAttempts int
+}
+
+// Object is a stored object. Archived objects are in the archive bucket.
+type Object struct {
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+	Archived  bool
+}
+
+// Options choose what a run changes. With neither set it only reports.
+type Options struct {
+	// Clean deletes orphan objects.
+	Clean bool
+	// Requeue starts OCR and summaries that are missing.
+	Requeue bool
+}
+
+// Finding is one inconsistency and what the run did about it.
+type Finding struct {
+	Kind     string `json:"kind"`
+	Path     string `json:"path,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	FUUID    string `json:"f_uuid,omitempty"`
+	Name     string `json:"f_name,omitempty"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Counts struct {
+	Objects          int   `json:"objects"`
+	Files            int   `json:"files"`
+	OrphanObjects    int   `json:"orphan_objects"`
+	OrphanBytes      int64 `json:"orphan_bytes"`
+	MissingObjects   int   `json:"missing_objects"`
+	MissingOCR       int   `json:"missing_ocr"`
+	MissingSummaries int   `json:"missing_summaries"`
+	Deleted          int   `json:"deleted"`
+	Requeued         int   `json:"requeued"`
+	Failed           int   `json:"failed"`
+}
+
+// Report is a stored run. Findings is nil when runs are listed; Counts and
+// Findings are filled in once the run is no longer running.
+type Report struct {
+	ID         string     `json:"run_id"`
+	Trigger    string     `json:"trigger"`
+	Clean      bool       `json:"clean"`
+	Requeue    bool       `json:"requeue"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Counts     Counts     `json:"counts"`
+	Findings   []Finding  `json:"findings,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
@@ -0,0 +1,269 @@
+package reconcile
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"backend/preview"
+)
+
+// Store reads file rows and persists runs. Implementations:
+// NewPostgresStore and NewMemoryStore.
+type Store interface {
+	// Files returns every file row, archived and trashed ones included.
+	Files() ([]File, error)
+	// NoteRequeue counts one more re-queue of kind for the file.
+	NoteRequeue(fuuid, kind string, at time.Time) error
+	// CreateRun stores a run as it starts and returns it with its ID.
+	CreateRun(r Report) (Report, error)
+	// FinishRun stores a run's outcome.
+	FinishRun(r Report) error
+	// Runs returns the latest runs without their findings, newest first.
+	Runs(limit int) ([]Report, error)
+	// Run returns nil when the run does not exist.
+	Run(id string) (*Report, error)
+}
+
+// Storage lists and removes objects in file_storage or, when archived, the
+// archive bucket.
+type Storage interface {
+	List(archived bool) ([]Object, error)
+	Delete(paths []string, archived bool) error
+}
+
+// Requeuer starts processing for a file whose object is in file_storage.
+type Requeuer interface {
+	OCR(f File) error
+	Summary(f File) error
+}
+
+type Service struct {
+	store    Store
+	storage  Storage
+	requeuer Requeuer
+	// Grace is how old objects and files must be before they are judged.
+	Grace time.Duration
+	Now   func() time.Time
+
+	running sync.Mutex
+}
+
+func NewService(store Store, storage Storage, requeuer Requeuer) *Service {
+	return &Service{store: store, storage: storage, requeuer: requeuer, Grace: DefaultGrace, Now: time.Now}
+}
+
+func (s *Service) Runs(limit int) ([]Report, error) {
+	return s.store.Runs(limit)
+}
+
+func (s *Service) Run(id string) (Report, error) {
+	r, err := s.store.Run(id)
+	if err != nil {
+		return Report{}, err
+	}
+	if r == nil {
+		return Report{}, ErrRunNotFound
+	}
+	return *r, nil
+}
+
+// Execute runs the reconciler once and stores its report, also when it
+// fails partway; the error is returned along with the saved report.
+func (s *Service) Execute(trigger string, opts Options) (Report, error) {
+	rep, err := s.begin(trigger, opts)
+	if err != nil {
+		return rep, err
+	}
+	return s.finish(rep, opts)
+}
+
+// Start stores a new run and carries it out in the background, returning
+// the run while it is still RunRunning; Run(id) has the outcome once it
+// ends. done, when not nil, is called with the finished report.
+func (s *Service) Start(trigger string, opts Options, done func(Report, error)) (Report, error) {
+	rep, err := s.begin(trigger, opts)
+	if err != nil {
+		return rep, err
+	}
+	go func() {
+		final, err := s.finish(rep, opts)
+		if done != nil {
+			done(final, err)
+		}
+	}()
+	return rep, nil
+}
+
+// begin takes the run lock and stores the run; finish releases the lock.
+func (s *Service) begin(trigger string, opts Options) (Report, error) {
+	if !s.running.TryLock() {
+		return Report{}, ErrRunning
+	}
+	rep := Report{Trigger: trigger, Clean: opts.Clean, Requeue: opts.Requeue, Status: RunRunning, StartedAt: s.Now()}
+	saved, err := s.store.CreateRun(rep)
+	if err != nil {
+		s.running.Unlock()
+		return rep, err
+	}
+	return saved, nil
+}
+
+func (s *Service) finish(rep Report, opts Options) (Report, error) {
+	defer s.running.Unlock()
+	rep.Findings = []Finding{}
+	runErr := s.check(&rep, opts)
+	rep.Status = RunDone
+	if runErr != nil {
+		rep.Status, rep.Error = RunFailed, runErr.Error()
+	}
+	finished := s.Now()
+	rep.FinishedAt = &finished
+	if err := s.store.FinishRun(rep); err != nil {
+		return rep, err
+	}
+	return rep, runErr
+}
+
+func (s *Service) check(rep *Report, opts Options) error {
+	files, err := s.store.Files()
+	if err != nil {
+		return err
+	}
+	live, err := s.storage.List(false)
+	if err != nil {
+		return err
+	}
+	archived, err := s.storage.List(true)
+	if err != nil {
+		return err
+	}
+	rep.Counts.Files = len(files)
+	rep.Counts.Objects = len(live) + len(archived)
+	cutoff := rep.StartedAt.Add(-s.Grace)
+
+	// Paths referenced by rows, by the bucket their object should be in.
+	rows := map[bool]map[string]bool{false: {}, true: {}}
+	for _, f := range files {
+		if f.FilePath != "" {
+			rows[f.Archived][f.FilePath] = true
+		}
+	}
+	stored := map[bool]map[string]bool{false: {}, true: {}}
+	var orphans []Object
+	for _, o := range append(live, archived...) {
+		stored[o.Archived][o.Path] = true
+		if !owned(o, rows) && o.CreatedAt.Before(cutoff) {
+			orphans = append(orphans, o)
+		}
+	}
+	s.orphans(rep, orphans, opts.Clean)
+
+	requeues := 0
+	for _, f := range files {
+		if f.UploadedAt.After(cutoff) {
+			continue
+		}
+		if f.FilePath == "" || !stored[f.Archived][f.FilePath] {
+			rep.Counts.MissingObjects++
+			rep.Findings = append(rep.Findings, Finding{
+				Kind: FindingMissingObject, Path: f.FilePath, Archived: f.Archived, FUUID: f.FUUID, Name: f.Name,
+				Action: ActionReported,
+			})
+			continue
+		}
+		// Archived and trashed files are not processed any more.
+		if f.Archived || f.Trashed {
+			continue
+		}
+		if !f.HasOCR {
+			rep.Counts.MissingOCR++
+			s.requeue(rep, f, KindOCR, f.OCRAttempts, opts.Requeue && requeues < MaxRequeuesPerRun, &requeues)
+		}
+		if !f.HasSummary {
+			rep.Counts.MissingSummaries++
+			s.requeue(rep, f, KindSummary, f.SummaryAttempts, opts.Requeue && requeues < MaxRequeuesPerRun, &requeues)
+		}
+	}
+	return nil
+}
+
+// owned reports whether a row still needs o. An object is kept when a row
+// points at it in either bucket, so one caught halfway through an archive
+// move is never deleted; previews are only needed next to live files, as
+// archiving drops them.
+func owned(o Object, rows map[bool]map[string]bool) bool {
+	if src, ok := preview.SourcePath(o.Path); ok {
+		return !o.Archived && rows[false][src]
+	}
+	return rows[false][o.Path] || rows[true][o.Path]
+}
+
+// orphans records the orphan objects and, when cleaning, deletes them in
+// batches per bucket.
+func (s *Service) orphans(rep *Report, objects []Object, clean bool) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	byBucket := map[bool][]int{}
+	for _, o := range objects {
+		rep.Counts.OrphanObjects++
+		rep.Counts.OrphanBytes += o.Size
+		byBucket[o.Archived] = append(byBucket[o.Archived], len(rep.Findings))
+		rep.Findings = append(rep.Findings, Finding{
+			Kind: FindingOrphanObject, Path: o.Path, Archived: o.Archived, Size: o.Size, Action: ActionReported,
+		})
+	}
+	if !clean {
+		return
+	}
+	for _, archived := range []bool{false, true} {
+		idx := byBucket[archived]
+		for start := 0; start < len(idx); start += deleteBatch {
+			batch := idx[start:min(start+deleteBatch, len(idx))]
+			paths := make([]string, len(batch))
+			for i, n := range batch {
+				paths[i] = rep.Findings[n].Path
+			}
+			err := s.storage.Delete(paths, archived)
+			for _, n := range batch {
+				if err != nil {
+					rep.Findings[n].Action, rep.Findings[n].Error = ActionFailed, err.Error()
+					rep.Counts.Failed++
+				} else {
+					rep.Findings[n].Action = ActionDeleted
+					rep.Counts.Deleted++
+				}
+			}
+		}
+	}
+}
+
+// requeue records a missing OCR or summary and, when allowed, starts it.
+func (s *Service) requeue(rep *Report, f File, kind string, attempts int, allowed bool, requeues *int) {
+	finding := Finding{Kind: FindingMissingOCR, Path: f.FilePath, FUUID: f.FUUID, Name: f.Name, Action: ActionReported}
+	if kind == KindSummary {
+		finding.Kind = FindingMissingSummary
+	}
+	switch {
+	case attempts >= MaxRequeueAttempts:
+		finding.Action = ActionGaveUp
+	case allowed:
+		*requeues++
+		var err error
+		if kind == KindOCR {
+			err = s.requeuer.OCR(f)
+		} else {
+			err = s.requeuer.Summary(f)
+		}
+		if err == nil {
+			err = s.store.NoteRequeue(f.FUUID, kind, s.Now())
+		}
+		if err != nil {
+			finding.Action, finding.Error = ActionFailed, err.Error()
+			rep.Counts.Failed++
+		} else {
+			finding.Action = ActionRequeued
+			rep.Counts.Requeued++
+		}
+	}
+	rep.Findings = append(rep.Findings, finding)
+}
@@ -0,0 +1,254 @@
+package reconcile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	now  = time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	old  = now.Add(-48 * time.Hour)
+	past = now.Add(-10 * time.Minute)
+)
+
+func newTestService(t *testing.T) (*Service, *MemoryStore, *MemoryStorage, *MemoryRequeuer) {
+	t.Helper()
+	store := NewMemoryStore()
+	storage := NewMemoryStorage()
+	requeuer := NewMemoryRequeuer()
+	svc := NewService(store, storage, requeuer)
+	svc.Now = func() time.Time { return now }
+	return svc, store, storage, requeuer
+}
+
+// addFile adds a processed file with its object in the right bucket.
+func addFile(store *MemoryStore, storage *MemoryStorage, f File) {
+	f.FilePath = "Ops/" + f.FUUID + ".pdf"
+	if f.UploadedAt.IsZero() {
+		f.UploadedAt = old
+	}
+	store.AddFile(f)
+	storage.Put(Object{Path: f.FilePath, Size: 100, CreatedAt: f.UploadedAt, Archived: f.Archived})
+}
+
+func findings(rep Report, kind string) map[string]string {
+	out := map[string]string{}
+	for _, f := range rep.Findings {
+		if f.Kind != kind {
+			continue
+		}
+		key := f.Path
+		if kind == FindingMissingOCR || kind == FindingMissingSummary {
+			key = f.FUUID
+		}
+		out[key] = f.Action
+	}
+	return out
+}
+
+func TestOrphanObjects(t *testing.T) {
+	svc, store, storage, _ := newTestService(t)
+	addFile(store, storage, File{FUUID: "f1", HasOCR: true, HasSummary: true})
+	addFile(store, storage, File{FUUID: "f2", HasOCR: true, HasSummary: true, Archived: true})
+	addFile(store, storage, File{FUUID: "f3", HasOCR: true, HasSummary: true, Trashed: true})
+	storage.Put(Object{Path: "Ops/f1.pdf.preview/thumbnail.jpg", CreatedAt: old})
+	// An upload whose row insert failed, with its preview.
+	storage.Put(Object{Path: "Ops/lost.pdf", Size: 250, CreatedAt: old})
+	storage.Put(Object{Path: "Ops/lost.pdf.preview/page-1.jpg", Size: 20, CreatedAt: old})
+	// Archived files lose their previews.
+	storage.Put(Object{Path: "Ops/f2.pdf.preview/thumbnail.jpg", Size: 10, CreatedAt: old})
+	storage.Put(Object{Path: "Ops/purged.pdf", Size: 5, CreatedAt: old, Archived: true})
+	// Still being uploaded.
+	storage.Put(Object{Path: "Ops/new.pdf", CreatedAt: past})
+
+	rep, err := svc.Execute(TriggerManual, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Ops/lost.pdf": ActionReported, "Ops/lost.pdf.preview/page-1.jpg": ActionReported,
+		"Ops/f2.pdf.preview/thumbnail.jpg": ActionReported, "Ops/purged.pdf": ActionReported,
+	}
+	if got := findings(rep, FindingOrphanObject); !reflect.DeepEqual(got, want) {
+		t.Fatalf("orphans = %v", got)
+	}
+	if rep.Counts.OrphanObjects != 4 || rep.Counts.OrphanBytes != 285 || rep.Counts.Objects != 9 || rep.Counts.Files != 3 {
+		t.Fatalf("unexpected counts: %+v", rep.Counts)
+	}
+	if !storage.Has("Ops/lost.pdf", false) {
+		t.Fatal("a report-only run must not delete anything")
+	}
+
+	storage.Fail["Ops/purged.pdf"] = true
+	rep, err = svc.Execute(TriggerManual, Options{Clean: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := findings(rep, FindingOrphanObject)
+	if got["Ops/lost.pdf"] != ActionDeleted || got["Ops/purged.pdf"] != ActionFailed || rep.Counts.Deleted != 3 || rep.Counts.Failed != 1 {
+		t.Fatalf("unexpected clean: %v %+v", got, rep.Counts)
+	}
+	for _, p := range []string{"Ops/lost.pdf", "Ops/f2.pdf.preview/thumbnail.jpg"} {
+		if storage.Has(p, false) {
+			t.Fatalf("%s should have been deleted", p)
+		}
+	}
+	for _, p := range []string{"Ops/f1.pdf", "Ops/f1.pdf.preview/thumbnail.jpg", "Ops/f3.pdf", "Ops/new.pdf"} {
+		if !storage.Has(p, false) {
+			t.Fatalf("%s should have been kept", p)
+		}
+	}
+}
+
+func TestMissingObjects(t *testing.T) {
+	svc, store, storage, requeuer := newTestService(t)
+	store.AddFile(File{FUUID: "f1", Name: "gone.pdf", FilePath: "Ops/gone.pdf", UploadedAt: old})
+	store.AddFile(File{FUUID: "f2", FilePath: "", UploadedAt: old})
+	// Archived: its object should be in the archive bucket, not file_storage.
+	store.AddFile(File{FUUID: "f3", FilePath: "Ops/f3.pdf", UploadedAt: old, Archived: true, HasOCR: true, HasSummary: true})
+	storage.Put(Object{Path: "Ops/f3.pdf", CreatedAt: old})
+
+	rep, err := svc.Execute(TriggerManual, Options{Requeue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Counts.MissingObjects != 3 || rep.Counts.OrphanObjects != 0 {
+		t.Fatalf("unexpected counts: %+v", rep.Counts)
+	}
+	if len(requeuer.OCRs) != 0 || len(requeuer.Summaries) != 0 {
+		t.Fatal("files without their object cannot be processed")
+	}
+}
+
+func TestRequeueMissingProcessing(t *testing.T) {
+	svc, store, storage, requeuer := newTestService(t)
+	addFile(store, storage, File{FUUID: "f1", HasSummary: true})
+	addFile(store, storage, File{FUUID: "f2", HasOCR: true})
+	addFile(store, storage, File{FUUID: "f3", OCRAttempts: MaxRequeueAttempts, HasSummary: true})
+	addFile(store, storage, File{FUUID: "f4", HasOCR: true, HasSummary: true})
+	addFile(store, storage, File{FUUID: "f5", Trashed: true})
+	addFile(store, storage, File{FUUID: "f6", UploadedAt: past})
+	addFile(store, storage, File{FUUID: "f7", HasSummary: true})
+	requeuer.Fail["f7"] = true
+
+	rep, err := svc.Execute(TriggerManual, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requeuer.OCRs) != 0 || findings(rep, FindingMissingOCR)["f1"] != ActionReported {
+		t.Fatal("a run without Requeue only reports")
+	}
+
+	rep, err = svc.Execute(TriggerSchedule, Options{Requeue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOCR := map[string]string{"f1": ActionRequeued, "f3": ActionGaveUp, "f7": ActionFailed}
+	if got := findings(rep, FindingMissingOCR); !reflect.DeepEqual(got, wantOCR) {
+		t.Fatalf("missing OCR = %v", got)
+	}
+	if got := findings(rep, FindingMissingSummary); !reflect.DeepEqual(got, map[string]string{"f2": ActionRequeued}) {
+		t.Fatalf("missing summaries = %v", got)
+	}
+	if !reflect.DeepEqual(requeuer.OCRs, []string{"f1"}) || !reflect.DeepEqual(requeuer.Summaries, []string{"f2"}) {
+		t.Fatalf("requeued %v %v", requeuer.OCRs, requeuer.Summaries)
+	}
+	if rep.Counts.Requeued != 2 || rep.Counts.Failed != 1 || rep.Counts.MissingOCR != 3 {
+		t.Fatalf("unexpected counts: %+v", rep.Counts)
+	}
+
+	files, _ := store.Files()
+	if files[0].OCRAttempts != 1 {
+		t.Fatalf("attempt not recorded: %+v", files[0])
+	}
+}
+
+func TestRequeuesPerRunAreBounded(t *testing.T) {
+	svc, store, storage, requeuer := newTestService(t)
+	for i := 0; i < MaxRequeuesPerRun+5; i++ {
+		addFile(store, storage, File{FUUID: string(rune('a'+i/26)) + string(rune('a'+i%26)), HasSummary: true})
+	}
+	rep, err := svc.Execute(TriggerManual, Options{Requeue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requeuer.OCRs) != MaxRequeuesPerRun || rep.Counts.MissingOCR != MaxRequeuesPerRun+5 {
+		t.Fatalf("requeued %d of %d", len(requeuer.OCRs), rep.Counts.MissingOCR)
+	}
+}
+
+func TestRunsAreStoredAndDoNotOverlap(t *testing.T) {
+	svc, _, _, _ := newTestService(t)
+	svc.running.Lock()
+	if _, err := svc.Execute(TriggerManual, Options{}); !errors.Is(err, ErrRunning) {
+		t.Fatalf("expected ErrRunning, got %v", err)
+	}
+	svc.running.Unlock()
+
+	rep, err := svc.Execute(TriggerManual, Options{Clean: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := svc.Runs(10)
+	if len(runs) != 1 || runs[0].ID != rep.ID || runs[0].Findings != nil {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+	if got, err := svc.Run(rep.ID); err != nil || !got.Clean || got.Status != RunDone || got.FinishedAt == nil {
+		t.Fatalf("unexpected run: %+v %v", got, err)
+	}
+	if _, err := svc.Run("missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("expected ErrRunNotFound, got %v", err)
+	}
+}
+
+// blockingStorage holds List until release is closed.
+type blockingStorage struct {
+	*MemoryStorage
+	release chan struct{}
+}
+
+func (b blockingStorage) List(archived bool) ([]Object, error) {
+	<-b.release
+	return b.MemoryStorage.List(archived)
+}
+
+func TestStartRunsInBackground(t *testing.T) {
+	store, storage := NewMemoryStore(), NewMemoryStorage()
+	addFile(store, storage, File{FUUID: "f1", HasOCR: true, HasSummary: true})
+	storage.Put(Object{Path: "Ops/lost.pdf", CreatedAt: old})
+	release := make(chan struct{})
+	svc := NewService(store, blockingStorage{storage, release}, NewMemoryRequeuer())
+	svc.Now = func() time.Time { return now }
+
+	done := make(chan Report, 1)
+	rep, err := svc.Start(TriggerManual, Options{Clean: true}, func(r Report, err error) {
+		if err != nil {
+			t.Errorf("run failed: %v", err)
+		}
+		done <- r
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.ID == "" || rep.Status != RunRunning || rep.FinishedAt != nil {
+		t.Fatalf("expected a stored running run, got %+v", rep)
+	}
+	if got, _ := svc.Run(rep.ID); got.Status != RunRunning {
+		t.Fatalf("run should be pollable while running, got %+v", got)
+	}
+	if _, err := svc.Start(TriggerManual, Options{}, nil); !errors.Is(err, ErrRunning) {
+		t.Fatalf("expected ErrRunning, got %v", err)
+	}
+
+	close(release)
+	final := <-done
+	got, err := svc.Run(rep.ID)
+	if err != nil || got.Status != RunDone || got.Counts.Deleted != 1 || final.ID != rep.ID {
+		t.Fatalf("unexpected finished run: %+v %v", got, err)
+	}
+	if storage.Has("Ops/lost.pdf", false) {
+		t.Fatal("the background run should have cleaned the orphan")
+	}
+}
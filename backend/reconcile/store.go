@@ -0,0 +1,275 @@
+package reconcile
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (file, reconcile_runs, reconcile_requeues)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func (s pgStore) Files() ([]File, error) {
+	rows, err := models.ListReconcileFiles(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]File, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, File{
+			FUUID: r.FUUID, Name: r.FileName, FilePath: r.FilePath, UploadedAt: r.UploadedAt,
+			Archived: r.Archived, Trashed: r.Trashed, HasOCR: r.HasOCR, HasSummary: r.HasSummary,
+			OCRAttempts: r.OCRAttempts, SummaryAttempts: r.SummaryAttempts,
+		})
+	}
+	return out, nil
+}
+
+func (s pgStore) NoteRequeue(fuuid, kind string, at time.Time) error {
+	return models.NoteReconcileRequeue(s.db, fuuid, kind, at)
+}
+
+func (s pgStore) CreateRun(r Report) (Report, error) {
+	row, err := models.InsertReconcileRun(s.db, models.ReconcileRunRow{
+		Trigger: r.Trigger, Clean: r.Clean, Requeue: r.Requeue, StartedAt: r.StartedAt,
+	})
+	if err != nil {
+		return r, err
+	}
+	r.ID = row.RunID
+	return r, nil
+}
+
+func (s pgStore) FinishRun(r Report) error {
+	counts, err := json.Marshal(r.Counts)
+	if err != nil {
+		return err
+	}
+	findings, err := json.Marshal(r.Findings)
+	if err != nil {
+		return err
+	}
+	return models.FinishReconcileRun(s.db, models.ReconcileRunRow{
+		RunID: r.ID, Status: r.Status, FinishedAt: r.FinishedAt, Counts: counts, Findings: findings, Error: r.Error,
+	})
+}
+
+func runFromRow(r models.ReconcileRunRow) (Report, error) {
+	rep := Report{
+		ID: r.RunID, Trigger: r.Trigger, Clean: r.Clean, Requeue: r.Requeue, Status: r.Status, StartedAt: r.StartedAt,
+		FinishedAt: r.FinishedAt, Error: r.Error,
+	}
+	if err := json.Unmarshal(r.Counts, &rep.Counts); err != nil {
+		return rep, err
+	}
+	if len(r.Findings) > 0 {
+		if err := json.Unmarshal(r.Findings, &rep.Findings); err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+func (s pgStore) Runs(limit int) ([]Report, error) {
+	rows, err := models.ListReconcileRuns(s.db, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Report, 0, len(rows))
+	for _, r := range rows {
+		rep, err := runFromRow(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+func (s pgStore) Run(id string) (*Report, error) {
+	r, err := models.GetReconcileRun(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	rep, err := runFromRow(*r)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store, storage and requeuer (tests)
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu    sync.Mutex
+	files []File
+	runs  []Report
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{} }
+
+func (m *MemoryStore) AddFile(f File) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files = append(m.files, f)
+}
+
+func (m *MemoryStore) Files() ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]File(nil), m.files...), nil
+}
+
+func (m *MemoryStore) NoteRequeue(fuuid, kind string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.files {
+		if m.files[i].FUUID != fuuid {
+			continue
+		}
+		if kind == KindOCR {
+			m.files[i].OCRAttempts++
+		} else {
+			m.files[i].SummaryAttempts++
+		}
+		return nil
+	}
+	return errors.New("file not found")
+}
+
+func (m *MemoryStore) CreateRun(r Report) (Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID = fmt.Sprintf("run-%d", len(m.runs)+1)
+	m.runs = append(m.runs, r)
+	return r, nil
+}
+
+func (m *MemoryStore) FinishRun(r Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.runs {
+		if m.runs[i].ID == r.ID && m.runs[i].Status == RunRunning {
+			m.runs[i] = r
+			return nil
+		}
+	}
+	return errors.New("run not found")
+}
+
+func (m *MemoryStore) Runs(limit int) ([]Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Report{}
+	for i := len(m.runs) - 1; i >= 0 && len(out) < limit; i-- {
+		r := m.runs[i]
+		r.Findings = nil
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Run(id string) (*Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.runs {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// MemoryStorage holds objects by bucket; Delete fails for paths in Fail.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	objects map[bool]map[string]Object
+	Fail    map[string]bool
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[bool]map[string]Object{false: {}, true: {}}, Fail: map[string]bool{}}
+}
+
+func (m *MemoryStorage) Put(o Object) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[o.Archived][o.Path] = o
+}
+
+// Has reports whether path is stored in file_storage or, when archived,
+// the archive bucket.
+func (m *MemoryStorage) Has(path string, archived bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[archived][path]
+	return ok
+}
+
+func (m *MemoryStorage) List(archived bool) ([]Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Object{}
+	for _, o := range m.objects[archived] {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+func (m *MemoryStorage) Delete(paths []string, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range paths {
+		if m.Fail[p] {
+			return errors.New("storage unavailable")
+		}
+	}
+	for _, p := range paths {
+		delete(m.objects[archived], p)
+	}
+	return nil
+}
+
+// MemoryRequeuer records what was re-queued; it fails for files in Fail.
+type MemoryRequeuer struct {
+	mu        sync.Mutex
+	OCRs      []string
+	Summaries []string
+	Fail      map[string]bool
+}
+
+func NewMemoryRequeuer() *MemoryRequeuer { return &MemoryRequeuer{Fail: map[string]bool{}} }
+
+func (m *MemoryRequeuer) OCR(f File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Fail[f.FUUID] {
+		return errors.New("queue full")
+	}
+	m.OCRs = append(m.OCRs, f.FUUID)
+	return nil
+}
+
+func (m *MemoryRequeuer) Summary(f File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Fail[f.FUUID] {
+		return errors.New("queue full")
+	}
+	m.Summaries = append(m.Summaries, f.FUUID)
+	return nil
+}
@@ -0,0 +1,33 @@
+// Package redact removes personal data from text that leaves the system,
+// such as summaries sent by email.
+package redact
+
+import "regexp"
+
+// Placeholders written in place of redacted values.
+const (
+	EmailMask = "[email redacted]"
+	IDMask    = "[ID redacted]"
+	PhoneMask = "[phone redacted]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Aadhaar-style IDs: 12 digits not starting with 0 or 1, optionally
+	// grouped 4-4-4 with spaces or hyphens.
+	idPattern = regexp.MustCompile(`\b[2-9][0-9]{3}[ \-]?[0-9]{4}[ \-]?[0-9]{4}\b`)
+	// Indian mobile numbers with an optional +91/91/0 prefix, and landlines
+	// written with an STD code (0484-2345678).
+	mobilePattern   = regexp.MustCompile(`(?:\+91[ \-]?|\b91[ \-]?|\b0)?\b[6-9][0-9]{4}[ \-]?[0-9]{5}\b`)
+	landlinePattern = regexp.MustCompile(`\b0[1-9][0-9]{1,3}[ \-][0-9]{6,8}\b`)
+)
+
+// PII masks email addresses, Aadhaar-style IDs and phone numbers in s. IDs
+// are masked before phone numbers so a 12-digit ID is never half-masked.
+func PII(s string) string {
+	s = emailPattern.ReplaceAllString(s, EmailMask)
+	s = idPattern.ReplaceAllString(s, IDMask)
+	s = mobilePattern.ReplaceAllString(s, PhoneMask)
+	s = landlinePattern.ReplaceAllString(s, PhoneMask)
+	return s
+}
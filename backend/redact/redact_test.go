@@ -0,0 +1,20 @@
+package redact
+
+import "testing"
+
+func TestPII(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"Contact a.kumar@kmrl.co.in for details.", "Contact [email redacted] for details."},
+		{"Aadhaar 2345 6789 0123 on file", "Aadhaar [ID redacted] on file"},
+		{"Aadhaar 234567890123.", "Aadhaar [ID redacted]."},
+		{"Call +91 98470 12345 or 9847012345.", "Call [phone redacted] or [phone redacted]."},
+		{"Office: 0484-2345678", "Office: [phone redacted]"},
+		{"Tender No. 4521 dated 12.03.2024 for 150 units", "Tender No. 4521 dated 12.03.2024 for 150 units"},
+		{"Order 1234567890123456 ref", "Order 1234567890123456 ref"},
+	}
+	for _, c := range cases {
+		if got := PII(c.in); got != c.want {
+			t.Errorf("PII(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package retention enforces how long documents and messages are kept.
+// Admins define policies per document category or uploading department,
+// and for quick-share messages and document_processing_results; a
+// scheduled run archives or purges whatever has outlived its policy and
+// records a report.
+//
+// Archiving moves a file's object to the archive bucket and keeps its row;
+// purging deletes the row with its derived OCR and summary rows, and the
+// stored object with its previews. Files under legal hold are never
+// touched.
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxNameLen = 120
+	// MaxKeepDays caps a policy at 100 years.
+	MaxKeepDays      = 36500
+	MaxHoldReasonLen = 500
+)
+
+// Policy scopes. Category and department policies govern files; the other
+// two govern whole tables by age.
+const (
+	ScopeCategory          = "category"
+	ScopeDepartment        = "department"
+	ScopeQuickShare        = "quick_share"
+	ScopeProcessingResults = "processing_results"
+)
+
+// Actions.
+const (
+	ActionArchive = "archive"
+	ActionPurge   = "purge"
+)
+
+// Item outcomes. Dry runs report what they would have done.
+const (
+	OutcomeArchived     = "archived"
+	OutcomePurged       = "purged"
+	OutcomeHeld         = "held"
+	OutcomeFailed       = "failed"
+	OutcomeWouldArchive = "would_archive"
+	OutcomeWouldPurge   = "would_purge"
+)
+
+// Run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+var (
+	ErrNotFound     = errors.New("retention policy not found")
+	ErrRunNotFound  = errors.New("retention run not found")
+	ErrFileNotFound = errors.New("file not found")
+	// ErrRunning means another run has not finished yet.
+	ErrRunning = errors.New("a retention run is already in progress")
+	// ErrHeld means the file was placed under legal hold, or removed,
+	// while the run was working on it.
+	ErrHeld = errors.New("file is under legal hold")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type Policy struct {
+	ID         string    `json:"policy_id"`
+	Name       string    `json:"name"`
+	Scope      string    `json:"scope"`
+	CategoryID string    `json:"category_id,omitempty"`
+	DUUID      string    `json:"d_uuid,omitempty"`
+	KeepDays   int       `json:"keep_days"`
+	Action     string    `json:"action"`
+	Enabled    bool      `json:"enabled"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// File is a stored document as retention sees it. CategoryID is empty for
+// unclassified files; Previews are the rendered images stored next to it.
+type File struct {
+	FUUID      string
+	Name       string
+	FilePath   string
+	DUUID      string
+	CategoryID string
+	UploadedAt time.Time
+	ArchivedAt *time.Time
+	LegalHold  bool
+	Previews   []string
+}
+
+// Item is what a run did, or would do, to one file.
+type Item struct {
+	FUUID      string `json:"f_uuid"`
+	Name       string `json:"f_name"`
+	PolicyID   string `json:"policy_id"`
+	PolicyName string `json:"policy_name"`
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Counts sums up a run. QuickShares and ProcessingResults count purged (or,
+// in a dry run, purgeable) rows.
+type Counts struct {
+	Archived          int `json:"archived"`
+	Purged            int `json:"purged"`
+	Held              int `json:"held"`
+	Failed            int `json:"failed"`
+	QuickShares       int `json:"quick_shares"`
+	ProcessingResults int `json:"processing_results"`
+}
+
+// Run statuses. A run is stored as RunRunning when it starts.
+const (
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed"
+	// RunInterrupted runs were cut short by a restart.
+	RunInterrupted = "interrupted"
+)
+
+// Report describes one run. Error is set when the run stopped early;
+// Counts and Items are filled in once it is no longer running.
+type Report struct {
+	ID         string     `json:"run_id"`
+	Trigger    string     `json:"trigger"`
+	DryRun     bool       `json:"dry_run"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Counts     Counts     `json:"counts"`
+	Items      []Item     `json:"items"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// NormalizePolicy trims p's fields and checks them. It does not check that
+// the category or department exists.
+func NormalizePolicy(p *Policy) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Scope = strings.ToLower(strings.TrimSpace(p.Scope))
+	p.Action = strings.ToLower(strings.TrimSpace(p.Action))
+	p.CategoryID = strings.ToLower(strings.TrimSpace(p.CategoryID))
+	p.DUUID = strings.ToLower(strings.TrimSpace(p.DUUID))
+	if p.Name == "" {
+		return invalid("name is required")
+	}
+	if utf8.RuneCountInString(p.Name) > MaxNameLen {
+		return invalid("name must be at most %d characters", MaxNameLen)
+	}
+	if p.KeepDays < 1 || p.KeepDays > MaxKeepDays {
+		return invalid("keep_days must be between 1 and %d", MaxKeepDays)
+	}
+	if p.Action != ActionArchive && p.Action != ActionPurge {
+		return invalid("action must be %q or %q", ActionArchive, ActionPurge)
+	}
+	switch p.Scope {
+	case ScopeCategory:
+		if !uuidPattern.MatchString(p.CategoryID) {
+			return invalid("category_id must be a category UUID")
+		}
+		p.DUUID = ""
+	case ScopeDepartment:
+		if !uuidPattern.MatchString(p.DUUID) {
+			return invalid("d_uuid must be a department UUID")
+		}
+		p.CategoryID = ""
+	case ScopeQuickShare, ScopeProcessingResults:
+		if p.Action != ActionPurge {
+			return invalid("%s policies can only purge", p.Scope)
+		}
+		p.CategoryID, p.DUUID = "", ""
+	default:
+		return invalid("scope must be one of %s, %s, %s or %s", ScopeCategory, ScopeDepartment, ScopeQuickShare, ScopeProcessingResults)
+	}
+	return nil
+}
+
+// checkHoldReason trims a legal-hold reason; placing a hold must explain
+// itself.
+func checkHoldReason(reason string, hold bool) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if hold && reason == "" {
+		return "", invalid("reason is required when placing a legal hold")
+	}
+	if utf8.RuneCountInString(reason) > MaxHoldReasonLen {
+		return "", invalid("reason must be at most %d characters", MaxHoldReasonLen)
+	}
+	return reason, nil
+}
+
+// Matches reports whether file policy p governs f.
+func (p Policy) Matches(f File) bool {
+	switch p.Scope {
+	case ScopeCategory:
+		return f.CategoryID != "" && strings.EqualFold(p.CategoryID, f.CategoryID)
+	case ScopeDepartment:
+		return f.DUUID != "" && strings.EqualFold(p.DUUID, f.DUUID)
+	}
+	return false
+}
+
+// Governing picks the policy that decides f's fate among the enabled
+// policies matching it: the one keeping it longest, and archive over purge
+// when two keep it equally long. It returns nil when none match.
+func Governing(policies []Policy, f File) *Policy {
+	var best *Policy
+	for i := range policies {
+		p := &policies[i]
+		if !p.Enabled || !p.Matches(f) {
+			continue
+		}
+		if best == nil || p.KeepDays > best.KeepDays ||
+			(p.KeepDays == best.KeepDays && p.Action == ActionArchive && best.Action == ActionPurge) {
+			best = p
+		}
+	}
+	return best
+}
+
+// Expires is when a record kept under p since at runs out.
+func (p Policy) Expires(at time.Time) time.Time {
+	return at.AddDate(0, 0, p.KeepDays)
+}
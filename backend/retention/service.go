@@ -0,0 +1,388 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists policies, runs and the records they govern.
+// Implementations: NewPostgresStore and NewMemoryStore.
+type Store interface {
+	Policies() ([]Policy, error)
+	// Policy returns nil when the policy does not exist.
+	Policy(id string) (*Policy, error)
+	CreatePolicy(p Policy) (Policy, error)
+	// UpdatePolicy and DeletePolicy return ErrNotFound for unknown
+	// policies.
+	UpdatePolicy(p Policy) (Policy, error)
+	DeletePolicy(id string) error
+	CategoryExists(id string) (bool, error)
+	DepartmentExists(dUUID string) (bool, error)
+
+	// Files lists the files uploaded before the cutoff.
+	Files(uploadedBefore time.Time) ([]File, error)
+	// File returns nil when the file does not exist.
+	File(fuuid string) (*File, error)
+	// MarkArchived and PurgeFile return ErrHeld if the file is under legal
+	// hold by the time they run.
+	MarkArchived(fuuid string, at time.Time) error
+	// PurgeFile deletes the file row together with its OCR and summary
+	// rows, department links and notifications.
+	PurgeFile(fuuid string) error
+	SetLegalHold(fuuid string, hold bool, reason, by string, at time.Time) error
+	// PurgeQuickShares and PurgeProcessingResults delete the rows created
+	// before the cutoff and return how many there were; with dryRun they
+	// only count them.
+	PurgeQuickShares(before time.Time, dryRun bool) (int, error)
+	PurgeProcessingResults(before time.Time, dryRun bool) (int, error)
+
+	// CreateRun stores a run as it starts and returns it with its ID.
+	CreateRun(r Report) (Report, error)
+	// FinishRun stores a run's outcome.
+	FinishRun(r Report) error
+	// Runs returns the latest runs, newest first, without their items.
+	Runs(limit int) ([]Report, error)
+	// Run returns nil when the run does not exist.
+	Run(id string) (*Report, error)
+}
+
+// Storage holds file objects. The live bucket serves downloads; archived
+// objects sit in the archive bucket.
+type Storage interface {
+	// Archive moves the object at path from the live bucket to the archive
+	// bucket; Unarchive moves it back.
+	Archive(path string) error
+	Unarchive(path string) error
+	// Delete removes objects from the archive bucket when archived is set,
+	// and from the live bucket otherwise.
+	Delete(paths []string, archived bool) error
+}
+
+type Service struct {
+	store   Store
+	storage Storage
+	Now     func() time.Time
+
+	// running keeps a manual run from overlapping the scheduled one.
+	running sync.Mutex
+}
+
+func NewService(store Store, storage Storage) *Service {
+	return &Service{store: store, storage: storage, Now: time.Now}
+}
+
+// ---------------------------------------------------------------------------
+// Policies (admin)
+// ---------------------------------------------------------------------------
+
+func (s *Service) Policies() ([]Policy, error) {
+	list, err := s.store.Policies()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *Service) checkPolicy(p *Policy) error {
+	if err := NormalizePolicy(p); err != nil {
+		return err
+	}
+	switch p.Scope {
+	case ScopeCategory:
+		ok, err := s.store.CategoryExists(p.CategoryID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return invalid("category %s does not exist", p.CategoryID)
+		}
+	case ScopeDepartment:
+		ok, err := s.store.DepartmentExists(p.DUUID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return invalid("department %s does not exist", p.DUUID)
+		}
+	}
+	return nil
+}
+
+// CreatePolicy validates and stores a policy. createdBy identifies the
+// admin.
+func (s *Service) CreatePolicy(p Policy, createdBy string) (Policy, error) {
+	if err := s.checkPolicy(&p); err != nil {
+		return Policy{}, err
+	}
+	p.ID, p.CreatedBy = "", createdBy
+	return s.store.CreatePolicy(p)
+}
+
+// UpdatePolicy replaces policy id and returns it as it was, for auditing.
+// The change applies from the next run.
+func (s *Service) UpdatePolicy(id string, p Policy) (before, after Policy, err error) {
+	old, err := s.store.Policy(id)
+	if err != nil {
+		return Policy{}, Policy{}, err
+	}
+	if old == nil {
+		return Policy{}, Policy{}, ErrNotFound
+	}
+	if err := s.checkPolicy(&p); err != nil {
+		return Policy{}, Policy{}, err
+	}
+	p.ID, p.CreatedBy, p.CreatedAt = old.ID, old.CreatedBy, old.CreatedAt
+	after, err = s.store.UpdatePolicy(p)
+	return *old, after, err
+}
+
+func (s *Service) DeletePolicy(id string) error {
+	return s.store.DeletePolicy(id)
+}
+
+// SetLegalHold places or lifts a legal hold on a file. A reason is required
+// to place one.
+func (s *Service) SetLegalHold(fuuid string, hold bool, reason, by string) (File, error) {
+	reason, err := checkHoldReason(reason, hold)
+	if err != nil {
+		return File{}, err
+	}
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return File{}, err
+	}
+	if f == nil {
+		return File{}, ErrFileNotFound
+	}
+	if err := s.store.SetLegalHold(f.FUUID, hold, reason, by, s.Now()); err != nil {
+		return File{}, err
+	}
+	f.LegalHold = hold
+	return *f, nil
+}
+
+// ---------------------------------------------------------------------------
+// Runs
+// ---------------------------------------------------------------------------
+
+func (s *Service) Runs(limit int) ([]Report, error) {
+	return s.store.Runs(limit)
+}
+
+func (s *Service) Run(id string) (Report, error) {
+	r, err := s.store.Run(id)
+	if err != nil {
+		return Report{}, err
+	}
+	if r == nil {
+		return Report{}, ErrRunNotFound
+	}
+	return *r, nil
+}
+
+// longestKeep returns the longest keep_days among the enabled policies of
+// scope, or 0 when there are none.
+func longestKeep(policies []Policy, scope string) int {
+	keep := 0
+	for _, p := range policies {
+		if p.Enabled && p.Scope == scope && p.KeepDays > keep {
+			keep = p.KeepDays
+		}
+	}
+	return keep
+}
+
+// shortestFileKeep returns the shortest keep_days among the enabled file
+// policies, or 0 when there are none. No file younger than that can be due.
+func shortestFileKeep(policies []Policy) int {
+	keep := 0
+	for _, p := range policies {
+		if !p.Enabled || (p.Scope != ScopeCategory && p.Scope != ScopeDepartment) {
+			continue
+		}
+		if keep == 0 || p.KeepDays < keep {
+			keep = p.KeepDays
+		}
+	}
+	return keep
+}
+
+// Execute applies every enabled policy once and stores the report. With
+// dryRun nothing is changed and the report says what would have been. A
+// report is stored even when the run stops early; its Error says why.
+func (s *Service) Execute(trigger string, dryRun bool) (Report, error) {
+	rep, err := s.begin(trigger, dryRun)
+	if err != nil {
+		return rep, err
+	}
+	return s.finish(rep)
+}
+
+// Start stores a new run and carries it out in the background, returning
+// the run while it is still RunRunning; Run(id) has the outcome once it
+// ends. done, when not nil, is called with the finished report.
+func (s *Service) Start(trigger string, dryRun bool, done func(Report, error)) (Report, error) {
+	rep, err := s.begin(trigger, dryRun)
+	if err != nil {
+		return rep, err
+	}
+	go func() {
+		final, err := s.finish(rep)
+		if done != nil {
+			done(final, err)
+		}
+	}()
+	return rep, nil
+}
+
+// begin takes the run lock and stores the run; finish releases the lock.
+func (s *Service) begin(trigger string, dryRun bool) (Report, error) {
+	if !s.running.TryLock() {
+		return Report{}, ErrRunning
+	}
+	rep := Report{Trigger: trigger, DryRun: dryRun, Status: RunRunning, StartedAt: s.Now(), Items: []Item{}}
+	saved, err := s.store.CreateRun(rep)
+	if err != nil {
+		s.running.Unlock()
+		return rep, err
+	}
+	return saved, nil
+}
+
+func (s *Service) finish(rep Report) (Report, error) {
+	defer s.running.Unlock()
+	runErr := s.apply(&rep, rep.StartedAt)
+	rep.Status = RunDone
+	if runErr != nil {
+		rep.Status, rep.Error = RunFailed, runErr.Error()
+	}
+	finished := s.Now()
+	rep.FinishedAt = &finished
+	if err := s.store.FinishRun(rep); err != nil {
+		return rep, err
+	}
+	return rep, runErr
+}
+
+func (s *Service) apply(rep *Report, now time.Time) error {
+	policies, err := s.store.Policies()
+	if err != nil {
+		return err
+	}
+	if keep := shortestFileKeep(policies); keep > 0 {
+		files, err := s.store.Files(now.AddDate(0, 0, -keep))
+		if err != nil {
+			return err
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].UploadedAt.Before(files[j].UploadedAt) })
+		for _, f := range files {
+			p := Governing(policies, f)
+			if p == nil || now.Before(p.Expires(f.UploadedAt)) {
+				continue
+			}
+			if p.Action == ActionArchive && f.ArchivedAt != nil {
+				continue
+			}
+			item := Item{FUUID: f.FUUID, Name: f.Name, PolicyID: p.ID, PolicyName: p.Name, Action: p.Action}
+			switch {
+			case f.LegalHold:
+				item.Outcome = OutcomeHeld
+			case rep.DryRun && p.Action == ActionArchive:
+				item.Outcome = OutcomeWouldArchive
+			case rep.DryRun:
+				item.Outcome = OutcomeWouldPurge
+			case p.Action == ActionArchive:
+				s.archive(f, &item, now)
+			default:
+				s.purge(f, &item)
+			}
+			switch item.Outcome {
+			case OutcomeArchived:
+				rep.Counts.Archived++
+			case OutcomePurged:
+				rep.Counts.Purged++
+			case OutcomeHeld:
+				rep.Counts.Held++
+			case OutcomeFailed:
+				rep.Counts.Failed++
+			}
+			rep.Items = append(rep.Items, item)
+		}
+	}
+	if keep := longestKeep(policies, ScopeQuickShare); keep > 0 {
+		n, err := s.store.PurgeQuickShares(now.AddDate(0, 0, -keep), rep.DryRun)
+		if err != nil {
+			return err
+		}
+		rep.Counts.QuickShares = n
+	}
+	if keep := longestKeep(policies, ScopeProcessingResults); keep > 0 {
+		n, err := s.store.PurgeProcessingResults(now.AddDate(0, 0, -keep), rep.DryRun)
+		if err != nil {
+			return err
+		}
+		rep.Counts.ProcessingResults = n
+	}
+	return nil
+}
+
+// archive moves f's object to the archive bucket and marks the row. If the
+// row cannot be marked the object is moved back. Previews are dropped;
+// leftovers are noted but do not fail the item.
+func (s *Service) archive(f File, item *Item, now time.Time) {
+	if f.FilePath != "" {
+		if err := s.storage.Archive(f.FilePath); err != nil {
+			item.Outcome, item.Error = OutcomeFailed, fmt.Sprintf("moving object: %v", err)
+			return
+		}
+	}
+	if err := s.store.MarkArchived(f.FUUID, now); err != nil {
+		item.Outcome, item.Error = OutcomeFailed, fmt.Sprintf("marking archived: %v", err)
+		if errors.Is(err, ErrHeld) {
+			item.Outcome, item.Error = OutcomeHeld, ""
+		}
+		if f.FilePath != "" {
+			if err := s.storage.Unarchive(f.FilePath); err != nil {
+				item.Error += fmt.Sprintf("; moving object back: %v", err)
+			}
+		}
+		return
+	}
+	item.Outcome = OutcomeArchived
+	if len(f.Previews) > 0 {
+		if err := s.storage.Delete(f.Previews, false); err != nil {
+			item.Error = fmt.Sprintf("previews left behind: %v", err)
+		}
+	}
+}
+
+// purge deletes f's rows, then its objects. Once the rows are gone the file
+// counts as purged; objects that could not be deleted are noted for the
+// reconciler.
+func (s *Service) purge(f File, item *Item) {
+	if err := s.store.PurgeFile(f.FUUID); errors.Is(err, ErrHeld) {
+		item.Outcome = OutcomeHeld
+		return
+	} else if err != nil {
+		item.Outcome, item.Error = OutcomeFailed, fmt.Sprintf("deleting rows: %v", err)
+		return
+	}
+	item.Outcome = OutcomePurged
+	var failed []string
+	if f.FilePath != "" {
+		if err := s.storage.Delete([]string{f.FilePath}, f.ArchivedAt != nil); err != nil {
+			failed = append(failed, fmt.Sprintf("object left behind: %v", err))
+		}
+	}
+	if len(f.Previews) > 0 && f.ArchivedAt == nil {
+		if err := s.storage.Delete(f.Previews, false); err != nil {
+			failed = append(failed, fmt.Sprintf("previews left behind: %v", err))
+		}
+	}
+	item.Error = strings.Join(failed, "; ")
+}
@@ -0,0 +1,339 @@
+package retention
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	catSafety  = "11111111-1111-1111-1111-111111111111"
+	catHR      = "22222222-2222-2222-2222-222222222222"
+	deptOps    = "33333333-3333-3333-3333-333333333333"
+	deptStores = "44444444-4444-4444-4444-444444444444"
+)
+
+var now = time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+
+func daysAgo(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+func newTestService(t *testing.T) (*Service, *MemoryStore, *MemoryStorage) {
+	t.Helper()
+	store := NewMemoryStore()
+	store.AddCategory(catSafety)
+	store.AddCategory(catHR)
+	store.AddDepartment(deptOps)
+	store.AddDepartment(deptStores)
+	storage := NewMemoryStorage()
+	svc := NewService(store, storage)
+	svc.Now = func() time.Time { return now }
+	return svc, store, storage
+}
+
+func addFile(store *MemoryStore, storage *MemoryStorage, f File) {
+	f.FilePath = f.FUUID + ".pdf"
+	f.Previews = []string{f.FilePath + ".preview/thumbnail.jpg"}
+	storage.Live[f.FilePath] = true
+	storage.Live[f.Previews[0]] = true
+	store.AddFile(f)
+}
+
+func mustPolicy(t *testing.T, svc *Service, p Policy) Policy {
+	t.Helper()
+	p.Enabled = true
+	created, err := svc.CreatePolicy(p, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func outcomes(rep Report) map[string]string {
+	out := map[string]string{}
+	for _, it := range rep.Items {
+		out[it.FUUID] = it.Outcome
+	}
+	return out
+}
+
+func TestPolicyValidation(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	bad := []Policy{
+		{Name: " ", Scope: ScopeQuickShare, KeepDays: 90, Action: ActionPurge},
+		{Name: "a", Scope: ScopeQuickShare, KeepDays: 0, Action: ActionPurge},
+		{Name: "a", Scope: ScopeQuickShare, KeepDays: MaxKeepDays + 1, Action: ActionPurge},
+		{Name: "a", Scope: ScopeQuickShare, KeepDays: 90, Action: ActionArchive},
+		{Name: "a", Scope: ScopeQuickShare, KeepDays: 90, Action: "shred"},
+		{Name: "a", Scope: "everything", KeepDays: 90, Action: ActionPurge},
+		{Name: "a", Scope: ScopeCategory, KeepDays: 90, Action: ActionPurge},
+		{Name: "a", Scope: ScopeCategory, CategoryID: "99999999-9999-9999-9999-999999999999", KeepDays: 90, Action: ActionPurge},
+		{Name: "a", Scope: ScopeDepartment, DUUID: "ops", KeepDays: 90, Action: ActionPurge},
+	}
+	for i, p := range bad {
+		if _, err := svc.CreatePolicy(p, "admin-1"); !errors.As(err, new(ValidationError)) {
+			t.Errorf("policy %d: got %v, want a validation error", i, err)
+		}
+	}
+
+	p, err := svc.CreatePolicy(Policy{Name: " Safety ", Scope: " Category ", CategoryID: catSafety, DUUID: deptOps, KeepDays: 3650, Action: "ARCHIVE"}, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Safety" || p.Scope != ScopeCategory || p.Action != ActionArchive || p.DUUID != "" || p.CreatedBy != "admin-1" {
+		t.Errorf("policy not normalized: %+v", p)
+	}
+}
+
+func TestGoverningPrefersLongestRetention(t *testing.T) {
+	policies := []Policy{
+		{ID: "dept", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 365, Action: ActionPurge, Enabled: true},
+		{ID: "safety", Scope: ScopeCategory, CategoryID: catSafety, KeepDays: 3650, Action: ActionPurge, Enabled: true},
+		{ID: "safety-archive", Scope: ScopeCategory, CategoryID: catSafety, KeepDays: 3650, Action: ActionArchive, Enabled: true},
+		{ID: "off", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 9000, Action: ActionPurge},
+	}
+	if p := Governing(policies, File{DUUID: deptOps, CategoryID: catSafety}); p == nil || p.ID != "safety-archive" {
+		t.Errorf("safety file governed by %+v, want safety-archive", p)
+	}
+	if p := Governing(policies, File{DUUID: deptOps, CategoryID: catHR}); p == nil || p.ID != "dept" {
+		t.Errorf("HR file governed by %+v, want dept", p)
+	}
+	if p := Governing(policies, File{DUUID: deptStores}); p != nil {
+		t.Errorf("unmatched file governed by %+v", p)
+	}
+}
+
+func TestRunArchivesAndPurgesExpiredFiles(t *testing.T) {
+	svc, store, storage := newTestService(t)
+	mustPolicy(t, svc, Policy{Name: "Safety records", Scope: ScopeCategory, CategoryID: catSafety, KeepDays: 3650, Action: ActionArchive})
+	mustPolicy(t, svc, Policy{Name: "Ops working files", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 365, Action: ActionPurge})
+
+	addFile(store, storage, File{FUUID: "incident-old", DUUID: deptOps, CategoryID: catSafety, UploadedAt: daysAgo(3651)})
+	addFile(store, storage, File{FUUID: "incident-new", DUUID: deptOps, CategoryID: catSafety, UploadedAt: daysAgo(400)})
+	addFile(store, storage, File{FUUID: "roster-old", DUUID: deptOps, CategoryID: catHR, UploadedAt: daysAgo(400)})
+	addFile(store, storage, File{FUUID: "roster-new", DUUID: deptOps, UploadedAt: daysAgo(10)})
+	addFile(store, storage, File{FUUID: "stores-old", DUUID: deptStores, UploadedAt: daysAgo(5000)})
+	addFile(store, storage, File{FUUID: "held", DUUID: deptOps, UploadedAt: daysAgo(400), LegalHold: true})
+
+	rep, err := svc.Execute(TriggerSchedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"incident-old": OutcomeArchived, "roster-old": OutcomePurged, "held": OutcomeHeld}
+	if got := outcomes(rep); !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes = %v, want %v", got, want)
+	}
+	if rep.Counts != (Counts{Archived: 1, Purged: 1, Held: 1}) {
+		t.Errorf("counts = %+v", rep.Counts)
+	}
+
+	if f, _ := store.File("incident-old"); f == nil || f.ArchivedAt == nil {
+		t.Errorf("incident-old not marked archived: %+v", f)
+	}
+	if !storage.Archived["incident-old.pdf"] || storage.Live["incident-old.pdf"] || storage.Live["incident-old.pdf.preview/thumbnail.jpg"] {
+		t.Errorf("incident-old objects not moved: live=%v archived=%v", storage.Live, storage.Archived)
+	}
+	if f, _ := store.File("roster-old"); f != nil {
+		t.Error("roster-old row was not purged")
+	}
+	if storage.Live["roster-old.pdf"] || storage.Live["roster-old.pdf.preview/thumbnail.jpg"] {
+		t.Error("roster-old objects were not deleted")
+	}
+	if f, _ := store.File("held"); f == nil || !storage.Live["held.pdf"] {
+		t.Error("a file under legal hold was touched")
+	}
+
+	// Archived files are not archived again.
+	rep, err = svc.Execute(TriggerSchedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outcomes(rep); !reflect.DeepEqual(got, map[string]string{"held": OutcomeHeld}) {
+		t.Errorf("second run outcomes = %v", got)
+	}
+}
+
+func TestPurgeOfArchivedFileDeletesFromArchive(t *testing.T) {
+	svc, store, storage := newTestService(t)
+	p := mustPolicy(t, svc, Policy{Name: "Safety", Scope: ScopeCategory, CategoryID: catSafety, KeepDays: 30, Action: ActionArchive})
+	addFile(store, storage, File{FUUID: "f1", CategoryID: catSafety, UploadedAt: daysAgo(40)})
+	if _, err := svc.Execute(TriggerSchedule, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Action = ActionPurge
+	if _, _, err := svc.UpdatePolicy(p.ID, p); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := svc.Execute(TriggerSchedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outcomes(rep); got["f1"] != OutcomePurged {
+		t.Errorf("outcomes = %v", got)
+	}
+	if storage.Archived["f1.pdf"] {
+		t.Error("archived object was not deleted")
+	}
+}
+
+func TestDryRunChangesNothing(t *testing.T) {
+	svc, store, storage := newTestService(t)
+	mustPolicy(t, svc, Policy{Name: "Ops", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 30, Action: ActionPurge})
+	mustPolicy(t, svc, Policy{Name: "Quick shares", Scope: ScopeQuickShare, KeepDays: 90, Action: ActionPurge})
+	addFile(store, storage, File{FUUID: "f1", DUUID: deptOps, UploadedAt: daysAgo(31)})
+	store.AddQuickShare(daysAgo(91))
+	store.AddQuickShare(daysAgo(89))
+
+	rep, err := svc.Execute(TriggerManual, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outcomes(rep); got["f1"] != OutcomeWouldPurge {
+		t.Errorf("outcomes = %v", got)
+	}
+	if rep.Counts.QuickShares != 1 || rep.Counts.Purged != 0 {
+		t.Errorf("counts = %+v", rep.Counts)
+	}
+	if f, _ := store.File("f1"); f == nil || !storage.Live["f1.pdf"] {
+		t.Error("dry run deleted the file")
+	}
+	if n, _ := store.PurgeQuickShares(daysAgo(90), true); n != 1 {
+		t.Errorf("dry run deleted quick shares; %d left to purge", n)
+	}
+
+	saved, err := svc.Run(rep.ID)
+	if err != nil || !saved.DryRun || saved.Trigger != TriggerManual || len(saved.Items) != 1 {
+		t.Errorf("stored report = %+v, %v", saved, err)
+	}
+}
+
+func TestQuickSharesAndProcessingResultsUseLongestPolicy(t *testing.T) {
+	svc, store, _ := newTestService(t)
+	mustPolicy(t, svc, Policy{Name: "Quick shares", Scope: ScopeQuickShare, KeepDays: 30, Action: ActionPurge})
+	mustPolicy(t, svc, Policy{Name: "Quick shares (audit)", Scope: ScopeQuickShare, KeepDays: 90, Action: ActionPurge})
+	mustPolicy(t, svc, Policy{Name: "Processing results", Scope: ScopeProcessingResults, KeepDays: 7, Action: ActionPurge})
+	store.AddQuickShare(daysAgo(60))
+	store.AddQuickShare(daysAgo(100))
+	store.AddProcessingResult(daysAgo(8))
+	store.AddProcessingResult(daysAgo(1))
+
+	rep, err := svc.Execute(TriggerSchedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Counts.QuickShares != 1 || rep.Counts.ProcessingResults != 1 {
+		t.Errorf("counts = %+v", rep.Counts)
+	}
+}
+
+func TestArchiveMovesObjectBackOnFailure(t *testing.T) {
+	svc, store, storage := newTestService(t)
+	mustPolicy(t, svc, Policy{Name: "Ops", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 30, Action: ActionArchive})
+	addFile(store, storage, File{FUUID: "f1", DUUID: deptOps, UploadedAt: daysAgo(31)})
+	addFile(store, storage, File{FUUID: "f2", DUUID: deptOps, UploadedAt: daysAgo(32)})
+	storage.Fail["f2.pdf"] = true
+	// f1 goes on hold between listing and marking.
+	listed, _ := store.File("f1")
+	if err := store.SetLegalHold("f1", true, "litigation", "admin-1", now); err != nil {
+		t.Fatal(err)
+	}
+	item := Item{}
+	svc.archive(*listed, &item, now)
+	if item.Outcome != OutcomeHeld || !storage.Live["f1.pdf"] || storage.Archived["f1.pdf"] {
+		t.Errorf("held file: item=%+v live=%v archived=%v", item, storage.Live, storage.Archived)
+	}
+
+	rep, err := svc.Execute(TriggerSchedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outcomes(rep); got["f2"] != OutcomeFailed || rep.Counts.Failed != 1 {
+		t.Errorf("outcomes = %v, counts = %+v", got, rep.Counts)
+	}
+}
+
+func TestLegalHold(t *testing.T) {
+	svc, store, storage := newTestService(t)
+	addFile(store, storage, File{FUUID: "f1", DUUID: deptOps, UploadedAt: daysAgo(1)})
+
+	if _, err := svc.SetLegalHold("f1", true, " ", "admin-1"); !errors.As(err, new(ValidationError)) {
+		t.Errorf("hold without reason: %v", err)
+	}
+	if _, err := svc.SetLegalHold("missing", true, "litigation", "admin-1"); err != ErrFileNotFound {
+		t.Errorf("hold on missing file: %v", err)
+	}
+	f, err := svc.SetLegalHold("f1", true, "Litigation 12/2025", "admin-1")
+	if err != nil || !f.LegalHold {
+		t.Fatalf("hold: %+v, %v", f, err)
+	}
+	if f, err := svc.SetLegalHold("f1", false, "", "admin-1"); err != nil || f.LegalHold {
+		t.Errorf("release: %+v, %v", f, err)
+	}
+}
+
+func TestRunsDoNotOverlap(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	svc.running.Lock()
+	if _, err := svc.Execute(TriggerManual, false); err != ErrRunning {
+		t.Errorf("overlapping run: %v", err)
+	}
+	svc.running.Unlock()
+	if _, err := svc.Execute(TriggerManual, false); err != nil {
+		t.Error(err)
+	}
+	runs, _ := svc.Runs(10)
+	if len(runs) != 1 || runs[0].Status != RunDone || runs[0].FinishedAt == nil {
+		t.Errorf("runs = %+v, want one finished run", runs)
+	}
+}
+
+// blockingStore holds Policies until release is closed.
+type blockingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (b blockingStore) Policies() ([]Policy, error) {
+	<-b.release
+	return b.MemoryStore.Policies()
+}
+
+func TestStartRunsInBackground(t *testing.T) {
+	setup, store, storage := newTestService(t)
+	mustPolicy(t, setup, Policy{Name: "Ops working files", Scope: ScopeDepartment, DUUID: deptOps, KeepDays: 365, Action: ActionPurge})
+	addFile(store, storage, File{FUUID: "roster-old", DUUID: deptOps, UploadedAt: daysAgo(400)})
+
+	release := make(chan struct{})
+	svc := NewService(blockingStore{store, release}, storage)
+	svc.Now = func() time.Time { return now }
+	done := make(chan Report, 1)
+	rep, err := svc.Start(TriggerManual, false, func(r Report, err error) {
+		if err != nil {
+			t.Errorf("run failed: %v", err)
+		}
+		done <- r
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.ID == "" || rep.Status != RunRunning || rep.FinishedAt != nil {
+		t.Fatalf("expected a stored running run, got %+v", rep)
+	}
+	if got, _ := svc.Run(rep.ID); got.Status != RunRunning {
+		t.Fatalf("run should be pollable while running, got %+v", got)
+	}
+	if _, err := svc.Start(TriggerManual, true, nil); !errors.Is(err, ErrRunning) {
+		t.Fatalf("expected ErrRunning, got %v", err)
+	}
+
+	close(release)
+	<-done
+	got, err := svc.Run(rep.ID)
+	if err != nil || got.Status != RunDone || got.Counts.Purged != 1 {
+		t.Fatalf("unexpected finished run: %+v %v", got, err)
+	}
+	if f, _ := store.File("roster-old"); f != nil {
+		t.Error("the background run should have purged roster-old")
+	}
+}
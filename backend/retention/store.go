@@ -0,0 +1,536 @@
+package retention
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/models"
+	"backend/preview"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (retention_policies, retention_runs, file)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func policyFromRow(r models.RetentionPolicyRow) Policy {
+	return Policy{
+		ID: r.PolicyID, Name: r.Name, Scope: r.Scope, CategoryID: r.CategoryID, DUUID: r.DUUID, KeepDays: r.KeepDays,
+		Action: r.Action, Enabled: r.Enabled, CreatedBy: r.CreatedBy, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func policyRow(p Policy) models.RetentionPolicyRow {
+	return models.RetentionPolicyRow{
+		PolicyID: p.ID, Name: p.Name, Scope: p.Scope, CategoryID: p.CategoryID, DUUID: p.DUUID, KeepDays: p.KeepDays,
+		Action: p.Action, Enabled: p.Enabled, CreatedBy: p.CreatedBy,
+	}
+}
+
+// fileFromRow lists the file's rendered previews by their storage paths.
+func fileFromRow(r models.RetentionFile) File {
+	f := File{
+		FUUID: r.FUUID, Name: r.FileName, FilePath: r.FilePath, DUUID: r.DUUID, CategoryID: r.CategoryID,
+		UploadedAt: r.UploadedAt, ArchivedAt: r.ArchivedAt, LegalHold: r.LegalHold,
+	}
+	if r.FilePath == "" {
+		return f
+	}
+	if r.HasThumbnail {
+		f.Previews = append(f.Previews, preview.ThumbnailPath(r.FilePath))
+	}
+	for _, n := range r.PreviewPages {
+		f.Previews = append(f.Previews, preview.PagePath(r.FilePath, n))
+	}
+	return f
+}
+
+func (s pgStore) Policies() ([]Policy, error) {
+	rows, err := models.ListRetentionPolicies(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Policy, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, policyFromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Policy(id string) (*Policy, error) {
+	r, err := models.GetRetentionPolicy(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	p := policyFromRow(*r)
+	return &p, nil
+}
+
+func (s pgStore) CreatePolicy(p Policy) (Policy, error) {
+	row, err := models.InsertRetentionPolicy(s.db, policyRow(p))
+	if err != nil {
+		return Policy{}, err
+	}
+	p.ID, p.CreatedAt, p.UpdatedAt = row.PolicyID, row.CreatedAt, row.UpdatedAt
+	return p, nil
+}
+
+func (s pgStore) UpdatePolicy(p Policy) (Policy, error) {
+	row, err := models.UpdateRetentionPolicy(s.db, policyRow(p))
+	if err == sql.ErrNoRows {
+		return Policy{}, ErrNotFound
+	}
+	if err != nil {
+		return Policy{}, err
+	}
+	p.UpdatedAt = row.UpdatedAt
+	return p, nil
+}
+
+func (s pgStore) DeletePolicy(id string) error {
+	if err := models.DeleteRetentionPolicy(s.db, id); err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) CategoryExists(id string) (bool, error) {
+	c, err := models.GetDocumentCategory(s.db, id)
+	return c != nil, err
+}
+
+func (s pgStore) DepartmentExists(dUUID string) (bool, error) {
+	d, err := models.GetDepartmentByUUID(s.db, dUUID)
+	return d != nil, err
+}
+
+func (s pgStore) Files(uploadedBefore time.Time) ([]File, error) {
+	rows, err := models.ListRetentionFiles(s.db, uploadedBefore)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]File, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fileFromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) File(fuuid string) (*File, error) {
+	r, err := models.GetRetentionFile(s.db, fuuid)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	f := fileFromRow(*r)
+	return &f, nil
+}
+
+func (s pgStore) MarkArchived(fuuid string, at time.Time) error {
+	if err := models.MarkFileArchived(s.db, fuuid, at); err == sql.ErrNoRows {
+		return ErrHeld
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) PurgeFile(fuuid string) error {
+	if err := models.PurgeFile(s.db, fuuid); err == sql.ErrNoRows {
+		return ErrHeld
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) SetLegalHold(fuuid string, hold bool, reason, by string, at time.Time) error {
+	if err := models.SetFileLegalHold(s.db, fuuid, hold, reason, by, at); err == sql.ErrNoRows {
+		return ErrFileNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) PurgeQuickShares(before time.Time, dryRun bool) (int, error) {
+	return models.PurgeQuickShares(s.db, before, dryRun)
+}
+
+func (s pgStore) PurgeProcessingResults(before time.Time, dryRun bool) (int, error) {
+	return models.PurgeProcessingResults(s.db, before, dryRun)
+}
+
+func (s pgStore) CreateRun(r Report) (Report, error) {
+	row, err := models.InsertRetentionRun(s.db, models.RetentionRunRow{
+		Trigger: r.Trigger, DryRun: r.DryRun, StartedAt: r.StartedAt,
+	})
+	if err != nil {
+		return r, err
+	}
+	r.ID = row.RunID
+	return r, nil
+}
+
+func (s pgStore) FinishRun(r Report) error {
+	counts, err := json.Marshal(r.Counts)
+	if err != nil {
+		return err
+	}
+	items, err := json.Marshal(r.Items)
+	if err != nil {
+		return err
+	}
+	return models.FinishRetentionRun(s.db, models.RetentionRunRow{
+		RunID: r.ID, Status: r.Status, FinishedAt: r.FinishedAt, Counts: counts, Items: items, Error: r.Error,
+	})
+}
+
+func runFromRow(r models.RetentionRunRow) (Report, error) {
+	rep := Report{
+		ID: r.RunID, Trigger: r.Trigger, DryRun: r.DryRun, Status: r.Status, StartedAt: r.StartedAt,
+		FinishedAt: r.FinishedAt, Error: r.Error,
+	}
+	if err := json.Unmarshal(r.Counts, &rep.Counts); err != nil {
+		return rep, err
+	}
+	if len(r.Items) > 0 {
+		if err := json.Unmarshal(r.Items, &rep.Items); err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+func (s pgStore) Runs(limit int) ([]Report, error) {
+	rows, err := models.ListRetentionRuns(s.db, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Report, 0, len(rows))
+	for _, r := range rows {
+		rep, err := runFromRow(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+func (s pgStore) Run(id string) (*Report, error) {
+	r, err := models.GetRetentionRun(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	rep, err := runFromRow(*r)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store and storage, for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu                sync.Mutex
+	categories        map[string]bool
+	departments       map[string]bool
+	policies          map[string]Policy
+	files             map[string]File
+	quickShares       []time.Time
+	processingResults []time.Time
+	runs              []Report
+	seq               int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		categories:  map[string]bool{},
+		departments: map[string]bool{},
+		policies:    map[string]Policy{},
+		files:       map[string]File{},
+	}
+}
+
+func (m *MemoryStore) AddCategory(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.categories[id] = true
+}
+
+func (m *MemoryStore) AddDepartment(dUUID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.departments[dUUID] = true
+}
+
+func (m *MemoryStore) AddFile(f File) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[f.FUUID] = f
+}
+
+func (m *MemoryStore) AddQuickShare(at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quickShares = append(m.quickShares, at)
+}
+
+func (m *MemoryStore) AddProcessingResult(at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processingResults = append(m.processingResults, at)
+}
+
+func (m *MemoryStore) Policies() ([]Policy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Policy{}
+	for _, p := range m.policies {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemoryStore) Policy(id string) (*Policy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.policies[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (m *MemoryStore) CreatePolicy(p Policy) (Policy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	p.ID = fmt.Sprintf("pol-%d", m.seq)
+	m.policies[p.ID] = p
+	return p, nil
+}
+
+func (m *MemoryStore) UpdatePolicy(p Policy) (Policy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.policies[p.ID]; !ok {
+		return Policy{}, ErrNotFound
+	}
+	m.policies[p.ID] = p
+	return p, nil
+}
+
+func (m *MemoryStore) DeletePolicy(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.policies[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.policies, id)
+	return nil
+}
+
+func (m *MemoryStore) CategoryExists(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.categories[id], nil
+}
+
+func (m *MemoryStore) DepartmentExists(dUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.departments[dUUID], nil
+}
+
+func (m *MemoryStore) Files(uploadedBefore time.Time) ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []File{}
+	for _, f := range m.files {
+		if f.UploadedAt.Before(uploadedBefore) {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) File(fuuid string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+func (m *MemoryStore) MarkArchived(fuuid string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok || f.LegalHold {
+		return ErrHeld
+	}
+	f.ArchivedAt, f.Previews = &at, nil
+	m.files[fuuid] = f
+	return nil
+}
+
+func (m *MemoryStore) PurgeFile(fuuid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok || f.LegalHold {
+		return ErrHeld
+	}
+	delete(m.files, fuuid)
+	return nil
+}
+
+func (m *MemoryStore) SetLegalHold(fuuid string, hold bool, reason, by string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return ErrFileNotFound
+	}
+	f.LegalHold = hold
+	m.files[fuuid] = f
+	return nil
+}
+
+func purgeTimes(list *[]time.Time, before time.Time, dryRun bool) int {
+	kept := []time.Time{}
+	for _, at := range *list {
+		if !at.Before(before) {
+			kept = append(kept, at)
+		}
+	}
+	n := len(*list) - len(kept)
+	if !dryRun {
+		*list = kept
+	}
+	return n
+}
+
+func (m *MemoryStore) PurgeQuickShares(before time.Time, dryRun bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return purgeTimes(&m.quickShares, before, dryRun), nil
+}
+
+func (m *MemoryStore) PurgeProcessingResults(before time.Time, dryRun bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return purgeTimes(&m.processingResults, before, dryRun), nil
+}
+
+func (m *MemoryStore) CreateRun(r Report) (Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	r.ID = fmt.Sprintf("run-%d", m.seq)
+	m.runs = append(m.runs, r)
+	return r, nil
+}
+
+func (m *MemoryStore) FinishRun(r Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.runs {
+		if m.runs[i].ID == r.ID && m.runs[i].Status == RunRunning {
+			m.runs[i] = r
+			return nil
+		}
+	}
+	return errors.New("run not found")
+}
+
+func (m *MemoryStore) Runs(limit int) ([]Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Report{}
+	for i := len(m.runs) - 1; i >= 0 && len(out) < limit; i-- {
+		r := m.runs[i]
+		r.Items = nil
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Run(id string) (*Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.runs {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// MemoryStorage keeps object paths per bucket. Fail makes every call
+// touching that path fail.
+type MemoryStorage struct {
+	mu       sync.Mutex
+	Live     map[string]bool
+	Archived map[string]bool
+	Fail     map[string]bool
+}
+
+func NewMemoryStorage(paths ...string) *MemoryStorage {
+	s := &MemoryStorage{Live: map[string]bool{}, Archived: map[string]bool{}, Fail: map[string]bool{}}
+	for _, p := range paths {
+		s.Live[p] = true
+	}
+	return s
+}
+
+func (s *MemoryStorage) move(path string, from, to map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fail[path] {
+		return fmt.Errorf("storage unavailable for %s", path)
+	}
+	if !from[path] {
+		return fmt.Errorf("object %s not found", path)
+	}
+	delete(from, path)
+	to[path] = true
+	return nil
+}
+
+func (s *MemoryStorage) Archive(path string) error   { return s.move(path, s.Live, s.Archived) }
+func (s *MemoryStorage) Unarchive(path string) error { return s.move(path, s.Archived, s.Live) }
+
+func (s *MemoryStorage) Delete(paths []string, archived bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket := s.Live
+	if archived {
+		bucket = s.Archived
+	}
+	for _, p := range paths {
+		if s.Fail[p] {
+			return fmt.Errorf("storage unavailable for %s", p)
+		}
+	}
+	for _, p := range paths {
+		delete(bucket, p)
+	}
+	return nil
+}
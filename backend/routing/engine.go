@@ -0,0 +1,292 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Document is what rules are evaluated against.
+type Document struct {
+	FUUID string
+	// FileName is the title shown to users; OriginalName is the uploaded
+	// file name, used for doc_type rules.
+	FileName      string
+	OriginalName  string
+	Text          string
+	UploaderUUID  string
+	UploaderEmail string
+	UploaderDUUID string
+	// Departments the file is already shared with.
+	Departments []string
+	// Category is the document's stored classification, if any, and
+	// Taxonomy the enabled category names. llm rules naming a taxonomy
+	// category are decided from Category instead of asking the classifier
+	// again.
+	Category *Classification
+	Taxonomy []string
+}
+
+// categorized reports whether label is decided by doc's stored category.
+func (doc Document) categorized(label string) bool {
+	if doc.Category == nil {
+		return false
+	}
+	for _, t := range doc.Taxonomy {
+		if strings.EqualFold(t, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classification is the classifier's pick among the labels it was given.
+type Classification struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Classifier picks the label that best describes text. It returns an empty
+// Label when none fits.
+type Classifier interface {
+	Classify(ctx context.Context, text string, labels []string) (Classification, error)
+}
+
+// Result is the outcome of one rule for one document.
+type Result struct {
+	RuleID   string `json:"rule_id"`
+	RuleName string `json:"name"`
+	Type     string `json:"rule_type"`
+	DUUID    string `json:"d_uuid"`
+	Matched  bool   `json:"matched"`
+	Reason   string `json:"reason,omitempty"`
+	// AlreadyAssigned is set when the rule matched but the file is already
+	// shared with its department, so applying it changes nothing.
+	AlreadyAssigned bool `json:"already_assigned,omitempty"`
+}
+
+// Sort orders rules by priority, then name.
+func Sort(rules []Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return strings.ToLower(rules[i].Name) < strings.ToLower(rules[j].Name)
+	})
+}
+
+// Evaluate runs the enabled rules against doc and returns one result per
+// rule, in priority order. The classifier is called at most once, with the
+// labels of every llm rule not decided by doc's category; a nil classifier
+// leaves those rules unmatched.
+func Evaluate(ctx context.Context, doc Document, rules []Rule, classifier Classifier) []Result {
+	enabled := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Enabled {
+			enabled = append(enabled, r)
+		}
+	}
+	Sort(enabled)
+
+	assigned := map[string]bool{}
+	for _, d := range doc.Departments {
+		assigned[strings.ToLower(d)] = true
+	}
+	text := normalizeText(doc.Text + " " + doc.FileName)
+
+	var llm *llmOutcome
+	out := make([]Result, 0, len(enabled))
+	for _, r := range enabled {
+		res := Result{RuleID: r.ID, RuleName: r.Name, Type: r.Type, DUUID: r.DUUID}
+		switch r.Type {
+		case TypeKeyword:
+			res.Matched, res.Reason = matchKeyword(text, r.Pattern)
+		case TypeRegex:
+			res.Matched, res.Reason = matchRegex(doc.Text, r.Pattern)
+		case TypeDocType:
+			res.Matched, res.Reason = matchDocType(doc, r.Pattern)
+		case TypeSender:
+			res.Matched, res.Reason = matchSender(doc, r.Pattern)
+		case TypeLLM:
+			if doc.categorized(r.Pattern) {
+				res.Matched, res.Reason = (&llmOutcome{result: *doc.Category}).match(r)
+				break
+			}
+			if llm == nil {
+				llm = classify(ctx, classifier, doc, enabled)
+			}
+			res.Matched, res.Reason = llm.match(r)
+		default:
+			res.Reason = fmt.Sprintf("unknown rule type %q", r.Type)
+		}
+		if res.Matched && assigned[strings.ToLower(r.DUUID)] {
+			res.AlreadyAssigned = true
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+// Targets returns the departments matched rules add, without duplicates or
+// departments the file already has, in rule order.
+func Targets(results []Result) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, r := range results {
+		if !r.Matched || r.AlreadyAssigned || seen[r.DUUID] {
+			continue
+		}
+		seen[r.DUUID] = true
+		out = append(out, r.DUUID)
+	}
+	return out
+}
+
+// normalizeText lowercases s and collapses runs of whitespace, which OCR
+// output is full of.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// matchKeyword looks for the phrase on word boundaries, so "rail" does not
+// match "trailer".
+func matchKeyword(text, phrase string) (bool, string) {
+	phrase = normalizeText(phrase)
+	if phrase == "" {
+		return false, ""
+	}
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], phrase)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(phrase)
+		before, after := ' ', ' '
+		if start > 0 {
+			before = lastRune(text[:start])
+		}
+		if end < len(text) {
+			after = []rune(text[end:])[0]
+		}
+		if !isWordRune(before) && !isWordRune(after) {
+			return true, fmt.Sprintf("contains %q", phrase)
+		}
+		from = start + 1
+	}
+	return false, ""
+}
+
+func lastRune(s string) rune {
+	r := []rune(s)
+	return r[len(r)-1]
+}
+
+func matchRegex(text, pattern string) (bool, string) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, "invalid pattern: " + err.Error()
+	}
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return false, ""
+	}
+	return true, fmt.Sprintf("matched %q", clip(text[loc[0]:loc[1]], 80))
+}
+
+func matchDocType(doc Document, pattern string) (bool, string) {
+	name := doc.OriginalName
+	if name == "" {
+		name = doc.FileName
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		return false, ""
+	}
+	for _, want := range strings.Split(pattern, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(want), ".") == ext {
+			return true, "file type " + ext
+		}
+	}
+	return false, ""
+}
+
+func matchSender(doc Document, pattern string) (bool, string) {
+	email := strings.ToLower(doc.UploaderEmail)
+	switch {
+	case uuidPattern.MatchString(pattern):
+		if strings.EqualFold(pattern, doc.UploaderUUID) {
+			return true, "uploaded by " + pattern
+		}
+		if strings.EqualFold(pattern, doc.UploaderDUUID) {
+			return true, "uploaded from department " + pattern
+		}
+	case strings.HasPrefix(pattern, "@"):
+		if email != "" && strings.HasSuffix(email, pattern) {
+			return true, "uploaded by " + email
+		}
+	default:
+		if email != "" && email == pattern {
+			return true, "uploaded by " + email
+		}
+	}
+	return false, ""
+}
+
+// llmOutcome is the single classifier call shared by all llm rules.
+type llmOutcome struct {
+	result Classification
+	err    error
+}
+
+func classify(ctx context.Context, c Classifier, doc Document, rules []Rule) *llmOutcome {
+	if c == nil {
+		return &llmOutcome{err: fmt.Errorf("no classifier configured")}
+	}
+	if strings.TrimSpace(doc.Text) == "" {
+		return &llmOutcome{err: fmt.Errorf("no text to classify")}
+	}
+	seen := map[string]bool{}
+	var labels []string
+	for _, r := range rules {
+		if r.Type == TypeLLM && !doc.categorized(r.Pattern) && !seen[strings.ToLower(r.Pattern)] {
+			seen[strings.ToLower(r.Pattern)] = true
+			labels = append(labels, r.Pattern)
+		}
+	}
+	res, err := c.Classify(ctx, doc.Text, labels)
+	return &llmOutcome{result: res, err: err}
+}
+
+func (o *llmOutcome) match(r Rule) (bool, string) {
+	if o.err != nil {
+		return false, "classifier unavailable: " + o.err.Error()
+	}
+	if !strings.EqualFold(o.result.Label, r.Pattern) {
+		return false, ""
+	}
+	min := r.MinConfidence
+	if min == 0 {
+		min = DefaultMinConfidence
+	}
+	reason := fmt.Sprintf("classified as %q (confidence %.2f)", o.result.Label, o.result.Confidence)
+	if o.result.Confidence < min {
+		return false, reason + fmt.Sprintf(", below %.2f", min)
+	}
+	return true, reason
+}
+
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxClassifyChars bounds how much OCR text is put in the prompt.
+const maxClassifyChars = 4000
+
+// LLMClassifier asks a llama.cpp completion server to pick a label.
+type LLMClassifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewLLMClassifier(url string) *LLMClassifier {
+	return &LLMClassifier{URL: url, Client: &http.Client{Timeout: 45 * time.Second}}
+}
+
+func classifyPrompt(text string, labels []string) string {
+	var b strings.Builder
+	b.WriteString("Classify the document below into exactly one of these categories:\n")
+	for _, l := range labels {
+		b.WriteString("- " + l + "\n")
+	}
+	b.WriteString("- none\n\n")
+	b.WriteString("Answer with JSON only, in the form {\"label\": \"<category>\", \"confidence\": <0 to 1>}.\n\n")
+	b.WriteString("Document:\n")
+	b.WriteString(clip(text, maxClassifyChars))
+	b.WriteString("\n\nAnswer:")
+	return b.String()
+}
+
+// parseClassification reads the model's answer. Labels are matched
+// case-insensitively; anything that is not one of labels counts as none.
+// Answers that are not JSON are searched for a label with a low confidence.
+func parseClassification(content string, labels []string) Classification {
+	var raw struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+	}
+	if i, j := strings.Index(content, "{"), strings.LastIndex(content, "}"); i >= 0 && j > i {
+		if json.Unmarshal([]byte(content[i:j+1]), &raw) == nil {
+			for _, l := range labels {
+				if strings.EqualFold(strings.TrimSpace(raw.Label), l) {
+					c := raw.Confidence
+					if c < 0 || c > 1 {
+						c = 0
+					}
+					return Classification{Label: l, Confidence: c}
+				}
+			}
+			return Classification{}
+		}
+	}
+	lower := strings.ToLower(content)
+	for _, l := range labels {
+		if strings.Contains(lower, strings.ToLower(l)) {
+			return Classification{Label: l, Confidence: 0.5}
+		}
+	}
+	return Classification{}
+}
+
+func (c *LLMClassifier) Classify(ctx context.Context, text string, labels []string) (Classification, error) {
+	if c.URL == "" {
+		return Classification{}, fmt.Errorf("LLM_COMPLETION_URL is not configured")
+	}
+	if len(labels) == 0 {
+		return Classification{}, nil
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"prompt":      classifyPrompt(text, labels),
+		"n_predict":   64,
+		"temperature": 0,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return Classification{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return Classification{}, fmt.Errorf("failed to call LLM service: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Classification{}, fmt.Errorf("LLM service error (status %d): %s", resp.StatusCode, msg)
+	}
+	var out struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Classification{}, fmt.Errorf("failed to decode LLM response: %v", err)
+	}
+	return parseClassification(out.Content, labels), nil
+}
@@ -0,0 +1,113 @@
+// Package routing assigns uploaded documents to departments with
+// admin-managed rules. Rules run once OCR text is available; every rule that
+// matches adds its department to the file and notifies that department.
+package routing
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Rule types.
+const (
+	TypeKeyword = "keyword"  // case-insensitive phrase in the text or file name
+	TypeRegex   = "regex"    // Go regular expression over the text
+	TypeDocType = "doc_type" // file extension, e.g. "pdf" or "docx"
+	TypeSender  = "sender"   // uploader email, "@domain", user UUID or department UUID
+	TypeLLM     = "llm"      // label chosen by the classifier
+)
+
+const (
+	MaxNameLen    = 100
+	MaxPatternLen = 500
+
+	// DefaultMinConfidence applies to llm rules without their own threshold.
+	DefaultMinConfidence = 0.6
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Rule sends documents that match Pattern to department DUUID. Rules are
+// evaluated in Priority order (lowest first), then by name.
+type Rule struct {
+	ID            string    `json:"rule_id"`
+	Name          string    `json:"name"`
+	Type          string    `json:"rule_type"`
+	Pattern       string    `json:"pattern"`
+	DUUID         string    `json:"d_uuid"`
+	Department    string    `json:"department,omitempty"`
+	Priority      int       `json:"priority"`
+	MinConfidence float64   `json:"min_confidence,omitempty"`
+	Enabled       bool      `json:"enabled"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Normalize trims r's fields and checks them. It does not check that the
+// department exists.
+func Normalize(r *Rule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+	r.Pattern = strings.TrimSpace(r.Pattern)
+	r.DUUID = strings.ToLower(strings.TrimSpace(r.DUUID))
+
+	if r.Name == "" {
+		return invalid("name is required")
+	}
+	if utf8.RuneCountInString(r.Name) > MaxNameLen {
+		return invalid("name must be at most %d characters", MaxNameLen)
+	}
+	if r.Pattern == "" {
+		return invalid("pattern is required")
+	}
+	if utf8.RuneCountInString(r.Pattern) > MaxPatternLen {
+		return invalid("pattern must be at most %d characters", MaxPatternLen)
+	}
+	if !uuidPattern.MatchString(r.DUUID) {
+		return invalid("d_uuid must be a department id")
+	}
+	if r.MinConfidence < 0 || r.MinConfidence > 1 {
+		return invalid("min_confidence must be between 0 and 1")
+	}
+
+	switch r.Type {
+	case TypeKeyword, TypeLLM:
+	case TypeRegex:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return invalid("pattern is not a valid regular expression: %v", err)
+		}
+	case TypeDocType:
+		r.Pattern = strings.ToLower(strings.TrimPrefix(r.Pattern, "."))
+	case TypeSender:
+		p := strings.ToLower(r.Pattern)
+		switch {
+		case uuidPattern.MatchString(p):
+		case strings.HasPrefix(p, "@") && len(p) > 1 && !strings.ContainsAny(p[1:], "@ "):
+		default:
+			if _, err := mail.ParseAddress(p); err != nil {
+				return invalid("sender pattern must be an email, @domain or id")
+			}
+		}
+		r.Pattern = p
+	default:
+		return invalid("rule_type must be keyword, regex, doc_type, sender or llm")
+	}
+	if r.Type != TypeLLM {
+		r.MinConfidence = 0
+	}
+	return nil
+}
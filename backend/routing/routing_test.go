@@ -0,0 +1,240 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const (
+	rollingStock = "11111111-1111-4111-8111-111111111111"
+	accounts     = "22222222-2222-4222-8222-222222222222"
+	uploaderID   = "33333333-3333-4333-8333-333333333333"
+)
+
+type fakeClassifier struct {
+	result Classification
+	err    error
+	calls  int
+	labels []string
+}
+
+func (f *fakeClassifier) Classify(ctx context.Context, text string, labels []string) (Classification, error) {
+	f.calls++
+	f.labels = labels
+	return f.result, f.err
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		rule Rule
+		ok   bool
+	}{
+		{Rule{Name: "RS", Type: "Keyword", Pattern: "rolling stock", DUUID: rollingStock}, true},
+		{Rule{Name: "", Type: TypeKeyword, Pattern: "x", DUUID: rollingStock}, false},
+		{Rule{Name: "bad re", Type: TypeRegex, Pattern: "(unclosed", DUUID: rollingStock}, false},
+		{Rule{Name: "re", Type: TypeRegex, Pattern: `(?i)tender\s+no\.?\s*\d+`, DUUID: rollingStock}, true},
+		{Rule{Name: "sender", Type: TypeSender, Pattern: "@Railways.gov.in", DUUID: accounts}, true},
+		{Rule{Name: "sender", Type: TypeSender, Pattern: "not an email", DUUID: accounts}, false},
+		{Rule{Name: "llm", Type: TypeLLM, Pattern: "invoice", DUUID: accounts, MinConfidence: 1.5}, false},
+		{Rule{Name: "dept", Type: TypeKeyword, Pattern: "x", DUUID: "accounts"}, false},
+		{Rule{Name: "type", Type: "magic", Pattern: "x", DUUID: accounts}, false},
+	}
+	for _, c := range cases {
+		r := c.rule
+		err := Normalize(&r)
+		if (err == nil) != c.ok {
+			t.Errorf("Normalize(%+v) error = %v, want ok=%v", c.rule, err, c.ok)
+		}
+		var v ValidationError
+		if err != nil && !errors.As(err, &v) {
+			t.Errorf("Normalize(%+v) returned %T, want ValidationError", c.rule, err)
+		}
+	}
+
+	r := Rule{Name: " pdfs ", Type: TypeDocType, Pattern: ".PDF", DUUID: accounts}
+	if err := Normalize(&r); err != nil || r.Pattern != "pdf" || r.Name != "pdfs" {
+		t.Fatalf("doc_type normalize = %+v, %v", r, err)
+	}
+}
+
+func TestMatchKeywordWordBoundaries(t *testing.T) {
+	text := normalizeText("Inspection report for ROLLING\n  STOCK depot; trailer hitch")
+	if ok, _ := matchKeyword(text, "rolling stock"); !ok {
+		t.Error("expected 'rolling stock' to match across a line break")
+	}
+	if ok, _ := matchKeyword(text, "rail"); ok {
+		t.Error("'rail' must not match inside 'trailer'")
+	}
+	if ok, _ := matchKeyword(text, "hitch"); !ok {
+		t.Error("expected a match at the end of the text")
+	}
+}
+
+func TestEvaluateRuleTypes(t *testing.T) {
+	doc := Document{
+		FUUID: "f1", FileName: "Tender 42", OriginalName: "tender_42.PDF",
+		Text:          "Tender No. 4521 for procurement of rolling stock spares.",
+		UploaderUUID:  uploaderID,
+		UploaderEmail: "clerk@railways.gov.in",
+	}
+	rules := []Rule{
+		{ID: "kw", Name: "keyword", Type: TypeKeyword, Pattern: "rolling stock", DUUID: rollingStock, Priority: 100, Enabled: true},
+		{ID: "re", Name: "regex", Type: TypeRegex, Pattern: `Tender No\. \d+`, DUUID: accounts, Enabled: true, Priority: 1},
+		{ID: "dt", Name: "doctype", Type: TypeDocType, Pattern: "docx,pdf", DUUID: accounts, Priority: 100, Enabled: true},
+		{ID: "sd", Name: "sender", Type: TypeSender, Pattern: "@railways.gov.in", DUUID: accounts, Priority: 100, Enabled: true},
+		{ID: "sx", Name: "other sender", Type: TypeSender, Pattern: "someone@else.in", DUUID: accounts, Priority: 100, Enabled: true},
+		{ID: "off", Name: "disabled", Type: TypeKeyword, Pattern: "tender", DUUID: accounts, Priority: 100, Enabled: false},
+	}
+	results := Evaluate(context.Background(), doc, rules, nil)
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5 (disabled rule skipped)", len(results))
+	}
+	if results[0].RuleID != "re" {
+		t.Errorf("first result = %s, want the priority 1 rule", results[0].RuleID)
+	}
+	matched := map[string]bool{}
+	for _, r := range results {
+		matched[r.RuleID] = r.Matched
+	}
+	want := map[string]bool{"kw": true, "re": true, "dt": true, "sd": true, "sx": false}
+	for id, w := range want {
+		if matched[id] != w {
+			t.Errorf("rule %s matched = %v, want %v", id, matched[id], w)
+		}
+	}
+	targets := Targets(results)
+	if len(targets) != 2 || targets[0] != accounts || targets[1] != rollingStock {
+		t.Errorf("Targets = %v, want [accounts rolling stock]", targets)
+	}
+}
+
+func TestEvaluateSkipsAssignedDepartments(t *testing.T) {
+	doc := Document{Text: "rolling stock", Departments: []string{rollingStock}}
+	rules := []Rule{{ID: "kw", Name: "kw", Type: TypeKeyword, Pattern: "rolling stock", DUUID: rollingStock, Enabled: true}}
+	results := Evaluate(context.Background(), doc, rules, nil)
+	if !results[0].Matched || !results[0].AlreadyAssigned {
+		t.Fatalf("result = %+v, want matched and already assigned", results[0])
+	}
+	if got := Targets(results); len(got) != 0 {
+		t.Errorf("Targets = %v, want none", got)
+	}
+}
+
+func TestEvaluateLLMRules(t *testing.T) {
+	rules := []Rule{
+		{ID: "a", Name: "a", Type: TypeLLM, Pattern: "Invoice", DUUID: accounts, Enabled: true},
+		{ID: "b", Name: "b", Type: TypeLLM, Pattern: "invoice", DUUID: rollingStock, Enabled: true, MinConfidence: 0.9},
+		{ID: "c", Name: "c", Type: TypeLLM, Pattern: "circular", DUUID: rollingStock, Enabled: true},
+	}
+	c := &fakeClassifier{result: Classification{Label: "invoice", Confidence: 0.8}}
+	results := Evaluate(context.Background(), Document{Text: "Bill for services"}, rules, c)
+	if c.calls != 1 {
+		t.Fatalf("classifier called %d times, want 1", c.calls)
+	}
+	if len(c.labels) != 2 {
+		t.Errorf("labels = %v, want de-duplicated [Invoice circular]", c.labels)
+	}
+	if !results[0].Matched || results[1].Matched || results[2].Matched {
+		t.Errorf("results = %+v; want only rule a (b is below its threshold)", results)
+	}
+
+	c = &fakeClassifier{err: errors.New("down")}
+	results = Evaluate(context.Background(), Document{Text: "x"}, rules[:1], c)
+	if results[0].Matched || results[0].Reason == "" {
+		t.Errorf("classifier error should leave the rule unmatched with a reason, got %+v", results[0])
+	}
+}
+
+func TestEvaluateUsesStoredCategory(t *testing.T) {
+	rules := []Rule{
+		{ID: "a", Name: "a", Type: TypeLLM, Pattern: "Safety", DUUID: accounts, Enabled: true},
+		{ID: "b", Name: "b", Type: TypeLLM, Pattern: "procurement", DUUID: rollingStock, Enabled: true},
+		{ID: "c", Name: "c", Type: TypeLLM, Pattern: "invoice", DUUID: rollingStock, Enabled: true},
+	}
+	doc := Document{
+		Text:     "Fire incident at depot",
+		Category: &Classification{Label: "Safety", Confidence: 0.9},
+		Taxonomy: []string{"Safety", "Procurement"},
+	}
+	c := &fakeClassifier{}
+	results := Evaluate(context.Background(), doc, rules, c)
+	if !results[0].Matched || results[1].Matched {
+		t.Errorf("results = %+v, want only the Safety rule", results)
+	}
+	if c.calls != 1 || len(c.labels) != 1 || c.labels[0] != "invoice" {
+		t.Errorf("classifier got %v in %d calls, want only [invoice]", c.labels, c.calls)
+	}
+
+	c = &fakeClassifier{}
+	Evaluate(context.Background(), doc, rules[:2], c)
+	if c.calls != 0 {
+		t.Error("classifier should not be called when the category decides every llm rule")
+	}
+}
+
+func TestParseClassification(t *testing.T) {
+	labels := []string{"Invoice", "Circular"}
+	if got := parseClassification(` {"label": "invoice", "confidence": 0.92}`, labels); got.Label != "Invoice" || got.Confidence != 0.92 {
+		t.Errorf("json answer parsed as %+v", got)
+	}
+	if got := parseClassification(`{"label": "memo", "confidence": 0.99}`, labels); got.Label != "" {
+		t.Errorf("unknown label should be none, got %+v", got)
+	}
+	if got := parseClassification(`It looks like a circular.`, labels); got.Label != "Circular" || got.Confidence != 0.5 {
+		t.Errorf("free text answer parsed as %+v", got)
+	}
+}
+
+func TestServiceRouteAndDryRun(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddDepartment(rollingStock, "Rolling Stock")
+	store.AddDepartment(accounts, "Accounts")
+	svc := NewService(store, nil)
+
+	if _, err := svc.Create(Rule{Name: "x", Type: TypeKeyword, Pattern: "x", DUUID: "44444444-4444-4444-8444-444444444444"}, "admin"); err == nil {
+		t.Fatal("expected unknown department to be rejected")
+	}
+	rule, err := svc.Create(Rule{Name: "RS", Type: TypeKeyword, Pattern: "rolling stock", DUUID: rollingStock, Enabled: true}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Department != "Rolling Stock" || rule.CreatedBy != "admin" {
+		t.Errorf("created rule = %+v", rule)
+	}
+
+	doc := Document{FUUID: "f1", Text: "New rolling stock arrived", UploaderUUID: uploaderID}
+	candidate := Rule{Name: "acc", Type: TypeKeyword, Pattern: "arrived", DUUID: accounts}
+	results, err := svc.DryRun(context.Background(), doc, &candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Matched || !results[1].Matched {
+		t.Errorf("dry run = %+v, want both rules to fire", results)
+	}
+	if len(store.FileDepartments["f1"]) != 0 {
+		t.Fatal("dry run must not change anything")
+	}
+
+	_, added, err := svc.Route(context.Background(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0] != rollingStock || len(store.Notified["f1"]) != 1 {
+		t.Errorf("added = %v, notified = %v", added, store.Notified["f1"])
+	}
+	// Routing again adds nothing.
+	if _, added, _ := svc.Route(context.Background(), doc); len(added) != 0 {
+		t.Errorf("second route added %v", added)
+	}
+
+	if _, _, err := svc.Update("missing", candidate); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update missing rule: %v", err)
+	}
+	if err := svc.Delete(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(rule.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package routing
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("routing rule not found")
+
+// Store persists rules and applies routing decisions. Implementations:
+// NewPostgresStore and NewMemoryStore.
+type Store interface {
+	// Rules returns every rule, enabled or not.
+	Rules() ([]Rule, error)
+	// Rule returns nil when the rule does not exist.
+	Rule(id string) (*Rule, error)
+	Create(r Rule) (Rule, error)
+	// Update and Delete return ErrNotFound for unknown rules.
+	Update(r Rule) (Rule, error)
+	Delete(id string) error
+	DepartmentExists(dUUID string) (bool, error)
+	// Apply shares the file with each hit's department and notifies its
+	// members, skipping departments the file already has. It returns the
+	// departments that were added.
+	Apply(fuuid, uploaderUUID string, hits []Result) ([]string, error)
+}
+
+type Service struct {
+	store      Store
+	Classifier Classifier
+}
+
+func NewService(store Store, classifier Classifier) *Service {
+	return &Service{store: store, Classifier: classifier}
+}
+
+// Rules lists every rule in evaluation order.
+func (s *Service) Rules() ([]Rule, error) {
+	rules, err := s.store.Rules()
+	if err != nil {
+		return nil, err
+	}
+	Sort(rules)
+	return rules, nil
+}
+
+func (s *Service) check(r *Rule) error {
+	if err := Normalize(r); err != nil {
+		return err
+	}
+	ok, err := s.store.DepartmentExists(r.DUUID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return invalid("department %s does not exist", r.DUUID)
+	}
+	return nil
+}
+
+// Create validates and stores a new rule. createdBy identifies the admin.
+func (s *Service) Create(r Rule, createdBy string) (Rule, error) {
+	if err := s.check(&r); err != nil {
+		return Rule{}, err
+	}
+	r.ID, r.CreatedBy = "", createdBy
+	return s.store.Create(r)
+}
+
+// Update replaces rule id with r. It returns the rule as it was before, for
+// auditing, and the rule as stored.
+func (s *Service) Update(id string, r Rule) (before, after Rule, err error) {
+	old, err := s.store.Rule(id)
+	if err != nil {
+		return Rule{}, Rule{}, err
+	}
+	if old == nil {
+		return Rule{}, Rule{}, ErrNotFound
+	}
+	if err := s.check(&r); err != nil {
+		return Rule{}, Rule{}, err
+	}
+	r.ID = id
+	after, err = s.store.Update(r)
+	return *old, after, err
+}
+
+func (s *Service) Delete(id string) error {
+	return s.store.Delete(id)
+}
+
+// DryRun shows which rules would fire for doc without changing anything.
+// candidate, when set, is an unsaved rule evaluated alongside the stored
+// ones so admins can try a rule before creating it.
+func (s *Service) DryRun(ctx context.Context, doc Document, candidate *Rule) ([]Result, error) {
+	rules, err := s.store.Rules()
+	if err != nil {
+		return nil, err
+	}
+	if candidate != nil {
+		c := *candidate
+		if err := s.check(&c); err != nil {
+			return nil, err
+		}
+		c.Enabled = true
+		if c.ID == "" {
+			c.ID = "candidate"
+		}
+		rules = append(rules, c)
+	}
+	return Evaluate(ctx, doc, rules, s.Classifier), nil
+}
+
+// Route evaluates the enabled rules against doc and applies the matches.
+// It returns every rule's result and the departments that were added.
+func (s *Service) Route(ctx context.Context, doc Document) ([]Result, []string, error) {
+	rules, err := s.store.Rules()
+	if err != nil {
+		return nil, nil, err
+	}
+	results := Evaluate(ctx, doc, rules, s.Classifier)
+	targets := Targets(results)
+	if len(targets) == 0 {
+		return results, nil, nil
+	}
+	// The first matching rule per department is the one recorded.
+	first := map[string]bool{}
+	var hits []Result
+	for _, r := range results {
+		if r.Matched && !r.AlreadyAssigned && !first[r.DUUID] {
+			first[r.DUUID] = true
+			hits = append(hits, r)
+		}
+	}
+	added, err := s.store.Apply(doc.FUUID, doc.UploaderUUID, hits)
+	return results, added, err
+}
@@ -0,0 +1,216 @@
+package routing
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (routing_rules, file_routing)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(r models.RoutingRule) Rule {
+	return Rule{
+		ID: r.RuleID, Name: r.Name, Type: r.RuleType, Pattern: r.Pattern, DUUID: r.DUUID, Department: r.DName,
+		Priority: r.Priority, MinConfidence: r.MinConfidence, Enabled: r.Enabled, CreatedBy: r.CreatedBy,
+		CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func toRow(r Rule) models.RoutingRule {
+	return models.RoutingRule{
+		RuleID: r.ID, Name: r.Name, RuleType: r.Type, Pattern: r.Pattern, DUUID: r.DUUID,
+		Priority: r.Priority, MinConfidence: r.MinConfidence, Enabled: r.Enabled, CreatedBy: r.CreatedBy,
+	}
+}
+
+func (s pgStore) Rules() ([]Rule, error) {
+	rows, err := models.ListRoutingRules(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Rule, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Rule(id string) (*Rule, error) {
+	r, err := models.GetRoutingRule(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	rule := fromRow(*r)
+	return &rule, nil
+}
+
+// reload re-reads a rule so the department name is filled in.
+func (s pgStore) reload(id string, err error) (Rule, error) {
+	if err != nil {
+		return Rule{}, err
+	}
+	r, err := s.Rule(id)
+	if err != nil || r == nil {
+		return Rule{}, fmt.Errorf("reload routing rule %s: %v", id, err)
+	}
+	return *r, nil
+}
+
+func (s pgStore) Create(r Rule) (Rule, error) {
+	row, err := models.InsertRoutingRule(s.db, toRow(r))
+	return s.reload(row.RuleID, err)
+}
+
+func (s pgStore) Update(r Rule) (Rule, error) {
+	_, err := models.UpdateRoutingRule(s.db, toRow(r))
+	if err == sql.ErrNoRows {
+		return Rule{}, ErrNotFound
+	}
+	return s.reload(r.ID, err)
+}
+
+func (s pgStore) Delete(id string) error {
+	err := models.DeleteRoutingRule(s.db, id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s pgStore) DepartmentExists(dUUID string) (bool, error) {
+	d, err := models.GetDepartmentByUUID(s.db, dUUID)
+	return d != nil, err
+}
+
+func (s pgStore) Apply(fuuid, uploaderUUID string, hits []Result) ([]string, error) {
+	rows := make([]models.RoutingHit, 0, len(hits))
+	for _, h := range hits {
+		id := h.RuleID
+		if !uuidPattern.MatchString(id) {
+			id = ""
+		}
+		rows = append(rows, models.RoutingHit{RuleID: id, RuleName: h.RuleName, DUUID: h.DUUID, Reason: h.Reason})
+	}
+	return models.ApplyFileRouting(s.db, fuuid, uploaderUUID, rows)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu          sync.Mutex
+	seq         int
+	rules       map[string]Rule
+	departments map[string]string
+	// FileDepartments and Notified record what Apply did, per f_uuid.
+	FileDepartments map[string][]string
+	Notified        map[string][]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rules:           map[string]Rule{},
+		departments:     map[string]string{},
+		FileDepartments: map[string][]string{},
+		Notified:        map[string][]string{},
+	}
+}
+
+func (m *MemoryStore) AddDepartment(dUUID, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.departments[strings.ToLower(dUUID)] = name
+}
+
+func (m *MemoryStore) Rules() ([]Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Rule, 0, len(m.rules))
+	for _, r := range m.rules {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Rule(id string) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rules[id]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (m *MemoryStore) Create(r Rule) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	r.ID = fmt.Sprintf("00000000-0000-4000-8000-%012d", m.seq)
+	r.Department = m.departments[r.DUUID]
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+	m.rules[r.ID] = r
+	return r, nil
+}
+
+func (m *MemoryStore) Update(r Rule) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.rules[r.ID]
+	if !ok {
+		return Rule{}, ErrNotFound
+	}
+	r.CreatedAt, r.CreatedBy = old.CreatedAt, old.CreatedBy
+	r.Department = m.departments[r.DUUID]
+	r.UpdatedAt = time.Now()
+	m.rules[r.ID] = r
+	return r, nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.rules, id)
+	return nil
+}
+
+func (m *MemoryStore) DepartmentExists(dUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.departments[strings.ToLower(dUUID)]
+	return ok, nil
+}
+
+func (m *MemoryStore) Apply(fuuid, uploaderUUID string, hits []Result) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var added []string
+	for _, h := range hits {
+		exists := false
+		for _, d := range m.FileDepartments[fuuid] {
+			exists = exists || d == h.DUUID
+		}
+		if exists {
+			continue
+		}
+		m.FileDepartments[fuuid] = append(m.FileDepartments[fuuid], h.DUUID)
+		m.Notified[fuuid] = append(m.Notified[fuuid], h.DUUID)
+		added = append(added, h.DUUID)
+	}
+	return added, nil
+}
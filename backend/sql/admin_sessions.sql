@@ -0,0 +1,20 @@
+-- SQL migrations for persistent admin sessions
+-- Run this in Supabase SQL Editor
+
+CREATE TABLE IF NOT EXISTS admin_sessions (
+    id TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    a_uuid UUID NOT NULL,
+    username TEXT NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_expires_at ON admin_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_last_seen_at ON admin_sessions(last_seen_at);
+
+-- Sessions are only ever touched by the Go backend (service connection)
+ALTER TABLE admin_sessions ENABLE ROW LEVEL SECURITY;
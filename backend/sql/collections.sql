@@ -0,0 +1,55 @@
+-- SQL migrations for favorites and named collections (/v1/me/favorites,
+-- /v1/me/collections)
+-- Run this in Supabase SQL Editor
+
+-- Favorites were inserted by the clients without a constraint; keep the
+-- oldest row of each pair and make the pair unique.
+ALTER TABLE favorites ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT NOW();
+DELETE FROM favorites a USING favorites b
+WHERE a.uuid = b.uuid AND a.f_uuid = b.f_uuid AND a.ctid > b.ctid;
+DROP INDEX IF EXISTS idx_favorites_user_file;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_favorites_user_file ON favorites(uuid, f_uuid);
+
+-- A collection belongs to one user. When shared, the members of d_uuid (the
+-- owner's department when it was last saved) can read it.
+CREATE TABLE IF NOT EXISTS collections (
+    collection_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    d_uuid UUID REFERENCES department(d_uuid) ON DELETE SET NULL,
+    name TEXT NOT NULL CHECK (char_length(btrim(name)) BETWEEN 1 AND 80),
+    description TEXT NOT NULL DEFAULT '' CHECK (char_length(description) <= 500),
+    shared BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_collections_owner_name ON collections(owner_uuid, lower(name));
+CREATE INDEX IF NOT EXISTS idx_collections_shared_dept ON collections(d_uuid) WHERE shared;
+
+-- Items are kept in a manual order. The position constraint is deferred so
+-- a reorder can swap positions inside one transaction.
+CREATE TABLE IF NOT EXISTS collection_items (
+    collection_id UUID NOT NULL REFERENCES collections(collection_id) ON DELETE CASCADE,
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    position INT NOT NULL CHECK (position >= 0),
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, f_uuid),
+    CONSTRAINT collection_items_position_unique UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_items_file ON collection_items(f_uuid);
+
+-- Collections are managed through the Go API, which checks ownership,
+-- sharing and file access; clients may only read their own.
+ALTER TABLE collections ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS collections_select_own ON collections;
+CREATE POLICY collections_select_own ON collections
+    FOR SELECT USING (auth.uid() = owner_uuid);
+
+ALTER TABLE collection_items ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS collection_items_select_own ON collection_items;
+CREATE POLICY collection_items_select_own ON collection_items
+    FOR SELECT USING (EXISTS (
+        SELECT 1 FROM collections c
+        WHERE c.collection_id = collection_items.collection_id AND c.owner_uuid = auth.uid()
+    ));
@@ -0,0 +1,36 @@
+-- SQL migrations for digest emails and deadlines
+-- Run this in Supabase SQL Editor
+
+-- immediate: one email per event (default); daily/weekly: batched digest
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS digest_frequency TEXT NOT NULL DEFAULT 'immediate';
+ALTER TABLE notification_preferences DROP CONSTRAINT IF EXISTS digest_frequency_valid;
+ALTER TABLE notification_preferences ADD CONSTRAINT digest_frequency_valid
+    CHECK (digest_frequency IN ('immediate', 'daily', 'weekly'));
+
+-- One row per user per digest period; the primary key makes each period send once
+CREATE TABLE IF NOT EXISTS digest_deliveries (
+    uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    period_key TEXT NOT NULL,                 -- daily:2025-01-31 | weekly:2025-W05
+    file_count INT NOT NULL DEFAULT 0,
+    deadline_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (uuid, period_key)
+);
+
+CREATE TABLE IF NOT EXISTS deadlines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    title TEXT NOT NULL CHECK (length(trim(title)) > 0),
+    due_at TIMESTAMPTZ NOT NULL,
+    f_uuid UUID REFERENCES file(f_uuid) ON DELETE CASCADE,
+    d_uuid UUID REFERENCES department(d_uuid) ON DELETE CASCADE,  -- NULL: everyone
+    created_by UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    reminder_sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deadlines_due_at ON deadlines(due_at);
+CREATE INDEX IF NOT EXISTS idx_deadlines_department ON deadlines(d_uuid, due_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(uuid, created_at);
+
+ALTER TABLE digest_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE deadlines ENABLE ROW LEVEL SECURITY;
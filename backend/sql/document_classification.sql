@@ -0,0 +1,56 @@
+-- SQL migrations for document categories and classifications
+-- Run this in Supabase SQL Editor
+
+-- Admin-managed taxonomy. Keywords drive the fallback scorer when the LLM
+-- is unavailable or unsure.
+CREATE TABLE IF NOT EXISTS document_categories (
+    category_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (length(trim(name)) > 0),
+    description TEXT NOT NULL DEFAULT '',
+    keywords TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_categories_name ON document_categories(lower(name));
+
+INSERT INTO document_categories (name, description, keywords) VALUES
+    ('Safety', 'Safety circulars, incident and accident reports, audits and fire safety',
+        ARRAY['safety', 'incident', 'accident', 'hazard', 'fire', 'evacuation', 'injury', 'near miss', 'ppe', 'emergency']),
+    ('Procurement', 'Tenders, purchase orders, quotations, bids and vendor contracts',
+        ARRAY['tender', 'procurement', 'purchase order', 'quotation', 'bid', 'vendor', 'supplier', 'invoice', 'rfp', 'contract award']),
+    ('HR', 'Recruitment, leave, payroll, transfers, training and staff circulars',
+        ARRAY['recruitment', 'leave', 'payroll', 'salary', 'appointment', 'transfer', 'promotion', 'training', 'employee', 'attendance']),
+    ('Operations', 'Train operations, timetables, service planning and station operations',
+        ARRAY['timetable', 'headway', 'train service', 'operations', 'ridership', 'station', 'passenger', 'schedule', 'control centre', 'dispatch']),
+    ('Maintenance', 'Rolling stock and infrastructure maintenance, job cards and inspections',
+        ARRAY['maintenance', 'rolling stock', 'inspection', 'job card', 'depot', 'overhaul', 'repair', 'spares', 'preventive', 'breakdown']),
+    ('Legal/Regulatory', 'Legal notices, regulatory directives, compliance and court matters',
+        ARRAY['legal', 'regulatory', 'compliance', 'court', 'notice', 'act', 'regulation', 'directive', 'statutory', 'ministry'])
+ON CONFLICT DO NOTHING;
+
+-- One classification per file, replaced when the file is re-classified.
+-- category_id is NULL when nothing fit (method 'none').
+CREATE TABLE IF NOT EXISTS document_classifications (
+    f_uuid UUID PRIMARY KEY REFERENCES file(f_uuid) ON DELETE CASCADE,
+    category_id UUID REFERENCES document_categories(category_id) ON DELETE SET NULL,
+    confidence REAL NOT NULL DEFAULT 0 CHECK (confidence >= 0 AND confidence <= 1),
+    method TEXT NOT NULL CHECK (method IN ('llm', 'keyword', 'none')),
+    scores JSONB NOT NULL DEFAULT '{}',
+    note TEXT,
+    classified_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_classifications_category ON document_classifications(category_id, f_uuid);
+
+-- The frontend filters search and file listings by category.
+ALTER TABLE document_categories ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS document_categories_read ON document_categories;
+CREATE POLICY document_categories_read ON document_categories
+    FOR SELECT TO authenticated USING (true);
+
+ALTER TABLE document_classifications ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS document_classifications_read ON document_classifications;
+CREATE POLICY document_classifications_read ON document_classifications
+    FOR SELECT TO authenticated USING (true);
@@ -0,0 +1,22 @@
+-- SQL migration for file_access_departments, the departments that may read
+-- each file
+-- Run this in Supabase SQL Editor after sql/workspaces.sql and
+-- sql/workflows.sql
+
+-- Every department that may read a file: its own departments, those that
+-- joined a workspace it is linked into, and those with a pending step of an
+-- approval on it (access ends with the step). List queries check
+-- confidential and restricted files against this view
+-- (models.fileVisibleTo). This is the only definition of the view; add new
+-- ways of reaching a file here.
+CREATE OR REPLACE VIEW file_access_departments AS
+    SELECT fd.f_uuid, fd.d_uuid FROM file_department fd
+    UNION
+    SELECT wf.f_uuid, wd.d_uuid
+    FROM workspace_files wf
+    JOIN workspace_departments wd ON wd.workspace_id = wf.workspace_id AND wd.status = 'joined'
+    UNION
+    SELECT wi.f_uuid, ws.d_uuid
+    FROM workflow_instances wi
+    JOIN workflow_steps ws ON ws.workflow_id = wi.workflow_id AND ws.status = 'pending'
+    WHERE wi.status = 'in_progress';
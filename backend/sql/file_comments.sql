@@ -0,0 +1,55 @@
+-- SQL migrations for threaded file comments (/v1/files/{id}/comments)
+-- Run this in Supabase SQL Editor
+
+-- thread_id is the root comment (its own ID for a root). page and the
+-- span are optional anchors; span offsets count characters of the file's
+-- latest OCR text and quote keeps the anchored words. Deleted comments keep
+-- their place in the thread with an empty body.
+CREATE TABLE IF NOT EXISTS file_comments (
+    comment_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    parent_id UUID REFERENCES file_comments(comment_id) ON DELETE CASCADE,
+    thread_id UUID NOT NULL REFERENCES file_comments(comment_id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+    author_uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    body TEXT NOT NULL CHECK (char_length(body) <= 4000),
+    page INTEGER CHECK (page >= 1),
+    span_start INTEGER CHECK (span_start >= 0),
+    span_end INTEGER,
+    quote TEXT NOT NULL DEFAULT '' CHECK (char_length(quote) <= 500),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    CHECK (span_end IS NULL OR span_end > COALESCE(span_start, 0)),
+    CHECK ((deleted_at IS NULL AND char_length(btrim(body)) >= 1) OR (deleted_at IS NOT NULL AND body = ''))
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_comments_file ON file_comments(f_uuid, created_at);
+CREATE INDEX IF NOT EXISTS idx_file_comments_thread ON file_comments(thread_id);
+
+CREATE TABLE IF NOT EXISTS file_comment_mentions (
+    comment_id UUID NOT NULL REFERENCES file_comments(comment_id) ON DELETE CASCADE,
+    uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    handle TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (comment_id, uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_comment_mentions_user ON file_comment_mentions(uuid);
+
+-- The text a comment had before each edit or deletion.
+CREATE TABLE IF NOT EXISTS file_comment_revisions (
+    revision_id BIGSERIAL PRIMARY KEY,
+    comment_id UUID NOT NULL REFERENCES file_comments(comment_id) ON DELETE CASCADE,
+    action TEXT NOT NULL CHECK (action IN ('edit', 'delete')),
+    body TEXT NOT NULL,
+    edited_by UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    edited_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_comment_revisions_comment ON file_comment_revisions(comment_id, edited_at);
+
+-- Comments are managed through the Go API, which applies file access;
+-- clients get no direct access.
+ALTER TABLE file_comments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_comment_mentions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_comment_revisions ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,14 @@
+-- SQL migrations for the paginated file listings (/v1/files,
+-- /v1/departments/{id}/files)
+-- Run this in Supabase SQL Editor
+
+-- Keyset pagination orders by (sort key, f_uuid).
+CREATE INDEX IF NOT EXISTS idx_file_created_at_keyset ON file ((COALESCE(created_at, 'epoch'::timestamptz)), (f_uuid::text));
+CREATE INDEX IF NOT EXISTS idx_file_name_keyset ON file ((lower(COALESCE(f_name, ''))), (f_uuid::text));
+
+-- Filters and per-item lookups.
+CREATE INDEX IF NOT EXISTS idx_file_uploader ON file(uuid);
+CREATE INDEX IF NOT EXISTS idx_file_department_dept ON file_department(d_uuid, f_uuid);
+CREATE INDEX IF NOT EXISTS idx_file_department_file ON file_department(f_uuid);
+CREATE INDEX IF NOT EXISTS idx_summary_file ON summary(f_uuid, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_favorites_user_file ON favorites(uuid, f_uuid);
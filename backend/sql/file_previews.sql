@@ -0,0 +1,23 @@
+-- SQL migrations for file thumbnails and page previews
+-- Run this in Supabase SQL Editor
+
+-- The rendered images live in the file_storage bucket next to the original
+-- (<file_path>.preview/thumbnail.jpg, <file_path>.preview/page-<n>.jpg);
+-- this table records which of them exist. The first pages are rendered
+-- while the file is processed, later ones on first request.
+CREATE TABLE IF NOT EXISTS file_previews (
+    f_uuid UUID PRIMARY KEY REFERENCES file(f_uuid) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('pdf', 'image')),
+    page_count INT NOT NULL DEFAULT 0 CHECK (page_count >= 0),
+    pages INT[] NOT NULL DEFAULT '{}',
+    has_thumbnail BOOLEAN NOT NULL DEFAULT false,
+    error TEXT,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Previews are served by the Go API, which checks access; clients only
+-- need to know whether a thumbnail exists.
+ALTER TABLE file_previews ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS file_previews_read ON file_previews;
+CREATE POLICY file_previews_read ON file_previews
+    FOR SELECT TO authenticated USING (true);
@@ -0,0 +1,13 @@
+-- SQL migrations for file sensitivity levels
+-- Run this in Supabase SQL Editor
+
+-- internal: every active user; confidential: uploader and members of the
+-- file's departments; restricted: uploader and heads of those departments.
+-- The Go API enforces these levels (backend/access).
+ALTER TABLE file ADD COLUMN IF NOT EXISTS sensitivity TEXT NOT NULL DEFAULT 'internal';
+
+ALTER TABLE file DROP CONSTRAINT IF EXISTS file_sensitivity_check;
+ALTER TABLE file ADD CONSTRAINT file_sensitivity_check
+    CHECK (sensitivity IN ('internal', 'confidential', 'restricted'));
+
+CREATE INDEX IF NOT EXISTS idx_file_sensitivity ON file(sensitivity) WHERE sensitivity <> 'internal';
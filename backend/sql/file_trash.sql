@@ -0,0 +1,19 @@
+-- SQL migrations for soft-deleted files (trash)
+-- Run this in Supabase SQL Editor
+
+-- DELETE /v1/files/{id} sets deleted_at and deleted_by and keeps the
+-- storage object. The file can be restored until TRASH_RETENTION_DAYS
+-- (default 30) have passed; the trash purge job then removes the row, its
+-- OCR, summaries, department links and the object.
+ALTER TABLE file ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(uuid) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_file_deleted_at ON file(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- The frontend reads file directly for listings and search; this keeps
+-- trashed files out of those reads on top of whatever permissive policies
+-- grant access. The Go API connects as the table owner and filters on
+-- deleted_at itself.
+DROP POLICY IF EXISTS file_hide_trashed ON file;
+CREATE POLICY file_hide_trashed ON file
+    AS RESTRICTIVE FOR SELECT TO authenticated USING (deleted_at IS NULL);
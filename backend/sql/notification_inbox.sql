@@ -0,0 +1,6 @@
+-- SQL migrations for the notifications inbox API
+-- Run this in Supabase SQL Editor
+
+-- Latest-per-file lookups and the unread badge.
+CREATE INDEX IF NOT EXISTS idx_notifications_user_file ON notifications(uuid, f_uuid, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unseen ON notifications(uuid, created_at DESC) WHERE is_seen IS NOT TRUE;
@@ -0,0 +1,29 @@
+-- SQL migrations for the notification outbox
+-- Run this in Supabase SQL Editor
+
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,                       -- new_file | quick_share
+    channel TEXT NOT NULL DEFAULT 'email',
+    recipient_uuid UUID,
+    recipient TEXT NOT NULL,                  -- address on the channel (email, ...)
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+-- The dispatcher only ever scans due, pending rows
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_due
+    ON notification_outbox(next_attempt_at) WHERE status = 'pending';
+
+-- Source tables are scanned for rows not yet copied into the outbox
+CREATE INDEX IF NOT EXISTS idx_notifications_unsent ON notifications(created_at) WHERE is_sent IS NOT TRUE;
+CREATE INDEX IF NOT EXISTS idx_quick_share_unsent ON quick_share(created_at) WHERE is_sent IS NOT TRUE;
+
+-- The outbox is only ever touched by the Go backend (service connection)
+ALTER TABLE notification_outbox ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,20 @@
+-- SQL migrations for per-user notification channel preferences
+-- Run this in Supabase SQL Editor
+
+-- Users without a row get the defaults: email and in-app on, webhook off.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    uuid UUID PRIMARY KEY REFERENCES users(uuid) ON DELETE CASCADE,
+    email_enabled BOOLEAN NOT NULL DEFAULT true,
+    in_app_enabled BOOLEAN NOT NULL DEFAULT true,
+    webhook_enabled BOOLEAN NOT NULL DEFAULT false,
+    webhook_url TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT webhook_needs_url CHECK (NOT webhook_enabled OR COALESCE(webhook_url, '') <> '')
+);
+
+ALTER TABLE notification_preferences ENABLE ROW LEVEL SECURITY;
+
+-- Users may read their own preferences from the frontend; writes go through the Go API
+DROP POLICY IF EXISTS notification_preferences_select_own ON notification_preferences;
+CREATE POLICY notification_preferences_select_own ON notification_preferences
+    FOR SELECT USING (auth.uid() = uuid);
@@ -0,0 +1,34 @@
+-- SQL migrations for typed quick shares, threads and read receipts
+-- Run this in Supabase SQL Editor
+
+-- Typed columns; data is still written for older clients.
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS subject TEXT;
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS body TEXT;
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal';
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS attachments UUID[] NOT NULL DEFAULT '{}';
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS target_departments UUID[] NOT NULL DEFAULT '{}';
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS parent_qs_uuid UUID REFERENCES quick_share(qs_uuid) ON DELETE CASCADE;
+ALTER TABLE quick_share ADD COLUMN IF NOT EXISTS thread_qs_uuid UUID REFERENCES quick_share(qs_uuid) ON DELETE CASCADE;
+
+ALTER TABLE quick_share DROP CONSTRAINT IF EXISTS quick_share_priority_valid;
+ALTER TABLE quick_share ADD CONSTRAINT quick_share_priority_valid CHECK (priority IN ('low', 'normal', 'high'));
+
+CREATE INDEX IF NOT EXISTS idx_quick_share_thread ON quick_share(thread_qs_uuid, created_at) WHERE thread_qs_uuid IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_quick_share_sender ON quick_share(uuid, created_at DESC);
+
+-- Resolved recipients of each API-created quick share, with read receipts.
+-- Rows inserted by older clients have none and are resolved at delivery time.
+CREATE TABLE IF NOT EXISTS quick_share_recipients (
+    qs_uuid UUID NOT NULL REFERENCES quick_share(qs_uuid) ON DELETE CASCADE,
+    uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    d_uuid UUID REFERENCES department(d_uuid) ON DELETE SET NULL,
+    read_at TIMESTAMPTZ,
+    PRIMARY KEY (qs_uuid, uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quick_share_recipients_user ON quick_share_recipients(uuid, qs_uuid);
+
+ALTER TABLE quick_share_recipients ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS quick_share_recipients_select_own ON quick_share_recipients;
+CREATE POLICY quick_share_recipients_select_own ON quick_share_recipients
+    FOR SELECT USING (auth.uid() = uuid);
@@ -0,0 +1,37 @@
+-- SQL migrations for the storage/database reconciler
+-- Run this in Supabase SQL Editor
+
+-- One row per reconciler run (scheduled, manual or from cmd/reconcile).
+-- findings lists every orphan object, missing object and missing OCR or
+-- summary the run found, with what it did about each. A run is stored as
+-- 'running' when it starts and updated when it ends; runs a restart cut
+-- short are marked 'interrupted'.
+CREATE TABLE IF NOT EXISTS reconcile_runs (
+    run_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    trigger TEXT NOT NULL,
+    clean BOOLEAN NOT NULL DEFAULT false,
+    requeue BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'done', 'failed', 'interrupted')),
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    counts JSONB NOT NULL DEFAULT '{}',
+    findings JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    CHECK ((status = 'running') = (finished_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconcile_runs_started ON reconcile_runs(started_at DESC);
+
+-- How often the reconciler re-queued OCR or a summary for a file, so files
+-- that keep failing are not retried forever.
+CREATE TABLE IF NOT EXISTS reconcile_requeues (
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('ocr', 'summary')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (f_uuid, kind)
+);
+
+-- The reconciler is run through the Go admin API; clients get no access.
+ALTER TABLE reconcile_runs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE reconcile_requeues ENABLE ROW LEVEL SECURITY;
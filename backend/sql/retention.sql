@@ -0,0 +1,62 @@
+-- SQL migrations for retention policies and scheduled archival/purge
+-- Run this in Supabase SQL Editor
+
+-- A policy keeps matching records for keep_days after they were uploaded
+-- (files) or sent (quick shares) or created (document_processing_results).
+-- scope 'category' matches files classified under category_id; scope
+-- 'department' matches files uploaded by department d_uuid. When several
+-- policies match a file the longest keep_days wins.
+CREATE TABLE IF NOT EXISTS retention_policies (
+    policy_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (char_length(btrim(name)) BETWEEN 1 AND 120),
+    scope TEXT NOT NULL CHECK (scope IN ('category', 'department', 'quick_share', 'processing_results')),
+    category_id UUID REFERENCES document_categories(category_id) ON DELETE CASCADE,
+    d_uuid UUID REFERENCES department(d_uuid) ON DELETE CASCADE,
+    keep_days INTEGER NOT NULL CHECK (keep_days BETWEEN 1 AND 36500),
+    action TEXT NOT NULL CHECK (action IN ('archive', 'purge')),
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((scope = 'category') = (category_id IS NOT NULL)),
+    CHECK ((scope = 'department') = (d_uuid IS NOT NULL)),
+    CHECK (scope IN ('category', 'department') OR action = 'purge')
+);
+
+-- Examples (incident reports for 10 years, quick shares for 90 days):
+-- INSERT INTO retention_policies (name, scope, category_id, keep_days, action)
+--     SELECT 'Safety records', 'category', category_id, 3650, 'archive' FROM document_categories WHERE name = 'Safety';
+-- INSERT INTO retention_policies (name, scope, keep_days, action)
+--     VALUES ('Quick share messages', 'quick_share', 90, 'purge');
+
+-- Archived files keep their row; the object moves to the archive bucket.
+-- A legal hold blocks both archival and purge.
+ALTER TABLE file ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS legal_hold_reason TEXT;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS legal_hold_by TEXT;
+ALTER TABLE file ADD COLUMN IF NOT EXISTS legal_hold_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_file_uploaded_at ON file(uploaded_at);
+
+-- One row per run, scheduled or manual, with what it did to each record.
+-- A run is stored as 'running' when it starts and updated when it ends;
+-- runs a restart cut short are marked 'interrupted'.
+CREATE TABLE IF NOT EXISTS retention_runs (
+    run_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    trigger TEXT NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'done', 'failed', 'interrupted')),
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    counts JSONB NOT NULL DEFAULT '{}',
+    items JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    CHECK ((status = 'running') = (finished_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started ON retention_runs(started_at DESC);
+
+-- Retention is managed through the Go admin API; clients get no access.
+ALTER TABLE retention_policies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE retention_runs ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,33 @@
+-- SQL migrations for departmental routing rules
+-- Run this in Supabase SQL Editor
+
+CREATE TABLE IF NOT EXISTS routing_rules (
+    rule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (length(trim(name)) > 0),
+    rule_type TEXT NOT NULL CHECK (rule_type IN ('keyword', 'regex', 'doc_type', 'sender', 'llm')),
+    pattern TEXT NOT NULL CHECK (length(trim(pattern)) > 0),
+    d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE CASCADE,
+    priority INT NOT NULL DEFAULT 100,
+    min_confidence REAL CHECK (min_confidence IS NULL OR (min_confidence >= 0 AND min_confidence <= 1)),
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_routing_rules_enabled ON routing_rules(priority, name) WHERE enabled;
+
+-- Departments added to a file by routing, and the rule that added them.
+-- Re-running routing for a file never adds or notifies a department twice.
+CREATE TABLE IF NOT EXISTS file_routing (
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE CASCADE,
+    rule_id UUID REFERENCES routing_rules(rule_id) ON DELETE SET NULL,
+    rule_name TEXT NOT NULL,
+    reason TEXT,
+    routed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (f_uuid, d_uuid)
+);
+
+ALTER TABLE routing_rules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_routing ENABLE ROW LEVEL SECURITY;
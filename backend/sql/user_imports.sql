@@ -0,0 +1,23 @@
+-- SQL migrations for bulk user imports
+-- Run this in Supabase SQL Editor
+
+-- One row per CSV import (dry runs included). rows holds the per-row
+-- outcome the report CSV is built from; counts tallies rows by status.
+-- Imports run in the background: status is 'running' until every valid
+-- row has been created, then 'done'. Imports a restart cut short are
+-- marked 'interrupted'.
+CREATE TABLE IF NOT EXISTS user_imports (
+    import_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_by TEXT NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL CHECK (status IN ('running', 'done', 'interrupted')),
+    counts JSONB NOT NULL DEFAULT '{}',
+    rows JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_imports_created ON user_imports(created_at DESC);
+
+-- Imports are run through the Go admin API; clients get no access.
+ALTER TABLE user_imports ENABLE ROW LEVEL SECURITY;
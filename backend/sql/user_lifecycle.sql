@@ -0,0 +1,7 @@
+-- SQL migrations for soft-deactivated users
+-- Run this in Supabase SQL Editor
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_active_department ON users(d_uuid) WHERE is_active;
@@ -0,0 +1,68 @@
+-- SQL migrations for approval workflows (/v1/workflows, /v1/admin/workflow-templates)
+-- Run this in Supabase SQL Editor
+
+-- steps is an ordered JSON array of
+-- {name, role: head|member, d_uuid (empty: the uploader's department), sla_hours, mode: sequential|parallel}.
+CREATE TABLE IF NOT EXISTS workflow_templates (
+    template_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (char_length(btrim(name)) BETWEEN 1 AND 120),
+    description TEXT NOT NULL DEFAULT '' CHECK (char_length(description) <= 1000),
+    active BOOLEAN NOT NULL DEFAULT true,
+    steps JSONB NOT NULL CHECK (jsonb_typeof(steps) = 'array' AND jsonb_array_length(steps) BETWEEN 1 AND 20),
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- An instance copies its template's steps, so editing or deleting the
+-- template does not change running approvals. version guards concurrent
+-- decisions.
+CREATE TABLE IF NOT EXISTS workflow_instances (
+    workflow_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id UUID REFERENCES workflow_templates(template_id) ON DELETE SET NULL,
+    template_name TEXT NOT NULL,
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    started_by UUID NOT NULL REFERENCES users(uuid),
+    status TEXT NOT NULL DEFAULT 'in_progress' CHECK (status IN ('in_progress', 'approved', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CHECK ((status = 'in_progress') = (completed_at IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_instances_file ON workflow_instances(f_uuid);
+CREATE INDEX IF NOT EXISTS idx_workflow_instances_started_by ON workflow_instances(started_by, created_at DESC);
+
+-- Deleting a department needs a reassignment target while steps name it;
+-- models.DeleteDepartment moves them, and the steps of templates, there.
+CREATE TABLE IF NOT EXISTS workflow_steps (
+    workflow_id UUID NOT NULL REFERENCES workflow_instances(workflow_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL CHECK (position >= 0),
+    stage INTEGER NOT NULL CHECK (stage >= 0),
+    name TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('head', 'member')),
+    d_uuid UUID NOT NULL REFERENCES department(d_uuid),
+    mode TEXT NOT NULL CHECK (mode IN ('sequential', 'parallel')),
+    sla_hours INTEGER NOT NULL DEFAULT 0 CHECK (sla_hours >= 0),
+    status TEXT NOT NULL CHECK (status IN ('waiting', 'pending', 'approved', 'rejected', 'skipped')),
+    started_at TIMESTAMPTZ,
+    due_at TIMESTAMPTZ,
+    acted_by UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    acted_at TIMESTAMPTZ,
+    comment TEXT NOT NULL DEFAULT '' CHECK (char_length(comment) <= 2000),
+    overdue_notified_at TIMESTAMPTZ,
+    PRIMARY KEY (workflow_id, position)
+);
+
+-- Approvers' inboxes and the SLA check look for pending steps.
+CREATE INDEX IF NOT EXISTS idx_workflow_steps_pending ON workflow_steps(d_uuid, due_at) WHERE status = 'pending';
+
+-- Approvers read the file while their step is pending, through
+-- file_access_departments (sql/file_access_departments.sql) rather than
+-- file_department, so access ends with the step.
+
+-- Workflows are managed through the Go API, which checks approvers and
+-- file access; clients get no direct access.
+ALTER TABLE workflow_templates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workflow_instances ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workflow_steps ENABLE ROW LEVEL SECURITY;
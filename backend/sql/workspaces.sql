@@ -0,0 +1,68 @@
+-- SQL migrations for cross-department workspaces (/v1/workspaces)
+-- Run this in Supabase SQL Editor
+
+-- A workspace is owned by its creator's department. The creator and that
+-- department's heads manage it. A department that owns workspaces can only
+-- be deleted with a reassignment target, which takes them over
+-- (models.DeleteDepartment).
+CREATE TABLE IF NOT EXISTS workspaces (
+    workspace_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (char_length(btrim(name)) BETWEEN 1 AND 120),
+    description TEXT NOT NULL DEFAULT '' CHECK (char_length(description) <= 1000),
+    created_by UUID NOT NULL REFERENCES users(uuid),
+    owner_d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE RESTRICT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Earlier versions deleted a department's workspaces with it.
+ALTER TABLE workspaces DROP CONSTRAINT IF EXISTS workspaces_owner_d_uuid_fkey;
+ALTER TABLE workspaces ADD CONSTRAINT workspaces_owner_d_uuid_fkey
+    FOREIGN KEY (owner_d_uuid) REFERENCES department(d_uuid) ON DELETE RESTRICT;
+
+-- Invited departments see the workspace's name; joined departments read
+-- its files and comments.
+CREATE TABLE IF NOT EXISTS workspace_departments (
+    workspace_id UUID NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+    d_uuid UUID NOT NULL REFERENCES department(d_uuid) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('invited', 'joined')),
+    invited_by UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    invited_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    joined_at TIMESTAMPTZ,
+    PRIMARY KEY (workspace_id, d_uuid),
+    CHECK ((status = 'joined') = (joined_at IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_departments_dept ON workspace_departments(d_uuid, status);
+
+-- Files are linked, not copied: deleting a file removes its links.
+CREATE TABLE IF NOT EXISTS workspace_files (
+    workspace_id UUID NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+    f_uuid UUID NOT NULL REFERENCES file(f_uuid) ON DELETE CASCADE,
+    linked_by UUID REFERENCES users(uuid) ON DELETE SET NULL,
+    linked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, f_uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_files_file ON workspace_files(f_uuid);
+
+CREATE TABLE IF NOT EXISTS workspace_comments (
+    comment_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(workspace_id) ON DELETE CASCADE,
+    f_uuid UUID REFERENCES file(f_uuid) ON DELETE CASCADE,
+    author_uuid UUID NOT NULL REFERENCES users(uuid) ON DELETE CASCADE,
+    body TEXT NOT NULL CHECK (char_length(btrim(body)) BETWEEN 1 AND 4000),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_comments_workspace ON workspace_comments(workspace_id, created_at);
+
+-- Joined departments read linked files through file_access_departments
+-- (sql/file_access_departments.sql).
+
+-- Workspaces are managed through the Go API, which applies membership and
+-- file access; clients get no direct access.
+ALTER TABLE workspaces ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workspace_departments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workspace_files ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workspace_comments ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,166 @@
+// Package templates renders notification emails from per-event text and
+// HTML templates. Built-in defaults are embedded; any file placed in the
+// override directory (EMAIL_TEMPLATE_DIR) replaces its default and is picked
+// up on the next render without a restart.
+//
+// Each event has two files:
+//
+//	<event>.txt   defines "subject" and "content" (text/template)
+//	<event>.html  defines "content" (html/template)
+//
+// and is wrapped by the shared layout.txt / layout.html, which define "layout".
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed default/*
+var defaults embed.FS
+
+// Rendered is one message in both formats.
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// View is what every template receives; event-specific fields are in Data.
+type View struct {
+	AppURL string
+	Year   int
+	Data   interface{}
+}
+
+type Renderer struct {
+	AppURL string
+
+	dir   string
+	mu    sync.Mutex
+	cache map[string]*parsed
+}
+
+type parsed struct {
+	signature string
+	text      *texttemplate.Template
+	html      *htmltemplate.Template
+}
+
+// New returns a renderer that prefers files in overrideDir (may be empty).
+func New(overrideDir, appURL string) *Renderer {
+	return &Renderer{AppURL: strings.TrimRight(appURL, "/"), dir: overrideDir, cache: map[string]*parsed{}}
+}
+
+// FromEnv configures a renderer from EMAIL_TEMPLATE_DIR and APP_URL.
+func FromEnv() *Renderer {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	return New(os.Getenv("EMAIL_TEMPLATE_DIR"), appURL)
+}
+
+var funcs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"date":  func(t time.Time) string { return t.Format("02 Jan 2006") },
+	"datetime": func(t time.Time) string {
+		return t.Format("02 Jan 2006 15:04 MST")
+	},
+}
+
+// Render executes the templates for event with data.
+func (r *Renderer) Render(event string, data interface{}) (Rendered, error) {
+	p, err := r.load(event)
+	if err != nil {
+		return Rendered{}, err
+	}
+	view := View{AppURL: r.AppURL, Year: time.Now().Year(), Data: data}
+
+	var subject, text, html bytes.Buffer
+	if err := p.text.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return Rendered{}, fmt.Errorf("%s subject: %w", event, err)
+	}
+	if err := p.text.ExecuteTemplate(&text, "layout", view); err != nil {
+		return Rendered{}, fmt.Errorf("%s text: %w", event, err)
+	}
+	if err := p.html.ExecuteTemplate(&html, "layout", view); err != nil {
+		return Rendered{}, fmt.Errorf("%s html: %w", event, err)
+	}
+	return Rendered{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// load parses the event's templates, reusing the cached copy until an
+// override file appears, disappears or changes.
+func (r *Renderer) load(event string) (*parsed, error) {
+	if strings.ContainsAny(event, `/\.`) {
+		return nil, fmt.Errorf("invalid template name %q", event)
+	}
+	names := []string{"layout.txt", event + ".txt", "layout.html", event + ".html"}
+	signature := r.signature(names)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.cache[event]; ok && p.signature == signature {
+		return p, nil
+	}
+
+	srcs := make([]string, len(names))
+	for i, name := range names {
+		b, err := r.read(name)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		srcs[i] = string(b)
+	}
+	text, err := texttemplate.New(event).Funcs(funcs).Parse(srcs[0] + srcs[1])
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New(event).Funcs(funcs).Parse(srcs[2] + srcs[3])
+	if err != nil {
+		return nil, err
+	}
+	p := &parsed{signature: signature, text: text, html: html}
+	r.cache[event] = p
+	return p, nil
+}
+
+func (r *Renderer) read(name string) ([]byte, error) {
+	if r.dir != "" {
+		b, err := os.ReadFile(filepath.Join(r.dir, name))
+		if err == nil {
+			return b, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return fs.ReadFile(defaults, "default/"+name)
+}
+
+func (r *Renderer) signature(names []string) string {
+	if r.dir == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(r.dir, name)); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return b.String()
+}
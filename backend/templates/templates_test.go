@@ -0,0 +1,103 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fileData struct {
+	FUUID, FileName, Departments, Summary string
+}
+
+func TestRenderDefaults(t *testing.T) {
+	r := New("", "https://docs.kmrl.example/")
+	out, err := r.Render("new_file", fileData{FUUID: "f1", FileName: "Rolling <stock>.pdf", Departments: "Operations"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Subject != "New file uploaded: Rolling <stock>.pdf" {
+		t.Fatalf("unexpected subject %q", out.Subject)
+	}
+	if !strings.Contains(out.Text, "Departments: Operations") || !strings.Contains(out.Text, "https://docs.kmrl.example/file/f1") {
+		t.Fatalf("unexpected text:\n%s", out.Text)
+	}
+	if !strings.Contains(out.HTML, "Rolling &lt;stock&gt;.pdf") || !strings.Contains(out.HTML, "Kochi Metro Rail Limited") {
+		t.Fatalf("expected escaped, branded HTML:\n%s", out.HTML)
+	}
+
+	for _, event := range []string{"quick_share", "summary_ready", "deadline_reminder", "workspace_event", "comment_mention", "workflow_event", "file_trashed"} {
+		if _, err := r.load(event); err != nil {
+			t.Fatalf("%s: %v", event, err)
+		}
+	}
+	due := struct {
+		Title, FUUID, FileName, Department string
+		DueAt                              time.Time
+	}{Title: "Safety audit", DueAt: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)}
+	out, err = r.Render("deadline_reminder", due)
+	if err != nil || out.Subject != "Reminder: Safety audit due 04 Mar 2025" {
+		t.Fatalf("unexpected deadline reminder: %q %v", out.Subject, err)
+	}
+	event := struct {
+		WorkspaceID, WorkspaceName, Event, Actor, Department, FUUID, FileName, Comment string
+	}{WorkspaceName: "Phase 2", Event: "comment", Actor: "Ravi", FUUID: "f1", FileName: "plan.pdf", Comment: "Looks good"}
+	out, err = r.Render("workspace_event", event)
+	if err != nil || out.Subject != "New comment in Phase 2" || !strings.Contains(out.Text, "Ravi commented in \"Phase 2\" on plan.pdf.") {
+		t.Fatalf("unexpected workspace event: %q %q %v", out.Subject, out.Text, err)
+	}
+	mention := struct {
+		CommentID, FUUID, FileName, Author, Comment string
+		Page                                        int
+	}{FUUID: "f1", FileName: "plan.pdf", Author: "Ravi", Comment: "@asha see clause 4", Page: 3}
+	out, err = r.Render("comment_mention", mention)
+	if err != nil || out.Subject != "Ravi mentioned you on plan.pdf" || !strings.Contains(out.Text, "(page 3)") {
+		t.Fatalf("unexpected comment mention: %q %q %v", out.Subject, out.Text, err)
+	}
+	stepDue := time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC)
+	step := struct {
+		WorkflowID, TemplateName, Event, Step, Actor, FUUID, FileName, Comment string
+		DueAt                                                                  *time.Time
+	}{TemplateName: "Purchase request", Event: "step_pending", Step: "Finance", FUUID: "f1", FileName: "po.pdf", DueAt: &stepDue}
+	out, err = r.Render("workflow_event", step)
+	if err != nil || out.Subject != "Approval needed: po.pdf" || !strings.Contains(out.Text, "due 05 Mar 2025 09:00 UTC") {
+		t.Fatalf("unexpected workflow event: %q %q %v", out.Subject, out.Text, err)
+	}
+	trashed := struct {
+		FUUID, FileName, Actor string
+		PurgeAt                time.Time
+	}{FUUID: "f1", FileName: "po.pdf", Actor: "Meera", PurgeAt: time.Date(2025, 4, 4, 9, 0, 0, 0, time.UTC)}
+	out, err = r.Render("file_trashed", trashed)
+	if err != nil || out.Subject != "Meera moved po.pdf to the trash" || !strings.Contains(out.Text, "until 04 Apr 2025") {
+		t.Fatalf("unexpected file trashed: %q %q %v", out.Subject, out.Text, err)
+	}
+}
+
+func TestRenderOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	r := New(dir, "")
+	if _, err := r.Render("summary_ready", fileData{FileName: "a.pdf"}); err != nil {
+		t.Fatal(err)
+	}
+
+	override := `{{define "subject"}}Custom: {{.Data.FileName}}{{end}}{{define "content"}}custom body{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "summary_ready.txt"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render("summary_ready", fileData{FileName: "a.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Subject != "Custom: a.pdf" || !strings.HasPrefix(out.Text, "custom body") {
+		t.Fatalf("override not picked up: %+v", out)
+	}
+	if !strings.Contains(out.HTML, "The summary for <strong>a.pdf</strong>") {
+		t.Fatal("HTML should still come from the default template")
+	}
+
+	if _, err := r.Render("../secrets", nil); err == nil {
+		t.Fatal("expected path-like template names to be rejected")
+	}
+}
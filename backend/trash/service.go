@@ -0,0 +1,191 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/access"
+)
+
+// Store persists trash state. Implementations: NewPostgresStore and
+// NewMemoryStore.
+type Store interface {
+	// File returns nil when the file does not exist, in the trash or not.
+	File(fuuid string) (*File, error)
+	// Trashed lists the files in the trash uploaded by uuid or, when
+	// headDUUID is set, shared with that department, most recently deleted
+	// first.
+	Trashed(uuid, headDUUID string) ([]File, error)
+	// Expired lists the files moved to the trash before the cutoff.
+	Expired(before time.Time) ([]File, error)
+	// Trash returns ErrNotFound when the file does not exist or is already
+	// in the trash; Restore when it does not exist or is not in the trash.
+	Trash(fuuid, by string, at time.Time) error
+	Restore(fuuid string) error
+	// Purge deletes a file that has been in the trash since before
+	// deletedBefore, with its OCR, summaries and department links. It
+	// returns ErrSkipped when the file was restored or placed under legal
+	// hold meanwhile.
+	Purge(fuuid string, deletedBefore time.Time) error
+}
+
+// Storage removes stored objects.
+type Storage interface {
+	// Delete removes the objects from file_storage or, when archived, from
+	// the archive bucket.
+	Delete(paths []string, archived bool) error
+}
+
+// Event tells a file's uploader that someone else moved it to the trash.
+type Event struct {
+	Recipient string
+	File      File
+}
+
+// PurgeResult reports one purge pass. Errors name the files that could not
+// be purged, or whose objects could not be deleted after their rows were.
+type PurgeResult struct {
+	Purged  []File
+	Skipped int
+	Errors  []string
+}
+
+type Service struct {
+	store   Store
+	storage Storage
+	// Window is how long trashed files stay restorable.
+	Window time.Duration
+	// Notify receives deletions; nil drops them.
+	Notify func(Event)
+	Now    func() time.Time
+}
+
+func NewService(store Store, storage Storage, window time.Duration) *Service {
+	return &Service{store: store, storage: storage, Window: window, Now: time.Now}
+}
+
+// withPurgeAt sets f.PurgeAt from its deletion time.
+func (s *Service) withPurgeAt(f File) File {
+	if f.DeletedAt != nil {
+		at := f.DeletedAt.Add(s.Window)
+		f.PurgeAt = &at
+	}
+	return f
+}
+
+// authorize returns ErrNotFound when v may not even see f, so the trash
+// does not reveal files the user could not otherwise find.
+func authorize(v access.Viewer, f File) error {
+	if CanManage(v, f) {
+		return nil
+	}
+	if access.Check(v, f.access()).Allowed {
+		return ErrForbidden
+	}
+	return ErrNotFound
+}
+
+// Delete moves fuuid to the trash and tells its uploader when someone else
+// deleted it.
+func (s *Service) Delete(v access.Viewer, fuuid string) (File, error) {
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return File{}, err
+	}
+	if f == nil || f.DeletedAt != nil {
+		return File{}, ErrNotFound
+	}
+	if err := authorize(v, *f); err != nil {
+		return File{}, err
+	}
+	if err := s.store.Trash(fuuid, v.UUID, s.Now()); err != nil {
+		return File{}, err
+	}
+	// Read it back for the deleter's name.
+	if f, err = s.store.File(fuuid); err != nil || f == nil {
+		return File{}, fmt.Errorf("reading trashed file back: %v", err)
+	}
+	out := s.withPurgeAt(*f)
+	if s.Notify != nil && out.OwnerUUID != "" && !strings.EqualFold(out.OwnerUUID, v.UUID) {
+		s.Notify(Event{Recipient: out.OwnerUUID, File: out})
+	}
+	return out, nil
+}
+
+// Restore takes fuuid out of the trash while its restore window is open.
+func (s *Service) Restore(v access.Viewer, fuuid string) (File, error) {
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return File{}, err
+	}
+	if f == nil || f.DeletedAt == nil {
+		return File{}, ErrNotFound
+	}
+	if err := authorize(v, *f); err != nil {
+		return File{}, err
+	}
+	if !s.Now().Before(*s.withPurgeAt(*f).PurgeAt) {
+		return File{}, ErrExpired
+	}
+	if err := s.store.Restore(fuuid); err != nil {
+		return File{}, err
+	}
+	f.DeletedAt, f.DeletedBy, f.DeletedByName = nil, "", ""
+	return *f, nil
+}
+
+// List returns the trashed files v may restore, including those whose
+// window has closed but which have not been purged yet.
+func (s *Service) List(v access.Viewer) ([]File, error) {
+	if v.UUID == "" || !v.Active {
+		return []File{}, nil
+	}
+	head := ""
+	if v.IsHead() {
+		head = v.DUUID
+	}
+	files, err := s.store.Trashed(v.UUID, head)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]File, 0, len(files))
+	for _, f := range files {
+		if CanManage(v, f) {
+			out = append(out, s.withPurgeAt(f))
+		}
+	}
+	return out, nil
+}
+
+// PurgeExpired permanently removes the files whose restore window has
+// closed. Rows go first: an object left behind by a failed delete is only
+// wasted space, while a row without its object would be a broken file.
+func (s *Service) PurgeExpired() (PurgeResult, error) {
+	res := PurgeResult{Purged: []File{}}
+	cutoff := s.Now().Add(-s.Window)
+	files, err := s.store.Expired(cutoff)
+	if err != nil {
+		return res, err
+	}
+	for _, f := range files {
+		err := s.store.Purge(f.FUUID, cutoff)
+		if errors.Is(err, ErrSkipped) {
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", f.FUUID, err))
+			continue
+		}
+		if f.FilePath != "" {
+			paths := append([]string{f.FilePath}, f.Previews...)
+			if err := s.storage.Delete(paths, f.ArchivedAt != nil); err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: deleting %s: %v", f.FUUID, strings.Join(paths, ", "), err))
+			}
+		}
+		res.Purged = append(res.Purged, s.withPurgeAt(f))
+	}
+	return res, nil
+}
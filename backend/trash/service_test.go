@@ -0,0 +1,209 @@
+package trash
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+const (
+	deptOps    = "33333333-3333-3333-3333-333333333333"
+	deptStores = "44444444-4444-4444-4444-444444444444"
+)
+
+var (
+	now = time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+
+	owner     = access.Viewer{UUID: "u-owner", DUUID: deptOps, Active: true}
+	opsHead   = access.Viewer{UUID: "u-head", DUUID: deptOps, Position: "head", Active: true}
+	opsMember = access.Viewer{UUID: "u-member", DUUID: deptOps, Active: true}
+	storeHead = access.Viewer{UUID: "u-stores", DUUID: deptStores, Position: "head", Active: true}
+)
+
+func newTestService(t *testing.T) (*Service, *MemoryStore, *MemoryStorage, *[]Event) {
+	t.Helper()
+	store := NewMemoryStore()
+	store.AddUser(opsHead.UUID, "Meera")
+	store.AddUser(owner.UUID, "Ravi")
+	storage := NewMemoryStorage()
+	svc := NewService(store, storage, 30*24*time.Hour)
+	svc.Now = func() time.Time { return now }
+	events := &[]Event{}
+	svc.Notify = func(e Event) { *events = append(*events, e) }
+	return svc, store, storage, events
+}
+
+func addFile(store *MemoryStore, fuuid, sensitivity string) {
+	store.AddFile(File{
+		FUUID: fuuid, Name: fuuid + ".pdf", OwnerUUID: owner.UUID, Sensitivity: sensitivity,
+		Departments: []string{deptOps}, FilePath: fuuid + ".pdf", Previews: []string{fuuid + ".pdf.preview/thumbnail.jpg"},
+	})
+}
+
+func TestDeletePermissions(t *testing.T) {
+	svc, store, _, events := newTestService(t)
+	addFile(store, "f1", access.Internal)
+	addFile(store, "f2", access.Restricted)
+
+	if _, err := svc.Delete(opsMember, "f1"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("a member of the department may see but not delete: %v", err)
+	}
+	if _, err := svc.Delete(storeHead, "f2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("a file the user cannot see should not be found: %v", err)
+	}
+	if _, err := svc.Delete(owner, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	f, err := svc.Delete(owner, "f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.DeletedAt == nil || !f.PurgeAt.Equal(now.AddDate(0, 0, 30)) || f.DeletedByName != "Ravi" {
+		t.Fatalf("unexpected trashed file: %+v", f)
+	}
+	if len(*events) != 0 {
+		t.Fatalf("the owner deleted their own file; nobody to notify: %+v", *events)
+	}
+	if _, err := svc.Delete(owner, "f1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("a trashed file cannot be deleted again: %v", err)
+	}
+
+	if _, err := svc.Delete(opsHead, "f2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 1 || (*events)[0].Recipient != owner.UUID || (*events)[0].File.DeletedByName != "Meera" {
+		t.Fatalf("owner should be told who deleted the file: %+v", *events)
+	}
+}
+
+func TestRestoreWindow(t *testing.T) {
+	svc, store, _, _ := newTestService(t)
+	addFile(store, "f1", access.Confidential)
+	addFile(store, "f2", access.Confidential)
+	if _, err := svc.Delete(owner, "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Restore(owner, "f2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("a file outside the trash cannot be restored: %v", err)
+	}
+	if _, err := svc.Restore(opsMember, "f1"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+
+	f, err := svc.Restore(opsHead, "f1")
+	if err != nil || f.DeletedAt != nil {
+		t.Fatalf("restore failed: %+v %v", f, err)
+	}
+
+	if _, err := svc.Delete(owner, "f1"); err != nil {
+		t.Fatal(err)
+	}
+	svc.Now = func() time.Time { return now.AddDate(0, 0, 30) }
+	if _, err := svc.Restore(owner, "f1"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("restore after the window should fail: %v", err)
+	}
+}
+
+func TestListShowsWhatTheUserMayRestore(t *testing.T) {
+	svc, store, _, _ := newTestService(t)
+	addFile(store, "f1", access.Internal)
+	addFile(store, "f2", access.Internal)
+	addFile(store, "f3", access.Internal)
+	for i, id := range []string{"f1", "f2"} {
+		svc.Now = func() time.Time { return now.Add(time.Duration(i) * time.Hour) }
+		if _, err := svc.Delete(owner, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(v access.Viewer) []string {
+		t.Helper()
+		list, err := svc.List(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := []string{}
+		for _, f := range list {
+			if f.PurgeAt == nil {
+				t.Fatalf("%s has no purge time", f.FUUID)
+			}
+			out = append(out, f.FUUID)
+		}
+		return out
+	}
+	if got := ids(owner); !reflect.DeepEqual(got, []string{"f2", "f1"}) {
+		t.Fatalf("owner trash: %v", got)
+	}
+	if got := ids(opsHead); !reflect.DeepEqual(got, []string{"f2", "f1"}) {
+		t.Fatalf("head trash: %v", got)
+	}
+	if got := ids(opsMember); len(got) != 0 {
+		t.Fatalf("members may not restore: %v", got)
+	}
+	if got := ids(storeHead); len(got) != 0 {
+		t.Fatalf("heads of other departments see nothing: %v", got)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	svc, store, storage, _ := newTestService(t)
+	for _, id := range []string{"f1", "f2", "f3", "f4"} {
+		addFile(store, id, access.Internal)
+	}
+	archivedAt := now.AddDate(0, 0, -100)
+	f4, _ := store.File("f4")
+	f4.ArchivedAt, f4.Previews = &archivedAt, nil
+	store.AddFile(*f4)
+
+	for _, id := range []string{"f1", "f2", "f4"} {
+		if _, err := svc.Delete(owner, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.SetLegalHold("f2", true)
+	svc.Now = func() time.Time { return now.AddDate(0, 0, 10) }
+	if _, err := svc.Delete(owner, "f3"); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.Now = func() time.Time { return now.AddDate(0, 0, 31) }
+	res, err := svc.PurgeExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Purged) != 2 || res.Skipped != 1 || len(res.Errors) != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	for id, want := range map[string]bool{"f1": false, "f2": true, "f3": true, "f4": false} {
+		if f, _ := store.File(id); (f != nil) != want {
+			t.Fatalf("%s kept = %v, want %v", id, f != nil, want)
+		}
+	}
+	if want := []string{"f1.pdf", "f1.pdf.preview/thumbnail.jpg"}; !reflect.DeepEqual(storage.Deleted, want) {
+		t.Fatalf("deleted objects: %v", storage.Deleted)
+	}
+	if want := []string{"f4.pdf"}; !reflect.DeepEqual(storage.DeletedArchived, want) {
+		t.Fatalf("archived objects should be deleted from the archive bucket: %v", storage.DeletedArchived)
+	}
+}
+
+func TestPurgeReportsObjectFailures(t *testing.T) {
+	svc, store, storage, _ := newTestService(t)
+	addFile(store, "f1", access.Internal)
+	if _, err := svc.Delete(owner, "f1"); err != nil {
+		t.Fatal(err)
+	}
+	storage.Fail["f1.pdf"] = true
+	svc.Now = func() time.Time { return now.AddDate(0, 0, 31) }
+	res, err := svc.PurgeExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Purged) != 1 || len(res.Errors) != 1 {
+		t.Fatalf("the row is gone; the leftover object should be reported: %+v", res)
+	}
+}
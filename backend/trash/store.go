@@ -0,0 +1,240 @@
+package trash
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/models"
+	"backend/preview"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (file.deleted_at)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+// fileFromRow lists the file's rendered previews by their storage paths.
+func fileFromRow(r models.TrashFile) File {
+	f := File{
+		FUUID: r.FUUID, Name: r.FileName, OwnerUUID: r.OwnerUUID, Sensitivity: r.Sensitivity,
+		DeletedAt: r.DeletedAt, DeletedBy: r.DeletedBy, DeletedByName: r.DeletedByName,
+		Departments: r.Departments, FilePath: r.FilePath, ArchivedAt: r.ArchivedAt,
+	}
+	if r.FilePath == "" {
+		return f
+	}
+	if r.HasThumbnail {
+		f.Previews = append(f.Previews, preview.ThumbnailPath(r.FilePath))
+	}
+	for _, n := range r.PreviewPages {
+		f.Previews = append(f.Previews, preview.PagePath(r.FilePath, n))
+	}
+	return f
+}
+
+func filesFromRows(rows []models.TrashFile) []File {
+	out := make([]File, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fileFromRow(r))
+	}
+	return out
+}
+
+func (s pgStore) File(fuuid string) (*File, error) {
+	r, err := models.GetTrashFile(s.db, fuuid)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	f := fileFromRow(*r)
+	return &f, nil
+}
+
+func (s pgStore) Trashed(uuid, headDUUID string) ([]File, error) {
+	rows, err := models.ListTrashedFiles(s.db, uuid, headDUUID)
+	if err != nil {
+		return nil, err
+	}
+	return filesFromRows(rows), nil
+}
+
+func (s pgStore) Expired(before time.Time) ([]File, error) {
+	rows, err := models.ListExpiredTrash(s.db, before)
+	if err != nil {
+		return nil, err
+	}
+	return filesFromRows(rows), nil
+}
+
+func (s pgStore) Trash(fuuid, by string, at time.Time) error {
+	err := models.MoveFileToTrash(s.db, fuuid, by, at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s pgStore) Restore(fuuid string) error {
+	err := models.RestoreTrashedFile(s.db, fuuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s pgStore) Purge(fuuid string, deletedBefore time.Time) error {
+	err := models.PurgeTrashedFile(s.db, fuuid, deletedBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSkipped
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store and storage (tests)
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu    sync.Mutex
+	files map[string]File
+	names map[string]string
+	held  map[string]bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{files: map[string]File{}, names: map[string]string{}, held: map[string]bool{}}
+}
+
+// AddUser names uuid for DeletedByName.
+func (m *MemoryStore) AddUser(uuid, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.names[uuid] = name
+}
+
+func (m *MemoryStore) AddFile(f File) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[f.FUUID] = f
+}
+
+// SetLegalHold keeps Purge from removing fuuid.
+func (m *MemoryStore) SetLegalHold(fuuid string, hold bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.held[fuuid] = hold
+}
+
+func (m *MemoryStore) File(fuuid string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// sorted returns the trashed files matching keep, most recently deleted
+// first.
+func (m *MemoryStore) sorted(keep func(File) bool) []File {
+	out := []File{}
+	for _, f := range m.files {
+		if f.DeletedAt != nil && keep(f) {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.After(*out[j].DeletedAt) })
+	return out
+}
+
+func (m *MemoryStore) Trashed(uuid, headDUUID string) ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sorted(func(f File) bool {
+		if strings.EqualFold(f.OwnerUUID, uuid) {
+			return true
+		}
+		for _, d := range f.Departments {
+			if headDUUID != "" && strings.EqualFold(d, headDUUID) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (m *MemoryStore) Expired(before time.Time) ([]File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sorted(func(f File) bool { return f.DeletedAt.Before(before) }), nil
+}
+
+func (m *MemoryStore) Trash(fuuid, by string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok || f.DeletedAt != nil {
+		return ErrNotFound
+	}
+	f.DeletedAt, f.DeletedBy, f.DeletedByName = &at, by, m.names[by]
+	m.files[fuuid] = f
+	return nil
+}
+
+func (m *MemoryStore) Restore(fuuid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok || f.DeletedAt == nil {
+		return ErrNotFound
+	}
+	f.DeletedAt, f.DeletedBy, f.DeletedByName = nil, "", ""
+	m.files[fuuid] = f
+	return nil
+}
+
+func (m *MemoryStore) Purge(fuuid string, deletedBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok || m.held[fuuid] || f.DeletedAt == nil || !f.DeletedAt.Before(deletedBefore) {
+		return ErrSkipped
+	}
+	delete(m.files, fuuid)
+	return nil
+}
+
+// MemoryStorage records deleted objects; Delete fails for paths in Fail.
+type MemoryStorage struct {
+	mu              sync.Mutex
+	Deleted         []string
+	DeletedArchived []string
+	Fail            map[string]bool
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{Fail: map[string]bool{}}
+}
+
+func (m *MemoryStorage) Delete(paths []string, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range paths {
+		if m.Fail[p] {
+			return errors.New("storage unavailable")
+		}
+	}
+	if archived {
+		m.DeletedArchived = append(m.DeletedArchived, paths...)
+	} else {
+		m.Deleted = append(m.Deleted, paths...)
+	}
+	return nil
+}
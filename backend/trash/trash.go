@@ -0,0 +1,75 @@
+// Package trash soft-deletes files. A deleted file keeps its row and stored
+// object but disappears from listings, search and every access check; its
+// uploader, or a head of one of its departments, can restore it until the
+// restore window closes. A scheduled purge then removes the row with its
+// OCR, summaries and department links, and the object with its previews.
+package trash
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"backend/access"
+)
+
+// DefaultWindow is how long a file stays restorable unless configured
+// otherwise.
+const DefaultWindow = 30 * 24 * time.Hour
+
+var (
+	ErrNotFound = errors.New("file not found")
+	// ErrForbidden means the user may see the file but not delete or
+	// restore it.
+	ErrForbidden = errors.New("only the uploader or a head of the file's department may delete or restore it")
+	// ErrExpired means the restore window has closed; the file is waiting
+	// for the purge.
+	ErrExpired = errors.New("the restore window for this file has passed")
+	// ErrSkipped means the file was restored, or placed under legal hold,
+	// while the purge was working on it.
+	ErrSkipped = errors.New("file was restored or is under legal hold")
+)
+
+// File is a file with its trash state. DeletedAt is nil for files that are
+// not in the trash; PurgeAt is set by the service for those that are.
+type File struct {
+	FUUID         string     `json:"f_uuid"`
+	Name          string     `json:"f_name"`
+	OwnerUUID     string     `json:"owner_uuid"`
+	Sensitivity   string     `json:"sensitivity"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy     string     `json:"deleted_by,omitempty"`
+	DeletedByName string     `json:"deleted_by_name,omitempty"`
+	PurgeAt       *time.Time `json:"purge_at,omitempty"`
+
+	Departments []string   `json:"-"`
+	FilePath    string     `json:"-"`
+	ArchivedAt  *time.Time `json:"-"`
+	// Previews are the storage paths of the file's rendered previews.
+	Previews []string `json:"-"`
+}
+
+func (f File) access() access.File {
+	return access.File{FUUID: f.FUUID, OwnerUUID: f.OwnerUUID, Sensitivity: f.Sensitivity, Departments: f.Departments}
+}
+
+// CanManage reports whether v may delete and restore f: its uploader, or a
+// head of one of the departments it is shared with. Workspace departments
+// do not count.
+func CanManage(v access.Viewer, f File) bool {
+	if v.UUID == "" || !v.Active {
+		return false
+	}
+	if strings.EqualFold(v.UUID, f.OwnerUUID) {
+		return true
+	}
+	if !v.IsHead() {
+		return false
+	}
+	for _, d := range f.Departments {
+		if strings.EqualFold(d, v.DUUID) {
+			return true
+		}
+	}
+	return false
+}
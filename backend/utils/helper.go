@@ -2,50 +2,31 @@
 package utils
 
 import (
-	"fmt"
-	"net/smtp"
-	"os"
-	"strings"
+	"context"
+	"time"
+
+	"backend/mail"
 )
 
-/* SendGmailNotification sends an email via Gmail SMTP
+// mailTimeout bounds a single send, including waiting for the rate limiter.
+const mailTimeout = 2 * time.Minute
 
- */
+/* SendGmailNotification sends a plain-text email through the configured
+   mail sender (SMTP by default, see mail.FromEnv)
+*/
 
 func SendGmailNotification(toEmail, subject, body string) error {
-	smtpHost := strings.TrimSpace(os.Getenv("SMTP_HOST"))
-	if smtpHost == "" {
-		smtpHost = "smtp.gmail.com"
-	}
-
-	smtpPort := strings.TrimSpace(os.Getenv("SMTP_PORT"))
-	if smtpPort == "" {
-		smtpPort = "587"
-	}
-
-	username := strings.TrimSpace(os.Getenv("SMTP_USERNAME"))
-	password := strings.TrimSpace(os.Getenv("SMTP_PASSWORD"))
-	from := strings.TrimSpace(os.Getenv("SMTP_FROM"))
-
-	// Backward-compatible fallback to existing Gmail env vars.
-	if username == "" {
-		username = strings.TrimSpace(os.Getenv("GMAIL_ADDRESS"))
-	}
-	if password == "" {
-		password = strings.TrimSpace(os.Getenv("GMAIL_APP_PASSWORD"))
-	}
-	if from == "" {
-		from = username
-	}
+	return send(&mail.Message{To: []string{toEmail}, Subject: subject, Text: body})
+}
 
-	if smtpHost == "" || smtpPort == "" || username == "" || password == "" || from == "" {
-		return fmt.Errorf("SMTP credentials are not fully configured")
-	}
+// SendGmailMultipart sends a multipart/alternative email with a plain-text
+// part and an HTML part; clients show the richest part they support.
+func SendGmailMultipart(toEmail, subject, textBody, htmlBody string) error {
+	return send(&mail.Message{To: []string{toEmail}, Subject: subject, Text: textBody, HTML: htmlBody})
+}
 
-	auth := smtp.PlainAuth("", username, password, smtpHost)
-	msg := []byte("To: " + toEmail + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"MIME-version: 1.0;\r\nContent-Type: text/plain; charset=\"UTF-8\";\r\n\r\n" +
-		body)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{toEmail}, msg)
+func send(m *mail.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	return mail.Send(ctx, m)
 }
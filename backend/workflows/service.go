@@ -0,0 +1,490 @@
+package workflows
+
+import (
+	"sort"
+	"time"
+
+	"backend/access"
+)
+
+// File is a document an instance runs on.
+type File struct {
+	access.File
+	Name string
+}
+
+// Filter selects instances. Empty fields match everything; at least one is
+// set by every caller.
+type Filter struct {
+	StartedBy string
+	// PendingDUUID matches in-progress instances with a pending step for
+	// that department.
+	PendingDUUID string
+	FUUID        string
+	// DueBefore matches in-progress instances with a pending step due
+	// before it whose overdue notice has not been sent.
+	DueBefore *time.Time
+}
+
+// Store persists templates and instances. Implementations:
+// NewPostgresStore and NewMemoryStore.
+type Store interface {
+	Templates() ([]Template, error)
+	// Template returns nil when the template does not exist.
+	Template(id string) (*Template, error)
+	CreateTemplate(t Template) (Template, error)
+	// UpdateTemplate and DeleteTemplate return ErrTemplateNotFound for
+	// unknown templates.
+	UpdateTemplate(t Template) (Template, error)
+	DeleteTemplate(id string) error
+	DepartmentExists(dUUID string) (bool, error)
+
+	// User returns nil for unknown or inactive users.
+	User(uuid string) (*Member, error)
+	// Members lists the active users of the departments.
+	Members(dUUIDs []string) ([]Member, error)
+	// File returns nil when the file does not exist. Its
+	// WorkflowDepartments are those of the pending steps of in-progress
+	// instances on it.
+	File(fuuid string) (*File, error)
+
+	CreateInstance(in Instance) (Instance, error)
+	// Instance returns nil when the instance does not exist.
+	Instance(id string) (*Instance, error)
+	// SaveInstance stores in's status and steps if the stored version is
+	// still in.Version, and returns ErrConflict otherwise.
+	SaveInstance(in Instance) (Instance, error)
+	// Instances returns matching instances, newest first.
+	Instances(f Filter) ([]Instance, error)
+}
+
+type Service struct {
+	store Store
+	// Notify receives transitions; nil drops them.
+	Notify func(Event)
+	Now    func() time.Time
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store, Now: time.Now}
+}
+
+// ---------------------------------------------------------------------------
+// Templates (admin)
+// ---------------------------------------------------------------------------
+
+func (s *Service) Templates() ([]Template, error) {
+	list, err := s.store.Templates()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ActiveTemplates lists the templates uploads may start.
+func (s *Service) ActiveTemplates() ([]Template, error) {
+	list, err := s.Templates()
+	if err != nil {
+		return nil, err
+	}
+	out := []Template{}
+	for _, t := range list {
+		if t.Active {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) checkTemplate(t *Template) error {
+	if err := NormalizeTemplate(t); err != nil {
+		return err
+	}
+	for i, st := range t.Steps {
+		if st.DUUID == "" {
+			continue
+		}
+		ok, err := s.store.DepartmentExists(st.DUUID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return invalid("steps[%d]: department %s does not exist", i, st.DUUID)
+		}
+	}
+	return nil
+}
+
+// CreateTemplate validates and stores a template. createdBy identifies the
+// admin.
+func (s *Service) CreateTemplate(t Template, createdBy string) (Template, error) {
+	if err := s.checkTemplate(&t); err != nil {
+		return Template{}, err
+	}
+	t.ID, t.CreatedBy = "", createdBy
+	return s.store.CreateTemplate(t)
+}
+
+// UpdateTemplate replaces template id. Running instances keep the steps they
+// started with. It returns the template as it was, for auditing.
+func (s *Service) UpdateTemplate(id string, t Template) (before, after Template, err error) {
+	old, err := s.store.Template(id)
+	if err != nil {
+		return Template{}, Template{}, err
+	}
+	if old == nil {
+		return Template{}, Template{}, ErrTemplateNotFound
+	}
+	if err := s.checkTemplate(&t); err != nil {
+		return Template{}, Template{}, err
+	}
+	t.ID, t.CreatedBy, t.CreatedAt = old.ID, old.CreatedBy, old.CreatedAt
+	after, err = s.store.UpdateTemplate(t)
+	return *old, after, err
+}
+
+func (s *Service) DeleteTemplate(id string) error {
+	return s.store.DeleteTemplate(id)
+}
+
+// CheckStartable returns an error unless template id exists and is active,
+// so uploads can be refused before anything is stored.
+func (s *Service) CheckStartable(id string) error {
+	t, err := s.store.Template(id)
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Active {
+		return invalid("workflow template %s does not exist or is inactive", id)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Instances
+// ---------------------------------------------------------------------------
+
+// approvers lists who may decide step st.
+func (s *Service) approvers(st Step) ([]string, error) {
+	members, err := s.store.Members([]string{st.DUUID})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, m := range members {
+		if st.Role == RoleMember || m.Position == RoleHead {
+			out = append(out, m.UUID)
+		}
+	}
+	return out, nil
+}
+
+// notify fills in the file's sensitivity, assuming the strictest level if
+// it cannot be read, and passes e on.
+func (s *Service) notify(e Event) {
+	if s.Notify == nil || len(e.Recipients) == 0 {
+		return
+	}
+	e.Sensitivity = access.Restricted
+	if f, err := s.store.File(e.Instance.FUUID); err == nil && f != nil {
+		e.Sensitivity = f.Sensitivity
+	}
+	s.Notify(e)
+}
+
+// notifyStarted asks the approvers of the newly pending steps to decide.
+// Their departments read the file through the pending step (File's
+// WorkflowDepartments); the file's own departments are left alone.
+func (s *Service) notifyStarted(in Instance, positions []int, actor string) error {
+	for _, i := range positions {
+		st := in.Steps[i]
+		to, err := s.approvers(st)
+		if err != nil {
+			return err
+		}
+		s.notify(Event{Kind: EventStepPending, Instance: in, Step: &st, Actor: actor, Recipients: to})
+	}
+	return nil
+}
+
+// Start runs template templateID on file fuuid for its uploader.
+func (s *Service) Start(templateID, fuuid, userID string) (Instance, error) {
+	t, err := s.store.Template(templateID)
+	if err != nil {
+		return Instance{}, err
+	}
+	if t == nil || !t.Active {
+		return Instance{}, ErrTemplateNotFound
+	}
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return Instance{}, err
+	}
+	if f == nil {
+		return Instance{}, ErrNotFound
+	}
+	u, err := s.store.User(userID)
+	if err != nil {
+		return Instance{}, err
+	}
+	if u == nil {
+		return Instance{}, ErrForbidden
+	}
+	in := newInstance(*t, f.FUUID, f.Name, u.UUID, u.DUUID)
+	for i, st := range in.Steps {
+		if st.DUUID == "" {
+			return Instance{}, invalid("steps[%d] needs the uploader's department, but they have none", i)
+		}
+	}
+	started := in.startStage(0, s.Now())
+	in, err = s.store.CreateInstance(in)
+	if err != nil {
+		return Instance{}, err
+	}
+	return in, s.notifyStarted(in, started, u.Name)
+}
+
+// canSee: the initiator, anyone who may decide one of its steps and anyone
+// who may see the file.
+func (s *Service) canSee(u Member, in Instance) (bool, error) {
+	if u.UUID == in.StartedBy {
+		return true, nil
+	}
+	for _, st := range in.Steps {
+		st.Status = StepPending
+		if CanAct(u, st) {
+			return true, nil
+		}
+	}
+	f, err := s.store.File(in.FUUID)
+	if err != nil || f == nil {
+		return false, err
+	}
+	v := access.Viewer{UUID: u.UUID, DUUID: u.DUUID, Position: u.Position, Active: true}
+	return access.Check(v, f.File).Allowed, nil
+}
+
+func (s *Service) user(userID string) (Member, error) {
+	u, err := s.store.User(userID)
+	if err != nil {
+		return Member{}, err
+	}
+	if u == nil {
+		return Member{}, ErrForbidden
+	}
+	return *u, nil
+}
+
+func (s *Service) Get(userID, id string) (Instance, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Instance{}, err
+	}
+	in, err := s.store.Instance(id)
+	if err != nil {
+		return Instance{}, err
+	}
+	if in == nil {
+		return Instance{}, ErrNotFound
+	}
+	ok, err := s.canSee(u, *in)
+	if err != nil {
+		return Instance{}, err
+	}
+	if !ok {
+		return Instance{}, ErrNotFound
+	}
+	return *in, nil
+}
+
+// Pending lists the instances waiting for the caller's decision.
+func (s *Service) Pending(userID string) ([]Instance, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.store.Instances(Filter{PendingDUUID: u.DUUID})
+	if err != nil {
+		return nil, err
+	}
+	out := []Instance{}
+	for _, in := range list {
+		for _, st := range in.Steps {
+			if CanAct(u, st) {
+				out = append(out, in)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// Started lists the instances the caller started.
+func (s *Service) Started(userID string) ([]Instance, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Instances(Filter{StartedBy: u.UUID})
+}
+
+// ForFile lists a file's instances to anyone who may see the file.
+func (s *Service) ForFile(userID, fuuid string) ([]Instance, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return nil, err
+	}
+	v := access.Viewer{UUID: u.UUID, DUUID: u.DUUID, Position: u.Position, Active: true}
+	if f == nil || !access.Check(v, f.File).Allowed {
+		return nil, ErrNotFound
+	}
+	return s.store.Instances(Filter{FUUID: f.FUUID})
+}
+
+// decide applies the caller's decision to the first pending step they may
+// act on (or to step, when given).
+func (s *Service) decide(userID, id string, step *int, approve bool, comment string) (Instance, []Transition, error) {
+	comment, err := checkComment(comment, !approve)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+	u, err := s.user(userID)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+	stored, err := s.store.Instance(id)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+	if stored == nil {
+		return Instance{}, nil, ErrNotFound
+	}
+	in := *stored
+	in.Steps = append([]Step{}, stored.Steps...)
+	if ok, err := s.canSee(u, in); err != nil {
+		return Instance{}, nil, err
+	} else if !ok {
+		return Instance{}, nil, ErrNotFound
+	}
+	if in.Status != StatusInProgress {
+		return Instance{}, nil, ErrClosed
+	}
+	pos := -1
+	for i, st := range in.Steps {
+		if (step == nil || *step == i) && CanAct(u, st) {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return Instance{}, nil, ErrNotPending
+	}
+
+	now := s.Now()
+	st := &in.Steps[pos]
+	at := now
+	st.ActedBy, st.ActedByName, st.ActedAt, st.Comment = u.UUID, u.Name, &at, comment
+	transitions := []Transition{{WorkflowID: in.ID, Step: pos, From: StepPending}}
+	var started []int
+	if approve {
+		st.Status = StepApproved
+		if in.stageDone(st.Stage) {
+			if st.Stage == in.lastStage() {
+				in.close(StatusApproved, now)
+			} else {
+				started = in.startStage(st.Stage+1, now)
+			}
+		}
+	} else {
+		st.Status = StepRejected
+		in.close(StatusRejected, now)
+	}
+	transitions[0].To, transitions[0].Status = st.Status, in.Status
+	for _, i := range started {
+		transitions = append(transitions, Transition{WorkflowID: in.ID, Step: i, From: StepWaiting, To: StepPending, Status: in.Status})
+	}
+	for i := range in.Steps {
+		if in.Steps[i].Status == StepSkipped && stored.Steps[i].Status != StepSkipped {
+			transitions = append(transitions, Transition{WorkflowID: in.ID, Step: i, From: stored.Steps[i].Status, To: StepSkipped, Status: in.Status})
+		}
+	}
+
+	saved, err := s.store.SaveInstance(in)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+	decided := saved.Steps[pos]
+	initiator := []string{}
+	if saved.StartedBy != u.UUID {
+		initiator = append(initiator, saved.StartedBy)
+	}
+	switch saved.Status {
+	case StatusApproved:
+		s.notify(Event{Kind: EventApproved, Instance: saved, Step: &decided, Actor: u.Name, Comment: comment, Recipients: initiator})
+	case StatusRejected:
+		s.notify(Event{Kind: EventRejected, Instance: saved, Step: &decided, Actor: u.Name, Comment: comment, Recipients: initiator})
+	default:
+		s.notify(Event{Kind: EventStepApproved, Instance: saved, Step: &decided, Actor: u.Name, Comment: comment, Recipients: initiator})
+	}
+	return saved, transitions, s.notifyStarted(saved, started, u.Name)
+}
+
+// Approve signs off the caller's pending step; when it completes a stage
+// the next one starts, and when it completes the last the instance is
+// approved.
+func (s *Service) Approve(userID, id string, step *int, comment string) (Instance, []Transition, error) {
+	return s.decide(userID, id, step, true, comment)
+}
+
+// Reject ends the instance. A comment is required.
+func (s *Service) Reject(userID, id string, step *int, comment string) (Instance, []Transition, error) {
+	return s.decide(userID, id, step, false, comment)
+}
+
+// CheckOverdue sends one overdue notice for every pending step past its
+// SLA, to its approvers and the initiator, and returns the steps flagged.
+func (s *Service) CheckOverdue() ([]Transition, error) {
+	now := s.Now()
+	list, err := s.store.Instances(Filter{DueBefore: &now})
+	if err != nil {
+		return nil, err
+	}
+	var out []Transition
+	for _, in := range list {
+		in.Steps = append([]Step{}, in.Steps...)
+		var late []int
+		for i := range in.Steps {
+			if st := &in.Steps[i]; st.Overdue(now) && !st.OverdueNotified {
+				st.OverdueNotified = true
+				late = append(late, i)
+			}
+		}
+		if len(late) == 0 {
+			continue
+		}
+		saved, err := s.store.SaveInstance(in)
+		if err == ErrConflict {
+			// Decided meanwhile; the next run looks again.
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		for _, i := range late {
+			st := saved.Steps[i]
+			to, err := s.approvers(st)
+			if err != nil {
+				return out, err
+			}
+			to = append(to, saved.StartedBy)
+			s.notify(Event{Kind: EventOverdue, Instance: saved, Step: &st, Recipients: to})
+			out = append(out, Transition{WorkflowID: saved.ID, Step: i, From: StepPending, To: EventOverdue, Status: saved.Status})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,218 @@
+package workflows
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+const (
+	deptOps     = "11111111-1111-1111-1111-111111111111"
+	deptFinance = "22222222-2222-2222-2222-222222222222"
+	deptLegal   = "33333333-3333-3333-3333-333333333333"
+	fileOps     = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+)
+
+type recorder struct{ events []Event }
+
+func (r *recorder) notify(e Event) { r.events = append(r.events, e) }
+
+func (r *recorder) kinds() []string {
+	out := []string{}
+	for _, e := range r.events {
+		out = append(out, e.Kind)
+	}
+	return out
+}
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestService() (*Service, *MemoryStore, *recorder, *clock) {
+	store := NewMemoryStore()
+	store.AddUser(Member{UUID: "ops-head", Name: "Ravi", DUUID: deptOps, Position: "head"})
+	store.AddUser(Member{UUID: "ops-clerk", Name: "Asha", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "fin-head", Name: "Meera", DUUID: deptFinance, Position: "head"})
+	store.AddUser(Member{UUID: "fin-clerk", Name: "Joseph", DUUID: deptFinance, Position: "regular"})
+	store.AddUser(Member{UUID: "legal-1", Name: "Anu", DUUID: deptLegal, Position: "regular"})
+	store.AddFile(access.File{FUUID: fileOps, OwnerUUID: "ops-clerk", Sensitivity: access.Confidential, Departments: []string{deptOps}}, "po.pdf")
+
+	svc := NewService(store)
+	rec := &recorder{}
+	svc.Notify = rec.notify
+	c := &clock{t: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)}
+	svc.Now = c.now
+	return svc, store, rec, c
+}
+
+// purchaseRequest: the uploader's head, then Finance and Legal together.
+func purchaseRequest(t *testing.T, svc *Service) Template {
+	t.Helper()
+	tpl, err := svc.CreateTemplate(Template{
+		Name: "Purchase request", Active: true,
+		Steps: []StepTemplate{
+			{Name: "Department head", Role: "head", SLAHours: 24},
+			{Name: "Finance", Role: "head", DUUID: deptFinance, SLAHours: 48},
+			{Name: "Legal", Role: "member", DUUID: deptLegal, Mode: "parallel"},
+		},
+	}, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
+
+func statuses(in Instance) []string {
+	out := []string{}
+	for _, s := range in.Steps {
+		out = append(out, s.Status)
+	}
+	return out
+}
+
+func TestTemplateValidation(t *testing.T) {
+	svc, _, _, _ := newTestService()
+	bad := []Template{
+		{Name: " ", Steps: []StepTemplate{{Name: "a"}}},
+		{Name: "x"},
+		{Name: "x", Steps: []StepTemplate{{Name: "a", Role: "clerk"}}},
+		{Name: "x", Steps: []StepTemplate{{Name: "a", Mode: "parallel"}}},
+		{Name: "x", Steps: []StepTemplate{{Name: "a", SLAHours: -1}}},
+		{Name: "x", Steps: []StepTemplate{{Name: "a", DUUID: "44444444-4444-4444-4444-444444444444"}}},
+	}
+	for _, tpl := range bad {
+		if _, err := svc.CreateTemplate(tpl, "admin-1"); !errors.As(err, &ValidationError{}) {
+			t.Fatalf("%+v: expected a validation error, got %v", tpl, err)
+		}
+	}
+	tpl := purchaseRequest(t, svc)
+	if tpl.Steps[0].Mode != ModeSequential || tpl.Steps[0].DUUID != "" || tpl.Steps[2].Role != RoleMember {
+		t.Fatalf("unexpected steps %+v", tpl.Steps)
+	}
+}
+
+func TestApprovalStages(t *testing.T) {
+	svc, store, rec, c := newTestService()
+	tpl := purchaseRequest(t, svc)
+	in, err := svc.Start(tpl.ID, fileOps, "ops-clerk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Steps[0].DUUID != deptOps || in.Steps[2].Stage != 1 || in.Steps[0].DueAt == nil {
+		t.Fatalf("unexpected layout %+v", in.Steps)
+	}
+	if !reflect.DeepEqual(statuses(in), []string{StepPending, StepWaiting, StepWaiting}) {
+		t.Fatalf("unexpected statuses %v", statuses(in))
+	}
+	if len(rec.events) != 1 || !reflect.DeepEqual(rec.events[0].Recipients, []string{"ops-head"}) {
+		t.Fatalf("the uploader's head should be asked, got %+v", rec.events)
+	}
+
+	if _, _, err := svc.Approve("ops-clerk", in.ID, nil, ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("a regular member cannot sign a head step, got %v", err)
+	}
+	c.t = c.t.Add(time.Hour)
+	in, trans, err := svc.Approve("ops-head", in.ID, nil, "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(statuses(in), []string{StepApproved, StepPending, StepPending}) || len(trans) != 3 {
+		t.Fatalf("Finance and Legal should start together: %v %+v", statuses(in), trans)
+	}
+	f, _ := store.File(fileOps)
+	if !reflect.DeepEqual(f.Departments, []string{deptOps}) || !reflect.DeepEqual(f.WorkflowDepartments, []string{deptFinance, deptLegal}) {
+		t.Fatalf("approving departments should read the file through the workflow, got %v %v", f.Departments, f.WorkflowDepartments)
+	}
+
+	pending, _ := svc.Pending("legal-1")
+	if len(pending) != 1 || pending[0].ID != in.ID {
+		t.Fatalf("Legal should see the request waiting, got %+v", pending)
+	}
+	if _, _, err := svc.Approve("legal-1", in.ID, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	in, _, err = svc.Approve("fin-head", in.ID, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Status != StatusApproved || in.CompletedAt == nil {
+		t.Fatalf("expected the instance approved, got %+v", in)
+	}
+	if f, _ = store.File(fileOps); len(f.WorkflowDepartments) != 0 || !reflect.DeepEqual(f.Departments, []string{deptOps}) {
+		t.Fatalf("access should end with the workflow, got %v %v", f.Departments, f.WorkflowDepartments)
+	}
+	want := []string{EventStepPending, EventStepApproved, EventStepPending, EventStepPending, EventStepApproved, EventApproved}
+	if !reflect.DeepEqual(rec.kinds(), want) {
+		t.Fatalf("events = %v, want %v", rec.kinds(), want)
+	}
+	if _, _, err := svc.Approve("fin-head", in.ID, nil, ""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRejectEndsInstance(t *testing.T) {
+	svc, _, rec, _ := newTestService()
+	tpl := purchaseRequest(t, svc)
+	in, _ := svc.Start(tpl.ID, fileOps, "ops-clerk")
+	if _, _, err := svc.Reject("ops-head", in.ID, nil, " "); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("rejections need a comment, got %v", err)
+	}
+	in, trans, err := svc.Reject("ops-head", in.ID, nil, "Quote is missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Status != StatusRejected || !reflect.DeepEqual(statuses(in), []string{StepRejected, StepSkipped, StepSkipped}) {
+		t.Fatalf("unexpected instance %+v", in)
+	}
+	if len(trans) != 3 || trans[0].To != StepRejected {
+		t.Fatalf("unexpected transitions %+v", trans)
+	}
+	last := rec.events[len(rec.events)-1]
+	if last.Kind != EventRejected || last.Comment != "Quote is missing" || !reflect.DeepEqual(last.Recipients, []string{"ops-clerk"}) {
+		t.Fatalf("the uploader should hear about the rejection, got %+v", last)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	svc, _, _, _ := newTestService()
+	tpl := purchaseRequest(t, svc)
+	in, _ := svc.Start(tpl.ID, fileOps, "ops-clerk")
+	if _, err := svc.Get("fin-clerk", in.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Finance clerks neither approve nor see the file, got %v", err)
+	}
+	if _, err := svc.Get("fin-head", in.ID); err != nil {
+		t.Fatalf("a later approver should see it: %v", err)
+	}
+	started, _ := svc.Started("ops-clerk")
+	if len(started) != 1 {
+		t.Fatalf("expected the uploader's instance, got %+v", started)
+	}
+}
+
+func TestOverdueNotifiedOnce(t *testing.T) {
+	svc, _, rec, c := newTestService()
+	tpl := purchaseRequest(t, svc)
+	in, _ := svc.Start(tpl.ID, fileOps, "ops-clerk")
+	if trans, _ := svc.CheckOverdue(); len(trans) != 0 {
+		t.Fatalf("nothing is late yet: %+v", trans)
+	}
+	c.t = c.t.Add(25 * time.Hour)
+	trans, err := svc.CheckOverdue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trans) != 1 || trans[0].WorkflowID != in.ID || trans[0].Step != 0 {
+		t.Fatalf("unexpected transitions %+v", trans)
+	}
+	last := rec.events[len(rec.events)-1]
+	if last.Kind != EventOverdue || !reflect.DeepEqual(last.Recipients, []string{"ops-head", "ops-clerk"}) {
+		t.Fatalf("approvers and the uploader should be told, got %+v", last)
+	}
+	if trans, _ := svc.CheckOverdue(); len(trans) != 0 {
+		t.Fatalf("overdue notices go out once: %+v", trans)
+	}
+}
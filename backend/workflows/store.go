@@ -0,0 +1,447 @@
+package workflows
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/access"
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (workflow_templates, workflow_instances,
+// workflow_steps)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func templateFromRow(r models.WorkflowTemplateRow) Template {
+	t := Template{
+		ID: r.TemplateID, Name: r.Name, Description: r.Description, Active: r.Active,
+		CreatedBy: r.CreatedBy, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt, Steps: []StepTemplate{},
+	}
+	for _, s := range r.Steps {
+		t.Steps = append(t.Steps, StepTemplate{Name: s.Name, Role: s.Role, DUUID: s.DUUID, SLAHours: s.SLAHours, Mode: s.Mode})
+	}
+	return t
+}
+
+func templateRow(t Template) models.WorkflowTemplateRow {
+	r := models.WorkflowTemplateRow{TemplateID: t.ID, Name: t.Name, Description: t.Description, Active: t.Active, CreatedBy: t.CreatedBy}
+	for _, s := range t.Steps {
+		r.Steps = append(r.Steps, models.WorkflowTemplateStepRow{Name: s.Name, Role: s.Role, DUUID: s.DUUID, SLAHours: s.SLAHours, Mode: s.Mode})
+	}
+	return r
+}
+
+func instanceFromRow(r models.WorkflowInstanceRow) Instance {
+	in := Instance{
+		ID: r.WorkflowID, TemplateID: r.TemplateID, TemplateName: r.TemplateName, FUUID: r.FUUID, FileName: r.FileName,
+		StartedBy: r.StartedBy, Status: r.Status, CreatedAt: r.CreatedAt, CompletedAt: r.CompletedAt, Version: r.Version,
+		Steps: []Step{},
+	}
+	for _, s := range r.Steps {
+		in.Steps = append(in.Steps, Step{
+			Position: s.Position, Stage: s.Stage, Name: s.Name, Role: s.Role, DUUID: s.DUUID, Mode: s.Mode,
+			SLAHours: s.SLAHours, Status: s.Status, StartedAt: s.StartedAt, DueAt: s.DueAt, ActedBy: s.ActedBy,
+			ActedByName: s.ActedByName, ActedAt: s.ActedAt, Comment: s.Comment, OverdueNotified: s.OverdueNotified,
+		})
+	}
+	return in
+}
+
+func instanceRow(in Instance) models.WorkflowInstanceRow {
+	r := models.WorkflowInstanceRow{
+		WorkflowID: in.ID, TemplateID: in.TemplateID, TemplateName: in.TemplateName, FUUID: in.FUUID,
+		StartedBy: in.StartedBy, Status: in.Status, CompletedAt: in.CompletedAt, Version: in.Version,
+	}
+	for _, s := range in.Steps {
+		r.Steps = append(r.Steps, models.WorkflowStepRow{
+			Position: s.Position, Stage: s.Stage, Name: s.Name, Role: s.Role, DUUID: s.DUUID, Mode: s.Mode,
+			SLAHours: s.SLAHours, Status: s.Status, StartedAt: s.StartedAt, DueAt: s.DueAt, ActedBy: s.ActedBy,
+			ActedAt: s.ActedAt, Comment: s.Comment, OverdueNotified: s.OverdueNotified,
+		})
+	}
+	return r
+}
+
+func (s pgStore) Templates() ([]Template, error) {
+	rows, err := models.ListWorkflowTemplates(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Template, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, templateFromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Template(id string) (*Template, error) {
+	r, err := models.GetWorkflowTemplate(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	t := templateFromRow(*r)
+	return &t, nil
+}
+
+func (s pgStore) CreateTemplate(t Template) (Template, error) {
+	row, err := models.InsertWorkflowTemplate(s.db, templateRow(t))
+	if err != nil {
+		return Template{}, err
+	}
+	t.ID, t.CreatedAt, t.UpdatedAt = row.TemplateID, row.CreatedAt, row.UpdatedAt
+	return t, nil
+}
+
+func (s pgStore) UpdateTemplate(t Template) (Template, error) {
+	row, err := models.UpdateWorkflowTemplate(s.db, templateRow(t))
+	if err == sql.ErrNoRows {
+		return Template{}, ErrTemplateNotFound
+	}
+	if err != nil {
+		return Template{}, err
+	}
+	t.UpdatedAt = row.UpdatedAt
+	return t, nil
+}
+
+func (s pgStore) DeleteTemplate(id string) error {
+	if err := models.DeleteWorkflowTemplate(s.db, id); err == sql.ErrNoRows {
+		return ErrTemplateNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) DepartmentExists(dUUID string) (bool, error) {
+	d, err := models.GetDepartmentByUUID(s.db, dUUID)
+	return d != nil, err
+}
+
+func (s pgStore) User(uuid string) (*Member, error) {
+	p, err := models.GetUserProfile(s.db, uuid)
+	if err != nil || p == nil || !p.IsActive {
+		return nil, err
+	}
+	return &Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position}, nil
+}
+
+func (s pgStore) Members(dUUIDs []string) ([]Member, error) {
+	rows, err := models.ListActiveDepartmentMembers(s.db, dUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position})
+	}
+	return out, nil
+}
+
+func (s pgStore) File(fuuid string) (*File, error) {
+	f, err := models.GetFileAccess(s.db, fuuid)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return &File{
+		File: access.File{
+			FUUID: f.FUUID, OwnerUUID: f.OwnerUUID, Sensitivity: f.Sensitivity,
+			Departments: f.Departments, WorkspaceDepartments: f.WorkspaceDepartments,
+			WorkflowDepartments: f.WorkflowDepartments,
+		},
+		Name: f.FileName,
+	}, nil
+}
+
+func (s pgStore) CreateInstance(in Instance) (Instance, error) {
+	row, err := models.InsertWorkflowInstance(s.db, instanceRow(in))
+	if err != nil {
+		return Instance{}, err
+	}
+	in.ID, in.CreatedAt, in.Version = row.WorkflowID, row.CreatedAt, row.Version
+	return in, nil
+}
+
+func (s pgStore) Instance(id string) (*Instance, error) {
+	r, err := models.GetWorkflowInstance(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	in := instanceFromRow(*r)
+	return &in, nil
+}
+
+func (s pgStore) SaveInstance(in Instance) (Instance, error) {
+	saved, err := models.SaveWorkflowInstance(s.db, instanceRow(in))
+	if err != nil {
+		return Instance{}, err
+	}
+	if !saved {
+		return Instance{}, ErrConflict
+	}
+	fresh, err := s.Instance(in.ID)
+	if err != nil || fresh == nil {
+		return Instance{}, err
+	}
+	return *fresh, nil
+}
+
+func (s pgStore) Instances(f Filter) ([]Instance, error) {
+	rows, err := models.ListWorkflowInstances(s.db, models.WorkflowFilter{
+		StartedBy: f.StartedBy, PendingDUUID: f.PendingDUUID, FUUID: f.FUUID, DueBefore: f.DueBefore,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Instance, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, instanceFromRow(r))
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store, for tests
+// ---------------------------------------------------------------------------
+
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       map[string]Member
+	departments map[string]bool
+	files       map[string]File
+	templates   map[string]Template
+	instances   map[string]Instance
+	seq         int
+	now         time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[string]Member{},
+		departments: map[string]bool{},
+		files:       map[string]File{},
+		templates:   map[string]Template{},
+		instances:   map[string]Instance{},
+		now:         time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func (m *MemoryStore) AddUser(u Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.UUID] = u
+	m.departments[u.DUUID] = true
+}
+
+func (m *MemoryStore) AddDepartment(dUUID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.departments[dUUID] = true
+}
+
+func (m *MemoryStore) AddFile(f access.File, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f.Departments = append([]string{}, f.Departments...)
+	m.files[f.FUUID] = File{File: f, Name: name}
+}
+
+func (m *MemoryStore) tick() time.Time {
+	m.now = m.now.Add(time.Minute)
+	return m.now
+}
+
+func copyInstance(in Instance) Instance {
+	in.Steps = append([]Step{}, in.Steps...)
+	return in
+}
+
+func (m *MemoryStore) Templates() ([]Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Template{}
+	for _, t := range m.templates {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) Template(id string) (*Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.templates[id]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *MemoryStore) CreateTemplate(t Template) (Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	t.ID = fmt.Sprintf("tpl-%d", m.seq)
+	t.CreatedAt = m.tick()
+	t.UpdatedAt = t.CreatedAt
+	m.templates[t.ID] = t
+	return t, nil
+}
+
+func (m *MemoryStore) UpdateTemplate(t Template) (Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.templates[t.ID]; !ok {
+		return Template{}, ErrTemplateNotFound
+	}
+	t.UpdatedAt = m.tick()
+	m.templates[t.ID] = t
+	return t, nil
+}
+
+func (m *MemoryStore) DeleteTemplate(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.templates[id]; !ok {
+		return ErrTemplateNotFound
+	}
+	delete(m.templates, id)
+	return nil
+}
+
+func (m *MemoryStore) DepartmentExists(dUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.departments[dUUID], nil
+}
+
+func (m *MemoryStore) User(uuid string) (*Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[uuid]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) Members(dUUIDs []string) ([]Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	want := map[string]bool{}
+	for _, d := range dUUIDs {
+		want[d] = true
+	}
+	var out []Member
+	for _, u := range m.users {
+		if want[u.DUUID] {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UUID < out[j].UUID })
+	return out, nil
+}
+
+func (m *MemoryStore) File(fuuid string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	f.Departments = append([]string{}, f.Departments...)
+	f.WorkflowDepartments = nil
+	seen := map[string]bool{}
+	for _, in := range m.instances {
+		if in.FUUID != fuuid || in.Status != StatusInProgress {
+			continue
+		}
+		for _, st := range in.Steps {
+			if st.Status == StepPending && !seen[st.DUUID] {
+				seen[st.DUUID] = true
+				f.WorkflowDepartments = append(f.WorkflowDepartments, st.DUUID)
+			}
+		}
+	}
+	sort.Strings(f.WorkflowDepartments)
+	return &f, nil
+}
+
+func (m *MemoryStore) CreateInstance(in Instance) (Instance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	in.ID = fmt.Sprintf("wf-%d", m.seq)
+	in.CreatedAt = m.tick()
+	in.Version = 1
+	m.instances[in.ID] = copyInstance(in)
+	return copyInstance(in), nil
+}
+
+func (m *MemoryStore) Instance(id string) (*Instance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.instances[id]
+	if !ok {
+		return nil, nil
+	}
+	in = copyInstance(in)
+	return &in, nil
+}
+
+func (m *MemoryStore) SaveInstance(in Instance) (Instance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.instances[in.ID]
+	if !ok {
+		return Instance{}, ErrNotFound
+	}
+	if old.Version != in.Version {
+		return Instance{}, ErrConflict
+	}
+	in.Version++
+	m.instances[in.ID] = copyInstance(in)
+	return copyInstance(in), nil
+}
+
+func (m *MemoryStore) Instances(f Filter) ([]Instance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Instance{}
+	for _, in := range m.instances {
+		if f.StartedBy != "" && in.StartedBy != f.StartedBy {
+			continue
+		}
+		if f.FUUID != "" && in.FUUID != f.FUUID {
+			continue
+		}
+		if f.PendingDUUID != "" || f.DueBefore != nil {
+			match := false
+			for _, s := range in.Steps {
+				if s.Status != StepPending || in.Status != StatusInProgress {
+					continue
+				}
+				if f.PendingDUUID != "" && s.DUUID != f.PendingDUUID {
+					continue
+				}
+				if f.DueBefore != nil && (s.DueAt == nil || !s.DueAt.Before(*f.DueBefore) || s.OverdueNotified) {
+					continue
+				}
+				match = true
+			}
+			if !match {
+				continue
+			}
+		}
+		out = append(out, copyInstance(in))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
@@ -0,0 +1,332 @@
+// Package workflows runs document approvals. Admins define templates made
+// of ordered steps; uploading a file with a template starts an instance
+// that asks each step's approvers, in turn, to approve or reject it.
+//
+// Steps are grouped into stages: a sequential step starts a new stage and a
+// parallel step joins the stage of the step before it. Every step of a
+// stage must be approved before the next stage starts, and any rejection
+// ends the instance.
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxNameLen        = 120
+	MaxDescriptionLen = 1000
+	MaxSteps          = 20
+	MaxCommentLen     = 2000
+	// MaxSLAHours caps a step's SLA at 90 days.
+	MaxSLAHours = 90 * 24
+)
+
+// Step modes.
+const (
+	ModeSequential = "sequential"
+	ModeParallel   = "parallel"
+)
+
+// Approver roles: any active member of the step's department, or only its
+// heads.
+const (
+	RoleMember = "member"
+	RoleHead   = "head"
+)
+
+// Instance statuses.
+const (
+	StatusInProgress = "in_progress"
+	StatusApproved   = "approved"
+	StatusRejected   = "rejected"
+)
+
+// Step statuses. Waiting steps belong to a later stage; pending steps await
+// a decision; skipped steps were never reached because the instance was
+// rejected.
+const (
+	StepWaiting  = "waiting"
+	StepPending  = "pending"
+	StepApproved = "approved"
+	StepRejected = "rejected"
+	StepSkipped  = "skipped"
+)
+
+// Event kinds, sent to Service.Notify.
+const (
+	EventStepPending  = "step_pending" // to the step's approvers
+	EventStepApproved = "step_approved"
+	EventApproved     = "approved"
+	EventRejected     = "rejected"
+	EventOverdue      = "overdue" // to the step's approvers and the initiator
+)
+
+var (
+	ErrNotFound         = errors.New("workflow not found")
+	ErrTemplateNotFound = errors.New("workflow template not found")
+	ErrForbidden        = errors.New("forbidden")
+	ErrNotPending       = errors.New("nothing is waiting for your decision")
+	ErrClosed           = errors.New("workflow is already closed")
+	// ErrConflict means the instance changed while it was being updated;
+	// the caller may retry.
+	ErrConflict = errors.New("workflow was updated concurrently")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// StepTemplate describes one approval. An empty DUUID means the uploader's
+// department. SLAHours of 0 means no deadline.
+type StepTemplate struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	DUUID    string `json:"d_uuid,omitempty"`
+	SLAHours int    `json:"sla_hours"`
+	Mode     string `json:"mode"`
+}
+
+type Template struct {
+	ID          string         `json:"template_id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Active      bool           `json:"active"`
+	Steps       []StepTemplate `json:"steps"`
+	CreatedBy   string         `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Step is a template step as it runs in an instance, with its department
+// resolved.
+type Step struct {
+	Position    int        `json:"position"`
+	Stage       int        `json:"stage"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	DUUID       string     `json:"d_uuid"`
+	Mode        string     `json:"mode"`
+	SLAHours    int        `json:"sla_hours"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	ActedBy     string     `json:"acted_by,omitempty"`
+	ActedByName string     `json:"acted_by_name,omitempty"`
+	ActedAt     *time.Time `json:"acted_at,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+	// OverdueNotified is set once the overdue notice has gone out.
+	OverdueNotified bool `json:"-"`
+}
+
+type Instance struct {
+	ID           string     `json:"workflow_id"`
+	TemplateID   string     `json:"template_id,omitempty"`
+	TemplateName string     `json:"template_name"`
+	FUUID        string     `json:"f_uuid"`
+	FileName     string     `json:"f_name,omitempty"`
+	StartedBy    string     `json:"started_by"`
+	Status       string     `json:"status"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	Steps        []Step     `json:"steps"`
+	// Version guards concurrent decisions; see Store.SaveInstance.
+	Version int `json:"-"`
+}
+
+// Member is an active user who may approve steps.
+type Member struct {
+	UUID     string
+	Name     string
+	DUUID    string
+	Position string
+}
+
+// Event tells Recipients about a transition of Instance. Step is the step
+// concerned, nil for instance-wide events. Sensitivity is the file's level.
+type Event struct {
+	Kind        string
+	Instance    Instance
+	Step        *Step
+	Actor       string
+	Comment     string
+	Sensitivity string
+	Recipients  []string
+}
+
+// Transition records one change made by a decision or the SLA check, for
+// the audit log.
+type Transition struct {
+	WorkflowID string `json:"workflow_id"`
+	Step       int    `json:"step"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Status     string `json:"status"`
+}
+
+// NormalizeTemplate trims t's fields and checks them. It does not check
+// that the departments exist.
+func NormalizeTemplate(t *Template) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Description = strings.TrimSpace(t.Description)
+	if t.Name == "" {
+		return invalid("name is required")
+	}
+	if utf8.RuneCountInString(t.Name) > MaxNameLen {
+		return invalid("name must be at most %d characters", MaxNameLen)
+	}
+	if utf8.RuneCountInString(t.Description) > MaxDescriptionLen {
+		return invalid("description must be at most %d characters", MaxDescriptionLen)
+	}
+	if len(t.Steps) == 0 {
+		return invalid("at least one step is required")
+	}
+	if len(t.Steps) > MaxSteps {
+		return invalid("a template may have at most %d steps", MaxSteps)
+	}
+	for i := range t.Steps {
+		s := &t.Steps[i]
+		s.Name = strings.TrimSpace(s.Name)
+		s.Role = strings.ToLower(strings.TrimSpace(s.Role))
+		s.Mode = strings.ToLower(strings.TrimSpace(s.Mode))
+		s.DUUID = strings.ToLower(strings.TrimSpace(s.DUUID))
+		if s.Name == "" {
+			return invalid("steps[%d].name is required", i)
+		}
+		if utf8.RuneCountInString(s.Name) > MaxNameLen {
+			return invalid("steps[%d].name must be at most %d characters", i, MaxNameLen)
+		}
+		if s.Role == "" {
+			s.Role = RoleHead
+		}
+		if s.Role != RoleHead && s.Role != RoleMember {
+			return invalid("steps[%d].role must be %q or %q", i, RoleHead, RoleMember)
+		}
+		if s.Mode == "" {
+			s.Mode = ModeSequential
+		}
+		if s.Mode != ModeSequential && s.Mode != ModeParallel {
+			return invalid("steps[%d].mode must be %q or %q", i, ModeSequential, ModeParallel)
+		}
+		if i == 0 && s.Mode == ModeParallel {
+			return invalid("the first step must be sequential")
+		}
+		if s.DUUID != "" && !uuidPattern.MatchString(s.DUUID) {
+			return invalid("steps[%d].d_uuid must be a department UUID", i)
+		}
+		if s.SLAHours < 0 || s.SLAHours > MaxSLAHours {
+			return invalid("steps[%d].sla_hours must be between 0 and %d", i, MaxSLAHours)
+		}
+	}
+	return nil
+}
+
+// checkComment trims a decision comment; rejections must explain
+// themselves.
+func checkComment(comment string, required bool) (string, error) {
+	comment = strings.TrimSpace(comment)
+	if required && comment == "" {
+		return "", invalid("comment is required when rejecting")
+	}
+	if utf8.RuneCountInString(comment) > MaxCommentLen {
+		return "", invalid("comment must be at most %d characters", MaxCommentLen)
+	}
+	return comment, nil
+}
+
+// newInstance lays out t's steps for a file uploaded by someone in
+// department uploaderDept.
+func newInstance(t Template, fuuid, fileName, startedBy, uploaderDept string) Instance {
+	in := Instance{
+		TemplateID: t.ID, TemplateName: t.Name, FUUID: fuuid, FileName: fileName, StartedBy: startedBy,
+		Status: StatusInProgress, Steps: make([]Step, len(t.Steps)),
+	}
+	stage := 0
+	for i, st := range t.Steps {
+		if i > 0 && st.Mode != ModeParallel {
+			stage++
+		}
+		dept := st.DUUID
+		if dept == "" {
+			dept = uploaderDept
+		}
+		in.Steps[i] = Step{
+			Position: i, Stage: stage, Name: st.Name, Role: st.Role, DUUID: dept, Mode: st.Mode,
+			SLAHours: st.SLAHours, Status: StepWaiting,
+		}
+	}
+	return in
+}
+
+// startStage marks the steps of stage pending and returns their positions.
+func (in *Instance) startStage(stage int, now time.Time) []int {
+	var started []int
+	for i := range in.Steps {
+		s := &in.Steps[i]
+		if s.Stage != stage || s.Status != StepWaiting {
+			continue
+		}
+		started = append(started, i)
+		at := now
+		s.Status, s.StartedAt = StepPending, &at
+		if s.SLAHours > 0 {
+			due := now.Add(time.Duration(s.SLAHours) * time.Hour)
+			s.DueAt = &due
+		}
+	}
+	return started
+}
+
+// stageDone reports whether every step of stage is approved.
+func (in *Instance) stageDone(stage int) bool {
+	for _, s := range in.Steps {
+		if s.Stage == stage && s.Status != StepApproved {
+			return false
+		}
+	}
+	return true
+}
+
+// lastStage is the stage of the final step.
+func (in *Instance) lastStage() int {
+	if len(in.Steps) == 0 {
+		return 0
+	}
+	return in.Steps[len(in.Steps)-1].Stage
+}
+
+// close ends the instance, skipping every step that was not decided.
+func (in *Instance) close(status string, now time.Time) {
+	in.Status = status
+	at := now
+	in.CompletedAt = &at
+	for i := range in.Steps {
+		if s := &in.Steps[i]; s.Status == StepWaiting || s.Status == StepPending {
+			s.Status = StepSkipped
+		}
+	}
+}
+
+// CanAct reports whether m may decide step s.
+func CanAct(m Member, s Step) bool {
+	if s.Status != StepPending || !strings.EqualFold(m.DUUID, s.DUUID) {
+		return false
+	}
+	return s.Role == RoleMember || m.Position == RoleHead
+}
+
+// Overdue reports whether s is pending past its deadline.
+func (s Step) Overdue(now time.Time) bool {
+	return s.Status == StepPending && s.DueAt != nil && now.After(*s.DueAt)
+}
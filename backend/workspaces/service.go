@@ -0,0 +1,583 @@
+package workspaces
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/access"
+)
+
+// Store persists workspaces. Implementations: NewPostgresStore and
+// NewMemoryStore.
+type Store interface {
+	// User returns an active user, or nil when missing or deactivated.
+	User(uuid string) (*Member, error)
+	// DepartmentName returns "" when the department does not exist.
+	DepartmentName(dUUID string) (string, error)
+	// Members lists the active users of the given departments.
+	Members(dUUIDs []string) ([]Member, error)
+	// File returns a file with its own sharing, or nil.
+	File(fuuid string) (*LinkedFile, error)
+
+	// Workspaces lists the workspaces dUUID has joined or been invited to.
+	Workspaces(dUUID string) ([]Workspace, error)
+	// Workspace returns nil when there is no such workspace.
+	Workspace(id string) (*Workspace, error)
+	// CreateWorkspace also adds the owner department as joined.
+	CreateWorkspace(w Workspace) (Workspace, error)
+	UpdateWorkspace(w Workspace) (Workspace, error)
+	DeleteWorkspace(id string) error
+	// SetDepartment adds a department or updates its status.
+	SetDepartment(id string, d Department) error
+	RemoveDepartment(id, dUUID string) (bool, error)
+
+	// Files lists the linked files, newest first.
+	Files(id string) ([]LinkedFile, error)
+	// LinkFile is a no-op when the file is already linked.
+	LinkFile(id, fuuid, linkedBy string) error
+	UnlinkFile(id, fuuid string) (bool, error)
+
+	// Comments lists a workspace's comments, oldest first.
+	Comments(id string) ([]Comment, error)
+	Comment(id, commentID string) (*Comment, error)
+	AddComment(c Comment) (Comment, error)
+	DeleteComment(id, commentID string) (bool, error)
+}
+
+type Service struct {
+	store Store
+	// Notify receives workspace events; nil drops them.
+	Notify func(Event)
+	Now    func() time.Time
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store, Now: time.Now}
+}
+
+func (s *Service) notify(e Event) {
+	if s.Notify != nil && len(e.Recipients) > 0 {
+		s.Notify(e)
+	}
+}
+
+func (s *Service) user(userID string) (Member, error) {
+	u, err := s.store.User(userID)
+	if err != nil {
+		return Member{}, err
+	}
+	if u == nil {
+		return Member{}, ErrNotFound
+	}
+	return *u, nil
+}
+
+func viewer(u Member) access.Viewer {
+	return access.Viewer{UUID: u.UUID, DUUID: u.DUUID, Position: u.Position, Active: true}
+}
+
+// canManage: the creator and the heads of the owner department.
+func canManage(u Member, w Workspace) bool {
+	return u.UUID == w.CreatedBy || (u.IsHead() && u.DUUID != "" && strings.EqualFold(u.DUUID, w.OwnerDUUID))
+}
+
+// role is the caller's relation to w, or "" when they have none.
+func role(u Member, w Workspace) string {
+	if canManage(u, w) {
+		return RoleOwner
+	}
+	d := w.department(u.DUUID)
+	switch {
+	case d == nil || u.DUUID == "":
+		return ""
+	case d.Status == StatusJoined:
+		return RoleMember
+	case u.IsHead():
+		return RoleInvited
+	}
+	return ""
+}
+
+// load returns the workspace if the caller has a role in it; others get
+// ErrNotFound so workspaces stay invisible to outsiders.
+func (s *Service) load(u Member, id string) (Workspace, error) {
+	w, err := s.store.Workspace(id)
+	if err != nil {
+		return Workspace{}, err
+	}
+	if w == nil {
+		return Workspace{}, ErrNotFound
+	}
+	w.Role = role(u, *w)
+	if w.Role == "" {
+		return Workspace{}, ErrNotFound
+	}
+	return *w, nil
+}
+
+// member is load for reading files and comments, which invited heads may
+// not do until they join.
+func (s *Service) member(userID, id string) (Member, Workspace, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Member{}, Workspace{}, err
+	}
+	w, err := s.load(u, id)
+	if err != nil {
+		return Member{}, Workspace{}, err
+	}
+	if w.Role == RoleInvited {
+		return Member{}, Workspace{}, ErrForbidden
+	}
+	return u, w, nil
+}
+
+func (s *Service) manager(userID, id string) (Member, Workspace, error) {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return Member{}, Workspace{}, err
+	}
+	if w.Role != RoleOwner {
+		return Member{}, Workspace{}, ErrForbidden
+	}
+	return u, w, nil
+}
+
+// view is f as seen through w: its own sharing plus w's departments.
+func view(f LinkedFile, w Workspace) access.File {
+	a := f.Access
+	a.WorkspaceDepartments = append(append([]string{}, a.WorkspaceDepartments...), w.joined()...)
+	return a
+}
+
+// recipients lists the members of w's departments who may see f (any
+// member when f is nil), without the actor.
+func (s *Service) recipients(w Workspace, actor Member, f *LinkedFile) ([]string, error) {
+	members, err := s.store.Members(w.joined())
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, m := range members {
+		if m.UUID == actor.UUID {
+			continue
+		}
+		if f != nil && !access.Check(viewer(m), view(*f, w)).Allowed {
+			continue
+		}
+		out = append(out, m.UUID)
+	}
+	return out, nil
+}
+
+// heads lists the heads of dUUIDs, or all their members when a
+// department has no head, without the actor.
+func (s *Service) heads(dUUIDs []string, actor Member) ([]string, error) {
+	members, err := s.store.Members(dUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	byDept := map[string][]Member{}
+	for _, m := range members {
+		byDept[m.DUUID] = append(byDept[m.DUUID], m)
+	}
+	var out []string
+	for _, list := range byDept {
+		var chosen []Member
+		for _, m := range list {
+			if m.IsHead() {
+				chosen = append(chosen, m)
+			}
+		}
+		if len(chosen) == 0 {
+			chosen = list
+		}
+		for _, m := range chosen {
+			if m.UUID != actor.UUID {
+				out = append(out, m.UUID)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Workspaces
+// ---------------------------------------------------------------------------
+
+// List returns the workspaces of the caller's department, and for heads the
+// pending invitations too, by name.
+func (s *Service) List(userID string) ([]Workspace, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []Workspace{}
+	if u.DUUID == "" {
+		return out, nil
+	}
+	all, err := s.store.Workspaces(u.DUUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range all {
+		if w.Role = role(u, w); w.Role != "" {
+			out = append(out, w)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
+	return out, nil
+}
+
+// Create starts a workspace owned by the caller's department. Only heads
+// may create one.
+func (s *Service) Create(userID string, d Draft) (Workspace, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Workspace{}, err
+	}
+	if !u.IsHead() || u.DUUID == "" {
+		return Workspace{}, ErrForbidden
+	}
+	name, err := checkText("name", d.Name, true, MaxNameLen)
+	if err != nil {
+		return Workspace{}, err
+	}
+	desc, err := checkText("description", d.Description, false, MaxDescriptionLen)
+	if err != nil {
+		return Workspace{}, err
+	}
+	w, err := s.store.CreateWorkspace(Workspace{
+		Name: name, Description: desc, CreatedBy: u.UUID, CreatorName: u.Name, OwnerDUUID: u.DUUID,
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+	w.Role = RoleOwner
+	return w, nil
+}
+
+func (s *Service) Get(userID, id string) (Workspace, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Workspace{}, err
+	}
+	return s.load(u, id)
+}
+
+func (s *Service) Update(userID, id string, p Patch) (Workspace, error) {
+	_, w, err := s.manager(userID, id)
+	if err != nil {
+		return Workspace{}, err
+	}
+	if p.Name != nil {
+		if w.Name, err = checkText("name", *p.Name, true, MaxNameLen); err != nil {
+			return Workspace{}, err
+		}
+	}
+	if p.Description != nil {
+		if w.Description, err = checkText("description", *p.Description, false, MaxDescriptionLen); err != nil {
+			return Workspace{}, err
+		}
+	}
+	updated, err := s.store.UpdateWorkspace(w)
+	if err != nil {
+		return Workspace{}, err
+	}
+	updated.Role = w.Role
+	return updated, nil
+}
+
+// Delete removes the workspace, its links and comments. The files stay.
+func (s *Service) Delete(userID, id string) error {
+	_, w, err := s.manager(userID, id)
+	if err != nil {
+		return err
+	}
+	return s.store.DeleteWorkspace(w.ID)
+}
+
+// ---------------------------------------------------------------------------
+// Departments
+// ---------------------------------------------------------------------------
+
+// Invite asks another department to join. Its heads are notified and one
+// of them accepts with Join.
+func (s *Service) Invite(userID, id, dUUID string) (Workspace, error) {
+	u, w, err := s.manager(userID, id)
+	if err != nil {
+		return Workspace{}, err
+	}
+	dUUID = strings.ToLower(strings.TrimSpace(dUUID))
+	if dUUID == "" {
+		return Workspace{}, invalid("d_uuid is required")
+	}
+	if w.department(dUUID) != nil {
+		return Workspace{}, invalid("that department is already in the workspace")
+	}
+	if len(w.Departments) >= MaxDepartments {
+		return Workspace{}, invalid("a workspace holds at most %d departments", MaxDepartments)
+	}
+	name, err := s.store.DepartmentName(dUUID)
+	if err != nil {
+		return Workspace{}, err
+	}
+	if name == "" {
+		return Workspace{}, invalid("department %s does not exist", dUUID)
+	}
+	d := Department{DUUID: dUUID, Name: name, Status: StatusInvited, InvitedBy: u.UUID, InvitedAt: s.Now()}
+	if err := s.store.SetDepartment(w.ID, d); err != nil {
+		return Workspace{}, err
+	}
+	if to, err := s.heads([]string{dUUID}, u); err == nil {
+		s.notify(Event{Kind: EventInvited, Workspace: w, Actor: u, Department: &d, Recipients: to})
+	}
+	return s.load(u, w.ID)
+}
+
+// Join accepts an invitation on behalf of the caller's department. Only
+// its heads may accept.
+func (s *Service) Join(userID, id string) (Workspace, error) {
+	u, err := s.user(userID)
+	if err != nil {
+		return Workspace{}, err
+	}
+	w, err := s.load(u, id)
+	if err != nil {
+		return Workspace{}, err
+	}
+	d := w.department(u.DUUID)
+	if d == nil || !u.IsHead() {
+		return Workspace{}, ErrForbidden
+	}
+	if d.Status == StatusJoined {
+		return w, nil
+	}
+	others := w.joined()
+	now := s.Now()
+	d.Status, d.JoinedAt = StatusJoined, &now
+	if err := s.store.SetDepartment(w.ID, *d); err != nil {
+		return Workspace{}, err
+	}
+	if to, err := s.heads(others, u); err == nil {
+		s.notify(Event{Kind: EventJoined, Workspace: w, Actor: u, Department: d, Recipients: to})
+	}
+	return s.load(u, w.ID)
+}
+
+// RemoveDepartment drops a department from the workspace: managers may
+// remove any but the owner department, and a department's heads may leave
+// or decline an invitation.
+func (s *Service) RemoveDepartment(userID, id, dUUID string) error {
+	u, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	w, err := s.load(u, id)
+	if err != nil {
+		return err
+	}
+	dUUID = strings.ToLower(strings.TrimSpace(dUUID))
+	if strings.EqualFold(dUUID, w.OwnerDUUID) {
+		return invalid("the owner department cannot leave; delete the workspace instead")
+	}
+	ownDept := u.IsHead() && strings.EqualFold(dUUID, u.DUUID)
+	if w.Role != RoleOwner && !ownDept {
+		return ErrForbidden
+	}
+	removed, err := s.store.RemoveDepartment(w.ID, dUUID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return invalid("that department is not in the workspace")
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Files
+// ---------------------------------------------------------------------------
+
+// Files lists the linked files the caller may see.
+func (s *Service) Files(userID, id string) ([]LinkedFile, error) {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.store.Files(w.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := []LinkedFile{}
+	for _, f := range all {
+		if access.Check(viewer(u), view(f, w)).Allowed {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// LinkFile links a file into the workspace, which shares it with every
+// joined department. Members may only link files they could download
+// without the workspace, so access never chains from one workspace to the
+// next. Linking a confidential or restricted file widens who may read it,
+// so only its uploader or a head of one of its departments may do that.
+func (s *Service) LinkFile(userID, id, fuuid string) (LinkedFile, error) {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return LinkedFile{}, err
+	}
+	fuuid = strings.ToLower(strings.TrimSpace(fuuid))
+	if fuuid == "" {
+		return LinkedFile{}, invalid("f_uuid is required")
+	}
+	f, err := s.store.File(fuuid)
+	if err != nil {
+		return LinkedFile{}, err
+	}
+	if f == nil {
+		return LinkedFile{}, ErrFileNotFound
+	}
+	own := f.Access
+	own.WorkspaceDepartments, own.WorkflowDepartments = nil, nil
+	if !access.CheckDownload(viewer(u), own).Allowed {
+		return LinkedFile{}, ErrNoAccess
+	}
+	if access.Sensitive(own.Sensitivity) && !strings.EqualFold(own.OwnerUUID, u.UUID) &&
+		!(u.IsHead() && slices.ContainsFunc(own.Departments, func(d string) bool { return strings.EqualFold(d, u.DUUID) })) {
+		return LinkedFile{}, ErrLinkNotAllowed
+	}
+	if err := s.store.LinkFile(w.ID, f.FUUID, u.UUID); err != nil {
+		return LinkedFile{}, err
+	}
+	f.LinkedBy, f.LinkedByName, f.LinkedAt = u.UUID, u.Name, s.Now()
+	if to, err := s.recipients(w, u, f); err == nil {
+		s.notify(Event{Kind: EventFileLinked, Workspace: w, Actor: u, File: f, Recipients: to})
+	}
+	return *f, nil
+}
+
+// UnlinkFile removes a link; the person who linked the file and the
+// managers may do so.
+func (s *Service) UnlinkFile(userID, id, fuuid string) error {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return err
+	}
+	fuuid = strings.ToLower(strings.TrimSpace(fuuid))
+	files, err := s.store.Files(w.ID)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.FUUID != fuuid {
+			continue
+		}
+		if f.LinkedBy != u.UUID && w.Role != RoleOwner {
+			return ErrForbidden
+		}
+		_, err := s.store.UnlinkFile(w.ID, fuuid)
+		return err
+	}
+	return ErrFileNotFound
+}
+
+// ---------------------------------------------------------------------------
+// Comments
+// ---------------------------------------------------------------------------
+
+// visibleFiles maps the workspace's files the caller may see.
+func (s *Service) visibleFiles(u Member, w Workspace) (map[string]LinkedFile, error) {
+	files, err := s.store.Files(w.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]LinkedFile{}
+	for _, f := range files {
+		if access.Check(viewer(u), view(f, w)).Allowed {
+			out[f.FUUID] = f
+		}
+	}
+	return out, nil
+}
+
+// Comments lists the workspace's comments, leaving out those about files
+// the caller may not see.
+func (s *Service) Comments(userID, id string) ([]Comment, error) {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.store.Comments(w.ID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := s.visibleFiles(u, w)
+	if err != nil {
+		return nil, err
+	}
+	out := []Comment{}
+	for _, c := range all {
+		if _, ok := visible[c.FUUID]; c.FUUID == "" || ok {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// AddComment posts a comment, about one of the workspace's files when
+// fuuid is set. Members who can see it are notified.
+func (s *Service) AddComment(userID, id, body, fuuid string) (Comment, error) {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return Comment{}, err
+	}
+	body, err = checkText("body", body, true, MaxCommentLen)
+	if err != nil {
+		return Comment{}, err
+	}
+	var file *LinkedFile
+	if fuuid = strings.ToLower(strings.TrimSpace(fuuid)); fuuid != "" {
+		visible, err := s.visibleFiles(u, w)
+		if err != nil {
+			return Comment{}, err
+		}
+		f, ok := visible[fuuid]
+		if !ok {
+			return Comment{}, ErrFileNotFound
+		}
+		file = &f
+	}
+	c, err := s.store.AddComment(Comment{WorkspaceID: w.ID, FUUID: fuuid, AuthorUUID: u.UUID, AuthorName: u.Name, Body: body})
+	if err != nil {
+		return Comment{}, err
+	}
+	if to, err := s.recipients(w, u, file); err == nil {
+		s.notify(Event{Kind: EventComment, Workspace: w, Actor: u, File: file, Comment: &c, Recipients: to})
+	}
+	return c, nil
+}
+
+// DeleteComment removes a comment; its author and the managers may do so.
+func (s *Service) DeleteComment(userID, id, commentID string) error {
+	u, w, err := s.member(userID, id)
+	if err != nil {
+		return err
+	}
+	c, err := s.store.Comment(w.ID, commentID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrCommentNotFound
+	}
+	if c.AuthorUUID != u.UUID && w.Role != RoleOwner {
+		return ErrForbidden
+	}
+	_, err = s.store.DeleteComment(w.ID, c.ID)
+	return err
+}
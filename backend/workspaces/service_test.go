@@ -0,0 +1,243 @@
+package workspaces
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/access"
+)
+
+const (
+	deptOps     = "11111111-1111-1111-1111-111111111111"
+	deptFinance = "22222222-2222-2222-2222-222222222222"
+	deptLegal   = "33333333-3333-3333-3333-333333333333"
+	fileOps     = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	fileSecret  = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+	fileLegal   = "cccccccc-cccc-cccc-cccc-cccccccccccc"
+	fileMinutes = "dddddddd-dddd-dddd-dddd-dddddddddddd"
+)
+
+type recorder struct{ events []Event }
+
+func (r *recorder) notify(e Event) { r.events = append(r.events, e) }
+
+func (r *recorder) last(t *testing.T, kind string) Event {
+	t.Helper()
+	if len(r.events) == 0 || r.events[len(r.events)-1].Kind != kind {
+		t.Fatalf("expected a %s event, got %+v", kind, r.events)
+	}
+	return r.events[len(r.events)-1]
+}
+
+func newTestService() (*Service, *recorder) {
+	store := NewMemoryStore()
+	store.AddDepartment(deptOps, "Operations")
+	store.AddDepartment(deptFinance, "Finance")
+	store.AddDepartment(deptLegal, "Legal")
+	store.AddUser(Member{UUID: "ops-head", Name: "Ravi", DUUID: deptOps, Position: "head"})
+	store.AddUser(Member{UUID: "ops-clerk", Name: "Asha", DUUID: deptOps, Position: "regular"})
+	store.AddUser(Member{UUID: "fin-head", Name: "Meera", DUUID: deptFinance, Position: "head"})
+	store.AddUser(Member{UUID: "fin-clerk", Name: "Joseph", DUUID: deptFinance, Position: "regular"})
+	store.AddUser(Member{UUID: "legal-1", Name: "Anu", DUUID: deptLegal, Position: "regular"})
+	store.AddFile(access.File{FUUID: fileOps, OwnerUUID: "ops-clerk", Sensitivity: access.Confidential, Departments: []string{deptOps}}, "ops.pdf")
+	store.AddFile(access.File{FUUID: fileSecret, OwnerUUID: "ops-head", Sensitivity: access.Restricted, Departments: []string{deptOps}}, "secret.pdf")
+	store.AddFile(access.File{FUUID: fileLegal, OwnerUUID: "legal-1", Sensitivity: access.Confidential, Departments: []string{deptLegal}}, "legal.pdf")
+	store.AddFile(access.File{FUUID: fileMinutes, OwnerUUID: "ops-head", Sensitivity: access.Confidential, Departments: []string{deptOps}}, "minutes.pdf")
+
+	svc := NewService(store)
+	rec := &recorder{}
+	svc.Notify = rec.notify
+	t0 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.Now = func() time.Time { t0 = t0.Add(time.Minute); return t0 }
+	return svc, rec
+}
+
+func fileIDs(files []LinkedFile) []string {
+	out := []string{}
+	for _, f := range files {
+		out = append(out, f.FUUID)
+	}
+	return out
+}
+
+func TestOnlyHeadsCreateWorkspaces(t *testing.T) {
+	svc, _ := newTestService()
+	if _, err := svc.Create("ops-clerk", Draft{Name: "Audit"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for a regular user, got %v", err)
+	}
+	if _, err := svc.Create("ops-head", Draft{Name: " "}); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	w, err := svc.Create("ops-head", Draft{Name: " Metro expansion "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Name != "Metro expansion" || w.Role != RoleOwner || len(w.Departments) != 1 || w.Departments[0].Status != StatusJoined {
+		t.Fatalf("unexpected workspace %+v", w)
+	}
+	got, err := svc.Get("ops-clerk", w.ID)
+	if err != nil || got.Role != RoleMember {
+		t.Fatalf("owner department members should see it: %+v, %v", got, err)
+	}
+	if _, err := svc.Get("fin-head", w.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("outsiders should not see it, got %v", err)
+	}
+}
+
+func TestInviteAndJoin(t *testing.T) {
+	svc, rec := newTestService()
+	w, _ := svc.Create("ops-head", Draft{Name: "Metro expansion"})
+
+	if _, err := svc.Invite("ops-clerk", w.ID, deptFinance); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("only managers may invite, got %v", err)
+	}
+	if _, err := svc.Invite("ops-head", w.ID, "99999999-9999-9999-9999-999999999999"); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected unknown department to be rejected, got %v", err)
+	}
+	if _, err := svc.Invite("ops-head", w.ID, deptFinance); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(t, EventInvited); !reflect.DeepEqual(e.Recipients, []string{"fin-head"}) {
+		t.Fatalf("invitation should go to the finance head, got %v", e.Recipients)
+	}
+	if _, err := svc.Invite("ops-head", w.ID, deptFinance); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected a duplicate invite to be rejected, got %v", err)
+	}
+
+	// Invited heads see the workspace but not its files until they join;
+	// the rest of the department does not see it at all.
+	list, _ := svc.List("fin-head")
+	if len(list) != 1 || list[0].Role != RoleInvited {
+		t.Fatalf("expected a pending invitation, got %+v", list)
+	}
+	if list, _ := svc.List("fin-clerk"); len(list) != 0 {
+		t.Fatalf("invitations are for heads only, got %+v", list)
+	}
+	if _, err := svc.Files("fin-head", w.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden before joining, got %v", err)
+	}
+	if _, err := svc.Join("fin-clerk", w.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a regular member to be refused, got %v", err)
+	}
+
+	joined, err := svc.Join("fin-head", w.ID)
+	if err != nil || joined.Role != RoleMember {
+		t.Fatalf("unexpected join result %+v, %v", joined, err)
+	}
+	if e := rec.last(t, EventJoined); !reflect.DeepEqual(e.Recipients, []string{"ops-head"}) {
+		t.Fatalf("join should notify the other heads, got %v", e.Recipients)
+	}
+	if got, err := svc.Get("fin-clerk", w.ID); err != nil || got.Role != RoleMember {
+		t.Fatalf("finance members should now see it: %+v, %v", got, err)
+	}
+
+	if err := svc.RemoveDepartment("fin-head", w.ID, deptOps); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("the owner department cannot be removed, got %v", err)
+	}
+	if err := svc.RemoveDepartment("fin-clerk", w.ID, deptFinance); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("only heads may leave, got %v", err)
+	}
+	if err := svc.RemoveDepartment("fin-head", w.ID, deptFinance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get("fin-clerk", w.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the workspace to be gone for finance, got %v", err)
+	}
+}
+
+func TestLinkedFilesFollowAccessRules(t *testing.T) {
+	svc, rec := newTestService()
+	w, _ := svc.Create("ops-head", Draft{Name: "Metro expansion"})
+	svc.Invite("ops-head", w.ID, deptFinance)
+	svc.Join("fin-head", w.ID)
+
+	if _, err := svc.LinkFile("fin-clerk", w.ID, fileOps); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("finance cannot link a file it does not own, got %v", err)
+	}
+	if _, err := svc.LinkFile("ops-head", w.ID, fileLegal); !errors.Is(err, ErrNoAccess) {
+		t.Fatalf("expected ErrNoAccess for another department's file, got %v", err)
+	}
+	if _, err := svc.LinkFile("ops-clerk", w.ID, fileMinutes); !errors.Is(err, ErrLinkNotAllowed) {
+		t.Fatalf("a member who only reads a confidential file cannot link it, got %v", err)
+	}
+	if _, err := svc.LinkFile("ops-clerk", w.ID, fileOps); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(t, EventFileLinked); !reflect.DeepEqual(e.Recipients, []string{"fin-clerk", "fin-head", "ops-head"}) {
+		t.Fatalf("unexpected recipients %v", e.Recipients)
+	}
+	if _, err := svc.LinkFile("ops-head", w.ID, fileSecret); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(t, EventFileLinked); !reflect.DeepEqual(e.Recipients, []string{"fin-head"}) {
+		t.Fatalf("restricted files should only reach heads, got %v", e.Recipients)
+	}
+
+	files, _ := svc.Files("fin-clerk", w.ID)
+	if !reflect.DeepEqual(fileIDs(files), []string{fileOps}) {
+		t.Fatalf("finance clerk should see only the confidential file, got %v", fileIDs(files))
+	}
+	files, _ = svc.Files("fin-head", w.ID)
+	if !reflect.DeepEqual(fileIDs(files), []string{fileSecret, fileOps}) {
+		t.Fatalf("finance head should see both files, got %v", fileIDs(files))
+	}
+
+	if err := svc.UnlinkFile("fin-head", w.ID, fileOps); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("only the linker or a manager may unlink, got %v", err)
+	}
+	if err := svc.UnlinkFile("ops-clerk", w.ID, fileOps); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UnlinkFile("ops-clerk", w.ID, fileOps); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	svc, rec := newTestService()
+	w, _ := svc.Create("ops-head", Draft{Name: "Metro expansion"})
+	svc.Invite("ops-head", w.ID, deptFinance)
+	svc.Join("fin-head", w.ID)
+	svc.LinkFile("ops-head", w.ID, fileSecret)
+
+	if _, err := svc.AddComment("fin-clerk", w.ID, "  ", ""); !errors.As(err, &ValidationError{}) {
+		t.Fatalf("expected an empty comment to be rejected, got %v", err)
+	}
+	if _, err := svc.AddComment("fin-clerk", w.ID, "About the secret", fileSecret); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("members cannot comment on files they cannot see, got %v", err)
+	}
+	general, err := svc.AddComment("fin-clerk", w.ID, "Hello all", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(t, EventComment); !reflect.DeepEqual(e.Recipients, []string{"fin-head", "ops-clerk", "ops-head"}) {
+		t.Fatalf("unexpected recipients %v", e.Recipients)
+	}
+	if _, err := svc.AddComment("fin-head", w.ID, "Budget looks fine", fileSecret); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(t, EventComment); !reflect.DeepEqual(e.Recipients, []string{"ops-head"}) {
+		t.Fatalf("comments on restricted files only reach those who can see them, got %v", e.Recipients)
+	}
+
+	list, _ := svc.Comments("fin-clerk", w.ID)
+	if len(list) != 1 || list[0].ID != general.ID {
+		t.Fatalf("clerk should only see the general comment, got %+v", list)
+	}
+	list, _ = svc.Comments("ops-head", w.ID)
+	if len(list) != 2 {
+		t.Fatalf("head should see both comments, got %+v", list)
+	}
+
+	if err := svc.DeleteComment("fin-head", w.ID, general.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("only the author or a manager may delete, got %v", err)
+	}
+	if err := svc.DeleteComment("ops-head", w.ID, general.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteComment("ops-head", w.ID, general.ID); !errors.Is(err, ErrCommentNotFound) {
+		t.Fatalf("expected ErrCommentNotFound, got %v", err)
+	}
+}
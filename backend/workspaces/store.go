@@ -0,0 +1,498 @@
+package workspaces
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/access"
+	"backend/models"
+)
+
+// ---------------------------------------------------------------------------
+// Postgres-backed store (workspaces, workspace_departments, workspace_files,
+// workspace_comments)
+// ---------------------------------------------------------------------------
+
+type pgStore struct{ db *sql.DB }
+
+func NewPostgresStore(db *sql.DB) Store { return pgStore{db: db} }
+
+func fromRow(r models.WorkspaceRow) Workspace {
+	w := Workspace{
+		ID: r.WorkspaceID, Name: r.Name, Description: r.Description, CreatedBy: r.CreatedBy, CreatorName: r.CreatorName,
+		OwnerDUUID: r.OwnerDUUID, FileCount: r.FileCount, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+		Departments: []Department{},
+	}
+	for _, d := range r.Departments {
+		w.Departments = append(w.Departments, Department{
+			DUUID: d.DUUID, Name: d.DName, Status: d.Status, InvitedBy: d.InvitedBy, InvitedAt: d.InvitedAt, JoinedAt: d.JoinedAt,
+		})
+	}
+	return w
+}
+
+func commentFromRow(r models.WorkspaceCommentRow) Comment {
+	return Comment{
+		ID: r.CommentID, WorkspaceID: r.WorkspaceID, FUUID: r.FUUID, AuthorUUID: r.AuthorUUID, AuthorName: r.AuthorName,
+		Body: r.Body, CreatedAt: r.CreatedAt,
+	}
+}
+
+func (s pgStore) User(uuid string) (*Member, error) {
+	p, err := models.GetUserProfile(s.db, uuid)
+	if err != nil || p == nil || !p.IsActive {
+		return nil, err
+	}
+	return &Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position}, nil
+}
+
+func (s pgStore) DepartmentName(dUUID string) (string, error) {
+	d, err := models.GetDepartmentByUUID(s.db, dUUID)
+	if err != nil || d == nil {
+		return "", err
+	}
+	return d.DName, nil
+}
+
+func (s pgStore) Members(dUUIDs []string) ([]Member, error) {
+	rows, err := models.ListActiveDepartmentMembers(s.db, dUUIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, Member{UUID: p.UUID, Name: p.Name, DUUID: p.DUUID, Position: p.Position})
+	}
+	return out, nil
+}
+
+func (s pgStore) File(fuuid string) (*LinkedFile, error) {
+	f, err := models.GetFileAccess(s.db, fuuid)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return &LinkedFile{
+		FUUID: f.FUUID, FileName: f.FileName, Sensitivity: f.Sensitivity,
+		Access: access.File{FUUID: f.FUUID, OwnerUUID: f.OwnerUUID, Sensitivity: f.Sensitivity, Departments: f.Departments},
+	}, nil
+}
+
+func (s pgStore) Workspaces(dUUID string) ([]Workspace, error) {
+	rows, err := models.ListWorkspaces(s.db, dUUID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Workspace, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Workspace(id string) (*Workspace, error) {
+	r, err := models.GetWorkspace(s.db, id)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	w := fromRow(*r)
+	return &w, nil
+}
+
+func (s pgStore) CreateWorkspace(w Workspace) (Workspace, error) {
+	row, err := models.InsertWorkspace(s.db, models.WorkspaceRow{
+		Name: w.Name, Description: w.Description, CreatedBy: w.CreatedBy, OwnerDUUID: w.OwnerDUUID,
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+	created, err := s.Workspace(row.WorkspaceID)
+	if err != nil || created == nil {
+		return Workspace{}, err
+	}
+	return *created, nil
+}
+
+func (s pgStore) UpdateWorkspace(w Workspace) (Workspace, error) {
+	row, err := models.UpdateWorkspace(s.db, models.WorkspaceRow{WorkspaceID: w.ID, Name: w.Name, Description: w.Description})
+	if err == sql.ErrNoRows {
+		return Workspace{}, ErrNotFound
+	}
+	if err != nil {
+		return Workspace{}, err
+	}
+	w.UpdatedAt = row.UpdatedAt
+	return w, nil
+}
+
+func (s pgStore) DeleteWorkspace(id string) error {
+	if err := models.DeleteWorkspace(s.db, id); err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s pgStore) SetDepartment(id string, d Department) error {
+	return models.UpsertWorkspaceDepartment(s.db, id, models.WorkspaceDepartmentRow{
+		DUUID: d.DUUID, Status: d.Status, InvitedBy: d.InvitedBy, InvitedAt: d.InvitedAt, JoinedAt: d.JoinedAt,
+	})
+}
+
+func (s pgStore) RemoveDepartment(id, dUUID string) (bool, error) {
+	return models.DeleteWorkspaceDepartment(s.db, id, dUUID)
+}
+
+func (s pgStore) Files(id string) ([]LinkedFile, error) {
+	rows, err := models.ListWorkspaceFiles(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LinkedFile, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, LinkedFile{
+			FUUID: r.FUUID, FileName: r.FileName, Sensitivity: r.Sensitivity,
+			LinkedBy: r.LinkedBy, LinkedByName: r.LinkedByName, LinkedAt: r.LinkedAt,
+			Access: access.File{FUUID: r.FUUID, OwnerUUID: r.OwnerUUID, Sensitivity: r.Sensitivity, Departments: r.Departments},
+		})
+	}
+	return out, nil
+}
+
+func (s pgStore) LinkFile(id, fuuid, linkedBy string) error {
+	return models.LinkWorkspaceFile(s.db, id, fuuid, linkedBy)
+}
+
+func (s pgStore) UnlinkFile(id, fuuid string) (bool, error) {
+	return models.UnlinkWorkspaceFile(s.db, id, fuuid)
+}
+
+func (s pgStore) Comments(id string) ([]Comment, error) {
+	rows, err := models.ListWorkspaceComments(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Comment, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, commentFromRow(r))
+	}
+	return out, nil
+}
+
+func (s pgStore) Comment(id, commentID string) (*Comment, error) {
+	r, err := models.GetWorkspaceComment(s.db, id, commentID)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	c := commentFromRow(*r)
+	return &c, nil
+}
+
+func (s pgStore) AddComment(c Comment) (Comment, error) {
+	row, err := models.InsertWorkspaceComment(s.db, models.WorkspaceCommentRow{
+		WorkspaceID: c.WorkspaceID, FUUID: c.FUUID, AuthorUUID: c.AuthorUUID, Body: c.Body,
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+	c.ID, c.CreatedAt = row.CommentID, row.CreatedAt
+	return c, nil
+}
+
+func (s pgStore) DeleteComment(id, commentID string) (bool, error) {
+	return models.DeleteWorkspaceComment(s.db, id, commentID)
+}
+
+// ---------------------------------------------------------------------------
+// In-memory store, for tests
+// ---------------------------------------------------------------------------
+
+type memLink struct {
+	fuuid, by string
+	at        time.Time
+}
+
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       map[string]Member
+	departments map[string]string
+	files       map[string]LinkedFile
+	workspaces  map[string]Workspace
+	links       map[string][]memLink
+	comments    map[string][]Comment
+	seq         int
+	now         time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       map[string]Member{},
+		departments: map[string]string{},
+		files:       map[string]LinkedFile{},
+		workspaces:  map[string]Workspace{},
+		links:       map[string][]memLink{},
+		comments:    map[string][]Comment{},
+		now:         time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func (m *MemoryStore) AddUser(u Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.UUID] = u
+}
+
+func (m *MemoryStore) AddDepartment(dUUID, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.departments[dUUID] = name
+}
+
+// AddFile registers a file with its own sharing.
+func (m *MemoryStore) AddFile(f access.File, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[f.FUUID] = LinkedFile{FUUID: f.FUUID, FileName: name, Sensitivity: f.Sensitivity, Access: f}
+}
+
+func (m *MemoryStore) tick() time.Time {
+	m.now = m.now.Add(time.Minute)
+	return m.now
+}
+
+func (m *MemoryStore) User(uuid string) (*Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[uuid]
+	if !ok {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) DepartmentName(dUUID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.departments[dUUID], nil
+}
+
+func (m *MemoryStore) Members(dUUIDs []string) ([]Member, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	want := map[string]bool{}
+	for _, d := range dUUIDs {
+		want[d] = true
+	}
+	var out []Member
+	for _, u := range m.users {
+		if want[u.DUUID] {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UUID < out[j].UUID })
+	return out, nil
+}
+
+func (m *MemoryStore) File(fuuid string) (*LinkedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[fuuid]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// copyOf fills in the derived fields and copies the department list.
+func (m *MemoryStore) copyOf(w Workspace) Workspace {
+	w.Departments = append([]Department{}, w.Departments...)
+	w.FileCount = len(m.links[w.ID])
+	if u, ok := m.users[w.CreatedBy]; ok {
+		w.CreatorName = u.Name
+	}
+	return w
+}
+
+func (m *MemoryStore) Workspaces(dUUID string) ([]Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Workspace{}
+	for _, w := range m.workspaces {
+		if w.department(dUUID) != nil {
+			out = append(out, m.copyOf(w))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *MemoryStore) Workspace(id string) (*Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workspaces[id]
+	if !ok {
+		return nil, nil
+	}
+	w = m.copyOf(w)
+	return &w, nil
+}
+
+func (m *MemoryStore) CreateWorkspace(w Workspace) (Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	w.ID = fmt.Sprintf("ws-%d", m.seq)
+	w.CreatedAt = m.tick()
+	w.UpdatedAt = w.CreatedAt
+	joined := w.CreatedAt
+	w.Departments = []Department{{
+		DUUID: w.OwnerDUUID, Name: m.departments[w.OwnerDUUID], Status: StatusJoined,
+		InvitedBy: w.CreatedBy, InvitedAt: w.CreatedAt, JoinedAt: &joined,
+	}}
+	m.workspaces[w.ID] = w
+	return m.copyOf(w), nil
+}
+
+func (m *MemoryStore) UpdateWorkspace(w Workspace) (Workspace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.workspaces[w.ID]
+	if !ok {
+		return Workspace{}, ErrNotFound
+	}
+	old.Name, old.Description, old.UpdatedAt = w.Name, w.Description, m.tick()
+	m.workspaces[w.ID] = old
+	return m.copyOf(old), nil
+}
+
+func (m *MemoryStore) DeleteWorkspace(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.workspaces[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.workspaces, id)
+	delete(m.links, id)
+	delete(m.comments, id)
+	return nil
+}
+
+func (m *MemoryStore) SetDepartment(id string, d Department) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workspaces[id]
+	if !ok {
+		return ErrNotFound
+	}
+	w.Departments = append([]Department{}, w.Departments...)
+	if existing := w.department(d.DUUID); existing != nil {
+		existing.Status, existing.JoinedAt = d.Status, d.JoinedAt
+	} else {
+		w.Departments = append(w.Departments, d)
+	}
+	m.workspaces[id] = w
+	return nil
+}
+
+func (m *MemoryStore) RemoveDepartment(id, dUUID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workspaces[id]
+	if !ok {
+		return false, nil
+	}
+	for i, d := range w.Departments {
+		if strings.EqualFold(d.DUUID, dUUID) {
+			w.Departments = append(append([]Department{}, w.Departments[:i]...), w.Departments[i+1:]...)
+			m.workspaces[id] = w
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) Files(id string) ([]LinkedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []LinkedFile{}
+	links := m.links[id]
+	for i := len(links) - 1; i >= 0; i-- {
+		f, ok := m.files[links[i].fuuid]
+		if !ok {
+			continue
+		}
+		f.LinkedBy, f.LinkedAt = links[i].by, links[i].at
+		f.LinkedByName = m.users[links[i].by].Name
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) LinkFile(id, fuuid, linkedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range m.links[id] {
+		if l.fuuid == fuuid {
+			return nil
+		}
+	}
+	m.links[id] = append(m.links[id], memLink{fuuid: fuuid, by: linkedBy, at: m.tick()})
+	return nil
+}
+
+func (m *MemoryStore) UnlinkFile(id, fuuid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	links := m.links[id]
+	for i, l := range links {
+		if l.fuuid == fuuid {
+			m.links[id] = append(links[:i], links[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) Comments(id string) ([]Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Comment{}, m.comments[id]...), nil
+}
+
+func (m *MemoryStore) Comment(id, commentID string) (*Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.comments[id] {
+		if c.ID == commentID {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) AddComment(c Comment) (Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	c.ID = fmt.Sprintf("c-%d", m.seq)
+	c.CreatedAt = m.tick()
+	m.comments[c.WorkspaceID] = append(m.comments[c.WorkspaceID], c)
+	return c, nil
+}
+
+func (m *MemoryStore) DeleteComment(id, commentID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.comments[id]
+	for i, c := range list {
+		if c.ID == commentID {
+			m.comments[id] = append(list[:i], list[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
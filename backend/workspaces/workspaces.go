@@ -0,0 +1,181 @@
+// Package workspaces implements cross-department collaboration workspaces.
+// A department head creates a workspace and invites other departments;
+// once a department's head accepts, its members can read the files linked
+// into the workspace (through access.File.WorkspaceDepartments, so the
+// usual sensitivity rules still apply) and comment on them. Files are
+// linked, never copied.
+package workspaces
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/access"
+)
+
+const (
+	MaxNameLen        = 120
+	MaxDescriptionLen = 1000
+	MaxCommentLen     = 4000
+	MaxDepartments    = 20
+)
+
+// Department membership states.
+const (
+	StatusInvited = "invited"
+	StatusJoined  = "joined"
+)
+
+// Roles of the caller in a workspace.
+const (
+	RoleOwner   = "owner"
+	RoleMember  = "member"
+	RoleInvited = "invited"
+)
+
+// Event kinds passed to Service.Notify.
+const (
+	EventInvited    = "invited"
+	EventJoined     = "joined"
+	EventFileLinked = "file_linked"
+	EventComment    = "comment"
+)
+
+var (
+	ErrNotFound     = errors.New("workspace not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrFileNotFound = errors.New("file not found")
+	ErrNoAccess     = errors.New("you do not have access to this file")
+	// ErrLinkNotAllowed: a member who may read a confidential file is not
+	// its uploader or a head of its department.
+	ErrLinkNotAllowed  = errors.New("only the uploader or a head of the file's department may link a confidential file")
+	ErrCommentNotFound = errors.New("comment not found")
+)
+
+// ValidationError is returned for bad input; its message is safe to show.
+type ValidationError struct{ Msg string }
+
+func (e ValidationError) Error() string { return e.Msg }
+
+func invalid(format string, args ...interface{}) error {
+	return ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Member is an active user as seen by workspaces.
+type Member struct {
+	UUID     string
+	Name     string
+	DUUID    string
+	Position string
+}
+
+func (m Member) IsHead() bool { return m.Position == "head" }
+
+// Department is a department's place in a workspace.
+type Department struct {
+	DUUID     string     `json:"d_uuid"`
+	Name      string     `json:"d_name"`
+	Status    string     `json:"status"`
+	InvitedBy string     `json:"invited_by,omitempty"`
+	InvitedAt time.Time  `json:"invited_at"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty"`
+}
+
+// Workspace is a shared space between departments. OwnerDUUID is the
+// creator's department; the creator and that department's heads manage the
+// workspace.
+type Workspace struct {
+	ID          string       `json:"workspace_id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	CreatedBy   string       `json:"created_by"`
+	CreatorName string       `json:"creator_name,omitempty"`
+	OwnerDUUID  string       `json:"owner_d_uuid"`
+	Departments []Department `json:"departments"`
+	FileCount   int          `json:"file_count"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	// Role is the caller's: owner, member or invited.
+	Role string `json:"role"`
+}
+
+// joined lists the departments that accepted.
+func (w Workspace) joined() []string {
+	var out []string
+	for _, d := range w.Departments {
+		if d.Status == StatusJoined {
+			out = append(out, d.DUUID)
+		}
+	}
+	return out
+}
+
+func (w Workspace) department(dUUID string) *Department {
+	for i := range w.Departments {
+		if strings.EqualFold(w.Departments[i].DUUID, dUUID) {
+			return &w.Departments[i]
+		}
+	}
+	return nil
+}
+
+// LinkedFile is a file linked into a workspace. Access carries the file's
+// own sharing, without any workspace.
+type LinkedFile struct {
+	FUUID        string      `json:"f_uuid"`
+	FileName     string      `json:"f_name"`
+	Sensitivity  string      `json:"sensitivity"`
+	LinkedBy     string      `json:"linked_by"`
+	LinkedByName string      `json:"linked_by_name,omitempty"`
+	LinkedAt     time.Time   `json:"linked_at"`
+	Access       access.File `json:"-"`
+}
+
+// Comment is a message in a workspace, optionally about one of its files.
+type Comment struct {
+	ID          string    `json:"comment_id"`
+	WorkspaceID string    `json:"workspace_id"`
+	FUUID       string    `json:"f_uuid,omitempty"`
+	AuthorUUID  string    `json:"author_uuid"`
+	AuthorName  string    `json:"author_name,omitempty"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Draft creates a workspace.
+type Draft struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Patch updates a workspace; nil fields are left alone.
+type Patch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// Event is something members should hear about. Recipients are resolved by
+// the service and exclude the actor.
+type Event struct {
+	Kind       string
+	Workspace  Workspace
+	Actor      Member
+	Department *Department
+	File       *LinkedFile
+	Comment    *Comment
+	Recipients []string
+}
+
+func checkText(field, s string, required bool, max int) (string, error) {
+	s = strings.TrimSpace(s)
+	if required && s == "" {
+		return "", invalid("%s is required", field)
+	}
+	if utf8.RuneCountInString(s) > max {
+		return "", invalid("%s must be at most %d characters", field, max)
+	}
+	return s, nil
+}
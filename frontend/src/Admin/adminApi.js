@@ -39,8 +39,8 @@ async function adminFetch(path, options = {}) {
     headers,
   });
 
-  // If the backend returns 401, the admin session is expired or invalid
-  // (e.g. backend was restarted and in-memory sessions were lost).
+  // If the backend returns 401, the admin session is expired, idle too long,
+  // or was revoked from the sessions list.
   // Clear the stale session and redirect to login.
   if (res.status === 401) {
     localStorage.removeItem("adminSession");
@@ -64,13 +64,36 @@ export async function adminLogin(username, password) {
 
   const data = await res.json();
   if (!res.ok) throw new Error(data.error || "Login failed");
-  return data; // { token, adminId, username }
+  return data; // { token, sessionId, adminId, username }
 }
 
 export async function adminLogout() {
   await adminFetch("/v1/admin/logout", { method: "POST" }).catch(() => {});
 }
 
+// ---------------------------------------------------------------------------
+// Sessions
+// ---------------------------------------------------------------------------
+
+export async function listAdminSessions() {
+  const res = await adminFetch("/v1/admin/sessions");
+  if (!res.ok) {
+    const err = await res.json().catch(() => ({}));
+    throw new Error(err.error || "Failed to list sessions");
+  }
+  return res.json();
+}
+
+export async function revokeAdminSession(sessionId) {
+  const res = await adminFetch(`/v1/admin/sessions/${sessionId}`, {
+    method: "DELETE",
+  });
+
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Failed to revoke session");
+  return data;
+}
+
 // ---------------------------------------------------------------------------
 // Users CRUD
 // ---------------------------------------------------------------------------
@@ -119,3 +142,95 @@ export async function deleteUser(userId) {
   if (!res.ok) throw new Error(data.error || "Failed to delete user");
   return data;
 }
+
+/**
+ * Bulk-create users from a CSV file (name, email, phone, department, role,
+ * position). With dryRun the rows are only validated and returned; otherwise
+ * the users are created in the background: poll getUserImport(import_id)
+ * until its status is no longer "running".
+ */
+export async function importUsers(file, { dryRun = false } = {}) {
+  const res = await adminFetch(`/v1/admin/users/import${dryRun ? "?dry_run=true" : ""}`, {
+    method: "POST",
+    headers: { "Content-Type": "text/csv" },
+    body: file,
+  });
+
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Failed to import users");
+  return data;
+}
+
+export async function getUserImport(importId) {
+  const res = await adminFetch(`/v1/admin/users/import/${importId}`);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || "Import not found");
+  return data;
+}
+
+export async function downloadImportReport(importId) {
+  const res = await adminFetch(`/v1/admin/users/import/${importId}/report`);
+  if (!res.ok) throw new Error("Import report not found or not finished");
+  return res.blob();
+}
+
+// ---------------------------------------------------------------------------
+// Departments & Roles CRUD
+// ---------------------------------------------------------------------------
+
+async function adminJSON(path, options, fallbackError) {
+  const res = await adminFetch(path, options);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || fallbackError);
+  return data;
+}
+
+export function listDepartments() {
+  return adminJSON("/v1/admin/departments", {}, "Failed to list departments");
+}
+
+export function createDepartment(name) {
+  return adminJSON("/v1/admin/departments", {
+    method: "POST",
+    body: JSON.stringify({ d_name: name }),
+  }, "Failed to create department");
+}
+
+export function updateDepartment(departmentId, name) {
+  return adminJSON(`/v1/admin/departments?id=${departmentId}`, {
+    method: "PUT",
+    body: JSON.stringify({ d_name: name }),
+  }, "Failed to update department");
+}
+
+export function deleteDepartment(departmentId, reassignTo) {
+  const query = reassignTo ? `&reassign_to=${reassignTo}` : "";
+  return adminJSON(`/v1/admin/departments?id=${departmentId}${query}`, {
+    method: "DELETE",
+  }, "Failed to delete department");
+}
+
+export function listRoles(departmentId) {
+  const query = departmentId ? `?d_uuid=${departmentId}` : "";
+  return adminJSON(`/v1/admin/roles${query}`, {}, "Failed to list roles");
+}
+
+export function createRole(departmentId, name) {
+  return adminJSON("/v1/admin/roles", {
+    method: "POST",
+    body: JSON.stringify({ r_name: name, d_uuid: departmentId }),
+  }, "Failed to create role");
+}
+
+export function updateRole(roleId, name) {
+  return adminJSON(`/v1/admin/roles?id=${roleId}`, {
+    method: "PUT",
+    body: JSON.stringify({ r_name: name }),
+  }, "Failed to update role");
+}
+
+export function deleteRole(roleId) {
+  return adminJSON(`/v1/admin/roles?id=${roleId}`, {
+    method: "DELETE",
+  }, "Failed to delete role");
+}
@@ -1,5 +1,5 @@
 import React, { useState, useEffect, useCallback } from 'react';
-import { supabase } from '../../supabaseClient';
+import { listDepartments, createDepartment, updateDepartment, deleteDepartment } from '../adminApi';
 
 const DepartmentManagement = () => {
   const [departments, setDepartments] = useState([]);
@@ -34,15 +34,7 @@ const DepartmentManagement = () => {
     setLoadingDepartments(true);
     
     try {
-      const { data, error } = await supabase
-        .from("department")
-        .select(`
-          d_uuid,
-          d_name
-        `)
-        .order('d_name', { ascending: true });
-      
-      if (error) throw error;
+      const data = await listDepartments();
       
       setDepartments(data || []);
     } catch (error) {
@@ -101,19 +93,10 @@ const DepartmentManagement = () => {
       }
       
       // Add department to database
-      const { data, error } = await supabase
-        .from('department')
-        .insert([
-          {
-            d_name: departmentForm.name.trim()
-          }
-        ])
-        .select();
-      
-      if (error) throw error;
+      const created = await createDepartment(departmentForm.name.trim());
       
       // Update local state
-      setDepartments([...departments, data[0]]);
+      setDepartments([...departments, created]);
       
       // Show success notification
       showNotification('success', 'Department added successfully!');
@@ -139,14 +122,7 @@ const DepartmentManagement = () => {
       }
       
       // Update department in database
-      const { error } = await supabase
-        .from('department')
-        .update({
-          d_name: departmentForm.name.trim()
-        })
-        .eq('d_uuid', editingDepartment.d_uuid);
-      
-      if (error) throw error;
+      await updateDepartment(editingDepartment.d_uuid, departmentForm.name.trim());
       
       // Update local state
       setDepartments(departments.map(dept => 
@@ -176,12 +152,8 @@ const DepartmentManagement = () => {
     
     try {
       // Delete department from database
-      const { error } = await supabase
-        .from('department')
-        .delete()
-        .eq('d_uuid', deptToDelete.d_uuid);
-      
-      if (error) throw error;
+      // The backend refuses while users or files remain in the department
+      await deleteDepartment(deptToDelete.d_uuid);
       
       // Update local state
       setDepartments(departments.filter(dept => dept.d_uuid !== deptToDelete.d_uuid));
@@ -391,7 +363,7 @@ const DepartmentManagement = () => {
             <div className="mb-4">
               <h3 className="text-lg font-medium text-gray-900">Confirm Delete</h3>
               <p className="text-sm text-gray-500 mt-2">
-                Are you sure you want to delete department <span className="font-medium">{deptToDelete?.d_name}</span>? This will also delete all roles associated with this department.
+                Are you sure you want to delete department <span className="font-medium">{deptToDelete?.d_name}</span>? This will also delete all roles associated with this department. Departments that still have users or files cannot be deleted.
               </p>
             </div>
             
@@ -1,5 +1,5 @@
 import React, { useState, useEffect, useCallback } from 'react';
-import { supabase } from '../../supabaseClient';
+import { listDepartments, listRoles, createRole, updateRole, deleteRole } from '../adminApi';
 
 const RoleManagement = () => {
   const [departments, setDepartments] = useState([]);
@@ -39,13 +39,7 @@ const RoleManagement = () => {
     setLoadingRoles(true);
     
     try {
-      const { data, error } = await supabase
-        .from('role')
-        .select('r_uuid, r_name, d_uuid')
-        .eq('d_uuid', departmentId)
-        .order('r_name', { ascending: true });
-      
-      if (error) throw error;
+      const data = await listRoles(departmentId);
       
       setRoles(data || []);
     } catch (error) {
@@ -62,12 +56,7 @@ const RoleManagement = () => {
       
       try {
         // Fetch all departments
-        const { data, error } = await supabase
-          .from('department')
-          .select('d_uuid, d_name')
-          .order('d_name', { ascending: true });
-        
-        if (error) throw error;
+        const data = await listDepartments();
         
         setDepartments(data || []);
         
@@ -143,20 +132,10 @@ const RoleManagement = () => {
       }
       
       // Add role to database
-      const { data, error } = await supabase
-        .from('role')
-        .insert([
-          {
-            r_name: roleForm.name.trim(),
-            d_uuid: selectedDepartment.d_uuid
-          }
-        ])
-        .select();
-      
-      if (error) throw error;
+      const created = await createRole(selectedDepartment.d_uuid, roleForm.name.trim());
       
       // Update local state
-      setRoles([...roles, data[0]]);
+      setRoles([...roles, created]);
       
       // Show success notification
       showNotification('success', 'Role added successfully!');
@@ -182,14 +161,7 @@ const RoleManagement = () => {
       }
       
       // Update role in database
-      const { error } = await supabase
-        .from('role')
-        .update({
-          r_name: roleForm.name.trim()
-        })
-        .eq('r_uuid', editingRole.r_uuid);
-      
-      if (error) throw error;
+      await updateRole(editingRole.r_uuid, roleForm.name.trim());
       
       // Update local state
       setRoles(roles.map(role => 
@@ -219,12 +191,7 @@ const RoleManagement = () => {
     
     try {
       // Delete role from database
-      const { error } = await supabase
-        .from('role')
-        .delete()
-        .eq('r_uuid', roleToDelete.r_uuid);
-      
-      if (error) throw error;
+      await deleteRole(roleToDelete.r_uuid);
       
       // Update local state
       setRoles(roles.filter(role => role.r_uuid !== roleToDelete.r_uuid));
@@ -190,7 +190,8 @@ const UserManagement = () => {
     setIsSubmitting(true);
     
     try {
-      // Delete user via backend admin API (handles both auth + DB deletion)
+      // Deactivate user via backend admin API (bans auth login, keeps the profile,
+      // hands owned files to the department head)
       const result = await apiDeleteUser(userToDelete.uuid);
       
       // Update local state to remove the deleted user
@@ -199,9 +200,9 @@ const UserManagement = () => {
       setNotification({
         show: true,
         type: 'success',
-        message: result.auth_deleted
-          ? 'User deleted successfully from both database and authentication!'
-          : 'User deleted from database (auth deletion may require manual cleanup)'
+        message: result.files_reassigned
+          ? `User deactivated. ${result.files_reassigned} file(s) reassigned.`
+          : 'User deactivated successfully!'
       });
       
       // Close the confirmation dialog
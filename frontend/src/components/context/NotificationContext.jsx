@@ -1,6 +1,8 @@
 import React, { createContext, useContext, useState, useEffect, useCallback } from 'react';
 import { useAuth } from './AuthContext';
 import { supabase } from '../../supabaseClient';
+import { wsURL } from '../../utils/apiBase';
+import { fetchUnreadCount } from '../../utils/notificationsApi';
 
 const NotificationContext = createContext();
 
@@ -16,7 +18,7 @@ export const NotificationProvider = ({ children }) => {
   const [notificationCount, setNotificationCount] = useState(0);
   const { user } = useAuth();
 
-  // Function to fetch notification count using exact same logic as notifications page
+  // Unread count comes from the backend (one row per file, like the notifications page)
   const fetchNotificationCount = useCallback(async () => {
     if (!user?.id) {
       setNotificationCount(0);
@@ -24,93 +26,7 @@ export const NotificationProvider = ({ children }) => {
     }
 
     try {
-      // Get user data - same as notifications page
-      const { data: userData, error: userError } = await supabase
-        .from('users')
-        .select('d_uuid, position')
-        .eq('uuid', user.id)
-        .maybeSingle();
-
-      if (userError || !userData?.d_uuid) {
-        setNotificationCount(0);
-        return;
-      }
-
-      // Calculate 4 days ago - exact same logic as notifications page
-      const fourDaysAgo = new Date();
-      fourDaysAgo.setDate(fourDaysAgo.getDate() - 4);
-      fourDaysAgo.setHours(0, 0, 0, 0);
-      const fourDaysAgoStr = fourDaysAgo.toISOString();
-
-      // All users see the same notifications
-      let notificationsQuery = supabase
-        .from('notifications')
-        .select('notif_id, f_uuid, is_seen, is_sent, created_at')
-        .eq('uuid', user.id)
-        .eq('is_seen', false)
-        .eq('is_sent', true)
-        .gte('created_at', fourDaysAgoStr)
-        .order('created_at', { ascending: false });
-
-      const { data, error } = await notificationsQuery;
-
-      if (error || !data) {
-        setNotificationCount(0);
-        return;
-      }
-
-      // Get file details - exact same as notifications page
-      const fileIds = data.map(n => n.f_uuid);
-      if (fileIds.length === 0) {
-        setNotificationCount(0);
-        return;
-      }
-
-      const { data: fileData, error: fileError } = await supabase
-        .from('file')
-        .select(`
-          f_uuid, 
-          f_name, 
-          created_at, 
-          d_uuid,
-          department:d_uuid(d_name),
-          file_department(
-            fd_uuid,
-            d_uuid,
-            is_approved
-          )
-        `)
-        .in('f_uuid', fileIds);
-
-      if (fileError || !fileData) {
-        setNotificationCount(0);
-        return;
-      }
-
-      // Create file map - exact same as notifications page
-      const fileMap = {};
-      fileData.forEach(file => {
-        fileMap[file.f_uuid] = file;
-      });
-
-      // Deduplicate notifications - exact same as notifications page
-      const sortedByNewest = data.slice().sort((a, b) => new Date(b.created_at) - new Date(a.created_at));
-      const seenFiles = new Set();
-      const latestPerFile = [];
-      for (const n of sortedByNewest) {
-        if (!seenFiles.has(n.f_uuid)) {
-          seenFiles.add(n.f_uuid);
-          latestPerFile.push(n);
-        }
-      }
-
-      // Filter notifications - show all files
-      const visibleNotifications = latestPerFile.filter(notification => {
-        const file = fileMap[notification.f_uuid];
-        return !!file;
-      });
-
-      setNotificationCount(visibleNotifications.length);
+      setNotificationCount(await fetchUnreadCount());
     } catch (err) {
       setNotificationCount(0);
     }
@@ -125,6 +41,39 @@ export const NotificationProvider = ({ children }) => {
     }
   }, [user?.id, fetchNotificationCount]);
 
+  // Live in-app notifications: the backend pushes an event per notification
+  // over a websocket; refresh the count whenever one arrives.
+  useEffect(() => {
+    if (!user?.id) return undefined;
+
+    let socket = null;
+    let retryTimer = null;
+    let retryDelay = 1000;
+    let stopped = false;
+
+    const connect = async () => {
+      const { data } = await supabase.auth.getSession();
+      const token = data?.session?.access_token;
+      if (!token || stopped) return;
+
+      socket = new WebSocket(wsURL(`/v1/ws/notifications?access_token=${encodeURIComponent(token)}`));
+      socket.onopen = () => { retryDelay = 1000; };
+      socket.onmessage = () => { fetchNotificationCount(); };
+      socket.onclose = () => {
+        if (stopped) return;
+        retryTimer = setTimeout(connect, retryDelay);
+        retryDelay = Math.min(retryDelay * 2, 60000);
+      };
+    };
+    connect();
+
+    return () => {
+      stopped = true;
+      clearTimeout(retryTimer);
+      if (socket) socket.close();
+    };
+  }, [user?.id, fetchNotificationCount]);
+
   const updateNotificationCount = (count) => {
     setNotificationCount(count);
   };
@@ -13,6 +13,8 @@ const HeaderSearch = () => {
   const [languages, setLanguages] = useState([]);
   const [selectedDepartments, setSelectedDepartments] = useState([]); // Array of d_uuid
   const [selectedLanguages, setSelectedLanguages] = useState([]); // Array of language strings
+  const [categories, setCategories] = useState([]);
+  const [selectedCategories, setSelectedCategories] = useState([]); // Array of category_id
   const popupRef = useRef();
   const sidebarRef = useRef();
 
@@ -24,6 +26,12 @@ const HeaderSearch = () => {
       setDepartments(deptData || []);
       const { data: langData } = await supabase.from("file").select("language");
       setLanguages([...new Set((langData || []).map(f => f.language).filter(Boolean))]);
+      const { data: catData } = await supabase
+        .from("document_categories")
+        .select("category_id, name")
+        .eq("enabled", true)
+        .order("name", { ascending: true });
+      setCategories(catData || []);
     };
     fetchFilters();
   }, []);
@@ -32,7 +40,8 @@ const HeaderSearch = () => {
     if (
       !query.trim() &&
       selectedDepartments.length === 0 &&
-      selectedLanguages.length === 0
+      selectedLanguages.length === 0 &&
+      selectedCategories.length === 0
     ) {
       setResults([]);
       setShowPopup(false);
@@ -52,6 +61,10 @@ const HeaderSearch = () => {
               d_name,
               d_uuid
             )
+          ),
+          document_classifications${selectedCategories.length > 0 ? "!inner" : ""}(
+            category_id,
+            document_categories(name)
           )
         `)
         .order("created_at", { ascending: false });
@@ -61,6 +74,11 @@ const HeaderSearch = () => {
         queryBuilder = queryBuilder.ilike("f_name", `%${query.trim()}%`);
       }
 
+      // Category filter runs server-side on the file's classification
+      if (selectedCategories.length > 0) {
+        queryBuilder = queryBuilder.in("document_classifications.category_id", selectedCategories);
+      }
+
       let { data, error } = await queryBuilder;
 
       if (!error && data) {
@@ -103,7 +121,7 @@ const HeaderSearch = () => {
     };
     const timeout = setTimeout(fetchResults, 300); // debounce
     return () => clearTimeout(timeout);
-  }, [query, selectedDepartments, selectedLanguages, departments]);
+  }, [query, selectedDepartments, selectedLanguages, selectedCategories, departments]);
 
   // Close popup on outside click
   useEffect(() => {
@@ -160,6 +178,18 @@ const HeaderSearch = () => {
     setSelectedLanguages(selectedLanguages.filter(l => l !== lang));
   };
 
+  // Add category to selectedCategories
+  const handleAddCategory = (category_id) => {
+    if (category_id && !selectedCategories.includes(category_id)) {
+      setSelectedCategories([...selectedCategories, category_id]);
+    }
+  };
+
+  // Remove category from selectedCategories
+  const handleRemoveCategory = (category_id) => {
+    setSelectedCategories(selectedCategories.filter(id => id !== category_id));
+  };
+
   return (
     <div className="relative w-full max-w-2xl mx-auto">
       {/* Search bar */}
@@ -174,7 +204,7 @@ const HeaderSearch = () => {
             value={query}
             onChange={e => setQuery(e.target.value)}
             onFocus={() =>
-          (query || selectedDepartments.length > 0 || selectedLanguages.length > 0) &&
+          (query || selectedDepartments.length > 0 || selectedLanguages.length > 0 || selectedCategories.length > 0) &&
           setShowPopup(true)
             }
           />
@@ -232,6 +262,29 @@ const HeaderSearch = () => {
           ))}
         </div>
       )}
+      {selectedCategories.length > 0 && (
+        <div className="flex flex-wrap gap-2 mt-2">
+          {selectedCategories.map(category_id => {
+            const cat = categories.find(c => c.category_id === category_id);
+            return (
+              <span
+                key={category_id}
+                className="flex items-center bg-emerald-100 text-emerald-800 px-3 py-1 rounded-full text-xs font-medium"
+              >
+                {cat?.name || "Category"}
+                <button
+                  className="ml-1 text-emerald-500 hover:text-emerald-700"
+                  onClick={() => handleRemoveCategory(category_id)}
+                  aria-label="Remove category"
+                  type="button"
+                >
+                  <XMarkIcon className="h-4 w-4" />
+                </button>
+              </span>
+            );
+          })}
+        </div>
+      )}
       {/* Filter sidebar */}
       <div
         className={`fixed top-0 right-0 h-full w-80 bg-white shadow-2xl z-50 transition-transform duration-300 ease-in-out ${
@@ -284,6 +337,21 @@ const HeaderSearch = () => {
                 ))}
             </select>
           </div>
+          <div>
+            <label className="block text-xs text-gray-500 mb-1">Add Category</label>
+            <select
+              className="w-full border rounded px-2 py-1"
+              value=""
+              onChange={e => handleAddCategory(e.target.value)}
+            >
+              <option value="">Select Category</option>
+              {categories
+                .filter(cat => !selectedCategories.includes(cat.category_id))
+                .map(cat => (
+                  <option key={cat.category_id} value={cat.category_id}>{cat.name}</option>
+                ))}
+            </select>
+          </div>
         </div>
       </div>
       {/* Search results popup */}
@@ -314,7 +382,13 @@ const HeaderSearch = () => {
         .filter(Boolean)
         .join(", ") || "No Department"}
     </span>
-    <span className="text-xs text-gray-400">{file.language || ""}</span>
+    <span className="text-xs text-gray-400">
+      {[file.language, (Array.isArray(file.document_classifications)
+        ? file.document_classifications[0]
+        : file.document_classifications)?.document_categories?.name]
+        .filter(Boolean)
+        .join(" · ")}
+    </span>
     <span className="text-xs text-gray-400">{file.created_at ? new Date(file.created_at).toLocaleString() : ""}</span>
               </div>
             ))
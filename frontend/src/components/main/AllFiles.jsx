@@ -2,6 +2,8 @@ import React, { useState, useEffect, useMemo, useCallback, useRef } from "react"
 import { useAuth } from "../context/AuthContext";
 import { supabase } from "../../supabaseClient";
 import { useFilter } from "../context/FilterContext";
+import { listFiles } from "../../utils/filesApi";
+import { addFavorite, removeFavorite } from "../../utils/favoritesApi";
 
 const AllFiles = () => {
   const { user } = useAuth();
@@ -10,6 +12,8 @@ const AllFiles = () => {
   const [departments, setDepartments] = useState([]);
   const [selectedDepartment, setSelectedDepartment] = useState("");
   const [selectedLanguage, setSelectedLanguage] = useState("");
+  const [categories, setCategories] = useState([]);
+  const [selectedCategory, setSelectedCategory] = useState("");
   const [searchTerm] = useState("");
   const [allDepartmentFiles, setAllDepartmentFiles] = useState([]);
   const [loading, setLoading] = useState(true);
@@ -50,112 +54,81 @@ const AllFiles = () => {
     fetchDepartments();
   }, []);
 
+  // Fetch the enabled document categories
+  useEffect(() => {
+    const fetchCategories = async () => {
+      const { data, error } = await supabase
+        .from("document_categories")
+        .select("category_id, name")
+        .eq("enabled", true)
+        .order("name", { ascending: true });
+      setCategories(error ? [] : data || []);
+    };
+    fetchCategories();
+  }, []);
+
   // Reset pagination when filters change
   useEffect(() => {
     setPage(1);
     setAllDepartmentFiles([]);
     setHasMore(true);
-  }, [selectedDepartment, selectedLanguage, searchTerm, globalSearchTerm]);
+  }, [selectedDepartment, selectedLanguage, selectedCategory, searchTerm, globalSearchTerm]);
 
-  // Fetch files and favorites, then merge
+  // Fetch a page of files; the API returns departments, category and the
+  // favorite flag with each file.
+  const nextCursor = useRef(null);
   const fetchFiles = useCallback(async (pageNum = 1, append = false) => {
     if (pageNum === 1) {
       setLoading(true);
+      nextCursor.current = null;
     } else {
       setLoadingMore(true);
     }
 
-    const from = (pageNum - 1) * FILES_PER_PAGE;
-    const to = from + FILES_PER_PAGE - 1;
-
-    // 1. Fetch all files with departments and uploader
-    const selectClause = `
-      f_uuid,
-      f_name,
-      language,
-      created_at,
-      uploader:uuid(name),
-      file_department${selectedDepartment ? "!inner" : ""} (
-        d_uuid,
-        is_approved,
-        department:d_uuid ( d_uuid, d_name )
-      )
-    `;
-
-    let fileQuery = supabase
-      .from("file")
-      .select(selectClause, { count: 'exact' })
-      .order("created_at", { ascending: false })
-      .range(from, to);
-
-    // Department: inner join + nested column filter
-    if (selectedDepartment) {
-      fileQuery = fileQuery.eq("file_department.d_uuid", selectedDepartment);
-    }
-
-
-
-    // Language
-    if (selectedLanguage) {
-      fileQuery = fileQuery.eq("language", selectedLanguage);
-    }
-
-    // Search (local or global)
     const effectiveSearch = searchTerm?.trim() || globalSearchTerm?.trim();
-    if (effectiveSearch) {
-      fileQuery = fileQuery.ilike("f_name", `%${effectiveSearch}%`);
-    }
-
-    const { data: files, error: fileError, count } = await fileQuery;
-    if (fileError) {
+    try {
+      const { files, next_cursor } = await listFiles({
+        dUUID: selectedDepartment,
+        language: selectedLanguage,
+        category: selectedCategory,
+        q: effectiveSearch,
+        limit: FILES_PER_PAGE,
+        cursor: pageNum === 1 ? null : nextCursor.current,
+      });
+      nextCursor.current = next_cursor;
+      setHasMore(!!next_cursor);
+
+      const normalized = (files || []).map(f => ({
+        ...f,
+        uploader: { name: f.uploader_name },
+      }));
+      if (append) {
+        setAllDepartmentFiles(prev => [...prev, ...normalized]);
+      } else {
+        setAllDepartmentFiles(normalized);
+      }
+    } catch (err) {
       if (pageNum === 1) {
         setAllDepartmentFiles([]);
       }
-      setLoading(false);
-      setLoadingMore(false);
-      return;
-    }
-
-    // Check if there are more files to load
-    setHasMore(!!(count && count > to + 1));
-
-    // 2. Fetch only favorites matching the current page's files
-    let favouriteIds = [];
-    const currentFileIds = (files || []).map(f => f.f_uuid);
-    if (user?.id && currentFileIds.length > 0) {
-      const { data: favs, error: favError } = await supabase
-        .from("favorites")
-        .select("f_uuid")
-        .eq("uuid", user.id)
-        .in("f_uuid", currentFileIds);
-      if (!favError && favs) {
-        favouriteIds = favs.map(f => f.f_uuid);
-      }
-    }
-
-    // 3. Merge: mark files as favorite if in favouriteIds
-    const filesWithFav = (files || []).map(f => ({
-      ...f,
-      departments: (f.file_department || [])
-        .map(fd => fd.department)
-        .filter(Boolean),
-      is_favorite: favouriteIds.includes(f.f_uuid),
-    }));
-
-    if (append) {
-      setAllDepartmentFiles(prev => [...prev, ...filesWithFav]);
-    } else {
-      setAllDepartmentFiles(filesWithFav);
+      setHasMore(false);
     }
 
     setLoading(false);
     setLoadingMore(false);
-  }, [user, selectedDepartment, selectedLanguage, searchTerm, globalSearchTerm, FILES_PER_PAGE]);
+  }, [selectedDepartment, selectedLanguage, selectedCategory, searchTerm, globalSearchTerm, FILES_PER_PAGE]);
 
   useEffect(() => {
     fetchFiles(1, false);
   }, [fetchFiles]);
 
+  useEffect(() => {
+    if (page > 1 && nextCursor.current) {
+      fetchFiles(page, true);
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [page]);
+
   // Fetch distinct languages ignoring selectedLanguage (but respecting dept + search)
   useEffect(() => {
     const fetchLanguages = async () => {
@@ -219,25 +192,9 @@ const AllFiles = () => {
 
     try {
       if (currentlyImportant) {
-        const { error } = await supabase
-          .from("favorites")
-          .delete()
-          .eq("uuid", user.id)
-          .eq("f_uuid", f_uuid);
-        if (error) throw error;
+        await removeFavorite(f_uuid);
       } else {
-        // If you don't have a unique constraint on (uuid,f_uuid), keep this insert.
-        const { data: exists } = await supabase
-          .from("favorites")
-          .select("fav_uuid")
-          .eq("uuid", user.id)
-          .eq("f_uuid", f_uuid)
-          .maybeSingle();
-
-        if (!exists) {
-          const { error } = await supabase.from("favorites").insert({ uuid: user.id, f_uuid });
-          if (error) throw error;
-        }
+        await addFavorite(f_uuid);
       }
     } catch (e) {
       // Revert on failure
@@ -284,6 +241,19 @@ const AllFiles = () => {
             </option>
           ))}
         </select>
+
+        <select
+          className="border border-gray-300 rounded px-3 py-2 focus:outline-none focus:ring-2 focus:ring-blue-500"
+          value={selectedCategory}
+          onChange={(e) => setSelectedCategory(e.target.value)}
+        >
+          <option value="">All Categories</option>
+          {categories.map((cat) => (
+            <option key={cat.category_id} value={cat.category_id}>
+              {cat.name}
+            </option>
+          ))}
+        </select>
       </div>
 
       {/* Files grid */}
@@ -337,6 +307,13 @@ const AllFiles = () => {
                               {file.language || "Unknown"}
                             </span>
                           </div>
+
+                          <div className="flex items-center gap-2 flex-wrap">
+                            <span className="text-[12px] font-medium text-gray-600">Category:</span>
+                            <span className="inline-block text-xs bg-emerald-100 text-emerald-800 rounded px-2 py-0.5">
+                              {file.category || "Uncategorized"}
+                            </span>
+                          </div>
                         </div>
                       </div>
 
@@ -1,16 +1,38 @@
-import React from 'react';
+import React, { useEffect, useState } from 'react';
 import { DocumentTextIcon } from '@heroicons/react/24/outline';
 import { supabase } from '../../supabaseClient';
+import { fetchThumbnail } from '../../utils/filesApi';
 
 const FileCard = ({ file, onToggleFavorite }) => {
     const publicUrl = file.file_path
         ? supabase.storage.from("file_storage").getPublicUrl(file.file_path).data.publicUrl
         : null;
 
+    const [thumbnail, setThumbnail] = useState(null);
+    useEffect(() => {
+        let url = null;
+        let cancelled = false;
+        fetchThumbnail(file.f_uuid)
+            .then((u) => {
+                url = u;
+                if (!cancelled) setThumbnail(u);
+                else if (u) URL.revokeObjectURL(u);
+            })
+            .catch(() => {});
+        return () => {
+            cancelled = true;
+            if (url) URL.revokeObjectURL(url);
+        };
+    }, [file.f_uuid]);
+
     return (
         <div className="bg-white p-4 rounded-lg shadow-sm border border-gray-200 hover:shadow-md transition group">
             <div className="flex items-start gap-4">
-                <DocumentTextIcon className="h-8 w-8 text-blue-500 flex-shrink-0" />
+                {thumbnail ? (
+                    <img src={thumbnail} alt="" className="h-16 w-12 object-cover object-top rounded border border-gray-200 flex-shrink-0" />
+                ) : (
+                    <DocumentTextIcon className="h-8 w-8 text-blue-500 flex-shrink-0" />
+                )}
                 <div className="flex-1 overflow-hidden">
                     <p className="font-semibold text-gray-800 truncate" title={file.f_name}>
                         {file.f_name}
@@ -2,18 +2,21 @@ import React, { useEffect, useState } from 'react';
 import { useParams, useLocation } from 'react-router-dom';
 import { supabase } from '../../supabaseClient';
 import { useAuth } from '../context/AuthContext';
-
-const BUCKET_NAME = 'file_storage';
+import { markNotificationsRead } from '../../utils/notificationsApi';
+import { downloadFile } from '../../utils/filesApi';
 
 const FileViewer = () => {
   const { uuid } = useParams();
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(true);
+  const [preview, setPreview] = useState(null);
   const { user } = useAuth();
   const location = useLocation();
 
   // Check if this view was initiated from a notification
-  const fromNotification = new URLSearchParams(location.search).get('from') === 'notification';
+  const params = new URLSearchParams(location.search);
+  const fromNotification = params.get('from') === 'notification';
+  const notifId = params.get('notif');
 
   useEffect(() => {
     const fetchAndDownload = async () => {
@@ -37,37 +40,47 @@ const FileViewer = () => {
         // If viewing from notification, mark it as seen
         if (fromNotification && user) {
           try {
-            const { error: markError } = await supabase
-              .from('notifications')
-              .update({ is_seen: true })
-              .match({ f_uuid: uuid, uuid: user.id });
+            if (notifId) {
+              await markNotificationsRead([notifId]);
+            } else {
+              const { error: markError } = await supabase
+                .from('notifications')
+                .update({ is_seen: true })
+                .match({ f_uuid: uuid, uuid: user.id });
 
-            if (markError) {
-              console.error('Failed to mark notification as seen:', markError);
+              if (markError) {
+                console.error('Failed to mark notification as seen:', markError);
+              }
             }
           } catch (err) {
             console.error('Error marking notification as seen:', err);
           }
         }
 
-        // Generate signed URL (private bucket)
-        const { data: signedData, error: signedError } = await supabase
-          .storage
-          .from(BUCKET_NAME)
-          .createSignedUrl(file_path, 60 * 60); // 1 hour
+        // The backend checks department access; confidential files come
+        // back inline-only with a watermark and are shown here, not saved.
+        let file;
+        try {
+          file = await downloadFile(uuid);
+        } catch (downloadError) {
+          setError(downloadError.message || 'Could not download the file.');
+          return;
+        }
+        const url = URL.createObjectURL(file.blob);
 
-        if (signedError || !signedData?.signedUrl) {
-          setError('Could not generate signed URL.');
+        if (file.inline && file.watermark) {
+          setPreview({ url, watermark: file.watermark, name: f_name });
           return;
         }
 
         // Force browser download
         const link = document.createElement('a');
-        link.href = signedData.signedUrl;
+        link.href = url;
         link.setAttribute('download', f_name);
         document.body.appendChild(link);
         link.click();
         document.body.removeChild(link);
+        setTimeout(() => URL.revokeObjectURL(url), 60 * 1000);
       } catch (err) {
         console.error('FileViewer error:', err);
         setError('An error occurred while processing your request.');
@@ -79,6 +92,28 @@ const FileViewer = () => {
     fetchAndDownload();
   }, [uuid, fromNotification, user]);
 
+  useEffect(() => () => {
+    if (preview) URL.revokeObjectURL(preview.url);
+  }, [preview]);
+
+  if (preview) {
+    return (
+      <div className="p-4 flex flex-col gap-2 min-h-[300px]">
+        <div className="text-sm font-medium text-red-600">{preview.name} — view only</div>
+        <div className="relative w-full h-[80vh] border rounded overflow-hidden">
+          <iframe title={preview.name} src={preview.url} className="w-full h-full" />
+          <div className="pointer-events-none absolute inset-0 flex flex-wrap content-around justify-around overflow-hidden select-none">
+            {Array.from({ length: 12 }).map((_, i) => (
+              <span key={i} className="-rotate-12 text-gray-500/30 text-sm font-semibold whitespace-nowrap">
+                {preview.watermark}
+              </span>
+            ))}
+          </div>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="p-8 flex flex-col items-center justify-center min-h-[300px]">
       {error ? (
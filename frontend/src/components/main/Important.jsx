@@ -2,6 +2,7 @@ import React, { useEffect, useState } from "react";
 import { supabase } from "../../supabaseClient";
 import { useAuth } from "../context/AuthContext";
 import { Link } from "react-router-dom";
+import { removeFavorite } from "../../utils/favoritesApi";
 
 export default function Important() {
   const { user } = useAuth();
@@ -68,12 +69,7 @@ export default function Important() {
     setImportants((prev) => prev.filter((f) => f.file?.f_uuid !== id));
     setImpBusy((s) => ({ ...s, [id]: true }));
     try {
-      const { error } = await supabase
-        .from("favorites")
-        .delete()
-        .eq("uuid", user.id)
-        .eq("f_uuid", id);
-      if (error) throw error;
+      await removeFavorite(id);
     } catch (e) {
       // revert on failure
       setImportants((prev) => [{ file, fav_uuid: `tmp-${id}` }, ...prev]);
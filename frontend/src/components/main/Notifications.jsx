@@ -2,6 +2,7 @@ import React, { useState, useEffect, useCallback } from 'react';
 import { supabase } from '../../supabaseClient';
 import { useAuth } from '../context/AuthContext';
 import { useNotificationCount } from '../context/NotificationContext';
+import { listNotifications, markAllNotificationsRead } from '../../utils/notificationsApi';
 import {
   DocumentTextIcon,
   BellIcon,
@@ -129,124 +130,28 @@ const Notifications = () => {
     setError(null);
 
     try {
-      // Get user's department
-      const { data: userData, error: userError } = await supabase
-        .from('users')
-        .select('d_uuid, position')
-        .eq('uuid', user.id)
-        .maybeSingle();
-
-      if (userError || !userData) {
-        setError(userError ? `User profile error: ${userError.message}` : 'Profile not found');
-        updateNotificationCount(0);
-        setLoading(false);
-        return;
-      }
-
-
-      const userDepartmentId = userData?.d_uuid;
-
-
-      // Calculate date 4 days ago
-      const fourDaysAgo = new Date();
-      fourDaysAgo.setDate(fourDaysAgo.getDate() - 4);
-      fourDaysAgo.setHours(0, 0, 0, 0);
-      const fourDaysAgoStr = fourDaysAgo.toISOString();
-
-      // All users see the same notifications
-      let notificationsQuery = supabase
-        .from('notifications')
-        .select('notif_id, f_uuid, is_seen, is_sent, created_at')
-        .eq('uuid', user.id)
-        .eq('is_seen', false)
-        .eq('is_sent', true)
-        .gte('created_at', fourDaysAgoStr)
-        .order('created_at', { ascending: false });
-
-      const { data, error } = await notificationsQuery;
-
-
-      if (error) {
-        setError(`Database error: ${error.message}`);
-        updateNotificationCount(0);
-        setLoading(false);
-        return;
-      }
-
-      if (!data || data.length === 0) {
-        setItems([]);
-        updateNotificationCount(0);
-        setLoading(false);
-        return;
-      }
-
-      // Get file details with department information
-      const fileIds = data.map(n => n.f_uuid);
-      const { data: fileData, error: fileError } = await supabase
-        .from('file')
-        .select(`
-          f_uuid, 
-          f_name, 
-          created_at, 
-          d_uuid,
-          department:d_uuid(d_name),
-          file_department(
-            fd_uuid,
-            d_uuid,
-            is_approved
-          )
-        `)
-        .in('f_uuid', fileIds);
-
-      if (fileError) {
-        setError(`File data error: ${fileError.message}`);
-        updateNotificationCount(0);
-        setLoading(false);
-        return;
-      }
-
-      // Create a map for quick lookup
-      const fileMap = {};
-      (fileData || []).forEach(file => {
-        fileMap[file.f_uuid] = file;
-      });
-
-
-
-      // Deduplicate notifications so only the latest per file is shown
-      const sortedByNewest = (data || []).slice().sort((a, b) => new Date(b.created_at) - new Date(a.created_at));
-      const seenFiles = new Set();
-      const latestPerFile = [];
-      for (const n of sortedByNewest) {
-        if (!seenFiles.has(n.f_uuid)) {
-          seenFiles.add(n.f_uuid);
-          latestPerFile.push(n);
-        }
-      }
-
-      const notificationItems = latestPerFile
-        .filter(notification => {
-          const file = fileMap[notification.f_uuid];
-          if (!file) return false;
-          return true;
-        })
-        .map(notification => {
-          const file = fileMap[notification.f_uuid];
-          const isFromSameDept = file.d_uuid === userDepartmentId;
-
-          return {
-            notif_id: notification.notif_id,
-            f_uuid: notification.f_uuid,
-            f_name: file.f_name || 'Unknown file',
-            created_at: notification.created_at,
-            file_created_at: file.created_at,
-            dateGroup: formatDate(notification.created_at),
-            fromSameDept: isFromSameDept,
-            isPendingApproval: false,
-            sourceDepartment: file.department?.d_name || 'Unknown Department',
-            is_sent: notification.is_sent
-          };
-        });
+      // One request returns the newest unseen notification per file, already
+      // joined with file name, departments and summary snippet.
+      const notifications = [];
+      let cursor = null;
+      do {
+        const page = await listNotifications({ status: 'unseen', cursor, limit: 100 });
+        notifications.push(...page.notifications);
+        cursor = page.next_cursor;
+      } while (cursor);
+
+      const notificationItems = notifications.map(notification => ({
+        notif_id: notification.notif_id,
+        f_uuid: notification.f_uuid,
+        f_name: notification.f_name || 'Unknown file',
+        created_at: notification.created_at,
+        file_created_at: notification.file_created_at,
+        dateGroup: formatDate(notification.created_at),
+        fromSameDept: notification.from_same_dept,
+        isPendingApproval: false,
+        sourceDepartment: notification.source_department || 'Unknown Department',
+        summarySnippet: notification.summary_snippet,
+      }));
 
       // Group by date for display
       const groups = {};
@@ -262,7 +167,7 @@ const Notifications = () => {
       // Update the notification count in the context
       updateNotificationCount(notificationItems.length);
     } catch (err) {
-      setError(`Unexpected error: ${err.message}`);
+      setError(`Could not load notifications: ${err.message}`);
       updateNotificationCount(0);
     } finally {
       setLoading(false);
@@ -319,13 +224,30 @@ const Notifications = () => {
           <BellIcon className="h-6 w-6 text-gray-700" />
           Notifications
         </h1>
-        <button
-          onClick={fetchNotifications}
-          className="text-sm text-blue-600 hover:text-blue-800 flex items-center gap-1"
-        >
-          <ArrowPathIcon className="h-4 w-4" />
-          Refresh
-        </button>
+        <div className="flex items-center gap-4">
+          {items.length > 0 && (
+            <button
+              onClick={async () => {
+                try {
+                  await markAllNotificationsRead();
+                  fetchNotifications();
+                } catch (err) {
+                  setError(`Could not mark notifications as read: ${err.message}`);
+                }
+              }}
+              className="text-sm text-gray-600 hover:text-gray-800"
+            >
+              Mark all as read
+            </button>
+          )}
+          <button
+            onClick={fetchNotifications}
+            className="text-sm text-blue-600 hover:text-blue-800 flex items-center gap-1"
+          >
+            <ArrowPathIcon className="h-4 w-4" />
+            Refresh
+          </button>
+        </div>
       </div>
 
       {error && (
@@ -405,12 +327,15 @@ const Notifications = () => {
                             </>
                           )}
                         </div>
+                        {file.summarySnippet && (
+                          <p className="text-xs text-gray-500 mt-1 line-clamp-2">{file.summarySnippet}</p>
+                        )}
                       </div>
                     </div>
 
                     <div className="flex items-center gap-2 ml-4 flex-shrink-0">
                       <a
-                        href={`/file/${file.f_uuid}?from=notification`}
+                        href={`/file/${file.f_uuid}?from=notification&notif=${file.notif_id}`}
                         target="_blank"
                         rel="noopener noreferrer"
                         className="inline-flex items-center justify-center px-3.5 py-1.5 bg-blue-500 hover:bg-blue-600 text-white rounded-md text-sm font-medium transition-colors"
@@ -6,6 +6,7 @@ import {
     ArrowPathIcon
 } from '@heroicons/react/24/outline';
 import { supabase } from '../supabaseClient';
+import { createQuickShare } from '../utils/quickShareApi';
 
 const QuickShare = ({ userProfile, onMessageSent }) => {
     const [message, setMessage] = useState('');
@@ -108,28 +109,14 @@ const QuickShare = ({ userProfile, onMessageSent }) => {
         setSubmitStatus({ type: '', message: '' });
         
         try {
-            // Create JSON data object
-            const jsonData = {
-                message: message,
-                timestamp: new Date().toISOString(),
-                priority: "normal"
-            };
-            
-            // Insert entry into quick_share table
-            const { error } = await supabase
-                .from('quick_share')
-                .insert([
-                    {
-                        data: jsonData,
-                        d_uuid: selectedDepartment.d_uuid, // Using the correct d_uuid field from schema
-                        uuid: userProfile.uuid,
-                        created_at: new Date().toISOString()
-                    }
-                ]);
-                
-            if (error) {
-                throw error;
-            }
+            // The first line doubles as the subject until the form has its own field
+            const firstLine = message.trim().split('\n')[0];
+            await createQuickShare({
+                subject: firstLine.length > 80 ? `${firstLine.slice(0, 77)}...` : firstLine,
+                body: message,
+                priority: "normal",
+                departments: [selectedDepartment.d_uuid],
+            });
             
             // Call the onMessageSent callback if provided
             if (typeof onMessageSent === 'function') {
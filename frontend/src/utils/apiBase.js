@@ -0,0 +1,15 @@
+// Base URL of the Go backend, shared by the user-facing API helpers.
+
+const DEFAULT_PROD_API_BASE = "https://metroinflow.onrender.com";
+
+export const API_BASE = process.env.REACT_APP_API_URL || (
+  typeof window !== "undefined" &&
+  (window.location.hostname === "localhost" || window.location.hostname === "127.0.0.1")
+    ? "http://localhost:8080"
+    : DEFAULT_PROD_API_BASE
+);
+
+// Websocket URL for a backend path (http -> ws, https -> wss).
+export function wsURL(path) {
+  return API_BASE.replace(/^http/, "ws") + path;
+}
@@ -0,0 +1,34 @@
+// Client for the /v1/files/{id}/comments endpoints of the Go backend.
+import { request } from "./apiClient";
+
+const base = (fUuid) => `/v1/files/${encodeURIComponent(fUuid)}/comments`;
+
+// Threads oldest first; each root comment carries its replies.
+export async function listComments(fUuid) {
+  const { comments } = await request(base(fUuid));
+  return comments;
+}
+
+// anchor is optional: { page, start, end } with start/end counting characters of the OCR text.
+export function addComment(fUuid, body, { parentId = "", anchor = null } = {}) {
+  return request(base(fUuid), {
+    method: "POST",
+    body: JSON.stringify({ body, parent_id: parentId, anchor }),
+  });
+}
+
+export function editComment(fUuid, commentId, body) {
+  return request(`${base(fUuid)}/${encodeURIComponent(commentId)}`, {
+    method: "PATCH",
+    body: JSON.stringify({ body }),
+  });
+}
+
+export function deleteComment(fUuid, commentId) {
+  return request(`${base(fUuid)}/${encodeURIComponent(commentId)}`, { method: "DELETE" });
+}
+
+export async function commentHistory(fUuid, commentId) {
+  const { revisions } = await request(`${base(fUuid)}/${encodeURIComponent(commentId)}/history`);
+  return revisions;
+}
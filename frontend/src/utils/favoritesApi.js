@@ -0,0 +1,58 @@
+// Client for the /v1/me/favorites and /v1/me/collections endpoints of the Go backend.
+import { request } from "./apiClient";
+
+export async function listFavorites() {
+  const { favorites } = await request("/v1/me/favorites");
+  return favorites;
+}
+
+export function addFavorite(fUuid) {
+  return request(`/v1/me/favorites/${encodeURIComponent(fUuid)}`, { method: "PUT" });
+}
+
+export function removeFavorite(fUuid) {
+  return request(`/v1/me/favorites/${encodeURIComponent(fUuid)}`, { method: "DELETE" });
+}
+
+// Returns { collections, shared }; shared ones are read-only.
+export function listCollections() {
+  return request("/v1/me/collections");
+}
+
+export function getCollection(id) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}`);
+}
+
+export function createCollection({ name, description = "", shared = false }) {
+  return request("/v1/me/collections", { method: "POST", body: JSON.stringify({ name, description, shared }) });
+}
+
+// patch: any of { name, description, shared }.
+export function updateCollection(id, patch) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}`, { method: "PATCH", body: JSON.stringify(patch) });
+}
+
+export function deleteCollection(id) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}`, { method: "DELETE" });
+}
+
+export function addToCollection(id, fUuid) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}/items`, {
+    method: "POST",
+    body: JSON.stringify({ f_uuid: fUuid }),
+  });
+}
+
+// fUuids must list every file in the collection, in the new order.
+export function reorderCollection(id, fUuids) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}/items`, {
+    method: "PUT",
+    body: JSON.stringify({ f_uuids: fUuids }),
+  });
+}
+
+export function removeFromCollection(id, fUuid) {
+  return request(`/v1/me/collections/${encodeURIComponent(id)}/items/${encodeURIComponent(fUuid)}`, {
+    method: "DELETE",
+  });
+}
@@ -0,0 +1,66 @@
+// Client for the /v1/files endpoints of the Go backend.
+import { supabase } from "../supabaseClient";
+import { API_BASE } from "./apiBase";
+import { request } from "./apiClient";
+
+// Fetches a file through the permission-checked download endpoint. Returns
+// the blob, whether it must be shown inline, and the watermark to stamp on
+// it (set for confidential and restricted files).
+export async function downloadFile(fuuid) {
+  const { data } = await supabase.auth.getSession();
+  const token = data?.session?.access_token;
+  const res = await fetch(`${API_BASE}/v1/files/${encodeURIComponent(fuuid)}/download`, {
+    headers: token ? { Authorization: `Bearer ${token}` } : {},
+  });
+  if (!res.ok) {
+    const body = await res.json().catch(() => ({}));
+    throw new Error(body.error || `Download failed (${res.status})`);
+  }
+  const disposition = res.headers.get("Content-Disposition") || "";
+  return {
+    blob: await res.blob(),
+    inline: disposition.startsWith("inline"),
+    watermark: res.headers.get("X-Watermark") || "",
+  };
+}
+
+// Fetches a file's page-1 thumbnail as an object URL, or null when the file
+// has none (unsupported type, no access). Revoke the URL when done.
+export async function fetchThumbnail(fuuid) {
+  const { data } = await supabase.auth.getSession();
+  const token = data?.session?.access_token;
+  const res = await fetch(`${API_BASE}/v1/files/${encodeURIComponent(fuuid)}/thumbnail`, {
+    headers: token ? { Authorization: `Bearer ${token}` } : {},
+  });
+  if (!res.ok) return null;
+  return URL.createObjectURL(await res.blob());
+}
+
+// Lists files through /v1/files, or /v1/departments/{dUUID}/files when
+// dUUID is set. filters: language, status, uploader, from, to, has_summary,
+// category, q, sort, order, limit. Pass the previous page's next_cursor to
+// continue.
+export function listFiles({ dUUID, cursor, ...filters } = {}) {
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([key, value]) => {
+    if (value !== undefined && value !== null && value !== "") params.set(key, String(value));
+  });
+  if (cursor) params.set("cursor", cursor);
+  const base = dUUID ? `/v1/departments/${encodeURIComponent(dUUID)}/files` : "/v1/files";
+  return request(`${base}?${params}`);
+}
+
+// Moves a file to the trash. Returns the file with deleted_at and purge_at.
+export function deleteFile(fuuid) {
+  return request(`/v1/files/${encodeURIComponent(fuuid)}`, { method: "DELETE" });
+}
+
+// Takes a file out of the trash; fails once its restore window has passed.
+export function restoreFile(fuuid) {
+  return request(`/v1/files/${encodeURIComponent(fuuid)}/restore`, { method: "POST" });
+}
+
+// Lists the trashed files the caller may restore, with retention_days.
+export function listTrash() {
+  return request("/v1/trash");
+}
@@ -0,0 +1,23 @@
+// Client for the /v1/notifications endpoints of the Go backend.
+import { request } from "./apiClient";
+
+// status: "unseen" | "seen" | "all"; pass the previous page's next_cursor to continue.
+export function listNotifications({ status = "all", cursor, limit } = {}) {
+  const params = new URLSearchParams({ status });
+  if (cursor) params.set("cursor", cursor);
+  if (limit) params.set("limit", String(limit));
+  return request(`/v1/notifications?${params}`);
+}
+
+export async function fetchUnreadCount() {
+  const { count } = await request("/v1/notifications/unread-count");
+  return count;
+}
+
+export function markNotificationsRead(notifIds) {
+  return request("/v1/notifications/read", { method: "POST", body: JSON.stringify({ notif_ids: notifIds }) });
+}
+
+export function markAllNotificationsRead() {
+  return request("/v1/notifications/read", { method: "POST", body: JSON.stringify({ all: true }) });
+}
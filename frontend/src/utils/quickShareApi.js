@@ -0,0 +1,26 @@
+// Client for the /v1/quickshare endpoints of the Go backend.
+import { request } from "./apiClient";
+
+// draft: { subject, body, priority, departments: [d_uuid], attachments: [f_uuid] }
+export function createQuickShare(draft) {
+  return request("/v1/quickshare", { method: "POST", body: JSON.stringify(draft) });
+}
+
+export function listQuickShares({ box = "inbox", before, limit } = {}) {
+  const params = new URLSearchParams({ box });
+  if (before) params.set("before", before);
+  if (limit) params.set("limit", String(limit));
+  return request(`/v1/quickshare?${params}`);
+}
+
+export function getQuickShareThread(id) {
+  return request(`/v1/quickshare/${id}`);
+}
+
+export function replyToQuickShare(id, reply) {
+  return request(`/v1/quickshare/${id}/reply`, { method: "POST", body: JSON.stringify(reply) });
+}
+
+export function markQuickShareRead(id) {
+  return request(`/v1/quickshare/${id}/read`, { method: "POST" });
+}
@@ -0,0 +1,41 @@
+// Client for the /v1/workflows endpoints of the Go backend.
+import { request } from "./apiClient";
+
+const base = "/v1/workflows";
+
+// Active templates an upload may start (send the id as workflow_template_id).
+export async function listWorkflowTemplates() {
+  const { templates } = await request("/v1/workflow-templates");
+  return templates;
+}
+
+// view is "pending" (waiting for my decision) or "started" (my uploads).
+export async function listWorkflows(view = "pending") {
+  const { workflows } = await request(`${base}?view=${encodeURIComponent(view)}`);
+  return workflows;
+}
+
+export async function listFileWorkflows(fUuid) {
+  const { workflows } = await request(`${base}?f_uuid=${encodeURIComponent(fUuid)}`);
+  return workflows;
+}
+
+export function getWorkflow(id) {
+  return request(`${base}/${encodeURIComponent(id)}`);
+}
+
+// step is optional; it picks one of several steps waiting for the caller.
+export function approveWorkflow(id, comment = "", step = null) {
+  return request(`${base}/${encodeURIComponent(id)}/approve`, {
+    method: "POST",
+    body: JSON.stringify({ comment, step }),
+  });
+}
+
+// Rejections need a comment.
+export function rejectWorkflow(id, comment, step = null) {
+  return request(`${base}/${encodeURIComponent(id)}/reject`, {
+    method: "POST",
+    body: JSON.stringify({ comment, step }),
+  });
+}
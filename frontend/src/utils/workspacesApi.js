@@ -0,0 +1,66 @@
+// Client for the /v1/workspaces endpoints of the Go backend.
+import { request } from "./apiClient";
+
+const base = (id) => `/v1/workspaces/${encodeURIComponent(id)}`;
+
+// Workspaces of the caller's department; heads also get pending invitations (role "invited").
+export async function listWorkspaces() {
+  const { workspaces } = await request("/v1/workspaces");
+  return workspaces;
+}
+
+export function getWorkspace(id) {
+  return request(base(id));
+}
+
+export function createWorkspace({ name, description = "" }) {
+  return request("/v1/workspaces", { method: "POST", body: JSON.stringify({ name, description }) });
+}
+
+export function updateWorkspace(id, patch) {
+  return request(base(id), { method: "PATCH", body: JSON.stringify(patch) });
+}
+
+export function deleteWorkspace(id) {
+  return request(base(id), { method: "DELETE" });
+}
+
+export function inviteDepartment(id, dUuid) {
+  return request(`${base(id)}/departments`, { method: "POST", body: JSON.stringify({ d_uuid: dUuid }) });
+}
+
+// Removes a department; heads use it on their own department to leave or decline.
+export function removeDepartment(id, dUuid) {
+  return request(`${base(id)}/departments/${encodeURIComponent(dUuid)}`, { method: "DELETE" });
+}
+
+export function joinWorkspace(id) {
+  return request(`${base(id)}/join`, { method: "POST" });
+}
+
+export async function listWorkspaceFiles(id) {
+  const { files } = await request(`${base(id)}/files`);
+  return files;
+}
+
+export function linkFile(id, fUuid) {
+  return request(`${base(id)}/files`, { method: "POST", body: JSON.stringify({ f_uuid: fUuid }) });
+}
+
+export function unlinkFile(id, fUuid) {
+  return request(`${base(id)}/files/${encodeURIComponent(fUuid)}`, { method: "DELETE" });
+}
+
+export async function listWorkspaceComments(id) {
+  const { comments } = await request(`${base(id)}/comments`);
+  return comments;
+}
+
+// fUuid is optional and must be a file linked into the workspace.
+export function addWorkspaceComment(id, body, fUuid) {
+  return request(`${base(id)}/comments`, { method: "POST", body: JSON.stringify({ body, f_uuid: fUuid || "" }) });
+}
+
+export function deleteWorkspaceComment(id, commentId) {
+  return request(`${base(id)}/comments/${encodeURIComponent(commentId)}`, { method: "DELETE" });
+}